@@ -0,0 +1,82 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package email_broker
+
+import (
+	"bytes"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/extractors"
+	log "github.com/Sirupsen/logrus"
+	"github.com/jhillyerd/go.enmime"
+	"net/mail"
+	"time"
+)
+
+// extractAttachmentsText returns the text found within the attachments of a raw email,
+// for attachments' formats that extractors package is able to handle.
+// Attachments that fail to be extracted are skipped.
+func (b *EmailBroker) extractAttachmentsText(raw string) (texts []AttachmentText) {
+	texts = []AttachmentText{}
+	msg, err := mail.ReadMessage(bytes.NewReader([]byte(raw)))
+	if err != nil {
+		return
+	}
+	mm, err := enmime.ParseMIMEBody(msg)
+	if err != nil {
+		return
+	}
+	limits := extractors.DefaultLimits
+	if b.Config.AttachmentsText.MaxSize > 0 {
+		limits.MaxSize = b.Config.AttachmentsText.MaxSize
+	}
+	if b.Config.AttachmentsText.MaxText > 0 {
+		limits.MaxText = b.Config.AttachmentsText.MaxText
+	}
+	if b.Config.AttachmentsText.Timeout > 0 {
+		limits.Timeout = time.Duration(b.Config.AttachmentsText.Timeout) * time.Second
+	}
+
+	parts := append([]enmime.MIMEPart{}, mm.Attachments...)
+	parts = append(parts, mm.Inlines...)
+	for _, part := range parts {
+		if !extractors.CanExtract(part.ContentType(), part.FileName()) {
+			continue
+		}
+		text, err := extractors.ExtractText(part.Content(), part.ContentType(), part.FileName(), limits)
+		if err != nil {
+			log.WithError(err).Infof("[EmailBroker] text extraction failed for attachment %s", part.FileName())
+			continue
+		}
+		if text != "" {
+			texts = append(texts, AttachmentText{
+				ContentType: part.ContentType(),
+				FileName:    part.FileName(),
+				Text:        text,
+			})
+		}
+	}
+	return
+}
+
+// indexAttachmentsText extracts text from attachments of an inbound raw email,
+// then adds it to the indexed messages that have been created for each recipient.
+// messages is a map of message_id -> user_id
+func (b *EmailBroker) indexAttachmentsText(messages map[string]UUID, raw string) {
+	if !b.Config.AttachmentsText.Enabled || b.Index == nil || len(messages) == 0 {
+		return
+	}
+	texts := b.extractAttachmentsText(raw)
+	if len(texts) == 0 {
+		return
+	}
+	for msg_id, user_id := range messages {
+		err := b.Index.IndexAttachmentsText(user_id.String(), msg_id, texts)
+		if err != nil {
+			log.WithError(err).Warnf("[EmailBroker] failed to index attachments' text for message %s", msg_id)
+		}
+	}
+}
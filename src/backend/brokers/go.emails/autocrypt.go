@@ -0,0 +1,172 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package email_broker
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/pgp"
+	log "github.com/Sirupsen/logrus"
+	"github.com/satori/go.uuid"
+	"golang.org/x/crypto/openpgp"
+	"net/mail"
+	"time"
+)
+
+// learntKey is an OpenPGP key advertised by the sender of an email
+type learntKey struct {
+	entity        *openpgp.Entity
+	source        string // pgp.KeySourceAutocrypt or pgp.KeySourceAttachment
+	preferEncrypt string // Autocrypt keys only
+}
+
+// harvestKeys saves the OpenPGP keys that the sender of raw email advertises, in an Autocrypt header
+// or in application/pgp-keys attachments, as public keys of user's contacts that have sender's address.
+// A known key is updated only if email is more recent than the last one it has been seen in ;
+// a newer Autocrypt key replaces the contact's previous Autocrypt key.
+func (b *EmailBroker) harvestKeys(user_id string, raw []byte) {
+	lower := bytes.ToLower(raw)
+	if !bytes.Contains(lower, []byte("autocrypt:")) && !bytes.Contains(lower, []byte("application/pgp-keys")) {
+		return
+	}
+	parsed, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return
+	}
+	from, err := mail.ParseAddress(parsed.Header.Get("From"))
+	if err != nil {
+		return
+	}
+	now := time.Now()
+	date, err := parsed.Header.Date()
+	if err != nil || date.After(now) {
+		date = now
+	}
+
+	var keys []learntKey
+	if header := pgp.EmailAutocrypt(parsed.Header, from.Address); header != nil {
+		keys = append(keys, learntKey{header.Key, pgp.KeySourceAutocrypt, header.PreferEncrypt})
+	}
+	for _, entity := range pgp.AttachedKeys(raw) {
+		// keys of third parties may be attached too, only sender's ones are learnt
+		if pgp.HasAddress(entity, from.Address) {
+			keys = append(keys, learntKey{entity, pgp.KeySourceAttachment, ""})
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+
+	contact_ids, err := b.Store.LookupContactsByIdentifier(user_id, from.Address)
+	if err != nil {
+		log.WithError(err).Warnf("[EmailBroker] failed to lookup contacts of %s", from.Address)
+		return
+	}
+	for _, contact_id := range contact_ids {
+		contact, err := b.Store.RetrieveContact(user_id, contact_id)
+		if err != nil || contact == nil {
+			continue
+		}
+		for _, key := range keys {
+			if err = b.saveContactKey(contact, key, from.Address, date, now); err != nil {
+				log.WithError(err).Warnf("[EmailBroker] failed to save OpenPGP key of contact %s", contact_id)
+			}
+		}
+	}
+}
+
+// saveContactKey creates or updates the public key of contact matching learnt key
+func (b *EmailBroker) saveContactKey(contact *Contact, key learntKey, address string, date, now time.Time) error {
+	fingerprint := fmt.Sprintf("%X", key.entity.PrimaryKey.Fingerprint)
+	var existing *PublicKey
+	for i, k := range contact.PublicKeys {
+		if string(k.Fingerprint) == fingerprint {
+			existing = &contact.PublicKeys[i]
+			break
+		}
+	}
+	if existing == nil && key.source == pgp.KeySourceAutocrypt {
+		for i, k := range contact.PublicKeys {
+			if k.PrivacyFeatures != nil && (*k.PrivacyFeatures)[pgp.KeySourceFeature] == pgp.KeySourceAutocrypt {
+				existing = &contact.PublicKeys[i]
+				break
+			}
+		}
+	}
+	if existing != nil && existing.PrivacyFeatures != nil {
+		if seen, err := time.Parse(time.RFC3339, (*existing.PrivacyFeatures)[pgp.AutocryptTimestampFeature]); err == nil && !date.After(seen) {
+			return nil
+		}
+	}
+
+	armored, err := pgp.ArmoredPublicKey(key.entity)
+	if err != nil {
+		return err
+	}
+	features := PrivacyFeatures{
+		pgp.KeySourceFeature:          key.source,
+		pgp.AutocryptTimestampFeature: date.UTC().Format(time.RFC3339),
+	}
+	if key.source == pgp.KeySourceAutocrypt {
+		features[pgp.AutocryptPreferEncryptFeature] = key.preferEncrypt
+	}
+
+	if existing != nil {
+		if existing.PrivacyFeatures != nil {
+			// a key seen as Autocrypt one keeps its source and prefer-encrypt state when it is attached later
+			for name, value := range *existing.PrivacyFeatures {
+				if _, ok := features[name]; !ok {
+					features[name] = value
+				}
+			}
+		}
+		existing.Key = armored
+		existing.Fingerprint = []byte(fingerprint)
+		existing.ExpireDate = pgp.ExpireDate(key.entity)
+		existing.DateUpdate = now
+		existing.PrivacyFeatures = &features
+		return b.Store.UpdatePublicKey(existing)
+	}
+	created := &PublicKey{
+		DateInsert:      now,
+		DateUpdate:      now,
+		ExpireDate:      pgp.ExpireDate(key.entity),
+		Fingerprint:     []byte(fingerprint),
+		Key:             armored,
+		KeyId:           UUID(uuid.NewV4()),
+		KeyType:         "gpg",
+		Label:           address,
+		PrivacyFeatures: &features,
+		ResourceId:      contact.ContactId,
+		ResourceType:    "contact",
+		Use:             "enc",
+		UserId:          contact.UserId,
+	}
+	if err = b.Store.CreatePublicKey(created); err != nil {
+		return err
+	}
+	contact.PublicKeys = append(contact.PublicKeys, *created)
+	return nil
+}
+
+// autocryptHeader returns the Autocrypt header value advertising the key of sender in broker's keyring,
+// empty if there is none. Encryption is preferred unless user never encrypts outbound messages.
+func (b *EmailBroker) autocryptHeader(user_id UUID, sender string) string {
+	entity := b.PGPKeyring.Entity(sender)
+	if entity == nil {
+		return ""
+	}
+	preferEncrypt := pgp.PreferEncryptMutual
+	if settings, err := b.Store.GetSettings(user_id.String()); err == nil && settings != nil && settings.OutboundEncryption == ProtectionNever {
+		preferEncrypt = pgp.PreferEncryptNoPreference
+	}
+	value, err := pgp.AutocryptHeaderValue(sender, entity, preferEncrypt)
+	if err != nil {
+		log.WithError(err).Warnf("[EmailBroker] failed to build Autocrypt header of %s", sender)
+		return ""
+	}
+	return value
+}
@@ -10,8 +10,11 @@ import (
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/index/elasticsearch"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/cassandra"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/facilities/Notifications"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/pgp"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/pi"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gocql/gocql"
 	"github.com/nats-io/go-nats"
@@ -22,8 +25,11 @@ type (
 		Config            LDAConfig
 		Connectors        EmailBrokerConnectors
 		Index             backends.LDAIndex
-		NatsConn          *nats.Conn
+		NatsConn          backends.MessageBus
 		Notifier          Notifications.Notifiers
+		PGPKeyring        *pgp.Keyring // secret keys of local identities
+		PGPKeystore       pgp.Keystore // users' secret keys, nil if server side decryption is disabled
+		PIEngine          *pi.Engine
 		Store             backends.LDAStore
 		natsSubscriptions []*nats.Subscription
 	}
@@ -35,18 +41,21 @@ type (
 
 	SmtpEmail struct {
 		EmailMessage *EmailMessage
+		Import       *ImportedMessage // set when email comes from a mailbox import
 		Response     chan *DeliveryAck
 	}
 
 	natsOrder struct {
-		Order     string `json:"order"`
-		MessageId string `json:"message_id"`
-		UserId    string `json:"user_id"`
+		Order     string           `json:"order"`
+		MessageId string           `json:"message_id"`
+		UserId    string           `json:"user_id"`
+		Import    *ImportedMessage `json:"import,omitempty"`
 	}
 
 	natsAck struct {
-		Error   string `json:"error,omitempty"`
-		Message string `json:"message"`
+		Error     string `json:"error,omitempty"`
+		Message   string `json:"message"`
+		MessageId string `json:"message_id,omitempty"`
 	}
 )
 
@@ -58,6 +67,23 @@ func Initialize(conf LDAConfig) (broker *EmailBroker, connectors EmailBrokerConn
 	var e error
 	broker = &EmailBroker{}
 	broker.Config = conf
+	engine := *pi.DefaultEngine
+	engine.AuthservID = conf.AuthservID
+	broker.PIEngine = &engine
+	if conf.PGPKeyring != "" {
+		if broker.PGPKeyring, e = pgp.LoadKeyring(conf.PGPKeyring); e != nil {
+			log.WithError(e).Warn("[EmailBroker] failed to load OpenPGP keyring, outbound emails won't be signed")
+		}
+	}
+	if conf.PGPKeystore.Type != "" {
+		broker.PGPKeystore, e = pgp.InitializeKeystore(pgp.KeystoreConfig{
+			KeystoreType: conf.PGPKeystore.Type,
+			Path:         conf.PGPKeystore.Path,
+		})
+		if e != nil {
+			log.WithError(e).Warn("[EmailBroker] failed to initialize OpenPGP keystore, inbound emails won't be decrypted")
+		}
+	}
 	switch conf.StoreName {
 	case "cassandra":
 		c := store.CassandraConfig{
@@ -66,8 +92,10 @@ func Initialize(conf LDAConfig) (broker *EmailBroker, connectors EmailBrokerConn
 			Consistency: gocql.Consistency(conf.StoreConfig.Consistency),
 			SizeLimit:   conf.StoreConfig.SizeLimit,
 		}
-		if conf.StoreConfig.ObjectStore == "s3" {
+		if conf.StoreConfig.ObjectStore == "s3" || conf.StoreConfig.ObjectStore == "fs" {
 			c.WithObjStore = true
+			c.StoreType = conf.StoreConfig.ObjectStore
+			c.RootPath = conf.StoreConfig.OSSConfig.RootPath
 			c.Endpoint = conf.StoreConfig.OSSConfig.Endpoint
 			c.AccessKey = conf.StoreConfig.OSSConfig.AccessKey
 			c.SecretKey = conf.StoreConfig.OSSConfig.SecretKey
@@ -75,6 +103,11 @@ func Initialize(conf LDAConfig) (broker *EmailBroker, connectors EmailBrokerConn
 			c.AttachmentBucket = conf.StoreConfig.OSSConfig.Buckets["temporary_attachments"]
 			c.Location = conf.StoreConfig.OSSConfig.Location
 		}
+		if conf.StoreConfig.Encryption == "local" {
+			c.WithEncryption = true
+			c.KMSType = conf.StoreConfig.Encryption
+			c.KeyringPath = conf.StoreConfig.KMSConfig.KeyringPath
+		}
 		b, e := store.InitializeCassandraBackend(c)
 		if e != nil {
 			err = e
@@ -82,6 +115,9 @@ func Initialize(conf LDAConfig) (broker *EmailBroker, connectors EmailBrokerConn
 			return
 		}
 
+		broker.Store = backends.LDAStore(b) // type conversion to LDA interface
+	case "memory":
+		b, _ := memory.InitializeMemoryBackend()
 		broker.Store = backends.LDAStore(b) // type conversion to LDA interface
 	default:
 		log.Warnf("[EmailBroker] unknown store backend: %s", conf.StoreName)
@@ -101,14 +137,30 @@ func Initialize(conf LDAConfig) (broker *EmailBroker, connectors EmailBrokerConn
 			return
 		}
 
+		broker.Index = backends.LDAIndex(i) // type conversion to LDA interface
+	case "memory":
+		i, _ := memory.InitializeMemoryIndex()
 		broker.Index = backends.LDAIndex(i) // type conversion to LDA interface
 	}
 
-	broker.NatsConn, e = nats.Connect(conf.NatsURL)
-	if e != nil {
-		err = e
-		log.WithError(err).Warn("[EmailBroker] initalization of NATS connexion failed")
-		return
+	switch conf.NatsURL {
+	case "memory":
+		broker.NatsConn, _ = memory.InitializeMemoryBus()
+		// Python handler of inbound orders can't reach the in-process bus, broker delivers messages itself
+		e = broker.startDeliveryAgent()
+		if e != nil {
+			err = e
+			log.WithError(err).Warn("[EmailBroker] failed to start delivery agent")
+			return
+		}
+	default:
+		conn, e := nats.Connect(conf.NatsURL)
+		if e != nil {
+			err = e
+			log.WithError(err).Warn("[EmailBroker] initalization of NATS connexion failed")
+			return
+		}
+		broker.NatsConn = conn
 	}
 	switch conf.BrokerType {
 	case "smtp":
@@ -10,25 +10,43 @@ import (
 
 type (
 	LDAConfig struct {
-		AppVersion       string         `mapstructure:"version"`
-		BrokerType       string         `mapstructure:"broker_type"`
-		ContactsTopic    string         `mapstructure:"contacts_topic"`
-		InTopic          string         `mapstructure:"in_topic"`
-		InWorkers        int            `mapstructure:"lda_workers_size"`
-		IndexConfig      IndexConfig    `mapstructure:"index_settings"`
-		IndexName        string         `mapstructure:"index_name"`
-		LogReceivedMails bool           `mapstructure:"log_received_mails"`
-		NatsListeners    int            `mapstructure:"nats_listeners"`
-		NatsQueue        string         `mapstructure:"nats_queue"`
-		NatsURL          string         `mapstructure:"nats_url"`
-		NotifierConfig   NotifierConfig `mapstructure:"NotifierConfig"`
-		OutTopic         string         `mapstructure:"out_topic"`
-		PrimaryMailHost  string         `mapstructure:"primary_mail_host"`
-		StoreConfig      StoreConfig    `mapstructure:"store_settings"`
-		StoreName        string         `mapstructure:"store_name"`
+		AppVersion       string                `mapstructure:"version"`
+		AttachmentsText  AttachmentsTextConfig `mapstructure:"attachments_text"`
+		AuthservID       string                `mapstructure:"authserv_id"` // authserv-id of the Authentication-Results headers added by our MTA, others are ignored
+		BrokerType       string                `mapstructure:"broker_type"`
+		ContactsTopic    string                `mapstructure:"contacts_topic"`
+		InTopic          string                `mapstructure:"in_topic"`
+		InWorkers        int                   `mapstructure:"lda_workers_size"`
+		IndexConfig      IndexConfig           `mapstructure:"index_settings"`
+		IndexName        string                `mapstructure:"index_name"`
+		LogReceivedMails bool                  `mapstructure:"log_received_mails"`
+		NatsListeners    int                   `mapstructure:"nats_listeners"`
+		NatsQueue        string                `mapstructure:"nats_queue"`
+		NatsURL          string                `mapstructure:"nats_url"`
+		NotifierConfig   NotifierConfig        `mapstructure:"NotifierConfig"`
+		OutTopic         string                `mapstructure:"out_topic"`
+		PGPKeyring       string                `mapstructure:"pgp_keyring"` // directory of local identities' OpenPGP secret keys, one <address>.asc file each
+		PGPKeystore      PGPKeystoreConfig     `mapstructure:"pgp_keystore"`
+		PrimaryMailHost  string                `mapstructure:"primary_mail_host"`
+		StoreConfig      StoreConfig           `mapstructure:"store_settings"`
+		StoreName        string                `mapstructure:"store_name"`
 	}
 
 	IndexConfig struct {
 		Urls []string `mapstructure:"urls"`
 	}
+
+	// text extraction from inbound attachments, for indexing purpose
+	AttachmentsTextConfig struct {
+		Enabled bool  `mapstructure:"enabled"`
+		MaxSize int64 `mapstructure:"max_size"` // in bytes, larger attachments are not processed
+		MaxText int   `mapstructure:"max_text"` // in bytes, extracted text is truncated beyond
+		Timeout int   `mapstructure:"timeout"`  // in seconds, max duration of extraction for one attachment
+	}
+
+	// users' OpenPGP secret keys, for server side decryption of inbound emails
+	PGPKeystoreConfig struct {
+		Type string `mapstructure:"type"` // only "fs" for now, empty to disable decryption
+		Path string `mapstructure:"path"` // for "fs" keystore : one <user_id> directory of *.asc files per user
+	}
 )
@@ -0,0 +1,108 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+// package email_broker handles codec/decodec between emails and Caliopen message format
+package email_broker
+
+/* delivery logic, in place of Python's NATS handler when broker runs on the in-process bus :
+- subscribe to 'process_raw' orders sent by inbound on NATS topic « inboundSMTP »
+- for each order
+	retrieves raw email from db
+	builds user's message from it
+	stores and indexes message
+	replies with the new message_id, as Python's handler does
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/outbox"
+	log "github.com/Sirupsen/logrus"
+	"github.com/nats-io/go-nats"
+	"github.com/satori/go.uuid"
+)
+
+// plainOrder decodes orders as plain JSON : natsOrder's own decoder only reads outbound 'deliver' orders
+type plainOrder natsOrder
+
+func (b *EmailBroker) startDeliveryAgent() error {
+	sub, err := b.NatsConn.QueueSubscribe(b.Config.InTopic, b.Config.NatsQueue, b.deliveryMsgHandler)
+	if err != nil {
+		return err
+	}
+	b.natsSubscriptions = append(b.natsSubscriptions, sub)
+	return nil
+}
+
+func (b *EmailBroker) deliveryMsgHandler(msg *nats.Msg) {
+	var order natsOrder
+	err := json.Unmarshal(msg.Data, (*plainOrder)(&order))
+	if err == nil && order.Order != "process_raw" {
+		err = fmt.Errorf("unhandled order %s", order.Order)
+	}
+	ack := natsAck{Message: "OK : inbound email message proceeded"}
+	if err == nil {
+		var m *Message
+		if m, err = b.deliverRawMessage(order); err == nil {
+			ack.MessageId = m.Message_id.String()
+		}
+	}
+	if err != nil {
+		log.WithError(err).Warnf("[EmailBroker] delivery failed for nats message : %s", msg.Data)
+		ack = natsAck{Error: err.Error(), Message: "inbound email message process failed"}
+	}
+	json_resp, _ := json.Marshal(ack)
+	b.NatsConn.Publish(msg.Reply, json_resp)
+}
+
+// deliverRawMessage makes user's message from a stored raw email, then saves it into store and index.
+// Original date, flags and tags of an imported message take precedence over computed ones.
+func (b *EmailBroker) deliverRawMessage(order natsOrder) (msg *Message, err error) {
+	raw, err := b.Store.GetRawMessage(order.MessageId)
+	if err != nil {
+		return nil, err
+	}
+	em := &EmailMessage{
+		Email:   &Email{},
+		Message: &Message{Raw_msg_id: raw.Raw_msg_id},
+	}
+	em.Email.Raw.WriteString(raw.Raw_data)
+	user_id := UUID(uuid.FromStringOrNil(order.UserId))
+	msg, err = b.UnmarshalEmail(em, user_id)
+	if err != nil {
+		return nil, err
+	}
+	if imported := order.Import; imported != nil {
+		msg.Is_unread = imported.IsUnread
+		if imported.Date != nil {
+			msg.Date_sort = *imported.Date
+		}
+		seen := map[string]bool{}
+		for _, tag := range imported.Tags {
+			if !seen[tag] {
+				seen[tag] = true
+				msg.Tags = append(msg.Tags, tag)
+			}
+		}
+	}
+
+	mutation, err := outbox.Record(b.Store, user_id, MessageType, msg.Message_id, IndexCreate)
+	if err != nil {
+		return nil, err
+	}
+	err = b.Store.CreateMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+	outbox.ApplyOrDefer(b.Store, b.Index, mutation)
+
+	if msg.External_references.Parent_id == "" && msg.External_references.Message_id != "" {
+		err = b.Store.CreateThreadLookup(user_id, msg.Discussion_id, msg.External_references.Message_id)
+		if err != nil {
+			log.WithError(err).Warn("[EmailBroker] Store.CreateThreadLookup operation failed")
+		}
+	}
+	return msg, nil
+}
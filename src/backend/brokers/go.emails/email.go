@@ -12,6 +12,7 @@ import (
 	"fmt"
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/messages"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/outbox"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gocql/gocql"
 	"github.com/jhillyerd/go.enmime"
@@ -105,6 +106,11 @@ func (b *EmailBroker) MarshalEmail(msg *Message) (em *EmailMessage, err error) {
 	}
 
 	m.SetHeader("X-Mailer", "Caliopen-"+b.Config.AppVersion)
+	if len(em.Email.SmtpMailFrom) > 0 {
+		if autocrypt := b.autocryptHeader(msg.User_id, em.Email.SmtpMailFrom[0]); autocrypt != "" {
+			m.SetHeader("Autocrypt", autocrypt)
+		}
+	}
 
 	//TODO: In-Reply-To header
 	m.SetHeader("Subject", msg.Subject)
@@ -147,6 +153,7 @@ func (b *EmailBroker) MarshalEmail(msg *Message) (em *EmailMessage, err error) {
 	m.WriteTo(&em.Email.Raw)
 	json_rep, _ := EmailToJsonRep(em.Email.Raw.String())
 	em.Email_json = &json_rep
+	err = b.protectEmail(em)
 	return
 }
 
@@ -173,7 +180,7 @@ func (b *EmailBroker) SaveIndexSentEmail(ack *DeliveryAck) error {
 		Raw_Size:   uint64(len(ack.EmailMessage.Email.Raw.String())),
 		Raw_data:   ack.EmailMessage.Email.Raw.String(),
 	}
-	err = b.Store.StoreRawMessage(m)
+	err = b.Store.StoreRawMessage(&m, []UUID{ack.EmailMessage.Message.User_id})
 	if err != nil {
 		log.WithError(err).Warn("[Email Broker] outbound: storing raw email failed")
 		return err
@@ -214,14 +221,19 @@ func (b *EmailBroker) SaveIndexSentEmail(ack *DeliveryAck) error {
 	fields["Date_sort"] = ack.EmailMessage.Message.Date_sort
 	fields["Attachments"] = ack.EmailMessage.Message.Attachments
 	fields["External_references"] = ack.EmailMessage.Message.External_references
-	err = b.Store.UpdateMessage(ack.EmailMessage.Message, fields)
+	fields["Privacy_features"] = ack.EmailMessage.Message.Privacy_features
+	mutation, err := outbox.Record(b.Store, ack.EmailMessage.Message.User_id, MessageType, ack.EmailMessage.Message.Message_id, IndexUpdate,
+		"Raw_msg_id", "Is_draft", "Date", "Date_sort", "Attachments", "External_references", "Privacy_features")
 	if err != nil {
-		log.WithError(err).Warn("[Email Broker] Store.UpdateMessage operation failed")
+		log.WithError(err).Warn("[Email Broker] outbox.Record operation failed")
 	}
-	err = b.Index.UpdateMessage(ack.EmailMessage.Message, fields)
+	err = b.Store.UpdateMessage(ack.EmailMessage.Message, fields)
 	if err != nil {
-		log.WithError(err).Warn("[Email Broker] Index.UpdateMessage operation failed")
+		log.WithError(err).Warn("[Email Broker] Store.UpdateMessage operation failed")
 	}
+	outbox.ApplyOrDefer(b.Store, b.Index, mutation)
+
+	go b.recordInteractions(ack.EmailMessage.Message, true)
 
 	// if needed :
 	// insert new entry into discussion_lookup table
@@ -237,42 +249,76 @@ func (b *EmailBroker) SaveIndexSentEmail(ack *DeliveryAck) error {
 	return err
 }
 
-// gets a raw email and transforms into a Caliopen 'message' object
-// belonging to an user
+// UnmarshalEmail builds user's message from a received email : participants, bodies,
+// attachments embedded in raw email and external references.
+// Message is not stored, it starts a new discussion.
 func (b *EmailBroker) UnmarshalEmail(em *EmailMessage, user_id UUID) (msg *Message, err error) {
-
-	parsed_mail, err := mail.ReadMessage(&em.Email.Raw)
+	raw := em.Email.Raw.String()
+	parsed_mail, err := mail.ReadMessage(strings.NewReader(raw))
 	if err != nil {
-		log.WithError(err).Warn("[Email Broker] unable to parse email with raw_id : %s", em.Message.Raw_msg_id)
+		log.WithError(err).Warnf("[Email Broker] unable to parse email with raw_id : %s", em.Message.Raw_msg_id)
 		return nil, err
 	}
-
-	var m_id UUID
-	m_id.UnmarshalBinary(uuid.NewV4().Bytes())
-	mail_date, err := parsed_mail.Header.Date()
+	json_email, err := EmailToJsonRep(raw)
 	if err != nil {
-		log.WithError(err).Warn("[Email Broker] unable to parse email's date")
+		log.WithError(err).Warnf("[Email Broker] unable to parse mime parts of email with raw_id : %s", em.Message.Raw_msg_id)
+		return nil, err
 	}
 
-	/*
-		mail_body, err := ioutil.ReadAll(parsed_mail.Body)
-		if err != nil {
-			log.WithError(err).Warn("unable to parse email's body")
-		}
-	*/
-	//TODO: Body parts, Attachments, Externals_references, identities, parent_id…
+	mail_date, e := parsed_mail.Header.Date()
+	if e != nil {
+		log.WithError(e).Warn("[Email Broker] unable to parse email's date")
+		mail_date = time.Now()
+	}
+	parents := externalIds(parsed_mail.Header.Get("In-Reply-To"))
+	//TODO: identities, thread lookup from external references
 	msg = &Message{
-		Date:             mail_date,
-		Date_insert:      time.Now(),
+		Attachments:   []Attachment{},
+		Body_html:     json_email.Html,
+		Body_plain:    json_email.Plain,
+		Date:          mail_date,
+		Date_insert:   time.Now(),
+		Discussion_id: UUID(uuid.NewV4()),
+		External_references: ExternalReferences{
+			Ancestors_ids: externalIds(parsed_mail.Header.Get("References")),
+			Message_id:    strings.Trim(parsed_mail.Header.Get("Message-Id"), "<> "),
+		},
+		Is_received:      true,
 		Is_unread:        true,
-		Message_id:       m_id,
+		Message_id:       UUID(uuid.NewV4()),
 		Participants:     []Participant{},
 		Privacy_features: &PrivacyFeatures{},
 		Raw_msg_id:       em.Message.Raw_msg_id,
 		Subject:          parsed_mail.Header.Get("subject"),
+		Tags:             []string{},
 		Type:             EmailProtocol,
 		User_id:          user_id,
 	}
+	msg.Date_sort = msg.Date_insert
+	if len(parents) > 0 {
+		msg.External_references.Parent_id = parents[0]
+	}
+	messages.SanitizeMessageBodies(msg)
+
+	// attachments are read from raw email by their index, as for sent emails
+	for part := range json_email.MimeRoot.Parts.Walk() {
+		if part.Is_attachment {
+			headers := textproto.MIMEHeader(part.Headers)
+			_, dparams, _ := mime.ParseMediaType(headers.Get("Content-Disposition"))
+			_, tparams, _ := mime.ParseMediaType(headers.Get("Content-Type"))
+			filename := dparams["filename"]
+			if filename == "" {
+				filename = tparams["name"]
+			}
+			msg.Attachments = append(msg.Attachments, Attachment{
+				ContentType:  part.ContentType,
+				FileName:     filename,
+				IsInline:     part.Is_inline,
+				Size:         len(part.Content),
+				MimeBoundary: part.Boundary,
+			})
+		}
+	}
 
 	for field := range newAddressesFields() {
 		p, err := b.unmarshalParticipants(parsed_mail.Header, field, user_id)
@@ -281,6 +327,17 @@ func (b *EmailBroker) UnmarshalEmail(em *EmailMessage, user_id UUID) (msg *Messa
 		}
 	}
 
+	return msg, nil
+}
+
+// externalIds returns the message ids found in a References or In-Reply-To header, without angle brackets
+func externalIds(header string) (ids []string) {
+	ids = []string{}
+	for _, id := range strings.Fields(header) {
+		if id = strings.Trim(id, "<>"); id != "" {
+			ids = append(ids, id)
+		}
+	}
 	return
 }
 
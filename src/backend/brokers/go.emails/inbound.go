@@ -165,7 +165,8 @@ func (b *EmailBroker) processInbound(rcptsIds []UUID, in *SmtpEmail, raw_only bo
 		Raw_Size:   uint64(len(in.EmailMessage.Email.Raw.String())),
 		Raw_data:   in.EmailMessage.Email.Raw.String(),
 	}
-	err = b.Store.StoreRawMessage(m)
+	// a raw email already received for other recipients is stored only once
+	err = b.Store.StoreRawMessage(&m, rcptsIds)
 	if err != nil {
 		log.WithError(err).Warn("inbound: storing raw email failed")
 		resp.Response = "storing raw email failed"
@@ -175,6 +176,8 @@ func (b *EmailBroker) processInbound(rcptsIds []UUID, in *SmtpEmail, raw_only bo
 
 	// send process order to nats for each rcpt
 	var errs error
+	created := map[string]UUID{} // message_id -> user_id of messages created by nats handler
+	createdLock := new(sync.Mutex)
 	wg := new(sync.WaitGroup)
 	wg.Add(len(rcptsIds))
 	for _, rcptId := range rcptsIds {
@@ -182,6 +185,16 @@ func (b *EmailBroker) processInbound(rcptsIds []UUID, in *SmtpEmail, raw_only bo
 			defer wg.Done()
 			const nats_order = "process_raw"
 			natsMessage := fmt.Sprintf(nats_message_tmpl, nats_order, rcptId.String(), m.Raw_msg_id.String())
+			if in.Import != nil {
+				// original date, flags and tags of imported message are sent along the order
+				order, _ := json.Marshal(natsOrder{
+					Order:     nats_order,
+					MessageId: m.Raw_msg_id.String(),
+					UserId:    rcptId.String(),
+					Import:    in.Import,
+				})
+				natsMessage = string(order)
+			}
 			// XXX manage timeout correctly
 			resp, err := b.NatsConn.Request(b.Config.InTopic, []byte(natsMessage), 10*time.Second)
 			if err != nil {
@@ -215,6 +228,16 @@ func (b *EmailBroker) processInbound(rcptsIds []UUID, in *SmtpEmail, raw_only bo
 					log.Infof("EmailBroker : NATS inbound request successfully handled for user %s : %s", rcptId.String(), (*nats_ack)["message"])
 				}
 
+				if msg_id, ok := (*nats_ack)["message_id"].(string); ok {
+					createdLock.Lock()
+					created[msg_id] = rcptId
+					createdLock.Unlock()
+				}
+
+				if in.Import != nil {
+					// user is notified once when whole import is done
+					return
+				}
 				notif := Notification{
 					Emitter: "smtp",
 					Type:    EventNotif,
@@ -234,11 +257,52 @@ func (b *EmailBroker) processInbound(rcptsIds []UUID, in *SmtpEmail, raw_only bo
 	// we assume the previous MTA did the rcpts lookup, so all rcpts should be OK
 	// consequently, we discard the whole delivery if there is at least one error
 	if errs != nil {
+		b.releaseRawMessage(rcptsIds, created, m.Raw_msg_id.String())
 		resp.Response = fmt.Sprint(errs.Error())
 		resp.Err = true
 		return
 	}
 
+	// messages are now indexed, post-processing steps run in order, each one relying on the previous ones :
+	// keys advertised by sender are learnt first, to check signature of the messages,
+	// then OpenPGP protection is checked, signature of decrypted messages is a privacy feature,
+	// then attachments' content is added to index,
+	// then privacy indexes and importance levels are computed with sender's interactions preceding the new messages,
+	// and finally saved searches that new messages match are looked for.
+	go func(created map[string]UUID, raw string, imported bool) {
+		harvested := map[string]bool{}
+		for _, user_id := range created {
+			if !harvested[user_id.String()] {
+				harvested[user_id.String()] = true
+				b.harvestKeys(user_id.String(), []byte(raw))
+			}
+		}
+		b.unprotectInboundMessages(created, raw)
+		b.indexAttachmentsText(created, raw)
+		b.qualifyInboundMessages(created, raw)
+		b.recordReceivedInteractions(created)
+		if !imported {
+			b.notifySavedSearchesMatches(created)
+		}
+	}(created, m.Raw_data, in.Import != nil)
+
+}
+
+// releaseRawMessage releases the references taken on raw message for recipients that did not get a message
+func (b *EmailBroker) releaseRawMessage(rcptsIds []UUID, created map[string]UUID, raw_msg_id string) {
+	delivered := map[string]int{}
+	for _, user_id := range created {
+		delivered[user_id.String()]++
+	}
+	for _, rcptId := range rcptsIds {
+		if delivered[rcptId.String()] > 0 {
+			delivered[rcptId.String()]--
+			continue
+		}
+		if err := b.Store.DeleteRawMessage(rcptId.String(), raw_msg_id); err != nil {
+			log.WithError(err).Warnf("[EmailBroker] failed to release raw message %s for user %s", raw_msg_id, rcptId.String())
+		}
+	}
 }
 
 // deliverMsgToUser marshal an incoming email to the Caliopen message format
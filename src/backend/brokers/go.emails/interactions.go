@@ -0,0 +1,52 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package email_broker
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	log "github.com/Sirupsen/logrus"
+	"time"
+)
+
+// recordInteractions updates user's interactions counters with message's participants :
+// recipients for a sent message, sender for a received one.
+func (b *EmailBroker) recordInteractions(msg *Message, sent bool) {
+	addresses := []string{}
+	for _, participant := range msg.Participants {
+		switch participant.Type {
+		case ParticipantTo, ParticipantCC, ParticipantBcc:
+			if sent {
+				addresses = append(addresses, participant.Address)
+			}
+		case ParticipantFrom:
+			if !sent {
+				addresses = append(addresses, participant.Address)
+			}
+		}
+	}
+	if len(addresses) == 0 {
+		return
+	}
+	// message's Date header is set by sender, do not trust it
+	err := b.Store.RecordInteractions(msg.User_id.String(), EmailProtocol, addresses, sent, time.Now())
+	if err != nil {
+		log.WithError(err).Warnf("[EmailBroker] failed to record interactions for message %s", msg.Message_id.String())
+	}
+}
+
+// recordReceivedInteractions retrieves inbound messages that have been created for each recipient to record their sender.
+// messages is a map of message_id -> user_id
+func (b *EmailBroker) recordReceivedInteractions(messages map[string]UUID) {
+	for msg_id, user_id := range messages {
+		msg, err := b.Store.RetrieveMessage(user_id.String(), msg_id)
+		if err != nil || msg == nil {
+			log.WithError(err).Warnf("[EmailBroker] failed to retrieve message %s to record interactions", msg_id)
+			continue
+		}
+		b.recordInteractions(msg, false)
+	}
+}
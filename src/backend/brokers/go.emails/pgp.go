@@ -0,0 +1,279 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package email_broker
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/messages"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/outbox"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/pgp"
+	log "github.com/Sirupsen/logrus"
+	"github.com/jhillyerd/go.enmime"
+	"golang.org/x/crypto/openpgp"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// protectEmail encrypts and/or signs em's raw email with PGP/MIME, according to user's and draft's policies.
+// Encryption needs a usable key for every recipient, signature needs the secret key of sender in broker's keyring.
+// An error is returned if a protection is required by an 'always' policy but can't be applied.
+// Email_json is left unchanged : it describes the clear email, as saved along sent message.
+func (b *EmailBroker) protectEmail(em *EmailMessage) error {
+	msg := em.Message
+	encryption, signature := b.protectionPolicies(msg)
+	var signer *openpgp.Entity
+	if signature != ProtectionNever && len(em.Email.SmtpMailFrom) > 0 {
+		signer = b.PGPKeyring.Entity(em.Email.SmtpMailFrom[0])
+	}
+	if signer == nil && signature == ProtectionAlways {
+		return fmt.Errorf("[EmailBroker] no OpenPGP secret key to sign message %s", msg.Message_id.String())
+	}
+
+	var recipients openpgp.EntityList
+	if encryption != ProtectionNever {
+		var missing []string
+		recipients, missing = b.recipientsKeys(msg.User_id.String(), em.Email.SmtpRcpTo)
+		if len(missing) > 0 {
+			if encryption == ProtectionAlways {
+				return fmt.Errorf("[EmailBroker] no OpenPGP key to encrypt message %s to %s", msg.Message_id.String(), strings.Join(missing, ", "))
+			}
+			recipients = nil
+		}
+	}
+
+	if len(recipients) > 0 || signer != nil {
+		var protected []byte
+		var err error
+		if len(recipients) > 0 {
+			if signer != nil {
+				// sender must be able to read its sent message
+				recipients = append(recipients, signer)
+			}
+			protected, err = pgp.EncryptMIME(em.Email.Raw.Bytes(), recipients, signer)
+		} else {
+			protected, err = pgp.SignMIME(em.Email.Raw.Bytes(), signer)
+		}
+		if err != nil {
+			log.WithError(err).Warnf("[EmailBroker] OpenPGP protection of message %s failed", msg.Message_id.String())
+			return err
+		}
+		em.Email.Raw.Reset()
+		em.Email.Raw.Write(protected)
+	}
+
+	// record applied protection
+	if msg.Privacy_features == nil {
+		msg.Privacy_features = &PrivacyFeatures{}
+	}
+	features := *msg.Privacy_features
+	features["message_encrypted"] = strconv.FormatBool(len(recipients) > 0)
+	features["message_signed"] = strconv.FormatBool(signer != nil)
+	if signer != nil {
+		features["message_signature_type"] = "PGP"
+		features["message_signer"] = fmt.Sprintf("%X", signer.PrimaryKey.Fingerprint)
+	}
+	return nil
+}
+
+// protectionPolicies returns the encryption and signature policies of msg :
+// draft's ones if set, user's settings otherwise, ProtectionWhenPossible by default.
+func (b *EmailBroker) protectionPolicies(msg *Message) (encryption, signature string) {
+	encryption, signature = ProtectionWhenPossible, ProtectionWhenPossible
+	if settings, err := b.Store.GetSettings(msg.User_id.String()); err == nil && settings != nil {
+		if IsValidProtectionPolicy(settings.OutboundEncryption) {
+			encryption = settings.OutboundEncryption
+		}
+		if IsValidProtectionPolicy(settings.OutboundSignature) {
+			signature = settings.OutboundSignature
+		}
+	}
+	if msg.Privacy_features != nil {
+		if policy := (*msg.Privacy_features)[EncryptionPolicyFeature]; IsValidProtectionPolicy(policy) {
+			encryption = policy
+		}
+		if policy := (*msg.Privacy_features)[SignaturePolicyFeature]; IsValidProtectionPolicy(policy) {
+			signature = policy
+		}
+	}
+	return
+}
+
+// recipientsKeys returns the OpenPGP keys found in user's contacts to encrypt to addresses,
+// and the addresses for which no usable key has been found.
+// A key whose user ids include the address is preferred to the other keys of the contact.
+func (b *EmailBroker) recipientsKeys(user_id string, addresses []string) (keys openpgp.EntityList, missing []string) {
+	now := time.Now()
+	for _, address := range addresses {
+		var matching, others openpgp.EntityList
+		for _, entity := range b.contactsKeys(user_id, address, now) {
+			if !pgp.CanEncrypt(entity, now) {
+				continue
+			}
+			if pgp.HasAddress(entity, address) {
+				matching = append(matching, entity)
+			} else {
+				others = append(others, entity)
+			}
+		}
+		switch {
+		case len(matching) > 0:
+			keys = append(keys, matching[0])
+		case len(others) > 0:
+			keys = append(keys, others[0])
+		default:
+			missing = append(missing, address)
+		}
+	}
+	return
+}
+
+// contactsKeys returns the unexpired OpenPGP keys of user's contacts that hold address
+func (b *EmailBroker) contactsKeys(user_id, address string, now time.Time) (keys openpgp.EntityList) {
+	contact_ids, err := b.Store.LookupContactsByIdentifier(user_id, address)
+	if err != nil {
+		log.WithError(err).Warnf("[EmailBroker] failed to lookup contacts of %s", address)
+	}
+	for _, contact_id := range contact_ids {
+		contact, err := b.Store.RetrieveContact(user_id, contact_id)
+		if err != nil || contact == nil {
+			continue
+		}
+		keys = append(keys, pgp.ReadPublicKeys(contact.PublicKeys, now)...)
+	}
+	return
+}
+
+// unprotectInboundMessages checks OpenPGP signature of inbound messages that have been created for each recipient,
+// and decrypts them for recipients who enabled server side decryption, with their keys found in broker's keystore.
+// Result is recorded in messages' privacy features ; bodies and attachments of decrypted messages are replaced
+// by decrypted ones, along with their indexed excerpt, then attachments' text is indexed.
+// created is a map of message_id -> user_id
+func (b *EmailBroker) unprotectInboundMessages(created map[string]UUID, raw string) {
+	if !strings.Contains(raw, "-----BEGIN PGP ") {
+		// neither PGP/MIME nor inline PGP
+		return
+	}
+	now := time.Now()
+	for msg_id, user_id := range created {
+		msg, err := b.Store.RetrieveMessage(user_id.String(), msg_id)
+		if err != nil || msg == nil {
+			log.WithError(err).Warnf("[EmailBroker] failed to retrieve message %s to check its OpenPGP protection", msg_id)
+			continue
+		}
+		var senderKeys, secretKeys openpgp.EntityList
+		for _, participant := range msg.Participants {
+			if strings.EqualFold(participant.Type, ParticipantFrom) && participant.Address != "" {
+				senderKeys = append(senderKeys, b.contactsKeys(user_id.String(), participant.Address, now)...)
+			}
+		}
+		if b.PGPKeystore != nil {
+			if settings, err := b.Store.GetSettings(user_id.String()); err == nil && settings != nil && settings.InboundDecryption {
+				if secretKeys, err = b.PGPKeystore.UserKeys(user_id.String()); err != nil {
+					log.WithError(err).Warnf("[EmailBroker] failed to read OpenPGP keys of user %s", user_id.String())
+				}
+			}
+		}
+		protection, err := pgp.Inspect([]byte(raw), senderKeys, secretKeys)
+		if err != nil {
+			log.WithError(err).Warnf("[EmailBroker] failed to check OpenPGP protection of message %s", msg_id)
+		}
+		if protection == nil {
+			continue
+		}
+
+		if msg.Privacy_features == nil {
+			msg.Privacy_features = &PrivacyFeatures{}
+		}
+		features := *msg.Privacy_features
+		features["message_encrypted"] = strconv.FormatBool(protection.Encrypted)
+		features["message_decrypted"] = strconv.FormatBool(protection.Decrypted != nil)
+		features["message_signed"] = strconv.FormatBool(protection.Signed)
+		if protection.Signed {
+			features["message_signature_type"] = "PGP"
+			features["message_signature_status"] = protection.SignatureStatus
+			features["message_signer"] = protection.Signer
+		}
+		fields := map[string]interface{}{"Privacy_features": msg.Privacy_features}
+
+		var stored []string
+		decrypted := false
+		if protection.Decrypted != nil {
+			if stored, err = b.setDecryptedContent(msg, protection.Decrypted); err != nil {
+				log.WithError(err).Warnf("[EmailBroker] failed to read decrypted content of message %s", msg_id)
+			} else {
+				decrypted = true
+				fields["Body_plain"], fields["Body_html"] = msg.Body_plain, msg.Body_html
+				fields["Attachments"] = msg.Attachments
+			}
+		}
+
+		names := make([]string, 0, len(fields)+1)
+		for name := range fields {
+			names = append(names, name)
+		}
+		if decrypted {
+			// excerpt is not stored, index gets the one derived from decrypted bodies
+			names = append(names, "Body_excerpt")
+		}
+		mutation, err := outbox.Record(b.Store, msg.User_id, MessageType, msg.Message_id, IndexUpdate, names...)
+		if err != nil {
+			log.WithError(err).Warnf("[EmailBroker] failed to record index update of message %s", msg_id)
+		}
+		if err = b.Store.UpdateMessage(msg, fields); err != nil {
+			log.WithError(err).Warnf("[EmailBroker] failed to save OpenPGP protection of message %s", msg_id)
+			for _, url := range stored {
+				b.Store.DeleteAttachment(url)
+			}
+			continue
+		}
+		outbox.ApplyOrDefer(b.Store, b.Index, mutation)
+
+		if protection.Decrypted != nil {
+			b.indexAttachmentsText(map[string]UUID{msg_id: user_id}, string(protection.Decrypted))
+		}
+	}
+}
+
+// setDecryptedContent replaces bodies and attachments of msg with the ones of decrypted email.
+// Raw email is kept encrypted, thus decrypted attachments are saved into object store,
+// their url is set into message's attachments, and returned to be removed if message can't be saved.
+func (b *EmailBroker) setDecryptedContent(msg *Message, decrypted []byte) (stored []string, err error) {
+	parsed, err := mail.ReadMessage(bytes.NewReader(decrypted))
+	if err != nil {
+		return nil, err
+	}
+	mm, err := enmime.ParseMIMEBody(parsed)
+	if err != nil {
+		return nil, err
+	}
+
+	attachments := []Attachment{}
+	for i, part := range append(append([]enmime.MIMEPart{}, mm.Attachments...), mm.Inlines...) {
+		url, size, err := b.Store.StoreAttachment(msg.User_id.String(), "", bytes.NewReader(part.Content()))
+		if err != nil {
+			for _, url := range stored {
+				b.Store.DeleteAttachment(url)
+			}
+			return nil, err
+		}
+		stored = append(stored, url)
+		attachments = append(attachments, Attachment{
+			ContentType: part.ContentType(),
+			FileName:    part.FileName(),
+			IsInline:    i >= len(mm.Attachments),
+			Size:        size,
+			URL:         url,
+		})
+	}
+
+	msg.Body_plain, msg.Body_html = mm.Text, mm.HTML
+	messages.SanitizeMessageBodies(msg)
+	msg.Attachments = attachments
+	return stored, nil
+}
@@ -0,0 +1,32 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package email_broker
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/pi"
+	log "github.com/Sirupsen/logrus"
+)
+
+// qualifyInboundMessages computes privacy index, then importance level, of inbound messages that have been created for each recipient.
+// It must run before received interactions are recorded : sender's history is the one preceding the message.
+// messages is a map of message_id -> user_id
+func (b *EmailBroker) qualifyInboundMessages(messages map[string]UUID, raw string) {
+	for msg_id, user_id := range messages {
+		msg, err := b.Store.RetrieveMessage(user_id.String(), msg_id)
+		if err != nil || msg == nil {
+			log.WithError(err).Warnf("[EmailBroker] failed to retrieve message %s to qualify it", msg_id)
+			continue
+		}
+		if err = b.PIEngine.ScoreMessage(b.Store, b.Index, msg, raw); err != nil {
+			log.WithError(err).Warnf("[EmailBroker] failed to compute privacy index of message %s", msg_id)
+		}
+		if err = pi.ScoreImportance(b.Store, b.Index, msg, raw); err != nil {
+			log.WithError(err).Warnf("[EmailBroker] failed to compute importance level of message %s", msg_id)
+		}
+	}
+}
@@ -0,0 +1,72 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package email_broker
+
+import (
+	"encoding/json"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	log "github.com/Sirupsen/logrus"
+	"github.com/satori/go.uuid"
+)
+
+// notifySavedSearchesMatches emits a notification for each saved search that a new message matches,
+// if user asked to be notified for this saved search.
+// messages is a map of message_id -> user_id
+// User's index is refreshed first : messages are matched by id, they must be visible to searches.
+func (b *EmailBroker) notifySavedSearchesMatches(messages map[string]UUID) {
+	if b.Index == nil || b.Notifier == nil {
+		return
+	}
+	refreshed := map[string]bool{}
+	for msg_id, user_id := range messages {
+		searches, err := b.Store.RetrieveSavedSearches(user_id.String())
+		if err != nil {
+			// user has no saved search
+			continue
+		}
+		for _, search := range searches {
+			if !search.Notify {
+				continue
+			}
+			if !refreshed[user_id.String()] {
+				if err = b.Index.RefreshMessages(user_id.String()); err != nil {
+					log.WithError(err).Warnf("[EmailBroker] failed to refresh index of user %s", user_id.String())
+				}
+				refreshed[user_id.String()] = true
+			}
+			// restrict saved search to the new message
+			query := search.IndexSearch([2]int8{-10, 10}, 1, 0)
+			query.Filters["_id"] = []string{msg_id}
+			result, err := b.Index.Search(query)
+			if err != nil {
+				log.WithError(err).Warnf("[EmailBroker] failed to match message %s against saved search %s", msg_id, search.SearchId.String())
+				continue
+			}
+			if result.MessagesHits.Total == 0 {
+				continue
+			}
+			body, _ := json.Marshal(map[string]interface{}{
+				"savedSearchMatch": map[string]string{
+					"search_id":  search.SearchId.String(),
+					"label":      search.Label,
+					"message_id": msg_id,
+				},
+			})
+			notif := Notification{
+				Emitter: "smtp",
+				Type:    EventNotif,
+				TTLcode: LongLived,
+				User: &User{
+					UserId: user_id,
+				},
+				NotifId: UUID(uuid.NewV1()),
+				Body:    string(body),
+			}
+			go b.Notifier.ByNotifQueue(&notif)
+		}
+	}
+}
@@ -20,6 +20,14 @@ type Attachment struct {
 	MimeBoundary string `cql:"mime_boundary"    json:"mime_boundary,omitempty"` // for attachments embedded in raw messages
 }
 
+// AttachmentText holds the text extracted from an attachment's content.
+// It is only indexed, along with the message the attachment belongs to.
+type AttachmentText struct {
+	ContentType string `json:"content_type"`
+	FileName    string `json:"file_name"`
+	Text        string `json:"content"`
+}
+
 func (a *Attachment) UnmarshalMap(input map[string]interface{}) error {
 	if content_type, ok := input["content_type"].(string); ok {
 		a.ContentType = content_type
@@ -6,11 +6,13 @@ package objects
 
 type (
 	CaliopenConfig struct {
-		RESTstoreConfig RESTstoreConfig
-		RESTindexConfig RESTIndexConfig
-		NatsConfig      NatsConfig
-		CacheConfig     CacheConfig
-		NotifierConfig  NotifierConfig
+		RESTstoreConfig     RESTstoreConfig
+		RESTindexConfig     RESTIndexConfig
+		NatsConfig          NatsConfig
+		CacheConfig         CacheConfig
+		NotifierConfig      NotifierConfig
+		GeoIPConfig         GeoIPConfig
+		RemoteContentConfig RemoteContentConfig
 	}
 
 	// REST API
@@ -21,7 +23,9 @@ type (
 		Consistency  uint16   `mapstructure:"consistency_level"`
 		SizeLimit    uint64   `mapstructure:"raw_size_limit"` // max size for db (in bytes)
 		ObjStoreType string   `mapstructure:"object_store"`
+		Encryption   string   `mapstructure:"encryption"`
 		OSSConfig    `mapstructure:"object_store_settings"`
+		KMSConfig    `mapstructure:"encryption_settings"`
 	}
 
 	RESTIndexConfig struct {
@@ -31,9 +35,10 @@ type (
 
 	// redis
 	CacheConfig struct {
-		Host     string `mapstructure:"host"`
-		Password string `mapstructure:"password"`
-		Db       int    `mapstructure:"db"`
+		CacheName string `mapstructure:"cache_name"` // "redis" (default) or "memory"
+		Host      string `mapstructure:"host"`
+		Password  string `mapstructure:"password"`
+		Db        int    `mapstructure:"db"`
 	}
 
 	// NATS
@@ -41,6 +46,7 @@ type (
 		Url            string `mapstructure:"url"`
 		OutSMTP_topic  string `mapstructure:"outSMTP_topic"`
 		Contacts_topic string `mapstructure:"contacts_topic"`
+		IMAP_topic     string `mapstructure:"imap_topic"`
 	}
 	// Cassandra
 	StoreConfig struct {
@@ -50,6 +56,8 @@ type (
 		SizeLimit   uint64    `mapstructure:"raw_size_limit"` // max size to store (in bytes)
 		ObjectStore string    `mapstructure:"object_store"`
 		OSSConfig   OSSConfig `mapstructure:"object_store_settings"`
+		Encryption  string    `mapstructure:"encryption"` // "local" to encrypt raw messages and attachments, empty otherwise
+		KMSConfig   KMSConfig `mapstructure:"encryption_settings"`
 	}
 
 	// Objects Store
@@ -59,6 +67,12 @@ type (
 		SecretKey string            `mapstructure:"secret_key"`
 		Location  string            `mapstructure:"location"`
 		Buckets   map[string]string `mapstructure:"buckets"`
+		RootPath  string            `mapstructure:"root_path"` // for "fs" objects store only
+	}
+
+	// Key Management Service
+	KMSConfig struct {
+		KeyringPath string `mapstructure:"keyring_path"` // for "local" encryption only
 	}
 
 	// Notifications facility
@@ -67,4 +81,18 @@ type (
 		BaseUrl       string `mapstructure:"base_url"`       // url upon which to build custom links sent to users. No trailing slash please.
 		TemplatesPath string `mapstructure:"templates_path"` // path to templates Notifiers may need to access to
 	}
+
+	// location of devices
+	GeoIPConfig struct {
+		CountryDB string `mapstructure:"country_db"` // MaxMind DB file of countries (GeoLite2-Country or GeoLite2-City), empty to disable
+		AsnDB     string `mapstructure:"asn_db"`     // MaxMind DB file of autonomous systems (GeoLite2-ASN), empty to disable
+		PIPenalty int    `mapstructure:"pi_penalty"` // points removed from device's context privacy index on each unexpected location
+	}
+
+	// proxy of messages' remote content
+	RemoteContentConfig struct {
+		SigningKey string `mapstructure:"signing_key"` // secret to sign proxied urls with, empty to disable proxy
+		MaxSize    int64  `mapstructure:"max_size"`    // max size of a fetched resource (in bytes)
+		Timeout    int    `mapstructure:"timeout"`     // max duration of a fetch (in seconds)
+	}
 )
@@ -31,6 +31,7 @@ const (
 	Nats_outSMTP_topicKey  = "outSMTP_topic"
 	Nats_inSMTP_topicKey   = "inSMTP_topic"
 	Nats_Contacts_topicKey = "contacts_topic"
+	Nats_IMAP_topicKey     = "imap_topic"
 
 	//participant types
 	ParticipantBcc     = "Bcc"
@@ -75,7 +75,7 @@ func (d *Device) UnmarshalCQLMap(input map[string]interface{}) {
 
 	// publicKeys are stored in another table
 
-	if revokedAt, ok := input["revoked_at"].(time.Time); ok {
+	if revokedAt, ok := input["date_revoked"].(time.Time); ok {
 		d.DateRevoked = revokedAt
 	}
 	if status, ok := input["status"].(string); ok {
@@ -139,7 +139,7 @@ func (d *Device) UnmarshalMap(input map[string]interface{}) error {
 			}
 		}
 	}
-	if revokedAt, ok := input["revoked_at"]; ok {
+	if revokedAt, ok := input["date_revoked"]; ok {
 		d.DateRevoked, _ = time.Parse(time.RFC3339Nano, revokedAt.(string))
 	}
 	if status, ok := input["status"].(string); ok {
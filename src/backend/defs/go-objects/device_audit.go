@@ -0,0 +1,81 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package objects
+
+import (
+	"github.com/gocql/gocql"
+	"time"
+)
+
+// DeviceAudit records a security-sensitive action done on one of user's devices,
+// along with the device and the IP address the action has been requested from.
+type DeviceAudit struct {
+	// PRIMARY KEY (user_id, audit_id)
+	Action     string    `cql:"action"          json:"action"`
+	AuditId    UUID      `cql:"audit_id"        json:"audit_id"` // uuid v1, including a timestamp
+	ByDeviceId UUID      `cql:"by_device_id"    json:"by_device_id"`
+	DateInsert time.Time `cql:"date_insert"     json:"date_insert"        formatter:"RFC3339Milli"`
+	DeviceId   UUID      `cql:"device_id"       json:"device_id"`
+	IpAddress  string    `cql:"ip_address"      json:"ip_address"`
+	UserId     UUID      `cql:"user_id"         json:"user_id"`
+}
+
+const (
+	// Device.Status values
+	DeviceUnverified = "unverified"
+	DeviceVerified   = "verified"
+	DeviceRevoked    = "revoked"
+
+	// DeviceAudit.Action values
+	DeviceRevokeAction = "revoke"
+)
+
+// IsRevoked tells if device can't be used anymore to access user's account
+func (d *Device) IsRevoked() bool {
+	return !d.DateRevoked.IsZero() || d.Status == DeviceRevoked
+}
+
+// UnmarshalCQLMap hydrates a DeviceAudit with data from a map[string]interface{}
+func (da *DeviceAudit) UnmarshalCQLMap(input map[string]interface{}) {
+	if action, ok := input["action"].(string); ok {
+		da.Action = action
+	}
+	if auditId, ok := input["audit_id"].(gocql.UUID); ok {
+		da.AuditId.UnmarshalBinary(auditId.Bytes())
+	}
+	if byDeviceId, ok := input["by_device_id"].(gocql.UUID); ok {
+		da.ByDeviceId.UnmarshalBinary(byDeviceId.Bytes())
+	}
+	if dateInsert, ok := input["date_insert"].(time.Time); ok {
+		da.DateInsert = dateInsert
+	}
+	if deviceId, ok := input["device_id"].(gocql.UUID); ok {
+		da.DeviceId.UnmarshalBinary(deviceId.Bytes())
+	}
+	if ipAddress, ok := input["ip_address"].(string); ok {
+		da.IpAddress = ipAddress
+	}
+	if userId, ok := input["user_id"].(gocql.UUID); ok {
+		da.UserId.UnmarshalBinary(userId.Bytes())
+	}
+}
+
+func (da *DeviceAudit) MarshalFrontEnd() ([]byte, error) {
+	return JSONMarshaller("frontend", da)
+}
+
+func (da *DeviceAudit) JSONMarshaller() ([]byte, error) {
+	return JSONMarshaller("", da)
+}
+
+func (da *DeviceAudit) NewEmpty() interface{} {
+	return new(DeviceAudit)
+}
+
+func (da *DeviceAudit) JsonTags() map[string]string {
+	return jsonTags(da)
+}
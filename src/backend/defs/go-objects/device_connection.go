@@ -0,0 +1,73 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package objects
+
+import (
+	"github.com/gocql/gocql"
+	"time"
+)
+
+// DeviceConnection logs an IP address a device has been seen from,
+// along with the country and autonomous system resolved for this address.
+type DeviceConnection struct {
+	// PRIMARY KEY (user_id, device_id, date_insert)
+	Asn        int       `cql:"asn"             json:"asn,omitempty"`
+	Country    string    `cql:"country"         json:"country,omitempty"`
+	DateInsert time.Time `cql:"date_insert"     json:"date_insert"        formatter:"RFC3339Milli"`
+	DeviceId   UUID      `cql:"device_id"       json:"device_id"`
+	IpAddress  string    `cql:"ip_address"      json:"ip_address"`
+	Type       string    `cql:"type"            json:"type,omitempty"`
+	UserId     UUID      `cql:"user_id"         json:"user_id"`
+}
+
+const (
+	// DeviceConnection.Type values
+	ConnectionLogin   = "login"
+	ConnectionLogout  = "logout"
+	ConnectionRequest = "request" // authenticated request to the API
+)
+
+// UnmarshalCQLMap hydrates a DeviceConnection with data from a map[string]interface{}
+func (dc *DeviceConnection) UnmarshalCQLMap(input map[string]interface{}) {
+	if asn, ok := input["asn"].(int); ok {
+		dc.Asn = asn
+	}
+	if country, ok := input["country"].(string); ok {
+		dc.Country = country
+	}
+	if dateInsert, ok := input["date_insert"].(time.Time); ok {
+		dc.DateInsert = dateInsert
+	}
+	if deviceId, ok := input["device_id"].(gocql.UUID); ok {
+		dc.DeviceId.UnmarshalBinary(deviceId.Bytes())
+	}
+	if ipAddress, ok := input["ip_address"].(string); ok {
+		dc.IpAddress = ipAddress
+	}
+	if i_type, ok := input["type"].(string); ok {
+		dc.Type = i_type
+	}
+	if userId, ok := input["user_id"].(gocql.UUID); ok {
+		dc.UserId.UnmarshalBinary(userId.Bytes())
+	}
+}
+
+func (dc *DeviceConnection) MarshalFrontEnd() ([]byte, error) {
+	return JSONMarshaller("frontend", dc)
+}
+
+func (dc *DeviceConnection) JSONMarshaller() ([]byte, error) {
+	return JSONMarshaller("", dc)
+}
+
+func (dc *DeviceConnection) NewEmpty() interface{} {
+	return new(DeviceConnection)
+}
+
+func (dc *DeviceConnection) JsonTags() map[string]string {
+	return jsonTags(dc)
+}
@@ -0,0 +1,94 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package objects
+
+import (
+	"github.com/gocql/gocql"
+	"time"
+)
+
+// UserExport is a background job that archives user's messages, contacts and tags.
+// Once done, the archive is kept into the objects store until user downloads it.
+type UserExport struct {
+	// PRIMARY KEYS (user_id, export_id)
+	ContactsCount int       `cql:"contacts_count"  json:"contacts_count"`
+	DateEnd       time.Time `cql:"date_end"        json:"date_end,omitempty"     formatter:"RFC3339Milli"`
+	DateInsert    time.Time `cql:"date_insert"     json:"date_insert"            formatter:"RFC3339Milli"`
+	Error         string    `cql:"error"           json:"error,omitempty"`
+	ExportId      UUID      `cql:"export_id"       json:"export_id"`
+	Format        string    `cql:"format"          json:"format"`
+	MessagesCount int       `cql:"messages_count"  json:"messages_count"`
+	Size          int       `cql:"size"            json:"size"`
+	Status        string    `cql:"status"          json:"status"`
+	URI           string    `cql:"uri"             json:"-"`
+	UserId        UUID      `cql:"user_id"         json:"user_id"                frontend:"omit"`
+}
+
+const (
+	ExportMbox    = "mbox"
+	ExportMaildir = "maildir"
+
+	ExportPending = "pending"
+	ExportDone    = "done"
+	ExportFailed  = "failed"
+)
+
+// UnmarshalCQLMap hydrates an UserExport with data from a map[string]interface{}
+// typical usage is for unmarshaling response from Cassandra backend
+func (ue *UserExport) UnmarshalCQLMap(input map[string]interface{}) {
+	if count, ok := input["contacts_count"].(int); ok {
+		ue.ContactsCount = count
+	}
+	if dateEnd, ok := input["date_end"].(time.Time); ok {
+		ue.DateEnd = dateEnd
+	}
+	if dateInsert, ok := input["date_insert"].(time.Time); ok {
+		ue.DateInsert = dateInsert
+	}
+	if e, ok := input["error"].(string); ok {
+		ue.Error = e
+	}
+	if exportId, ok := input["export_id"].(gocql.UUID); ok {
+		ue.ExportId.UnmarshalBinary(exportId.Bytes())
+	}
+	if format, ok := input["format"].(string); ok {
+		ue.Format = format
+	}
+	if count, ok := input["messages_count"].(int); ok {
+		ue.MessagesCount = count
+	}
+	if size, ok := input["size"].(int); ok {
+		ue.Size = size
+	}
+	if status, ok := input["status"].(string); ok {
+		ue.Status = status
+	}
+	if uri, ok := input["uri"].(string); ok {
+		ue.URI = uri
+	}
+	if userId, ok := input["user_id"].(gocql.UUID); ok {
+		ue.UserId.UnmarshalBinary(userId.Bytes())
+	}
+}
+
+// return a JSON representation of UserExport suitable for frontend client
+func (ue *UserExport) MarshalFrontEnd() ([]byte, error) {
+	return JSONMarshaller("frontend", ue)
+}
+
+func (ue *UserExport) JSONMarshaller() ([]byte, error) {
+	return JSONMarshaller("", ue)
+}
+
+// implementation of the CaliopenObject interface
+func (ue *UserExport) NewEmpty() interface{} {
+	return new(UserExport)
+}
+
+func (ue *UserExport) JsonTags() map[string]string {
+	return jsonTags(ue)
+}
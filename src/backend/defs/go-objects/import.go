@@ -0,0 +1,108 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package objects
+
+import (
+	"github.com/gocql/gocql"
+	"time"
+)
+
+// UserImport is a background job that feeds messages of a mbox or Maildir archive into user's account.
+// Counters are updated while import is running so that user can follow its progress.
+type UserImport struct {
+	// PRIMARY KEYS (user_id, import_id)
+	DateEnd    time.Time `cql:"date_end"     json:"date_end,omitempty"     formatter:"RFC3339Milli"`
+	DateInsert time.Time `cql:"date_insert"  json:"date_insert"            formatter:"RFC3339Milli"`
+	DateUpdate time.Time `cql:"date_update"  json:"date_update,omitempty"  formatter:"RFC3339Milli"`
+	Duplicates int       `cql:"duplicates"   json:"duplicates"`
+	Error      string    `cql:"error"        json:"error,omitempty"`
+	Failed     int       `cql:"failed"       json:"failed"`
+	Format     string    `cql:"format"       json:"format,omitempty"`
+	ImportId   UUID      `cql:"import_id"    json:"import_id"`
+	Imported   int       `cql:"imported"     json:"imported"`
+	Source     string    `cql:"source"       json:"-"`
+	Status     string    `cql:"status"       json:"status"`
+	Total      int       `cql:"total"        json:"total"`
+	UserId     UUID      `cql:"user_id"      json:"user_id"                frontend:"omit"`
+}
+
+// ImportedMessage holds original state of a message read from an archive.
+// It is sent along the raw email to the inbound process, which applies it to the new message.
+type ImportedMessage struct {
+	Date     *time.Time `json:"date,omitempty"`
+	IsUnread bool       `json:"is_unread"`
+	Tags     []string   `json:"tags,omitempty"`
+}
+
+const (
+	ImportPending = "pending"
+	ImportRunning = "running"
+	ImportDone    = "done"
+	ImportFailed  = "failed"
+)
+
+// UnmarshalCQLMap hydrates an UserImport with data from a map[string]interface{}
+// typical usage is for unmarshaling response from Cassandra backend
+func (ui *UserImport) UnmarshalCQLMap(input map[string]interface{}) {
+	if dateEnd, ok := input["date_end"].(time.Time); ok {
+		ui.DateEnd = dateEnd
+	}
+	if dateInsert, ok := input["date_insert"].(time.Time); ok {
+		ui.DateInsert = dateInsert
+	}
+	if dateUpdate, ok := input["date_update"].(time.Time); ok {
+		ui.DateUpdate = dateUpdate
+	}
+	if count, ok := input["duplicates"].(int); ok {
+		ui.Duplicates = count
+	}
+	if e, ok := input["error"].(string); ok {
+		ui.Error = e
+	}
+	if count, ok := input["failed"].(int); ok {
+		ui.Failed = count
+	}
+	if format, ok := input["format"].(string); ok {
+		ui.Format = format
+	}
+	if importId, ok := input["import_id"].(gocql.UUID); ok {
+		ui.ImportId.UnmarshalBinary(importId.Bytes())
+	}
+	if count, ok := input["imported"].(int); ok {
+		ui.Imported = count
+	}
+	if source, ok := input["source"].(string); ok {
+		ui.Source = source
+	}
+	if status, ok := input["status"].(string); ok {
+		ui.Status = status
+	}
+	if count, ok := input["total"].(int); ok {
+		ui.Total = count
+	}
+	if userId, ok := input["user_id"].(gocql.UUID); ok {
+		ui.UserId.UnmarshalBinary(userId.Bytes())
+	}
+}
+
+// return a JSON representation of UserImport suitable for frontend client
+func (ui *UserImport) MarshalFrontEnd() ([]byte, error) {
+	return JSONMarshaller("frontend", ui)
+}
+
+func (ui *UserImport) JSONMarshaller() ([]byte, error) {
+	return JSONMarshaller("", ui)
+}
+
+// implementation of the CaliopenObject interface
+func (ui *UserImport) NewEmpty() interface{} {
+	return new(UserImport)
+}
+
+func (ui *UserImport) JsonTags() map[string]string {
+	return jsonTags(ui)
+}
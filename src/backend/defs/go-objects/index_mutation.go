@@ -0,0 +1,75 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package objects
+
+import (
+	"github.com/gocql/gocql"
+	"time"
+)
+
+// IndexMutation is an index write recorded into store's outbox before the store write it replicates.
+// Mutations do not carry documents : they are applied by reading the resource from store,
+// thus applying a mutation twice, or after a later one, leaves index in line with store.
+type IndexMutation struct {
+	// PRIMARY KEY (user_id, mutation_id)
+	Attempts     int       `cql:"attempts"        json:"attempts"`
+	DateInsert   time.Time `cql:"date_insert"     json:"date_insert"            formatter:"RFC3339Milli"`
+	Fields       []string  `cql:"fields"          json:"fields,omitempty"` // struct fields names modified by an update
+	LastError    string    `cql:"last_error"      json:"last_error,omitempty"`
+	MutationId   UUID      `cql:"mutation_id"     json:"mutation_id"` // time based uuid, mutations of an user are applied in this order
+	Operation    string    `cql:"operation"       json:"operation"`
+	ResourceId   UUID      `cql:"resource_id"     json:"resource_id"`
+	ResourceType string    `cql:"resource_type"   json:"resource_type"` // MessageType or ContactType
+	UserId       UUID      `cql:"user_id"         json:"user_id"`
+}
+
+const (
+	IndexCreate = "create" // index the whole resource
+	IndexUpdate = "update" // update Fields of indexed resource
+	IndexDelete = "delete" // remove resource from index
+)
+
+// UnmarshalCQLMap hydrates an IndexMutation with data from a map[string]interface{}
+// typical usage is for unmarshaling response from Cassandra backend
+func (im *IndexMutation) UnmarshalCQLMap(input map[string]interface{}) {
+	if attempts, ok := input["attempts"].(int); ok {
+		im.Attempts = attempts
+	}
+	if dateInsert, ok := input["date_insert"].(time.Time); ok {
+		im.DateInsert = dateInsert
+	}
+	if fields, ok := input["fields"].([]string); ok {
+		im.Fields = fields
+	}
+	if lastError, ok := input["last_error"].(string); ok {
+		im.LastError = lastError
+	}
+	if mutationId, ok := input["mutation_id"].(gocql.UUID); ok {
+		im.MutationId.UnmarshalBinary(mutationId.Bytes())
+	}
+	if operation, ok := input["operation"].(string); ok {
+		im.Operation = operation
+	}
+	if resourceId, ok := input["resource_id"].(gocql.UUID); ok {
+		im.ResourceId.UnmarshalBinary(resourceId.Bytes())
+	}
+	if resourceType, ok := input["resource_type"].(string); ok {
+		im.ResourceType = resourceType
+	}
+	if userId, ok := input["user_id"].(gocql.UUID); ok {
+		im.UserId.UnmarshalBinary(userId.Bytes())
+	}
+}
+
+// implementation of the CaliopenObject interface
+func (im *IndexMutation) NewEmpty() interface{} {
+	return new(IndexMutation)
+}
+
+func (im *IndexMutation) JsonTags() map[string]string {
+	return jsonTags(im)
+}
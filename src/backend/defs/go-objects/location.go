@@ -15,6 +15,7 @@ import (
 
 type DeviceLocation struct {
 	// PRIMARY KEYS (user_id, device_id, ip_address)
+	Asn       int    `cql:"asn"              json:"asn,omitempty"           patch:"system"` // autonomous system number
 	Country   string `cql:"country"          json:"country,omitempty"       patch:"user"`
 	DeviceId  UUID   `cql:"device_id"        json:"device_id"               patch:"user"`
 	IpAddress string `cql:"address"          json:"address,omitempty"       patch:"user"`
@@ -22,9 +23,17 @@ type DeviceLocation struct {
 	UserId    UUID   `cql:"user_id"          json:"user_id"                 patch:"system"`
 }
 
+const (
+	// DeviceLocation.Type value for locations found by the backend
+	DetectedLocation = "detected"
+)
+
 type DeviceLocations []DeviceLocation
 
 func (dl *DeviceLocation) UnmarshalMap(input map[string]interface{}) error {
+	if asn, ok := input["asn"].(float64); ok {
+		dl.Asn = int(asn)
+	}
 	if country, ok := input["country"].(string); ok {
 		dl.Country = country
 	}
@@ -48,6 +57,9 @@ func (dl *DeviceLocation) UnmarshalMap(input map[string]interface{}) error {
 }
 
 func (dl *DeviceLocation) UnmarshalCQLMap(input map[string]interface{}) {
+	if asn, ok := input["asn"].(int); ok {
+		dl.Asn = asn
+	}
 	if country, ok := input["country"].(string); ok {
 		dl.Country = country
 	}
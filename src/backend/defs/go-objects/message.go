@@ -16,8 +16,8 @@ import (
 
 type Message struct {
 	Attachments         []Attachment       `cql:"attachments"              json:"attachments,omitempty"       `
-	Body_html           string             `cql:"body_html"                json:"body_html"         `
-	Body_plain          string             `cql:"body_plain"               json:"body_plain"        `
+	Body_html           string             `cql:"body_html"                json:"body_html"                          patch:"user"`
+	Body_plain          string             `cql:"body_plain"               json:"body_plain"                         patch:"user"`
 	Body_excerpt        string             `cql:"-"                        json:"excerpt"           `
 	Date                time.Time          `cql:"date"                     json:"date"                                                      formatter:"RFC3339Milli"`
 	Date_delete         time.Time          `cql:"date_delete"              json:"date_delete,omitempty"                                     formatter:"RFC3339Milli"`
@@ -25,19 +25,19 @@ type Message struct {
 	Date_sort           time.Time          `cql:"date_sort"                json:"date_sort"                                                 formatter:"RFC3339Milli"`
 	Discussion_id       UUID               `cql:"discussion_id"            json:"discussion_id,omitempty"                                   formatter:"rfc4122"`
 	External_references ExternalReferences `cql:"external_references"      json:"external_references,omitempty"`
-	Identities          []Identity         `cql:"identities"               json:"identities,omitempty"       `
+	Identities          []Identity         `cql:"identities"               json:"identities,omitempty"               patch:"user"`
 	Importance_level    int32              `cql:"importance_level"         json:"importance_level" `
 	Is_answered         bool               `cql:"is_answered"              json:"is_answered"      `
 	Is_draft            bool               `cql:"is_draft"                 json:"is_draft"         `
 	Is_unread           bool               `cql:"is_unread"                json:"is_unread"        `
 	Is_received         bool               `cql:"is_received"              json:"is_received"      `
 	Message_id          UUID               `cql:"message_id"               json:"message_id,omitempty"                                      formatter:"rfc4122"`
-	Parent_id           UUID               `cql:"parent_id"                json:"parent_id,omitempty"        `
-	Participants        []Participant      `cql:"participants"             json:"participants,omitempty"     `
-	Privacy_features    *PrivacyFeatures   `cql:"privacy_features"         json:"privacy_features,omitempty" `
+	Parent_id           UUID               `cql:"parent_id"                json:"parent_id,omitempty"                patch:"user"`
+	Participants        []Participant      `cql:"participants"             json:"participants,omitempty"             patch:"user"`
+	Privacy_features    *PrivacyFeatures   `cql:"privacy_features"         json:"privacy_features,omitempty"         patch:"user"`
 	PrivacyIndex        *PrivacyIndex      `cql:"pi"                       json:"pi,omitempty"`
 	Raw_msg_id          UUID               `cql:"raw_msg_id"               json:"raw_msg_id,omitempty"                                      formatter:"rfc4122"`
-	Subject             string             `cql:"subject"                  json:"subject"          `
+	Subject             string             `cql:"subject"                  json:"subject"                            patch:"user"`
 	Tags                []string           `cql:"tagnames"                 json:"tags,omitempty"                     patch:"system" `
 	Type                string             `cql:"type"                     json:"type,omitempty"             `
 	User_id             UUID               `cql:"user_id"                  json:"user_id,omitempty"                  elastic:"omit"         formatter:"rfc4122"`
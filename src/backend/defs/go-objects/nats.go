@@ -7,6 +7,8 @@
 package objects
 
 // IMAPfetchOrder is model for message sent on topic 'IMAPfetcher' in NATS's queue 'IMAPworkers'
+// For « import » orders, Identifier is the id of an import created by REST api
+// and Mailbox is a local path to the archive when import is ordered by imapctl.
 type IMAPfetchOrder struct {
 	Order      string
 	UserId     string
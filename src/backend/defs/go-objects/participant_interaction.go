@@ -0,0 +1,84 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package objects
+
+import (
+	"github.com/gocql/gocql"
+	"math"
+	"strings"
+	"time"
+)
+
+// ParticipantInteraction counts how many messages an user exchanged with an address, and when it happened for the last time.
+// It is used to rank recipients' suggestions.
+type ParticipantInteraction struct {
+	// PRIMARY KEYS (user_id, address)
+	Address      string    `cql:"address"        json:"address"`
+	LastReceived time.Time `cql:"last_received"  json:"last_received" formatter:"RFC3339Milli"`
+	LastSent     time.Time `cql:"last_sent"      json:"last_sent"     formatter:"RFC3339Milli"`
+	Protocol     string    `cql:"protocol"       json:"protocol"`
+	Received     int       `cql:"received"       json:"received"` // messages received from address
+	Sent         int       `cql:"sent"           json:"sent"`     // messages sent to address
+	UserId       UUID      `cql:"user_id"        json:"user_id"`
+}
+
+const (
+	// weight of a sent message compared to a received one : writing to someone is a stronger signal
+	InteractionSentWeight = 2
+	// number of days after which recency bonus is halved
+	InteractionHalfLife = 30
+)
+
+// NormalizeInteractionAddress returns the form of address used as key for interactions
+func NormalizeInteractionAddress(address string) string {
+	return strings.ToLower(strings.TrimSpace(address))
+}
+
+// Score weighs interactions by frequency and recency :
+// frequency is log-scaled to prevent mailing-lists from crushing everything else,
+// then it's boosted by up to x2 for recent interactions, the boost being halved every InteractionHalfLife days.
+func (pi *ParticipantInteraction) Score(now time.Time) float64 {
+	frequency := math.Log1p(float64(InteractionSentWeight*pi.Sent + pi.Received))
+	if frequency == 0 {
+		return 0
+	}
+	last := pi.LastSent
+	if pi.LastReceived.After(last) {
+		last = pi.LastReceived
+	}
+	days := now.Sub(last).Hours() / 24
+	if days < 0 {
+		days = 0
+	}
+	return frequency * (1 + math.Pow(2, -days/InteractionHalfLife))
+}
+
+// UnmarshalCQLMap hydrates a ParticipantInteraction with data from a map[string]interface{}
+// typical usage is for unmarshaling response from Cassandra backend
+func (pi *ParticipantInteraction) UnmarshalCQLMap(input map[string]interface{}) {
+	if address, ok := input["address"].(string); ok {
+		pi.Address = address
+	}
+	if lastReceived, ok := input["last_received"].(time.Time); ok {
+		pi.LastReceived = lastReceived
+	}
+	if lastSent, ok := input["last_sent"].(time.Time); ok {
+		pi.LastSent = lastSent
+	}
+	if protocol, ok := input["protocol"].(string); ok {
+		pi.Protocol = protocol
+	}
+	if received, ok := input["received"].(int); ok {
+		pi.Received = received
+	}
+	if sent, ok := input["sent"].(int); ok {
+		pi.Sent = sent
+	}
+	if userId, ok := input["user_id"].(gocql.UUID); ok {
+		pi.UserId.UnmarshalBinary(userId.Bytes())
+	}
+}
@@ -0,0 +1,34 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package objects
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParticipantInteractionScore(t *testing.T) {
+	now := time.Now()
+	never := ParticipantInteraction{}
+	if never.Score(now) != 0 {
+		t.Errorf("expected zero score without interaction, got %f", never.Score(now))
+	}
+	daily := ParticipantInteraction{Sent: 40, Received: 60, LastSent: now.Add(-24 * time.Hour)}
+	rare := ParticipantInteraction{Received: 2, LastReceived: now.Add(-24 * time.Hour)}
+	if daily.Score(now) <= rare.Score(now) {
+		t.Errorf("frequent correspondent should rank above rare one")
+	}
+	old := ParticipantInteraction{Sent: 40, Received: 60, LastSent: now.AddDate(-1, 0, 0)}
+	if daily.Score(now) <= old.Score(now) {
+		t.Errorf("recent correspondent should rank above old one")
+	}
+	toMe := ParticipantInteraction{Received: 10, LastReceived: now}
+	fromMe := ParticipantInteraction{Sent: 10, LastSent: now}
+	if fromMe.Score(now) <= toMe.Score(now) {
+		t.Errorf("sent messages should weigh more than received ones")
+	}
+}
@@ -6,6 +6,7 @@ package objects
 
 import (
 	"encoding/json"
+	"github.com/gocql/gocql"
 	"math/big"
 	"time"
 )
@@ -35,44 +36,58 @@ type PublicKey struct {
 // unmarshal a map[string]interface{} that must owns all PublicKey's fields
 // typical usage is for unmarshaling response from Cassandra backend
 func (pk *PublicKey) UnmarshalCQLMap(input map[string]interface{}) {
-	/*
-		if contactId, ok := input["contact_id"].(gocql.UUID); ok {
-			pk.ContactId.UnmarshalBinary(contactId.Bytes())
-		}
-		if dateInsert, ok := input["date_insert"].(time.Time); ok {
-			pk.DateInsert = dateInsert
-		}
-		if dateUpdate, ok := input["date_update"].(time.Time); ok {
-			pk.DateUpdate = dateUpdate
-		}
-		if expireDate, ok := input["expire_date"].(time.Time); ok {
-			pk.ExpireDate = expireDate
-		}
-		if fingerprint, ok := input["fingerprint"].(string); ok {
-			pk.Fingerprint = fingerprint
-		}
-		if key, ok := input["key"].(string); ok {
-			pk.Key = key
-		}
-		if name, ok := input["name"].(string); ok {
-			pk.Name = name
-		}
-		if i_pf, ok := input["privacy_features"].(map[string]string); ok && i_pf != nil {
-			pf := PrivacyFeatures{}
-			for k, v := range i_pf {
-				pf[k] = v
-			}
-			pk.PrivacyFeatures = &pf
-		} else {
-			pk.PrivacyFeatures = nil
-		}
-		if size, ok := input["size"].(int); ok {
-			pk.Size = size
-		}
-		if userid, ok := input["user_id"].(gocql.UUID); ok {
-			pk.UserId.UnmarshalBinary(userid.Bytes())
-		}
-	*/
+	if alg, ok := input["alg"].(string); ok {
+		pk.Algorithm = alg
+	}
+	if crv, ok := input["crv"].(string); ok {
+		pk.Curve = crv
+	}
+	if dateInsert, ok := input["date_insert"].(time.Time); ok {
+		pk.DateInsert = dateInsert
+	}
+	if dateUpdate, ok := input["date_update"].(time.Time); ok {
+		pk.DateUpdate = dateUpdate
+	}
+	if expireDate, ok := input["expire_date"].(time.Time); ok {
+		pk.ExpireDate = expireDate
+	}
+	if fingerprint, ok := input["fingerprint"].(string); ok {
+		pk.Fingerprint = []byte(fingerprint)
+	}
+	if key, ok := input["key"].(string); ok {
+		pk.Key = []byte(key)
+	}
+	if keyId, ok := input["key_id"].(gocql.UUID); ok {
+		pk.KeyId.UnmarshalBinary(keyId.Bytes())
+	}
+	if kty, ok := input["kty"].(string); ok {
+		pk.KeyType = kty
+	}
+	if label, ok := input["label"].(string); ok {
+		pk.Label = label
+	}
+	if features, ok := input["privacy_features"].(map[string]string); ok && len(features) > 0 {
+		pf := PrivacyFeatures(features)
+		pk.PrivacyFeatures = &pf
+	}
+	if resourceId, ok := input["resource_id"].(gocql.UUID); ok {
+		pk.ResourceId.UnmarshalBinary(resourceId.Bytes())
+	}
+	if resourceType, ok := input["resource_type"].(string); ok {
+		pk.ResourceType = resourceType
+	}
+	if use, ok := input["use"].(string); ok {
+		pk.Use = use
+	}
+	if userId, ok := input["user_id"].(gocql.UUID); ok {
+		pk.UserId.UnmarshalBinary(userId.Bytes())
+	}
+	if x, ok := input["x"].(*big.Int); ok && x != nil {
+		pk.X.Set(x)
+	}
+	if y, ok := input["y"].(*big.Int); ok && y != nil {
+		pk.Y.Set(y)
+	}
 }
 
 func (pk *PublicKey) UnmarshalMap(input map[string]interface{}) error {
@@ -11,7 +11,12 @@ type RawMessage struct {
 	Raw_msg_id UUID   `cql:"raw_msg_id"        json:"raw_msg_id"`
 	Raw_data   string `cql:"raw_data"          json:"raw_data"` //could be empty if raw message is too large to be stored in db
 	Raw_Size   uint64 `cql:"raw_size"          json:"raw_size"`
-	URI        string `cql:"uri"               json:"uri"` //object's location if message is too large to be stored in db
+	URI        string `cql:"uri"               json:"uri"`  //object's location if message is too large to be stored in db
+	Hash       string `cql:"hash"              json:"hash"` //sha256 of raw_data, used to deduplicate raw messages
+	// encryption metadata, empty if raw data is stored in clear
+	Key_owner   UUID   `cql:"key_owner"         json:"key_owner"`
+	Key_version int    `cql:"key_version"       json:"key_version"`
+	Wrapped_key []byte `cql:"wrapped_key"       json:"-"`
 }
 
 // unmarshal a map[string]interface{} that must owns all Message fields
@@ -28,4 +33,16 @@ func (msg *RawMessage) UnmarshalCQLMap(input map[string]interface{}) {
 	if uri, ok := input["uri"].(string); ok {
 		msg.URI = uri
 	}
+	if hash, ok := input["hash"].(string); ok {
+		msg.Hash = hash
+	}
+	if key_owner, ok := input["key_owner"].(gocql.UUID); ok {
+		msg.Key_owner.UnmarshalBinary(key_owner.Bytes())
+	}
+	if key_version, ok := input["key_version"].(int); ok {
+		msg.Key_version = key_version
+	}
+	if wrapped_key, ok := input["wrapped_key"].([]byte); ok {
+		msg.Wrapped_key = wrapped_key
+	}
 }
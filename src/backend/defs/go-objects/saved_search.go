@@ -0,0 +1,203 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package objects
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gocql/gocql"
+	"github.com/satori/go.uuid"
+	"sort"
+	"time"
+)
+
+// SavedSearch is a search that user stored to run it again later (aka 'smart folder').
+// Filters are the same as GET /messages query params, Query is an optional full text term
+// that is evaluated like POST /search does.
+type SavedSearch struct {
+	// PRIMARY KEYS (user_id, search_id)
+	DateInsert  time.Time           `cql:"date_insert"  json:"date_insert"       patch:"system" formatter:"RFC3339Milli"`
+	DateUpdate  time.Time           `cql:"date_update"  json:"date_update"       patch:"system" formatter:"RFC3339Milli"`
+	Field       string              `cql:"field"        json:"field,omitempty"   patch:"user"`
+	Filters     map[string][]string `cql:"filters"      json:"filters,omitempty" patch:"user"`
+	Label       string              `cql:"label"        json:"label"             patch:"user"`
+	Notify      bool                `cql:"notify"       json:"notify"            patch:"user"`
+	Query       string              `cql:"query"        json:"query,omitempty"   patch:"user"`
+	SearchId    UUID                `cql:"search_id"    json:"search_id"         patch:"system"`
+	UnreadCount int64               `cql:"-"            json:"unread_count"      patch:"system"`
+	UserId      UUID                `cql:"user_id"      json:"user_id"           patch:"system" frontend:"omit"`
+}
+
+const (
+	SavedSearchMinQuery = 3 // same min length as search API's 'term' param
+	SavedSearchMaxLabel = 255
+)
+
+// IndexSearch returns the search to send to index to evaluate the saved search.
+// Filters are copied, thus caller could add its own filters without altering saved search.
+func (ss *SavedSearch) IndexSearch(ILrange [2]int8, limit, offset int) IndexSearch {
+	search := IndexSearch{
+		User_id: ss.UserId,
+		Limit:   limit,
+		Offset:  offset,
+		ILrange: ILrange,
+		DocType: MessageIndexType,
+		Filters: map[string][]string{},
+	}
+	for name, values := range ss.Filters {
+		search.Filters[name] = append([]string{}, values...)
+	}
+	if ss.Query != "" {
+		if ss.Field != "" {
+			search.Terms = map[string][]string{ss.Field: {ss.Query}}
+		} else {
+			search.Terms = map[string][]string{"_all": {ss.Query}}
+		}
+	}
+	return search
+}
+
+// UnmarshalCQLMap hydrates a SavedSearch with data from a map[string]interface{}
+// typical usage is for unmarshaling response from Cassandra backend
+func (ss *SavedSearch) UnmarshalCQLMap(input map[string]interface{}) {
+	if dateInsert, ok := input["date_insert"].(time.Time); ok {
+		ss.DateInsert = dateInsert
+	}
+	if dateUpdate, ok := input["date_update"].(time.Time); ok {
+		ss.DateUpdate = dateUpdate
+	}
+	if field, ok := input["field"].(string); ok {
+		ss.Field = field
+	}
+	ss.Filters = map[string][]string{}
+	if filters, ok := input["filters"].(map[string][]string); ok {
+		for name, values := range filters {
+			ss.Filters[name] = values
+		}
+	}
+	if label, ok := input["label"].(string); ok {
+		ss.Label = label
+	}
+	if notify, ok := input["notify"].(bool); ok {
+		ss.Notify = notify
+	}
+	if query, ok := input["query"].(string); ok {
+		ss.Query = query
+	}
+	if searchId, ok := input["search_id"].(gocql.UUID); ok {
+		ss.SearchId.UnmarshalBinary(searchId.Bytes())
+	}
+	if userId, ok := input["user_id"].(gocql.UUID); ok {
+		ss.UserId.UnmarshalBinary(userId.Bytes())
+	}
+}
+
+// UnmarshalMap hydrates a SavedSearch with data from a map[string]interface{}
+func (ss *SavedSearch) UnmarshalMap(input map[string]interface{}) error {
+	if dateInsert, ok := input["date_insert"].(string); ok {
+		ss.DateInsert, _ = time.Parse(time.RFC3339Nano, dateInsert)
+	}
+	if dateUpdate, ok := input["date_update"].(string); ok {
+		ss.DateUpdate, _ = time.Parse(time.RFC3339Nano, dateUpdate)
+	}
+	if field, ok := input["field"].(string); ok {
+		ss.Field = field
+	}
+	if filters, ok := input["filters"].(map[string]interface{}); ok {
+		ss.Filters = map[string][]string{}
+		for name, values := range filters {
+			switch values.(type) {
+			case []interface{}:
+				for _, value := range values.([]interface{}) {
+					if v, ok := value.(string); ok {
+						ss.Filters[name] = append(ss.Filters[name], v)
+					}
+				}
+			case string:
+				ss.Filters[name] = []string{values.(string)}
+			}
+		}
+	}
+	if label, ok := input["label"].(string); ok {
+		ss.Label = label
+	}
+	if notify, ok := input["notify"].(bool); ok {
+		ss.Notify = notify
+	}
+	if query, ok := input["query"].(string); ok {
+		ss.Query = query
+	}
+	if searchId, ok := input["search_id"].(string); ok {
+		if id, err := uuid.FromString(searchId); err == nil {
+			ss.SearchId.UnmarshalBinary(id.Bytes())
+		}
+	}
+	// unread_count is computed when retrieving saved search, it's not unmarshaled on purpose.
+	if userId, ok := input["user_id"].(string); ok {
+		if id, err := uuid.FromString(userId); err == nil {
+			ss.UserId.UnmarshalBinary(id.Bytes())
+		}
+	}
+	return nil
+}
+
+func (ss *SavedSearch) UnmarshalJSON(b []byte) error {
+	input := map[string]interface{}{}
+	if err := json.Unmarshal(b, &input); err != nil {
+		return err
+	}
+	return ss.UnmarshalMap(input)
+}
+
+// return a JSON representation of SavedSearch suitable for frontend client
+func (ss *SavedSearch) MarshalFrontEnd() ([]byte, error) {
+	return JSONMarshaller("frontend", ss)
+}
+
+func (ss *SavedSearch) JSONMarshaller() ([]byte, error) {
+	return JSONMarshaller("", ss)
+}
+
+// implementation of the CaliopenObject interface
+func (ss *SavedSearch) NewEmpty() interface{} {
+	nss := new(SavedSearch)
+	nss.Filters = map[string][]string{}
+	return nss
+}
+
+// an UUID should be provided to fill UserId with
+func (ss *SavedSearch) MarshallNew(args ...interface{}) {
+	if len(ss.SearchId) == 0 || (bytes.Equal(ss.SearchId.Bytes(), EmptyUUID.Bytes())) {
+		ss.SearchId.UnmarshalBinary(uuid.NewV4().Bytes())
+	}
+	if len(ss.UserId) == 0 || (bytes.Equal(ss.UserId.Bytes(), EmptyUUID.Bytes())) {
+		if len(args) == 1 {
+			switch args[0].(type) {
+			case UUID:
+				ss.UserId = args[0].(UUID)
+			}
+		}
+	}
+	if ss.DateInsert.IsZero() {
+		ss.DateInsert = time.Now()
+	}
+	ss.DateUpdate = ss.DateInsert
+	if ss.Filters == nil {
+		ss.Filters = map[string][]string{}
+	}
+}
+
+func (ss *SavedSearch) JsonTags() map[string]string {
+	return jsonTags(ss)
+}
+
+// filters' values are sorted to make saved searches comparable when patching
+func (ss *SavedSearch) SortSlices() {
+	for _, values := range ss.Filters {
+		sort.Strings(values)
+	}
+}
@@ -16,12 +16,48 @@ type IndexSearch struct {
 	User_id UUID                `json:"user_id"`
 	DocType string              `json:"doc_type"`
 	ILrange [2]int8             `json:"il_range"`
+	Facets  []FacetRequest      `json:"facets,omitempty"`
+	Filters map[string][]string `json:"filters,omitempty"` // exact values that documents must hold, in addition to Terms
+	Trash   bool                `json:"trash,omitempty"`   // if true, only documents that have been moved to trash are returned, otherwise they are left out
+}
+
+// facets that could be requested along with a search.
+// facets are only computed on messages documents.
+const (
+	FacetTags            = "tags"
+	FacetParticipants    = "participants"
+	FacetDates           = "dates"
+	FacetHasAttachments  = "has_attachments"
+	FacetAttachmentTypes = "attachment_types"
+
+	DefaultFacetSize     = 10
+	MaxFacetSize         = 100
+	DefaultFacetInterval = "month"
+)
+
+var FacetNames = [5]string{FacetTags, FacetParticipants, FacetDates, FacetHasAttachments, FacetAttachmentTypes}
+
+var FacetIntervals = [5]string{"day", "week", "month", "quarter", "year"} // allowed intervals for FacetDates histogram
+
+// FacetRequest tells which facet to compute and how many buckets to return.
+// Size is ignored for FacetDates and FacetHasAttachments, Interval only applies to FacetDates.
+type FacetRequest struct {
+	Name     string `json:"name"`
+	Size     int    `json:"size,omitempty"`
+	Interval string `json:"interval,omitempty"`
+}
+
+// FacetBucket is a value found for a facet, with how many documents hold this value
+type FacetBucket struct {
+	Key   string `json:"key"`
+	Count int64  `json:"count"`
 }
 
 type IndexResult struct {
-	Total        int64       `json:"total"`
-	MessagesHits MessageHits `json:"messages_hits"`
-	ContactsHits ContactHits `json:"contact_hits"`
+	Total        int64                    `json:"total"`
+	MessagesHits MessageHits              `json:"messages_hits"`
+	ContactsHits ContactHits              `json:"contact_hits"`
+	Facets       map[string][]FacetBucket `json:"facets"`
 }
 
 type MessageHits struct {
@@ -49,15 +85,31 @@ func (is *IndexSearch) FilterQuery(service *elastic.SearchService, withIL bool)
 			q = q.Filter(elastic.NewTermQuery(name, value))
 		}
 	}
+	for name, values := range is.Filters {
+		for _, value := range values {
+			q = q.Filter(elastic.NewTermQuery(name, value))
+		}
+	}
 	if withIL {
 		rq := elastic.NewRangeQuery("importance_level").Gte(is.ILrange[0]).Lte(is.ILrange[1])
 		q = q.Filter(rq)
 	}
+	q = q.Filter(is.TrashQuery())
 	service = service.Query(q)
 
 	return service
 }
 
+// TrashQuery returns a filter that keeps documents moved to trash if is.Trash is true,
+// or that leaves them out otherwise. Documents are in trash as soon as they have a date_delete.
+func (is *IndexSearch) TrashQuery() elastic.Query {
+	deleted := elastic.NewExistsQuery("date_delete")
+	if is.Trash {
+		return deleted
+	}
+	return elastic.NewBoolQuery().MustNot(deleted)
+}
+
 func (is *IndexSearch) MatchQuery(service *elastic.SearchService) *elastic.SearchService {
 
 	if len(is.Terms) == 0 {
@@ -68,6 +120,36 @@ func (is *IndexSearch) MatchQuery(service *elastic.SearchService) *elastic.Searc
 	return service
 }
 
+// IsValidFacetName returns true if `name` is one of the FacetNames
+func IsValidFacetName(name string) bool {
+	for _, facet := range FacetNames {
+		if facet == name {
+			return true
+		}
+	}
+	return false
+}
+
+// IsValidFacetInterval returns true if `interval` is one of the FacetIntervals
+func IsValidFacetInterval(interval string) bool {
+	for _, i := range FacetIntervals {
+		if i == interval {
+			return true
+		}
+	}
+	return false
+}
+
+// MarshallNew enforces default values for facet's size and interval
+func (fr *FacetRequest) MarshallNew(...interface{}) {
+	if fr.Size <= 0 {
+		fr.Size = DefaultFacetSize
+	}
+	if fr.Name == FacetDates && fr.Interval == "" {
+		fr.Interval = DefaultFacetInterval
+	}
+}
+
 func (ir *IndexResult) MarshalFrontEnd() ([]byte, error) {
 	return ir.JSONMarshaller("frontend")
 }
@@ -15,14 +15,36 @@ type Settings struct {
 	ContactDisplayFormat       string `cql:"contact_display_format"	json:"contact_display_format"`
 	ContactDisplayOrder        string `cql:"contact_display_order"	json:"contact_display_order"`
 	DefaultLocale              string `cql:"default_locale"      json:"default_locale"`
+	InboundDecryption          bool   `cql:"inbound_decryption"	json:"inbound_decryption"` // server side decryption of received OpenPGP messages
 	MessageDisplayFormat       string `cql:"message_display_format"	json:"message_display_format"`
 	NotificationDelayDisappear int    `cql:"notification_delay_disappear"	json:"notification_delay_disappear"`
 	NotificationEnabled        bool   `cql:"notification_enabled"	json:"notification_enabled"`
 	NotificationMessagePreview string `cql:"notification_message_preview"	json:"notification_message_preview"`
 	NotificationSoundEnabled   bool   `cql:"notification_sound_enabled"	json:"notification_sound_enabled"`
+	OutboundEncryption         string `cql:"outbound_encryption"	json:"outbound_encryption"` // OpenPGP protection policy of sent messages
+	OutboundSignature          string `cql:"outbound_signature"	json:"outbound_signature"`
+	TrashRetentionDays         int    `cql:"trash_retention_days"	json:"trash_retention_days"`
 	UserId                     UUID   `cql:"user_id"            json:"user_id"`
 }
 
+// OpenPGP protection policies of sent messages
+const (
+	ProtectionNever        = "never"
+	ProtectionWhenPossible = "when_possible" // default one
+	ProtectionAlways       = "always"        // message is not sent if it can't be protected
+)
+
+// keys of a draft's privacy features that override user's protection policies
+const (
+	EncryptionPolicyFeature = "encryption_policy"
+	SignaturePolicyFeature  = "signature_policy"
+)
+
+// IsValidProtectionPolicy tells if policy is one of the Protection* constants
+func IsValidProtectionPolicy(policy string) bool {
+	return policy == ProtectionNever || policy == ProtectionWhenPossible || policy == ProtectionAlways
+}
+
 // unmarshal a map[string]interface{} that must owns all Settings's fields
 // typical usage is for unmarshaling response from Cassandra backend
 func (s *Settings) UnmarshalCQLMap(input map[string]interface{}) {
@@ -31,9 +53,13 @@ func (s *Settings) UnmarshalCQLMap(input map[string]interface{}) {
 	s.DefaultLocale = input["default_locale"].(string)
 	s.MessageDisplayFormat = input["message_display_format"].(string)
 	s.NotificationDelayDisappear = input["notification_delay_disappear"].(int)
+	s.InboundDecryption, _ = input["inbound_decryption"].(bool)
 	s.NotificationEnabled = input["notification_enabled"].(bool)
 	s.NotificationSoundEnabled = input["notification_sound_enabled"].(bool)
 	s.NotificationMessagePreview = input["notification_message_preview"].(string)
+	s.OutboundEncryption, _ = input["outbound_encryption"].(string)
+	s.OutboundSignature, _ = input["outbound_signature"].(string)
+	s.TrashRetentionDays, _ = input["trash_retention_days"].(int)
 	userid, _ := input["user_id"].(gocql.UUID)
 	s.UserId.UnmarshalBinary(userid.Bytes())
 }
@@ -54,6 +80,9 @@ func (s *Settings) UnmarshalMap(input map[string]interface{}) error {
 	if delay, ok := input["notification_delay_disappear"].(float64); ok {
 		s.NotificationDelayDisappear = int(delay)
 	}
+	if inboundDecryption, ok := input["inbound_decryption"].(bool); ok {
+		s.InboundDecryption = inboundDecryption
+	}
 	if notificationEnabled, ok := input["notification_enabled"].(bool); ok {
 		s.NotificationEnabled = notificationEnabled
 	}
@@ -63,6 +92,15 @@ func (s *Settings) UnmarshalMap(input map[string]interface{}) error {
 	if notificationMessagePreview, ok := input["notification_message_preview"].(string); ok {
 		s.NotificationMessagePreview = notificationMessagePreview
 	}
+	if outboundEncryption, ok := input["outbound_encryption"].(string); ok {
+		s.OutboundEncryption = outboundEncryption
+	}
+	if outboundSignature, ok := input["outbound_signature"].(string); ok {
+		s.OutboundSignature = outboundSignature
+	}
+	if retention, ok := input["trash_retention_days"].(float64); ok {
+		s.TrashRetentionDays = int(retention)
+	}
 	if userID, ok := input["user_id"].(string); ok {
 		if id, err := uuid.FromString(userID); err == nil {
 			s.UserId.UnmarshalBinary(id.Bytes())
@@ -23,6 +23,7 @@ type Tag struct {
 	Name    string `cql:"name" json:"name"`                                           // primary key
 	// values
 	Date_insert      time.Time `cql:"date_insert" json:"date_insert" formatter:"RFC3339Milli"`
+	Expiry_days      int32     `cql:"expiry_days" json:"expiry_days" patch:"user"` // 0 means messages tagged with it never expire
 	Importance_level int32     `cql:"importance_level" json:"importance_level" patch:"user"`
 	Label            string    `cql:"label" json:"label" patch:"user"`
 	Type             TagType   `cql:"type" json:"type"`
@@ -104,6 +105,9 @@ func (tag *Tag) UnmarshalMap(input map[string]interface{}) error {
 	if date, ok := input["date_insert"]; ok {
 		tag.Date_insert, _ = time.Parse(time.RFC3339Nano, date.(string))
 	}
+	if expiry, ok := input["expiry_days"].(float64); ok {
+		tag.Expiry_days = int32(expiry)
+	}
 	if il, ok := input["importance_level"].(float64); ok {
 		tag.Importance_level = int32(il)
 	}
@@ -128,6 +132,9 @@ func (tag *Tag) UnmarshalMap(input map[string]interface{}) error {
 // typical usage is for unmarshaling response from Cassandra backend
 func (tag *Tag) UnmarshalCQLMap(input map[string]interface{}) error {
 	tag.Date_insert = input["date_insert"].(time.Time)
+	if expiry, ok := input["expiry_days"].(int); ok {
+		tag.Expiry_days = int32(expiry)
+	}
 	tag.Importance_level = int32(input["importance_level"].(int))
 	tag.Label = input["label"].(string)
 	tag.Name = input["name"].(string)
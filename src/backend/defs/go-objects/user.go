@@ -15,6 +15,7 @@ import (
 
 type User struct {
 	ContactId        UUID              `cql:"contact_id"               json:"contact_id"`
+	DateDelete       time.Time         `cql:"date_delete"              json:"date_delete,omitempty"                    formatter:"RFC3339Milli"` // set once account deletion is requested
 	DateInsert       time.Time         `cql:"date_insert"              json:"date_insert"                              formatter:"RFC3339Milli"`
 	FamilyName       string            `cql:"family_name"              json:"family_name"`
 	GivenName        string            `cql:"given_name"               json:"given_name"`
@@ -56,6 +57,7 @@ type Pass_reset_session struct {
 func (user *User) UnmarshalCQLMap(input map[string]interface{}) {
 	contactId, _ := input["contact_id"].(gocql.UUID)
 	user.ContactId.UnmarshalBinary(contactId.Bytes())
+	user.DateDelete, _ = input["date_delete"].(time.Time)
 	user.DateInsert, _ = input["date_insert"].(time.Time)
 	user.FamilyName, _ = input["family_name"].(string)
 	user.GivenName, _ = input["given_name"].(string)
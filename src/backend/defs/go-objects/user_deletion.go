@@ -0,0 +1,106 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package objects
+
+import (
+	"github.com/gocql/gocql"
+	"time"
+)
+
+// UserDeletion tracks the deletion of an user's account.
+// Once requested, user can't log in anymore. After a grace period, the purger worker removes
+// all user's data step by step ; completed steps are recorded so that an interrupted deletion resumes where it stopped.
+type UserDeletion struct {
+	// PRIMARY KEY (user_id)
+	DateEnd     time.Time `cql:"date_end"        json:"date_end,omitempty"     formatter:"RFC3339Milli"`
+	DateInsert  time.Time `cql:"date_insert"     json:"date_insert"            formatter:"RFC3339Milli"`
+	Error       string    `cql:"error"           json:"error,omitempty"`
+	RequestedBy string    `cql:"requested_by"    json:"requested_by"`
+	Status      string    `cql:"status"          json:"status"`
+	Steps       []string  `cql:"steps"           json:"steps"                  frontend:"omit"`
+	UserId      UUID      `cql:"user_id"         json:"user_id"`
+}
+
+const (
+	DeletionPending = "pending"
+	DeletionRunning = "running"
+	DeletionDone    = "done"
+
+	DeletionByUser  = "user"
+	DeletionByAdmin = "admin"
+
+	// deletion steps, in the order they are run
+	DeletionStepSessions = "sessions" // authentication tokens and password reset session
+	DeletionStepIndex    = "index"    // user's index
+	DeletionStepMessages = "messages" // messages with their attachments, then raw messages
+	DeletionStepArchives = "archives" // exports and imports with their archives
+	DeletionStepData     = "data"     // every other table partitioned by user_id
+	DeletionStepAccount  = "account"  // local identities, username, recovery email and user itself
+)
+
+var DeletionSteps = []string{
+	DeletionStepSessions,
+	DeletionStepIndex,
+	DeletionStepMessages,
+	DeletionStepArchives,
+	DeletionStepData,
+	DeletionStepAccount,
+}
+
+// HasStep returns true if step has already been completed
+func (ud *UserDeletion) HasStep(step string) bool {
+	for _, s := range ud.Steps {
+		if s == step {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmarshalCQLMap hydrates an UserDeletion with data from a map[string]interface{}
+// typical usage is for unmarshaling response from Cassandra backend
+func (ud *UserDeletion) UnmarshalCQLMap(input map[string]interface{}) {
+	if dateEnd, ok := input["date_end"].(time.Time); ok {
+		ud.DateEnd = dateEnd
+	}
+	if dateInsert, ok := input["date_insert"].(time.Time); ok {
+		ud.DateInsert = dateInsert
+	}
+	if e, ok := input["error"].(string); ok {
+		ud.Error = e
+	}
+	if by, ok := input["requested_by"].(string); ok {
+		ud.RequestedBy = by
+	}
+	if status, ok := input["status"].(string); ok {
+		ud.Status = status
+	}
+	if steps, ok := input["steps"].([]string); ok {
+		ud.Steps = steps
+	}
+	if userId, ok := input["user_id"].(gocql.UUID); ok {
+		ud.UserId.UnmarshalBinary(userId.Bytes())
+	}
+}
+
+// return a JSON representation of UserDeletion suitable for frontend client
+func (ud *UserDeletion) MarshalFrontEnd() ([]byte, error) {
+	return JSONMarshaller("frontend", ud)
+}
+
+func (ud *UserDeletion) JSONMarshaller() ([]byte, error) {
+	return JSONMarshaller("", ud)
+}
+
+// implementation of the CaliopenObject interface
+func (ud *UserDeletion) NewEmpty() interface{} {
+	return new(UserDeletion)
+}
+
+func (ud *UserDeletion) JsonTags() map[string]string {
+	return jsonTags(ud)
+}
@@ -6,6 +6,7 @@ import uuid
 
 import datetime
 import pytz
+from dateutil.parser import parse as parse_date
 from caliopen_storage.exception import NotFound
 from caliopen_main.message.core import RawMessage
 from caliopen_main.message.objects.message import Message
@@ -21,8 +22,12 @@ class UserMessageDelivery(object):
         """Create a new UserMessageDelivery belong to an user."""
         self.user = user
 
-    def process_raw(self, raw_msg_id):
-        """Process a raw message for an user, ie makes it a rich 'message'."""
+    def process_raw(self, raw_msg_id, imported=None):
+        """Process a raw message for an user, ie makes it a rich 'message'.
+
+        imported holds date, flags and tags of a message coming from
+        a mailbox import, they take precedence over computed ones.
+        """
         raw = RawMessage.get(raw_msg_id)
         if not raw:
             log.error('Raw message <{}> not found'.format(raw_msg_id))
@@ -39,8 +44,22 @@ class UserMessageDelivery(object):
         obj.message_id = uuid.uuid4()
         obj.date_insert = datetime.datetime.now(tz=pytz.utc)
         obj.date_sort = obj.date_insert
+        if imported:
+            self._apply_import(obj, imported)
         obj.marshall_db()
         obj.save_db()
         obj.marshall_index()
         obj.save_index()
         return obj
+
+    def _apply_import(self, obj, imported):
+        """Restore original state of an imported message."""
+        if 'is_unread' in imported:
+            obj.is_unread = bool(imported['is_unread'])
+        if imported.get('date'):
+            obj.date_sort = parse_date(imported['date'])
+        tags = obj.tags or []
+        for tag in imported.get('tags') or []:
+            if tag not in tags:
+                tags.append(tag)
+        obj.tags = tags
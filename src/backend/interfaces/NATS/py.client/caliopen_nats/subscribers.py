@@ -37,7 +37,8 @@ class InboundEmail(BaseHandler):
         user = User.get(payload['user_id'])
         deliver = UserMessageDelivery(user)
         try:
-            new_message = deliver.process_raw(payload['message_id'])
+            new_message = deliver.process_raw(payload['message_id'],
+                                              payload.get('import'))
             nats_success['message_id'] = str(new_message.message_id)
             self.natsConn.publish(msg.reply, json.dumps(nats_success))
         except Exception as exc:
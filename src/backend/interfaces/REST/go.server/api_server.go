@@ -10,9 +10,12 @@ import (
 	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations"
 	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations/contacts"
 	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations/devices"
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations/exports"
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations/imports"
 	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations/messages"
 	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations/notifications"
 	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations/participants"
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations/saved_searches"
 	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations/tags"
 	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations/users"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.main"
@@ -28,19 +31,24 @@ var (
 
 type (
 	REST_API struct {
-		config   APIConfig
-		swagSpec *loads.Document
+		config            APIConfig
+		swagSpec          *loads.Document
+		deviceConnections gin.HandlerFunc // shared by authenticated route groups
 	}
 
 	APIConfig struct {
-		Host           string `mapstructure:"host"`
-		Port           string `mapstructure:"port"`
-		SwaggerFile    string `mapstructure:"swaggerSpec"`
-		BackendConfig  `mapstructure:"BackendConfig"`
-		IndexConfig    `mapstructure:"IndexConfig"`
-		CacheSettings  `mapstructure:"RedisConfig"`
-		NatsConfig     `mapstructure:"NatsConfig"`
-		NotifierConfig `mapstructure:"NotifierConfig"`
+		Host                string `mapstructure:"host"`
+		Port                string `mapstructure:"port"`
+		SwaggerFile         string `mapstructure:"swaggerSpec"`
+		BackendConfig       `mapstructure:"BackendConfig"`
+		IndexConfig         `mapstructure:"IndexConfig"`
+		CacheSettings       `mapstructure:"RedisConfig"`
+		NatsConfig          `mapstructure:"NatsConfig"`
+		NotifierConfig      `mapstructure:"NotifierConfig"`
+		GeoIPConfig         `mapstructure:"GeoIPConfig"`
+		RemoteContentConfig `mapstructure:"RemoteContentConfig"`
+		// route groups for which requests must be signed by user's device
+		DeviceSignature http_middleware.DeviceSignatureConfig `mapstructure:"DeviceSignatureConfig"`
 	}
 
 	BackendConfig struct {
@@ -55,6 +63,8 @@ type (
 		SizeLimit        uint64        `mapstructure:"raw_size_limit"` // max size for db (in bytes)
 		ObjStoreType     string        `mapstructure:"object_store"`
 		ObjStoreSettings obj.OSSConfig `mapstructure:"object_store_settings"`
+		Encryption       string        `mapstructure:"encryption"`
+		EncryptionConfig obj.KMSConfig `mapstructure:"encryption_settings"`
 	}
 
 	IndexConfig struct {
@@ -67,15 +77,17 @@ type (
 	}
 
 	CacheSettings struct {
-		Host     string `mapstructure:"host"`
-		Password string `mapstructure:"password"`
-		Db       int    `mapstructure:"db"`
+		CacheName string `mapstructure:"cache_name"`
+		Host      string `mapstructure:"host"`
+		Password  string `mapstructure:"password"`
+		Db        int    `mapstructure:"db"`
 	}
 
 	NatsConfig struct {
 		Url            string `mapstructure:"url"`
 		OutSMTP_topic  string `mapstructure:"outSMTP_topic"`
 		Contacts_topic string `mapstructure:"contacts_topic"`
+		IMAP_topic     string `mapstructure:"imap_topic"`
 	}
 
 	NotifierConfig struct {
@@ -83,6 +95,18 @@ type (
 		BaseUrl       string `mapstructure:"base_url"`
 		TemplatesPath string `mapstructure:"templates_path"`
 	}
+
+	GeoIPConfig struct {
+		CountryDB string `mapstructure:"country_db"`
+		AsnDB     string `mapstructure:"asn_db"`
+		PIPenalty int    `mapstructure:"pi_penalty"`
+	}
+
+	RemoteContentConfig struct {
+		SigningKey string `mapstructure:"signing_key"`
+		MaxSize    int64  `mapstructure:"max_size"`
+		Timeout    int    `mapstructure:"timeout"`
+	}
 )
 
 func InitializeServer(config APIConfig) error {
@@ -103,26 +127,40 @@ func (server *REST_API) initialize(config APIConfig) error {
 			SizeLimit:    config.BackendConfig.Settings.SizeLimit,
 			ObjStoreType: config.BackendConfig.Settings.ObjStoreType,
 			OSSConfig:    config.BackendConfig.Settings.ObjStoreSettings,
+			Encryption:   config.BackendConfig.Settings.Encryption,
+			KMSConfig:    config.BackendConfig.Settings.EncryptionConfig,
 		},
 		RESTindexConfig: obj.RESTIndexConfig{
 			IndexName: config.IndexConfig.IndexName,
 			Hosts:     config.IndexConfig.Settings.Hosts,
 		},
 		CacheConfig: obj.CacheConfig{
-			Host:     config.CacheSettings.Host,
-			Password: config.CacheSettings.Password,
-			Db:       config.CacheSettings.Db,
+			CacheName: config.CacheSettings.CacheName,
+			Host:      config.CacheSettings.Host,
+			Password:  config.CacheSettings.Password,
+			Db:        config.CacheSettings.Db,
 		},
 		NatsConfig: obj.NatsConfig{
 			Url:            config.NatsConfig.Url,
 			OutSMTP_topic:  config.NatsConfig.OutSMTP_topic,
 			Contacts_topic: config.NatsConfig.Contacts_topic,
+			IMAP_topic:     config.NatsConfig.IMAP_topic,
 		},
 		NotifierConfig: obj.NotifierConfig{
 			AdminUsername: config.NotifierConfig.AdminUsername,
 			BaseUrl:       config.NotifierConfig.BaseUrl,
 			TemplatesPath: config.NotifierConfig.TemplatesPath,
 		},
+		GeoIPConfig: obj.GeoIPConfig{
+			CountryDB: config.GeoIPConfig.CountryDB,
+			AsnDB:     config.GeoIPConfig.AsnDB,
+			PIPenalty: config.GeoIPConfig.PIPenalty,
+		},
+		RemoteContentConfig: obj.RemoteContentConfig{
+			SigningKey: config.RemoteContentConfig.SigningKey,
+			MaxSize:    config.RemoteContentConfig.MaxSize,
+			Timeout:    config.RemoteContentConfig.Timeout,
+		},
 	}
 
 	err := caliopen.Initialize(caliopenConfig)
@@ -160,6 +198,7 @@ func (server *REST_API) start() error {
 	}
 	// adds our routes and handlers
 	api := router.Group(http_middleware.RoutePrefix)
+	server.deviceConnections = http_middleware.DeviceConnections(recordDeviceConnection)
 	server.AddHandlers(api)
 
 	// listens
@@ -171,13 +210,29 @@ func (server *REST_API) start() error {
 	return err
 }
 
+// authentication returns the middlewares that authenticate requests to route group :
+// access token, then device signature if configured for this group.
+// Location of authenticated devices is recorded last.
+func (server *REST_API) authentication(group string) []gin.HandlerFunc {
+	handlers := []gin.HandlerFunc{http_middleware.BasicAuthFromCache(caliopen.Facilities.Cache, "caliopen")}
+	if server.config.DeviceSignature.Signs(group) {
+		handlers = append(handlers, http_middleware.DeviceSignature(caliopen.Facilities.RESTfacility, server.config.DeviceSignature))
+	}
+	return append(handlers, server.deviceConnections)
+}
+
+func recordDeviceConnection(userId, deviceId, ipAddress string) {
+	caliopen.Facilities.RESTfacility.RecordDeviceConnection(userId, deviceId, ipAddress, caliopen.Facilities.Notifiers)
+}
+
 func (server *REST_API) AddHandlers(api *gin.RouterGroup) {
 
 	/** users API **/
-	usrs := api.Group("/users", http_middleware.BasicAuthFromCache(caliopen.Facilities.Cache, "caliopen"))
+	usrs := api.Group("/users", server.authentication("/users")...)
 	usrs.PATCH("/:user_id", users.PatchUser)
+	usrs.DELETE("/:user_id", users.DeleteUser)
 
-	identities := api.Group(http_middleware.IdentitiesRoute, http_middleware.BasicAuthFromCache(caliopen.Facilities.Cache, "caliopen"))
+	identities := api.Group(http_middleware.IdentitiesRoute, server.authentication(http_middleware.IdentitiesRoute)...)
 	identities.GET("/locals", users.GetLocalsIdentities)
 	identities.GET("/locals/:identity_id", users.GetLocalIdentity)
 
@@ -192,23 +247,28 @@ func (server *REST_API) AddHandlers(api *gin.RouterGroup) {
 	api.GET("/username/isAvailable", users.IsAvailable)
 
 	/** messages API **/
-	msg := api.Group("/messages", http_middleware.BasicAuthFromCache(caliopen.Facilities.Cache, "caliopen"))
+	msg := api.Group("/messages", server.authentication("/messages")...)
 	msg.GET("", messages.GetMessagesList)
+	msg.POST("", messages.NewDraft)
 	msg.GET("/:message_id", messages.GetMessage)
+	msg.PATCH("/:message_id", messages.PatchDraft)
+	msg.DELETE("/:message_id", messages.DeleteMessage)
 	msg.POST("/:message_id/actions", messages.Actions)
 	//attachments
 	msg.POST("/:message_id/attachments", messages.UploadAttachment)
 	msg.DELETE("/:message_id/attachments/:attachment_id", messages.DeleteAttachment)
 	msg.GET("/:message_id/attachments/:attachment_id", messages.DownloadAttachment)
+	//remote content
+	msg.GET("/:message_id/remote-content", messages.GetRemoteContent)
 	//tags
 	msg.PATCH("/:message_id/tags", tags.PatchResourceWithTags)
 
 	/** participants API **/
-	parts := api.Group("/participants", http_middleware.BasicAuthFromCache(caliopen.Facilities.Cache, "caliopen"))
+	parts := api.Group("/participants", server.authentication("/participants")...)
 	parts.GET("/suggest", participants.Suggest)
 
 	/** contacts API **/
-	cts := api.Group(http_middleware.ContactsRoute, http_middleware.BasicAuthFromCache(caliopen.Facilities.Cache, "caliopen"))
+	cts := api.Group(http_middleware.ContactsRoute, server.authentication(http_middleware.ContactsRoute)...)
 	cts.GET("", contacts.GetContactsList)
 	cts.POST("", contacts.NewContact)
 	cts.GET("/:contactID", contacts.GetContact)
@@ -219,15 +279,16 @@ func (server *REST_API) AddHandlers(api *gin.RouterGroup) {
 	cts.PATCH("/:contactID/tags", tags.PatchResourceWithTags)
 
 	/** devices API **/
-	dev := api.Group(http_middleware.DevicesRoute, http_middleware.BasicAuthFromCache(caliopen.Facilities.Cache, "caliopen"))
+	dev := api.Group(http_middleware.DevicesRoute, server.authentication(http_middleware.DevicesRoute)...)
 	dev.GET("", devices.GetDevicesList)
 	//dev.POST("", devices.NewDevice)
 	dev.GET("/:deviceID", devices.GetDevice)
 	dev.PATCH("/:deviceID", devices.PatchDevice)
 	dev.DELETE("/:deviceID", devices.DeleteDevice)
+	dev.POST("/:deviceID/actions", devices.Actions)
 
 	/** tags API **/
-	tag := api.Group(http_middleware.TagsRoute, http_middleware.BasicAuthFromCache(caliopen.Facilities.Cache, "caliopen"))
+	tag := api.Group(http_middleware.TagsRoute, server.authentication(http_middleware.TagsRoute)...)
 	tag.GET("", tags.RetrieveUserTags)
 	tag.POST("", tags.CreateTag)
 	tag.GET("/:tag_name", tags.RetrieveTag)
@@ -235,12 +296,33 @@ func (server *REST_API) AddHandlers(api *gin.RouterGroup) {
 	tag.DELETE("/:tag_name", tags.DeleteTag)
 
 	/** search API **/
-	search := api.Group("/search", http_middleware.BasicAuthFromCache(caliopen.Facilities.Cache, "caliopen"))
+	search := api.Group("/search", server.authentication("/search")...)
 	search.GET("", operations.SimpleSearch)
 	search.POST("", operations.AdvancedSearch)
 
+	/** saved searches API **/
+	saved := api.Group(http_middleware.SavedSearchesRoute, server.authentication(http_middleware.SavedSearchesRoute)...)
+	saved.GET("", saved_searches.GetSavedSearchesList)
+	saved.POST("", saved_searches.NewSavedSearch)
+	saved.GET("/:search_id", saved_searches.GetSavedSearch)
+	saved.PATCH("/:search_id", saved_searches.PatchSavedSearch)
+	saved.DELETE("/:search_id", saved_searches.DeleteSavedSearch)
+	saved.GET("/:search_id/messages", saved_searches.RunSavedSearch)
+
+	/** exports API **/
+	export := api.Group(http_middleware.ExportsRoute, server.authentication(http_middleware.ExportsRoute)...)
+	export.POST("", exports.NewExport)
+	export.GET("/:export_id", exports.GetExport)
+	export.DELETE("/:export_id", exports.DeleteExport)
+	export.GET("/:export_id/download", exports.DownloadExport)
+
+	/** imports API **/
+	imp := api.Group(http_middleware.ImportsRoute, server.authentication(http_middleware.ImportsRoute)...)
+	imp.POST("", imports.NewImport)
+	imp.GET("/:import_id", imports.GetImport)
+
 	/** notifications API **/
-	notif := api.Group("/notifications", http_middleware.BasicAuthFromCache(caliopen.Facilities.Cache, "caliopen"))
+	notif := api.Group("/notifications", server.authentication("/notifications")...)
 	notif.GET("", notifications.GetPendingNotif)
 	notif.DELETE("", notifications.DeleteNotifications)
 }
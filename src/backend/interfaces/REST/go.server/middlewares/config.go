@@ -1,9 +1,13 @@
 package http_middleware
 
 const (
-	RoutePrefix     = "/api/v2"
-	IdentitiesRoute = "/identities"
-	TagsRoute       = "/tags"
-	ContactsRoute   = "/contacts"
-	DevicesRoute    = "/devices"
+	RoutePrefix        = "/api/v2"
+	IdentitiesRoute    = "/identities"
+	TagsRoute          = "/tags"
+	ContactsRoute      = "/contacts"
+	MessagesRoute      = "/messages"
+	DevicesRoute       = "/devices"
+	SavedSearchesRoute = "/saved-searches"
+	ExportsRoute       = "/exports"
+	ImportsRoute       = "/imports"
 )
@@ -0,0 +1,61 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package http_middleware
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+)
+
+// ConnectionsQueueSize is the number of connections that may wait to be recorded.
+// When recorder lags behind, further connections are dropped instead of piling up.
+const ConnectionsQueueSize = 1024
+
+// ConnectionRecorder is called with the device and the client's IP address of each authenticated request.
+type ConnectionRecorder func(userId, deviceId, ipAddress string)
+
+type connection struct {
+	userId, deviceId, ipAddress string
+}
+
+// connectionsQueue hands connections over to a single goroutine that records them one at a time.
+type connectionsQueue chan connection
+
+func newConnectionsQueue(record ConnectionRecorder, size int) connectionsQueue {
+	queue := make(connectionsQueue, size)
+	go func() {
+		for c := range queue {
+			record(c.userId, c.deviceId, c.ipAddress)
+		}
+	}()
+	return queue
+}
+
+// push queues connection, it returns false if connection has been dropped because queue is full.
+func (queue connectionsQueue) push(c connection) bool {
+	select {
+	case queue <- c:
+		return true
+	default:
+		return false
+	}
+}
+
+// DeviceConnections records authenticated requests' client IP address, without delaying them.
+// It must be used after authentication middlewares. Each call starts a recording goroutine,
+// thus the returned handler is meant to be shared by all route groups.
+func DeviceConnections(record ConnectionRecorder) gin.HandlerFunc {
+	queue := newConnectionsQueue(record, ConnectionsQueueSize)
+	return func(c *gin.Context) {
+		userId, _ := c.MustGet("user_id").(string)
+		deviceId := c.Request.Header.Get(DeviceIdHeader)
+		if userId == "" || deviceId == "" {
+			return
+		}
+		if !queue.push(connection{userId, deviceId, c.ClientIP()}) {
+			log.Debugf("[DeviceConnections] queue is full, connection of device %s not recorded", deviceId)
+		}
+	}
+}
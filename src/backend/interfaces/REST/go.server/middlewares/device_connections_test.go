@@ -0,0 +1,56 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package http_middleware
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConnectionsQueue(t *testing.T) {
+	var lock sync.Mutex
+	running, maxRunning, recorded := 0, 0, 0
+	release := make(chan struct{})
+	queue := newConnectionsQueue(func(userId, deviceId, ipAddress string) {
+		lock.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		lock.Unlock()
+		<-release
+		lock.Lock()
+		running--
+		recorded++
+		lock.Unlock()
+	}, 2)
+
+	// first connection is being recorded, 2 are waiting, the other ones are dropped
+	queued := 0
+	for i := 0; i < 10; i++ {
+		if queue.push(connection{"user", "device", "192.0.2.1"}) {
+			queued++
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if queued != 3 {
+		t.Errorf("expected 3 connections queued, got %d", queued)
+	}
+	close(release)
+	for deadline := time.Now().Add(time.Second); time.Now().Before(deadline); time.Sleep(time.Millisecond) {
+		lock.Lock()
+		done := recorded == queued
+		lock.Unlock()
+		if done {
+			break
+		}
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if recorded != queued || maxRunning != 1 {
+		t.Errorf("expected %d connections recorded one at a time, got %d with up to %d at once", queued, recorded, maxRunning)
+	}
+}
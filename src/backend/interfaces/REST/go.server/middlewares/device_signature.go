@@ -0,0 +1,191 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package http_middleware
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gin-gonic/gin"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// headers of a device-signed request
+const (
+	DeviceIdHeader        = "X-Device-ID"
+	DeviceDateHeader      = "X-Caliopen-Date" // browsers can't set Date header, it is used as a fallback
+	DeviceSignatureHeader = "X-Caliopen-Device-Signature"
+
+	DefaultMaxSkew = 300 // seconds
+)
+
+type (
+	DeviceSignatureConfig struct {
+		Enabled bool     `mapstructure:"enabled"`
+		Groups  []string `mapstructure:"groups"`   // route groups that require signed requests, ie "/messages"
+		MaxSkew int      `mapstructure:"max_skew"` // max gap in seconds between request's date and server's clock
+	}
+
+	// DeviceRetriever is the facility used to retrieve devices along with their public keys
+	DeviceRetriever interface {
+		RetrieveDevice(userId, deviceId string) (*Device, CaliopenError)
+	}
+
+	ecdsaSignature struct {
+		R, S *big.Int
+	}
+)
+
+// Signs tells if requests to route group must be signed by user's device
+func (conf DeviceSignatureConfig) Signs(group string) bool {
+	if !conf.Enabled {
+		return false
+	}
+	for _, g := range conf.Groups {
+		if g == group || g == "*" {
+			return true
+		}
+	}
+	return false
+}
+
+// DeviceSignature checks that request has been signed by the device named in X-Device-ID header.
+// It must be used after BasicAuthFromCache, which sets user_id into context.
+func DeviceSignature(devices DeviceRetriever, conf DeviceSignatureConfig) gin.HandlerFunc {
+	maxSkew := time.Duration(conf.MaxSkew) * time.Second
+	if maxSkew <= 0 {
+		maxSkew = DefaultMaxSkew * time.Second
+	}
+
+	return func(c *gin.Context) {
+		userId, _ := c.MustGet("user_id").(string)
+		deviceId := c.Request.Header.Get(DeviceIdHeader)
+		if userId == "" || deviceId == "" {
+			kickUnauthorizedRequest(c, "")
+			return
+		}
+
+		body, err := ioutil.ReadAll(c.Request.Body)
+		if err != nil {
+			c.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+		c.Request.Body.Close()
+		c.Request.Body = ioutil.NopCloser(bytes.NewReader(body))
+
+		device, e := devices.RetrieveDevice(userId, deviceId)
+		if e != nil || device == nil {
+			kickUnauthorizedRequest(c, "")
+			return
+		}
+		if err = VerifyDeviceSignature(device, c.Request, body, time.Now(), maxSkew); err != nil {
+			log.WithError(err).Infof("[DeviceSignature] request of user %s rejected for device %s", userId, deviceId)
+			kickUnauthorizedRequest(c, "")
+			return
+		}
+	}
+}
+
+// RequestDate returns the date to sign, from X-Caliopen-Date header, or from Date header if former is missing.
+func RequestDate(r *http.Request) string {
+	if date := r.Header.Get(DeviceDateHeader); date != "" {
+		return date
+	}
+	return r.Header.Get("Date")
+}
+
+// CanonicalRequest builds the string signed by devices : METHOD \n path?query \n date \n hex(sha256(body))
+func CanonicalRequest(method, uri, date string, body []byte) string {
+	sum := sha256.Sum256(body)
+	return strings.ToUpper(method) + "\n" + uri + "\n" + date + "\n" + hex.EncodeToString(sum[:])
+}
+
+// VerifyDeviceSignature checks that request has been signed with one of device's valid public keys,
+// and that request's date is within maxSkew of now.
+func VerifyDeviceSignature(device *Device, r *http.Request, body []byte, now time.Time, maxSkew time.Duration) error {
+	if device.IsRevoked() {
+		return errors.New("device is revoked")
+	}
+	date := RequestDate(r)
+	sent, err := http.ParseTime(date)
+	if err != nil {
+		return errors.New("missing or invalid request date")
+	}
+	if skew := now.Sub(sent); skew > maxSkew || skew < -maxSkew {
+		return errors.New("request date is out of allowed skew")
+	}
+	signature, err := base64.StdEncoding.DecodeString(r.Header.Get(DeviceSignatureHeader))
+	if err != nil || len(signature) == 0 {
+		return errors.New("missing or invalid signature header")
+	}
+
+	hash := sha256.Sum256([]byte(CanonicalRequest(r.Method, r.URL.RequestURI(), date, body)))
+	for _, key := range device.PublicKeys {
+		pub, ok := ecdsaPublicKey(&key, now)
+		if !ok {
+			continue
+		}
+		if rs, ok := parseSignature(signature, pub.Curve); ok && ecdsa.Verify(pub, hash[:], rs.R, rs.S) {
+			return nil
+		}
+	}
+	return errors.New("signature does not match any of device's public keys")
+}
+
+// ecdsaPublicKey returns the ECDSA key described by key, if it is a valid signing key
+func ecdsaPublicKey(key *PublicKey, now time.Time) (*ecdsa.PublicKey, bool) {
+	if key.KeyType != "" && !strings.EqualFold(key.KeyType, "ec") {
+		return nil, false
+	}
+	if key.Use != "" && key.Use != "sig" {
+		return nil, false
+	}
+	if !key.ExpireDate.IsZero() && key.ExpireDate.Before(now) {
+		return nil, false
+	}
+	var curve elliptic.Curve
+	switch strings.ToUpper(key.Curve) {
+	case "P-256", "P256":
+		curve = elliptic.P256()
+	case "P-384", "P384":
+		curve = elliptic.P384()
+	case "P-521", "P521":
+		curve = elliptic.P521()
+	default:
+		return nil, false
+	}
+	x, y := new(big.Int).Set(&key.X), new(big.Int).Set(&key.Y)
+	if !curve.IsOnCurve(x, y) {
+		return nil, false
+	}
+	return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, true
+}
+
+// parseSignature reads an ASN.1 DER signature, or a raw r||s one as produced by WebCrypto.
+func parseSignature(signature []byte, curve elliptic.Curve) (*ecdsaSignature, bool) {
+	rs := new(ecdsaSignature)
+	if rest, err := asn1.Unmarshal(signature, rs); err == nil && len(rest) == 0 && rs.R != nil && rs.S != nil {
+		return rs, true
+	}
+	size := (curve.Params().BitSize + 7) / 8
+	if len(signature) != 2*size {
+		return nil, false
+	}
+	return &ecdsaSignature{
+		R: new(big.Int).SetBytes(signature[:size]),
+		S: new(big.Int).SetBytes(signature[size:]),
+	}, true
+}
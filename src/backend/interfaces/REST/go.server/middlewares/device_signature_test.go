@@ -0,0 +1,97 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package http_middleware
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// test vectors : P-256 key with d = c9afa9d845ba75166b5c215767b1d6934e50c3db36e89b127b8a622b120f6721
+const (
+	vectorX         = "60fed4ba255a9d31c961eb74c6356d68c049b8923b61fa6ce669622e60f29fb6"
+	vectorY         = "7903fe1008b8bc99a41ae9e95628bc64f2f1b20c2d7e9f5177a3c294d4462299"
+	vectorURI       = "/api/v2/messages/0c8b3fcf-84cb-4d34-9d2f-2b6e7c8b6a11?validate=true"
+	vectorDate      = "Fri, 19 Oct 2018 10:00:00 GMT"
+	vectorBody      = `{"body":"hello"}`
+	vectorCanonical = "PATCH\n" + vectorURI + "\n" + vectorDate + "\n1da63ae1d1c64f4549cece58555b23ef253aa7bb2c5ca6c48c12918863cff51a"
+	vectorDER       = "MEQCIFCjQgstjMmTuA+47javCu8DVSIWhQpMgMd1V+unSj8EAiAZ/0bpufahKsmgMIolLZSsM9S+hCkA5mla6gpHs3Tu7w=="
+	vectorRaw       = "UKNCCy2MyZO4D7juNq8K7wNVIhaFCkyAx3VX66dKPwQZ/0bpufahKsmgMIolLZSsM9S+hCkA5mla6gpHs3Tu7w=="
+)
+
+func vectorDevice() *Device {
+	key := PublicKey{Curve: "P-256", KeyType: "ec", Use: "sig"}
+	key.X.SetString(vectorX, 16)
+	key.Y.SetString(vectorY, 16)
+	return &Device{PublicKeys: PublicKeys{key}}
+}
+
+func vectorRequest(method, signature, dateHeader string) *http.Request {
+	r, _ := http.NewRequest(method, "http://localhost"+vectorURI, strings.NewReader(vectorBody))
+	r.Header.Set(dateHeader, vectorDate)
+	r.Header.Set(DeviceSignatureHeader, signature)
+	return r
+}
+
+func TestCanonicalRequest(t *testing.T) {
+	if c := CanonicalRequest("patch", vectorURI, vectorDate, []byte(vectorBody)); c != vectorCanonical {
+		t.Errorf("unexpected canonical request %q", c)
+	}
+}
+
+func TestVerifyDeviceSignature(t *testing.T) {
+	now, _ := http.ParseTime(vectorDate)
+	now = now.Add(time.Minute)
+	skew := 5 * time.Minute
+
+	for name, r := range map[string]*http.Request{
+		"DER signature":         vectorRequest("PATCH", vectorDER, DeviceDateHeader),
+		"raw r||s signature":    vectorRequest("PATCH", vectorRaw, DeviceDateHeader),
+		"Date header as backup": vectorRequest("PATCH", vectorDER, "Date"),
+	} {
+		if err := VerifyDeviceSignature(vectorDevice(), r, []byte(vectorBody), now, skew); err != nil {
+			t.Errorf("%s : valid signature rejected : %s", name, err)
+		}
+	}
+
+	r := vectorRequest("PATCH", vectorDER, DeviceDateHeader)
+	if VerifyDeviceSignature(vectorDevice(), r, []byte(`{"body":"tampered"}`), now, skew) == nil {
+		t.Error("signature of a tampered body should be rejected")
+	}
+	if VerifyDeviceSignature(vectorDevice(), vectorRequest("DELETE", vectorDER, DeviceDateHeader), []byte(vectorBody), now, skew) == nil {
+		t.Error("signature of another method should be rejected")
+	}
+	if VerifyDeviceSignature(vectorDevice(), r, []byte(vectorBody), now.Add(10*time.Minute), skew) == nil {
+		t.Error("request out of allowed skew should be rejected")
+	}
+	if VerifyDeviceSignature(vectorDevice(), vectorRequest("PATCH", "", DeviceDateHeader), []byte(vectorBody), now, skew) == nil {
+		t.Error("unsigned request should be rejected")
+	}
+
+	revoked := vectorDevice()
+	revoked.DateRevoked = now.Add(-time.Hour)
+	if VerifyDeviceSignature(revoked, r, []byte(vectorBody), now, skew) == nil {
+		t.Error("request from a revoked device should be rejected")
+	}
+	expired := vectorDevice()
+	expired.PublicKeys[0].ExpireDate = now.Add(-time.Hour)
+	if VerifyDeviceSignature(expired, r, []byte(vectorBody), now, skew) == nil {
+		t.Error("signature with an expired key should be rejected")
+	}
+}
+
+func TestDeviceSignatureConfig(t *testing.T) {
+	conf := DeviceSignatureConfig{Enabled: true, Groups: []string{MessagesRoute}}
+	if !conf.Signs(MessagesRoute) || conf.Signs(ContactsRoute) {
+		t.Error("only configured groups should require signed requests")
+	}
+	conf.Enabled = false
+	if conf.Signs(MessagesRoute) {
+		t.Error("no group should require signed requests when disabled")
+	}
+}
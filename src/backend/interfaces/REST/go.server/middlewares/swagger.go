@@ -21,6 +21,7 @@ import (
 	"io"
 	"io/ioutil"
 	"net/http"
+	"os"
 	"strings"
 	"sync"
 )
@@ -99,7 +100,12 @@ func SwaggerValidator() gin.HandlerFunc {
 func SwaggerInboundValidation(ctx *gin.Context) {
 	// make a copy of request to be able to drain body twice :
 	// one for swagger validation, other to ctx.next handlers
-	body1, body2, err := drainBody(ctx.Request.Body)
+	drain := drainBody
+	if strings.HasPrefix(ctx.ContentType(), "multipart/") {
+		// uploaded files may be large
+		drain = spoolBody
+	}
+	body1, body2, err := drain(ctx.Request.Body)
 	req_copy := new(http.Request)
 	*req_copy = *ctx.Request
 	ctx.Request.Body = body1
@@ -269,6 +275,34 @@ func drainBody(b io.ReadCloser) (r1, r2 io.ReadCloser, err error) {
 	return ioutil.NopCloser(&buf), ioutil.NopCloser(bytes.NewReader(buf.Bytes())), nil
 }
 
+// spoolBody is like drainBody, but bytes are written to a temporary file instead of memory.
+// File is unlinked at once, it is removed when the first ReadCloser is closed.
+func spoolBody(b io.ReadCloser) (r1, r2 io.ReadCloser, err error) {
+	if b == http.NoBody {
+		return http.NoBody, http.NoBody, nil
+	}
+	tmp, err := ioutil.TempFile("", "caliopen-body-")
+	if err != nil {
+		return nil, b, err
+	}
+	os.Remove(tmp.Name())
+	size, err := io.Copy(tmp, b)
+	if err == nil {
+		err = b.Close()
+	}
+	if err != nil {
+		tmp.Close()
+		return nil, b, err
+	}
+	return spooledBody{io.NewSectionReader(tmp, 0, size), tmp},
+		ioutil.NopCloser(io.NewSectionReader(tmp, 0, size)), nil
+}
+
+type spooledBody struct {
+	*io.SectionReader
+	io.Closer
+}
+
 // Func copied from go-openapi
 func newSecureAPI(ctx *middleware.Context, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(rw http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,30 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package http_middleware
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSpoolBody(t *testing.T) {
+	content := bytes.Repeat([]byte("archive "), 100000)
+	r1, r2, err := spoolBody(ioutil.NopCloser(bytes.NewReader(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// validation reads its copy first, then handlers read theirs
+	for i, r := range []io.ReadCloser{r2, r1} {
+		read, err := ioutil.ReadAll(r)
+		if err != nil || !bytes.Equal(read, content) {
+			t.Errorf("copy %d : unexpected body of %d bytes, err %v", i, len(read), err)
+		}
+		if err = r.Close(); err != nil {
+			t.Errorf("copy %d : failed to close : %s", i, err)
+		}
+	}
+}
@@ -229,3 +229,44 @@ func DeleteDevice(ctx *gin.Context) {
 		ctx.Status(http.StatusNoContent)
 	}
 }
+
+// Actions handles POST /devices/:deviceID/actions
+func Actions(ctx *gin.Context) {
+	userId, err := operations.NormalizeUUIDstring(ctx.MustGet("user_id").(string))
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	deviceId, err := operations.NormalizeUUIDstring(ctx.Param("deviceID"))
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	var order struct {
+		Actions []string `json:"actions"`
+	}
+	if err = ctx.BindJSON(&order); err != nil || len(order.Actions) == 0 {
+		e := swgErr.New(http.StatusBadRequest, "actions are missing")
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	switch order.Actions[0] {
+	case DeviceRevokeAction:
+		byDeviceId := ctx.Request.Header.Get(http_middleware.DeviceIdHeader)
+		Cerr := caliopen.Facilities.RESTfacility.RevokeDevice(userId, deviceId, byDeviceId, ctx.ClientIP(), caliopen.Facilities.Notifiers)
+		if Cerr != nil {
+			operations.ServeCaliopenError(ctx, Cerr)
+			return
+		}
+		ctx.Status(http.StatusNoContent)
+	default:
+		e := swgErr.New(http.StatusNotImplemented, "unknown action "+order.Actions[0])
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+	}
+}
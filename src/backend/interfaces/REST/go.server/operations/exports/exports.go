@@ -0,0 +1,114 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package exports
+
+import (
+	"fmt"
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/middlewares"
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	swgErr "github.com/go-openapi/errors"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// NewExport handles POST /exports
+// export runs in background, user is notified when it's done.
+func NewExport(ctx *gin.Context) {
+	var payload struct {
+		Format string `json:"format"`
+	}
+	b := binding.JSON
+	if err := b.Bind(ctx.Request, &payload); err != nil {
+		e := swgErr.New(http.StatusBadRequest, fmt.Sprintf("Unable to json marshal the provided payload : %s", err))
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	userId := ctx.MustGet("user_id").(string)
+	export, err := caliopen.Facilities.RESTfacility.CreateExport(userId, payload.Format, caliopen.Facilities.Notifiers)
+	if err != nil {
+		operations.ServeCaliopenError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusAccepted, struct {
+		Location string `json:"location"`
+		ExportId string `json:"export_id"`
+	}{
+		http_middleware.RoutePrefix + http_middleware.ExportsRoute + "/" + export.ExportId.String(),
+		export.ExportId.String(),
+	})
+}
+
+// GetExport handles GET /exports/:export_id
+func GetExport(ctx *gin.Context) {
+	userId := ctx.MustGet("user_id").(string)
+	exportId, err := operations.NormalizeUUIDstring(ctx.Param("export_id"))
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	export, CalErr := caliopen.Facilities.RESTfacility.RetrieveExport(userId, exportId)
+	if CalErr != nil {
+		operations.ServeCaliopenError(ctx, CalErr)
+		return
+	}
+	export_json, err := export.MarshalFrontEnd()
+	if err != nil {
+		e := swgErr.New(http.StatusFailedDependency, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+	} else {
+		ctx.Data(http.StatusOK, "application/json; charset=utf-8", export_json)
+	}
+}
+
+// DownloadExport handles GET /exports/:export_id/download
+func DownloadExport(ctx *gin.Context) {
+	userId := ctx.MustGet("user_id").(string)
+	exportId, err := operations.NormalizeUUIDstring(ctx.Param("export_id"))
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	export, content, CalErr := caliopen.Facilities.RESTfacility.OpenExport(userId, exportId)
+	if CalErr != nil {
+		operations.ServeCaliopenError(ctx, CalErr)
+		return
+	}
+	filename := "caliopen-" + export.Format + "-" + export.DateInsert.Format("2006-01-02") + ".zip"
+	ctx.Header("Content-Type", "application/zip")
+	ctx.Header("Content-Length", strconv.Itoa(export.Size))
+	ctx.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	ctx.Status(http.StatusOK)
+	io.Copy(ctx.Writer, content)
+}
+
+// DeleteExport handles DELETE /exports/:export_id
+func DeleteExport(ctx *gin.Context) {
+	userId := ctx.MustGet("user_id").(string)
+	exportId, err := operations.NormalizeUUIDstring(ctx.Param("export_id"))
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	CalErr := caliopen.Facilities.RESTfacility.DeleteExport(userId, exportId)
+	if CalErr != nil {
+		operations.ServeCaliopenError(ctx, CalErr)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -1,8 +1,12 @@
 package operations
 
 import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/middlewares"
 	"github.com/gin-gonic/gin"
+	swgErr "github.com/go-openapi/errors"
 	"github.com/satori/go.uuid"
+	"net/http"
 	"strconv"
 	"strings"
 )
@@ -42,6 +46,13 @@ func GetImportanceLevel(ctx *gin.Context) (il [2]int8) {
 	}
 }
 
+// LoadRemoteContent tells if remote content of messages' html bodies should be loaded, as asked by `load_remote_content` query param.
+// Remote content is blocked by default.
+func LoadRemoteContent(ctx *gin.Context) bool {
+	load, _ := strconv.ParseBool(ctx.Query("load_remote_content"))
+	return load
+}
+
 // NormalizeUUIDstring returns a valid uuidv4 string from input
 // or an error if input string is invalid.
 // Following input formats are supported:
@@ -56,3 +67,22 @@ func NormalizeUUIDstring(uuid_str string) (string, error) {
 	}
 	return uuid.String(), nil
 }
+
+// ServeCaliopenError writes the http error matching err's code, then aborts request's handling.
+func ServeCaliopenError(ctx *gin.Context, err CaliopenError) {
+	returnedErr := new(swgErr.CompositeError)
+	switch {
+	case err.Code() == NotFoundCaliopenErr || (err.Code() == DbCaliopenErr && err.Cause() != nil && err.Cause().Error() == "not found"):
+		returnedErr = swgErr.CompositeValidationError(swgErr.New(http.StatusNotFound, "db returned not found"), err, err.Cause())
+	case err.Code() == ForbiddenCaliopenErr:
+		returnedErr = swgErr.CompositeValidationError(swgErr.New(http.StatusForbidden, err.Error()))
+	case err.Code() == UnprocessableCaliopenErr:
+		returnedErr = swgErr.CompositeValidationError(swgErr.New(http.StatusUnprocessableEntity, err.Error()))
+	case err.Code() == NotImplementedCaliopenErr:
+		returnedErr = swgErr.CompositeValidationError(swgErr.New(http.StatusNotImplemented, err.Error()))
+	default:
+		returnedErr = swgErr.CompositeValidationError(swgErr.New(http.StatusFailedDependency, err.Error()), err, err.Cause())
+	}
+	http_middleware.ServeError(ctx.Writer, ctx.Request, returnedErr)
+	ctx.Abort()
+}
@@ -0,0 +1,67 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package imports
+
+import (
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/middlewares"
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main"
+	"github.com/gin-gonic/gin"
+	swgErr "github.com/go-openapi/errors"
+	"net/http"
+)
+
+// NewImport handles POST /imports
+// archive is uploaded as multipart form's "archive" file, import runs in background.
+func NewImport(ctx *gin.Context) {
+	file, _, err := ctx.Request.FormFile("archive")
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	defer file.Close()
+	userId := ctx.MustGet("user_id").(string)
+	userImport, CalErr := caliopen.Facilities.RESTfacility.CreateImport(userId, file)
+	if CalErr != nil {
+		operations.ServeCaliopenError(ctx, CalErr)
+		return
+	}
+	ctx.JSON(http.StatusAccepted, struct {
+		Location string `json:"location"`
+		ImportId string `json:"import_id"`
+	}{
+		http_middleware.RoutePrefix + http_middleware.ImportsRoute + "/" + userImport.ImportId.String(),
+		userImport.ImportId.String(),
+	})
+}
+
+// GetImport handles GET /imports/:import_id
+func GetImport(ctx *gin.Context) {
+	userId := ctx.MustGet("user_id").(string)
+	importId, err := operations.NormalizeUUIDstring(ctx.Param("import_id"))
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	userImport, CalErr := caliopen.Facilities.RESTfacility.RetrieveImport(userId, importId)
+	if CalErr != nil {
+		operations.ServeCaliopenError(ctx, CalErr)
+		return
+	}
+	import_json, err := userImport.MarshalFrontEnd()
+	if err != nil {
+		e := swgErr.New(http.StatusFailedDependency, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+	} else {
+		ctx.Data(http.StatusOK, "application/json; charset=utf-8", import_json)
+	}
+}
@@ -67,6 +67,12 @@ func Actions(ctx *gin.Context) {
 			} else {
 				ctx.Status(http.StatusNoContent)
 			}
+		case "restore":
+			if err := caliopen.Facilities.RESTfacility.RestoreMessage(user_id, msg_id); err != nil {
+				operations.ServeCaliopenError(ctx, err)
+			} else {
+				ctx.Status(http.StatusNoContent)
+			}
 		default:
 			e := swgErr.New(http.StatusNotImplemented, err.Error())
 			http_middleware.ServeError(ctx.Writer, ctx.Request, e)
@@ -0,0 +1,64 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package messages
+
+import (
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/middlewares"
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main"
+	"github.com/gin-gonic/gin"
+	swgErr "github.com/go-openapi/errors"
+	"io/ioutil"
+	"net/http"
+)
+
+// NewDraft handles POST …/messages
+func NewDraft(ctx *gin.Context) {
+	user_id := ctx.MustGet("user_id").(string)
+	payload, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	draft, Cerr := caliopen.Facilities.RESTfacility.CreateDraft(user_id, payload)
+	if Cerr != nil {
+		operations.ServeCaliopenError(ctx, Cerr)
+		return
+	}
+	ctx.JSON(http.StatusOK, struct {
+		Location  string `json:"location"`
+		MessageId string `json:"message_id"`
+	}{
+		http_middleware.RoutePrefix + http_middleware.MessagesRoute + "/" + draft.Message_id.String(),
+		draft.Message_id.String(),
+	})
+}
+
+// PatchDraft handles PATCH …/messages/:message_id
+// Front-end calls it at each autosave of the draft.
+func PatchDraft(ctx *gin.Context) {
+	user_id := ctx.MustGet("user_id").(string)
+	msg_id, err := operations.NormalizeUUIDstring(ctx.Param("message_id"))
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	patch, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	if Cerr := caliopen.Facilities.RESTfacility.PatchDraft(patch, user_id, msg_id); Cerr != nil {
+		operations.ServeCaliopenError(ctx, Cerr)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -44,6 +44,13 @@ func GetMessagesList(ctx *gin.Context) {
 		offset, _ = strconv.Atoi(o[0])
 		query_values.Del("offset")
 	}
+	var trash bool
+	if t, ok := query_values["trash"]; ok {
+		trash, _ = strconv.ParseBool(t[0])
+		query_values.Del("trash")
+	}
+	loadRemote := operations.LoadRemoteContent(ctx)
+	query_values.Del("load_remote_content")
 
 	filter := IndexSearch{
 		User_id: user_UUID,
@@ -51,8 +58,9 @@ func GetMessagesList(ctx *gin.Context) {
 		Limit:   limit,
 		Offset:  offset,
 		ILrange: operations.GetImportanceLevel(ctx),
+		Trash:   trash,
 	}
-	list, totalFound, err := caliopen.Facilities.RESTfacility.GetMessagesList(filter)
+	list, totalFound, err := caliopen.Facilities.RESTfacility.GetMessagesList(filter, loadRemote)
 	if err != nil {
 		e := swgErr.New(http.StatusFailedDependency, err.Error())
 		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
@@ -95,7 +103,7 @@ func GetMessage(ctx *gin.Context) {
 		ctx.Abort()
 		return
 	}
-	msg, err := caliopen.Facilities.RESTfacility.GetMessage(user_id, msg_id)
+	msg, err := caliopen.Facilities.RESTfacility.GetMessage(user_id, msg_id, operations.LoadRemoteContent(ctx))
 	if err != nil {
 		e := swgErr.New(http.StatusFailedDependency, err.Error())
 		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
@@ -118,3 +126,21 @@ func GetMessage(ctx *gin.Context) {
 		ctx.Data(http.StatusOK, "application/json; charset=utf-8", msg_json)
 	}
 }
+
+// DELETE …/messages/:message_id
+// moves message to trash
+func DeleteMessage(ctx *gin.Context) {
+	user_id := ctx.MustGet("user_id").(string)
+	msg_id, err := operations.NormalizeUUIDstring(ctx.Param("message_id"))
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	if err := caliopen.Facilities.RESTfacility.DeleteMessage(user_id, msg_id); err != nil {
+		operations.ServeCaliopenError(ctx, err)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
@@ -0,0 +1,50 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package messages
+
+import (
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/middlewares"
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main"
+	"github.com/gin-gonic/gin"
+	swgErr "github.com/go-openapi/errors"
+	"net/http"
+	"strconv"
+)
+
+// GetRemoteContent handles GET …/messages/:message_id/remote-content?url=…&sig=…
+// It serves a remote image of the message through proxy, thus remote server never sees user.
+// Only urls signed when message was displayed are fetched.
+func GetRemoteContent(ctx *gin.Context) {
+	user_id := ctx.MustGet("user_id").(string)
+	msg_id, err := operations.NormalizeUUIDstring(ctx.Param("message_id"))
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	remoteURL, sig := ctx.Query("url"), ctx.Query("sig")
+	if remoteURL == "" || sig == "" {
+		e := swgErr.New(http.StatusUnprocessableEntity, "url and sig query parameters are mandatory")
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	content, contentType, CalErr := caliopen.Facilities.RESTfacility.FetchRemoteContent(user_id, msg_id, remoteURL, sig)
+	if CalErr != nil {
+		operations.ServeCaliopenError(ctx, CalErr)
+		return
+	}
+	// content type has been sniffed by proxy, browser must neither guess another one nor run anything
+	ctx.Header("Content-Type", contentType)
+	ctx.Header("Content-Length", strconv.Itoa(len(content)))
+	ctx.Header("X-Content-Type-Options", "nosniff")
+	ctx.Header("Content-Security-Policy", "default-src 'none'")
+	ctx.Header("Referrer-Policy", "no-referrer")
+	ctx.Header("Cache-Control", "private, max-age=86400")
+	ctx.Status(http.StatusOK)
+	ctx.Writer.Write(content)
+}
@@ -0,0 +1,204 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package saved_searches
+
+import (
+	"bytes"
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/middlewares"
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/operations"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main"
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/binding"
+	swgErr "github.com/go-openapi/errors"
+	"github.com/satori/go.uuid"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+)
+
+// GetSavedSearchesList handles GET /saved-searches
+func GetSavedSearchesList(ctx *gin.Context) {
+	userId := ctx.MustGet("user_id").(string)
+	searches, err := caliopen.Facilities.RESTfacility.RetrieveSavedSearches(userId, operations.GetImportanceLevel(ctx))
+	if err != nil && err.Cause().Error() != "saved searches not found" {
+		operations.ServeCaliopenError(ctx, err)
+		return
+	}
+	var respBuf bytes.Buffer
+	respBuf.WriteString("{\"total\": " + strconv.Itoa(len(searches)) + ",")
+	respBuf.WriteString(("\"saved_searches\":["))
+	first := true
+	for _, search := range searches {
+		json_search, err := search.MarshalFrontEnd()
+		if err == nil {
+			if first {
+				first = false
+			} else {
+				respBuf.WriteByte(',')
+			}
+			respBuf.Write(json_search)
+		}
+	}
+	respBuf.WriteString("]}")
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", respBuf.Bytes())
+}
+
+// NewSavedSearch handles POST /saved-searches
+func NewSavedSearch(ctx *gin.Context) {
+	var search SavedSearch
+	b := binding.JSON
+	if err := b.Bind(ctx.Request, &search); err != nil {
+		e := swgErr.New(http.StatusBadRequest, fmt.Sprintf("Unable to json marshal the provided payload : %s", err))
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	user_uuid, _ := uuid.FromString(ctx.MustGet("user_id").(string))
+	search.UserId.UnmarshalBinary(user_uuid.Bytes())
+	// ids are always generated by backend
+	search.SearchId = UUID{}
+
+	err := caliopen.Facilities.RESTfacility.CreateSavedSearch(&search)
+	if err != nil {
+		operations.ServeCaliopenError(ctx, err)
+		return
+	}
+	ctx.JSON(http.StatusOK, struct {
+		Location string `json:"location"`
+		SearchId string `json:"search_id"`
+	}{
+		http_middleware.RoutePrefix + http_middleware.SavedSearchesRoute + "/" + search.SearchId.String(),
+		search.SearchId.String(),
+	})
+}
+
+// GetSavedSearch handles GET /saved-searches/:search_id
+func GetSavedSearch(ctx *gin.Context) {
+	userId := ctx.MustGet("user_id").(string)
+	searchId, err := operations.NormalizeUUIDstring(ctx.Param("search_id"))
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	search, CalErr := caliopen.Facilities.RESTfacility.RetrieveSavedSearch(userId, searchId, operations.GetImportanceLevel(ctx))
+	if CalErr != nil {
+		operations.ServeCaliopenError(ctx, CalErr)
+		return
+	}
+	search_json, err := search.MarshalFrontEnd()
+	if err != nil {
+		e := swgErr.New(http.StatusFailedDependency, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+	} else {
+		ctx.Data(http.StatusOK, "application/json; charset=utf-8", search_json)
+	}
+}
+
+// PatchSavedSearch handles PATCH /saved-searches/:search_id
+func PatchSavedSearch(ctx *gin.Context) {
+	userId := ctx.MustGet("user_id").(string)
+	searchId, err := operations.NormalizeUUIDstring(ctx.Param("search_id"))
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	patch, err := ioutil.ReadAll(ctx.Request.Body)
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	CalErr := caliopen.Facilities.RESTfacility.PatchSavedSearch(patch, userId, searchId)
+	if CalErr != nil {
+		operations.ServeCaliopenError(ctx, CalErr)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// DeleteSavedSearch handles DELETE /saved-searches/:search_id
+func DeleteSavedSearch(ctx *gin.Context) {
+	userId := ctx.MustGet("user_id").(string)
+	searchId, err := operations.NormalizeUUIDstring(ctx.Param("search_id"))
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	CalErr := caliopen.Facilities.RESTfacility.DeleteSavedSearch(userId, searchId)
+	if CalErr != nil {
+		operations.ServeCaliopenError(ctx, CalErr)
+		return
+	}
+	ctx.Status(http.StatusNoContent)
+}
+
+// RunSavedSearch handles GET /saved-searches/:search_id/messages
+// it returns messages matching the saved search, the same way GET /messages does.
+func RunSavedSearch(ctx *gin.Context) {
+	// temporary hack to check if X-Caliopen-IL header is in request, because go-openapi pkg fails to do it.
+	if _, ok := ctx.Request.Header["X-Caliopen-Il"]; !ok {
+		e := swgErr.New(http.StatusFailedDependency, "Missing mandatory header 'X-Caliopen-Il'.")
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	userId := ctx.MustGet("user_id").(string)
+	searchId, err := operations.NormalizeUUIDstring(ctx.Param("search_id"))
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	var limit, offset int
+	if l, ok := ctx.GetQuery("limit"); ok {
+		limit, _ = strconv.Atoi(l)
+	}
+	if o, ok := ctx.GetQuery("offset"); ok {
+		offset, _ = strconv.Atoi(o)
+	}
+
+	list, totalFound, CalErr := caliopen.Facilities.RESTfacility.RunSavedSearch(userId, searchId, operations.GetImportanceLevel(ctx), limit, offset, operations.LoadRemoteContent(ctx))
+	if CalErr != nil {
+		operations.ServeCaliopenError(ctx, CalErr)
+		return
+	}
+	settings, err := caliopen.Facilities.RESTfacility.GetSettings(userId)
+	if err != nil {
+		e := swgErr.New(http.StatusFailedDependency, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	var respBuf bytes.Buffer
+	respBuf.WriteString("{\"total\": " + strconv.FormatInt(totalFound, 10) + ",")
+	respBuf.WriteString("\"messages\":[")
+	first := true
+	for _, msg := range list {
+		json_msg, err := msg.MarshalFrontEnd(settings.MessageDisplayFormat)
+		if err == nil {
+			if first {
+				first = false
+			} else {
+				respBuf.WriteByte(',')
+			}
+			respBuf.Write(json_msg)
+		}
+	}
+	respBuf.WriteString("]}")
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", respBuf.Bytes())
+}
@@ -2,6 +2,7 @@ package operations
 
 import (
 	"errors"
+	"fmt"
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
 	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server/middlewares"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.main"
@@ -128,6 +129,124 @@ func SimpleSearch(ctx *gin.Context) {
 	}
 }
 
+// payload expected by AdvancedSearch
+type advancedSearchPayload struct {
+	Term    string         `json:"term"`
+	Field   string         `json:"field"`
+	DocType string         `json:"doctype"`
+	Limit   int            `json:"limit"`
+	Offset  int            `json:"offset"`
+	Facets  []FacetRequest `json:"facets"`
+}
+
+// AdvancedSearch handles POST /search
+// it takes the same params as SimpleSearch within a json payload, plus a list of facets to compute along with the results
 func AdvancedSearch(ctx *gin.Context) {
-	ctx.AbortWithStatus(http.StatusNotImplemented)
+	if _, ok := ctx.Request.Header["X-Caliopen-Il"]; !ok {
+		e := swgErr.New(http.StatusFailedDependency, "Missing mandatory header 'X-Caliopen-Il'.")
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+
+	user_uuid, _ := uuid.FromString(ctx.MustGet("user_id").(string))
+	var user_UUID UUID
+	user_UUID.UnmarshalBinary(user_uuid.Bytes())
+
+	var payload advancedSearchPayload
+	if err := ctx.ShouldBindJSON(&payload); err != nil {
+		e := swgErr.New(http.StatusBadRequest, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+
+	// check request consistency. (see search API readme in doc folder)
+	invalid := false
+	reasons := []error{}
+	if len(payload.Term) < 3 {
+		invalid = true
+		reasons = append(reasons, errors.New("'term' param must length 3 chars at least"))
+	}
+	if payload.DocType == "" && (payload.Limit > 0 || payload.Offset > 0) {
+		invalid = true
+		reasons = append(reasons, errors.New("'limit' and 'offset' params only allowed if 'doctype' param also provided"))
+	}
+
+	search := IndexSearch{
+		User_id: user_UUID,
+		Limit:   payload.Limit,
+		Offset:  payload.Offset,
+		ILrange: GetImportanceLevel(ctx),
+		Facets:  []FacetRequest{},
+	}
+	if payload.Field != "" {
+		search.Terms = map[string][]string{payload.Field: {payload.Term}}
+	} else {
+		search.Terms = map[string][]string{"_all": {payload.Term}}
+	}
+	switch payload.DocType {
+	case "":
+	case "message":
+		search.DocType = MessageIndexType
+	case "contact":
+		search.DocType = ContactIndexType
+	default:
+		invalid = true
+		reasons = append(reasons, errors.New("'doctype' unknown"))
+	}
+
+	requested := map[string]bool{}
+	for _, facet := range payload.Facets {
+		if !IsValidFacetName(facet.Name) {
+			invalid = true
+			reasons = append(reasons, fmt.Errorf("facet '%s' unknown", facet.Name))
+			continue
+		}
+		if requested[facet.Name] {
+			invalid = true
+			reasons = append(reasons, fmt.Errorf("facet '%s' requested more than once", facet.Name))
+			continue
+		}
+		requested[facet.Name] = true
+		// an omitted size is 0, which stands for DefaultFacetSize
+		if facet.Size < 0 || facet.Size > MaxFacetSize {
+			invalid = true
+			reasons = append(reasons, fmt.Errorf("facet '%s' size must be between 1 and %d, or 0 for default size (%d)", facet.Name, MaxFacetSize, DefaultFacetSize))
+		}
+		if facet.Interval != "" && (facet.Name != FacetDates || !IsValidFacetInterval(facet.Interval)) {
+			invalid = true
+			reasons = append(reasons, fmt.Errorf("invalid interval '%s' for facet '%s'", facet.Interval, facet.Name))
+		}
+		facet.MarshallNew()
+		search.Facets = append(search.Facets, facet)
+	}
+	if len(search.Facets) > 0 && search.DocType == ContactIndexType {
+		invalid = true
+		reasons = append(reasons, errors.New("facets are not available for 'contact' doctype"))
+	}
+
+	if invalid {
+		e := swgErr.CompositeValidationError(reasons...)
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+
+	// trigger the search
+	result, err := caliopen.Facilities.RESTfacility.Search(search)
+	if err != nil {
+		e := swgErr.New(http.StatusFailedDependency, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	response, err := result.MarshalFrontEnd()
+	if err != nil {
+		e := swgErr.New(http.StatusFailedDependency, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	ctx.Data(http.StatusOK, "application/json; charset=utf-8", response)
 }
@@ -58,6 +58,47 @@ func PatchUser(ctx *gin.Context) {
 
 }
 
+// DeleteUser handles DELETE …/users/{user_id}
+// account is disabled at once, its data is purged after a grace period.
+func DeleteUser(ctx *gin.Context) {
+	auth_user := ctx.MustGet("user_id").(string)
+	user_id, err := operations.NormalizeUUIDstring(ctx.Param("user_id"))
+	if err != nil {
+		e := swgErr.New(http.StatusUnprocessableEntity, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+	// an user can only delete himself
+	if auth_user != user_id {
+		e := swgErr.New(http.StatusUnauthorized, "user can only delete himself")
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+		return
+	}
+
+	deletion, CalErr := caliopen.Facilities.RESTfacility.RequestUserDeletion(user_id)
+	if CalErr != nil {
+		returnedErr := new(swgErr.CompositeError)
+		if CalErr.Code() == NotFoundCaliopenErr {
+			returnedErr = swgErr.CompositeValidationError(swgErr.New(http.StatusNotFound, "db returned not found"), CalErr, CalErr.Cause())
+		} else {
+			returnedErr = swgErr.CompositeValidationError(swgErr.New(http.StatusFailedDependency, CalErr.Error()), CalErr, CalErr.Cause())
+		}
+		http_middleware.ServeError(ctx.Writer, ctx.Request, returnedErr)
+		ctx.Abort()
+		return
+	}
+	deletion_json, err := deletion.MarshalFrontEnd()
+	if err != nil {
+		e := swgErr.New(http.StatusFailedDependency, err.Error())
+		http_middleware.ServeError(ctx.Writer, ctx.Request, e)
+		ctx.Abort()
+	} else {
+		ctx.Data(http.StatusAccepted, "application/json; charset=utf-8", deletion_json)
+	}
+}
+
 // RequestPasswordReset handles an anonymous POST request on /passwords/reset/ with json payload
 // it will try to trigger a password reset procedure
 func RequestPasswordReset(ctx *gin.Context) {
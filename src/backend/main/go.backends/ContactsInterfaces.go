@@ -17,6 +17,12 @@ type ContactStorage interface {
 	DeleteContact(contact *Contact) error
 }
 
+// ContactKeysStorage saves public keys of contacts, PublicKey.ResourceId being the contact id
+type ContactKeysStorage interface {
+	CreatePublicKey(key *PublicKey) error
+	UpdatePublicKey(key *PublicKey) error
+}
+
 type ContactIndex interface {
 	CreateContact(contact *Contact) error
 	UpdateContact(contact *Contact, fields map[string]interface{}) error // 'fields' are the struct fields names that have been modified
@@ -0,0 +1,48 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package backends
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+)
+
+type UserDeletionStorage interface {
+	CreateUserDeletion(deletion *UserDeletion) error
+	RetrieveUserDeletion(userId string) (deletion *UserDeletion, err error)
+	UpdateUserDeletion(deletion *UserDeletion, modifiedFields map[string]interface{}) error
+	DeleteUserDeletion(userId string) error
+	// channel is closed once all deletions have been sent
+	RetrieveUserDeletions() (<-chan *UserDeletion, error)
+}
+
+// UserPurgeStore is the storage needed to delete an user's account with all its data.
+// Each Delete… method could be run again after a failure, until it succeeds.
+type UserPurgeStore interface {
+	UserDeletionStorage
+	RetrieveUser(user_id string) (user *User, err error)
+	UpdateUser(user *User, fields map[string]interface{}) error
+	RetrieveAllMessages(userId string) (<-chan *Message, error)
+	DeleteUserRawMessages(userId string) error
+	DeleteUserArchives(userId string) error
+	DeleteUserPartitions(userId string) error
+	DeleteUserAccount(user *User) error
+}
+
+// PurgerStore is the storage needed by the purger worker, to enforce retention policies and delete accounts
+type PurgerStore interface {
+	RetentionStore
+	UserPurgeStore
+}
+
+type PurgerIndex interface {
+	RetentionIndex
+	DeleteUserIndex(user_id string) error
+}
+
+type UserSessionsCache interface {
+	DeleteUserSessions(user_id string) error
+}
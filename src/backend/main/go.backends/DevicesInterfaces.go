@@ -16,4 +16,10 @@ type DevicesStorage interface {
 	RetrieveDevice(userId, deviceId string) (device *Device, err error)
 	UpdateDevice(device, oldDevice *Device, modifiedFields map[string]interface{}) error
 	DeleteDevice(device *Device) error
+	// audit of actions done on devices, most recent first
+	CreateDeviceAudit(audit *DeviceAudit) error
+	RetrieveDeviceAudits(userId string) ([]DeviceAudit, error)
+	// locations devices have been seen from
+	CreateDeviceLocation(location *DeviceLocation) error
+	CreateDeviceConnection(connection *DeviceConnection) error
 }
@@ -0,0 +1,21 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package backends
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+)
+
+type ExportsStorage interface {
+	CreateUserExport(export *UserExport) error
+	RetrieveUserExport(userId, exportId string) (export *UserExport, err error)
+	UpdateUserExport(export *UserExport, modifiedFields map[string]interface{}) error
+	DeleteUserExport(userId, exportId string) error
+	// channels are closed once all user's objects have been sent
+	RetrieveAllMessages(userId string) (<-chan *Message, error)
+	RetrieveAllContacts(userId string) (<-chan *Contact, error)
+}
@@ -0,0 +1,19 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package backends
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"time"
+)
+
+type InteractionsStorage interface {
+	// RecordInteractions increments sent (or received) counter for each address and sets last interaction date to `at`
+	RecordInteractions(userId, protocol string, addresses []string, sent bool, at time.Time) error
+	// RetrieveInteractions returns interactions found for addresses, keyed by normalized address
+	RetrieveInteractions(userId string, addresses []string) (interactions map[string]ParticipantInteraction, err error)
+}
@@ -0,0 +1,13 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package backends
+
+// KeysStore manages the keys used to encrypt contents at rest
+type KeysStore interface {
+	Close()
+	RetrieveAllUsersIds() (<-chan string, error)
+	RewrapUserDataKeys() (count int, err error)
+	RotateUserDataKey(user_id string, reencrypt bool) error
+}
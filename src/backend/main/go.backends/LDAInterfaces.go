@@ -17,19 +17,45 @@ type LDAStore interface {
 	GetSettings(user_id string) (settings *Settings, err error)
 	CreateMessage(msg *Message) error
 
-	StoreRawMessage(msg RawMessage) (err error)
+	StoreRawMessage(msg *RawMessage, users []UUID) (err error) // msg.Raw_msg_id is updated if raw message already exists
+	GetRawMessage(raw_message_id string) (raw_message RawMessage, err error)
+	DeleteRawMessage(user_id, raw_msg_id string) error
 	UpdateMessage(msg *Message, fields map[string]interface{}) error // 'fields' are the struct fields names that have been modified
 	CreateThreadLookup(user_id, discussion_id UUID, external_msg_id string) error
+	RaiseDiscussionImportanceLevel(user_id, discussion_id string, level int32) error
+	GetLocalsIdentities(user_id string) (identities []LocalIdentity, err error)
 
 	LookupContactsByIdentifier(user_id, address string) (contact_ids []string, err error)
+	RetrieveSavedSearches(userId string) (searches []SavedSearch, err error)
+	InteractionsStorage
 
+	RetrieveUserTags(user_id string) (tags []Tag, err error)
+	CreateTag(tag *Tag) error
+	ImportsStorage
+
+	StoreAttachment(user_id, attachment_id string, file io.Reader) (uri string, size int, err error)
 	GetAttachment(uri string) (file io.Reader, err error)
 	DeleteAttachment(uri string) error
 	AttachmentExists(uri string) bool
+
+	// to replicate store writes to index, see OutboxStore
+	IndexOutbox
+	RetrieveContact(user_id, contact_id string) (contact *Contact, err error)
+	ContactKeysStorage
 }
 
 type LDAIndex interface {
 	Close()
 	CreateMessage(msg *Message) error
 	UpdateMessage(msg *Message, fields map[string]interface{}) error // 'fields' are the struct fields names that have been modified
+	IndexAttachmentsText(user_id, message_id string, texts []AttachmentText) error
+	Search(search IndexSearch) (result *IndexResult, err error)
+	RefreshMessages(user_id string) error // makes recent writes visible to Search
+	MessageExistsByExternalId(user_id, external_msg_id string) (bool, error)
+
+	// to apply index mutations, see OutboxIndex
+	DeleteMessage(msg *Message) error
+	CreateContact(contact *Contact) error
+	UpdateContact(contact *Contact, fields map[string]interface{}) error
+	DeleteContact(contact *Contact) error
 }
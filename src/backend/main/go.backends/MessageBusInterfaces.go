@@ -0,0 +1,21 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package backends
+
+import (
+	"github.com/nats-io/go-nats"
+	"time"
+)
+
+// MessageBus is the subset of NATS connection used by facilities and workers to exchange orders.
+// It is implemented by *nats.Conn, and by memory.Bus to run all components within a single process.
+type MessageBus interface {
+	Publish(subject string, data []byte) error
+	Request(subject string, data []byte, timeout time.Duration) (*nats.Msg, error)
+	QueueSubscribe(subject, queue string, handler nats.MsgHandler) (*nats.Subscription, error)
+	Flush() error
+	LastError() error
+	Close()
+}
@@ -17,5 +17,6 @@ type MessageIndex interface {
 	SetMessageUnread(user_id, message_id string, status bool) error
 	CreateMessage(msg *Message) error
 	UpdateMessage(msg *Message, fields map[string]interface{}) error // 'fields' are the struct fields names that have been modified
+	DeleteMessage(msg *Message) error
 	FilterMessages(search IndexSearch) (messages []*Message, totalFound int64, err error)
 }
@@ -0,0 +1,55 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package backends
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+)
+
+// IndexOutbox holds index mutations until they have been applied to index
+type IndexOutbox interface {
+	CreateIndexMutation(mutation *IndexMutation) error
+	UpdateIndexMutation(mutation *IndexMutation, fields map[string]interface{}) error // 'fields' are the struct fields names that have been modified
+	DeleteIndexMutation(userId, mutationId string) error
+	// channel is closed once all pending mutations have been sent, mutations of an user are sent in their order
+	RetrieveIndexMutations() (<-chan *IndexMutation, error)
+}
+
+// OutboxStore is the storage needed to apply index mutations : resources are read from store
+type OutboxStore interface {
+	IndexOutbox
+	RetrieveMessage(user_id, msg_id string) (msg *Message, err error)
+	RetrieveContact(user_id, contact_id string) (contact *Contact, err error)
+}
+
+// OutboxIndex is the index needed to apply index mutations
+type OutboxIndex interface {
+	CreateMessage(msg *Message) error
+	UpdateMessage(msg *Message, fields map[string]interface{}) error // 'fields' are the struct fields names that have been modified
+	DeleteMessage(msg *Message) error
+	CreateContact(contact *Contact) error
+	UpdateContact(contact *Contact, fields map[string]interface{}) error // 'fields' are the struct fields names that have been modified
+	DeleteContact(contact *Contact) error
+}
+
+// IndexerStore is the storage needed by the indexer worker, to apply outbox's mutations and to check store/index consistency
+type IndexerStore interface {
+	OutboxStore
+	Close()
+	RetrieveAllUsersIds() (<-chan string, error)
+	RetrieveAllMessages(userId string) (<-chan *Message, error)
+	RetrieveAllContacts(userId string) (<-chan *Contact, error)
+}
+
+type IndexerIndex interface {
+	OutboxIndex
+	Close()
+	IndexedIds(user_id, docType string) (ids []string, err error) // docType is MessageIndexType or ContactIndexType
+	// indexed documents with the given ids, by id. Ids not found in index are left out.
+	IndexedMessages(user_id string, ids []string) (messages map[string]*Message, err error)
+	IndexedContacts(user_id string, ids []string) (contacts map[string]*Contact, err error)
+}
@@ -0,0 +1,47 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package backends
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+)
+
+// PIStore is the storage needed to compute and save privacy indexes of received messages
+type PIStore interface {
+	OutboxStore
+	UpdateMessage(msg *Message, fields map[string]interface{}) error // 'fields' are the struct fields names that have been modified
+	LookupContactsByIdentifier(user_id, address string) (contact_ids []string, err error)
+	InteractionsStorage
+}
+
+// PIRescoreStore is the storage needed to score users' messages again when scoring rules change
+type PIRescoreStore interface {
+	PIStore
+	Close()
+	RetrieveAllUsersIds() (<-chan string, error)
+	RetrieveAllMessages(userId string) (<-chan *Message, error)
+	GetRawMessage(raw_message_id string) (raw_message RawMessage, err error)
+}
+
+type PIRescoreIndex interface {
+	OutboxIndex
+	Close()
+}
+
+// ImportanceStore is the storage needed to compute and save importance levels of received messages
+type ImportanceStore interface {
+	PIStore
+	GetLocalsIdentities(user_id string) (identities []LocalIdentity, err error)
+	RetrieveUserTags(user_id string) (tags []Tag, err error)
+	RaiseDiscussionImportanceLevel(user_id, discussion_id string, level int32) error
+}
+
+// ImportanceIndex is the index needed to compute and save importance levels of received messages
+type ImportanceIndex interface {
+	OutboxIndex
+	Search(search IndexSearch) (result *IndexResult, err error)
+}
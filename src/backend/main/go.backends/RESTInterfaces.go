@@ -18,6 +18,13 @@ type APIStorage interface {
 	UserNameStorage
 	UserStorage
 	DevicesStorage
+	SavedSearchesStorage
+	InteractionsStorage
+	ExportsStorage
+	ImportsStorage
+	UserDeletionStorage
+	IndexOutbox
+	RemoteContentStorage
 }
 
 type APIIndex interface {
@@ -35,4 +42,8 @@ type APICache interface {
 	GetResetPasswordSession(user_id string) (*Pass_reset_session, error)
 	SetResetPasswordSession(user_id, reset_token string) (*Pass_reset_session, error)
 	DeleteResetPasswordSession(user_id string) error
+	// account deletion
+	UserSessionsCache
+	// device revocation
+	DeleteDeviceSessions(user_id, device_id string) error
 }
@@ -0,0 +1,11 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package backends
+
+// RemoteContentStorage caches remote resources of messages fetched by proxy, by key
+type RemoteContentStorage interface {
+	GetRemoteContent(key string) (content []byte, err error)
+	PutRemoteContent(key string, content []byte) error
+}
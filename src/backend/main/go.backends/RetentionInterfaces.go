@@ -0,0 +1,32 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package backends
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"time"
+)
+
+// RetentionStore is the storage needed to enforce users' trash retention and tags' expiry policies.
+// Index is updated through store's index outbox.
+type RetentionStore interface {
+	OutboxStore
+	Close()
+	RetrieveAllUsersIds() (<-chan string, error)
+	GetSettings(user_id string) (settings *Settings, err error)
+	RetrieveUserTags(user_id string) (tags []Tag, err error)
+	UpdateMessage(msg *Message, fields map[string]interface{}) error // 'fields' are the struct fields names that have been modified
+	DeleteMessage(msg *Message) error
+	DeleteRawMessage(user_id, raw_msg_id string) error
+	DeleteAttachment(uri string) error
+	ReleaseMessageRefs(msg *Message) error // releases message's attachments and raw message, can be run again after a failure
+}
+
+type RetentionIndex interface {
+	OutboxIndex
+	Close()
+	TrashedMessagesBefore(user_id string, before time.Time, limit int) (messages []*Message, err error)
+	TaggedMessagesBefore(user_id, tag string, before time.Time, limit int) (messages []*Message, err error)
+}
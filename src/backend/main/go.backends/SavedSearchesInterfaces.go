@@ -0,0 +1,19 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package backends
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+)
+
+type SavedSearchesStorage interface {
+	CreateSavedSearch(search *SavedSearch) error
+	RetrieveSavedSearches(userId string) (searches []SavedSearch, err error)
+	RetrieveSavedSearch(userId, searchId string) (search *SavedSearch, err error)
+	UpdateSavedSearch(search *SavedSearch, modifiedFields map[string]interface{}) error
+	DeleteSavedSearch(userId, searchId string) error
+}
@@ -0,0 +1,45 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package cache
+
+import (
+	"gopkg.in/redis.v5"
+)
+
+const tokensPrefix = "tokens::"
+
+// DeleteUserSessions revokes all authentication tokens of user,
+// keys are in the form of "tokens::user_id" or "tokens::user_id-device_id".
+// Pending password reset session is deleted too.
+func (cache *RedisBackend) DeleteUserSessions(user_id string) error {
+	var cursor uint64
+	for {
+		keys, next, err := cache.client.Scan(cursor, tokensPrefix+user_id+"*", 100).Result()
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			if _, err = cache.client.Del(keys...).Result(); err != nil {
+				return err
+			}
+		}
+		cursor = next
+		if cursor == 0 {
+			break
+		}
+	}
+	err := cache.DeleteResetPasswordSession(user_id)
+	if err != nil && err != redis.Nil {
+		return err
+	}
+	return nil
+}
+
+// DeleteDeviceSessions revokes authentication tokens of one of user's devices,
+// stored under "tokens::user_id-device_id" key.
+func (cache *RedisBackend) DeleteDeviceSessions(user_id, device_id string) error {
+	_, err := cache.client.Del(tokensPrefix + user_id + "-" + device_id).Result()
+	return err
+}
@@ -9,6 +9,10 @@ import (
 	"gopkg.in/olivere/elastic.v5"
 )
 
+// name of inner hits that hold attachments matching the search.
+// It's also the key within message hit's highlights for matching attachments' names.
+const attachments_inner_hits = "attachments"
+
 // Composes a full text ES query from IndexSearch object,
 // making use of "common terms query" (see https://www.elastic.co/guide/en/elasticsearch/reference/5.4/query-dsl-common-terms-query.html).
 // The func returns a compound response from ES to return 5 relevant docs filed by type if no doctype is provided,
@@ -33,7 +37,23 @@ func (es *ElasticSearchBackend) Search(search IndexSearch) (result *IndexResult,
 		q = q.Should(elastic.NewCommonTermsQuery("given_name.normalized", value).CutoffFrequency(0.01))
 		q = q.Should(elastic.NewCommonTermsQuery("family_name", value).CutoffFrequency(0.01))
 		q = q.Should(elastic.NewCommonTermsQuery("family_name.normalized", value).CutoffFrequency(0.01))
+		// attachments' content is nested within messages, inner hits give back the names of matching attachments
+		attachments := elastic.NewNestedQuery("attachments", elastic.NewCommonTermsQuery("attachments.content", value).CutoffFrequency(0.01)).
+			InnerHit(elastic.NewInnerHit().Name(attachments_inner_hits).FetchSourceContext(elastic.NewFetchSourceContext(true).Include("attachments.file_name")))
+		q = q.Should(attachments)
+	}
+	// filters restrict the search to documents that hold exact values, at least one of the terms above must still match
+	for name, values := range search.Filters {
+		for _, value := range values {
+			q = q.Filter(elastic.NewTermQuery(name, value))
+		}
+	}
+	q = q.Filter(search.TrashQuery())
+	if len(search.Terms) > 0 {
+		q = q.MinimumNumberShouldMatch(1)
 	}
+	// attachments' content is only indexed for search purpose, it's useless to send it back
+	source := elastic.NewFetchSourceContext(true).Exclude("attachments.content")
 
 	// make aggregation to file docs by type:
 	// get only the 5 most relevant doc for each type if search.DocType is empty
@@ -43,7 +63,7 @@ func (es *ElasticSearchBackend) Search(search IndexSearch) (result *IndexResult,
 	case "":
 		// no doctype provided. Trigger search on all document types within index and build an aggregation
 		h := elastic.NewHighlight().Fields(elastic.NewHighlighterField("*").RequireFieldMatch(false))
-		top_hits := elastic.NewTopHitsAggregation().Size(5).FetchSource(true).Highlight(h)
+		top_hits := elastic.NewTopHitsAggregation().Size(5).FetchSourceContext(source).Highlight(h)
 		by_type := elastic.NewTermsAggregation().Field("_type").SubAggregation(sub_agg_key, top_hits)
 		//TODO/WIP
 		/*iq := elastic.NewIndicesQuery(elastic.NewRangeQuery("importance_level").Gte(search.ILrange[0]).Lte(search.ILrange[1]), MessageIndexType)
@@ -54,13 +74,18 @@ func (es *ElasticSearchBackend) Search(search IndexSearch) (result *IndexResult,
 		// The search focuses on message document type, no aggregation needed, but importance level apply
 		h := elastic.NewHighlight().Fields(elastic.NewHighlighterField("*").RequireFieldMatch(false))
 		rq := elastic.NewRangeQuery("importance_level").Gte(search.ILrange[0]).Lte(search.ILrange[1])
-		s = es.Client.Search().Index(search.User_id.String()).Query(q).FetchSource(true).Highlight(h).PostFilter(rq)
+		s = es.Client.Search().Index(search.User_id.String()).Query(q).FetchSourceContext(source).Highlight(h).PostFilter(rq)
 	case ContactIndexType:
 		// The search focuses on contact document type, no aggregation needed and importance level not taken into account
 		h := elastic.NewHighlight().Fields(elastic.NewHighlighterField("*").RequireFieldMatch(false))
 		s = es.Client.Search().Index(search.User_id.String()).Query(q).FetchSource(true).Highlight(h)
 	}
 
+	// facets are computed on messages only
+	if len(search.Facets) > 0 && search.DocType != ContactIndexType {
+		s = s.Aggregation(facets_agg_key, facetsAggregation(search))
+	}
+
 	//prepare search
 	// add type, from & size params only if type is not empty
 	if search.DocType != "" {
@@ -89,6 +114,10 @@ func (es *ElasticSearchBackend) Search(search IndexSearch) (result *IndexResult,
 		Total:        response.TotalHits(),
 		MessagesHits: MessageHits{0, []*IndexHit{}},
 		ContactsHits: ContactHits{0, []*IndexHit{}},
+		Facets:       map[string][]FacetBucket{},
+	}
+	if len(search.Facets) > 0 {
+		result.Facets = parseFacets(response.Aggregations, search)
 	}
 	if search.DocType != "" {
 		// no aggregation, thus elastic returns a parsed json
@@ -108,6 +137,12 @@ func (es *ElasticSearchBackend) Search(search IndexSearch) (result *IndexResult,
 				msg_hit.Id = msg.Message_id
 				msg_hit.Score = *hit.Score
 				msg_hit.Highlights = hit.Highlight
+				if names := matchingAttachments(hit.InnerHits); len(names) > 0 {
+					if msg_hit.Highlights == nil {
+						msg_hit.Highlights = map[string][]string{}
+					}
+					msg_hit.Highlights[attachments_inner_hits] = names
+				}
 				msg_hit.Document = msg
 				(*result).MessagesHits.Messages = append((*result).MessagesHits.Messages, msg_hit)
 			}
@@ -169,6 +204,9 @@ func (es *ElasticSearchBackend) Search(search IndexSearch) (result *IndexResult,
 						h.Highlights[key] = append(h.Highlights[key], highlight.(string))
 					}
 				}
+				if names := matchingAttachments(rawInnerHits(hit)); len(names) > 0 {
+					h.Highlights[attachments_inner_hits] = names
+				}
 				switch bucket["key"].(string) {
 				case MessageIndexType:
 					msg_map, _ := hit["_source"].(map[string]interface{})
@@ -195,3 +233,48 @@ func (es *ElasticSearchBackend) Search(search IndexSearch) (result *IndexResult,
 
 	return
 }
+
+// rawInnerHits decodes inner hits of a hit given as a raw json map, as within top hits aggregations
+func rawInnerHits(hit map[string]interface{}) (inner map[string]*elastic.SearchHitInnerHits) {
+	raw, ok := hit["inner_hits"]
+	if !ok {
+		return
+	}
+	b, err := json.Marshal(raw)
+	if err != nil {
+		return
+	}
+	json.Unmarshal(b, &inner)
+	return
+}
+
+// matchingAttachments returns file names of attachments found within inner hits of a message hit
+func matchingAttachments(inner map[string]*elastic.SearchHitInnerHits) (names []string) {
+	attachments, ok := inner[attachments_inner_hits]
+	if !ok || attachments == nil || attachments.Hits == nil {
+		return
+	}
+	for _, hit := range attachments.Hits.Hits {
+		if hit.Source == nil {
+			continue
+		}
+		// depending on ES version, nested source is given relatively to root document or to nested object
+		var source struct {
+			FileName    string `json:"file_name"`
+			Attachments struct {
+				FileName string `json:"file_name"`
+			} `json:"attachments"`
+		}
+		if err := json.Unmarshal(*hit.Source, &source); err != nil {
+			continue
+		}
+		name := source.FileName
+		if name == "" {
+			name = source.Attachments.FileName
+		}
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return
+}
@@ -0,0 +1,27 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package index
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestMatchingAttachmentsOfRawHit(t *testing.T) {
+	// hit of a top hits aggregation, as returned when search has no doctype
+	var hit map[string]interface{}
+	err := json.Unmarshal([]byte(`{"_id":"x","inner_hits":{"attachments":{"hits":{"total":1,"hits":[
+		{"_nested":{"field":"attachments","offset":0},"_source":{"file_name":"report.pdf"}}]}}}}`), &hit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := matchingAttachments(rawInnerHits(hit))
+	if len(names) != 1 || names[0] != "report.pdf" {
+		t.Errorf("unexpected matching attachments %v", names)
+	}
+	if names := matchingAttachments(rawInnerHits(map[string]interface{}{"_id": "y"})); len(names) != 0 {
+		t.Errorf("unexpected matching attachments %v", names)
+	}
+}
@@ -0,0 +1,111 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package index
+
+import (
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"gopkg.in/olivere/elastic.v5"
+)
+
+const (
+	facets_agg_key = "facets"
+	values_agg_key = "values"
+)
+
+// facetsAggregation builds an aggregation for each facet requested within search.
+// Facets only apply to messages, thus all sub-aggregations are embedded into a filter on message type.
+// Importance level range is taken into account only if search is narrowed to messages.
+func facetsAggregation(search IndexSearch) *elastic.FilterAggregation {
+	filter := elastic.NewBoolQuery().Filter(elastic.NewTermQuery("_type", MessageIndexType))
+	if search.DocType == MessageIndexType {
+		filter = filter.Filter(elastic.NewRangeQuery("importance_level").Gte(search.ILrange[0]).Lte(search.ILrange[1]))
+	}
+	agg := elastic.NewFilterAggregation().Filter(filter)
+
+	for _, facet := range search.Facets {
+		facet.MarshallNew()
+		switch facet.Name {
+		case FacetTags:
+			agg = agg.SubAggregation(facet.Name, elastic.NewTermsAggregation().Field("tags").Size(facet.Size))
+		case FacetParticipants:
+			terms := elastic.NewTermsAggregation().Field("participants.address.raw").Size(facet.Size)
+			agg = agg.SubAggregation(facet.Name, elastic.NewNestedAggregation().Path("participants").SubAggregation(values_agg_key, terms))
+		case FacetDates:
+			histogram := elastic.NewDateHistogramAggregation().Field("date_sort").Interval(facet.Interval).Format("yyyy-MM-dd").MinDocCount(1)
+			agg = agg.SubAggregation(facet.Name, histogram)
+		case FacetHasAttachments:
+			with := elastic.NewNestedQuery("attachments", elastic.NewExistsQuery("attachments.content_type"))
+			filters := elastic.NewFiltersAggregation().
+				FilterWithName("true", with).
+				FilterWithName("false", elastic.NewBoolQuery().MustNot(with))
+			agg = agg.SubAggregation(facet.Name, filters)
+		case FacetAttachmentTypes:
+			terms := elastic.NewTermsAggregation().Field("attachments.content_type").Size(facet.Size)
+			agg = agg.SubAggregation(facet.Name, elastic.NewNestedAggregation().Path("attachments").SubAggregation(values_agg_key, terms))
+		}
+	}
+	return agg
+}
+
+// parseFacets extracts buckets from ES response's aggregations built by facetsAggregation
+func parseFacets(aggs elastic.Aggregations, search IndexSearch) map[string][]FacetBucket {
+	facets := map[string][]FacetBucket{}
+	root, found := aggs.Filter(facets_agg_key)
+	if !found {
+		return facets
+	}
+	for _, facet := range search.Facets {
+		buckets := []FacetBucket{}
+		switch facet.Name {
+		case FacetTags:
+			if terms, ok := root.Terms(facet.Name); ok {
+				buckets = termsBuckets(terms)
+			}
+		case FacetParticipants, FacetAttachmentTypes:
+			if nested, ok := root.Nested(facet.Name); ok {
+				if terms, ok := nested.Terms(values_agg_key); ok {
+					buckets = termsBuckets(terms)
+				}
+			}
+		case FacetDates:
+			if histogram, ok := root.DateHistogram(facet.Name); ok {
+				for _, b := range histogram.Buckets {
+					key := fmt.Sprintf("%.0f", b.Key)
+					if b.KeyAsString != nil {
+						key = *b.KeyAsString
+					}
+					buckets = append(buckets, FacetBucket{Key: key, Count: b.DocCount})
+				}
+			}
+		case FacetHasAttachments:
+			if filters, ok := root.Filters(facet.Name); ok {
+				for _, key := range []string{"true", "false"} {
+					if b, ok := filters.NamedBuckets[key]; ok {
+						buckets = append(buckets, FacetBucket{Key: key, Count: b.DocCount})
+					}
+				}
+			}
+		default:
+			continue
+		}
+		facets[facet.Name] = buckets
+	}
+	return facets
+}
+
+func termsBuckets(terms *elastic.AggregationBucketKeyItems) (buckets []FacetBucket) {
+	buckets = []FacetBucket{}
+	for _, b := range terms.Buckets {
+		var key string
+		if b.KeyAsString != nil {
+			key = *b.KeyAsString
+		} else {
+			key = fmt.Sprint(b.Key)
+		}
+		buckets = append(buckets, FacetBucket{Key: key, Count: b.DocCount})
+	}
+	return
+}
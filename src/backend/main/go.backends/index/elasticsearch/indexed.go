@@ -0,0 +1,116 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package index
+
+import (
+	"context"
+	"encoding/json"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	log "github.com/Sirupsen/logrus"
+	"github.com/satori/go.uuid"
+	"gopkg.in/olivere/elastic.v5"
+	"io"
+)
+
+// how many documents are fetched at once when walking through an user's index
+const indexedBatchSize = 500
+
+// IndexedIds returns the ids of all documents of docType within user's index.
+// It is not an error if user has no index.
+func (es *ElasticSearchBackend) IndexedIds(user_id, docType string) (ids []string, err error) {
+	scroll := es.Client.Scroll(user_id).Type(docType).
+		FetchSource(false).
+		Size(indexedBatchSize)
+	defer scroll.Clear(context.TODO())
+	for {
+		result, err := scroll.Do(context.TODO())
+		if err == io.EOF {
+			return ids, nil
+		}
+		if err != nil {
+			if elastic.IsNotFound(err) {
+				return ids, nil
+			}
+			return nil, err
+		}
+		for _, hit := range result.Hits.Hits {
+			ids = append(ids, hit.Id)
+		}
+	}
+}
+
+// IndexedMessages returns the indexed messages with the given ids, by id
+func (es *ElasticSearchBackend) IndexedMessages(user_id string, ids []string) (messages map[string]*Message, err error) {
+	messages = map[string]*Message{}
+	docs, err := es.indexedDocs(user_id, MessageIndexType, ids)
+	if err != nil {
+		return nil, err
+	}
+	var userUUID UUID
+	if id, err := uuid.FromString(user_id); err == nil {
+		userUUID.UnmarshalBinary(id.Bytes())
+	}
+	for id, source := range docs {
+		msg := new(Message).NewEmpty().(*Message)
+		if err := json.Unmarshal(*source, msg); err != nil {
+			log.WithError(err).Warnf("[ElasticSearchBackend] failed to unmarshal message %s", id)
+			continue
+		}
+		msg_id, _ := uuid.FromString(id)
+		msg.Message_id.UnmarshalBinary(msg_id.Bytes())
+		msg.User_id = userUUID
+		messages[id] = msg
+	}
+	return
+}
+
+// IndexedContacts returns the indexed contacts with the given ids, by id
+func (es *ElasticSearchBackend) IndexedContacts(user_id string, ids []string) (contacts map[string]*Contact, err error) {
+	contacts = map[string]*Contact{}
+	docs, err := es.indexedDocs(user_id, ContactIndexType, ids)
+	if err != nil {
+		return nil, err
+	}
+	var userUUID UUID
+	if id, err := uuid.FromString(user_id); err == nil {
+		userUUID.UnmarshalBinary(id.Bytes())
+	}
+	for id, source := range docs {
+		contact := new(Contact).NewEmpty().(*Contact)
+		if err := json.Unmarshal(*source, contact); err != nil {
+			log.WithError(err).Warnf("[ElasticSearchBackend] failed to unmarshal contact %s", id)
+			continue
+		}
+		contact_id, _ := uuid.FromString(id)
+		contact.ContactId.UnmarshalBinary(contact_id.Bytes())
+		contact.UserId = userUUID
+		contacts[id] = contact
+	}
+	return
+}
+
+// indexedDocs returns the sources of the documents found among ids, by id
+func (es *ElasticSearchBackend) indexedDocs(user_id, docType string, ids []string) (docs map[string]*json.RawMessage, err error) {
+	docs = map[string]*json.RawMessage{}
+	if len(ids) == 0 {
+		return
+	}
+	mget := es.Client.MultiGet()
+	for _, id := range ids {
+		mget.Add(elastic.NewMultiGetItem().Index(user_id).Type(docType).Id(id))
+	}
+	result, err := mget.Do(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+	for _, doc := range result.Docs {
+		if doc.Found && doc.Source != nil {
+			docs[doc.Id] = doc.Source
+		}
+	}
+	return
+}
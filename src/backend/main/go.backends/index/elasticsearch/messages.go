@@ -5,6 +5,7 @@
 package index
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -12,6 +13,7 @@ import (
 	log "github.com/Sirupsen/logrus"
 	"github.com/satori/go.uuid"
 	"gopkg.in/oleiade/reflections.v1"
+	"gopkg.in/olivere/elastic.v5"
 	"strings"
 )
 
@@ -21,6 +23,25 @@ func (es *ElasticSearchBackend) CreateMessage(msg *objects.Message) error {
 	if err != nil {
 		return err
 	}
+	if len(msg.Attachments) > 0 {
+		// message may be indexed again from store, see keepAttachmentsText
+		document := map[string]interface{}{}
+		decoder := json.NewDecoder(bytes.NewReader(es_msg))
+		decoder.UseNumber()
+		if err = decoder.Decode(&document); err != nil {
+			return err
+		}
+		attachments, kept, err := es.keepAttachmentsText(msg.User_id.String(), msg.Message_id.String(), document["attachments"])
+		if err != nil {
+			return err
+		}
+		if kept {
+			document["attachments"] = attachments
+			if es_msg, err = json.Marshal(document); err != nil {
+				return err
+			}
+		}
+	}
 
 	resp, err := es.Client.Index().Index(msg.User_id.String()).Type(objects.MessageIndexType).Id(msg.Message_id.String()).
 		BodyString(string(es_msg)).
@@ -47,6 +68,15 @@ func (es *ElasticSearchBackend) UpdateMessage(msg *objects.Message, fields map[s
 		split := strings.Split(jsonField, ",")
 		jsonFields[split[0]] = value
 	}
+	if attachments, ok := jsonFields["attachments"]; ok {
+		kept, changed, err := es.keepAttachmentsText(msg.User_id.String(), msg.Message_id.String(), attachments)
+		if err != nil {
+			return err
+		}
+		if changed {
+			jsonFields["attachments"] = kept
+		}
+	}
 
 	update, err := es.Client.Update().Index(msg.User_id.String()).Type(objects.MessageIndexType).Id(msg.Message_id.String()).
 		Doc(jsonFields).
@@ -60,6 +90,127 @@ func (es *ElasticSearchBackend) UpdateMessage(msg *objects.Message, fields map[s
 	return nil
 }
 
+func (es *ElasticSearchBackend) DeleteMessage(msg *objects.Message) error {
+	_, err := es.Client.Delete().Index(msg.User_id.String()).Type(objects.MessageIndexType).Id(msg.Message_id.String()).
+		Refresh("wait_for").
+		Do(context.TODO())
+	if err != nil {
+		log.WithError(err).Warn("backend Index: DeleteMessage operation failed")
+		return err
+	}
+	return nil
+}
+
+// IndexAttachmentsText adds the text extracted from attachments to the indexed message.
+// Text is put into a `content` property of each nested attachment that has the same file name,
+// thus the message must have been indexed before with its attachments.
+func (es *ElasticSearchBackend) IndexAttachmentsText(user_id, message_id string, texts []objects.AttachmentText) error {
+	if len(texts) == 0 {
+		return nil
+	}
+	attachments, found, err := es.indexedAttachments(user_id, message_id)
+	if err != nil {
+		return fmt.Errorf("[ElasticSearchBackend] IndexAttachmentsText failed to get message %s : %s", message_id, err)
+	}
+	if !found {
+		return fmt.Errorf("[ElasticSearchBackend] IndexAttachmentsText : message %s not found", message_id)
+	}
+
+	contents := map[string][]string{}
+	for _, text := range texts {
+		contents[text.FileName] = append(contents[text.FileName], text.Text)
+	}
+	if !setAttachmentsContent(attachments, contents) {
+		log.Warnf("[ElasticSearchBackend] IndexAttachmentsText : no attachment of message %s matches extracted texts", message_id)
+		return nil
+	}
+
+	_, err = es.Client.Update().Index(user_id).Type(objects.MessageIndexType).Id(message_id).
+		Doc(map[string]interface{}{"attachments": attachments}).
+		Refresh("wait_for").
+		Do(context.TODO())
+	if err != nil {
+		log.WithError(err).Warn("backend Index: IndexAttachmentsText operation failed")
+		return err
+	}
+	return nil
+}
+
+// keepAttachmentsText puts the text already indexed for message's attachments into attachments,
+// the new value of message's attachments property. Text is only in index (see IndexAttachmentsText),
+// thus it must be kept when message is indexed again from store (outbox mutations, consistency repairs).
+// It returns false if there was no text to keep.
+func (es *ElasticSearchBackend) keepAttachmentsText(user_id, message_id string, attachments interface{}) (kept []map[string]interface{}, ok bool, err error) {
+	indexed, found, err := es.indexedAttachments(user_id, message_id)
+	if err != nil || !found {
+		return nil, false, err
+	}
+	contents := attachmentsContent(indexed)
+	if len(contents) == 0 {
+		return nil, false, nil
+	}
+	// attachments as they are sent to index
+	j, err := json.Marshal(attachments)
+	if err != nil {
+		return nil, false, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(j))
+	decoder.UseNumber()
+	if err = decoder.Decode(&kept); err != nil {
+		return nil, false, err
+	}
+	return kept, setAttachmentsContent(kept, contents), nil
+}
+
+// indexedAttachments returns the attachments property of indexed message, found is false if message is not indexed.
+func (es *ElasticSearchBackend) indexedAttachments(user_id, message_id string) (attachments []map[string]interface{}, found bool, err error) {
+	doc, err := es.Client.Get().Index(user_id).Type(objects.MessageIndexType).Id(message_id).
+		FetchSourceContext(elastic.NewFetchSourceContext(true).Include("attachments")).
+		Do(context.TODO())
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	if !doc.Found || doc.Source == nil {
+		return nil, false, nil
+	}
+	source := struct {
+		Attachments []map[string]interface{} `json:"attachments"`
+	}{}
+	if err = json.Unmarshal(*doc.Source, &source); err != nil {
+		return nil, false, err
+	}
+	return source.Attachments, true, nil
+}
+
+// attachmentsContent returns the texts of indexed attachments, by file name, in attachments' order.
+func attachmentsContent(attachments []map[string]interface{}) map[string][]string {
+	contents := map[string][]string{}
+	for _, attachment := range attachments {
+		if content, ok := attachment["content"].(string); ok && content != "" {
+			name, _ := attachment["file_name"].(string)
+			contents[name] = append(contents[name], content)
+		}
+	}
+	return contents
+}
+
+// setAttachmentsContent puts each text into the `content` of the next attachment with the same file name.
+// It returns false if no attachment matches.
+func setAttachmentsContent(attachments []map[string]interface{}, contents map[string][]string) (found bool) {
+	for _, attachment := range attachments {
+		name, _ := attachment["file_name"].(string)
+		if texts := contents[name]; len(texts) > 0 {
+			attachment["content"] = texts[0]
+			contents[name] = texts[1:]
+			found = true
+		}
+	}
+	return
+}
+
 func (es *ElasticSearchBackend) SetMessageUnread(user_id, message_id string, status bool) (err error) {
 	payload := struct {
 		Is_unread bool `json:"is_unread"`
@@ -71,6 +222,23 @@ func (es *ElasticSearchBackend) SetMessageUnread(user_id, message_id string, sta
 	return
 }
 
+// RefreshMessages makes the messages recently written into user's index visible to searches
+func (es *ElasticSearchBackend) RefreshMessages(user_id string) error {
+	_, err := es.Client.Refresh(user_id).Do(context.TODO())
+	return err
+}
+
+// MessageExistsByExternalId returns true if user already has a message with the given Message-ID
+func (es *ElasticSearchBackend) MessageExistsByExternalId(user_id, external_msg_id string) (bool, error) {
+	q := elastic.NewNestedQuery("external_references",
+		elastic.NewTermQuery("external_references.message_id", external_msg_id))
+	count, err := es.Client.Count().Index(user_id).Type(objects.MessageIndexType).Query(q).Do(context.TODO())
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
 func (es *ElasticSearchBackend) FilterMessages(filter objects.IndexSearch) (messages []*objects.Message, totalFound int64, err error) {
 
 	search := es.Client.Search().Index(filter.User_id.String()).Type(objects.MessageIndexType)
@@ -33,3 +33,29 @@ func TestElasticSearchBackend_FilterMessages(t *testing.T) {
 	t.Logf("%+v", result[0])
 	t.Logf("total : %d", total_found)
 }
+
+func TestKeepAttachmentsContent(t *testing.T) {
+	indexed := []map[string]interface{}{
+		{"file_name": "report.pdf", "content": "quarterly figures"},
+		{"file_name": "photo.jpg"},
+		{"file_name": "notes.txt", "content": "first notes"},
+		{"file_name": "notes.txt", "content": "second notes"},
+	}
+	// message indexed again from store, without any content and with an attachment removed
+	attachments := []map[string]interface{}{
+		{"file_name": "notes.txt", "size": 12},
+		{"file_name": "report.pdf", "size": 1024},
+		{"file_name": "notes.txt", "size": 13},
+	}
+	if !setAttachmentsContent(attachments, attachmentsContent(indexed)) {
+		t.Fatal("expected indexed contents to match attachments")
+	}
+	for i, expected := range []string{"first notes", "quarterly figures", "second notes"} {
+		if attachments[i]["content"] != expected {
+			t.Errorf("attachment %d : expected content %q, got %v", i, expected, attachments[i]["content"])
+		}
+	}
+	if setAttachmentsContent([]map[string]interface{}{{"file_name": "other.pdf"}}, attachmentsContent(indexed)) {
+		t.Error("expected no attachment to match")
+	}
+}
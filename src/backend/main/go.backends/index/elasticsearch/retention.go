@@ -0,0 +1,59 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package index
+
+import (
+	"context"
+	"encoding/json"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	log "github.com/Sirupsen/logrus"
+	"github.com/satori/go.uuid"
+	"gopkg.in/olivere/elastic.v5"
+	"time"
+)
+
+// TrashedMessagesBefore returns up to `limit` messages that have been moved to trash before the given date.
+func (es *ElasticSearchBackend) TrashedMessagesBefore(user_id string, before time.Time, limit int) (messages []*Message, err error) {
+	q := elastic.NewBoolQuery().Filter(elastic.NewRangeQuery("date_delete").Lt(before.Format(RFC3339Milli)))
+	return es.retentionSearch(user_id, q, "date_delete", limit)
+}
+
+// TaggedMessagesBefore returns up to `limit` messages not yet in trash, that hold `tag` and that have been received before the given date.
+func (es *ElasticSearchBackend) TaggedMessagesBefore(user_id, tag string, before time.Time, limit int) (messages []*Message, err error) {
+	q := elastic.NewBoolQuery().
+		Filter(elastic.NewTermQuery("tags", tag)).
+		Filter(elastic.NewRangeQuery("date_insert").Lt(before.Format(RFC3339Milli))).
+		MustNot(elastic.NewExistsQuery("date_delete"))
+	return es.retentionSearch(user_id, q, "date_insert", limit)
+}
+
+func (es *ElasticSearchBackend) retentionSearch(user_id string, q elastic.Query, sortField string, limit int) (messages []*Message, err error) {
+	result, err := es.Client.Search().Index(user_id).Type(MessageIndexType).
+		Query(q).
+		Sort(sortField, true).
+		Size(limit).
+		Do(context.TODO())
+	if err != nil {
+		return nil, err
+	}
+
+	// user_id is not indexed, messages are found within user's index
+	var userUUID UUID
+	if id, err := uuid.FromString(user_id); err == nil {
+		userUUID.UnmarshalBinary(id.Bytes())
+	}
+	for _, hit := range result.Hits.Hits {
+		msg := new(Message).NewEmpty().(*Message)
+		if err := json.Unmarshal(*hit.Source, msg); err != nil {
+			log.WithError(err).Warnf("[ElasticSearchBackend] failed to unmarshal message %s", hit.Id)
+			continue
+		}
+		msg_id, _ := uuid.FromString(hit.Id)
+		msg.Message_id.UnmarshalBinary(msg_id.Bytes())
+		msg.User_id = userUUID
+		messages = append(messages, msg)
+	}
+	return
+}
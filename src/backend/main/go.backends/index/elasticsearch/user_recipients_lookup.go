@@ -15,9 +15,14 @@ import (
 
 type (
 	returnedContact struct {
-		Fname string `json:"family_name"`
-		Gname string `json:"given_name"`
-		Title string `json:"title"`
+		Fname string        `json:"family_name"`
+		Gname string        `json:"given_name"`
+		PI    *PrivacyIndex `json:"pi"`
+		Title string        `json:"title"`
+	}
+
+	returnedEmail struct {
+		Address string `json:"address"`
 	}
 
 	returnedParticipant struct {
@@ -33,6 +38,10 @@ type (
 	}
 )
 
+// how many hits are fetched from index for a suggestion request.
+// Candidates are then ranked according to user's interactions by caller.
+const suggest_candidates = 100
+
 // build ES queries and responses for finding relevant recipients when an user compose a message
 func (es *ElasticSearchBackend) RecipientsSuggest(user_id, query_string string) (suggests []RecipientSuggestion, err error) {
 	suggests = []RecipientSuggestion{}
@@ -67,14 +76,14 @@ func (es *ElasticSearchBackend) RecipientsSuggest(user_id, query_string string)
 
 	// doc source pruning
 	fsc := elastic.NewFetchSourceContext(true)
-	fsc.Include("title")
+	fsc.Include("title", "pi")
 
 	// run the query
 	main_query := elastic.NewBoolQuery().Should(participants_q, contact_name_q, emails_q)
 	search := es.Client.Search().
 		Index(user_id).
 		FetchSourceContext(fsc).
-		Size(30)
+		Size(suggest_candidates)
 	/** log the full json query to help development
 	source, _ := main_query.Source()
 	json_query, _ := json.Marshal(source)
@@ -127,6 +136,15 @@ func extractContactInfos(contact_hit *elastic.SearchHit) (suggest RecipientSugge
 	suggest.Source = "contact"
 	suggest.Label = contact.Title
 	suggest.Contact_Id = contact_hit.Id
+	suggest.PI = contact.PI
+	// take the contact's email that matched the query, if any
+	if emails, ok := contact_hit.InnerHits["emails"]; ok && emails.Hits != nil && len(emails.Hits.Hits) > 0 {
+		var email returnedEmail
+		if e := json.Unmarshal(*emails.Hits.Hits[0].Source, &email); e == nil {
+			suggest.Address = email.Address
+			suggest.Protocol = EmailProtocol
+		}
+	}
 	return
 }
 
@@ -141,5 +159,8 @@ func extractParticipantInfos(message_hit *elastic.SearchHit) (suggest RecipientS
 	suggest.Label = participant.Label
 	suggest.Address = participant.Address
 	suggest.Protocol = participant.Protocol
+	if len(participant.Contact_ids) > 0 {
+		suggest.Contact_Id = participant.Contact_ids[0]
+	}
 	return
 }
@@ -0,0 +1,37 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package index
+
+import (
+	"context"
+	"gopkg.in/olivere/elastic.v5"
+)
+
+// DeleteUserIndex deletes user's index with all its documents.
+// user_id is an alias to the actual index, which is named after mappings' version.
+// It is not an error if user has no index (anymore).
+func (es *ElasticSearchBackend) DeleteUserIndex(user_id string) error {
+	indices, err := es.Client.IndexGet(user_id).Do(context.TODO())
+	if err != nil {
+		if elastic.IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	names := []string{}
+	for name := range indices {
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil
+	}
+	_, err = es.Client.DeleteIndex(names...).Do(context.TODO())
+	if err != nil && !elastic.IsNotFound(err) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package memory
+
+import (
+	"errors"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/nats-io/go-nats"
+	"sync"
+	"time"
+)
+
+// pending messages of a subscription, beyond which messages are dropped as NATS does for slow consumers
+const busPendingLimit = 1024
+
+var ErrNoSubscriber = errors.New("[MemoryBus] no subscriber for subject")
+
+// Bus implements MessageBus within process memory, in place of a NATS connection.
+// Subscribers of a queue group receive messages in turn, other subscribers receive all messages.
+// Each subscription handles its messages in order, in its own goroutine.
+//
+// Subscriptions returned by QueueSubscribe are not bound to a NATS connection,
+// thus they can't be unsubscribed : they last as long as the process.
+type Bus struct {
+	mu          sync.Mutex
+	subscribers map[string][]*busSubscriber // by subject
+	turns       map[string]int              // next subscriber of queue groups, by subject and queue
+	replies     map[string]chan *nats.Msg   // by inbox subject of pending requests
+	inboxes     uint64
+}
+
+type busSubscriber struct {
+	sub     *nats.Subscription
+	pending chan *nats.Msg
+}
+
+// InitializeMemoryBus returns the process-wide memory bus, see InitializeMemoryBackend
+func InitializeMemoryBus() (*Bus, error) {
+	sharedOnce.Do(initShared)
+	return sharedBus, nil
+}
+
+func NewMemoryBus() *Bus {
+	return &Bus{
+		subscribers: map[string][]*busSubscriber{},
+		turns:       map[string]int{},
+		replies:     map[string]chan *nats.Msg{},
+	}
+}
+
+func (bus *Bus) Publish(subject string, data []byte) error {
+	bus.publish(subject, "", data)
+	return nil
+}
+
+// Request publishes data with an inbox subject to reply to, then waits for the first reply.
+// Unlike NATS, it fails at once if nobody subscribed to subject.
+func (bus *Bus) Request(subject string, data []byte, timeout time.Duration) (*nats.Msg, error) {
+	reply := make(chan *nats.Msg, 1)
+	bus.mu.Lock()
+	bus.inboxes++
+	inbox := fmt.Sprintf("_INBOX.%d", bus.inboxes)
+	bus.replies[inbox] = reply
+	bus.mu.Unlock()
+	defer func() {
+		bus.mu.Lock()
+		delete(bus.replies, inbox)
+		bus.mu.Unlock()
+	}()
+
+	if bus.publish(subject, inbox, data) == 0 {
+		return nil, ErrNoSubscriber
+	}
+	select {
+	case msg := <-reply:
+		return msg, nil
+	case <-time.After(timeout):
+		return nil, nats.ErrTimeout
+	}
+}
+
+func (bus *Bus) QueueSubscribe(subject, queue string, handler nats.MsgHandler) (*nats.Subscription, error) {
+	s := &busSubscriber{
+		sub:     &nats.Subscription{Subject: subject, Queue: queue},
+		pending: make(chan *nats.Msg, busPendingLimit),
+	}
+	go func() {
+		for msg := range s.pending {
+			handler(msg)
+		}
+	}()
+	bus.mu.Lock()
+	bus.subscribers[subject] = append(bus.subscribers[subject], s)
+	bus.mu.Unlock()
+	return s.sub, nil
+}
+
+// publish delivers data to subject's subscribers, or to the pending request waiting on subject.
+// It returns the count of subscribers that received data.
+func (bus *Bus) publish(subject, reply string, data []byte) (delivered int) {
+	bus.mu.Lock()
+	if waiting, ok := bus.replies[subject]; ok {
+		delete(bus.replies, subject)
+		bus.mu.Unlock()
+		waiting <- &nats.Msg{Subject: subject, Data: append([]byte{}, data...)}
+		return 1
+	}
+	receivers := []*busSubscriber{}
+	groups := map[string][]*busSubscriber{}
+	for _, s := range bus.subscribers[subject] {
+		if s.sub.Queue == "" {
+			receivers = append(receivers, s)
+		} else {
+			groups[s.sub.Queue] = append(groups[s.sub.Queue], s)
+		}
+	}
+	for queue, members := range groups {
+		key := subject + "\n" + queue
+		receivers = append(receivers, members[bus.turns[key]%len(members)])
+		bus.turns[key]++
+	}
+	bus.mu.Unlock()
+
+	for _, s := range receivers {
+		msg := &nats.Msg{Subject: subject, Reply: reply, Data: append([]byte{}, data...), Sub: s.sub}
+		select {
+		case s.pending <- msg:
+			delivered++
+		default:
+			log.Warnf("[MemoryBus] subscriber of %s is too slow, message dropped", subject)
+		}
+	}
+	return
+}
+
+// Flush returns at once, messages are handed to subscribers when they are published
+func (bus *Bus) Flush() error {
+	return nil
+}
+
+func (bus *Bus) LastError() error {
+	return nil
+}
+
+// Close does nothing, bus is shared by all facilities of the process
+func (bus *Bus) Close() {}
@@ -0,0 +1,154 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package memory
+
+import (
+	"errors"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"strings"
+	"sync"
+	"time"
+)
+
+// keys' prefixes and ttl are the same as in Redis cache
+const (
+	tokensPrefix     = "tokens::"
+	sessionPrefix    = "resetsession::"
+	resetTokenPrefix = "resettoken::"
+	resetPasswordTTL = 8 * time.Hour
+)
+
+var errCacheMiss = errors.New("cache: key not found")
+
+// MemoryCache implements APICache.
+// Entries expire like Redis keys, expired entries are removed when they are read.
+type MemoryCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	value   interface{}
+	expires time.Time // never expires if zero
+}
+
+// InitializeMemoryCache returns the process-wide memory cache, see InitializeMemoryBackend
+func InitializeMemoryCache() (*MemoryCache, error) {
+	sharedOnce.Do(initShared)
+	return sharedCache, nil
+}
+
+func NewMemoryCache() *MemoryCache {
+	return &MemoryCache{entries: map[string]cacheEntry{}}
+}
+
+func (mc *MemoryCache) set(key string, value interface{}, ttl time.Duration) {
+	entry := cacheEntry{value: clone(value)}
+	if ttl > 0 {
+		entry.expires = time.Now().Add(ttl)
+	}
+	mc.entries[key] = entry
+}
+
+func (mc *MemoryCache) get(key string) (interface{}, error) {
+	entry, ok := mc.entries[key]
+	if ok && !entry.expires.IsZero() && time.Now().After(entry.expires) {
+		delete(mc.entries, key)
+		ok = false
+	}
+	if !ok {
+		return nil, errCacheMiss
+	}
+	return clone(entry.value), nil
+}
+
+// SetAuthToken stores the authentication token of a session, at key "tokens::<user_id>[-<device_id>]".
+// Tokens are set by python API on login in production, this is meant for tests and demos.
+func (mc *MemoryCache) SetAuthToken(key string, value *Auth_cache) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.set(key, value, time.Until(value.Expires_at))
+}
+
+func (mc *MemoryCache) GetAuthToken(token string) (value *Auth_cache, err error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	v, err := mc.get(token)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Auth_cache), nil
+}
+
+func (mc *MemoryCache) GetResetPasswordToken(token string) (*Pass_reset_session, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	v, err := mc.get(resetTokenPrefix + token)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Pass_reset_session), nil
+}
+
+func (mc *MemoryCache) GetResetPasswordSession(user_id string) (*Pass_reset_session, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	v, err := mc.get(sessionPrefix + user_id)
+	if err != nil {
+		return nil, err
+	}
+	return v.(*Pass_reset_session), nil
+}
+
+func (mc *MemoryCache) SetResetPasswordSession(user_id, reset_token string) (*Pass_reset_session, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	session := &Pass_reset_session{
+		Reset_token: reset_token,
+		Expires_at:  time.Now().Add(resetPasswordTTL),
+		Expires_in:  int(resetPasswordTTL / time.Second),
+		User_id:     user_id,
+	}
+	mc.set(sessionPrefix+user_id, session, resetPasswordTTL)
+	mc.set(resetTokenPrefix+reset_token, session, resetPasswordTTL)
+	return session, nil
+}
+
+func (mc *MemoryCache) DeleteResetPasswordSession(user_id string) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	v, err := mc.get(sessionPrefix + user_id)
+	if err != nil {
+		return err
+	}
+	delete(mc.entries, sessionPrefix+user_id)
+	delete(mc.entries, resetTokenPrefix+v.(*Pass_reset_session).Reset_token)
+	return nil
+}
+
+// DeleteDeviceSessions removes authentication tokens of one of user's devices
+func (mc *MemoryCache) DeleteDeviceSessions(user_id, device_id string) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	delete(mc.entries, tokensPrefix+user_id+"-"+device_id)
+	return nil
+}
+
+// DeleteUserSessions removes all authentication tokens of user, and its reset password session if any
+func (mc *MemoryCache) DeleteUserSessions(user_id string) error {
+	mc.mu.Lock()
+	for key := range mc.entries {
+		if strings.HasPrefix(key, tokensPrefix+user_id) {
+			delete(mc.entries, key)
+		}
+	}
+	mc.mu.Unlock()
+	if err := mc.DeleteResetPasswordSession(user_id); err != nil && err != errCacheMiss {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,170 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package memory
+
+import (
+	"errors"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"time"
+)
+
+func (mb *MemoryBackend) CreateContact(contact *Contact) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.contacts.set(contact.UserId.String(), contact.ContactId.String(), contact)
+	return nil
+}
+
+func (mb *MemoryBackend) RetrieveContact(user_id, contact_id string) (contact *Contact, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	row, ok := mb.contacts.get(user_id, contact_id)
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return row.(*Contact), nil
+}
+
+func (mb *MemoryBackend) UpdateContact(contact, oldContact *Contact, fields map[string]interface{}) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	userId, contactId := contact.UserId.String(), contact.ContactId.String()
+	row, ok := mb.contacts.get(userId, contactId)
+	if !ok {
+		return errors.New("not found")
+	}
+	stored := row.(*Contact)
+	if err := updateFields(stored, contact, fields); err != nil {
+		return err
+	}
+	mb.contacts.set(userId, contactId, stored)
+	return nil
+}
+
+func (mb *MemoryBackend) DeleteContact(contact *Contact) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.contacts.delete(contact.UserId.String(), contact.ContactId.String())
+	return nil
+}
+
+// CreatePublicKey adds key to the public keys of contact key.ResourceId
+func (mb *MemoryBackend) CreatePublicKey(key *PublicKey) error {
+	return mb.savePublicKey(key, false)
+}
+
+// UpdatePublicKey replaces the public key of contact key.ResourceId that has same key id
+func (mb *MemoryBackend) UpdatePublicKey(key *PublicKey) error {
+	return mb.savePublicKey(key, true)
+}
+
+func (mb *MemoryBackend) savePublicKey(key *PublicKey, update bool) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	row, ok := mb.contacts.get(key.UserId.String(), key.ResourceId.String())
+	if !ok {
+		return errors.New("not found")
+	}
+	contact := row.(*Contact)
+	found := false
+	for i, k := range contact.PublicKeys {
+		if k.KeyId.String() == key.KeyId.String() {
+			if !update {
+				return errors.New("key already exists")
+			}
+			contact.PublicKeys[i] = *key
+			found = true
+			break
+		}
+	}
+	if !found {
+		if update {
+			return errors.New("not found")
+		}
+		contact.PublicKeys = append(contact.PublicKeys, *key)
+	}
+	mb.contacts.set(key.UserId.String(), key.ResourceId.String(), contact)
+	return nil
+}
+
+// RetrieveAllContacts sends a snapshot of user's contacts, chan is closed once all have been sent.
+func (mb *MemoryBackend) RetrieveAllContacts(userId string) (<-chan *Contact, error) {
+	mb.mu.RLock()
+	rows := mb.contacts.rows(userId)
+	mb.mu.RUnlock()
+	ch := make(chan *Contact)
+	go func() {
+		for _, row := range rows {
+			ch <- row.(*Contact)
+		}
+		close(ch)
+	}()
+	return ch, nil
+}
+
+// LookupContactsByIdentifier returns ids of user's contacts that have address among their emails,
+// contacts are scanned instead of maintaining a contact_lookup table.
+func (mb *MemoryBackend) LookupContactsByIdentifier(user_id, address string) (contact_ids []string, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	for _, row := range mb.contacts.rows(user_id) {
+		contact := row.(*Contact)
+		for _, email := range contact.Emails {
+			if email.Address == address {
+				contact_ids = append(contact_ids, contact.ContactId.String())
+				break
+			}
+		}
+	}
+	return
+}
+
+func (mb *MemoryBackend) RecordInteractions(userId, protocol string, addresses []string, sent bool, at time.Time) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	seen := map[string]bool{}
+	for _, address := range addresses {
+		address = NormalizeInteractionAddress(address)
+		if address == "" || seen[address] {
+			continue
+		}
+		seen[address] = true
+		interaction := &ParticipantInteraction{Address: address}
+		if row, ok := mb.interactions.get(userId, address); ok {
+			interaction = row.(*ParticipantInteraction)
+		} else if userUUID, err := uuidFromString(userId); err == nil {
+			interaction.UserId = userUUID
+		}
+		interaction.Protocol = protocol
+		if sent {
+			interaction.Sent++
+			if at.After(interaction.LastSent) {
+				interaction.LastSent = at
+			}
+		} else {
+			interaction.Received++
+			if at.After(interaction.LastReceived) {
+				interaction.LastReceived = at
+			}
+		}
+		mb.interactions.set(userId, address, interaction)
+	}
+	return nil
+}
+
+func (mb *MemoryBackend) RetrieveInteractions(userId string, addresses []string) (interactions map[string]ParticipantInteraction, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	interactions = map[string]ParticipantInteraction{}
+	for _, address := range addresses {
+		address = NormalizeInteractionAddress(address)
+		if row, ok := mb.interactions.get(userId, address); ok {
+			interactions[address] = *(row.(*ParticipantInteraction))
+		}
+	}
+	return
+}
@@ -0,0 +1,623 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package memory
+
+import (
+	"encoding/json"
+	"errors"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// fields always searched in addition to the requested ones, as with Elasticsearch
+var searchedFields = []string{"body_plain", "body_html", "subject", "given_name", "family_name"}
+
+// name of the highlights that hold matching attachments' names, as with Elasticsearch
+const attachmentsHighlights = "attachments"
+
+// how many candidates are returned for a suggestion request, as with Elasticsearch
+const suggestCandidates = 100
+
+// MemoryIndex implements the indexes of REST API, LDA and notifiers.
+// Documents are the JSON representations sent to Elasticsearch, thus terms and filters use the same field names.
+// Filters are exact and case insensitive matches, full text search matches words within fields.
+// Facets are not computed.
+type MemoryIndex struct {
+	mu              sync.RWMutex
+	attachmentsText table // user_id, message_id
+	contacts        table // user_id, contact_id
+	messages        table // user_id, message_id
+}
+
+// InitializeMemoryIndex returns the process-wide memory index, see InitializeMemoryBackend
+func InitializeMemoryIndex() (*MemoryIndex, error) {
+	sharedOnce.Do(initShared)
+	return sharedIndex, nil
+}
+
+func NewMemoryIndex() *MemoryIndex {
+	return &MemoryIndex{
+		attachmentsText: table{},
+		contacts:        table{},
+		messages:        table{},
+	}
+}
+
+func (mi *MemoryIndex) Close() {
+}
+
+// messages
+
+func (mi *MemoryIndex) CreateMessage(msg *Message) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	mi.messages.set(msg.User_id.String(), msg.Message_id.String(), msg)
+	return nil
+}
+
+func (mi *MemoryIndex) UpdateMessage(msg *Message, fields map[string]interface{}) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	userId, msgId := msg.User_id.String(), msg.Message_id.String()
+	row, ok := mi.messages.get(userId, msgId)
+	if !ok {
+		return errors.New("not found")
+	}
+	stored := row.(*Message)
+	if err := updateFields(stored, msg, fields); err != nil {
+		return err
+	}
+	mi.messages.set(userId, msgId, stored)
+	return nil
+}
+
+func (mi *MemoryIndex) DeleteMessage(msg *Message) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	mi.messages.delete(msg.User_id.String(), msg.Message_id.String())
+	mi.attachmentsText.delete(msg.User_id.String(), msg.Message_id.String())
+	return nil
+}
+
+func (mi *MemoryIndex) SetMessageUnread(user_id, message_id string, status bool) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	row, ok := mi.messages.get(user_id, message_id)
+	if !ok {
+		return errors.New("not found")
+	}
+	msg := row.(*Message)
+	msg.Is_unread = status
+	mi.messages.set(user_id, message_id, msg)
+	return nil
+}
+
+// IndexAttachmentsText makes attachments' texts searchable along with their message
+func (mi *MemoryIndex) IndexAttachmentsText(user_id, message_id string, texts []AttachmentText) error {
+	if len(texts) == 0 {
+		return nil
+	}
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	if _, ok := mi.messages.get(user_id, message_id); !ok {
+		return errors.New("[MemoryIndex] IndexAttachmentsText : message " + message_id + " not found")
+	}
+	mi.attachmentsText.set(user_id, message_id, texts)
+	return nil
+}
+
+// RefreshMessages does nothing, memory index is searchable as soon as it is written
+func (mi *MemoryIndex) RefreshMessages(user_id string) error {
+	return nil
+}
+
+func (mi *MemoryIndex) MessageExistsByExternalId(user_id, external_msg_id string) (bool, error) {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	for _, row := range mi.messages.rows(user_id) {
+		if row.(*Message).External_references.Message_id == external_msg_id {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// FilterMessages returns messages matching filter, most recent first
+func (mi *MemoryIndex) FilterMessages(filter IndexSearch) (messages []*Message, totalFound int64, err error) {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	for _, row := range mi.messages.rows(filter.User_id.String()) {
+		msg := row.(*Message)
+		if filterMatches(filter, messageDocument(msg), true) {
+			messages = append(messages, msg)
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return messages[i].Date_sort.After(messages[j].Date_sort)
+	})
+	totalFound = int64(len(messages))
+	from, to := paginate(len(messages), filter.Offset, filter.Limit)
+	messages = messages[from:to]
+	return
+}
+
+// contacts
+
+func (mi *MemoryIndex) CreateContact(contact *Contact) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	mi.contacts.set(contact.UserId.String(), contact.ContactId.String(), contact)
+	return nil
+}
+
+func (mi *MemoryIndex) UpdateContact(contact *Contact, fields map[string]interface{}) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	userId, contactId := contact.UserId.String(), contact.ContactId.String()
+	row, ok := mi.contacts.get(userId, contactId)
+	if !ok {
+		return errors.New("not found")
+	}
+	stored := row.(*Contact)
+	if err := updateFields(stored, contact, fields); err != nil {
+		return err
+	}
+	mi.contacts.set(userId, contactId, stored)
+	return nil
+}
+
+func (mi *MemoryIndex) DeleteContact(contact *Contact) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	mi.contacts.delete(contact.UserId.String(), contact.ContactId.String())
+	return nil
+}
+
+// FilterContacts returns contacts matching filter, ordered by title
+func (mi *MemoryIndex) FilterContacts(filter IndexSearch) (contacts []*Contact, totalFound int64, err error) {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	for _, row := range mi.contacts.rows(filter.User_id.String()) {
+		contact := row.(*Contact)
+		if filterMatches(filter, contactDocument(contact), false) {
+			contacts = append(contacts, contact)
+		}
+	}
+	sort.SliceStable(contacts, func(i, j int) bool {
+		return contacts[i].Title < contacts[j].Title
+	})
+	totalFound = int64(len(contacts))
+	from, to := paginate(len(contacts), filter.Offset, filter.Limit)
+	contacts = contacts[from:to]
+	return
+}
+
+// RecipientsSuggest returns messages' participants and contacts whose name or address starts with query_string,
+// or whose address has a part equal to query_string.
+func (mi *MemoryIndex) RecipientsSuggest(user_id, query_string string) (suggests []RecipientSuggestion, err error) {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	suggests = []RecipientSuggestion{}
+	query := strings.ToLower(query_string)
+	seen := map[string]bool{}
+	for _, row := range mi.messages.rows(user_id) {
+		for _, participant := range row.(*Message).Participants {
+			if seen[participant.Address] || !(hasPrefix(participant.Label, query) || addressMatches(participant.Address, query)) {
+				continue
+			}
+			seen[participant.Address] = true
+			suggest := RecipientSuggestion{
+				Address:  participant.Address,
+				Label:    participant.Label,
+				Protocol: participant.Protocol,
+				Source:   "participant",
+			}
+			if len(participant.Contact_ids) > 0 {
+				suggest.Contact_Id = participant.Contact_ids[0].String()
+			}
+			suggests = append(suggests, suggest)
+		}
+	}
+	for _, row := range mi.contacts.rows(user_id) {
+		contact := row.(*Contact)
+		suggest := RecipientSuggestion{
+			Contact_Id: contact.ContactId.String(),
+			Label:      contact.Title,
+			PI:         contact.PrivacyIndex,
+			Source:     "contact",
+		}
+		matched := hasPrefix(contact.GivenName, query) || hasPrefix(contact.FamilyName, query)
+		for _, email := range contact.Emails {
+			if hasPrefix(email.Label, query) || addressMatches(email.Address, query) {
+				suggest.Address = email.Address
+				suggest.Protocol = EmailProtocol
+				matched = true
+				break
+			}
+		}
+		if matched {
+			suggests = append(suggests, suggest)
+		}
+	}
+	if len(suggests) > suggestCandidates {
+		suggests = suggests[:suggestCandidates]
+	}
+	return
+}
+
+// Search looks for the words of search's terms within documents.
+// Hits are ordered by score, which is the number of words found.
+// If search.DocType is empty, only the 5 best hits of each type are returned.
+func (mi *MemoryIndex) Search(search IndexSearch) (result *IndexResult, err error) {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	userId := search.User_id.String()
+	result = &IndexResult{
+		MessagesHits: MessageHits{Messages: []*IndexHit{}},
+		ContactsHits: ContactHits{Contacts: []*IndexHit{}},
+		Facets:       map[string][]FacetBucket{},
+	}
+
+	if search.DocType == "" || search.DocType == MessageIndexType {
+		hits := []*IndexHit{}
+		for _, row := range mi.messages.rows(userId) {
+			msg := row.(*Message)
+			doc := messageDocument(msg)
+			if texts, ok := mi.attachmentsText.get(userId, msg.Message_id.String()); ok {
+				addAttachmentsText(doc, texts.([]AttachmentText))
+			}
+			hit := searchDocument(search, doc, search.DocType == MessageIndexType)
+			if hit != nil {
+				hit.Id = msg.Message_id
+				hit.Document = msg
+				hits = append(hits, hit)
+			}
+		}
+		result.MessagesHits.Total = int64(len(hits))
+		result.MessagesHits.Messages = rankHits(hits, search)
+	}
+	if search.DocType == "" || search.DocType == ContactIndexType {
+		hits := []*IndexHit{}
+		for _, row := range mi.contacts.rows(userId) {
+			contact := row.(*Contact)
+			hit := searchDocument(search, contactDocument(contact), false)
+			if hit != nil {
+				hit.Id = contact.ContactId
+				hit.Document = contact
+				hits = append(hits, hit)
+			}
+		}
+		result.ContactsHits.Total = int64(len(hits))
+		result.ContactsHits.Contacts = rankHits(hits, search)
+	}
+	result.Total = result.MessagesHits.Total + result.ContactsHits.Total
+	return
+}
+
+// retention
+
+// TrashedMessagesBefore returns up to limit messages moved to trash before the given date, oldest first
+func (mi *MemoryIndex) TrashedMessagesBefore(user_id string, before time.Time, limit int) (messages []*Message, err error) {
+	return mi.retentionMessages(user_id, limit, func(msg *Message) (time.Time, bool) {
+		return msg.Date_delete, !msg.Date_delete.IsZero() && msg.Date_delete.Before(before)
+	})
+}
+
+// TaggedMessagesBefore returns up to limit messages not in trash, that hold tag and that have been received before the given date, oldest first
+func (mi *MemoryIndex) TaggedMessagesBefore(user_id, tag string, before time.Time, limit int) (messages []*Message, err error) {
+	return mi.retentionMessages(user_id, limit, func(msg *Message) (time.Time, bool) {
+		if !msg.Date_delete.IsZero() || !msg.Date_insert.Before(before) {
+			return msg.Date_insert, false
+		}
+		for _, t := range msg.Tags {
+			if t == tag {
+				return msg.Date_insert, true
+			}
+		}
+		return msg.Date_insert, false
+	})
+}
+
+// retentionMessages returns up to limit messages selected by match, ordered by the date returned by match
+func (mi *MemoryIndex) retentionMessages(user_id string, limit int, match func(*Message) (time.Time, bool)) ([]*Message, error) {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	messages := []*Message{}
+	dates := map[*Message]time.Time{}
+	for _, row := range mi.messages.rows(user_id) {
+		msg := row.(*Message)
+		if date, ok := match(msg); ok {
+			messages = append(messages, msg)
+			dates[msg] = date
+		}
+	}
+	sort.SliceStable(messages, func(i, j int) bool {
+		return dates[messages[i]].Before(dates[messages[j]])
+	})
+	from, to := paginate(len(messages), 0, limit)
+	return messages[from:to], nil
+}
+
+// DeleteUserIndex removes all documents of user
+func (mi *MemoryIndex) DeleteUserIndex(user_id string) error {
+	mi.mu.Lock()
+	defer mi.mu.Unlock()
+	delete(mi.messages, user_id)
+	delete(mi.contacts, user_id)
+	delete(mi.attachmentsText, user_id)
+	return nil
+}
+
+// consistency checks
+
+// IndexedIds returns the ids of all documents of docType indexed for user
+func (mi *MemoryIndex) IndexedIds(user_id, docType string) (ids []string, err error) {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	var t table
+	switch docType {
+	case MessageIndexType:
+		t = mi.messages
+	case ContactIndexType:
+		t = mi.contacts
+	default:
+		return nil, errors.New("[MemoryIndex] unknown document type " + docType)
+	}
+	for id := range t[user_id] {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return
+}
+
+func (mi *MemoryIndex) IndexedMessages(user_id string, ids []string) (messages map[string]*Message, err error) {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	messages = map[string]*Message{}
+	for _, id := range ids {
+		if row, ok := mi.messages.get(user_id, id); ok {
+			messages[id] = row.(*Message)
+		}
+	}
+	return
+}
+
+func (mi *MemoryIndex) IndexedContacts(user_id string, ids []string) (contacts map[string]*Contact, err error) {
+	mi.mu.RLock()
+	defer mi.mu.RUnlock()
+	contacts = map[string]*Contact{}
+	for _, id := range ids {
+		if row, ok := mi.contacts.get(user_id, id); ok {
+			contacts[id] = row.(*Contact)
+		}
+	}
+	return
+}
+
+// rankHits orders hits by score, then keeps the requested page, or the 5 best hits if search.DocType is empty
+func rankHits(hits []*IndexHit, search IndexSearch) []*IndexHit {
+	sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+	if search.DocType == "" {
+		if len(hits) > 5 {
+			hits = hits[:5]
+		}
+		return hits
+	}
+	from, to := paginate(len(hits), search.Offset, search.Limit)
+	return hits[from:to]
+}
+
+// searchDocument returns a hit if doc holds search's filters and at least one word of its terms, nil otherwise.
+// Terms are not required if there is none.
+func searchDocument(search IndexSearch, doc map[string]interface{}, withIL bool) *IndexHit {
+	if !exactMatches(search.Filters, doc) || isDeleted(doc) != search.Trash || (withIL && !inILRange(search, doc)) {
+		return nil
+	}
+	hit := &IndexHit{Score: 1, Highlights: map[string][]string{}}
+	if len(search.Terms) == 0 {
+		return hit
+	}
+	hit.Score = 0
+	for field, values := range search.Terms {
+		fields := append([]string{field}, searchedFields...)
+		for _, value := range values {
+			for _, word := range strings.Fields(strings.ToLower(value)) {
+				for _, f := range fields {
+					for _, text := range lookup(doc, f) {
+						if strings.Contains(strings.ToLower(formatValue(text)), word) {
+							hit.Score++
+							hit.Highlights[f] = append(hit.Highlights[f], "<em>"+word+"</em>")
+							break
+						}
+					}
+				}
+				for _, attachment := range lookupList(doc, "attachments") {
+					content, _ := attachment["content"].(string)
+					if strings.Contains(strings.ToLower(content), word) {
+						hit.Score++
+						name, _ := attachment["file_name"].(string)
+						hit.Highlights[attachmentsHighlights] = append(hit.Highlights[attachmentsHighlights], name)
+					}
+				}
+			}
+		}
+	}
+	if hit.Score == 0 {
+		return nil
+	}
+	return hit
+}
+
+// filterMatches returns true if doc holds all search's terms and filters,
+// is in trash or not as requested by search, and is in search's importance level range if withIL.
+func filterMatches(search IndexSearch, doc map[string]interface{}, withIL bool) bool {
+	return exactMatches(search.Terms, doc) && exactMatches(search.Filters, doc) &&
+		isDeleted(doc) == search.Trash && (!withIL || inILRange(search, doc))
+}
+
+func exactMatches(terms map[string][]string, doc map[string]interface{}) bool {
+	for name, values := range terms {
+		for _, value := range values {
+			found := false
+			for _, v := range lookup(doc, name) {
+				if strings.EqualFold(formatValue(v), value) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// documents are in trash as soon as they have a date_delete
+func isDeleted(doc map[string]interface{}) bool {
+	return len(lookup(doc, "date_delete")) > 0
+}
+
+func inILRange(search IndexSearch, doc map[string]interface{}) bool {
+	il := 0.0
+	if values := lookup(doc, "importance_level"); len(values) > 0 {
+		il, _ = values[0].(float64)
+	}
+	return il >= float64(search.ILrange[0]) && il <= float64(search.ILrange[1])
+}
+
+// messageDocument returns message as indexed into Elasticsearch
+func messageDocument(msg *Message) map[string]interface{} {
+	j, _ := msg.MarshalES()
+	return jsonDocument(j)
+}
+
+// contactDocument returns contact as indexed into Elasticsearch
+func contactDocument(contact *Contact) map[string]interface{} {
+	j, _ := contact.MarshelES()
+	return jsonDocument(j)
+}
+
+func jsonDocument(j []byte) map[string]interface{} {
+	doc := map[string]interface{}{}
+	json.Unmarshal(j, &doc)
+	return doc
+}
+
+// addAttachmentsText puts texts into the content of the attachments with the same file name
+func addAttachmentsText(doc map[string]interface{}, texts []AttachmentText) {
+	used := make([]bool, len(texts))
+	for _, attachment := range lookupList(doc, "attachments") {
+		name, _ := attachment["file_name"].(string)
+		for i, text := range texts {
+			if !used[i] && text.FileName == name {
+				attachment["content"] = text.Text
+				used[i] = true
+				break
+			}
+		}
+	}
+}
+
+// lookup returns the values found at a dotted path, arrays being flattened.
+// Elasticsearch's sub-fields (title.raw, subject.normalized…) are looked up as their parent field.
+func lookup(doc map[string]interface{}, name string) []interface{} {
+	path := strings.Split(name, ".")
+	values := walk(doc, path)
+	if len(values) == 0 && len(path) > 1 {
+		switch path[len(path)-1] {
+		case "raw", "normalized", "parts":
+			values = walk(doc, path[:len(path)-1])
+		}
+	}
+	return values
+}
+
+func walk(node interface{}, path []string) (values []interface{}) {
+	switch n := node.(type) {
+	case []interface{}:
+		for _, item := range n {
+			values = append(values, walk(item, path)...)
+		}
+		return
+	case map[string]interface{}:
+		if len(path) == 0 {
+			return []interface{}{n}
+		}
+		child, ok := n[path[0]]
+		if !ok || child == nil {
+			return nil
+		}
+		return walk(child, path[1:])
+	}
+	if len(path) > 0 {
+		return nil
+	}
+	return []interface{}{node}
+}
+
+// lookupList returns the objects of the array at name
+func lookupList(doc map[string]interface{}, name string) (list []map[string]interface{}) {
+	for _, v := range lookup(doc, name) {
+		if m, ok := v.(map[string]interface{}); ok {
+			list = append(list, m)
+		}
+	}
+	return
+}
+
+func formatValue(v interface{}) string {
+	switch value := v.(type) {
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	case float64:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case nil:
+		return ""
+	}
+	j, _ := json.Marshal(v)
+	return string(j)
+}
+
+func hasPrefix(s, lowerPrefix string) bool {
+	return lowerPrefix != "" && strings.HasPrefix(strings.ToLower(s), lowerPrefix)
+}
+
+// addressMatches returns true if address starts with query or if one of its parts (split on punctuation) is query
+func addressMatches(address, lowerQuery string) bool {
+	if hasPrefix(address, lowerQuery) {
+		return true
+	}
+	parts := strings.FieldsFunc(strings.ToLower(address), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, part := range parts {
+		if part == lowerQuery {
+			return true
+		}
+	}
+	return false
+}
+
+// paginate returns the bounds of the page at offset, limit is ignored if not positive
+func paginate(length, offset, limit int) (from, to int) {
+	from, to = offset, length
+	if from > length || from < 0 {
+		from = length
+	}
+	if limit > 0 && from+limit < length {
+		to = from + limit
+	}
+	return
+}
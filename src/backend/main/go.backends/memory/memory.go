@@ -0,0 +1,172 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+// Package memory implements store, index and cache backends that keep everything in process memory.
+// They are selected with the "memory" backend name in config, to run tests and demos without
+// Cassandra, Elasticsearch nor Redis. Bus replaces NATS when its url is "memory". Nothing is persisted : data is lost when process exits.
+//
+// Objects are deep copied when they are saved and when they are returned,
+// thus callers never share memory with the backends, as with real databases.
+package memory
+
+import (
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/satori/go.uuid"
+	"gopkg.in/oleiade/reflections.v1"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// table holds rows by partition key, then by clustering key.
+// Tables without partition use "" as partition key.
+type table map[string]map[string]interface{}
+
+func (t table) set(partition, key string, row interface{}) {
+	if t[partition] == nil {
+		t[partition] = map[string]interface{}{}
+	}
+	t[partition][key] = clone(row)
+}
+
+func (t table) get(partition, key string) (row interface{}, ok bool) {
+	row, ok = t[partition][key]
+	if !ok {
+		return nil, false
+	}
+	return clone(row), true
+}
+
+// rows returns copies of partition's rows, ordered by key
+func (t table) rows(partition string) []interface{} {
+	keys := make([]string, 0, len(t[partition]))
+	for key := range t[partition] {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	rows := make([]interface{}, len(keys))
+	for i, key := range keys {
+		rows[i] = clone(t[partition][key])
+	}
+	return rows
+}
+
+func (t table) delete(partition, key string) {
+	delete(t[partition], key)
+	if len(t[partition]) == 0 {
+		delete(t, partition)
+	}
+}
+
+// clone returns a deep copy of obj.
+// Pointers to structs without exported fields (mutexes, time locations…) are shared, not copied.
+func clone(obj interface{}) interface{} {
+	if obj == nil {
+		return nil
+	}
+	return deepCopy(reflect.ValueOf(obj)).Interface()
+}
+
+func deepCopy(src reflect.Value) reflect.Value {
+	switch src.Kind() {
+	case reflect.Ptr:
+		if src.IsNil() || !hasExportedFields(src.Elem()) {
+			return src
+		}
+		dst := reflect.New(src.Elem().Type())
+		dst.Elem().Set(deepCopy(src.Elem()))
+		return dst
+	case reflect.Struct:
+		dst := reflect.New(src.Type()).Elem()
+		dst.Set(src) // unexported fields are copied as is
+		for i := 0; i < src.NumField(); i++ {
+			if dst.Field(i).CanSet() {
+				dst.Field(i).Set(deepCopy(src.Field(i)))
+			}
+		}
+		return dst
+	case reflect.Slice:
+		if src.IsNil() {
+			return src
+		}
+		dst := reflect.MakeSlice(src.Type(), src.Len(), src.Len())
+		for i := 0; i < src.Len(); i++ {
+			dst.Index(i).Set(deepCopy(src.Index(i)))
+		}
+		return dst
+	case reflect.Map:
+		if src.IsNil() {
+			return src
+		}
+		dst := reflect.MakeMap(src.Type())
+		for _, key := range src.MapKeys() {
+			dst.SetMapIndex(key, deepCopy(src.MapIndex(key)))
+		}
+		return dst
+	case reflect.Interface:
+		if src.IsNil() {
+			return src
+		}
+		dst := reflect.New(src.Type()).Elem()
+		dst.Set(deepCopy(src.Elem()))
+		return dst
+	}
+	return src
+}
+
+func hasExportedFields(v reflect.Value) bool {
+	if v.Kind() != reflect.Struct {
+		return true
+	}
+	for i := 0; i < v.NumField(); i++ {
+		if v.Type().Field(i).PkgPath == "" {
+			return true
+		}
+	}
+	return false
+}
+
+// updateFields copies the given fields from obj to stored.
+// Like for other backends, 'fields' are the struct fields names that have been modified :
+// values are read from obj, map's values are ignored.
+func updateFields(stored, obj interface{}, fields map[string]interface{}) error {
+	for field := range fields {
+		value, err := reflections.GetField(obj, field)
+		if err != nil {
+			return fmt.Errorf("[MemoryBackend] failed to find field %s", field)
+		}
+		if err = reflections.SetField(stored, field, clone(value)); err != nil {
+			return fmt.Errorf("[MemoryBackend] failed to update field %s : %s", field, err)
+		}
+	}
+	return nil
+}
+
+func uuidFromString(id string) (UUID, error) {
+	var u UUID
+	parsed, err := uuid.FromString(id)
+	if err != nil {
+		return u, err
+	}
+	err = u.UnmarshalBinary(parsed.Bytes())
+	return u, err
+}
+
+var (
+	sharedBackend *MemoryBackend
+	sharedIndex   *MemoryIndex
+	sharedCache   *MemoryCache
+	sharedBus     *Bus
+	sharedOnce    sync.Once
+)
+
+func initShared() {
+	sharedBackend = NewMemoryBackend()
+	sharedIndex = NewMemoryIndex()
+	sharedCache = NewMemoryCache()
+	sharedBus = NewMemoryBus()
+}
@@ -0,0 +1,180 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package memory
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/nats-io/go-nats"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testUUID(t *testing.T, id string) UUID {
+	u, err := uuidFromString(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestMemoryBackend_MessagesAreCopied(t *testing.T) {
+	mb := NewMemoryBackend()
+	msg := &Message{
+		User_id:    testUUID(t, "8a8fdb3d-cd41-4988-a0a5-80ea2df2633e"),
+		Message_id: testUUID(t, "06e35fed-72d5-4138-b5d3-cc2e28a1bf6d"),
+		Subject:    "hello",
+		Tags:       []string{"work"},
+	}
+	if err := mb.CreateMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+	msg.Tags[0] = "changed"
+
+	stored, err := mb.RetrieveMessage(msg.User_id.String(), msg.Message_id.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Tags[0] != "work" {
+		t.Errorf("stored message shares memory with caller : tags are %v", stored.Tags)
+	}
+
+	stored.Subject = "updated"
+	if err = mb.UpdateMessage(stored, map[string]interface{}{"Subject": nil}); err != nil {
+		t.Fatal(err)
+	}
+	stored, _ = mb.RetrieveMessage(msg.User_id.String(), msg.Message_id.String())
+	if stored.Subject != "updated" || stored.Tags[0] != "work" {
+		t.Errorf("expected only subject to be updated, got %+v", stored)
+	}
+
+	if _, err = mb.RetrieveMessage(msg.User_id.String(), "unknown"); err == nil || err.Error() != "not found" {
+		t.Errorf("expected 'not found' error, got %v", err)
+	}
+}
+
+func TestMemoryIndex_FilterAndSearch(t *testing.T) {
+	mi := NewMemoryIndex()
+	userId := testUUID(t, "8a8fdb3d-cd41-4988-a0a5-80ea2df2633e")
+	now := time.Now()
+	messages := []*Message{
+		{
+			User_id:          userId,
+			Message_id:       testUUID(t, "06e35fed-72d5-4138-b5d3-cc2e28a1bf6d"),
+			Subject:          "Meeting tomorrow",
+			Is_unread:        true,
+			Importance_level: 5,
+			Date_sort:        now.Add(-time.Hour),
+		},
+		{
+			User_id:          userId,
+			Message_id:       testUUID(t, "1c5e2d43-5c39-4a2a-8c0b-7d3d9f3fbd52"),
+			Subject:          "Holidays",
+			Importance_level: 2,
+			Date_sort:        now,
+		},
+		{
+			User_id:     userId,
+			Message_id:  testUUID(t, "3b0d6c5e-9f8a-4a55-b0a2-6e0a9c6b2f11"),
+			Subject:     "Old meeting",
+			Date_sort:   now.Add(-2 * time.Hour),
+			Date_delete: now,
+		},
+	}
+	for _, msg := range messages {
+		if err := mi.CreateMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	filter := IndexSearch{User_id: userId, ILrange: [2]int8{-10, 10}}
+	found, total, err := mi.FilterMessages(filter)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 2 || found[0].Subject != "Holidays" || found[1].Subject != "Meeting tomorrow" {
+		t.Errorf("expected messages not in trash, most recent first, got %d : %+v", total, found)
+	}
+
+	filter.Terms = map[string][]string{"is_unread": {"true"}}
+	found, total, _ = mi.FilterMessages(filter)
+	if total != 1 || found[0].Subject != "Meeting tomorrow" {
+		t.Errorf("expected unread message only, got %d : %+v", total, found)
+	}
+
+	filter.Terms = nil
+	filter.ILrange = [2]int8{3, 10}
+	found, total, _ = mi.FilterMessages(filter)
+	if total != 1 || found[0].Subject != "Meeting tomorrow" {
+		t.Errorf("expected message within importance level range only, got %d : %+v", total, found)
+	}
+
+	search := IndexSearch{
+		User_id: userId,
+		Terms:   map[string][]string{"subject": {"meeting"}},
+		ILrange: [2]int8{-10, 10},
+	}
+	result, err := mi.Search(search)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.MessagesHits.Total != 1 || result.MessagesHits.Messages[0].Id != messages[0].Message_id {
+		t.Errorf("expected one hit for first message, got %+v", result.MessagesHits)
+	}
+	search.Trash = true
+	result, _ = mi.Search(search)
+	if result.MessagesHits.Total != 1 || result.MessagesHits.Messages[0].Id != messages[2].Message_id {
+		t.Errorf("expected one hit for deleted message, got %+v", result.MessagesHits)
+	}
+}
+
+func TestBus(t *testing.T) {
+	bus := NewMemoryBus()
+	if _, err := bus.Request("inboundSMTP", []byte("order"), time.Second); err != ErrNoSubscriber {
+		t.Errorf("expected request without subscriber to fail, got %v", err)
+	}
+
+	// two workers of a queue group share the orders, the other subscriber sees all of them
+	var mu sync.Mutex
+	received := map[string]int{}
+	wg := new(sync.WaitGroup)
+	wg.Add(8)
+	for _, name := range []string{"worker1", "worker2"} {
+		name := name
+		bus.QueueSubscribe("outboundSMTP", "SMTPqueue", func(msg *nats.Msg) {
+			mu.Lock()
+			received[name]++
+			mu.Unlock()
+			bus.Publish(msg.Reply, append([]byte("sent by "), msg.Data...))
+			wg.Done()
+		})
+	}
+	bus.QueueSubscribe("outboundSMTP", "", func(msg *nats.Msg) {
+		mu.Lock()
+		received["monitor"]++
+		mu.Unlock()
+		wg.Done()
+	})
+
+	for i := 0; i < 4; i++ {
+		if i%2 == 0 {
+			bus.Publish("outboundSMTP", []byte("deliver"))
+			continue
+		}
+		reply, err := bus.Request("outboundSMTP", []byte("deliver"), time.Second)
+		if err != nil || string(reply.Data) != "sent by deliver" {
+			t.Errorf("unexpected reply %v, %v", reply, err)
+		}
+	}
+	wg.Wait()
+	if received["worker1"] != 2 || received["worker2"] != 2 || received["monitor"] != 4 {
+		t.Errorf("unexpected dispatch of messages : %v", received)
+	}
+
+	bus.QueueSubscribe("slow", "", func(msg *nats.Msg) {})
+	if _, err := bus.Request("slow", nil, 10*time.Millisecond); err != nats.ErrTimeout {
+		t.Errorf("expected request without reply to time out, got %v", err)
+	}
+}
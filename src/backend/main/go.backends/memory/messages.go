@@ -0,0 +1,313 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package memory
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"io"
+	"io/ioutil"
+	"strings"
+	"time"
+)
+
+// uri prefix of files stored by StoreAttachment
+const attachmentsURI = "memory://attachments/"
+
+func (mb *MemoryBackend) CreateMessage(msg *Message) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.messages.set(msg.User_id.String(), msg.Message_id.String(), msg)
+	return nil
+}
+
+func (mb *MemoryBackend) RetrieveMessage(user_id, msg_id string) (msg *Message, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	row, ok := mb.messages.get(user_id, msg_id)
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return row.(*Message), nil
+}
+
+func (mb *MemoryBackend) UpdateMessage(msg *Message, fields map[string]interface{}) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	userId, msgId := msg.User_id.String(), msg.Message_id.String()
+	row, ok := mb.messages.get(userId, msgId)
+	if !ok {
+		return errors.New("not found")
+	}
+	stored := row.(*Message)
+	if err := updateFields(stored, msg, fields); err != nil {
+		return err
+	}
+	mb.messages.set(userId, msgId, stored)
+	return nil
+}
+
+// DeleteMessage removes the message, along with the marks left by ReleaseMessageRefs.
+func (mb *MemoryBackend) DeleteMessage(msg *Message) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	userId, msgId := msg.User_id.String(), msg.Message_id.String()
+	mb.messages.delete(userId, msgId)
+	for key := range mb.released[userId] {
+		if strings.HasPrefix(key, msgId+" ") {
+			mb.released.delete(userId, key)
+		}
+	}
+	return nil
+}
+
+// ReleaseMessageRefs releases the references that msg holds on its attachments and raw message.
+// Each reference is marked as released before its counter is decremented,
+// thus running it again for the same message after a failure never releases a reference twice.
+func (mb *MemoryBackend) ReleaseMessageRefs(msg *Message) error {
+	userId, msgId := msg.User_id.String(), msg.Message_id.String()
+	for i, attachment := range msg.Attachments {
+		if attachment.URL == "" {
+			continue
+		}
+		mark := fmt.Sprintf("%s %d:%s", msgId, i, attachment.URL)
+		mb.mu.Lock()
+		_, done := mb.released.get(userId, mark)
+		if !done {
+			mb.released.set(userId, mark, true)
+			mb.attachmentRefs[attachment.URL]--
+		}
+		if mb.attachmentRefs[attachment.URL] <= 0 {
+			delete(mb.attachmentRefs, attachment.URL)
+			delete(mb.attachments, attachment.URL)
+		}
+		mb.mu.Unlock()
+	}
+	if !bytes.Equal(msg.Raw_msg_id.Bytes(), EmptyUUID.Bytes()) {
+		rawMsgId := msg.Raw_msg_id.String()
+		mark := msgId + " raw:" + rawMsgId
+		mb.mu.Lock()
+		defer mb.mu.Unlock()
+		if _, done := mb.released.get(userId, mark); done {
+			mb.removeUnreferencedRawMessage(rawMsgId)
+			return nil
+		}
+		mb.released.set(userId, mark, true)
+		mb.releaseRawMessage(userId, rawMsgId, false)
+	}
+	return nil
+}
+
+func (mb *MemoryBackend) SetMessageUnread(user_id, message_id string, status bool) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	row, ok := mb.messages.get(user_id, message_id)
+	if !ok {
+		return errors.New("not found")
+	}
+	msg := row.(*Message)
+	msg.Is_unread = status
+	mb.messages.set(user_id, message_id, msg)
+	return nil
+}
+
+// RetrieveAllMessages sends a snapshot of user's messages, chan is closed once all have been sent.
+func (mb *MemoryBackend) RetrieveAllMessages(userId string) (<-chan *Message, error) {
+	mb.mu.RLock()
+	rows := mb.messages.rows(userId)
+	mb.mu.RUnlock()
+	ch := make(chan *Message)
+	go func() {
+		for _, row := range rows {
+			ch <- row.(*Message)
+		}
+		close(ch)
+	}()
+	return ch, nil
+}
+
+// RaiseDiscussionImportanceLevel sets discussion's importance level to level if it is higher than current one.
+func (mb *MemoryBackend) RaiseDiscussionImportanceLevel(user_id, discussion_id string, level int32) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	discussion := &Discussion{Date_insert: time.Now()}
+	if row, ok := mb.discussions.get(user_id, discussion_id); ok {
+		discussion = row.(*Discussion)
+		if discussion.Importance_level >= level {
+			return nil
+		}
+	} else if id, err := uuidFromString(discussion_id); err == nil {
+		discussion.Discussion_id = id
+	}
+	discussion.Importance_level = level
+	mb.discussions.set(user_id, discussion_id, discussion)
+	return nil
+}
+
+// RetrieveDiscussion returns discussion's row, as saved by RaiseDiscussionImportanceLevel
+func (mb *MemoryBackend) RetrieveDiscussion(user_id, discussion_id string) (*Discussion, error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	row, ok := mb.discussions.get(user_id, discussion_id)
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return row.(*Discussion), nil
+}
+
+func (mb *MemoryBackend) CreateThreadLookup(user_id, discussion_id UUID, external_msg_id string) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.threads.set(user_id.String(), external_msg_id, discussion_id)
+	return nil
+}
+
+// StoreRawMessage stores msg unless a raw message with the same content already exists,
+// in which case msg.Raw_msg_id is set to the existing raw message's id.
+// Each user takes a reference on the raw message, released by DeleteRawMessage.
+// References are counted for the raw message, and per user in user's lookup.
+func (mb *MemoryBackend) StoreRawMessage(msg *RawMessage, users []UUID) (err error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	sum := sha256.Sum256([]byte(msg.Raw_data))
+	msg.Hash = hex.EncodeToString(sum[:])
+	if existing, ok := mb.rawHashes[msg.Hash]; ok {
+		row, _ := mb.rawMessages.get("", existing)
+		msg.Raw_msg_id = row.(*RawMessage).Raw_msg_id
+	} else {
+		mb.rawMessages.set("", msg.Raw_msg_id.String(), msg)
+		mb.rawHashes[msg.Hash] = msg.Raw_msg_id.String()
+	}
+	rawMsgId := msg.Raw_msg_id.String()
+	mb.rawRefs[rawMsgId] += len(users)
+	for _, user := range users {
+		held, _ := mb.rawLookup.get(user.String(), rawMsgId)
+		refs, _ := held.(int)
+		mb.rawLookup.set(user.String(), rawMsgId, refs+1)
+	}
+	return nil
+}
+
+func (mb *MemoryBackend) GetRawMessage(raw_message_id string) (raw_message RawMessage, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	row, ok := mb.rawMessages.get("", raw_message_id)
+	if !ok {
+		return RawMessage{}, errors.New("not found")
+	}
+	return *(row.(*RawMessage)), nil
+}
+
+// DeleteRawMessage releases one of the references that user holds on the raw message,
+// then deletes it if no other message references it anymore.
+func (mb *MemoryBackend) DeleteRawMessage(user_id, raw_msg_id string) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.releaseRawMessage(user_id, raw_msg_id, false)
+	return nil
+}
+
+// releaseRawMessage releases one or all of the references that user holds on the raw message,
+// then deletes it if it is not referenced anymore. mb.mu must be held.
+func (mb *MemoryBackend) releaseRawMessage(user_id, raw_msg_id string, all bool) {
+	if held, ok := mb.rawLookup.get(user_id, raw_msg_id); ok {
+		refs := held.(int)
+		released := 1
+		if all || refs <= 1 {
+			released = refs
+			mb.rawLookup.delete(user_id, raw_msg_id)
+		} else {
+			mb.rawLookup.set(user_id, raw_msg_id, refs-1)
+		}
+		mb.rawRefs[raw_msg_id] -= released
+	}
+	mb.removeUnreferencedRawMessage(raw_msg_id)
+}
+
+// removeUnreferencedRawMessage deletes the raw message if no message references it anymore. mb.mu must be held.
+func (mb *MemoryBackend) removeUnreferencedRawMessage(raw_msg_id string) {
+	if mb.rawRefs[raw_msg_id] > 0 {
+		return
+	}
+	delete(mb.rawRefs, raw_msg_id)
+	if row, ok := mb.rawMessages.get("", raw_msg_id); ok {
+		delete(mb.rawHashes, row.(*RawMessage).Hash)
+		mb.rawMessages.delete("", raw_msg_id)
+	}
+}
+
+// StoreAttachment keeps file under its content's hash,
+// unless a file with the same content is already stored, in which case its uri is returned.
+// Each call takes a reference on the file, released by DeleteAttachment.
+func (mb *MemoryBackend) StoreAttachment(user_id, attachment_id string, file io.Reader) (uri string, size int, err error) {
+	content, err := ioutil.ReadAll(file)
+	if err != nil {
+		return "", 0, err
+	}
+	sum := sha256.Sum256(content)
+	uri = attachmentsURI + hex.EncodeToString(sum[:])
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if _, ok := mb.attachments[uri]; !ok {
+		mb.attachments[uri] = content
+	}
+	mb.attachmentRefs[uri]++
+	return uri, len(content), nil
+}
+
+func (mb *MemoryBackend) GetAttachment(uri string) (file io.Reader, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	content, ok := mb.attachments[uri]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return bytes.NewReader(content), nil
+}
+
+// DeleteAttachment releases a reference on the file at uri, then removes it if no attachment references it anymore.
+func (mb *MemoryBackend) DeleteAttachment(uri string) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	if _, ok := mb.attachments[uri]; !ok {
+		return errors.New("not found")
+	}
+	mb.attachmentRefs[uri]--
+	if mb.attachmentRefs[uri] <= 0 {
+		delete(mb.attachmentRefs, uri)
+		delete(mb.attachments, uri)
+	}
+	return nil
+}
+
+func (mb *MemoryBackend) AttachmentExists(uri string) bool {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	_, ok := mb.attachments[uri]
+	return ok
+}
+
+func (mb *MemoryBackend) GetRemoteContent(key string) (content []byte, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	content, ok := mb.remoteContents[key]
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return content, nil
+}
+
+func (mb *MemoryBackend) PutRemoteContent(key string, content []byte) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.remoteContents[key] = append([]byte(nil), content...)
+	return nil
+}
@@ -0,0 +1,79 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package memory
+
+import (
+	"errors"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/gocql/gocql"
+	"sort"
+	"time"
+)
+
+func (mb *MemoryBackend) CreateIndexMutation(mutation *IndexMutation) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.mutations.set(mutation.UserId.String(), mutation.MutationId.String(), mutation)
+	return nil
+}
+
+func (mb *MemoryBackend) UpdateIndexMutation(mutation *IndexMutation, fields map[string]interface{}) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	userId, mutationId := mutation.UserId.String(), mutation.MutationId.String()
+	row, ok := mb.mutations.get(userId, mutationId)
+	if !ok {
+		return errors.New("not found")
+	}
+	stored := row.(*IndexMutation)
+	if err := updateFields(stored, mutation, fields); err != nil {
+		return err
+	}
+	mb.mutations.set(userId, mutationId, stored)
+	return nil
+}
+
+func (mb *MemoryBackend) DeleteIndexMutation(userId, mutationId string) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.mutations.delete(userId, mutationId)
+	return nil
+}
+
+// RetrieveIndexMutations sends a snapshot of pending mutations, user by user, each user's ones in their time based id order.
+// Chan is closed once all have been sent.
+func (mb *MemoryBackend) RetrieveIndexMutations() (<-chan *IndexMutation, error) {
+	mb.mu.RLock()
+	users := make([]string, 0, len(mb.mutations))
+	for userId := range mb.mutations {
+		users = append(users, userId)
+	}
+	sort.Strings(users)
+	mutations := []*IndexMutation{}
+	for _, userId := range users {
+		rows := mb.mutations.rows(userId)
+		sort.SliceStable(rows, func(i, j int) bool {
+			return mutationTime(rows[i]).Before(mutationTime(rows[j]))
+		})
+		for _, row := range rows {
+			mutations = append(mutations, row.(*IndexMutation))
+		}
+	}
+	mb.mu.RUnlock()
+	ch := make(chan *IndexMutation)
+	go func() {
+		for _, mutation := range mutations {
+			ch <- mutation
+		}
+		close(ch)
+	}()
+	return ch, nil
+}
+
+func mutationTime(row interface{}) time.Time {
+	return gocql.UUID(row.(*IndexMutation).MutationId).Time()
+}
@@ -0,0 +1,300 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package memory
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"strings"
+	"sync"
+)
+
+// MemoryBackend implements the stores of REST API, LDA, notifiers and identities pollers.
+// Rows are kept in tables named and keyed like their Cassandra counterparts.
+type MemoryBackend struct {
+	mu             sync.RWMutex
+	attachments    map[string][]byte // files' content by uri
+	attachmentRefs map[string]int    // references on files, by uri
+	contacts       table             // user_id, contact_id
+	deletions      table             // "", user_id
+	deviceAudits   table             // user_id, audit_id
+	deviceConns    table             // user_id, device_id + date_insert
+	devices        table             // user_id, device_id
+	discussions    table             // user_id, discussion_id
+	exports        table             // user_id, export_id
+	imports        table             // user_id, import_id
+	interactions   table             // user_id, address
+	localIds       table             // "", identifier
+	messages       table             // user_id, message_id
+	mutations      table             // user_id, mutation_id
+	notifications  table             // user_id, notif_id
+	rawHashes      map[string]string // raw_msg_id by content's hash
+	rawLookup      table             // user_id, raw_msg_id : number of references held by user
+	rawMessages    table             // "", raw_msg_id
+	rawRefs        map[string]int    // references on raw messages, by raw_msg_id
+	recoveryEmails map[string]string // user_id by recovery email
+	released       table             // user_id, message_id + ref
+	remoteContents map[string][]byte // remote resources fetched by proxy, by key
+	remoteIds      table             // user_id, identifier
+	savedSearches  table             // user_id, search_id
+	settings       table             // "", user_id
+	tags           table             // user_id, name
+	threads        table             // user_id, external_root_msg_id
+	usernames      map[string]string // user_id by lowercased username
+	users          table             // "", user_id
+}
+
+// InitializeMemoryBackend returns the process-wide memory backend,
+// thus all facilities of a process share the same data.
+func InitializeMemoryBackend() (*MemoryBackend, error) {
+	sharedOnce.Do(initShared)
+	return sharedBackend, nil
+}
+
+// NewMemoryBackend returns a new empty backend, not shared with anyone.
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{
+		attachments:    map[string][]byte{},
+		attachmentRefs: map[string]int{},
+		contacts:       table{},
+		deletions:      table{},
+		deviceAudits:   table{},
+		deviceConns:    table{},
+		devices:        table{},
+		discussions:    table{},
+		exports:        table{},
+		imports:        table{},
+		interactions:   table{},
+		localIds:       table{},
+		messages:       table{},
+		mutations:      table{},
+		notifications:  table{},
+		rawHashes:      map[string]string{},
+		rawLookup:      table{},
+		rawMessages:    table{},
+		rawRefs:        map[string]int{},
+		recoveryEmails: map[string]string{},
+		released:       table{},
+		remoteContents: map[string][]byte{},
+		remoteIds:      table{},
+		savedSearches:  table{},
+		settings:       table{},
+		tags:           table{},
+		threads:        table{},
+		usernames:      map[string]string{},
+		users:          table{},
+	}
+}
+
+func (mb *MemoryBackend) Close() {
+}
+
+// CreateUser adds an user with its username and recovery email lookups.
+// Users are created by python API in production, this is meant to seed the backend for tests and demos.
+func (mb *MemoryBackend) CreateUser(user *User) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	name := strings.ToLower(user.Name)
+	if _, taken := mb.usernames[name]; taken {
+		return fmt.Errorf("[MemoryBackend] username %s already exists", user.Name)
+	}
+	userId := user.UserId.String()
+	mb.users.set("", userId, user)
+	mb.usernames[name] = userId
+	if user.RecoveryEmail != "" {
+		mb.recoveryEmails[user.RecoveryEmail] = userId
+	}
+	return nil
+}
+
+// CreateLocalIdentity adds identity and appends it to its user's local identities
+func (mb *MemoryBackend) CreateLocalIdentity(identity *LocalIdentity) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	userId := identity.User_id.String()
+	row, ok := mb.users.get("", userId)
+	if !ok {
+		return errors.New("[MemoryBackend] user not found")
+	}
+	identifier := strings.ToLower(identity.Identifier)
+	mb.localIds.set("", identifier, identity)
+	user := row.(*User)
+	for _, existing := range user.LocalIdentities {
+		if existing == identifier {
+			return nil
+		}
+	}
+	user.LocalIdentities = append(user.LocalIdentities, identifier)
+	mb.users.set("", userId, user)
+	return nil
+}
+
+// CreateSettings sets user's settings, see CreateUser
+func (mb *MemoryBackend) CreateSettings(settings *Settings) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.settings.set("", settings.UserId.String(), settings)
+	return nil
+}
+
+func (mb *MemoryBackend) GetSettings(user_id string) (settings *Settings, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	row, ok := mb.settings.get("", user_id)
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return row.(*Settings), nil
+}
+
+func (mb *MemoryBackend) RetrieveUser(user_id string) (user *User, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	row, ok := mb.users.get("", user_id)
+	if !ok {
+		return nil, errors.New("[MemoryBackend] user not found")
+	}
+	return row.(*User), nil
+}
+
+func (mb *MemoryBackend) UpdateUser(user *User, fields map[string]interface{}) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	userId := user.UserId.String()
+	row, ok := mb.users.get("", userId)
+	if !ok {
+		return errors.New("[MemoryBackend] user not found")
+	}
+	stored := row.(*User)
+	if err := updateFields(stored, user, fields); err != nil {
+		return err
+	}
+	mb.users.set("", userId, stored)
+	return nil
+}
+
+func (mb *MemoryBackend) UpdateUserPasswordHash(user *User) error {
+	return mb.UpdateUser(user, map[string]interface{}{
+		"Password":        user.Password,
+		"PrivacyFeatures": user.PrivacyFeatures,
+	})
+}
+
+func (mb *MemoryBackend) UserByRecoveryEmail(email string) (user *User, err error) {
+	mb.mu.RLock()
+	userId, ok := mb.recoveryEmails[email]
+	mb.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return mb.RetrieveUser(userId)
+}
+
+func (mb *MemoryBackend) UsernameIsAvailable(username string) (bool, error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	_, taken := mb.usernames[strings.ToLower(username)]
+	return !taken, nil
+}
+
+func (mb *MemoryBackend) UserByUsername(username string) (user *User, err error) {
+	mb.mu.RLock()
+	userId, ok := mb.usernames[strings.ToLower(username)]
+	mb.mu.RUnlock()
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return mb.RetrieveUser(userId)
+}
+
+func (mb *MemoryBackend) GetLocalsIdentities(user_id string) (identities []LocalIdentity, err error) {
+	user, err := mb.RetrieveUser(user_id)
+	if err != nil {
+		return nil, err
+	}
+	if len(user.LocalIdentities) == 0 {
+		return nil, errors.New("[MemoryBackend] : local identities lookup returns empty")
+	}
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	for _, identifier := range user.LocalIdentities {
+		if row, ok := mb.localIds.get("", identifier); ok {
+			identities = append(identities, *(row.(*LocalIdentity)))
+		}
+	}
+	return
+}
+
+// GetUsersForRecipients returns the owner of each recipient's local identity.
+// As for Cassandra, lookup fails if any recipient is unknown.
+func (mb *MemoryBackend) GetUsersForRecipients(rcpts []string) (user_ids []UUID, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	for _, rcpt := range rcpts {
+		row, ok := mb.localIds.get("", strings.ToLower(rcpt))
+		if !ok {
+			return nil, fmt.Errorf("[MemoryBackend] no local identity for recipient %s", rcpt)
+		}
+		user_ids = append(user_ids, row.(*LocalIdentity).User_id)
+	}
+	return
+}
+
+func (mb *MemoryBackend) CreateRemoteIdentity(rId *RemoteIdentity) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.remoteIds.set(rId.UserId.String(), rId.Identifier, rId)
+	return nil
+}
+
+func (mb *MemoryBackend) RetrieveRemoteIdentity(userId, identifier string) (*RemoteIdentity, error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	row, ok := mb.remoteIds.get(userId, identifier)
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return row.(*RemoteIdentity), nil
+}
+
+func (mb *MemoryBackend) UpdateRemoteIdentity(rId *RemoteIdentity, fields map[string]interface{}) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	userId := rId.UserId.String()
+	row, ok := mb.remoteIds.get(userId, rId.Identifier)
+	if !ok {
+		return errors.New("not found")
+	}
+	stored := row.(*RemoteIdentity)
+	if err := updateFields(stored, rId, fields); err != nil {
+		return err
+	}
+	mb.remoteIds.set(userId, rId.Identifier, stored)
+	return nil
+}
+
+// RetrieveAllRemotes sends a snapshot of all remote identities, chan is closed once all have been sent.
+func (mb *MemoryBackend) RetrieveAllRemotes() (<-chan *RemoteIdentity, error) {
+	mb.mu.RLock()
+	remotes := []*RemoteIdentity{}
+	for userId := range mb.remoteIds {
+		for _, row := range mb.remoteIds.rows(userId) {
+			remotes = append(remotes, row.(*RemoteIdentity))
+		}
+	}
+	mb.mu.RUnlock()
+
+	ch := make(chan *RemoteIdentity)
+	go func() {
+		for _, rId := range remotes {
+			ch <- rId
+		}
+		close(ch)
+	}()
+	return ch, nil
+}
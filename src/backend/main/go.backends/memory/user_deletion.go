@@ -0,0 +1,99 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package memory
+
+import (
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"strings"
+)
+
+// RetrieveAllUsersIds sends a snapshot of users' ids, chan is closed once all have been sent.
+func (mb *MemoryBackend) RetrieveAllUsersIds() (<-chan string, error) {
+	mb.mu.RLock()
+	rows := mb.users.rows("")
+	mb.mu.RUnlock()
+	ch := make(chan string)
+	go func() {
+		for _, row := range rows {
+			ch <- row.(*User).UserId.String()
+		}
+		close(ch)
+	}()
+	return ch, nil
+}
+
+// DeleteUserRawMessages releases all references that user still holds on raw messages
+func (mb *MemoryBackend) DeleteUserRawMessages(userId string) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	ids := make([]string, 0, len(mb.rawLookup[userId]))
+	for id := range mb.rawLookup[userId] {
+		ids = append(ids, id)
+	}
+	for _, id := range ids {
+		mb.releaseRawMessage(userId, id, true)
+	}
+	return nil
+}
+
+// DeleteUserArchives removes exports' archives and uploaded imports' archives, along with their export or import.
+func (mb *MemoryBackend) DeleteUserArchives(userId string) error {
+	mb.mu.RLock()
+	exports, imports := mb.exports.rows(userId), mb.imports.rows(userId)
+	mb.mu.RUnlock()
+	for _, row := range exports {
+		export := row.(*UserExport)
+		if export.URI != "" {
+			if err := mb.DeleteAttachment(export.URI); err != nil {
+				return fmt.Errorf("[MemoryBackend] DeleteUserArchives failed to remove archive of export %s : %s", export.ExportId.String(), err)
+			}
+		}
+		mb.DeleteUserExport(userId, export.ExportId.String())
+	}
+	for _, row := range imports {
+		userImport := row.(*UserImport)
+		if strings.Contains(userImport.Source, "://") {
+			mb.DeleteAttachment(userImport.Source)
+		}
+		mb.mu.Lock()
+		mb.imports.delete(userId, userImport.ImportId.String())
+		mb.mu.Unlock()
+	}
+	return nil
+}
+
+// DeleteUserPartitions removes all rows of user, except user itself and its lookups
+func (mb *MemoryBackend) DeleteUserPartitions(userId string) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	for _, t := range []table{mb.contacts, mb.deviceAudits, mb.deviceConns, mb.devices, mb.discussions, mb.exports, mb.imports, mb.interactions, mb.messages,
+		mb.mutations, mb.notifications, mb.rawLookup, mb.released, mb.remoteIds, mb.savedSearches, mb.tags, mb.threads} {
+		delete(t, userId)
+	}
+	mb.settings.delete("", userId)
+	return nil
+}
+
+// DeleteUserAccount removes user's local identities, username and recovery email lookups, then user itself.
+// Once done, username is available again.
+func (mb *MemoryBackend) DeleteUserAccount(user *User) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	userId := user.UserId.String()
+	for _, identifier := range user.LocalIdentities {
+		mb.localIds.delete("", strings.ToLower(identifier))
+	}
+	if name := strings.ToLower(user.Name); mb.usernames[name] == userId {
+		delete(mb.usernames, name)
+	}
+	if mb.recoveryEmails[user.RecoveryEmail] == userId {
+		delete(mb.recoveryEmails, user.RecoveryEmail)
+	}
+	mb.users.delete("", userId)
+	return nil
+}
@@ -0,0 +1,422 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package memory
+
+import (
+	"errors"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/gocql/gocql"
+	"sort"
+	"time"
+)
+
+// tags
+
+func (mb *MemoryBackend) RetrieveUserTags(user_id string) (tags []Tag, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	rows := mb.tags.rows(user_id)
+	if len(rows) == 0 {
+		return nil, errors.New("tags not found")
+	}
+	for _, row := range rows {
+		tags = append(tags, *(row.(*Tag)))
+	}
+	return
+}
+
+func (mb *MemoryBackend) CreateTag(tag *Tag) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	tag.Date_insert = time.Now()
+	tag.Type = TagType(UserTag)
+	mb.tags.set(tag.User_id.String(), tag.Name, tag)
+	return nil
+}
+
+func (mb *MemoryBackend) RetrieveTag(user_id, tag_id string) (tag Tag, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	row, ok := mb.tags.get(user_id, tag_id)
+	if !ok {
+		return Tag{}, errors.New("tag not found")
+	}
+	return *(row.(*Tag)), nil
+}
+
+// UpdateTag updates the same columns as Cassandra's backend, date_insert is kept
+func (mb *MemoryBackend) UpdateTag(tag *Tag) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	userId := tag.User_id.String()
+	stored := &Tag{User_id: tag.User_id, Name: tag.Name}
+	if row, ok := mb.tags.get(userId, tag.Name); ok {
+		stored = row.(*Tag)
+	}
+	stored.Expiry_days = tag.Expiry_days
+	stored.Importance_level = tag.Importance_level
+	stored.Label = tag.Label
+	stored.Type = tag.Type
+	mb.tags.set(userId, tag.Name, stored)
+	return nil
+}
+
+func (mb *MemoryBackend) DeleteTag(user_id, tag_id string) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.tags.delete(user_id, tag_id)
+	return nil
+}
+
+// devices
+
+func (mb *MemoryBackend) CreateDevice(device *Device) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.devices.set(device.UserId.String(), device.DeviceId.String(), device)
+	return nil
+}
+
+func (mb *MemoryBackend) RetrieveDevices(user_id string) (devices []Device, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	rows := mb.devices.rows(user_id)
+	if len(rows) == 0 {
+		return nil, errors.New("devices not found")
+	}
+	for _, row := range rows {
+		devices = append(devices, *(row.(*Device)))
+	}
+	return
+}
+
+func (mb *MemoryBackend) RetrieveDevice(userId, deviceId string) (device *Device, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	row, ok := mb.devices.get(userId, deviceId)
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return row.(*Device), nil
+}
+
+func (mb *MemoryBackend) UpdateDevice(device, oldDevice *Device, modifiedFields map[string]interface{}) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	userId, deviceId := device.UserId.String(), device.DeviceId.String()
+	row, ok := mb.devices.get(userId, deviceId)
+	if !ok {
+		return errors.New("not found")
+	}
+	stored := row.(*Device)
+	if err := updateFields(stored, device, modifiedFields); err != nil {
+		return err
+	}
+	mb.devices.set(userId, deviceId, stored)
+	return nil
+}
+
+func (mb *MemoryBackend) DeleteDevice(device *Device) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.devices.delete(device.UserId.String(), device.DeviceId.String())
+	return nil
+}
+
+func (mb *MemoryBackend) CreateDeviceAudit(audit *DeviceAudit) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.deviceAudits.set(audit.UserId.String(), audit.AuditId.String(), audit)
+	return nil
+}
+
+// RetrieveDeviceAudits returns user's devices audit, most recent first
+func (mb *MemoryBackend) RetrieveDeviceAudits(userId string) ([]DeviceAudit, error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	audits := []DeviceAudit{}
+	for _, row := range mb.deviceAudits.rows(userId) {
+		audits = append(audits, *(row.(*DeviceAudit)))
+	}
+	sort.SliceStable(audits, func(i, j int) bool {
+		return audits[i].DateInsert.After(audits[j].DateInsert)
+	})
+	return audits, nil
+}
+
+// CreateDeviceLocation adds location to device's locations, or replaces the one with the same address
+func (mb *MemoryBackend) CreateDeviceLocation(location *DeviceLocation) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	userId, deviceId := location.UserId.String(), location.DeviceId.String()
+	row, ok := mb.devices.get(userId, deviceId)
+	if !ok {
+		return errors.New("not found")
+	}
+	device := row.(*Device)
+	locations := DeviceLocations{*location}
+	for _, l := range device.Locations {
+		if l.IpAddress != location.IpAddress {
+			locations = append(locations, l)
+		}
+	}
+	device.Locations = locations
+	device.SortSlices()
+	mb.devices.set(userId, deviceId, device)
+	return nil
+}
+
+func (mb *MemoryBackend) CreateDeviceConnection(connection *DeviceConnection) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	key := connection.DeviceId.String() + connection.DateInsert.Format(time.RFC3339Nano)
+	mb.deviceConns.set(connection.UserId.String(), key, connection)
+	return nil
+}
+
+// saved searches
+
+func (mb *MemoryBackend) CreateSavedSearch(search *SavedSearch) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.savedSearches.set(search.UserId.String(), search.SearchId.String(), search)
+	return nil
+}
+
+func (mb *MemoryBackend) RetrieveSavedSearches(userId string) (searches []SavedSearch, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	rows := mb.savedSearches.rows(userId)
+	if len(rows) == 0 {
+		return nil, errors.New("saved searches not found")
+	}
+	for _, row := range rows {
+		searches = append(searches, *(row.(*SavedSearch)))
+	}
+	return
+}
+
+func (mb *MemoryBackend) RetrieveSavedSearch(userId, searchId string) (search *SavedSearch, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	row, ok := mb.savedSearches.get(userId, searchId)
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return row.(*SavedSearch), nil
+}
+
+func (mb *MemoryBackend) UpdateSavedSearch(search *SavedSearch, modifiedFields map[string]interface{}) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	userId, searchId := search.UserId.String(), search.SearchId.String()
+	row, ok := mb.savedSearches.get(userId, searchId)
+	if !ok {
+		return errors.New("not found")
+	}
+	stored := row.(*SavedSearch)
+	if err := updateFields(stored, search, modifiedFields); err != nil {
+		return err
+	}
+	mb.savedSearches.set(userId, searchId, stored)
+	return nil
+}
+
+func (mb *MemoryBackend) DeleteSavedSearch(userId, searchId string) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.savedSearches.delete(userId, searchId)
+	return nil
+}
+
+// exports
+
+func (mb *MemoryBackend) CreateUserExport(export *UserExport) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.exports.set(export.UserId.String(), export.ExportId.String(), export)
+	return nil
+}
+
+func (mb *MemoryBackend) RetrieveUserExport(userId, exportId string) (export *UserExport, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	row, ok := mb.exports.get(userId, exportId)
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return row.(*UserExport), nil
+}
+
+func (mb *MemoryBackend) UpdateUserExport(export *UserExport, modifiedFields map[string]interface{}) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	userId, exportId := export.UserId.String(), export.ExportId.String()
+	row, ok := mb.exports.get(userId, exportId)
+	if !ok {
+		return errors.New("not found")
+	}
+	stored := row.(*UserExport)
+	if err := updateFields(stored, export, modifiedFields); err != nil {
+		return err
+	}
+	mb.exports.set(userId, exportId, stored)
+	return nil
+}
+
+func (mb *MemoryBackend) DeleteUserExport(userId, exportId string) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.exports.delete(userId, exportId)
+	return nil
+}
+
+// imports
+
+func (mb *MemoryBackend) CreateUserImport(userImport *UserImport) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.imports.set(userImport.UserId.String(), userImport.ImportId.String(), userImport)
+	return nil
+}
+
+func (mb *MemoryBackend) RetrieveUserImport(userId, importId string) (userImport *UserImport, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	row, ok := mb.imports.get(userId, importId)
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return row.(*UserImport), nil
+}
+
+func (mb *MemoryBackend) UpdateUserImport(userImport *UserImport, modifiedFields map[string]interface{}) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	userId, importId := userImport.UserId.String(), userImport.ImportId.String()
+	row, ok := mb.imports.get(userId, importId)
+	if !ok {
+		return errors.New("not found")
+	}
+	stored := row.(*UserImport)
+	if err := updateFields(stored, userImport, modifiedFields); err != nil {
+		return err
+	}
+	mb.imports.set(userId, importId, stored)
+	return nil
+}
+
+// accounts' deletions
+
+func (mb *MemoryBackend) CreateUserDeletion(deletion *UserDeletion) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.deletions.set("", deletion.UserId.String(), deletion)
+	return nil
+}
+
+func (mb *MemoryBackend) RetrieveUserDeletion(userId string) (deletion *UserDeletion, err error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	row, ok := mb.deletions.get("", userId)
+	if !ok {
+		return nil, errors.New("not found")
+	}
+	return row.(*UserDeletion), nil
+}
+
+func (mb *MemoryBackend) UpdateUserDeletion(deletion *UserDeletion, modifiedFields map[string]interface{}) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	userId := deletion.UserId.String()
+	row, ok := mb.deletions.get("", userId)
+	if !ok {
+		return errors.New("not found")
+	}
+	stored := row.(*UserDeletion)
+	if err := updateFields(stored, deletion, modifiedFields); err != nil {
+		return err
+	}
+	mb.deletions.set("", userId, stored)
+	return nil
+}
+
+func (mb *MemoryBackend) DeleteUserDeletion(userId string) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	mb.deletions.delete("", userId)
+	return nil
+}
+
+// RetrieveUserDeletions sends a snapshot of all deletions, chan is closed once all have been sent.
+func (mb *MemoryBackend) RetrieveUserDeletions() (<-chan *UserDeletion, error) {
+	mb.mu.RLock()
+	rows := mb.deletions.rows("")
+	mb.mu.RUnlock()
+	ch := make(chan *UserDeletion)
+	go func() {
+		for _, row := range rows {
+			ch <- row.(*UserDeletion)
+		}
+		close(ch)
+	}()
+	return ch, nil
+}
+
+// notifications
+
+// PutNotificationInQueue keeps notification until it is deleted, notifications' TTL are not enforced.
+func (mb *MemoryBackend) PutNotificationInQueue(notif *Notification) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	// like for Cassandra, only user's id is saved and internal payload is left out
+	stored := *notif
+	stored.InternalPayload = nil
+	stored.User = &User{UserId: notif.User.UserId}
+	mb.notifications.set(notif.User.UserId.String(), notif.NotifId.String(), &stored)
+	return nil
+}
+
+// RetrieveNotifications returns user's notifications issued between from and to (if not zero), oldest first
+func (mb *MemoryBackend) RetrieveNotifications(userId string, from, to time.Time) ([]Notification, error) {
+	mb.mu.RLock()
+	defer mb.mu.RUnlock()
+	notifs := []Notification{}
+	for _, row := range mb.notifications.rows(userId) {
+		notif := row.(*Notification)
+		at := notifTime(notif)
+		if (!from.IsZero() && at.Before(from)) || (!to.IsZero() && at.After(to)) {
+			continue
+		}
+		notifs = append(notifs, *notif)
+	}
+	if len(notifs) == 0 {
+		return notifs, errors.New("notifications not found")
+	}
+	sort.SliceStable(notifs, func(i, j int) bool {
+		return notifTime(&notifs[i]).Before(notifTime(&notifs[j]))
+	})
+	return notifs, nil
+}
+
+// DeleteNotifications deletes user's notifications issued before until, or all of them if until is zero
+func (mb *MemoryBackend) DeleteNotifications(userId string, until time.Time) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+	for _, row := range mb.notifications.rows(userId) {
+		notif := row.(*Notification)
+		if until.IsZero() || notifTime(notif).Before(until) {
+			mb.notifications.delete(userId, notif.NotifId.String())
+		}
+	}
+	return nil
+}
+
+// notifTime returns the timestamp of notification's time based uuid
+func notifTime(notif *Notification) time.Time {
+	return gocql.UUID(notif.NotifId).Time()
+}
@@ -4,25 +4,319 @@
 
 package store
 
-import "io"
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/kms"
+	"github.com/gocql/gocql"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"path"
+)
 
-func (cb *CassandraBackend) StoreAttachment(attachment_id string, file io.Reader) (uri string, size int, err error) {
-	uri, s, err := cb.ObjectsStore.PutAttachment(attachment_id, file)
-	return uri, int(s), err
+// attachment_blob entries are updated with lightweight transactions, which are retried on contention
+// at most blobAttempts times.
+const blobAttempts = 20
+
+var errBlobContention = errors.New("[CassandraBackend] too many concurrent updates of attachment's file")
+
+// StoreAttachment puts file into objects store, unless a file with the same content is already stored,
+// and returns an uri named after content's hash.
+// File is read once : it is hashed, sealed if encryption is enabled, and uploaded under a temporary name
+// on the fly. Uploaded object is then registered in attachment_blob table under content's hash,
+// or removed if a file with the same content is already registered.
+// Each call takes a reference on the file, released by DeleteAttachment.
+// attachment_id is kept for interface compatibility, files are named after their content.
+// If encryption is enabled, file is sealed with a content key wrapped by the data key of its first owner.
+func (cb *CassandraBackend) StoreAttachment(user_id, attachment_id string, file io.Reader) (uri string, size int, err error) {
+	var owner interface{}
+	var version int
+	var wrapped, contentKey []byte
+	if cb.KMS != nil {
+		var userKey []byte
+		if version, userKey, err = cb.currentUserDataKey(user_id); err != nil {
+			return "", 0, err
+		}
+		if contentKey, err = kms.NewDataKey(); err != nil {
+			return "", 0, err
+		}
+		if wrapped, err = kms.Seal(userKey, contentKey); err != nil {
+			return "", 0, err
+		}
+		owner = user_id
+	}
+
+	hash := sha256.New()
+	counter := new(byteCounter)
+	content := io.TeeReader(file, io.MultiWriter(hash, counter))
+	if contentKey != nil {
+		if content, err = kms.NewSealingReader(contentKey, content); err != nil {
+			return "", 0, err
+		}
+	}
+	object, _, err := cb.ObjectsStore.PutAttachment("upload-"+gocql.TimeUUID().String(), content)
+	if err != nil {
+		return "", 0, err
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	size = int(counter.n)
+	if uri, err = siblingURI(object, sum); err != nil {
+		cb.ObjectsStore.RemoveObject(object)
+		return "", 0, err
+	}
+
+	for attempt := 0; attempt < blobAttempts; attempt++ {
+		m := map[string]interface{}{}
+		applied, err := cb.Session.Query(`INSERT INTO attachment_blob (hash, uri, size, refs, key_owner, key_version, wrapped_key, stream_sealed) VALUES (?, ?, ?, 1, ?, ?, ?, ?) IF NOT EXISTS`,
+			sum, object, size, owner, version, wrapped, contentKey != nil).MapScanCAS(m)
+		if err != nil {
+			cb.ObjectsStore.RemoveObject(object)
+			return "", 0, err
+		}
+		if applied {
+			return uri, size, nil
+		}
+
+		// same content already registered, take a reference on its file
+		refs, err := cb.blobRefs(sum)
+		if err == gocql.ErrNotFound {
+			// entry removed meanwhile
+			continue
+		}
+		if err != nil {
+			cb.ObjectsStore.RemoveObject(object)
+			return "", 0, err
+		}
+		applied, err = cb.Session.Query(`UPDATE attachment_blob SET refs = ? WHERE hash = ? IF refs = ?`,
+			refs+1, sum, refs).MapScanCAS(map[string]interface{}{})
+		if err != nil {
+			cb.ObjectsStore.RemoveObject(object)
+			return "", 0, err
+		}
+		if !applied {
+			// entry updated or removed meanwhile
+			continue
+		}
+		var previous interface{} // null for files stored before uploads under temporary names
+		existing, _ := m["uri"].(string)
+		if existing != "" {
+			previous = existing
+		} else {
+			existing = uri
+		}
+		if cb.objectExists(existing) {
+			return uri, size, cb.ObjectsStore.RemoveObject(object)
+		}
+		// registered file has been lost, uploaded object replaces it
+		applied, err = cb.Session.Query(`UPDATE attachment_blob SET uri = ?, key_owner = ?, key_version = ?, wrapped_key = ?, stream_sealed = ? WHERE hash = ? IF uri = ?`,
+			object, owner, version, wrapped, contentKey != nil, sum, previous).MapScanCAS(map[string]interface{}{})
+		if err != nil || !applied {
+			// replaced meanwhile by a concurrent call
+			cb.ObjectsStore.RemoveObject(object)
+		}
+		if err != nil {
+			cb.releaseAttachmentRef(sum)
+			return "", 0, err
+		}
+		return uri, size, nil
+	}
+	cb.ObjectsStore.RemoveObject(object)
+	return "", 0, errBlobContention
+}
+
+// blobRefs returns the number of references on the file of hash.
+// Entries stored before refs column was added are initialized from the former attachment_refs counter.
+func (cb *CassandraBackend) blobRefs(hash string) (int, error) {
+	var refs *int
+	err := cb.Session.Query(`SELECT refs FROM attachment_blob WHERE hash = ?`, hash).Scan(&refs)
+	if err != nil || refs != nil {
+		if refs == nil {
+			return 0, err
+		}
+		return *refs, err
+	}
+	var counter int64
+	err = cb.Session.Query(`SELECT refs FROM attachment_refs WHERE hash = ?`, hash).Scan(&counter)
+	if err != nil && err != gocql.ErrNotFound {
+		return 0, err
+	}
+	applied, err := cb.Session.Query(`UPDATE attachment_blob SET refs = ? WHERE hash = ? IF refs = null`,
+		int(counter), hash).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return 0, err
+	}
+	if !applied {
+		// initialized meanwhile
+		return cb.blobRefs(hash)
+	}
+	return int(counter), nil
 }
 
+// DeleteAttachment releases a reference on the file at uri,
+// then removes it from objects store if no attachment references it anymore.
 func (cb *CassandraBackend) DeleteAttachment(uri string) error {
-	return cb.ObjectsStore.RemoveObject(uri)
+	hash, ok := attachmentHash(uri)
+	if !ok {
+		// file stored before deduplication, it is not shared
+		return cb.ObjectsStore.RemoveObject(uri)
+	}
+	if err := cb.releaseAttachmentRef(hash); err != nil {
+		return err
+	}
+	return cb.removeUnreferencedAttachment(hash, uri)
 }
 
+// removeUnreferencedAttachment removes the file designated by uri and its entry in attachment_blob table,
+// unless some attachment still references it. Removing an already removed file is harmless.
+// Entry is deleted on condition that no reference has been taken meanwhile : a concurrent StoreAttachment
+// either takes its reference before and the file is kept, or finds no entry and registers its own upload.
+func (cb *CassandraBackend) removeUnreferencedAttachment(hash, uri string) error {
+	var object *string
+	var owner gocql.UUID
+	err := cb.Session.Query(`SELECT uri, key_owner FROM attachment_blob WHERE hash = ?`, hash).Scan(&object, &owner)
+	if err == gocql.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	// uri is checked too, in case a lost file has been replaced meanwhile
+	applied, err := cb.Session.Query(`DELETE FROM attachment_blob WHERE hash = ? IF refs = 0 AND uri = ?`,
+		hash, object).MapScanCAS(map[string]interface{}{})
+	if err != nil || !applied {
+		return err
+	}
+	if object != nil && *object != "" {
+		uri = *object
+	}
+	if err = cb.ObjectsStore.RemoveObject(uri); err != nil {
+		return err
+	}
+	cb.contentRemoved(owner)
+	return nil
+}
+
+// releaseAttachmentRef decrements the number of references on the file of hash, if any is left.
+func (cb *CassandraBackend) releaseAttachmentRef(hash string) error {
+	for attempt := 0; attempt < blobAttempts; attempt++ {
+		refs, err := cb.blobRefs(hash)
+		if err == gocql.ErrNotFound {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if refs <= 0 {
+			return nil
+		}
+		applied, err := cb.Session.Query(`UPDATE attachment_blob SET refs = ? WHERE hash = ? IF refs = ?`,
+			refs-1, hash, refs).MapScanCAS(map[string]interface{}{})
+		if err != nil || applied {
+			return err
+		}
+	}
+	return errBlobContention
+}
+
+// attachmentHash returns the content's hash that names the file at uri, if any.
+func attachmentHash(uri string) (hash string, ok bool) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", false
+	}
+	name := path.Base(u.Path)
+	if len(name) != sha256.Size*2 {
+		return "", false
+	}
+	if _, err := hex.DecodeString(name); err != nil {
+		return "", false
+	}
+	return name, true
+}
+
+// siblingURI returns the uri of the object named name, in the same bucket as the object at uri.
+func siblingURI(uri, name string) (string, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", err
+	}
+	u.Path = path.Join(path.Dir(u.Path), name)
+	return u.String(), nil
+}
+
+// GetAttachment returns file's content at uri, decrypted if needed.
 func (cb *CassandraBackend) GetAttachment(uri string) (file io.Reader, err error) {
-	return cb.ObjectsStore.GetObject(uri)
+	hash, ok := attachmentHash(uri)
+	if !ok {
+		return cb.ObjectsStore.GetObject(uri)
+	}
+	var object string
+	var owner gocql.UUID
+	var version int
+	var wrapped []byte
+	var streamSealed bool
+	err = cb.Session.Query(`SELECT uri, key_owner, key_version, wrapped_key, stream_sealed FROM attachment_blob WHERE hash = ?`,
+		hash).Scan(&object, &owner, &version, &wrapped, &streamSealed)
+	if err != nil && err != gocql.ErrNotFound {
+		return nil, err
+	}
+	if object == "" {
+		object = uri
+	}
+	file, err = cb.ObjectsStore.GetObject(object)
+	if err != nil || len(wrapped) == 0 {
+		return file, err
+	}
+	contentKey, err := cb.unwrapContentKey(owner.String(), version, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if streamSealed {
+		return kms.NewOpeningReader(contentKey, file)
+	}
+	sealed, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := kms.Open(contentKey, sealed)
+	if err != nil {
+		return nil, err
+	}
+	return bytes.NewReader(plain), nil
 }
 
+// AttachmentExists returns true if the file designated by uri is in objects store.
 func (cb *CassandraBackend) AttachmentExists(uri string) bool {
+	if hash, ok := attachmentHash(uri); ok {
+		var object string
+		err := cb.Session.Query(`SELECT uri FROM attachment_blob WHERE hash = ?`, hash).Scan(&object)
+		if err != nil && err != gocql.ErrNotFound {
+			return false
+		}
+		if object != "" {
+			uri = object
+		}
+	}
+	return cb.objectExists(uri)
+}
+
+func (cb *CassandraBackend) objectExists(uri string) bool {
 	info, err := cb.ObjectsStore.StatObject(uri)
 	if err == nil && info.Err == nil {
 		return true
 	}
 	return false
 }
+
+// byteCounter counts the bytes written to it.
+type byteCounter struct {
+	n int64
+}
+
+func (bc *byteCounter) Write(p []byte) (int, error) {
+	bc.n += int64(len(p))
+	return len(p), nil
+}
@@ -0,0 +1,40 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package store
+
+import "testing"
+
+func TestAttachmentHash(t *testing.T) {
+	const sum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	cases := map[string]bool{
+		"s3://tmp-attachments/" + sum:                         true,
+		"fs://tmp-attachments/" + sum:                         true,
+		"s3://tmp-attachments/0d5e0cda-03a1-4b4a-8b0e-4c1ed8": false,
+		"s3://tmp-attachments/" + sum[:63] + "z":              false,
+		"":                                                    false,
+	}
+	for uri, expected := range cases {
+		hash, ok := attachmentHash(uri)
+		if ok != expected {
+			t.Errorf("attachmentHash(%q) : expected %v, got %v", uri, expected, ok)
+		}
+		if ok && hash != sum {
+			t.Errorf("attachmentHash(%q) : unexpected hash %s", uri, hash)
+		}
+	}
+}
+
+func TestSiblingURI(t *testing.T) {
+	const sum = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	cases := map[string]string{
+		"s3://tmp-attachments/upload-0d5e0cda": "s3://tmp-attachments/" + sum,
+		"fs://tmp-attachments/upload-0d5e0cda": "fs://tmp-attachments/" + sum,
+	}
+	for uri, expected := range cases {
+		if sibling, err := siblingURI(uri, sum); err != nil || sibling != expected {
+			t.Errorf("siblingURI(%q) : expected %s, got %s (%v)", uri, expected, sibling, err)
+		}
+	}
+}
@@ -5,6 +5,7 @@
 package store
 
 import (
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/kms"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/object_store"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gocassa/gocassa"
@@ -18,16 +19,19 @@ type (
 		Session      *gocql.Session
 		IKeyspace    gocassa.KeySpace //gocassa keyspace interface
 		ObjectsStore object_store.ObjectsStore
+		KMS          kms.KeyManagementService // nil if contents are stored in clear
 		Timeout      time.Duration
 	}
 
 	CassandraConfig struct {
-		Hosts        []string          `mapstructure:"hosts"`
-		Keyspace     string            `mapstructure:"keyspace"`
-		Consistency  gocql.Consistency `mapstructure:"consistency_level"`
-		SizeLimit    uint64            `mapstructure:"raw_size_limit"` // max size to store (in bytes)
-		WithObjStore bool              // whether to use an objects store service for objects above SizeLimit
+		Hosts          []string          `mapstructure:"hosts"`
+		Keyspace       string            `mapstructure:"keyspace"`
+		Consistency    gocql.Consistency `mapstructure:"consistency_level"`
+		SizeLimit      uint64            `mapstructure:"raw_size_limit"` // max size to store (in bytes)
+		WithObjStore   bool              // whether to use an objects store service for objects above SizeLimit
+		WithEncryption bool              // whether to encrypt raw messages and attachments, see encryption.go
 		object_store.OSSConfig
+		kms.KMSConfig
 	}
 
 	HasTable interface {
@@ -68,6 +72,10 @@ func (cb *CassandraBackend) initialize(config CassandraConfig) (err error) {
 	if err != nil {
 		return
 	}
+	if err = checkSchemaVersion(cb.Session, cb.Keyspace); err != nil {
+		cb.Session.Close()
+		return
+	}
 	connection := gocassa.NewConnection(gocassa.GoCQLSessionToQueryExecutor(cb.Session))
 	cb.IKeyspace = connection.KeySpace(cb.Keyspace)
 
@@ -78,6 +86,13 @@ func (cb *CassandraBackend) initialize(config CassandraConfig) (err error) {
 			return err
 		}
 	}
+	if config.WithEncryption {
+		cb.KMS, err = kms.InitializeKMS(config.KMSConfig)
+		if err != nil {
+			log.WithError(err).Warn("KMS initialization failed.")
+			return err
+		}
+	}
 	return
 }
 
@@ -62,6 +62,11 @@ func (cb *CassandraBackend) RetrieveContact(user_id, contact_id string) (contact
 	if err != nil {
 		log.WithError(err).Error("[CassandraBackend] RetrieveContact: failed to retrieve related.")
 	}
+	if keys, e := cb.retrievePublicKeys(user_id, contact_id); e == nil {
+		contact.PublicKeys = keys
+	} else {
+		log.WithError(e).Error("[CassandraBackend] RetrieveContact: failed to retrieve public keys.")
+	}
 
 	return contact, err
 }
@@ -98,6 +98,10 @@ func (cb *CassandraBackend) RetrieveDevice(userId, deviceId string) (device *Dev
 	if err != nil {
 		log.WithError(err).Error("[CassandraBackend] RetrieveDevice: failed to retrieve related.")
 	}
+	device.PublicKeys, err = cb.retrievePublicKeys(userId, deviceId)
+	if err != nil {
+		log.WithError(err).Error("[CassandraBackend] RetrieveDevice: failed to retrieve public keys.")
+	}
 
 	return device, nil
 }
@@ -172,3 +176,50 @@ func (cb *CassandraBackend) DeleteDevice(device *Device) error {
 	***/
 	return nil
 }
+
+func (cb *CassandraBackend) CreateDeviceAudit(audit *DeviceAudit) error {
+	auditT := cb.IKeyspace.Table("device_audit", &DeviceAudit{}, gocassa.Keys{
+		PartitionKeys:     []string{"user_id"},
+		ClusteringColumns: []string{"audit_id"},
+	}).WithOptions(gocassa.Options{TableName: "device_audit"}) // need to overwrite default gocassa table naming convention
+
+	err := auditT.Set(audit).Run()
+	if err != nil {
+		return fmt.Errorf("[CassandraBackend] CreateDeviceAudit: %s", err)
+	}
+	return nil
+}
+
+// RetrieveDeviceAudits returns user's devices audit, most recent first (see table's clustering order)
+func (cb *CassandraBackend) RetrieveDeviceAudits(userId string) (audits []DeviceAudit, err error) {
+	rows, err := cb.Session.Query(`SELECT * FROM device_audit WHERE user_id = ?`, userId).Iter().SliceMap()
+	if err != nil {
+		return nil, err
+	}
+	audits = []DeviceAudit{}
+	for _, row := range rows {
+		audit := new(DeviceAudit)
+		audit.UnmarshalCQLMap(row)
+		audits = append(audits, *audit)
+	}
+	return audits, nil
+}
+
+// CreateDeviceLocation adds location to device's locations, or overwrites the one with the same address
+func (cb *CassandraBackend) CreateDeviceLocation(location *DeviceLocation) error {
+	err := cb.Session.Query(`INSERT INTO device_location (user_id, device_id, address, type, country, asn) VALUES (?, ?, ?, ?, ?, ?)`,
+		location.UserId.String(), location.DeviceId.String(), location.IpAddress, location.Type, location.Country, location.Asn).Exec()
+	if err != nil {
+		return fmt.Errorf("[CassandraBackend] CreateDeviceLocation: %s", err)
+	}
+	return nil
+}
+
+func (cb *CassandraBackend) CreateDeviceConnection(connection *DeviceConnection) error {
+	err := cb.Session.Query(`INSERT INTO device_connection_log (user_id, device_id, date_insert, ip_address, type, country, asn) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		connection.UserId.String(), connection.DeviceId.String(), connection.DateInsert, connection.IpAddress, connection.Type, connection.Country, connection.Asn).Exec()
+	if err != nil {
+		return fmt.Errorf("[CassandraBackend] CreateDeviceConnection: %s", err)
+	}
+	return nil
+}
@@ -6,6 +6,8 @@ package store
 
 import (
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/gocql/gocql"
+	"time"
 )
 
 // CreateThreadLookup inserts a new entry into discussion_thread_lookup table
@@ -15,3 +17,23 @@ func (cb *CassandraBackend) CreateThreadLookup(user_id, discussion_id UUID, exte
 		external_msg_id,
 		discussion_id.String()).Exec()
 }
+
+// RaiseDiscussionImportanceLevel sets discussion's importance level to level if it is higher than current one :
+// a discussion is as important as its most important message. Discussion row is created if missing.
+func (cb *CassandraBackend) RaiseDiscussionImportanceLevel(user_id, discussion_id string, level int32) error {
+	var current *int
+	err := cb.Session.Query(`SELECT importance_level FROM discussion WHERE user_id = ? AND discussion_id = ?`,
+		user_id, discussion_id).Scan(&current)
+	switch err {
+	case nil:
+		if current != nil && int32(*current) >= level {
+			return nil
+		}
+		return cb.Session.Query(`UPDATE discussion SET importance_level = ? WHERE user_id = ? AND discussion_id = ?`,
+			level, user_id, discussion_id).Exec()
+	case gocql.ErrNotFound:
+		return cb.Session.Query(`INSERT INTO discussion (user_id, discussion_id, date_insert, importance_level) VALUES (?,?,?,?)`,
+			user_id, discussion_id, time.Now(), level).Exec()
+	}
+	return err
+}
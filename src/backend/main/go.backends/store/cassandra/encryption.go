@@ -0,0 +1,339 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	obj "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/kms"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gocql/gocql"
+	"strings"
+	"time"
+)
+
+// Envelope encryption of raw messages and attachments' files :
+//   - each content is sealed with its own random content key,
+//   - content key is wrapped with the data key of the user who first stored the content (the key owner),
+//     and saved along with content's metadata (raw_message and attachment_blob tables),
+//   - users' data keys are wrapped by a master key of the KMS, and saved into user_data_key table.
+// Contents shared by several users (see StoreRawMessage and StoreAttachment) keep the key of their first owner.
+
+// sealContent encrypts content with a new content key wrapped with user's current data key.
+func (cb *CassandraBackend) sealContent(user_id string, content []byte) (sealed []byte, keyVersion int, wrappedKey []byte, err error) {
+	keyVersion, userKey, err := cb.currentUserDataKey(user_id)
+	if err != nil {
+		return
+	}
+	contentKey, err := kms.NewDataKey()
+	if err != nil {
+		return
+	}
+	if wrappedKey, err = kms.Seal(userKey, contentKey); err != nil {
+		return
+	}
+	sealed, err = kms.Seal(contentKey, content)
+	return
+}
+
+// openContent decrypts content sealed by sealContent.
+func (cb *CassandraBackend) openContent(keyOwner string, keyVersion int, wrappedKey, sealed []byte) ([]byte, error) {
+	contentKey, err := cb.unwrapContentKey(keyOwner, keyVersion, wrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	return kms.Open(contentKey, sealed)
+}
+
+func (cb *CassandraBackend) unwrapContentKey(keyOwner string, keyVersion int, wrappedKey []byte) ([]byte, error) {
+	if cb.KMS == nil {
+		return nil, errors.New("[CassandraBackend] content is encrypted but encryption is not configured")
+	}
+	userKey, err := cb.userDataKey(keyOwner, keyVersion)
+	if err != nil {
+		return nil, err
+	}
+	return kms.Open(userKey, wrappedKey)
+}
+
+// currentUserDataKey returns the latest version of user's data key, creating the first one if needed.
+func (cb *CassandraBackend) currentUserDataKey(user_id string) (version int, key []byte, err error) {
+	var wrapped []byte
+	var masterKeyId string
+	err = cb.Session.Query(`SELECT version, wrapped_key, master_key_id FROM user_data_key WHERE user_id = ? LIMIT 1`,
+		user_id).Scan(&version, &wrapped, &masterKeyId)
+	if err == gocql.ErrNotFound {
+		version = 1
+		key, err = cb.createUserDataKey(user_id, version)
+		if err != errKeyExists {
+			return
+		}
+		// created meanwhile by another process
+		err = cb.Session.Query(`SELECT version, wrapped_key, master_key_id FROM user_data_key WHERE user_id = ? LIMIT 1`,
+			user_id).Scan(&version, &wrapped, &masterKeyId)
+	}
+	if err != nil {
+		return 0, nil, err
+	}
+	key, err = cb.KMS.UnwrapKey(wrapped, masterKeyId)
+	return
+}
+
+// userDataKey returns the given version of user's data key.
+func (cb *CassandraBackend) userDataKey(user_id string, version int) (key []byte, err error) {
+	var wrapped []byte
+	var masterKeyId string
+	err = cb.Session.Query(`SELECT wrapped_key, master_key_id FROM user_data_key WHERE user_id = ? AND version = ?`,
+		user_id, version).Scan(&wrapped, &masterKeyId)
+	if err != nil {
+		return nil, fmt.Errorf("[CassandraBackend] failed to retrieve data key version %d for user %s : %s", version, user_id, err)
+	}
+	return cb.KMS.UnwrapKey(wrapped, masterKeyId)
+}
+
+var errKeyExists = errors.New("[CassandraBackend] user data key already exists")
+
+func (cb *CassandraBackend) createUserDataKey(user_id string, version int) (key []byte, err error) {
+	key, err = kms.NewDataKey()
+	if err != nil {
+		return nil, err
+	}
+	wrapped, masterKeyId, err := cb.KMS.WrapKey(key)
+	if err != nil {
+		return nil, err
+	}
+	applied, err := cb.Session.Query(`INSERT INTO user_data_key (user_id, version, wrapped_key, master_key_id, date_insert) VALUES (?, ?, ?, ?, ?) IF NOT EXISTS`,
+		user_id, version, wrapped, masterKeyId, time.Now()).MapScanCAS(map[string]interface{}{})
+	if err != nil {
+		return nil, err
+	}
+	if !applied {
+		return nil, errKeyExists
+	}
+	return key, nil
+}
+
+// RewrapUserDataKeys wraps again with the current master key all users' data keys wrapped by a former master key.
+// It must be run after a master key rotation, before the former master key is removed from the KMS.
+func (cb *CassandraBackend) RewrapUserDataKeys() (count int, err error) {
+	if cb.KMS == nil {
+		return 0, errors.New("[CassandraBackend] encryption is not configured")
+	}
+	current := cb.KMS.CurrentKeyId()
+	iter := cb.Session.Query(`SELECT user_id, version, wrapped_key, master_key_id FROM user_data_key`).Iter()
+	var user_id gocql.UUID
+	var version int
+	var wrapped []byte
+	var masterKeyId string
+	for iter.Scan(&user_id, &version, &wrapped, &masterKeyId) {
+		if masterKeyId == current {
+			continue
+		}
+		key, e := cb.KMS.UnwrapKey(wrapped, masterKeyId)
+		if e != nil {
+			log.WithError(e).Warnf("[RewrapUserDataKeys] failed to unwrap data key %d of user %s", version, user_id.String())
+			err = e
+			continue
+		}
+		rewrapped, newId, e := cb.KMS.WrapKey(key)
+		if e == nil {
+			e = cb.Session.Query(`UPDATE user_data_key SET wrapped_key = ?, master_key_id = ? WHERE user_id = ? AND version = ?`,
+				rewrapped, newId, user_id, version).Exec()
+		}
+		if e != nil {
+			log.WithError(e).Warnf("[RewrapUserDataKeys] failed to rewrap data key %d of user %s", version, user_id.String())
+			err = e
+			continue
+		}
+		count++
+	}
+	if e := iter.Close(); e != nil {
+		return count, e
+	}
+	return count, err
+}
+
+// RotateUserDataKey creates a new version of user's data key, then wraps again with it
+// the content keys of raw messages and attachments owned by user.
+// If reencrypt is true, these contents are also sealed again with new content keys.
+// Former versions of user's data key are kept, in case some contents could not be processed.
+func (cb *CassandraBackend) RotateUserDataKey(user_id string, reencrypt bool) error {
+	if cb.KMS == nil {
+		return errors.New("[CassandraBackend] encryption is not configured")
+	}
+	var version int
+	err := cb.Session.Query(`SELECT version FROM user_data_key WHERE user_id = ? LIMIT 1`, user_id).Scan(&version)
+	if err == gocql.ErrNotFound {
+		// user has not stored any encrypted content yet
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if _, err = cb.createUserDataKey(user_id, version+1); err != nil {
+		return err
+	}
+
+	var errs []string
+	// key owner is not part of tables' primary keys,
+	// these costly queries are acceptable for administrative tasks only.
+	iter := cb.Session.Query(`SELECT raw_msg_id FROM raw_message WHERE key_owner = ? ALLOW FILTERING`, user_id).Iter()
+	var raw_msg_id gocql.UUID
+	for iter.Scan(&raw_msg_id) {
+		if e := cb.rotateRawMessageKey(raw_msg_id.String(), reencrypt); e != nil {
+			errs = append(errs, fmt.Sprintf("raw message %s : %s", raw_msg_id.String(), e))
+		}
+	}
+	if e := iter.Close(); e != nil {
+		return e
+	}
+	iter = cb.Session.Query(`SELECT hash FROM attachment_blob WHERE key_owner = ? ALLOW FILTERING`, user_id).Iter()
+	var hash string
+	for iter.Scan(&hash) {
+		if e := cb.rotateAttachmentKey(hash, reencrypt); e != nil {
+			errs = append(errs, fmt.Sprintf("attachment %s : %s", hash, e))
+		}
+	}
+	if e := iter.Close(); e != nil {
+		return e
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("[RotateUserDataKey] failed to rotate keys of %d contents : %s", len(errs), strings.Join(errs, ", "))
+	}
+	return nil
+}
+
+func (cb *CassandraBackend) rotateRawMessageKey(raw_msg_id string, reencrypt bool) error {
+	m := map[string]interface{}{}
+	err := cb.Session.Query(`SELECT * FROM raw_message WHERE raw_msg_id = ?`, raw_msg_id).MapScan(m)
+	if err != nil {
+		return err
+	}
+	raw := obj.RawMessage{}
+	raw.UnmarshalCQLMap(m)
+	owner := raw.Key_owner.String()
+
+	if !reencrypt {
+		wrapped, version, err := cb.rewrapContentKey(owner, raw.Key_version, raw.Wrapped_key)
+		if err != nil {
+			return err
+		}
+		return cb.Session.Query(`UPDATE raw_message SET key_version = ?, wrapped_key = ? WHERE raw_msg_id = ?`,
+			version, wrapped, raw_msg_id).Exec()
+	}
+
+	// GetRawMessage returns plain data, wherever it is stored
+	plain, err := cb.GetRawMessage(raw_msg_id)
+	if err != nil {
+		return err
+	}
+	sealed, version, wrapped, err := cb.sealContent(owner, []byte(plain.Raw_data))
+	if err != nil {
+		return err
+	}
+	if raw.URI == "" {
+		return cb.Session.Query(`UPDATE raw_message SET raw_data = ?, key_version = ?, wrapped_key = ? WHERE raw_msg_id = ?`,
+			sealed, version, wrapped, raw_msg_id).Exec()
+	}
+	// sealed data is written under a new name, then raw message is switched to it :
+	// former object is kept until then, thus a failure never leaves raw message unreadable
+	var name obj.UUID
+	name.UnmarshalBinary(gocql.TimeUUID().Bytes())
+	uri, err := cb.ObjectsStore.PutRawMessage(name, string(sealed))
+	if err != nil {
+		return err
+	}
+	applied, err := cb.Session.Query(`UPDATE raw_message SET uri = ?, raw_data = ?, key_version = ?, wrapped_key = ? WHERE raw_msg_id = ? IF uri = ?`,
+		uri, []byte{}, version, wrapped, raw_msg_id, raw.URI).MapScanCAS(map[string]interface{}{})
+	if err != nil || !applied {
+		cb.ObjectsStore.RemoveObject(uri)
+		if err == nil {
+			err = errors.New("[CassandraBackend] raw message has been modified meanwhile")
+		}
+		return err
+	}
+	return cb.ObjectsStore.RemoveObject(raw.URI)
+}
+
+func (cb *CassandraBackend) rotateAttachmentKey(hash string, reencrypt bool) error {
+	var object string
+	var owner gocql.UUID
+	var keyVersion int
+	var wrapped []byte
+	err := cb.Session.Query(`SELECT uri, key_owner, key_version, wrapped_key FROM attachment_blob WHERE hash = ?`,
+		hash).Scan(&object, &owner, &keyVersion, &wrapped)
+	if err != nil {
+		return err
+	}
+
+	if !reencrypt {
+		newWrapped, version, err := cb.rewrapContentKey(owner.String(), keyVersion, wrapped)
+		if err != nil {
+			return err
+		}
+		return cb.Session.Query(`UPDATE attachment_blob SET key_version = ?, wrapped_key = ? WHERE hash = ?`,
+			version, newWrapped, hash).Exec()
+	}
+
+	if object == "" {
+		return errors.New("[CassandraBackend] attachment's file has not been stored")
+	}
+	uri, err := siblingURI(object, hash)
+	if err != nil {
+		return err
+	}
+	reader, err := cb.GetAttachment(uri)
+	if err != nil {
+		return err
+	}
+	version, userKey, err := cb.currentUserDataKey(owner.String())
+	if err != nil {
+		return err
+	}
+	contentKey, err := kms.NewDataKey()
+	if err != nil {
+		return err
+	}
+	newWrapped, err := kms.Seal(userKey, contentKey)
+	if err != nil {
+		return err
+	}
+	sealed, err := kms.NewSealingReader(contentKey, reader)
+	if err != nil {
+		return err
+	}
+	// sealed file is uploaded under a new name, then entry is switched to it :
+	// former object is kept until then, thus a failure never leaves the file unreadable
+	uploaded, _, err := cb.ObjectsStore.PutAttachment("upload-"+gocql.TimeUUID().String(), sealed)
+	if err != nil {
+		return err
+	}
+	applied, err := cb.Session.Query(`UPDATE attachment_blob SET uri = ?, key_version = ?, wrapped_key = ?, stream_sealed = true WHERE hash = ? IF uri = ?`,
+		uploaded, version, newWrapped, hash, object).MapScanCAS(map[string]interface{}{})
+	if err != nil || !applied {
+		cb.ObjectsStore.RemoveObject(uploaded)
+		if err == nil {
+			err = errors.New("[CassandraBackend] attachment's file has been modified meanwhile")
+		}
+		return err
+	}
+	return cb.ObjectsStore.RemoveObject(object)
+}
+
+// rewrapContentKey unwraps content key with the given version of owner's data key
+// and wraps it again with owner's current data key.
+func (cb *CassandraBackend) rewrapContentKey(owner string, keyVersion int, wrapped []byte) (rewrapped []byte, version int, err error) {
+	contentKey, err := cb.unwrapContentKey(owner, keyVersion, wrapped)
+	if err != nil {
+		return
+	}
+	version, userKey, err := cb.currentUserDataKey(owner)
+	if err != nil {
+		return
+	}
+	rewrapped, err = kms.Seal(userKey, contentKey)
+	return
+}
@@ -0,0 +1,118 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gocassa/gocassa"
+	"gopkg.in/oleiade/reflections.v1"
+)
+
+func (cb *CassandraBackend) CreateUserExport(export *UserExport) error {
+	exportT := cb.IKeyspace.Table("user_export", &UserExport{}, gocassa.Keys{
+		PartitionKeys: []string{"user_id", "export_id"},
+	}).WithOptions(gocassa.Options{TableName: "user_export"}) // need to overwrite default gocassa table naming convention
+
+	err := exportT.Set(export).Run()
+	if err != nil {
+		return fmt.Errorf("[CassandraBackend] CreateUserExport: %s", err)
+	}
+	return nil
+}
+
+func (cb *CassandraBackend) RetrieveUserExport(userId, exportId string) (export *UserExport, err error) {
+	export = new(UserExport).NewEmpty().(*UserExport)
+	m := map[string]interface{}{}
+	q := cb.Session.Query(`SELECT * FROM user_export WHERE user_id = ? AND export_id = ?`, userId, exportId)
+	err = q.MapScan(m)
+	if err != nil {
+		return nil, err
+	}
+	if len(m) == 0 {
+		return nil, errors.New("not found")
+	}
+	export.UnmarshalCQLMap(m)
+	return export, nil
+}
+
+func (cb *CassandraBackend) UpdateUserExport(export *UserExport, fields map[string]interface{}) error {
+	//get cassandra's field name for each field to modify
+	cassaFields := map[string]interface{}{}
+	for field, value := range fields {
+		cassaField, err := reflections.GetFieldTag(export, field, "cql")
+		if err != nil {
+			return fmt.Errorf("[CassandraBackend] UpdateUserExport failed to find a cql field for object field %s", field)
+		}
+		if cassaField != "-" {
+			cassaFields[cassaField] = value
+		}
+	}
+
+	exportT := cb.IKeyspace.Table("user_export", &UserExport{}, gocassa.Keys{
+		PartitionKeys: []string{"user_id", "export_id"},
+	}).WithOptions(gocassa.Options{TableName: "user_export"})
+
+	return exportT.
+		Where(gocassa.Eq("user_id", export.UserId.String()), gocassa.Eq("export_id", export.ExportId.String())).
+		Update(cassaFields).
+		Run()
+}
+
+func (cb *CassandraBackend) DeleteUserExport(userId, exportId string) error {
+	return cb.Session.Query(`DELETE FROM user_export WHERE user_id = ? AND export_id = ?`, userId, exportId).Exec()
+}
+
+// RetrieveAllMessages iterates over all user's messages, drafts and trashed ones included.
+// Unlike RetrieveAllUsersIds, sending to the channel never times out : caller must drain it.
+func (cb *CassandraBackend) RetrieveAllMessages(userId string) (<-chan *Message, error) {
+	ch := make(chan *Message)
+	go func(cb *CassandraBackend, ch chan *Message) {
+		iter := cb.Session.Query(`SELECT * FROM message WHERE user_id = ?`, userId).Iter()
+		for {
+			m := map[string]interface{}{}
+			if !iter.MapScan(m) {
+				break
+			}
+			msg := new(Message).NewEmpty().(*Message)
+			msg.UnmarshalCQLMap(m)
+			ch <- msg
+		}
+		if err := iter.Close(); err != nil {
+			log.WithError(err).Warnf("[RetrieveAllMessages] failed to iterate over messages of user %s", userId)
+		}
+		close(ch)
+	}(cb, ch)
+
+	return ch, nil
+}
+
+// RetrieveAllContacts iterates over all user's contacts.
+// Unlike RetrieveAllUsersIds, sending to the channel never times out : caller must drain it.
+func (cb *CassandraBackend) RetrieveAllContacts(userId string) (<-chan *Contact, error) {
+	ch := make(chan *Contact)
+	go func(cb *CassandraBackend, ch chan *Contact) {
+		iter := cb.Session.Query(`SELECT * FROM contact WHERE user_id = ?`, userId).Iter()
+		for {
+			m := map[string]interface{}{}
+			if !iter.MapScan(m) {
+				break
+			}
+			contact := new(Contact).NewEmpty().(*Contact)
+			contact.UnmarshalCQLMap(m)
+			ch <- contact
+		}
+		if err := iter.Close(); err != nil {
+			log.WithError(err).Warnf("[RetrieveAllContacts] failed to iterate over contacts of user %s", userId)
+		}
+		close(ch)
+	}(cb, ch)
+
+	return ch, nil
+}
@@ -0,0 +1,65 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/gocassa/gocassa"
+	"gopkg.in/oleiade/reflections.v1"
+)
+
+func (cb *CassandraBackend) CreateUserImport(userImport *UserImport) error {
+	importT := cb.IKeyspace.Table("user_import", &UserImport{}, gocassa.Keys{
+		PartitionKeys: []string{"user_id", "import_id"},
+	}).WithOptions(gocassa.Options{TableName: "user_import"}) // need to overwrite default gocassa table naming convention
+
+	err := importT.Set(userImport).Run()
+	if err != nil {
+		return fmt.Errorf("[CassandraBackend] CreateUserImport: %s", err)
+	}
+	return nil
+}
+
+func (cb *CassandraBackend) RetrieveUserImport(userId, importId string) (userImport *UserImport, err error) {
+	userImport = new(UserImport).NewEmpty().(*UserImport)
+	m := map[string]interface{}{}
+	q := cb.Session.Query(`SELECT * FROM user_import WHERE user_id = ? AND import_id = ?`, userId, importId)
+	err = q.MapScan(m)
+	if err != nil {
+		return nil, err
+	}
+	if len(m) == 0 {
+		return nil, errors.New("not found")
+	}
+	userImport.UnmarshalCQLMap(m)
+	return userImport, nil
+}
+
+func (cb *CassandraBackend) UpdateUserImport(userImport *UserImport, fields map[string]interface{}) error {
+	//get cassandra's field name for each field to modify
+	cassaFields := map[string]interface{}{}
+	for field, value := range fields {
+		cassaField, err := reflections.GetFieldTag(userImport, field, "cql")
+		if err != nil {
+			return fmt.Errorf("[CassandraBackend] UpdateUserImport failed to find a cql field for object field %s", field)
+		}
+		if cassaField != "-" {
+			cassaFields[cassaField] = value
+		}
+	}
+
+	importT := cb.IKeyspace.Table("user_import", &UserImport{}, gocassa.Keys{
+		PartitionKeys: []string{"user_id", "import_id"},
+	}).WithOptions(gocassa.Options{TableName: "user_import"})
+
+	return importT.
+		Where(gocassa.Eq("user_id", userImport.UserId.String()), gocassa.Eq("import_id", userImport.ImportId.String())).
+		Update(cassaFields).
+		Run()
+}
@@ -0,0 +1,80 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package store
+
+import (
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gocassa/gocassa"
+	"gopkg.in/oleiade/reflections.v1"
+)
+
+func (cb *CassandraBackend) CreateIndexMutation(mutation *IndexMutation) error {
+	outboxT := cb.IKeyspace.Table("index_outbox", &IndexMutation{}, gocassa.Keys{
+		PartitionKeys: []string{"user_id", "mutation_id"},
+	}).WithOptions(gocassa.Options{TableName: "index_outbox"}) // need to overwrite default gocassa table naming convention
+
+	err := outboxT.Set(mutation).Run()
+	if err != nil {
+		return fmt.Errorf("[CassandraBackend] CreateIndexMutation: %s", err)
+	}
+	return nil
+}
+
+func (cb *CassandraBackend) UpdateIndexMutation(mutation *IndexMutation, fields map[string]interface{}) error {
+	//get cassandra's field name for each field to modify
+	cassaFields := map[string]interface{}{}
+	for field, value := range fields {
+		cassaField, err := reflections.GetFieldTag(mutation, field, "cql")
+		if err != nil {
+			return fmt.Errorf("[CassandraBackend] UpdateIndexMutation failed to find a cql field for object field %s", field)
+		}
+		if cassaField != "-" {
+			cassaFields[cassaField] = value
+		}
+	}
+
+	outboxT := cb.IKeyspace.Table("index_outbox", &IndexMutation{}, gocassa.Keys{
+		PartitionKeys: []string{"user_id", "mutation_id"},
+	}).WithOptions(gocassa.Options{TableName: "index_outbox"})
+
+	return outboxT.
+		Where(gocassa.Eq("user_id", mutation.UserId.String()),
+			gocassa.Eq("mutation_id", mutation.MutationId.String())).
+		Update(cassaFields).
+		Run()
+}
+
+func (cb *CassandraBackend) DeleteIndexMutation(userId, mutationId string) error {
+	return cb.Session.Query(`DELETE FROM index_outbox WHERE user_id = ? AND mutation_id = ?`, userId, mutationId).Exec()
+}
+
+// RetrieveIndexMutations iterates over all pending mutations, partition by partition,
+// thus mutations of an user are sent in their mutation_id order.
+// Sending to the channel never times out : caller must drain it.
+func (cb *CassandraBackend) RetrieveIndexMutations() (<-chan *IndexMutation, error) {
+	ch := make(chan *IndexMutation)
+	go func(cb *CassandraBackend, ch chan *IndexMutation) {
+		iter := cb.Session.Query(`SELECT * FROM index_outbox`).Iter()
+		for {
+			m := map[string]interface{}{}
+			if !iter.MapScan(m) {
+				break
+			}
+			mutation := new(IndexMutation).NewEmpty().(*IndexMutation)
+			mutation.UnmarshalCQLMap(m)
+			ch <- mutation
+		}
+		if err := iter.Close(); err != nil {
+			log.WithError(err).Warn("[RetrieveIndexMutations] failed to iterate over index outbox")
+		}
+		close(ch)
+	}(cb, ch)
+
+	return ch, nil
+}
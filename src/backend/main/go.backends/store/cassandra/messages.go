@@ -5,7 +5,7 @@
 package store
 
 import (
-	"errors"
+	"bytes"
 	"fmt"
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
 	"github.com/gocassa/gocassa"
@@ -59,8 +59,64 @@ func (cb *CassandraBackend) UpdateMessage(msg *Message, fields map[string]interf
 	return err
 }
 
+// DeleteMessage removes the message row from db, along with the marks left by ReleaseMessageRefs.
+// Raw message and attachments are left as is, see DeleteRawMessage and DeleteAttachment.
 func (cb *CassandraBackend) DeleteMessage(msg *Message) error {
-	return errors.New("[CassandraBackend] DeleteMessage not yet implemented")
+	err := cb.Session.Query(`DELETE FROM message WHERE user_id = ? AND message_id = ?`,
+		msg.User_id.String(), msg.Message_id.String()).Exec()
+	if err != nil {
+		return err
+	}
+	return cb.Session.Query(`DELETE FROM message_release WHERE user_id = ? AND message_id = ?`,
+		msg.User_id.String(), msg.Message_id.String()).Exec()
+}
+
+// ReleaseMessageRefs releases the references that msg holds on its attachments and raw message.
+// Each reference is marked as released in message_release table before its counter is decremented,
+// thus running it again for the same message after a failure never releases a reference twice.
+// A failure between mark and decrement leaks the reference : file is kept rather than lost.
+func (cb *CassandraBackend) ReleaseMessageRefs(msg *Message) error {
+	userId, msgId := msg.User_id.String(), msg.Message_id.String()
+	for i, attachment := range msg.Attachments {
+		if attachment.URL == "" {
+			continue
+		}
+		hash, ok := attachmentHash(attachment.URL)
+		if !ok {
+			// file stored before deduplication, it is not shared and removing it again is harmless
+			if err := cb.ObjectsStore.RemoveObject(attachment.URL); err != nil {
+				return err
+			}
+			continue
+		}
+		mark := fmt.Sprintf("%d:%s", i, attachment.URL)
+		applied, err := cb.Session.Query(`INSERT INTO message_release (user_id, message_id, ref) VALUES (?, ?, ?) IF NOT EXISTS`,
+			userId, msgId, mark).MapScanCAS(map[string]interface{}{})
+		if err != nil {
+			return err
+		}
+		if applied {
+			if err = cb.releaseAttachmentRef(hash); err != nil {
+				return err
+			}
+		}
+		if err = cb.removeUnreferencedAttachment(hash, attachment.URL); err != nil {
+			return err
+		}
+	}
+	if !bytes.Equal(msg.Raw_msg_id.Bytes(), EmptyUUID.Bytes()) {
+		rawMsgId := msg.Raw_msg_id.String()
+		applied, err := cb.Session.Query(`INSERT INTO message_release (user_id, message_id, ref) VALUES (?, ?, ?) IF NOT EXISTS`,
+			userId, msgId, "raw:"+rawMsgId).MapScanCAS(map[string]interface{}{})
+		if err != nil {
+			return err
+		}
+		if applied {
+			return cb.DeleteRawMessage(userId, rawMsgId)
+		}
+		return cb.removeUnreferencedRawMessage(rawMsgId)
+	}
+	return nil
 }
 
 func (cb *CassandraBackend) SetMessageUnread(user_id, message_id string, status bool) (err error) {
@@ -0,0 +1,84 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package store
+
+import (
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"time"
+)
+
+// RecordInteractions increments participant_interaction_count counters, thus concurrent deliveries
+// for the same user and address don't lose increments. Protocol and last dates are plainly overwritten.
+func (cb *CassandraBackend) RecordInteractions(userId, protocol string, addresses []string, sent bool, at time.Time) error {
+	counter, last := "received", "last_received"
+	if sent {
+		counter, last = "sent", "last_sent"
+	}
+	seen := map[string]bool{}
+	for _, address := range addresses {
+		address = NormalizeInteractionAddress(address)
+		if address == "" || seen[address] {
+			continue
+		}
+		seen[address] = true
+
+		err := cb.Session.Query(`UPDATE participant_interaction SET protocol = ?, `+last+` = ? WHERE user_id = ? AND address = ?`,
+			protocol, at, userId, address).Exec()
+		if err != nil {
+			return fmt.Errorf("[CassandraBackend] RecordInteractions failed for address %s : %s", address, err)
+		}
+		err = cb.Session.Query(`UPDATE participant_interaction_count SET `+counter+` = `+counter+` + 1 WHERE user_id = ? AND address = ?`,
+			userId, address).Exec()
+		if err != nil {
+			return fmt.Errorf("[CassandraBackend] RecordInteractions failed to count address %s : %s", address, err)
+		}
+	}
+	return nil
+}
+
+func (cb *CassandraBackend) RetrieveInteractions(userId string, addresses []string) (interactions map[string]ParticipantInteraction, err error) {
+	interactions = map[string]ParticipantInteraction{}
+	keys := []string{}
+	for _, address := range addresses {
+		if address = NormalizeInteractionAddress(address); address != "" {
+			keys = append(keys, address)
+		}
+	}
+	if len(keys) == 0 {
+		return
+	}
+	rows, err := cb.Session.Query(`SELECT * FROM participant_interaction WHERE user_id = ? AND address IN ?`, userId, keys).Iter().SliceMap()
+	if err != nil {
+		return
+	}
+	for _, row := range rows {
+		interaction := ParticipantInteraction{}
+		interaction.UnmarshalCQLMap(row)
+		interactions[interaction.Address] = interaction
+	}
+	// sent and received of participant_interaction are counts recorded before counters, added to them
+	counts, err := cb.Session.Query(`SELECT address, sent, received FROM participant_interaction_count WHERE user_id = ? AND address IN ?`, userId, keys).Iter().SliceMap()
+	if err != nil {
+		return
+	}
+	for _, row := range counts {
+		address, _ := row["address"].(string)
+		interaction, ok := interactions[address]
+		if !ok {
+			continue
+		}
+		if sent, ok := row["sent"].(int64); ok {
+			interaction.Sent += int(sent)
+		}
+		if received, ok := row["received"].(int64); ok {
+			interaction.Received += int(received)
+		}
+		interactions[address] = interaction
+	}
+	return
+}
@@ -0,0 +1,71 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package store
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"time"
+)
+
+// retrievePublicKeys returns public keys whose resource is a device or a contact
+func (cb *CassandraBackend) retrievePublicKeys(userId, resourceId string) (keys PublicKeys, err error) {
+	rows, err := cb.Session.Query(`SELECT * FROM public_key WHERE user_id = ? AND resource_id = ?`, userId, resourceId).Iter().SliceMap()
+	if err != nil {
+		return nil, err
+	}
+	keys = PublicKeys{}
+	for _, row := range rows {
+		key := PublicKey{}
+		key.UnmarshalCQLMap(row)
+		keys = append(keys, key)
+	}
+	return keys, nil
+}
+
+// CreatePublicKey adds a public key to its resource
+func (cb *CassandraBackend) CreatePublicKey(key *PublicKey) error {
+	return cb.Session.Query(`INSERT INTO public_key (user_id, resource_id, key_id, resource_type, label, date_insert, date_update, expire_date, key, fingerprint, kty, use, alg, privacy_features) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		key.UserId.String(),
+		key.ResourceId.String(),
+		key.KeyId.String(),
+		key.ResourceType,
+		key.Label,
+		key.DateInsert,
+		key.DateUpdate,
+		nullableTime(key.ExpireDate),
+		string(key.Key),
+		string(key.Fingerprint),
+		key.KeyType,
+		key.Use,
+		key.Algorithm,
+		privacyFeaturesMap(key.PrivacyFeatures)).Exec()
+}
+
+// UpdatePublicKey saves the key material, dates and privacy features of a public key
+func (cb *CassandraBackend) UpdatePublicKey(key *PublicKey) error {
+	return cb.Session.Query(`UPDATE public_key SET date_update = ?, expire_date = ?, key = ?, fingerprint = ?, privacy_features = ? WHERE user_id = ? AND resource_id = ? AND key_id = ?`,
+		key.DateUpdate,
+		nullableTime(key.ExpireDate),
+		string(key.Key),
+		string(key.Fingerprint),
+		privacyFeaturesMap(key.PrivacyFeatures),
+		key.UserId.String(),
+		key.ResourceId.String(),
+		key.KeyId.String()).Exec()
+}
+
+func nullableTime(t time.Time) interface{} {
+	if t.IsZero() {
+		return nil
+	}
+	return t
+}
+
+func privacyFeaturesMap(pf *PrivacyFeatures) map[string]string {
+	if pf == nil {
+		return nil
+	}
+	return *pf
+}
@@ -5,15 +5,56 @@
 package store
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	obj "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gocassa/gocassa"
 	"github.com/gocql/gocql"
-	"io"
+	"io/ioutil"
 )
 
-func (cb *CassandraBackend) StoreRawMessage(msg obj.RawMessage) (err error) {
+// StoreRawMessage stores msg unless a raw message with the same content already exists,
+// in which case msg.Raw_msg_id is set to the existing raw message's id.
+// users are the recipients of the messages that will be built from the raw message,
+// each message releasing its reference with DeleteRawMessage.
+// References are counted for the raw message, and per user in user_raw_lookup table.
+// If encryption is enabled, raw message is sealed with a content key wrapped by first user's data key.
+func (cb *CassandraBackend) StoreRawMessage(msg *obj.RawMessage, users []obj.UUID) (err error) {
+	if err = cb.storeRawMessage(msg, users); err != nil {
+		return err
+	}
+	byUser := map[string]int{}
+	for _, user := range users {
+		byUser[user.String()]++
+	}
+	for user_id, refs := range byUser {
+		if err = cb.takeUserRawRefs(user_id, msg.Raw_msg_id.String(), refs); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (cb *CassandraBackend) storeRawMessage(msg *obj.RawMessage, users []obj.UUID) (err error) {
+	refs := len(users)
+	sum := sha256.Sum256([]byte(msg.Raw_data))
+	msg.Hash = hex.EncodeToString(sum[:])
+
+	// look for an already stored raw message with the same content
+	var existing gocql.UUID
+	err = cb.Session.Query(`SELECT raw_msg_id FROM raw_message_hash WHERE hash = ?`, msg.Hash).Scan(&existing)
+	if err != nil && err != gocql.ErrNotFound {
+		return err
+	}
+	if err == nil {
+		reused, e := cb.reuseRawMessage(msg, existing, refs)
+		if e != nil || reused {
+			return e
+		}
+	}
+
 	rawMsgTable := cb.IKeyspace.MapTable("raw_message", "raw_msg_id", &obj.RawMessage{})
 	consistency := gocql.Consistency(cb.CassandraConfig.Consistency)
 
@@ -23,24 +64,80 @@ func (cb *CassandraBackend) StoreRawMessage(msg obj.RawMessage) (err error) {
 		Consistency: &consistency,
 	})
 
+	// msg keeps plain data for caller, only stored data is sealed
+	toStore := *msg
+	if cb.KMS != nil && refs > 0 {
+		sealed, version, wrapped, err := cb.sealContent(users[0].String(), []byte(msg.Raw_data))
+		if err != nil {
+			return err
+		}
+		toStore.Raw_data = string(sealed)
+		toStore.Key_owner, toStore.Key_version, toStore.Wrapped_key = users[0], version, wrapped
+	}
+
 	// handle emails too large to fit into cassandra
 	if msg.Raw_Size > cb.CassandraConfig.SizeLimit {
 		if cb.CassandraConfig.WithObjStore {
-			uri, err := cb.ObjectsStore.PutRawMessage(msg.Raw_msg_id, msg.Raw_data)
+			uri, err := cb.ObjectsStore.PutRawMessage(msg.Raw_msg_id, toStore.Raw_data)
 			if err != nil {
 				return err
 			}
 			msg.URI = uri
-			msg.Raw_data = ""
+			toStore.URI = uri
+			toStore.Raw_data = ""
 		} else {
 			return errors.New("Object too large to fit into cassandra")
 		}
 	}
+	if err = rawMsgTable.Set(toStore).Run(); err != nil {
+		return err
+	}
 
-	if err = rawMsgTable.Set(msg).Run(); err != nil {
+	// another delivery may have stored the same content meanwhile, only one of them is kept
+	m := map[string]interface{}{}
+	applied, err := cb.Session.Query(`INSERT INTO raw_message_hash (hash, raw_msg_id) VALUES (?, ?) IF NOT EXISTS`,
+		msg.Hash, msg.Raw_msg_id.String()).MapScanCAS(m)
+	if err != nil {
 		return err
 	}
-	return
+	if !applied {
+		if winner, ok := m["raw_msg_id"].(gocql.UUID); ok {
+			duplicate := *msg
+			reused, e := cb.reuseRawMessage(msg, winner, refs)
+			if e != nil {
+				return e
+			}
+			if reused {
+				if e := cb.removeRawMessage(duplicate.Raw_msg_id.String(), duplicate.URI); e != nil {
+					log.WithError(e).Warnf("[cassandra.StoreRawMessage] failed to remove duplicate raw message %s", duplicate.Raw_msg_id.String())
+				}
+				return nil
+			}
+		}
+	}
+	return cb.Session.Query(`UPDATE raw_message_refs SET refs = refs + ? WHERE raw_msg_id = ?`, int64(refs), msg.Raw_msg_id.String()).Exec()
+}
+
+// reuseRawMessage adds refs to the existing raw message and points msg to it.
+// It returns false if existing raw message has been deleted meanwhile.
+func (cb *CassandraBackend) reuseRawMessage(msg *obj.RawMessage, existing gocql.UUID, refs int) (bool, error) {
+	err := cb.Session.Query(`UPDATE raw_message_refs SET refs = refs + ? WHERE raw_msg_id = ?`, int64(refs), existing).Exec()
+	if err != nil {
+		return false, err
+	}
+	var uri string
+	err = cb.Session.Query(`SELECT uri FROM raw_message WHERE raw_msg_id = ?`, existing).Scan(&uri)
+	if err != nil {
+		// release the references we have just taken
+		cb.Session.Query(`UPDATE raw_message_refs SET refs = refs - ? WHERE raw_msg_id = ?`, int64(refs), existing).Exec()
+		if err == gocql.ErrNotFound {
+			return false, nil
+		}
+		return false, err
+	}
+	msg.Raw_msg_id.UnmarshalBinary(existing.Bytes())
+	msg.URI = uri
+	return true, nil
 }
 
 // returns a RawMessage object, with 'raw_data' property always filled
@@ -61,15 +158,183 @@ func (cb *CassandraBackend) GetRawMessage(raw_message_id string) (message obj.Ra
 		if e != nil {
 			return obj.RawMessage{}, e
 		}
-		raw_data := make([]byte, message.Raw_Size)
-		s, e := reader.Read(raw_data)
-		if s == 0 || e != io.EOF {
+		// objects stores do not guarantee that data comes in one Read call
+		raw_data, e := ioutil.ReadAll(reader)
+		if e != nil {
 			return obj.RawMessage{}, e
 		}
-		if uint64(s) != message.Raw_Size {
-			log.Warnf("[cassandra.GetRawMessage] : Read %d bytes from Object Store, expected %d.", s, message.Raw_Size)
+		if len(raw_data) == 0 {
+			return obj.RawMessage{}, errors.New("empty raw message in objects store")
 		}
 		message.Raw_data = string(raw_data)
 	}
+	if len(message.Wrapped_key) > 0 {
+		plain, e := cb.openContent(message.Key_owner.String(), message.Key_version, message.Wrapped_key, []byte(message.Raw_data))
+		if e != nil {
+			return obj.RawMessage{}, e
+		}
+		message.Raw_data = string(plain)
+	}
+	if message.URI != "" && uint64(len(message.Raw_data)) != message.Raw_Size {
+		log.Warnf("[cassandra.GetRawMessage] : Read %d bytes from Object Store, expected %d.", len(message.Raw_data), message.Raw_Size)
+	}
 	return
 }
+
+// DeleteRawMessage releases one of the references that user holds on the raw message,
+// then deletes the raw message (and its copy in objects store if any) if no other message references it anymore.
+// Raw messages are shared by all the messages built from the same content, see StoreRawMessage.
+// Callers make sure that a reference is not released twice, see ReleaseMessageRefs.
+func (cb *CassandraBackend) DeleteRawMessage(user_id, raw_msg_id string) error {
+	return cb.releaseRawMessage(user_id, raw_msg_id, false)
+}
+
+// releaseRawMessage releases one or all of the references that user holds on the raw message,
+// then removes it if it is not referenced anymore.
+// References are first removed from user's lookup entry with a lightweight transaction,
+// thus a reference is released from raw message's counter at most once.
+func (cb *CassandraBackend) releaseRawMessage(user_id, raw_msg_id string, all bool) error {
+	released, err := cb.releaseUserRawRefs(user_id, raw_msg_id, all)
+	if err != nil {
+		return err
+	}
+	if released > 0 {
+		err = cb.Session.Query(`UPDATE raw_message_refs SET refs = refs - ? WHERE raw_msg_id = ?`, int64(released), raw_msg_id).Exec()
+		if err != nil {
+			return err
+		}
+	}
+	return cb.removeUnreferencedRawMessage(raw_msg_id)
+}
+
+// removeUnreferencedRawMessage deletes the raw message if no message references it anymore.
+func (cb *CassandraBackend) removeUnreferencedRawMessage(raw_msg_id string) error {
+	var refs int64
+	err := cb.Session.Query(`SELECT refs FROM raw_message_refs WHERE raw_msg_id = ?`, raw_msg_id).Scan(&refs)
+	if err != nil && err != gocql.ErrNotFound {
+		return err
+	}
+	if refs > 0 {
+		return nil
+	}
+
+	m := map[string]interface{}{}
+	err = cb.Session.Query(`SELECT uri, hash, key_owner FROM raw_message WHERE raw_msg_id = ?`, raw_msg_id).MapScan(m)
+	if err != nil {
+		if err == gocql.ErrNotFound {
+			return nil
+		}
+		return err
+	}
+	// unregister content first, so that new deliveries do not reuse a raw message being deleted
+	if hash, ok := m["hash"].(string); ok && hash != "" {
+		err = cb.Session.Query(`DELETE FROM raw_message_hash WHERE hash = ? IF raw_msg_id = ?`, hash, raw_msg_id).Exec()
+		if err != nil {
+			return err
+		}
+		// a delivery may have reused the raw message before its hash has been unregistered
+		err = cb.Session.Query(`SELECT refs FROM raw_message_refs WHERE raw_msg_id = ?`, raw_msg_id).Scan(&refs)
+		if err != nil && err != gocql.ErrNotFound {
+			return err
+		}
+		if refs > 0 {
+			return cb.Session.Query(`INSERT INTO raw_message_hash (hash, raw_msg_id) VALUES (?, ?) IF NOT EXISTS`, hash, raw_msg_id).Exec()
+		}
+	}
+	uri, _ := m["uri"].(string)
+	if err = cb.removeRawMessage(raw_msg_id, uri); err != nil {
+		return err
+	}
+	owner, _ := m["key_owner"].(gocql.UUID)
+	cb.contentRemoved(owner)
+	return nil
+}
+
+// takeUserRawRefs adds refs to the number of references that user holds on the raw message.
+func (cb *CassandraBackend) takeUserRawRefs(user_id, raw_msg_id string, refs int) error {
+	for attempt := 0; attempt < blobAttempts; attempt++ {
+		applied, err := cb.Session.Query(`INSERT INTO user_raw_lookup (user_id, raw_msg_id, refs) VALUES (?, ?, ?) IF NOT EXISTS`,
+			user_id, raw_msg_id, refs).MapScanCAS(map[string]interface{}{})
+		if err != nil || applied {
+			return err
+		}
+		held, current, err := cb.userRawRefs(user_id, raw_msg_id)
+		if err == gocql.ErrNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		applied, err = cb.Session.Query(`UPDATE user_raw_lookup SET refs = ? WHERE user_id = ? AND raw_msg_id = ? IF refs = ?`,
+			held+refs, user_id, raw_msg_id, current).MapScanCAS(map[string]interface{}{})
+		if err != nil || applied {
+			return err
+		}
+	}
+	return errRawLookupContention
+}
+
+// releaseUserRawRefs removes one, or all, of the references that user holds on the raw message from user's lookup entry.
+// It returns the number of references removed.
+func (cb *CassandraBackend) releaseUserRawRefs(user_id, raw_msg_id string, all bool) (released int, err error) {
+	for attempt := 0; attempt < blobAttempts; attempt++ {
+		held, current, err := cb.userRawRefs(user_id, raw_msg_id)
+		if err == gocql.ErrNotFound {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		var applied bool
+		if all || held <= 1 {
+			released = held
+			applied, err = cb.Session.Query(`DELETE FROM user_raw_lookup WHERE user_id = ? AND raw_msg_id = ? IF refs = ?`,
+				user_id, raw_msg_id, current).MapScanCAS(map[string]interface{}{})
+		} else {
+			released = 1
+			applied, err = cb.Session.Query(`UPDATE user_raw_lookup SET refs = ? WHERE user_id = ? AND raw_msg_id = ? IF refs = ?`,
+				held-1, user_id, raw_msg_id, current).MapScanCAS(map[string]interface{}{})
+		}
+		if err != nil {
+			return 0, err
+		}
+		if applied {
+			if released < 0 {
+				released = 0
+			}
+			return released, nil
+		}
+	}
+	return 0, errRawLookupContention
+}
+
+// userRawRefs returns the number of references that user holds on the raw message,
+// along with the value of refs column to check in conditions.
+func (cb *CassandraBackend) userRawRefs(user_id, raw_msg_id string) (refs int, current interface{}, err error) {
+	var value *int
+	err = cb.Session.Query(`SELECT refs FROM user_raw_lookup WHERE user_id = ? AND raw_msg_id = ?`,
+		user_id, raw_msg_id).Scan(&value)
+	if err != nil {
+		return 0, nil, err
+	}
+	if value == nil {
+		// entry written before references were counted per user
+		return 1, nil, nil
+	}
+	return *value, *value, nil
+}
+
+var errRawLookupContention = errors.New("[CassandraBackend] too many concurrent updates of user's raw message lookup")
+
+// removeRawMessage deletes raw message from db and from objects store if uri is not empty
+func (cb *CassandraBackend) removeRawMessage(raw_msg_id, uri string) error {
+	if uri != "" {
+		if !cb.CassandraConfig.WithObjStore {
+			return errors.New("raw message is in objects store but objects store is not configured")
+		}
+		if err := cb.ObjectsStore.RemoveObject(uri); err != nil {
+			return err
+		}
+	}
+	return cb.Session.Query(`DELETE FROM raw_message WHERE raw_msg_id = ?`, raw_msg_id).Exec()
+}
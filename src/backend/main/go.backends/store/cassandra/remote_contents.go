@@ -0,0 +1,32 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package store
+
+import (
+	"bytes"
+	"errors"
+	"io/ioutil"
+)
+
+// remote contents are public resources shared by all users, thus they are cached in clear in objects store
+
+func (cb *CassandraBackend) GetRemoteContent(key string) (content []byte, err error) {
+	if cb.ObjectsStore == nil {
+		return nil, errors.New("[CassandraBackend] no objects store to cache remote contents")
+	}
+	reader, err := cb.ObjectsStore.GetRemoteContent(key)
+	if err != nil {
+		return nil, err
+	}
+	return ioutil.ReadAll(reader)
+}
+
+func (cb *CassandraBackend) PutRemoteContent(key string, content []byte) error {
+	if cb.ObjectsStore == nil {
+		return errors.New("[CassandraBackend] no objects store to cache remote contents")
+	}
+	_, _, err := cb.ObjectsStore.PutRemoteContent(key, bytes.NewReader(content))
+	return err
+}
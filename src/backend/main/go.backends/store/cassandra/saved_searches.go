@@ -0,0 +1,87 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/gocassa/gocassa"
+	"gopkg.in/oleiade/reflections.v1"
+)
+
+func (cb *CassandraBackend) CreateSavedSearch(search *SavedSearch) error {
+	searchT := cb.IKeyspace.Table("user_saved_search", &SavedSearch{}, gocassa.Keys{
+		PartitionKeys: []string{"user_id", "search_id"},
+	}).WithOptions(gocassa.Options{TableName: "user_saved_search"}) // need to overwrite default gocassa table naming convention
+
+	err := searchT.Set(search).Run()
+	if err != nil {
+		return fmt.Errorf("[CassandraBackend] CreateSavedSearch: %s", err)
+	}
+	return nil
+}
+
+// retrieve saved searches belonging to userId
+func (cb *CassandraBackend) RetrieveSavedSearches(userId string) (searches []SavedSearch, err error) {
+	all_searches, err := cb.Session.Query(`SELECT * FROM user_saved_search WHERE user_id = ?`, userId).Iter().SliceMap()
+	if err != nil {
+		return
+	}
+	if len(all_searches) == 0 {
+		err = errors.New("saved searches not found")
+		return
+	}
+	for _, search := range all_searches {
+		s := new(SavedSearch).NewEmpty().(*SavedSearch)
+		s.UnmarshalCQLMap(search)
+		searches = append(searches, *s)
+	}
+	return
+}
+
+func (cb *CassandraBackend) RetrieveSavedSearch(userId, searchId string) (search *SavedSearch, err error) {
+	search = new(SavedSearch).NewEmpty().(*SavedSearch)
+	s := map[string]interface{}{}
+	q := cb.Session.Query(`SELECT * FROM user_saved_search WHERE user_id = ? AND search_id = ?`, userId, searchId)
+	err = q.MapScan(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(s) == 0 {
+		return nil, errors.New("not found")
+	}
+	search.UnmarshalCQLMap(s)
+	return search, nil
+}
+
+func (cb *CassandraBackend) UpdateSavedSearch(search *SavedSearch, fields map[string]interface{}) error {
+	//get cassandra's field name for each field to modify
+	cassaFields := map[string]interface{}{}
+	for field, value := range fields {
+		cassaField, err := reflections.GetFieldTag(search, field, "cql")
+		if err != nil {
+			return fmt.Errorf("[CassandraBackend] UpdateSavedSearch failed to find a cql field for object field %s", field)
+		}
+		if cassaField != "-" {
+			cassaFields[cassaField] = value
+		}
+	}
+
+	searchT := cb.IKeyspace.Table("user_saved_search", &SavedSearch{}, gocassa.Keys{
+		PartitionKeys: []string{"user_id", "search_id"},
+	}).WithOptions(gocassa.Options{TableName: "user_saved_search"})
+
+	return searchT.
+		Where(gocassa.Eq("user_id", search.UserId.String()), gocassa.Eq("search_id", search.SearchId.String())).
+		Update(cassaFields).
+		Run()
+}
+
+func (cb *CassandraBackend) DeleteSavedSearch(userId, searchId string) error {
+	return cb.Session.Query(`DELETE FROM user_saved_search WHERE user_id = ? AND search_id = ?`, userId, searchId).Exec()
+}
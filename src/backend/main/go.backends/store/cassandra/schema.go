@@ -0,0 +1,49 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package store
+
+import (
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gocql/gocql"
+)
+
+// SchemaVersion is the version of the Cassandra schema this code works with,
+// that is the number of the last migration file in defs/cql.
+const SchemaVersion = 11
+
+// SchemaVersionTable records the migrations applied to the keyspace, it is created by the migrate tool.
+const SchemaVersionTable = "schema_version"
+
+// AppliedSchemaVersion returns the highest migration version recorded in keyspace, 0 if none.
+func AppliedSchemaVersion(session *gocql.Session) (version int, err error) {
+	iter := session.Query(`SELECT version FROM ` + SchemaVersionTable).Iter()
+	var v int
+	for iter.Scan(&v) {
+		if v > version {
+			version = v
+		}
+	}
+	err = iter.Close()
+	return
+}
+
+// checkSchemaVersion refuses a keyspace whose schema is older than SchemaVersion.
+// A newer schema is accepted, migrations only add tables and columns.
+func checkSchemaVersion(session *gocql.Session, keyspace string) error {
+	version, err := AppliedSchemaVersion(session)
+	if err != nil {
+		return fmt.Errorf("[CassandraBackend] can't read schema version of keyspace %s, has `migrate up` been run ? %s", keyspace, err)
+	}
+	if version < SchemaVersion {
+		return fmt.Errorf("[CassandraBackend] schema of keyspace %s is at version %d, version %d is required : run `migrate up`", keyspace, version, SchemaVersion)
+	}
+	if version > SchemaVersion {
+		log.Warnf("[CassandraBackend] schema of keyspace %s is at version %d, newer than version %d this program is built for", keyspace, version, SchemaVersion)
+	}
+	return nil
+}
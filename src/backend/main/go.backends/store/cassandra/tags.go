@@ -35,10 +35,11 @@ func (cb *CassandraBackend) CreateTag(tag *Tag) error {
 	user_id, _ := gocql.UUIDFromBytes((*tag).User_id.Bytes())
 	(*tag).Date_insert = time.Now()
 	(*tag).Type = TagType(UserTag)
-	return cb.Session.Query(`INSERT INTO user_tag (user_id, name, date_insert, importance_level, label, type) VALUES (?,?,?,?,?,?)`,
+	return cb.Session.Query(`INSERT INTO user_tag (user_id, name, date_insert, expiry_days, importance_level, label, type) VALUES (?,?,?,?,?,?,?)`,
 		user_id,
 		(*tag).Name,
 		(*tag).Date_insert,
+		(*tag).Expiry_days,
 		(*tag).Importance_level,
 		(*tag).Label,
 		(*tag).Type).Exec()
@@ -62,7 +63,8 @@ func (cb *CassandraBackend) RetrieveTag(user_id, name string) (tag Tag, err erro
 }
 
 func (cb *CassandraBackend) UpdateTag(tag *Tag) error {
-	return cb.Session.Query(`UPDATE user_tag SET importance_level = ?, label = ?, type = ? WHERE user_id = ? AND name = ?`,
+	return cb.Session.Query(`UPDATE user_tag SET expiry_days = ?, importance_level = ?, label = ?, type = ? WHERE user_id = ? AND name = ?`,
+		tag.Expiry_days,
 		tag.Importance_level,
 		tag.Label,
 		tag.Type,
@@ -0,0 +1,262 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package store
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gocassa/gocassa"
+	"github.com/gocql/gocql"
+	"gopkg.in/oleiade/reflections.v1"
+	"strings"
+)
+
+// userPartitions are the tables whose partition key is only user_id.
+// user table is not listed, it is removed last by DeleteUserAccount.
+// user_data_key table is not listed either, see deleteUnusedDataKey.
+var userPartitions = []string{
+	"contact",
+	"contact_lookup",
+	"device",
+	"device_audit",
+	"device_connection_log",
+	"device_location",
+	"discussion",
+	"discussion_list_lookup",
+	"discussion_recipient_lookup",
+	"discussion_thread_lookup",
+	"filter_rule",
+	"index_outbox",
+	"message",
+	"message_release",
+	"notification",
+	"participant_interaction",
+	"participant_interaction_count",
+	"public_key",
+	"remote_identity",
+	"settings",
+	"user_export",
+	"user_import",
+	"user_raw_lookup",
+	"user_saved_search",
+	"user_tag",
+}
+
+func (cb *CassandraBackend) CreateUserDeletion(deletion *UserDeletion) error {
+	deletionT := cb.IKeyspace.Table("user_deletion", &UserDeletion{}, gocassa.Keys{
+		PartitionKeys: []string{"user_id"},
+	}).WithOptions(gocassa.Options{TableName: "user_deletion"}) // need to overwrite default gocassa table naming convention
+
+	err := deletionT.Set(deletion).Run()
+	if err != nil {
+		return fmt.Errorf("[CassandraBackend] CreateUserDeletion: %s", err)
+	}
+	return nil
+}
+
+func (cb *CassandraBackend) RetrieveUserDeletion(userId string) (deletion *UserDeletion, err error) {
+	deletion = new(UserDeletion).NewEmpty().(*UserDeletion)
+	m := map[string]interface{}{}
+	q := cb.Session.Query(`SELECT * FROM user_deletion WHERE user_id = ?`, userId)
+	err = q.MapScan(m)
+	if err != nil {
+		if err == gocql.ErrNotFound {
+			return nil, errors.New("not found")
+		}
+		return nil, err
+	}
+	if len(m) == 0 {
+		return nil, errors.New("not found")
+	}
+	deletion.UnmarshalCQLMap(m)
+	return deletion, nil
+}
+
+func (cb *CassandraBackend) UpdateUserDeletion(deletion *UserDeletion, fields map[string]interface{}) error {
+	//get cassandra's field name for each field to modify
+	cassaFields := map[string]interface{}{}
+	for field, value := range fields {
+		cassaField, err := reflections.GetFieldTag(deletion, field, "cql")
+		if err != nil {
+			return fmt.Errorf("[CassandraBackend] UpdateUserDeletion failed to find a cql field for object field %s", field)
+		}
+		if cassaField != "-" {
+			cassaFields[cassaField] = value
+		}
+	}
+
+	deletionT := cb.IKeyspace.Table("user_deletion", &UserDeletion{}, gocassa.Keys{
+		PartitionKeys: []string{"user_id"},
+	}).WithOptions(gocassa.Options{TableName: "user_deletion"})
+
+	return deletionT.
+		Where(gocassa.Eq("user_id", deletion.UserId.String())).
+		Update(cassaFields).
+		Run()
+}
+
+func (cb *CassandraBackend) DeleteUserDeletion(userId string) error {
+	return cb.Session.Query(`DELETE FROM user_deletion WHERE user_id = ?`, userId).Exec()
+}
+
+// RetrieveUserDeletions iterates over all accounts' deletions, completed ones included.
+// Unlike RetrieveAllUsersIds, sending to the channel never times out : caller must drain it.
+func (cb *CassandraBackend) RetrieveUserDeletions() (<-chan *UserDeletion, error) {
+	ch := make(chan *UserDeletion)
+	go func(cb *CassandraBackend, ch chan *UserDeletion) {
+		iter := cb.Session.Query(`SELECT * FROM user_deletion`).Iter()
+		for {
+			m := map[string]interface{}{}
+			if !iter.MapScan(m) {
+				break
+			}
+			deletion := new(UserDeletion).NewEmpty().(*UserDeletion)
+			deletion.UnmarshalCQLMap(m)
+			ch <- deletion
+		}
+		if err := iter.Close(); err != nil {
+			log.WithError(err).Warn("[RetrieveUserDeletions] failed to iterate over deletions")
+		}
+		close(ch)
+	}(cb, ch)
+
+	return ch, nil
+}
+
+// DeleteUserRawMessages releases all references that user still holds on raw messages.
+// Lookup entries are removed before counters are decremented, thus a reference is released only once even if it is run again.
+func (cb *CassandraBackend) DeleteUserRawMessages(userId string) error {
+	iter := cb.Session.Query(`SELECT raw_msg_id FROM user_raw_lookup WHERE user_id = ?`, userId).Iter()
+	var rawMsgId gocql.UUID
+	ids := []string{}
+	for iter.Scan(&rawMsgId) {
+		ids = append(ids, rawMsgId.String())
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := cb.releaseRawMessage(userId, id, true); err != nil {
+			return fmt.Errorf("[CassandraBackend] DeleteUserRawMessages failed to delete raw message %s : %s", id, err)
+		}
+	}
+	return nil
+}
+
+// DeleteUserArchives removes exports' archives and not yet imported archives from objects store.
+// Each export or import is deleted along with its archive.
+func (cb *CassandraBackend) DeleteUserArchives(userId string) error {
+	var id gocql.UUID
+	var uri string
+	iter := cb.Session.Query(`SELECT export_id, uri FROM user_export WHERE user_id = ?`, userId).Iter()
+	for iter.Scan(&id, &uri) {
+		if uri != "" {
+			if err := cb.DeleteAttachment(uri); err != nil {
+				iter.Close()
+				return fmt.Errorf("[CassandraBackend] DeleteUserArchives failed to remove archive of export %s : %s", id.String(), err)
+			}
+		}
+		if err := cb.DeleteUserExport(userId, id.String()); err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	if err := iter.Close(); err != nil {
+		return err
+	}
+
+	iter = cb.Session.Query(`SELECT import_id, source FROM user_import WHERE user_id = ?`, userId).Iter()
+	for iter.Scan(&id, &uri) {
+		// only uploaded archives are in objects store, other sources are paths on IMAP workers' filesystem
+		if strings.Contains(uri, "://") {
+			if err := cb.DeleteAttachment(uri); err != nil {
+				log.WithError(err).Warnf("[CassandraBackend] DeleteUserArchives : archive of import %s not removed", id.String())
+			}
+		}
+		err := cb.Session.Query(`DELETE FROM user_import WHERE user_id = ? AND import_id = ?`, userId, id.String()).Exec()
+		if err != nil {
+			iter.Close()
+			return err
+		}
+	}
+	return iter.Close()
+}
+
+// DeleteUserPartitions removes all rows of user in tables partitioned by user_id.
+// User's data key is kept as long as contents shared with other users are sealed with it.
+func (cb *CassandraBackend) DeleteUserPartitions(userId string) error {
+	for _, table := range userPartitions {
+		err := cb.Session.Query(`DELETE FROM `+table+` WHERE user_id = ?`, userId).Exec()
+		if err != nil {
+			return fmt.Errorf("[CassandraBackend] DeleteUserPartitions failed on table %s : %s", table, err)
+		}
+	}
+	if err := cb.deleteUnusedDataKey(userId); err != nil {
+		return fmt.Errorf("[CassandraBackend] DeleteUserPartitions failed on table user_data_key : %s", err)
+	}
+	return nil
+}
+
+// deleteUnusedDataKey removes all versions of user's data key, unless some contents are still sealed with them.
+// Contents shared with other users keep the key of their first owner (see StoreRawMessage and StoreAttachment),
+// thus a deleted user's data key is removed along with the last of these contents, see contentRemoved.
+func (cb *CassandraBackend) deleteUnusedDataKey(userId string) error {
+	// key owner is not part of tables' primary keys, as in RotateUserDataKey
+	for _, table := range []string{"raw_message", "attachment_blob"} {
+		var owner gocql.UUID
+		err := cb.Session.Query(`SELECT key_owner FROM `+table+` WHERE key_owner = ? LIMIT 1 ALLOW FILTERING`, userId).Scan(&owner)
+		if err == nil {
+			return nil
+		}
+		if err != gocql.ErrNotFound {
+			return err
+		}
+	}
+	return cb.Session.Query(`DELETE FROM user_data_key WHERE user_id = ?`, userId).Exec()
+}
+
+// contentRemoved is called once a content sealed with owner's data key has been removed.
+// If owner's account is being deleted or has been deleted, its data key is removed if it is not used anymore.
+func (cb *CassandraBackend) contentRemoved(owner gocql.UUID) {
+	if owner == (gocql.UUID{}) {
+		// content stored in clear
+		return
+	}
+	var status string
+	err := cb.Session.Query(`SELECT status FROM user_deletion WHERE user_id = ?`, owner).Scan(&status)
+	if err != nil || status == DeletionPending {
+		return
+	}
+	if err = cb.deleteUnusedDataKey(owner.String()); err != nil {
+		log.WithError(err).Warnf("[CassandraBackend] failed to remove data key of deleted user %s", owner.String())
+	}
+}
+
+// DeleteUserAccount removes user's local identities, username and recovery email lookups, then user itself.
+// Once done, username is available again.
+func (cb *CassandraBackend) DeleteUserAccount(user *User) error {
+	userId := user.UserId.String()
+	for _, identifier := range user.LocalIdentities {
+		err := cb.Session.Query(`DELETE FROM local_identity WHERE identifier = ?`, identifier).Exec()
+		if err != nil {
+			return err
+		}
+	}
+	err := cb.Session.Query(`DELETE FROM user_name WHERE name = ? IF user_id = ?`, strings.ToLower(user.Name), userId).Exec()
+	if err != nil {
+		return err
+	}
+	if user.RecoveryEmail != "" {
+		err = cb.Session.Query(`DELETE FROM user_recovery_email WHERE recovery_email = ? IF user_id = ?`, user.RecoveryEmail, userId).Exec()
+		if err != nil {
+			return err
+		}
+	}
+	return cb.Session.Query(`DELETE FROM user WHERE user_id = ?`, userId).Exec()
+}
@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	log "github.com/Sirupsen/logrus"
 	"github.com/gocassa/gocassa"
 	"github.com/gocql/gocql"
 	"gopkg.in/oleiade/reflections.v1"
@@ -66,3 +67,22 @@ func (cb *CassandraBackend) UserByRecoveryEmail(email string) (user *User, err e
 	}
 	return cb.RetrieveUser(user_id.String())
 }
+
+// RetrieveAllUsersIds iterates over user table and sends each user_id found through the returned chan.
+// Chan is closed once all users have been read, callers must drain it : no user is skipped for a slow reader.
+func (cb *CassandraBackend) RetrieveAllUsersIds() (<-chan string, error) {
+	ch := make(chan string)
+	go func(cb *CassandraBackend, ch chan string) {
+		iter := cb.Session.Query(`SELECT user_id FROM user`).Iter()
+		var userId gocql.UUID
+		for iter.Scan(&userId) {
+			ch <- userId.String()
+		}
+		if err := iter.Close(); err != nil {
+			log.WithError(err).Warn("[RetrieveAllUsersIds] failed to iterate over users")
+		}
+		close(ch)
+	}(cb, ch)
+
+	return ch, nil
+}
@@ -0,0 +1,92 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+// Package kms provides envelope encryption primitives :
+// contents are encrypted with data keys, which are themselves wrapped by master keys held by a KeyManagementService.
+package kms
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+)
+
+type (
+	KMSConfig struct {
+		KMSType     string // only "local" for now
+		KeyringPath string // for "local" kms type only
+	}
+
+	// KeyManagementService wraps and unwraps data keys with master keys that never leave the service.
+	KeyManagementService interface {
+		// WrapKey encrypts key with the current master key, and returns the id of the master key used.
+		WrapKey(key []byte) (wrapped []byte, masterKeyId string, err error)
+		// UnwrapKey decrypts a key wrapped by master key masterKeyId, which may not be the current one.
+		UnwrapKey(wrapped []byte, masterKeyId string) (key []byte, err error)
+		CurrentKeyId() string
+	}
+)
+
+const (
+	KeySize   = 32 // AES-256
+	nonceSize = 12
+)
+
+func InitializeKMS(config KMSConfig) (KeyManagementService, error) {
+	switch config.KMSType {
+	case "local":
+		return NewLocalKMS(config.KeyringPath)
+	default:
+		return nil, fmt.Errorf("[KMS] unknown kms type <%s>", config.KMSType)
+	}
+}
+
+// NewDataKey returns a random key suitable for Seal and Open.
+func NewDataKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// Seal encrypts and authenticates plaintext with AES-256-GCM.
+// Output is the random nonce followed by the ciphertext.
+func Seal(key, plaintext []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, nonceSize, nonceSize+len(plaintext)+aead.Overhead())
+	if _, err = io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, nil), nil
+}
+
+// Open decrypts and authenticates data sealed by Seal.
+func Open(key, sealed []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(sealed) < nonceSize+aead.Overhead() {
+		return nil, errors.New("[KMS] sealed data too short")
+	}
+	return aead.Open(nil, sealed[:nonceSize], sealed[nonceSize:], nil)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("[KMS] invalid key size %d", len(key))
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
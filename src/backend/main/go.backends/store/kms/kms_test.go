@@ -0,0 +1,124 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package kms
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io/ioutil"
+	"testing"
+)
+
+func TestSealOpen(t *testing.T) {
+	key, err := NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	plaintext := []byte("From: alice@example.com\r\n\r\nhello")
+	sealed, err := Seal(key, plaintext)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(sealed, plaintext) {
+		t.Error("sealed data contains plaintext")
+	}
+	opened, err := Open(key, sealed)
+	if err != nil || !bytes.Equal(opened, plaintext) {
+		t.Errorf("unexpected opened data %q, err %v", opened, err)
+	}
+
+	sealed[len(sealed)-1] ^= 1
+	if _, err = Open(key, sealed); err == nil {
+		t.Error("tampered data should not be opened")
+	}
+	other, _ := NewDataKey()
+	if _, err = Open(other, sealed); err == nil {
+		t.Error("data should not be opened with another key")
+	}
+}
+
+func TestLocalKMS_Rotation(t *testing.T) {
+	k1 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, KeySize))
+	k2 := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{2}, KeySize))
+	before, err := newLocalKMS(keyring{Current: "k1", Keys: map[string]string{"k1": k1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dataKey, _ := NewDataKey()
+	wrapped, id, err := before.WrapKey(dataKey)
+	if err != nil || id != "k1" {
+		t.Fatalf("unexpected master key id %s, err %v", id, err)
+	}
+
+	after, err := newLocalKMS(keyring{Current: "k2", Keys: map[string]string{"k1": k1, "k2": k2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	unwrapped, err := after.UnwrapKey(wrapped, id)
+	if err != nil || !bytes.Equal(unwrapped, dataKey) {
+		t.Fatalf("data key wrapped by former master key should still be unwrapped, err %v", err)
+	}
+	if _, id, _ = after.WrapKey(unwrapped); id != "k2" {
+		t.Errorf("data key should be wrapped with current master key, got %s", id)
+	}
+	if _, err = after.UnwrapKey(wrapped, "k2"); err == nil {
+		t.Error("data key should not be unwrapped with another master key")
+	}
+
+	if _, err = newLocalKMS(keyring{Current: "k3", Keys: map[string]string{"k1": k1}}); err == nil {
+		t.Error("keyring without current key should be rejected")
+	}
+	if _, err = newLocalKMS(keyring{Current: "k1", Keys: map[string]string{"k1": "c2hvcnQ="}}); err == nil {
+		t.Error("keyring with invalid key should be rejected")
+	}
+}
+
+func TestSealingReader(t *testing.T) {
+	key, err := NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, size := range []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3 * StreamChunkSize} {
+		plaintext := bytes.Repeat([]byte("x"), size)
+		sealing, err := NewSealingReader(key, bytes.NewReader(plaintext))
+		if err != nil {
+			t.Fatal(err)
+		}
+		sealed, err := ioutil.ReadAll(sealing)
+		if err != nil {
+			t.Fatalf("size %d : failed to seal : %s", size, err)
+		}
+		opening, err := NewOpeningReader(key, bytes.NewReader(sealed))
+		if err != nil {
+			t.Fatal(err)
+		}
+		opened, err := ioutil.ReadAll(opening)
+		if err != nil || !bytes.Equal(opened, plaintext) {
+			t.Errorf("size %d : unexpected opened data of %d bytes, err %v", size, len(opened), err)
+		}
+
+		tampered := append([]byte{}, sealed...)
+		tampered[len(tampered)-1] ^= 1
+		if opening, err = NewOpeningReader(key, bytes.NewReader(tampered)); err == nil {
+			_, err = ioutil.ReadAll(opening)
+		}
+		if err == nil {
+			t.Errorf("size %d : tampered stream should not be opened", size)
+		}
+		if size > StreamChunkSize {
+			// last chunk dropped
+			truncated := sealed[:len(sealed)-(size%StreamChunkSize+16)]
+			if size%StreamChunkSize == 0 {
+				truncated = sealed[:len(sealed)-(StreamChunkSize+16)]
+			}
+			if opening, err = NewOpeningReader(key, bytes.NewReader(truncated)); err == nil {
+				_, err = ioutil.ReadAll(opening)
+			}
+			if err == nil {
+				t.Errorf("size %d : truncated stream should not be opened", size)
+			}
+		}
+	}
+}
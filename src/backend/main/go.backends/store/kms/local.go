@@ -0,0 +1,91 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package kms
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	log "github.com/Sirupsen/logrus"
+	"io/ioutil"
+	"os"
+)
+
+// LocalKMS is a KeyManagementService holding master keys loaded from a keyring file :
+//
+//	{
+//	  "current": "2018-03",
+//	  "keys": {
+//	    "2018-01": "<base64 encoded 32 bytes key>",
+//	    "2018-03": "<base64 encoded 32 bytes key>"
+//	  }
+//	}
+//
+// To rotate master key, add a new key to the keyring, make it the current one and restart services.
+// Former keys must be kept until all data keys have been re-wrapped with the new one.
+type LocalKMS struct {
+	current string
+	keys    map[string][]byte
+}
+
+type keyring struct {
+	Current string            `json:"current"`
+	Keys    map[string]string `json:"keys"`
+}
+
+func NewLocalKMS(keyringPath string) (*LocalKMS, error) {
+	if keyringPath == "" {
+		return nil, errors.New("[KMS] keyring path is mandatory for local kms")
+	}
+	info, err := os.Stat(keyringPath)
+	if err != nil {
+		return nil, err
+	}
+	if info.Mode().Perm()&0077 != 0 {
+		log.Warnf("[KMS] keyring file %s is readable by other users", keyringPath)
+	}
+	content, err := ioutil.ReadFile(keyringPath)
+	if err != nil {
+		return nil, err
+	}
+	ring := keyring{}
+	if err = json.Unmarshal(content, &ring); err != nil {
+		return nil, fmt.Errorf("[KMS] invalid keyring file : %s", err)
+	}
+	return newLocalKMS(ring)
+}
+
+func newLocalKMS(ring keyring) (*LocalKMS, error) {
+	lk := &LocalKMS{current: ring.Current, keys: map[string][]byte{}}
+	for id, encoded := range ring.Keys {
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil || len(key) != KeySize {
+			return nil, fmt.Errorf("[KMS] invalid master key <%s> in keyring", id)
+		}
+		lk.keys[id] = key
+	}
+	if _, ok := lk.keys[lk.current]; !ok {
+		return nil, fmt.Errorf("[KMS] current master key <%s> not found in keyring", lk.current)
+	}
+	return lk, nil
+}
+
+func (lk *LocalKMS) WrapKey(key []byte) (wrapped []byte, masterKeyId string, err error) {
+	wrapped, err = Seal(lk.keys[lk.current], key)
+	return wrapped, lk.current, err
+}
+
+func (lk *LocalKMS) UnwrapKey(wrapped []byte, masterKeyId string) (key []byte, err error) {
+	master, ok := lk.keys[masterKeyId]
+	if !ok {
+		return nil, fmt.Errorf("[KMS] unknown master key <%s>", masterKeyId)
+	}
+	return Open(master, wrapped)
+}
+
+func (lk *LocalKMS) CurrentKeyId() string {
+	return lk.current
+}
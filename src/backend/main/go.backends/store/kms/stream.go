@@ -0,0 +1,176 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package kms
+
+import (
+	"bufio"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// Streams are sealed by chunks of StreamChunkSize bytes of plaintext, thus contents too large
+// to be held in memory can be sealed and opened on the fly.
+// Sealed stream is a random nonce prefix followed by the sealed chunks. Each chunk's nonce is the prefix,
+// chunk's index and a flag set on the last chunk only, thus chunks can't be reordered, dropped or truncated.
+const (
+	StreamChunkSize = 64 * 1024
+	streamPrefix    = nonceSize - 5 // 4 bytes of chunk index, 1 byte of last chunk flag
+)
+
+type streamSealer struct {
+	aead   cipher.AEAD
+	nonce  []byte
+	index  uint32
+	src    *bufio.Reader
+	plain  []byte
+	buf    []byte
+	out    []byte // sealed data not read yet
+	done   bool
+	failed error
+}
+
+// NewSealingReader returns a reader of src's content sealed with key.
+func NewSealingReader(key []byte, src io.Reader) (io.Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	s := &streamSealer{
+		aead:  aead,
+		nonce: make([]byte, nonceSize),
+		src:   bufio.NewReaderSize(src, StreamChunkSize),
+		plain: make([]byte, StreamChunkSize),
+	}
+	if _, err = io.ReadFull(rand.Reader, s.nonce[:streamPrefix]); err != nil {
+		return nil, err
+	}
+	s.buf = make([]byte, 0, StreamChunkSize+aead.Overhead())
+	s.out = append(s.out, s.nonce[:streamPrefix]...)
+	return s, nil
+}
+
+func (s *streamSealer) Read(p []byte) (n int, err error) {
+	for len(s.out) == 0 {
+		if s.failed != nil {
+			return 0, s.failed
+		}
+		if s.done {
+			return 0, io.EOF
+		}
+		s.sealChunk()
+	}
+	n = copy(p, s.out)
+	s.out = s.out[n:]
+	return n, nil
+}
+
+func (s *streamSealer) sealChunk() {
+	size, err := io.ReadFull(s.src, s.plain)
+	switch err {
+	case nil:
+		// chunk is the last one if nothing follows it
+		if _, err = s.src.Peek(1); err == io.EOF {
+			s.done = true
+		} else if err != nil {
+			s.failed = err
+			return
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		s.done = true
+	default:
+		s.failed = err
+		return
+	}
+	if s.index == ^uint32(0) {
+		s.failed = errors.New("[KMS] stream too long")
+		return
+	}
+	streamNonce(s.nonce, s.index, s.done)
+	s.index++
+	s.out = s.aead.Seal(s.buf[:0], s.nonce, s.plain[:size], nil)
+}
+
+type streamOpener struct {
+	aead   cipher.AEAD
+	nonce  []byte
+	index  uint32
+	src    *bufio.Reader
+	sealed []byte
+	buf    []byte
+	out    []byte // opened data not read yet
+	done   bool
+	failed error
+}
+
+// NewOpeningReader returns a reader of the content sealed by NewSealingReader that src reads.
+// Reading fails if sealed data has been tampered with, after the chunks read before have been returned.
+func NewOpeningReader(key []byte, src io.Reader) (io.Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	o := &streamOpener{
+		aead:   aead,
+		nonce:  make([]byte, nonceSize),
+		src:    bufio.NewReaderSize(src, StreamChunkSize+aead.Overhead()),
+		sealed: make([]byte, StreamChunkSize+aead.Overhead()),
+		buf:    make([]byte, 0, StreamChunkSize),
+	}
+	if _, err = io.ReadFull(o.src, o.nonce[:streamPrefix]); err != nil {
+		return nil, errors.New("[KMS] sealed stream too short")
+	}
+	return o, nil
+}
+
+func (o *streamOpener) Read(p []byte) (n int, err error) {
+	for len(o.out) == 0 {
+		if o.failed != nil {
+			return 0, o.failed
+		}
+		if o.done {
+			return 0, io.EOF
+		}
+		o.openChunk()
+	}
+	n = copy(p, o.out)
+	o.out = o.out[n:]
+	return n, nil
+}
+
+func (o *streamOpener) openChunk() {
+	size, err := io.ReadFull(o.src, o.sealed)
+	switch err {
+	case nil:
+		if _, err = o.src.Peek(1); err == io.EOF {
+			o.done = true
+		} else if err != nil {
+			o.failed = err
+			return
+		}
+	case io.EOF, io.ErrUnexpectedEOF:
+		o.done = true
+	default:
+		o.failed = err
+		return
+	}
+	streamNonce(o.nonce, o.index, o.done)
+	o.index++
+	o.out, err = o.aead.Open(o.buf[:0], o.nonce, o.sealed[:size], nil)
+	if err != nil {
+		o.failed = errors.New("[KMS] failed to open sealed stream : " + err.Error())
+	}
+}
+
+// streamNonce sets chunk's index and last chunk flag after the prefix of nonce.
+func streamNonce(nonce []byte, index uint32, last bool) {
+	binary.BigEndian.PutUint32(nonce[streamPrefix:], index)
+	nonce[nonceSize-1] = 0
+	if last {
+		nonce[nonceSize-1] = 1
+	}
+}
@@ -0,0 +1,302 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package object_store
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	obj "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/minio/minio-go"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+)
+
+// FilesystemBackend is an ObjectsStore that keeps objects on local disk, under RootPath.
+// Each bucket is a directory holding :
+//   - blobs/ : objects' contents, named after the sha256 of their content and sharded in two levels of sub-directories,
+//   - objects/ : one hard link per object name to the blob holding its content, sharded by name's first characters,
+//   - tmp/ : files being written.
+//
+// Objects with the same content share the same blob on disk. A blob is removed with its last object.
+type FilesystemBackend struct {
+	OSSConfig
+}
+
+const (
+	fsURIScheme = "fs"
+	blobsDir    = "blobs"
+	objectsDir  = "objects"
+	tmpDir      = "tmp"
+)
+
+func NewFilesystemBackend(config OSSConfig) (fb *FilesystemBackend, err error) {
+	if config.RootPath == "" {
+		return nil, errors.New("[ObjectStore] root path is mandatory for filesystem objects store")
+	}
+	fb = &FilesystemBackend{config}
+	buckets := []string{config.RawMsgBucket, config.AttachmentBucket}
+	if config.RemoteContentBucket != "" {
+		buckets = append(buckets, config.RemoteContentBucket)
+	}
+	for _, bucket := range buckets {
+		if bucket == "" || bucket != filepath.Base(bucket) {
+			return nil, fmt.Errorf("[ObjectStore] invalid bucket name <%s>", bucket)
+		}
+		for _, dir := range []string{blobsDir, objectsDir, tmpDir} {
+			err = os.MkdirAll(filepath.Join(config.RootPath, bucket, dir), 0700)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	return fb, nil
+}
+
+func (fb *FilesystemBackend) PutRawMessage(message_uuid obj.UUID, raw_email string) (uri string, err error) {
+	uri, _, err = fb.PutObject(message_uuid.String(), fb.RawMsgBucket, strings.NewReader(raw_email))
+	return
+}
+
+func (fb *FilesystemBackend) PutAttachment(attchId string, attch io.Reader) (uri string, size int64, err error) {
+	return fb.PutObject(attchId, fb.AttachmentBucket, attch)
+}
+
+func (fb *FilesystemBackend) PutRemoteContent(name string, content io.Reader) (uri string, size int64, err error) {
+	if fb.RemoteContentBucket == "" {
+		return "", 0, errors.New("[ObjectStore] no bucket configured for remote contents")
+	}
+	return fb.PutObject(name, fb.RemoteContentBucket, content)
+}
+
+func (fb *FilesystemBackend) GetRemoteContent(name string) (content io.Reader, err error) {
+	if fb.RemoteContentBucket == "" {
+		return nil, errors.New("[ObjectStore] no bucket configured for remote contents")
+	}
+	return fb.GetObject(fmt.Sprintf("%s://%s/%s", fsURIScheme, fb.RemoteContentBucket, name))
+}
+
+// PutObject writes object to a temp file, then moves it to its blob if no blob holds the same content yet,
+// and finally links object's name to the blob. Files and directories are synced at each step,
+// thus an object is either fully written or absent.
+func (fb *FilesystemBackend) PutObject(name, bucket string, object io.Reader) (uri string, size int64, err error) {
+	if err = fb.checkLocation(bucket, name); err != nil {
+		return "", 0, err
+	}
+	bucketPath := filepath.Join(fb.RootPath, bucket)
+
+	tmp, err := ioutil.TempFile(filepath.Join(bucketPath, tmpDir), "put-")
+	if err != nil {
+		return "", 0, err
+	}
+	defer os.Remove(tmp.Name()) // no-op once tmp has been renamed to blob
+	hash := sha256.New()
+	size, err = io.Copy(io.MultiWriter(tmp, hash), object)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	if e := tmp.Close(); err == nil {
+		err = e
+	}
+	if err != nil {
+		return "", 0, err
+	}
+
+	blob := fb.blobPath(bucket, hex.EncodeToString(hash.Sum(nil)))
+	if err = os.MkdirAll(filepath.Dir(blob), 0700); err != nil {
+		return "", 0, err
+	}
+	objPath := fb.objectPath(bucket, name)
+	if err = os.MkdirAll(filepath.Dir(objPath), 0700); err != nil {
+		return "", 0, err
+	}
+
+	// link a temp name to the blob, then rename it to object's name to atomically replace any previous object.
+	link := tmp.Name() + ".link"
+	for attempt := 0; ; attempt++ {
+		err = os.Link(blob, link)
+		if err == nil {
+			break
+		}
+		if !os.IsNotExist(err) || attempt > 0 {
+			return "", 0, err
+		}
+		// no blob with this content yet, our temp file becomes the blob
+		if err = os.Rename(tmp.Name(), blob); err != nil {
+			return "", 0, err
+		}
+		if err = syncDir(filepath.Dir(blob)); err != nil {
+			return "", 0, err
+		}
+	}
+	previousBlob, _ := fb.blobOf(bucket, objPath) // object is being overwritten
+	if err = os.Rename(link, objPath); err != nil {
+		os.Remove(link)
+		return "", 0, err
+	}
+	if err = syncDir(filepath.Dir(objPath)); err != nil {
+		return "", 0, err
+	}
+	if previousBlob != "" && previousBlob != blob {
+		if err = removeUnusedBlob(previousBlob); err != nil {
+			return "", 0, err
+		}
+	}
+
+	return fmt.Sprintf("%s://%s/%s", fsURIScheme, bucket, name), size, nil
+}
+
+// RemoveObject unlinks object's name, then removes its blob if no other object shares it.
+func (fb *FilesystemBackend) RemoveObject(objURI string) error {
+	bucket, name, err := fb.parseURI(objURI)
+	if err != nil {
+		return err
+	}
+	objPath := fb.objectPath(bucket, name)
+	blob, err := fb.blobOf(bucket, objPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if err = os.Remove(objPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err = syncDir(filepath.Dir(objPath)); err != nil {
+		return err
+	}
+	return removeUnusedBlob(blob)
+}
+
+// removeUnusedBlob removes blob if no object is linked to it anymore.
+// Objects linked to this blob meanwhile keep their content through their own hard link.
+func removeUnusedBlob(blob string) error {
+	info, err := os.Stat(blob)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	if stat, ok := info.Sys().(*syscall.Stat_t); ok && stat.Nlink <= 1 {
+		if err = os.Remove(blob); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return syncDir(filepath.Dir(blob))
+	}
+	return nil
+}
+
+func (fb *FilesystemBackend) GetObject(objURI string) (file io.Reader, err error) {
+	bucket, name, err := fb.parseURI(objURI)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(fb.objectPath(bucket, name))
+	if err != nil {
+		return nil, err
+	}
+	return &autoCloseFile{f}, nil
+}
+
+// autoCloseFile closes the underlying file as soon as it has been fully read,
+// because ObjectsStore's callers only get an io.Reader.
+type autoCloseFile struct {
+	*os.File
+}
+
+func (acf *autoCloseFile) Read(p []byte) (n int, err error) {
+	n, err = acf.File.Read(p)
+	if err != nil {
+		acf.File.Close()
+	}
+	return
+}
+
+func (fb *FilesystemBackend) StatObject(objURI string) (info minio.ObjectInfo, err error) {
+	bucket, name, err := fb.parseURI(objURI)
+	if err != nil {
+		return
+	}
+	fi, err := os.Stat(fb.objectPath(bucket, name))
+	if err != nil {
+		return
+	}
+	return minio.ObjectInfo{
+		Key:          name,
+		Size:         fi.Size(),
+		LastModified: fi.ModTime(),
+		ContentType:  "application/octet-stream",
+	}, nil
+}
+
+// parseURI returns bucket and object's name from an uri built by PutObject
+func (fb *FilesystemBackend) parseURI(objURI string) (bucket, name string, err error) {
+	uri, err := url.Parse(objURI)
+	if err != nil {
+		return
+	}
+	if uri.Scheme != fsURIScheme || len(uri.Path) < 2 {
+		return "", "", fmt.Errorf("[ObjectStore] invalid uri <%s> for filesystem objects store", objURI)
+	}
+	bucket, name = uri.Host, uri.Path[1:]
+	err = fb.checkLocation(bucket, name)
+	return
+}
+
+// checkLocation ensures bucket is one of the configured buckets,
+// and that name could not be used to reach a file outside of its bucket.
+func (fb *FilesystemBackend) checkLocation(bucket, name string) error {
+	if bucket != fb.RawMsgBucket && bucket != fb.AttachmentBucket && (bucket != fb.RemoteContentBucket || bucket == "") {
+		return fmt.Errorf("[ObjectStore] unknown bucket <%s>", bucket)
+	}
+	if name == "" || name == "." || name == ".." || name != filepath.Base(name) {
+		return fmt.Errorf("[ObjectStore] invalid object name <%s>", name)
+	}
+	return nil
+}
+
+func (fb *FilesystemBackend) blobPath(bucket, sum string) string {
+	return filepath.Join(fb.RootPath, bucket, blobsDir, sum[0:2], sum[2:4], sum)
+}
+
+func (fb *FilesystemBackend) objectPath(bucket, name string) string {
+	shard := name
+	if len(shard) > 2 {
+		shard = shard[0:2]
+	}
+	return filepath.Join(fb.RootPath, bucket, objectsDir, shard, name)
+}
+
+// blobOf hashes object's content to find the blob it is linked to
+func (fb *FilesystemBackend) blobOf(bucket, objPath string) (string, error) {
+	f, err := os.Open(objPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	return fb.blobPath(bucket, hex.EncodeToString(hash.Sum(nil))), nil
+}
+
+// syncDir flushes directory's entries to disk, to make renames and links durable
+func syncDir(path string) error {
+	dir, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer dir.Close()
+	return dir.Sync()
+}
@@ -0,0 +1,146 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package object_store
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestFilesystemBackend(t *testing.T) (*FilesystemBackend, func()) {
+	root, err := ioutil.TempDir("", "caliopen-objects")
+	if err != nil {
+		t.Fatal(err)
+	}
+	fb, err := NewFilesystemBackend(OSSConfig{
+		StoreType:        "fs",
+		RootPath:         root,
+		RawMsgBucket:     "raw-messages",
+		AttachmentBucket: "tmp-attachments",
+	})
+	if err != nil {
+		os.RemoveAll(root)
+		t.Fatal(err)
+	}
+	return fb, func() { os.RemoveAll(root) }
+}
+
+func countBlobs(t *testing.T, fb *FilesystemBackend, bucket string) (count int) {
+	err := filepath.Walk(filepath.Join(fb.RootPath, bucket, blobsDir), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			count++
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return
+}
+
+func readObject(t *testing.T, fb *FilesystemBackend, uri string) string {
+	reader, err := fb.GetObject(uri)
+	if err != nil {
+		t.Fatalf("GetObject(%s) failed : %s", uri, err)
+	}
+	content, err := ioutil.ReadAll(reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestFilesystemBackend_PutGetRemove(t *testing.T) {
+	fb, clean := newTestFilesystemBackend(t)
+	defer clean()
+
+	uri1, size, err := fb.PutAttachment("att-1", strings.NewReader("same content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if uri1 != "fs://tmp-attachments/att-1" || size != 12 {
+		t.Errorf("unexpected uri %s or size %d", uri1, size)
+	}
+	uri2, _, err := fb.PutAttachment("att-2", strings.NewReader("same content"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err = fb.PutAttachment("att-3", strings.NewReader("other content")); err != nil {
+		t.Fatal(err)
+	}
+	if n := countBlobs(t, fb, fb.AttachmentBucket); n != 2 {
+		t.Errorf("expected identical contents to share a blob, found %d blobs", n)
+	}
+	if content := readObject(t, fb, uri2); content != "same content" {
+		t.Errorf("unexpected content %q", content)
+	}
+	info, err := fb.StatObject(uri1)
+	if err != nil || info.Size != 12 || info.Key != "att-1" {
+		t.Errorf("unexpected stat %+v, err %v", info, err)
+	}
+
+	if err = fb.RemoveObject(uri1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fb.StatObject(uri1); err == nil {
+		t.Error("removed object should not exist anymore")
+	}
+	if content := readObject(t, fb, uri2); content != "same content" {
+		t.Errorf("object sharing a blob with a removed one has been altered : %q", content)
+	}
+	if err = fb.RemoveObject(uri2); err != nil {
+		t.Fatal(err)
+	}
+	if n := countBlobs(t, fb, fb.AttachmentBucket); n != 1 {
+		t.Errorf("expected blob to be removed with its last object, found %d blobs", n)
+	}
+	if err = fb.RemoveObject(uri2); err != nil {
+		t.Errorf("removing an absent object should not fail : %s", err)
+	}
+}
+
+func TestFilesystemBackend_Overwrite(t *testing.T) {
+	fb, clean := newTestFilesystemBackend(t)
+	defer clean()
+
+	var id [16]byte
+	uri, err := fb.PutRawMessage(id, "first version")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = fb.PutRawMessage(id, "second version"); err != nil {
+		t.Fatal(err)
+	}
+	if content := readObject(t, fb, uri); content != "second version" {
+		t.Errorf("unexpected content %q", content)
+	}
+	if n := countBlobs(t, fb, fb.RawMsgBucket); n != 1 {
+		t.Errorf("expected overwritten content's blob to be removed, found %d blobs", n)
+	}
+	entries, err := ioutil.ReadDir(filepath.Join(fb.RootPath, fb.RawMsgBucket, tmpDir))
+	if err != nil || len(entries) != 0 {
+		t.Errorf("temp files left behind : %d, err %v", len(entries), err)
+	}
+}
+
+func TestFilesystemBackend_InvalidLocations(t *testing.T) {
+	fb, clean := newTestFilesystemBackend(t)
+	defer clean()
+
+	if _, _, err := fb.PutObject("../escape", fb.AttachmentBucket, strings.NewReader("x")); err == nil {
+		t.Error("object name with path separator should be rejected")
+	}
+	if _, _, err := fb.PutObject("name", "unknown-bucket", strings.NewReader("x")); err == nil {
+		t.Error("unknown bucket should be rejected")
+	}
+	for _, uri := range []string{"s3://tmp-attachments/name", "fs://tmp-attachments/../../etc/passwd", "fs://other/name"} {
+		if _, err := fb.GetObject(uri); err == nil {
+			t.Errorf("uri %s should be rejected", uri)
+		}
+	}
+}
@@ -18,12 +18,15 @@ type (
 	}
 
 	OSSConfig struct {
-		Endpoint         string
-		AccessKey        string
-		SecretKey        string
-		Location         string
-		RawMsgBucket     string
-		AttachmentBucket string
+		StoreType           string // "s3" (default) or "fs"
+		RootPath            string // for "fs" store type only
+		Endpoint            string
+		AccessKey           string
+		SecretKey           string
+		Location            string
+		RawMsgBucket        string
+		AttachmentBucket    string
+		RemoteContentBucket string // cache of messages' remote contents, optional
 	}
 
 	ObjectsStore interface {
@@ -32,10 +35,20 @@ type (
 		RemoveObject(uri string) error
 		GetObject(uri string) (file io.Reader, err error)
 		StatObject(uri string) (info minio.ObjectInfo, err error)
+		PutRemoteContent(name string, content io.Reader) (uri string, size int64, err error)
+		GetRemoteContent(name string) (content io.Reader, err error)
 	}
 )
 
 func InitializeObjectsStore(config OSSConfig) (oss ObjectsStore, err error) {
+	if config.StoreType == "fs" {
+		fb, err := NewFilesystemBackend(config)
+		if err != nil {
+			return nil, err
+		}
+		return fb, nil
+	}
+
 	mb := new(MinioBackend)
 	mb.OSSConfig = config
 
@@ -71,5 +84,18 @@ func InitializeObjectsStore(config OSSConfig) (oss ObjectsStore, err error) {
 		}
 	}
 
+	if config.RemoteContentBucket != "" {
+		exists, err = mb.Client.BucketExists(config.RemoteContentBucket)
+		if err != nil || !exists {
+			// Create a new bucket for cached remote contents
+			err = mb.Client.MakeBucket(config.RemoteContentBucket, config.Location)
+			if err != nil {
+				logrus.WithError(err).Warnf("[ObjectStore] failed to create new bucket for remote contents")
+				mb.Client = nil
+				return
+			}
+		}
+	}
+
 	return mb, err
 }
@@ -0,0 +1,25 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package object_store
+
+import (
+	"errors"
+	"github.com/minio/minio-go"
+	"io"
+)
+
+func (mb *MinioBackend) PutRemoteContent(name string, content io.Reader) (uri string, size int64, err error) {
+	if mb.RemoteContentBucket == "" {
+		return "", 0, errors.New("[ObjectStore] no bucket configured for remote contents")
+	}
+	return mb.PutObject(name, mb.RemoteContentBucket, content)
+}
+
+func (mb *MinioBackend) GetRemoteContent(name string) (content io.Reader, err error) {
+	if mb.RemoteContentBucket == "" {
+		return nil, errors.New("[ObjectStore] no bucket configured for remote contents")
+	}
+	return mb.Client.GetObject(mb.RemoteContentBucket, name, minio.GetObjectOptions{})
+}
@@ -7,6 +7,7 @@ package caliopen
 import (
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/facilities/Notifications"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/facilities/REST"
 	log "github.com/Sirupsen/logrus"
@@ -24,8 +25,8 @@ type (
 
 		Cache backends.APICache
 
-		// NATS facility
-		nats *nats.Conn
+		// NATS facility, or in-process bus
+		nats backends.MessageBus
 		// LDA facility
 		LDAstore backends.LDAStore
 		// Notifications facility
@@ -45,10 +46,17 @@ func (facilities *CaliopenFacilities) initialize(config CaliopenConfig) (err err
 	facilities.config = config
 
 	// NATS facility initialization
-	facilities.nats, err = nats.Connect(config.NatsConfig.Url)
-	if err != nil {
-		log.WithError(err).Warn("CaliopenFacilities : initalization of NATS connexion failed")
-		return
+	switch config.NatsConfig.Url {
+	case "memory":
+		facilities.nats, _ = memory.InitializeMemoryBus()
+	default:
+		conn, e := nats.Connect(config.NatsConfig.Url)
+		if e != nil {
+			err = e
+			log.WithError(err).Warn("CaliopenFacilities : initalization of NATS connexion failed")
+			return
+		}
+		facilities.nats = conn
 	}
 
 	// REST facility initialization
@@ -0,0 +1,154 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+// package extractors pulls plain text out of common attachment formats
+// so that attachments' content could be indexed along with their message.
+package extractors
+
+import (
+	"context"
+	"errors"
+	"mime"
+	"path/filepath"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// an extractor returns the text found within content.
+// It must give up as soon as ctx is done, and never process more than limits.MaxSize bytes,
+// including decompressed data.
+type extractor func(ctx context.Context, content []byte, limits Limits) (string, error)
+
+// Limits bounds resources spent on a single attachment
+type Limits struct {
+	MaxSize int64         // attachments (or decompressed parts of them) larger than MaxSize bytes are not processed
+	MaxText int           // extracted text is truncated to MaxText bytes
+	Timeout time.Duration // max duration of an extraction
+}
+
+var (
+	ErrUnsupported = errors.New("[extractors] unsupported attachment format")
+	ErrTooLarge    = errors.New("[extractors] attachment exceeds size limit")
+	ErrTimeout     = errors.New("[extractors] text extraction timed out")
+)
+
+var DefaultLimits = Limits{
+	MaxSize: 10 * 1024 * 1024,
+	MaxText: 1024 * 1024,
+	Timeout: 5 * time.Second,
+}
+
+var byMimeType = map[string]extractor{
+	"text/plain":            plainText,
+	"text/csv":              plainText,
+	"text/markdown":         plainText,
+	"text/calendar":         plainText,
+	"text/vcard":            plainText,
+	"text/x-vcard":          plainText,
+	"text/html":             htmlText,
+	"application/xhtml+xml": htmlText,
+	"application/pdf":       pdfText,
+	"application/x-pdf":     pdfText,
+	"application/vnd.oasis.opendocument.text":                                   odfText,
+	"application/vnd.oasis.opendocument.spreadsheet":                            odfText,
+	"application/vnd.oasis.opendocument.presentation":                           odfText,
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document":   ooxmlText,
+	"application/vnd.openxmlformats-officedocument.spreadsheetml.sheet":         ooxmlText,
+	"application/vnd.openxmlformats-officedocument.presentationml.presentation": ooxmlText,
+}
+
+// used when mime type is missing or too generic (application/octet-stream…)
+var byExtension = map[string]extractor{
+	".txt":  plainText,
+	".csv":  plainText,
+	".md":   plainText,
+	".ics":  plainText,
+	".vcf":  plainText,
+	".htm":  htmlText,
+	".html": htmlText,
+	".pdf":  pdfText,
+	".odt":  odfText,
+	".ods":  odfText,
+	".odp":  odfText,
+	".docx": ooxmlText,
+	".xlsx": ooxmlText,
+	".pptx": ooxmlText,
+}
+
+// CanExtract returns true if an extractor is available for given content type or file name
+func CanExtract(contentType, fileName string) bool {
+	return getExtractor(contentType, fileName) != nil
+}
+
+// ExtractText returns the text found within an attachment's content, according to its content type or file name.
+// Limits are enforced : content larger than limits.MaxSize is rejected,
+// extraction is aborted after limits.Timeout and text is truncated to limits.MaxText bytes.
+func ExtractText(content []byte, contentType, fileName string, limits Limits) (text string, err error) {
+	extract := getExtractor(contentType, fileName)
+	if extract == nil {
+		return "", ErrUnsupported
+	}
+	if limits.MaxSize > 0 && int64(len(content)) > limits.MaxSize {
+		return "", ErrTooLarge
+	}
+
+	ctx := context.Background()
+	var cancel context.CancelFunc
+	if limits.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, limits.Timeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+
+	// extractors check ctx while they work and their input is bounded by limits.MaxSize,
+	// thus extraction is run synchronously : nothing is left running once ExtractText returns.
+	text, err = extract(ctx, content, limits)
+	if ctx.Err() == context.DeadlineExceeded {
+		return "", ErrTimeout
+	}
+	if err != nil {
+		return "", err
+	}
+	return truncate(normalizeSpaces(text), limits.MaxText), nil
+}
+
+func getExtractor(contentType, fileName string) extractor {
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if extract, ok := byMimeType[strings.ToLower(mediaType)]; ok {
+			return extract
+		}
+	}
+	if extract, ok := byExtension[strings.ToLower(filepath.Ext(fileName))]; ok {
+		return extract
+	}
+	return nil
+}
+
+// normalizeSpaces removes empty lines and trailing spaces that extractors usually produce
+func normalizeSpaces(text string) string {
+	lines := strings.Split(text, "\n")
+	kept := make([]string, 0, len(lines))
+	for _, line := range lines {
+		line = strings.TrimRight(line, " \t\r")
+		if strings.TrimSpace(line) != "" {
+			kept = append(kept, line)
+		}
+	}
+	return strings.Join(kept, "\n")
+}
+
+// truncate cuts text to max bytes at most, without breaking an utf-8 sequence
+func truncate(text string, max int) string {
+	if max <= 0 || len(text) <= max {
+		return text
+	}
+	for max > 0 && !utf8.RuneStart(text[max]) {
+		max--
+	}
+	return text[:max]
+}
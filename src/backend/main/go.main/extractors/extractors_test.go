@@ -0,0 +1,153 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package extractors
+
+import (
+	"archive/zip"
+	"bytes"
+	"compress/zlib"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExtractPlainText(t *testing.T) {
+	text, err := ExtractText([]byte("hello\r\n\r\nworld  \n"), "text/plain; charset=utf-8", "", DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "hello\nworld" {
+		t.Errorf("unexpected text %q", text)
+	}
+	text, err = ExtractText([]byte("caf\xe9"), "application/octet-stream", "menu.txt", DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "café" {
+		t.Errorf("latin-1 content not converted, got %q", text)
+	}
+}
+
+func TestExtractPdf(t *testing.T) {
+	var content bytes.Buffer
+	w := zlib.NewWriter(&content)
+	w.Write([]byte("BT /F1 12 Tf 72 712 Td (Quarterly report) Tj 0 -14 Td [(revenue) -300 (\\(up\\))] TJ ET"))
+	w.Close()
+	pdf := fmt.Sprintf("%%PDF-1.4\n1 0 obj\n<< /Type /Catalog >>\nendobj\n4 0 obj\n<< /Length %d /Filter /FlateDecode >>\nstream\n%s\nendstream\nendobj\n%%%%EOF\n",
+		content.Len(), content.String())
+
+	text, err := ExtractText([]byte(pdf), "application/pdf", "report.pdf", DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Quarterly report\nrevenue (up)" {
+		t.Errorf("unexpected text %q", text)
+	}
+}
+
+func TestExtractOffice(t *testing.T) {
+	odt := zipped(t, map[string]string{
+		"mimetype":    "application/vnd.oasis.opendocument.text",
+		"content.xml": `<office:document-content xmlns:office="o" xmlns:text="t"><office:body><text:h>Title</text:h><text:p>first<text:s/>paragraph</text:p></office:body></office:document-content>`,
+	})
+	text, err := ExtractText(odt, "application/vnd.oasis.opendocument.text", "doc.odt", DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Title\nfirst paragraph" {
+		t.Errorf("unexpected odt text %q", text)
+	}
+
+	docx := zipped(t, map[string]string{
+		"[Content_Types].xml": `<Types/>`,
+		"word/document.xml":   `<w:document xmlns:w="w"><w:body><w:p><w:r><w:t>Hello</w:t></w:r><w:r><w:t xml:space="preserve"> docx</w:t></w:r></w:p><w:p><w:r><w:instrText>ignored</w:instrText></w:r></w:p></w:body></w:document>`,
+	})
+	text, err = ExtractText(docx, "application/octet-stream", "doc.docx", DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "Hello docx" {
+		t.Errorf("unexpected docx text %q", text)
+	}
+}
+
+func TestExtractLimits(t *testing.T) {
+	if _, err := ExtractText([]byte("GIF89a"), "image/gif", "pic.gif", DefaultLimits); err != ErrUnsupported {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+	limits := Limits{MaxSize: 10, MaxText: 4, Timeout: time.Second}
+	if _, err := ExtractText([]byte(strings.Repeat("a", 11)), "text/plain", "", limits); err != ErrTooLarge {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	text, err := ExtractText([]byte("éééé"), "text/plain", "", limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text != "éé" {
+		t.Errorf("text not truncated on rune boundary: %q", text)
+	}
+}
+
+func TestExtractBudget(t *testing.T) {
+	// many streams that inflate to 1MB of text each
+	var stream bytes.Buffer
+	w := zlib.NewWriter(&stream)
+	w.Write([]byte("BT (" + strings.Repeat("a", 1024*1024) + ") Tj ET"))
+	w.Close()
+	pdf := bytes.NewBufferString("%PDF-1.4\n")
+	for i := 0; i < 50; i++ {
+		fmt.Fprintf(pdf, "%d 0 obj << /Filter /FlateDecode >> stream\n%s\nendstream endobj\n", i+1, stream.Bytes())
+	}
+	limits := Limits{MaxSize: 2 * 1024 * 1024, Timeout: 10 * time.Second}
+	text, err := ExtractText(pdf.Bytes(), "application/pdf", "", limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(text) > int(limits.MaxSize) {
+		t.Errorf("inflated more than size limit : %d bytes of text", len(text))
+	}
+
+	limits.Timeout = time.Nanosecond
+	if _, err := ExtractText(pdf.Bytes(), "application/pdf", "", limits); err != ErrTimeout {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+}
+
+func TestExtractorsContext(t *testing.T) {
+	var stream bytes.Buffer
+	w := zlib.NewWriter(&stream)
+	w.Write([]byte("BT (hello) Tj ET"))
+	w.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := inflate(ctx, stream.Bytes(), 1024); err == nil {
+		t.Error("inflate : expected an error once context is done")
+	}
+	for name, extract := range map[string]extractor{"plain": plainText, "html": htmlText} {
+		if _, err := extract(ctx, []byte("<p>caf\xe9</p>"), DefaultLimits); err == nil {
+			t.Errorf("%s : expected an error once context is done", name)
+		}
+	}
+}
+
+func zipped(t *testing.T, files map[string]string) []byte {
+	var buf bytes.Buffer
+	w := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, err := w.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		f.Write([]byte(content))
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
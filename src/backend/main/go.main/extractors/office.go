@@ -0,0 +1,156 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package extractors
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"io"
+	"path"
+	"sort"
+	"strings"
+)
+
+// odfText extracts text from OpenDocument files (odt, ods, odp).
+// All text nodes of content.xml are kept, paragraphs and headings are separated by new lines.
+func odfText(ctx context.Context, content []byte, limits Limits) (string, error) {
+	return zippedXmlText(ctx, content, limits,
+		func(name string) bool { return name == "content.xml" },
+		nil,
+		map[string]bool{"p": true, "h": true, "table-row": true},
+	)
+}
+
+// ooxmlText extracts text from Office Open XML files (docx, xlsx, pptx).
+// Only <t> nodes hold document's text within these formats.
+func ooxmlText(ctx context.Context, content []byte, limits Limits) (string, error) {
+	return zippedXmlText(ctx, content, limits,
+		func(name string) bool {
+			switch {
+			case name == "word/document.xml",
+				name == "xl/sharedStrings.xml",
+				strings.HasPrefix(name, "word/header") && path.Ext(name) == ".xml",
+				strings.HasPrefix(name, "word/footer") && path.Ext(name) == ".xml",
+				strings.HasPrefix(name, "ppt/slides/slide") && path.Ext(name) == ".xml":
+				return true
+			}
+			return false
+		},
+		map[string]bool{"t": true},
+		map[string]bool{"p": true, "si": true},
+	)
+}
+
+// zippedXmlText walks through xml files of a zip archive that match `wanted` func.
+// It collects char data found within `textElems` elements (or within any element if textElems is nil)
+// and outputs a new line each time a `breakElems` element is closed.
+func zippedXmlText(ctx context.Context, content []byte, limits Limits,
+	wanted func(name string) bool, textElems, breakElems map[string]bool) (string, error) {
+
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		return "", err
+	}
+	files := []*zip.File{}
+	for _, f := range archive.File {
+		if wanted(f.Name) {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return "", ErrUnsupported
+	}
+	// keep slides/sheets in natural order (slide2 before slide10)
+	sort.Slice(files, func(i, j int) bool {
+		if len(files[i].Name) != len(files[j].Name) {
+			return len(files[i].Name) < len(files[j].Name)
+		}
+		return files[i].Name < files[j].Name
+	})
+
+	var text bytes.Buffer
+	budget := limits.MaxSize // bytes left to decompress, all files together
+	for _, f := range files {
+		if limits.MaxSize > 0 && int64(f.UncompressedSize64) > budget {
+			return "", ErrTooLarge
+		}
+		rc, err := f.Open()
+		if err != nil {
+			return "", err
+		}
+		var reader io.Reader = ctxReader{ctx, rc}
+		var limited *io.LimitedReader
+		if limits.MaxSize > 0 {
+			// do not trust headers' size
+			limited = &io.LimitedReader{R: reader, N: budget}
+			reader = limited
+		}
+		err = xmlText(ctx, reader, &text, limits, textElems, breakElems)
+		rc.Close()
+		if err != nil {
+			return "", err
+		}
+		if limited != nil {
+			budget = limited.N
+		}
+		if limits.MaxText > 0 && text.Len() > limits.MaxText {
+			break
+		}
+	}
+	return text.String(), nil
+}
+
+func xmlText(ctx context.Context, r io.Reader, out *bytes.Buffer, limits Limits, textElems, breakElems map[string]bool) error {
+	decoder := xml.NewDecoder(r)
+	decoder.Strict = false
+	depth := 0 // how deep we are within text elements
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			if textElems[t.Name.Local] {
+				depth++
+			}
+			switch t.Name.Local {
+			case "tab":
+				out.WriteByte('\t')
+			case "br", "line-break":
+				out.WriteByte('\n')
+			case "s":
+				out.WriteByte(' ')
+			}
+		case xml.EndElement:
+			if textElems[t.Name.Local] && depth > 0 {
+				depth--
+			}
+			if breakElems[t.Name.Local] {
+				out.WriteByte('\n')
+			}
+			if t.Name.Local == "table-cell" || t.Name.Local == "c" {
+				out.WriteByte('\t')
+			}
+		case xml.CharData:
+			if textElems == nil || depth > 0 {
+				out.Write(t)
+			}
+		}
+		if limits.MaxText > 0 && out.Len() > limits.MaxText {
+			return nil
+		}
+	}
+}
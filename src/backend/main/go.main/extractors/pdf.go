@@ -0,0 +1,395 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package extractors
+
+import (
+	"bytes"
+	"compress/zlib"
+	"context"
+	"encoding/hex"
+	"io"
+	"io/ioutil"
+	"strconv"
+	"unicode"
+	"unicode/utf16"
+)
+
+// pdfText is a naive PDF text extractor :
+// it inflates uncompressed and FlateDecode'd streams, then interprets text showing operators (Tj, TJ, ', ")
+// found within content streams. Fonts' encodings are not resolved, strings are read as latin-1 or UTF-16BE,
+// which is good enough for indexing most PDF produced by office suites.
+// Encrypted PDF and exotic filters are ignored.
+func pdfText(ctx context.Context, content []byte, limits Limits) (string, error) {
+	if !bytes.HasPrefix(bytes.TrimLeft(content, "\x00\t\r\n "), []byte("%PDF")) {
+		return "", ErrUnsupported
+	}
+	if bytes.Contains(content, []byte("/Encrypt")) {
+		return "", ErrUnsupported
+	}
+
+	var text bytes.Buffer
+	budget := limits.MaxSize // bytes left to inflate, all streams together
+	pos := 0
+	for {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		i := bytes.Index(content[pos:], []byte("stream"))
+		if i < 0 {
+			break
+		}
+		start := pos + i
+		pos = start + len("stream")
+		// skip "endstream" keywords
+		if start >= 3 && string(content[start-3:start]) == "end" {
+			continue
+		}
+		// stream keyword must be followed by EOL
+		switch {
+		case bytes.HasPrefix(content[pos:], []byte("\r\n")):
+			pos += 2
+		case bytes.HasPrefix(content[pos:], []byte("\n")), bytes.HasPrefix(content[pos:], []byte("\r")):
+			pos++
+		default:
+			continue
+		}
+		end := bytes.Index(content[pos:], []byte("endstream"))
+		if end < 0 {
+			break
+		}
+		data := content[pos : pos+end]
+		dict := streamDictionary(content[:start])
+		pos = pos + end + len("endstream")
+
+		if !isContentStream(dict) {
+			continue
+		}
+		if bytes.Contains(dict, []byte("/FlateDecode")) {
+			if limits.MaxSize > 0 && budget <= 0 {
+				break
+			}
+			inflated, err := inflate(ctx, data, budget)
+			if ctx.Err() != nil {
+				return "", ctx.Err()
+			}
+			if limits.MaxSize > 0 {
+				budget -= int64(len(inflated))
+			}
+			if err != nil || len(inflated) == 0 {
+				continue
+			}
+			data = inflated
+		} else if bytes.Contains(dict, []byte("/Filter")) {
+			// unsupported filter
+			continue
+		}
+		if err := showText(ctx, data, &text); err != nil {
+			return "", err
+		}
+		if limits.MaxText > 0 && text.Len() > limits.MaxText {
+			break
+		}
+	}
+	return text.String(), nil
+}
+
+// max distance between an object's beginning and its stream, beyond which the dictionary is truncated.
+// It keeps the search linear in documents with many streams.
+const maxDictionaryLength = 4096
+
+// streamDictionary returns the dictionary of the object that holds the stream beginning at end of `before`
+func streamDictionary(before []byte) []byte {
+	if len(before) > maxDictionaryLength {
+		before = before[len(before)-maxDictionaryLength:]
+	}
+	objStart := bytes.LastIndex(before, []byte(" obj"))
+	if objStart < 0 {
+		objStart = 0
+	}
+	return before[objStart:]
+}
+
+// only content streams could hold text showing operators
+func isContentStream(dict []byte) bool {
+	for _, t := range [][]byte{
+		[]byte("/Image"),
+		[]byte("/XRef"),
+		[]byte("/ObjStm"),
+		[]byte("/Metadata"),
+		[]byte("/Length1"), // embedded font files
+		[]byte("/FontFile"),
+		[]byte("/DCTDecode"),
+		[]byte("/JPXDecode"),
+		[]byte("/CCITTFaxDecode"),
+		[]byte("/JBIG2Decode"),
+	} {
+		if bytes.Contains(dict, t) {
+			return false
+		}
+	}
+	return true
+}
+
+// inflate decompresses data up to max bytes, it stops reading as soon as ctx is done
+func inflate(ctx context.Context, data []byte, max int64) ([]byte, error) {
+	r, err := zlib.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	var reader io.Reader = ctxReader{ctx, r}
+	if max > 0 {
+		reader = io.LimitReader(reader, max)
+	}
+	inflated, err := ioutil.ReadAll(reader)
+	if err != nil && len(inflated) == 0 {
+		// truncated streams are common, keep what we got
+		return nil, err
+	}
+	return inflated, nil
+}
+
+// ctxReader fails as soon as ctx is done, thus long decompressions can be aborted
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (cr ctxReader) Read(p []byte) (int, error) {
+	if err := cr.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return cr.r.Read(p)
+}
+
+// showText interprets a content stream and writes text operators' strings to out
+func showText(ctx context.Context, data []byte, out *bytes.Buffer) error {
+	var (
+		operands [][]byte  // strings operands of current operator
+		numbers  []float64 // numeric operands of current operator
+		inArray  bool
+		array    bytes.Buffer
+	)
+	i := 0
+	for tokens := 0; i < len(data); tokens++ {
+		if tokens%4096 == 0 && ctx.Err() != nil {
+			return ctx.Err()
+		}
+		c := data[i]
+		switch {
+		case isPdfSpace(c):
+			i++
+		case c == '%':
+			for i < len(data) && data[i] != '\n' && data[i] != '\r' {
+				i++
+			}
+		case c == '(':
+			s, next := literalString(data, i)
+			i = next
+			if inArray {
+				array.Write(s)
+			} else {
+				operands = append(operands, s)
+			}
+		case c == '<' && i+1 < len(data) && data[i+1] == '<':
+			i += 2
+		case c == '>' && i+1 < len(data) && data[i+1] == '>':
+			i += 2
+		case c == '<':
+			s, next := hexString(data, i)
+			i = next
+			if inArray {
+				array.Write(s)
+			} else {
+				operands = append(operands, s)
+			}
+		case c == '[':
+			inArray = true
+			array.Reset()
+			i++
+		case c == ']':
+			inArray = false
+			operands = append(operands, append([]byte{}, array.Bytes()...))
+			i++
+		case c == '/':
+			i++
+			for i < len(data) && !isPdfSpace(data[i]) && !isPdfDelimiter(data[i]) {
+				i++
+			}
+		default:
+			start := i
+			for i < len(data) && !isPdfSpace(data[i]) && !isPdfDelimiter(data[i]) {
+				i++
+			}
+			if i == start {
+				// lonely delimiter
+				i++
+				continue
+			}
+			token := string(data[start:i])
+			if n, err := strconv.ParseFloat(token, 64); err == nil {
+				if inArray {
+					// large negative kerning within TJ arrays usually means a space between words
+					if n < -200 {
+						array.WriteByte(' ')
+					}
+				} else {
+					numbers = append(numbers, n)
+				}
+				continue
+			}
+			switch token {
+			case "Tj", "TJ":
+				for _, s := range operands {
+					out.WriteString(decodePdfString(s))
+				}
+			case "'", "\"":
+				out.WriteByte('\n')
+				for _, s := range operands {
+					out.WriteString(decodePdfString(s))
+				}
+			case "T*", "ET":
+				out.WriteByte('\n')
+			case "Td", "TD":
+				if len(numbers) >= 2 && numbers[len(numbers)-1] != 0 {
+					out.WriteByte('\n')
+				} else {
+					out.WriteByte(' ')
+				}
+			}
+			operands = operands[:0]
+			numbers = numbers[:0]
+		}
+	}
+	return nil
+}
+
+func literalString(data []byte, start int) (s []byte, next int) {
+	depth := 0
+	i := start
+	for ; i < len(data); i++ {
+		c := data[i]
+		switch c {
+		case '(':
+			depth++
+			if depth == 1 {
+				continue
+			}
+		case ')':
+			depth--
+			if depth == 0 {
+				return s, i + 1
+			}
+		case '\\':
+			i++
+			if i >= len(data) {
+				return s, i
+			}
+			switch e := data[i]; e {
+			case 'n':
+				s = append(s, '\n')
+			case 'r':
+				s = append(s, '\r')
+			case 't':
+				s = append(s, '\t')
+			case 'b', 'f':
+			case '\r', '\n':
+				// line continuation
+			default:
+				if e >= '0' && e <= '7' {
+					octal := 0
+					for j := 0; j < 3 && i < len(data) && data[i] >= '0' && data[i] <= '7'; j++ {
+						octal = octal*8 + int(data[i]-'0')
+						i++
+					}
+					i--
+					s = append(s, byte(octal))
+				} else {
+					s = append(s, e)
+				}
+			}
+			continue
+		}
+		s = append(s, c)
+	}
+	return s, i
+}
+
+func hexString(data []byte, start int) (s []byte, next int) {
+	end := bytes.IndexByte(data[start:], '>')
+	if end < 0 {
+		return nil, len(data)
+	}
+	digits := make([]byte, 0, end)
+	for _, c := range data[start+1 : start+end] {
+		if !isPdfSpace(c) {
+			digits = append(digits, c)
+		}
+	}
+	if len(digits)%2 == 1 {
+		digits = append(digits, '0')
+	}
+	s = make([]byte, len(digits)/2)
+	if _, err := hex.Decode(s, digits); err != nil {
+		return nil, start + end + 1
+	}
+	return s, start + end + 1
+}
+
+// decodePdfString reads s as UTF-16BE if it has a BOM or looks like 2-bytes chars, as latin-1 otherwise.
+// Non printable chars are dropped.
+func decodePdfString(s []byte) string {
+	var runes []rune
+	if len(s) >= 2 && len(s)%2 == 0 && (s[0] == 0xfe && s[1] == 0xff || looksLikeUTF16(s)) {
+		if s[0] == 0xfe && s[1] == 0xff {
+			s = s[2:]
+		}
+		units := make([]uint16, len(s)/2)
+		for i := range units {
+			units[i] = uint16(s[2*i])<<8 | uint16(s[2*i+1])
+		}
+		runes = utf16.Decode(units)
+	} else {
+		runes = make([]rune, len(s))
+		for i, b := range s {
+			runes[i] = rune(b)
+		}
+	}
+	kept := runes[:0]
+	for _, r := range runes {
+		if unicode.IsPrint(r) || r == '\n' || r == '\t' {
+			kept = append(kept, r)
+		}
+	}
+	return string(kept)
+}
+
+func looksLikeUTF16(s []byte) bool {
+	zeros := 0
+	for i := 0; i < len(s); i += 2 {
+		if s[i] == 0 {
+			zeros++
+		}
+	}
+	return zeros*2 >= len(s)/2
+}
+
+func isPdfSpace(c byte) bool {
+	switch c {
+	case ' ', '\t', '\r', '\n', '\f', 0:
+		return true
+	}
+	return false
+}
+
+func isPdfDelimiter(c byte) bool {
+	switch c {
+	case '(', ')', '<', '>', '[', ']', '{', '}', '/', '%':
+		return true
+	}
+	return false
+}
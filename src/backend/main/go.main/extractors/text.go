@@ -0,0 +1,51 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package extractors
+
+import (
+	"bytes"
+	"context"
+	"github.com/jaytaylor/html2text"
+	"strings"
+	"unicode/utf8"
+)
+
+// plainText returns content as is if it is valid utf-8,
+// otherwise content is assumed to be latin-1 encoded.
+func plainText(ctx context.Context, content []byte, limits Limits) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	content = bytes.TrimPrefix(content, []byte("\xef\xbb\xbf")) // BOM
+	if utf8.Valid(content) {
+		return string(content), nil
+	}
+	runes := make([]rune, len(content))
+	for i, b := range content {
+		if i%ctxCheckInterval == 0 && ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		runes[i] = rune(b)
+	}
+	return string(runes), nil
+}
+
+// htmlText parses html as it is read, thus parsing stops as soon as ctx is done
+func htmlText(ctx context.Context, content []byte, limits Limits) (string, error) {
+	html, err := plainText(ctx, content, limits)
+	if err != nil {
+		return "", err
+	}
+	text, err := html2text.FromReader(ctxReader{ctx, strings.NewReader(html)})
+	if ctx.Err() != nil {
+		return "", ctx.Err()
+	}
+	return text, err
+}
+
+// number of bytes processed between two checks of ctx by loops that do not read from a ctxReader
+const ctxCheckInterval = 64 * 1024
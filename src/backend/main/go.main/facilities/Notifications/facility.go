@@ -8,10 +8,10 @@ import (
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/index/elasticsearch"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/cassandra"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gocql/gocql"
-	"github.com/nats-io/go-nats"
 	"os"
 	"time"
 )
@@ -29,7 +29,7 @@ type (
 		adminLocalID *LocalIdentity // Admin's local identity used to send emails
 		config       *NotifierConfig
 		index        backends.NotificationsIndex
-		natsQueue    *nats.Conn
+		natsQueue    backends.MessageBus
 		natsTopics   map[string]string
 		store        backends.NotificationsStore
 		log          *log.Logger
@@ -38,7 +38,7 @@ type (
 
 // NewNotificationsFacility initialises the notifiers
 // it takes the same store & index configurations than the REST API for now
-func NewNotificationsFacility(config CaliopenConfig, queue *nats.Conn) (notifier *Notifier) {
+func NewNotificationsFacility(config CaliopenConfig, queue backends.MessageBus) (notifier *Notifier) {
 	notifier = new(Notifier)
 	notifier.log = log.New()
 	notifier.log.Out = os.Stdout
@@ -62,8 +62,10 @@ func NewNotificationsFacility(config CaliopenConfig, queue *nats.Conn) (notifier
 			Keyspace:    config.RESTstoreConfig.Keyspace,
 			Consistency: gocql.Consistency(config.RESTstoreConfig.Consistency),
 		}
-		if config.RESTstoreConfig.ObjStoreType == "s3" {
+		if config.RESTstoreConfig.ObjStoreType == "s3" || config.RESTstoreConfig.ObjStoreType == "fs" {
 			cassaConfig.WithObjStore = true
+			cassaConfig.OSSConfig.StoreType = config.RESTstoreConfig.ObjStoreType
+			cassaConfig.OSSConfig.RootPath = config.RESTstoreConfig.OSSConfig.RootPath
 			cassaConfig.OSSConfig.Endpoint = config.RESTstoreConfig.OSSConfig.Endpoint
 			cassaConfig.OSSConfig.AccessKey = config.RESTstoreConfig.OSSConfig.AccessKey
 			cassaConfig.OSSConfig.SecretKey = config.RESTstoreConfig.OSSConfig.SecretKey
@@ -76,6 +78,9 @@ func NewNotificationsFacility(config CaliopenConfig, queue *nats.Conn) (notifier
 			log.WithError(err).Fatalf("Initalization of %s backend failed", config.RESTstoreConfig.BackendName)
 		}
 		notifier.store = backends.NotificationsStore(backend) // type conversion
+	case "memory":
+		backend, _ := memory.InitializeMemoryBackend()
+		notifier.store = backends.NotificationsStore(backend) // type conversion
 	default:
 		log.Fatalf("Unknown backend: %s", config.RESTstoreConfig.BackendName)
 	}
@@ -90,6 +95,9 @@ func NewNotificationsFacility(config CaliopenConfig, queue *nats.Conn) (notifier
 			log.WithError(err).Fatalf("Initalization of %s index failed", config.RESTindexConfig.IndexName)
 		}
 		notifier.index = backends.NotificationsIndex(index) // type conversion
+	case "memory":
+		index, _ := memory.InitializeMemoryIndex()
+		notifier.index = backends.NotificationsIndex(index) // type conversion
 	default:
 		log.Fatalf("Unknown index: %s", config.RESTindexConfig.IndexName)
 	}
@@ -9,13 +9,17 @@ import (
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/cache/redis"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/index/elasticsearch"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/cassandra"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/facilities/Notifications"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/geoip"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/remote"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gocql/gocql"
-	"github.com/nats-io/go-nats"
 	"github.com/tidwall/gjson"
 	"io"
+	"sync"
+	"time"
 )
 
 type (
@@ -33,15 +37,21 @@ type (
 		DeleteContact(userID, contactID string) error
 		ContactIdentities(user_id, contact_id string) (identities []ContactIdentity, err error)
 		//messages
-		GetMessagesList(filter IndexSearch) (messages []*Message, totalFound int64, err error)
-		GetMessage(user_id, message_id string) (message *Message, err error)
+		GetMessagesList(filter IndexSearch, loadRemote bool) (messages []*Message, totalFound int64, err error)
+		GetMessage(user_id, message_id string, loadRemote bool) (message *Message, err error)
+		CreateDraft(user_id string, payload []byte) (*Message, CaliopenError)
+		PatchDraft(patch []byte, user_id, msg_id string) CaliopenError
 		SendDraft(user_id, msg_id string) (msg *Message, err error)
 		SetMessageUnread(user_id, message_id string, status bool) error
+		DeleteMessage(user_id, message_id string) CaliopenError
+		RestoreMessage(user_id, message_id string) CaliopenError
 		GetRawMessage(raw_message_id string) (message []byte, err error)
 		//attachments
 		AddAttachment(user_id, message_id, filename, content_type string, file io.Reader) (tempId string, err error)
 		DeleteAttachment(user_id, message_id, attchmt_id string) CaliopenError
 		OpenAttachment(user_id, message_id, attchmtIndex string) (meta map[string]string, content io.Reader, err error)
+		//remote content
+		FetchRemoteContent(user_id, message_id, remoteURL, signature string) (content []byte, contentType string, err CaliopenError)
 		//tags
 		RetrieveUserTags(user_id string) (tags []Tag, err CaliopenError)
 		CreateTag(tag *Tag) CaliopenError
@@ -57,6 +67,7 @@ type (
 		RequestPasswordReset(payload PasswordResetRequest, notifier Notifications.Notifiers) error
 		ValidatePasswordResetToken(token string) (session *Pass_reset_session, err error)
 		ResetUserPassword(token, new_password string, notifier Notifications.Notifiers) error
+		RequestUserDeletion(userId string) (*UserDeletion, CaliopenError)
 		//devices
 		CreateDevice(device *Device) CaliopenError
 		RetrieveDevices(userId string) ([]Device, CaliopenError)
@@ -64,22 +75,46 @@ type (
 		UpdateDevice(device, oldDevice *Device, update map[string]interface{}) CaliopenError
 		PatchDevice(patch []byte, userId, deviceId string) CaliopenError
 		DeleteDevice(userId, deviceId string) CaliopenError
+		RevokeDevice(userId, deviceId, byDeviceId, ipAddress string, notifier Notifications.Notifiers) CaliopenError
+		RecordDeviceConnection(userId, deviceId, ipAddress string, notifier Notifications.Notifiers)
+		//saved searches
+		RetrieveSavedSearches(userId string, ILrange [2]int8) ([]SavedSearch, CaliopenError)
+		CreateSavedSearch(search *SavedSearch) CaliopenError
+		RetrieveSavedSearch(userId, searchId string, ILrange [2]int8) (*SavedSearch, CaliopenError)
+		PatchSavedSearch(patch []byte, userId, searchId string) CaliopenError
+		DeleteSavedSearch(userId, searchId string) CaliopenError
+		RunSavedSearch(userId, searchId string, ILrange [2]int8, limit, offset int, loadRemote bool) (messages []*Message, totalFound int64, err CaliopenError)
+		//exports
+		CreateExport(userId, format string, notifier Notifications.Notifiers) (*UserExport, CaliopenError)
+		RetrieveExport(userId, exportId string) (*UserExport, CaliopenError)
+		OpenExport(userId, exportId string) (*UserExport, io.Reader, CaliopenError)
+		DeleteExport(userId, exportId string) CaliopenError
+		//imports
+		CreateImport(userId string, archive io.Reader) (*UserImport, CaliopenError)
+		RetrieveImport(userId, importId string) (*UserImport, CaliopenError)
 	}
 	RESTfacility struct {
-		store      backends.APIStorage
-		index      backends.APIIndex
-		Cache      backends.APICache
-		nats_conn  *nats.Conn
-		natsTopics map[string]string
+		store         backends.APIStorage
+		index         backends.APIIndex
+		Cache         backends.APICache
+		nats_conn     backends.MessageBus
+		natsTopics    map[string]string
+		geoip         *geoip.Resolver
+		geoipConfig   GeoIPConfig
+		seen          map[string]time.Time // last connections recorded, by user, device and address
+		seenLock      sync.Mutex
+		remoteFetcher *remote.Fetcher // nil if remote content proxy is disabled
+		remoteSigner  *remote.Signer
 	}
 )
 
-func NewRESTfacility(config CaliopenConfig, nats_conn *nats.Conn) (rest_facility *RESTfacility) {
+func NewRESTfacility(config CaliopenConfig, nats_conn backends.MessageBus) (rest_facility *RESTfacility) {
 	rest_facility = new(RESTfacility)
 	rest_facility.nats_conn = nats_conn
 	rest_facility.natsTopics = map[string]string{
 		Nats_outSMTP_topicKey:  config.NatsConfig.OutSMTP_topic,
 		Nats_Contacts_topicKey: config.NatsConfig.Contacts_topic,
+		Nats_IMAP_topicKey:     config.NatsConfig.IMAP_topic,
 	}
 
 	switch config.RESTstoreConfig.BackendName {
@@ -89,20 +124,31 @@ func NewRESTfacility(config CaliopenConfig, nats_conn *nats.Conn) (rest_facility
 			Keyspace:    config.RESTstoreConfig.Keyspace,
 			Consistency: gocql.Consistency(config.RESTstoreConfig.Consistency),
 		}
-		if config.RESTstoreConfig.ObjStoreType == "s3" {
+		if config.RESTstoreConfig.ObjStoreType == "s3" || config.RESTstoreConfig.ObjStoreType == "fs" {
 			cassaConfig.WithObjStore = true
+			cassaConfig.OSSConfig.StoreType = config.RESTstoreConfig.ObjStoreType
+			cassaConfig.OSSConfig.RootPath = config.RESTstoreConfig.OSSConfig.RootPath
 			cassaConfig.OSSConfig.Endpoint = config.RESTstoreConfig.OSSConfig.Endpoint
 			cassaConfig.OSSConfig.AccessKey = config.RESTstoreConfig.OSSConfig.AccessKey
 			cassaConfig.OSSConfig.SecretKey = config.RESTstoreConfig.OSSConfig.SecretKey
 			cassaConfig.OSSConfig.Location = config.RESTstoreConfig.OSSConfig.Location
 			cassaConfig.OSSConfig.RawMsgBucket = config.RESTstoreConfig.OSSConfig.Buckets["raw_messages"]
 			cassaConfig.OSSConfig.AttachmentBucket = config.RESTstoreConfig.OSSConfig.Buckets["temporary_attachments"]
+			cassaConfig.OSSConfig.RemoteContentBucket = config.RESTstoreConfig.OSSConfig.Buckets["remote_contents"]
+		}
+		if config.RESTstoreConfig.Encryption == "local" {
+			cassaConfig.WithEncryption = true
+			cassaConfig.KMSConfig.KMSType = config.RESTstoreConfig.Encryption
+			cassaConfig.KMSConfig.KeyringPath = config.RESTstoreConfig.KMSConfig.KeyringPath
 		}
 		backend, err := store.InitializeCassandraBackend(cassaConfig)
 		if err != nil {
 			log.WithError(err).Fatalf("Initalization of %s backend failed", config.RESTstoreConfig.BackendName)
 		}
 		rest_facility.store = backends.APIStorage(backend) // type conversion
+	case "memory":
+		backend, _ := memory.InitializeMemoryBackend()
+		rest_facility.store = backends.APIStorage(backend) // type conversion
 	default:
 		log.Fatalf("Unknown backend: %s", config.RESTstoreConfig.BackendName)
 	}
@@ -117,16 +163,42 @@ func NewRESTfacility(config CaliopenConfig, nats_conn *nats.Conn) (rest_facility
 			log.WithError(err).Fatalf("Initalization of %s index failed", config.RESTindexConfig.IndexName)
 		}
 		rest_facility.index = backends.APIIndex(indx) // type conversion
+	case "memory":
+		indx, _ := memory.InitializeMemoryIndex()
+		rest_facility.index = backends.APIIndex(indx) // type conversion
 	default:
 		log.Fatalf("Unknown index: %s", config.RESTindexConfig.IndexName)
 	}
 
-	cach, err := cache.InitializeRedisBackend(config.CacheConfig)
+	switch config.CacheConfig.CacheName {
+	case "memory":
+		cach, _ := memory.InitializeMemoryCache()
+		rest_facility.Cache = backends.APICache(cach) // type conversion
+	default:
+		cach, err := cache.InitializeRedisBackend(config.CacheConfig)
+		if err != nil {
+			log.WithError(err).Fatal("Initialization of Redis cache failed")
+		}
+		rest_facility.Cache = backends.APICache(cach) // type conversion
+	}
+
+	rest_facility.geoipConfig = config.GeoIPConfig
+	resolver, err := geoip.NewResolver(config.GeoIPConfig.CountryDB, config.GeoIPConfig.AsnDB)
 	if err != nil {
-		log.WithError(err).Fatal("Initialization of Redis cache failed")
+		log.WithError(err).Warn("[RESTfacility] failed to load GeoIP databases, devices' locations won't be resolved")
+	} else {
+		rest_facility.geoip = resolver
 	}
 
-	rest_facility.Cache = backends.APICache(cach) // type conversion
+	switch err := remote.CheckSigningKey(config.RemoteContentConfig.SigningKey); err {
+	case nil:
+		rest_facility.remoteSigner = remote.NewSigner(config.RemoteContentConfig.SigningKey)
+		rest_facility.remoteFetcher = remote.NewFetcher(config.RemoteContentConfig.MaxSize, time.Duration(config.RemoteContentConfig.Timeout)*time.Second)
+	case remote.ErrNoSigningKey:
+		log.Info("[RESTfacility] no signing key, remote content proxy is disabled")
+	default:
+		log.WithError(err).Error("[RESTfacility] remote content proxy is disabled, set a random signing_key in RemoteContentConfig")
+	}
 
 	return rest_facility
 }
@@ -10,6 +10,7 @@ import (
 	"fmt"
 	"github.com/CaliOpen/Caliopen/src/backend/brokers/go.emails"
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/outbox"
 	"github.com/satori/go.uuid"
 	"io"
 	"strconv"
@@ -28,7 +29,7 @@ func (rest *RESTfacility) AddAttachment(user_id, message_id, filename, content_t
 	//store temporary file in objectStore facility
 	tmpId := uuid.NewV4()
 	tempId = tmpId.String()
-	url, size, err := rest.store.StoreAttachment(tempId, file)
+	url, size, err := rest.store.StoreAttachment(user_id, tempId, file)
 	if err != nil {
 		return "", err
 	}
@@ -45,24 +46,21 @@ func (rest *RESTfacility) AddAttachment(user_id, message_id, filename, content_t
 	draftAttchmnt.TempID.UnmarshalBinary(tmpId.Bytes())
 	msg.Attachments = append(msg.Attachments, draftAttchmnt)
 
-	//update store
-	fields := make(map[string]interface{})
-	fields["Attachments"] = msg.Attachments
-	err = rest.store.UpdateMessage(msg, fields)
+	//update store, index is updated through outbox
+	mutation, err := outbox.Record(rest.store, msg.User_id, MessageType, msg.Message_id, IndexUpdate, "Attachments")
 	if err != nil {
-		//roll-back attachment storage before returning the error
 		rest.store.DeleteAttachment(url)
 		return "", err
 	}
-	//update index
-	err = rest.index.UpdateMessage(msg, fields)
+	fields := make(map[string]interface{})
+	fields["Attachments"] = msg.Attachments
+	err = rest.store.UpdateMessage(msg, fields)
 	if err != nil {
 		//roll-back attachment storage before returning the error
-		fields["Attachments"] = msg.Attachments[:len(msg.Attachments)-1]
-		rest.store.UpdateMessage(msg, fields)
 		rest.store.DeleteAttachment(url)
 		return "", err
 	}
+	outbox.ApplyOrDefer(rest.store, rest.index, mutation)
 
 	return
 }
@@ -87,15 +85,18 @@ func (rest *RESTfacility) DeleteAttachment(user_id, message_id, attchmt_id strin
 		if attachment.TempID.String() == attchmt_id {
 			msg.Attachments = append(msg.Attachments[:i], msg.Attachments[i+1:]...)
 
-			//update store
+			//update store, index is updated through outbox
+			mutation, err := outbox.Record(rest.store, msg.User_id, MessageType, msg.Message_id, IndexUpdate, "Attachments")
+			if err != nil {
+				return WrapCaliopenErr(err, DbCaliopenErr, "")
+			}
 			fields := make(map[string]interface{})
 			fields["Attachments"] = msg.Attachments
-			rest.store.UpdateMessage(msg, fields)
+			err = rest.store.UpdateMessage(msg, fields)
 			if err != nil {
 				return WrapCaliopenErr(err, DbCaliopenErr, "")
 			}
-			//update index
-			err = rest.index.UpdateMessage(msg, fields)
+			outbox.ApplyOrDefer(rest.store, rest.index, mutation)
 
 			//remove temporary file from object store
 			err = rest.store.DeleteAttachment(attachment.URL)
@@ -148,12 +149,13 @@ func (rest *RESTfacility) OpenAttachment(user_id, message_id, attchmtIndex strin
 		meta["Content-Type"] = msg.Attachments[index].ContentType
 		meta["Message-Size"] = strconv.Itoa(msg.Attachments[index].Size)
 		meta["Filename"] = msg.Attachments[index].FileName
+		meta["Url"] = msg.Attachments[index].URL
 	}
 
 	// create a Reader
-	// either from object store (draft context)
+	// either from object store (draft context, or attachment decrypted by broker)
 	// or from raw message's mime part (non-draft context)
-	if msg.Is_draft {
+	if meta["Url"] != "" {
 		attachment, e := rest.store.GetAttachment(meta["Url"])
 		if e != nil {
 			return map[string]string{}, nil, e
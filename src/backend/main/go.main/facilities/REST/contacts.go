@@ -10,11 +10,10 @@ import (
 	"fmt"
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/helpers"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/outbox"
 	"github.com/bitly/go-simplejson"
 	"github.com/pkg/errors"
 	"github.com/satori/go.uuid"
-	"strings"
-	"sync"
 	"time"
 )
 
@@ -31,35 +30,16 @@ func (rest *RESTfacility) CreateContact(contact *Contact) (err error) {
 	MarshalNested(contact)
 	MarshalRelated(contact)
 
-	// concurrent creation in db & index
-	wg := new(sync.WaitGroup)
-	wg.Add(2)
-	errGroup := new([]string)
-	mx := new(sync.Mutex)
-	go func(wg *sync.WaitGroup, errGroup *[]string, mx *sync.Mutex) {
-		err = rest.store.CreateContact(contact)
-		if err != nil {
-			mx.Lock()
-			*errGroup = append(*errGroup, err.Error())
-			mx.Unlock()
-		}
-		wg.Done()
-	}(wg, errGroup, mx)
-
-	go func(wg *sync.WaitGroup, errGroup *[]string, mx *sync.Mutex) {
-		err = rest.index.CreateContact(contact)
-		if err != nil {
-			mx.Lock()
-			*errGroup = append(*errGroup, err.Error())
-			mx.Unlock()
-		}
-		wg.Done()
-	}(wg, errGroup, mx)
-
-	wg.Wait()
-	if len(*errGroup) > 0 {
-		return fmt.Errorf("%s", strings.Join(*errGroup, " / "))
+	// store then index through outbox
+	mutation, err := outbox.Record(rest.store, contact.UserId, ContactType, contact.ContactId, IndexCreate)
+	if err != nil {
+		return err
 	}
+	err = rest.store.CreateContact(contact)
+	if err != nil {
+		return err
+	}
+	outbox.ApplyOrDefer(rest.store, rest.index, mutation)
 
 	// notify external components
 	go func(contact *Contact) {
@@ -150,17 +130,22 @@ func (rest *RESTfacility) PatchContact(patch []byte, userID, contactID string) e
 
 // UpdateContact updates a contact in store & index with payload
 func (rest *RESTfacility) UpdateContact(contact, oldContact *Contact, modifiedFields map[string]interface{}) error {
-
-	err := rest.store.UpdateContact(contact, oldContact, modifiedFields)
+	fields := make([]string, 0, len(modifiedFields))
+	for field := range modifiedFields {
+		fields = append(fields, field)
+	}
+	mutation, err := outbox.Record(rest.store, contact.UserId, ContactType, contact.ContactId, IndexUpdate, fields...)
 	if err != nil {
 		return err
 	}
 
-	err = rest.index.UpdateContact(contact, modifiedFields)
+	err = rest.store.UpdateContact(contact, oldContact, modifiedFields)
 	if err != nil {
 		return err
 	}
 
+	outbox.ApplyOrDefer(rest.store, rest.index, mutation)
+
 	// notify external components
 	go func(contact *Contact) {
 		const update_order = "contact_update"
@@ -188,34 +173,15 @@ func (rest *RESTfacility) DeleteContact(userID, contactID string) error {
 		return errors.New("can't delete contact card related to user")
 	}
 
-	// parallel deletion in db & index
-	wg := new(sync.WaitGroup)
-	wg.Add(2)
-	errGroup := new([]string)
-	mx := new(sync.Mutex)
-	go func(wg *sync.WaitGroup, errGroup *[]string, mx *sync.Mutex) {
-		err = rest.store.DeleteContact(contact)
-		if err != nil {
-			mx.Lock()
-			*errGroup = append(*errGroup, err.Error())
-			mx.Unlock()
-		}
-		wg.Done()
-	}(wg, errGroup, mx)
-
-	go func(wg *sync.WaitGroup, errGroup *[]string, mx *sync.Mutex) {
-		err = rest.index.DeleteContact(contact)
-		if err != nil {
-			mx.Lock()
-			*errGroup = append(*errGroup, err.Error())
-			mx.Unlock()
-		}
-		wg.Done()
-	}(wg, errGroup, mx)
-
-	wg.Wait()
-	if len(*errGroup) > 0 {
-		return fmt.Errorf("%s", strings.Join(*errGroup, " / "))
+	// deletion in store then in index through outbox
+	mutation, err := outbox.Record(rest.store, contact.UserId, ContactType, contact.ContactId, IndexDelete)
+	if err != nil {
+		return err
+	}
+	err = rest.store.DeleteContact(contact)
+	if err != nil {
+		return err
 	}
+	outbox.ApplyOrDefer(rest.store, rest.index, mutation)
 	return nil
 }
@@ -0,0 +1,172 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package REST
+
+import (
+	"encoding/json"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/facilities/Notifications"
+	log "github.com/Sirupsen/logrus"
+	"github.com/satori/go.uuid"
+	"time"
+)
+
+// connections from the same device and address are recorded at most once per connectionThrottle
+const connectionThrottle = time.Hour
+
+// reasons of a location alert
+const (
+	NewDeviceReason   = "new_device"
+	NewLocationReason = "new_location"
+)
+
+// RecordDeviceConnection logs that device has been seen from ipAddress, with the country and autonomous system found in GeoIP databases.
+// If the device shows up from a location it has never been seen from, or if it is a new device of an user who already has others,
+// the location is added to the device's ones, user is warned with a notification and device's privacy index is lowered.
+func (rest *RESTfacility) RecordDeviceConnection(userId, deviceId, ipAddress string, notifier Notifications.Notifiers) {
+	if userId == "" || deviceId == "" || ipAddress == "" || !rest.firstSeen(userId, deviceId, ipAddress) {
+		return
+	}
+	resolved := rest.geoip.Lookup(ipAddress)
+	now := time.Now()
+	connection := &DeviceConnection{
+		Asn:        int(resolved.ASN),
+		Country:    resolved.Country,
+		DateInsert: now,
+		DeviceId:   UUID(uuid.FromStringOrNil(deviceId)),
+		IpAddress:  ipAddress,
+		Type:       ConnectionRequest,
+		UserId:     UUID(uuid.FromStringOrNil(userId)),
+	}
+	if err := rest.store.CreateDeviceConnection(connection); err != nil {
+		log.WithError(err).Warnf("[RESTfacility] RecordDeviceConnection failed to log connection of device %s", deviceId)
+	}
+
+	device, err := rest.store.RetrieveDevice(userId, deviceId)
+	if err != nil || device.IsRevoked() {
+		return
+	}
+	location := DeviceLocation{
+		Asn:       connection.Asn,
+		Country:   connection.Country,
+		DeviceId:  connection.DeviceId,
+		IpAddress: ipAddress,
+		Type:      DetectedLocation,
+		UserId:    connection.UserId,
+	}
+	reason := ""
+	if len(device.Locations) == 0 {
+		if devices, err := rest.store.RetrieveDevices(userId); err == nil && len(devices) > 1 {
+			reason = NewDeviceReason
+		}
+	} else if !KnownLocation(device.Locations, location) {
+		reason = NewLocationReason
+	}
+	if len(device.Locations) == 0 || reason != "" {
+		if err = rest.store.CreateDeviceLocation(&location); err != nil {
+			log.WithError(err).Warnf("[RESTfacility] RecordDeviceConnection failed to add location to device %s", deviceId)
+		}
+	}
+	if reason == "" {
+		return
+	}
+	log.Infof("[RESTfacility] device %s of user %s seen from unexpected location %s (%s, AS%d) : %s", deviceId, userId, ipAddress, resolved.Country, resolved.ASN, reason)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"deviceLocation": map[string]interface{}{
+			"device_id":    deviceId,
+			"name":         device.Name,
+			"ip_address":   ipAddress,
+			"country":      resolved.Country,
+			"asn":          resolved.ASN,
+			"organization": resolved.Organization,
+			"reason":       reason,
+		},
+	})
+	notif := Notification{
+		Body:    string(body),
+		Emitter: "api",
+		NotifId: UUID(uuid.NewV1()),
+		TTLcode: LongLived,
+		Type:    WarningNotif,
+		User: &User{
+			UserId: device.UserId,
+		},
+	}
+	go notifier.ByNotifQueue(&notif)
+
+	if rest.geoipConfig.PIPenalty > 0 {
+		rest.lowerDevicePI(device, rest.geoipConfig.PIPenalty)
+	}
+}
+
+// KnownLocation tells if device has already been seen from location,
+// either from the same address or from the same country and autonomous system.
+// An unresolved autonomous system matches any other.
+func KnownLocation(locations DeviceLocations, location DeviceLocation) bool {
+	for _, known := range locations {
+		if known.IpAddress == location.IpAddress {
+			return true
+		}
+		if known.Country != "" && known.Country == location.Country &&
+			(known.Asn == 0 || location.Asn == 0 || known.Asn == location.Asn) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstSeen tells if device has not been seen from ipAddress for connectionThrottle
+func (rest *RESTfacility) firstSeen(userId, deviceId, ipAddress string) bool {
+	rest.seenLock.Lock()
+	defer rest.seenLock.Unlock()
+	now := time.Now()
+	if rest.seen == nil {
+		rest.seen = map[string]time.Time{}
+		go rest.forgetConnections(time.NewTicker(connectionThrottle).C)
+	}
+	key := userId + "-" + deviceId + "-" + ipAddress
+	if last, ok := rest.seen[key]; ok && now.Sub(last) < connectionThrottle {
+		return false
+	}
+	rest.seen[key] = now
+	return true
+}
+
+// forgetConnections removes outdated connections from the ones seen on each tick, to not grow forever
+func (rest *RESTfacility) forgetConnections(ticks <-chan time.Time) {
+	for now := range ticks {
+		rest.seenLock.Lock()
+		for k, last := range rest.seen {
+			if now.Sub(last) >= connectionThrottle {
+				delete(rest.seen, k)
+			}
+		}
+		rest.seenLock.Unlock()
+	}
+}
+
+// lowerDevicePI removes penalty from device's context privacy index, down to 0
+func (rest *RESTfacility) lowerDevicePI(device *Device, penalty int) {
+	oldDevice := *device
+	pi := PrivacyIndex{}
+	if device.PrivacyIndex != nil {
+		pi = *device.PrivacyIndex
+	}
+	pi.Context -= penalty
+	if pi.Context < 0 {
+		pi.Context = 0
+	}
+	pi.DateUpdate = time.Now()
+	device.PrivacyIndex = &pi
+	err := rest.store.UpdateDevice(device, &oldDevice, map[string]interface{}{
+		"PrivacyIndex": device.PrivacyIndex,
+	})
+	if err != nil {
+		log.WithError(err).Warnf("[RESTfacility] failed to lower privacy index of device %s", device.DeviceId.String())
+	}
+}
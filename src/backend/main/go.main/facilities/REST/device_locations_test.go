@@ -0,0 +1,62 @@
+package REST
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"testing"
+	"time"
+)
+
+func TestKnownLocation(t *testing.T) {
+	locations := DeviceLocations{
+		{IpAddress: "192.0.2.1", Country: "FR", Asn: 64500},
+		{IpAddress: "198.51.100.7"},
+	}
+	for _, loc := range []DeviceLocation{
+		{IpAddress: "198.51.100.7"},
+		{IpAddress: "192.0.2.200", Country: "FR", Asn: 64500},
+		{IpAddress: "192.0.2.201", Country: "FR"},
+	} {
+		if !KnownLocation(locations, loc) {
+			t.Errorf("location %+v should be known", loc)
+		}
+	}
+	for _, loc := range []DeviceLocation{
+		{IpAddress: "203.0.113.1"},
+		{IpAddress: "203.0.113.2", Country: "DE", Asn: 64500},
+		{IpAddress: "203.0.113.3", Country: "FR", Asn: 64501},
+	} {
+		if KnownLocation(locations, loc) {
+			t.Errorf("location %+v should be unexpected", loc)
+		}
+	}
+}
+
+func TestFirstSeen(t *testing.T) {
+	rest := &RESTfacility{}
+	if !rest.firstSeen("user", "device", "192.0.2.1") || rest.firstSeen("user", "device", "192.0.2.1") {
+		t.Error("connection should be recorded once per throttle period")
+	}
+	if !rest.firstSeen("user", "device", "192.0.2.2") {
+		t.Error("connection from another address should be recorded")
+	}
+
+	ticks := make(chan time.Time)
+	go rest.forgetConnections(ticks)
+	remaining := func(now time.Time) int {
+		ticks <- now
+		ticks <- now // waits for previous tick to be handled
+		rest.seenLock.Lock()
+		defer rest.seenLock.Unlock()
+		return len(rest.seen)
+	}
+	if count := remaining(time.Now().Add(connectionThrottle / 2)); count != 2 {
+		t.Errorf("recent connections should be kept, %d remaining", count)
+	}
+	if count := remaining(time.Now().Add(connectionThrottle)); count != 0 {
+		t.Errorf("outdated connections should be forgotten, %d remaining", count)
+	}
+	close(ticks)
+	if !rest.firstSeen("user", "device", "192.0.2.1") {
+		t.Error("connection should be recorded again once forgotten")
+	}
+}
@@ -7,8 +7,11 @@
 package REST
 
 import (
+	"encoding/json"
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/facilities/Notifications"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/helpers"
+	log "github.com/Sirupsen/logrus"
 	"github.com/bitly/go-simplejson"
 	"github.com/satori/go.uuid"
 	"strings"
@@ -117,6 +120,78 @@ func (rest *RESTfacility) DeleteDevice(userId, deviceId string) CaliopenError {
 	if e != nil {
 		return WrapCaliopenErr(e, DbCaliopenErr, "[RESTfacility] DeleteDevice failed to delete device")
 	}
+	if e = rest.Cache.DeleteDeviceSessions(userId, deviceId); e != nil {
+		log.WithError(e).Warnf("[RESTfacility] DeleteDevice failed to delete sessions of device %s", deviceId)
+	}
+
+	return nil
+}
+
+// RevokeDevice marks user's device as revoked, then deletes its authentication tokens :
+// next requests from this device are rejected, device must be registered again to be used.
+// Revocation is recorded into devices' audit along with the device and IP address it has been requested from,
+// then user is notified on its other devices.
+// Revoking an already revoked device only deletes its tokens again.
+func (rest *RESTfacility) RevokeDevice(userId, deviceId, byDeviceId, ipAddress string, notifier Notifications.Notifiers) CaliopenError {
+	device, e := rest.store.RetrieveDevice(userId, deviceId)
+	if e != nil {
+		if e.Error() == "not found" {
+			return WrapCaliopenErr(e, NotFoundCaliopenErr, "[RESTfacility] RevokeDevice device not found")
+		}
+		return WrapCaliopenErr(e, DbCaliopenErr, "[RESTfacility] RevokeDevice failed to retrieve device")
+	}
+	// tokens are deleted first, thus a failure below leaves device unusable
+	if e = rest.Cache.DeleteDeviceSessions(userId, deviceId); e != nil {
+		return WrapCaliopenErr(e, FailDependencyCaliopenErr, "[RESTfacility] RevokeDevice failed to delete device's sessions")
+	}
+	if device.IsRevoked() {
+		return nil
+	}
+
+	oldDevice := *device
+	device.DateRevoked = time.Now()
+	device.Status = DeviceRevoked
+	e = rest.store.UpdateDevice(device, &oldDevice, map[string]interface{}{
+		"DateRevoked": device.DateRevoked,
+		"Status":      device.Status,
+	})
+	if e != nil {
+		return WrapCaliopenErr(e, DbCaliopenErr, "[RESTfacility] RevokeDevice failed to update device")
+	}
+
+	audit := &DeviceAudit{
+		Action:     DeviceRevokeAction,
+		AuditId:    UUID(uuid.NewV1()),
+		DateInsert: device.DateRevoked,
+		DeviceId:   device.DeviceId,
+		IpAddress:  ipAddress,
+		UserId:     device.UserId,
+	}
+	if byDeviceId != "" {
+		audit.ByDeviceId = UUID(uuid.FromStringOrNil(byDeviceId))
+	}
+	if e = rest.store.CreateDeviceAudit(audit); e != nil {
+		log.WithError(e).Errorf("[RESTfacility] RevokeDevice failed to record audit of device %s revocation", deviceId)
+	}
+	log.Infof("[RESTfacility] device %s of user %s revoked by device %s from %s", deviceId, userId, byDeviceId, ipAddress)
+
+	body, _ := json.Marshal(map[string]interface{}{
+		"deviceRevoked": map[string]string{
+			"device_id": deviceId,
+			"name":      device.Name,
+		},
+	})
+	notif := Notification{
+		Body:    string(body),
+		Emitter: "api",
+		NotifId: UUID(uuid.NewV1()),
+		TTLcode: LongLived,
+		Type:    WarningNotif,
+		User: &User{
+			UserId: device.UserId,
+		},
+	}
+	go notifier.ByNotifQueue(&notif)
 
 	return nil
 }
@@ -5,15 +5,70 @@
 package REST
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/helpers"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/messages"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/outbox"
 	log "github.com/Sirupsen/logrus"
+	"github.com/satori/go.uuid"
+	"regexp"
+	"strings"
 	"time"
 )
 
+// properties that user may set on a draft, others are silently removed from payloads
+var draftProperties = map[string]bool{
+	"body":             true, // alias for body_plain
+	"body_html":        true,
+	"body_plain":       true,
+	"discussion_id":    true,
+	"identities":       true,
+	"message_id":       true,
+	"parent_id":        true,
+	"participants":     true,
+	"privacy_features": true, // only protection policies, see draftPrivacyFeatures
+	"subject":          true,
+}
+
+// privacy features a user may set on its drafts to override its protection policies
+var draftPrivacyFeatures = map[string]bool{
+	EncryptionPolicyFeature: true,
+	SignaturePolicyFeature:  true,
+}
+
+// reply and forward prefixes of subjects (https://www.wikiwand.com/en/List_of_email_subject_abbreviations)
+const subjectAbbreviations = `RE?S?|FYI|RIF|I|FS|VB|RV|ENC|ODP|PD|YNT|ILT|SV|VS|VL|AW|WG|ΑΠ|ΣΧΕΤ|ΠΡΘ|תגובה|הועבר|主题|转发|FWD?`
+
+// subjectPrefix matches one prefix at the beginning of a subject : "Re: ", "RE[2]: ", "[Fwd] " or "[Fwd: " (group 1 is the bracket).
+var subjectPrefix = regexp.MustCompile(`(?i)^(?:(?:` + subjectAbbreviations + `)\s*(?:\[\d+\]|\(\d+\))?\s*[:：]|[\[(]\s*(?:` + subjectAbbreviations + `)\s*[\])]|([\[(])\s*(?:` + subjectAbbreviations + `)\s*[:：])\s*`)
+
+// stripSubjectPrefixes removes all reply and forward prefixes at the beginning of subject,
+// along with the closing bracket of a "[Fwd: subject]".
+func stripSubjectPrefixes(subject string) string {
+	subject = strings.TrimSpace(subject)
+	for {
+		match := subjectPrefix.FindStringSubmatchIndex(subject)
+		if match == nil {
+			return subject
+		}
+		bracket := ""
+		if match[2] >= 0 {
+			bracket = subject[match[2]:match[3]]
+		}
+		subject = strings.TrimSpace(subject[match[1]:])
+		switch bracket {
+		case "[":
+			subject = strings.TrimSpace(strings.TrimSuffix(subject, "]"))
+		case "(":
+			subject = strings.TrimSpace(strings.TrimSuffix(subject, ")"))
+		}
+	}
+}
+
 func (rest *RESTfacility) SendDraft(user_id, msg_id string) (msg *Message, err error) {
 	const nats_order = "deliver"
 	natsMessage := fmt.Sprintf(Nats_message_tmpl, nats_order, msg_id, user_id)
@@ -44,3 +99,389 @@ func (rest *RESTfacility) SendDraft(user_id, msg_id string) (msg *Message, err e
 	(*msg).Body_excerpt = messages.ExcerptMessage(*msg, 200, true, true)
 	return msg, err
 }
+
+// CreateDraft saves a new draft from a NewMessage payload.
+// Draft's context is given by :
+// - parent_id : draft is a reply to parent message, within parent's discussion,
+// - discussion_id alone : draft is a reply to the last message of the discussion,
+// - forward_id : draft forwards this message, in a new discussion,
+// - none of them : draft starts a new discussion.
+// For a reply, missing subject, recipients and body are built from parent message : "Re: " subject,
+// parent's sender and recipients, quoted parent body.
+// Sender is the local identity found in identities, or user's only local identity if identities is empty.
+func (rest *RESTfacility) CreateDraft(user_id string, payload []byte) (*Message, CaliopenError) {
+	params := map[string]interface{}{}
+	if err := json.Unmarshal(payload, &params); err != nil {
+		return nil, WrapCaliopenErr(err, UnprocessableCaliopenErr, "[RESTfacility] CreateDraft : invalid json payload")
+	}
+	forwardId, _ := params["forward_id"].(string)
+	filterDraftProperties(params)
+	_, hasSubject := params["subject"]
+	_, hasBody := params["body_plain"]
+	if !hasBody {
+		_, hasBody = params["body_html"]
+	}
+
+	draft := new(Message).NewEmpty().(*Message)
+	if err := draft.UnmarshalMap(params); err != nil {
+		return nil, WrapCaliopenErr(err, UnprocessableCaliopenErr, "[RESTfacility] CreateDraft : invalid draft")
+	}
+	draft.User_id = UUID(uuid.FromStringOrNil(user_id))
+	if isEmptyUUID(draft.Message_id) {
+		draft.Message_id = UUID(uuid.NewV4())
+	} else if _, err := rest.store.RetrieveMessage(user_id, draft.Message_id.String()); err == nil {
+		return nil, NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] CreateDraft : message_id not unique")
+	}
+	now := time.Now()
+	draft.Date, draft.Date_insert, draft.Date_sort = now, now, now
+	draft.Is_draft = true
+	draft.Is_received = false
+	draft.Type = EmailProtocol
+
+	sender, e := rest.setDraftSender(draft)
+	if e != nil {
+		return nil, e
+	}
+
+	switch {
+	case !isEmptyUUID(draft.Parent_id):
+		parent, e := rest.retrieveDraftContext(user_id, draft.Parent_id.String(), "parent")
+		if e != nil {
+			return nil, e
+		}
+		if !isEmptyUUID(draft.Discussion_id) && draft.Discussion_id != parent.Discussion_id {
+			return nil, NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] CreateDraft : parent message does not belong to discussion")
+		}
+		replyTo(draft, parent, sender, hasSubject, hasBody)
+	case !isEmptyUUID(draft.Discussion_id):
+		parent, e := rest.lastDiscussionMessage(draft.User_id, draft.Discussion_id)
+		if e != nil {
+			return nil, e
+		}
+		replyTo(draft, parent, sender, hasSubject, hasBody)
+	case forwardId != "":
+		forwarded, e := rest.retrieveDraftContext(user_id, forwardId, "forwarded")
+		if e != nil {
+			return nil, e
+		}
+		draft.Discussion_id = UUID(uuid.NewV4())
+		forward(draft, forwarded, hasSubject, hasBody)
+	default:
+		draft.Discussion_id = UUID(uuid.NewV4())
+	}
+
+	if e := validateDraftParticipants(draft); e != nil {
+		return nil, e
+	}
+
+	mutation, err := outbox.Record(rest.store, draft.User_id, MessageType, draft.Message_id, IndexCreate)
+	if err != nil {
+		return nil, WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] CreateDraft failed to record index mutation")
+	}
+	err = rest.store.CreateMessage(draft)
+	if err != nil {
+		return nil, WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] CreateDraft failed to create draft in store")
+	}
+	outbox.ApplyOrDefer(rest.store, rest.index, mutation)
+
+	return draft, nil
+}
+
+// PatchDraft updates a draft with a patch holding a current_state, as for other resources.
+// It is meant to be called at each autosave of the draft : an unchanged draft is not written.
+// Sender is checked again if identities or participants are modified, reply context if parent_id is modified.
+func (rest *RESTfacility) PatchDraft(patch []byte, user_id, msg_id string) CaliopenError {
+	draft, err := rest.store.RetrieveMessage(user_id, msg_id)
+	if err != nil {
+		if err.Error() == "not found" {
+			return NewCaliopenErr(NotFoundCaliopenErr, "[RESTfacility] PatchDraft : message not found")
+		}
+		return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] PatchDraft failed to retrieve message")
+	}
+	if !draft.Is_draft {
+		return NewCaliopenErrf(ForbiddenCaliopenErr, "[RESTfacility] PatchDraft : message %s is not a draft", msg_id)
+	}
+
+	// read into the patch to make basic controls before processing it with generic helper
+	params := map[string]interface{}{}
+	if err := json.Unmarshal(patch, &params); err != nil {
+		return WrapCaliopenErr(err, UnprocessableCaliopenErr, "[RESTfacility] PatchDraft : invalid json patch")
+	}
+	if _, hasTags := params["tags"]; hasTags {
+		return NewCaliopenErr(ForbiddenCaliopenErr, "[RESTfacility] PatchDraft : patching tags through parent object is forbidden")
+	}
+	currentState, hasCurrentState := params["current_state"].(map[string]interface{})
+	if !hasCurrentState {
+		return NewCaliopenErr(ForbiddenCaliopenErr, "[RESTfacility] PatchDraft : current_state property must be in patch")
+	}
+	filterDraftProperties(params)
+	filterDraftProperties(currentState)
+	params["current_state"] = currentState
+	patch, _ = json.Marshal(params)
+
+	// patch seams OK, apply it to the resource
+	newObj, modifiedFields, err := helpers.UpdateWithPatch(patch, draft, UserActor)
+	if err != nil {
+		if e, ok := err.(CaliopenError); ok {
+			return e
+		}
+		return WrapCaliopenErr(err, UnprocessableCaliopenErr, "[RESTfacility] PatchDraft : patch failed")
+	}
+	if len(modifiedFields) == 0 {
+		return nil
+	}
+	newDraft := newObj.(*Message)
+
+	_, identitiesModified := modifiedFields["Identities"]
+	_, participantsModified := modifiedFields["Participants"]
+	if identitiesModified || participantsModified {
+		if _, e := rest.setDraftSender(newDraft); e != nil {
+			return e
+		}
+		modifiedFields["Identities"] = newDraft.Identities
+		modifiedFields["Participants"] = newDraft.Participants
+		if e := validateDraftParticipants(newDraft); e != nil {
+			return e
+		}
+	}
+	if _, parentModified := modifiedFields["Parent_id"]; parentModified {
+		if isEmptyUUID(newDraft.Parent_id) {
+			newDraft.External_references = ExternalReferences{}
+		} else {
+			parent, e := rest.retrieveDraftContext(user_id, newDraft.Parent_id.String(), "parent")
+			if e != nil {
+				return e
+			}
+			if parent.Discussion_id != newDraft.Discussion_id {
+				return NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] PatchDraft : parent message does not belong to draft's discussion")
+			}
+			newDraft.External_references = replyReferences(parent)
+		}
+		modifiedFields["External_references"] = newDraft.External_references
+	}
+	newDraft.Date = time.Now()
+	newDraft.Date_sort = newDraft.Date
+	modifiedFields["Date"] = newDraft.Date
+	modifiedFields["Date_sort"] = newDraft.Date_sort
+
+	// save updated resource
+	fields := make([]string, 0, len(modifiedFields))
+	for field := range modifiedFields {
+		fields = append(fields, field)
+	}
+	mutation, err := outbox.Record(rest.store, newDraft.User_id, MessageType, newDraft.Message_id, IndexUpdate, fields...)
+	if err != nil {
+		return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] PatchDraft failed to record index mutation")
+	}
+	err = rest.store.UpdateMessage(newDraft, modifiedFields)
+	if err != nil {
+		return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] PatchDraft failed to update draft in store")
+	}
+	outbox.ApplyOrDefer(rest.store, rest.index, mutation)
+
+	return nil
+}
+
+// setDraftSender checks that draft's identity is one of user's local identities,
+// then sets the 'From' participant accordingly.
+func (rest *RESTfacility) setDraftSender(draft *Message) (sender Participant, err CaliopenError) {
+	userId := draft.User_id.String()
+	locals, e := rest.store.GetLocalsIdentities(userId)
+	if e != nil {
+		return sender, WrapCaliopenErr(e, DbCaliopenErr, "[RESTfacility] failed to retrieve user's local identities")
+	}
+	if len(draft.Identities) == 0 && len(locals) == 1 {
+		draft.Identities = []Identity{{Identifier: locals[0].Identifier, Type: locals[0].Type}}
+	}
+	if len(draft.Identities) != 1 {
+		return sender, NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] draft must have one and only one identity")
+	}
+	var local *LocalIdentity
+	for i, identity := range locals {
+		if strings.EqualFold(identity.Identifier, draft.Identities[0].Identifier) {
+			local = &locals[i]
+			break
+		}
+	}
+	if local == nil {
+		return sender, NewCaliopenErrf(ForbiddenCaliopenErr, "[RESTfacility] identity <%s> is not one of user's identities", draft.Identities[0].Identifier)
+	}
+	draft.Identities[0] = Identity{Identifier: local.Identifier, Type: local.Type}
+
+	user, e := rest.store.RetrieveUser(userId)
+	if e != nil {
+		return sender, WrapCaliopenErr(e, DbCaliopenErr, "[RESTfacility] failed to retrieve user")
+	}
+	sender = Participant{
+		Address:     local.Identifier,
+		Contact_ids: []UUID{user.ContactId},
+		Label:       local.Display_name,
+		Protocol:    EmailProtocol,
+		Type:        ParticipantFrom,
+	}
+	participants := []Participant{sender}
+	for _, participant := range draft.Participants {
+		if !strings.EqualFold(participant.Type, ParticipantFrom) {
+			participants = append(participants, participant)
+		}
+	}
+	draft.Participants = participants
+	return sender, nil
+}
+
+// validateDraftParticipants checks draft's recipients, they are the participants that are not the sender.
+func validateDraftParticipants(draft *Message) CaliopenError {
+	for i, participant := range draft.Participants {
+		switch strings.ToLower(participant.Type) {
+		case "from":
+			continue
+		case "to", "cc", "bcc":
+		default:
+			return NewCaliopenErrf(UnprocessableCaliopenErr, "[RESTfacility] invalid participant type <%s>", participant.Type)
+		}
+		if strings.TrimSpace(participant.Address) == "" {
+			return NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] participant without address")
+		}
+		if participant.Protocol == "" {
+			draft.Participants[i].Protocol = EmailProtocol
+		}
+	}
+	return nil
+}
+
+// retrieveDraftContext retrieves a message that a draft replies to or forwards
+func (rest *RESTfacility) retrieveDraftContext(user_id, msg_id, role string) (*Message, CaliopenError) {
+	msg, err := rest.store.RetrieveMessage(user_id, msg_id)
+	if err != nil {
+		if err.Error() == "not found" {
+			return nil, NewCaliopenErrf(UnprocessableCaliopenErr, "[RESTfacility] %s message not found", role)
+		}
+		return nil, WrapCaliopenErrf(err, DbCaliopenErr, "[RESTfacility] failed to retrieve %s message", role)
+	}
+	return msg, nil
+}
+
+// lastDiscussionMessage returns the most recent message of discussion
+func (rest *RESTfacility) lastDiscussionMessage(userId, discussionId UUID) (*Message, CaliopenError) {
+	found, _, err := rest.index.FilterMessages(IndexSearch{
+		User_id: userId,
+		Terms:   map[string][]string{"discussion_id": {discussionId.String()}},
+		Limit:   1,
+		ILrange: [2]int8{-10, 10},
+	})
+	if err != nil {
+		return nil, WrapCaliopenErr(err, IndexCaliopenErr, "[RESTfacility] failed to retrieve discussion's messages")
+	}
+	if len(found) == 0 {
+		return nil, NewCaliopenErrf(UnprocessableCaliopenErr, "[RESTfacility] no such discussion %s", discussionId.String())
+	}
+	return rest.retrieveDraftContext(userId.String(), found[0].Message_id.String(), "parent")
+}
+
+// replyTo fills draft as a reply to parent, keeping what user already provided
+func replyTo(draft, parent *Message, sender Participant, hasSubject, hasBody bool) {
+	draft.Parent_id = parent.Message_id
+	draft.Discussion_id = parent.Discussion_id
+	draft.External_references = replyReferences(parent)
+	if !hasSubject {
+		draft.Subject = "Re: " + stripSubjectPrefixes(parent.Subject)
+	}
+	if len(draft.Participants) == 1 {
+		// only sender : parent's sender and recipients become draft's recipients, blind copies are left out
+		for _, participant := range parent.Participants {
+			if strings.EqualFold(participant.Address, sender.Address) {
+				continue
+			}
+			switch strings.ToLower(participant.Type) {
+			case "from":
+				participant.Type = ParticipantTo
+			case "to", "cc":
+			default:
+				continue
+			}
+			draft.Participants = append(draft.Participants, participant)
+		}
+	}
+	if !hasBody {
+		draft.Body_plain = quoteBody(fmt.Sprintf("On %s, %s wrote :", parent.Date.Format(time.RFC1123Z), messageSender(parent)), parent.Body_plain)
+	}
+}
+
+// forward fills draft with forwarded message's subject and body, recipients are left to user.
+func forward(draft, forwarded *Message, hasSubject, hasBody bool) {
+	if !hasSubject {
+		draft.Subject = "Fwd: " + stripSubjectPrefixes(forwarded.Subject)
+	}
+	if !hasBody {
+		var body bytes.Buffer
+		body.WriteString("\n\n---------- Forwarded message ----------\n")
+		body.WriteString("From: " + messageSender(forwarded) + "\n")
+		body.WriteString("Date: " + forwarded.Date.Format(time.RFC1123Z) + "\n")
+		body.WriteString("Subject: " + forwarded.Subject + "\n\n")
+		body.WriteString(forwarded.Body_plain)
+		draft.Body_plain = body.String()
+	}
+}
+
+// replyReferences returns the external references of a reply to parent.
+// Message_id is left empty, it is set by broker when message is sent.
+func replyReferences(parent *Message) ExternalReferences {
+	refs := ExternalReferences{
+		Ancestors_ids: append([]string{}, parent.External_references.Ancestors_ids...),
+		Parent_id:     parent.External_references.Message_id,
+	}
+	if parent.External_references.Message_id != "" {
+		refs.Ancestors_ids = append(refs.Ancestors_ids, parent.External_references.Message_id)
+	}
+	return refs
+}
+
+func quoteBody(header, body string) string {
+	var quoted bytes.Buffer
+	quoted.WriteString("\n\n" + header + "\n")
+	for _, line := range strings.Split(strings.TrimRight(body, "\n"), "\n") {
+		quoted.WriteString("> " + line + "\n")
+	}
+	return quoted.String()
+}
+
+func messageSender(msg *Message) string {
+	for _, participant := range msg.Participants {
+		if strings.EqualFold(participant.Type, ParticipantFrom) {
+			if participant.Label != "" && participant.Label != participant.Address {
+				return participant.Label + " <" + participant.Address + ">"
+			}
+			return participant.Address
+		}
+	}
+	return ""
+}
+
+func filterDraftProperties(params map[string]interface{}) {
+	for key := range params {
+		if !draftProperties[key] {
+			delete(params, key)
+		}
+	}
+	if body, ok := params["body"]; ok {
+		params["body_plain"] = body
+		delete(params, "body")
+	}
+	if features, ok := params["privacy_features"]; ok {
+		featuresMap, isMap := features.(map[string]interface{})
+		if !isMap {
+			delete(params, "privacy_features")
+			return
+		}
+		for key, value := range featuresMap {
+			policy, isString := value.(string)
+			if !draftPrivacyFeatures[key] || !isString || !IsValidProtectionPolicy(policy) {
+				delete(featuresMap, key)
+			}
+		}
+	}
+}
+
+func isEmptyUUID(id UUID) bool {
+	return bytes.Equal(id.Bytes(), EmptyUUID.Bytes())
+}
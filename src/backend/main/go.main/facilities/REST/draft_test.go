@@ -0,0 +1,167 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package REST
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
+	"github.com/satori/go.uuid"
+	"testing"
+	"time"
+)
+
+func TestDraftSubject(t *testing.T) {
+	cases := []struct {
+		subject, reply, forward string
+	}{
+		{"Hello", "Re: Hello", "Fwd: Hello"},
+		{"Re: Hello", "Re: Hello", "Fwd: Hello"},
+		{"RE: re: Fwd: Hello", "Re: Hello", "Fwd: Hello"},
+		{"RE[2]: Hello", "Re: Hello", "Fwd: Hello"},
+		{"AW: WG: Hello", "Re: Hello", "Fwd: Hello"},
+		{"[Fwd: Hello]", "Re: Hello", "Fwd: Hello"},
+		{"[Fwd] Hello", "Re: Hello", "Fwd: Hello"},
+		{"  Re :  Hello  ", "Re: Hello", "Fwd: Hello"},
+		{"Care: urgent", "Re: Care: urgent", "Fwd: Care: urgent"},
+		{"Part I: Introduction", "Re: Part I: Introduction", "Fwd: Part I: Introduction"},
+		{"Tickets for FYI", "Re: Tickets for FYI", "Fwd: Tickets for FYI"},
+		{"Result [final]", "Re: Result [final]", "Fwd: Result [final]"},
+		{"Residents meeting", "Re: Residents meeting", "Fwd: Residents meeting"},
+		{"", "Re: ", "Fwd: "},
+	}
+	for _, c := range cases {
+		parent := &Message{Subject: c.subject}
+		reply := &Message{}
+		replyTo(reply, parent, Participant{}, false, true)
+		if reply.Subject != c.reply {
+			t.Errorf("reply to %q : expected subject %q, got %q", c.subject, c.reply, reply.Subject)
+		}
+		fwd := &Message{}
+		forward(fwd, parent, false, true)
+		if fwd.Subject != c.forward {
+			t.Errorf("forward of %q : expected subject %q, got %q", c.subject, c.forward, fwd.Subject)
+		}
+	}
+
+	reply := &Message{Subject: "my subject"}
+	replyTo(reply, &Message{Subject: "Hello"}, Participant{}, true, true)
+	if reply.Subject != "my subject" {
+		t.Errorf("user's subject should be kept, got %q", reply.Subject)
+	}
+}
+
+func TestReplyRecipients(t *testing.T) {
+	sender := Participant{Address: "me@caliopen.local", Type: ParticipantFrom}
+	participant := func(address, kind string) Participant {
+		return Participant{Address: address, Type: kind, Protocol: EmailProtocol}
+	}
+	cases := []struct {
+		name     string
+		draft    []Participant
+		parent   []Participant
+		expected []Participant
+	}{
+		{
+			name:     "sender becomes recipient",
+			draft:    []Participant{sender},
+			parent:   []Participant{participant("bob@example.com", "From"), participant("me@caliopen.local", "To")},
+			expected: []Participant{sender, participant("bob@example.com", "To")},
+		},
+		{
+			name:   "recipients are kept, blind copies are left out",
+			draft:  []Participant{sender},
+			parent: []Participant{participant("bob@example.com", "From"), participant("ME@caliopen.local", "To"), participant("carol@example.com", "Cc"), participant("dave@example.com", "Bcc")},
+			expected: []Participant{sender, participant("bob@example.com", "To"),
+				participant("carol@example.com", "Cc")},
+		},
+		{
+			name:     "reply to own message",
+			draft:    []Participant{sender},
+			parent:   []Participant{participant("me@caliopen.local", "From"), participant("bob@example.com", "To")},
+			expected: []Participant{sender, participant("bob@example.com", "To")},
+		},
+		{
+			name:     "recipients given by user are kept",
+			draft:    []Participant{sender, participant("erin@example.com", "To")},
+			parent:   []Participant{participant("bob@example.com", "From")},
+			expected: []Participant{sender, participant("erin@example.com", "To")},
+		},
+	}
+	for _, c := range cases {
+		draft := &Message{Participants: c.draft}
+		replyTo(draft, &Message{Participants: c.parent}, sender, true, true)
+		if len(draft.Participants) != len(c.expected) {
+			t.Errorf("%s : expected %d participants, got %+v", c.name, len(c.expected), draft.Participants)
+			continue
+		}
+		for i, p := range draft.Participants {
+			if p.Address != c.expected[i].Address || p.Type != c.expected[i].Type {
+				t.Errorf("%s : expected participant %d to be %s %s, got %s %s", c.name, i,
+					c.expected[i].Type, c.expected[i].Address, p.Type, p.Address)
+			}
+		}
+	}
+}
+
+func TestPatchDraft(t *testing.T) {
+	store, index := memory.NewMemoryBackend(), memory.NewMemoryIndex()
+	rest := &RESTfacility{store: store, index: index}
+	userId := UUID(uuid.NewV4())
+	store.CreateUser(&User{UserId: userId, Name: "alice", ContactId: UUID(uuid.NewV4())})
+	store.CreateLocalIdentity(&LocalIdentity{User_id: userId, Identifier: "alice@caliopen.local", Type: "email"})
+	newMessage := func(isDraft bool) string {
+		msg := &Message{
+			User_id:      userId,
+			Message_id:   UUID(uuid.NewV4()),
+			Date_insert:  time.Now(),
+			Is_draft:     isDraft,
+			Subject:      "draft",
+			Identities:   []Identity{{Identifier: "alice@caliopen.local", Type: "email"}},
+			Participants: []Participant{{Address: "alice@caliopen.local", Type: ParticipantFrom, Protocol: EmailProtocol}},
+		}
+		store.CreateMessage(msg)
+		index.CreateMessage(msg)
+		return msg.Message_id.String()
+	}
+	draftId, messageId := newMessage(true), newMessage(false)
+
+	cases := []struct {
+		name     string
+		msgId    string
+		patch    string
+		expected int32
+	}{
+		{"not a draft", messageId, `{"subject": "new", "current_state": {"subject": "draft"}}`, ForbiddenCaliopenErr},
+		{"unknown draft", uuid.NewV4().String(), `{"subject": "new", "current_state": {"subject": "draft"}}`, NotFoundCaliopenErr},
+		{"invalid json", draftId, `{"subject": `, UnprocessableCaliopenErr},
+		{"tags", draftId, `{"tags": ["a"], "current_state": {"tags": []}}`, ForbiddenCaliopenErr},
+		{"no current state", draftId, `{"subject": "new"}`, ForbiddenCaliopenErr},
+		{"foreign identity", draftId, `{"identities": [{"identifier": "bob@example.com", "type": "email"}], "current_state": {"identities": [{"identifier": "alice@caliopen.local", "type": "email"}]}}`, ForbiddenCaliopenErr},
+		{"invalid participant type", draftId, `{"participants": [{"address": "bob@example.com", "type": "Reply-To"}], "current_state": {"participants": [{"address": "alice@caliopen.local", "type": "From", "protocol": "email"}]}}`, UnprocessableCaliopenErr},
+		{"participant without address", draftId, `{"participants": [{"address": " ", "type": "To"}], "current_state": {"participants": [{"address": "alice@caliopen.local", "type": "From", "protocol": "email"}]}}`, UnprocessableCaliopenErr},
+		{"subject", draftId, `{"subject": "new", "current_state": {"subject": "draft"}}`, 0},
+		{"recipient", draftId, `{"participants": [{"address": "bob@example.com", "type": "To"}], "current_state": {"participants": [{"address": "alice@caliopen.local", "type": "From", "protocol": "email"}]}}`, 0},
+	}
+	for _, c := range cases {
+		err := rest.PatchDraft([]byte(c.patch), userId.String(), c.msgId)
+		switch {
+		case c.expected == 0 && err != nil:
+			t.Errorf("%s : unexpected error %s", c.name, err)
+		case c.expected != 0 && err == nil:
+			t.Errorf("%s : expected error %d, got none", c.name, c.expected)
+		case c.expected != 0 && err.Code() != c.expected:
+			t.Errorf("%s : expected error %d, got %d (%s)", c.name, c.expected, err.Code(), err)
+		}
+	}
+
+	draft, _ := store.RetrieveMessage(userId.String(), draftId)
+	if draft.Subject != "new" {
+		t.Errorf("expected subject to be patched, got %q", draft.Subject)
+	}
+	if len(draft.Participants) != 2 || draft.Participants[0].Type != ParticipantFrom ||
+		draft.Participants[1].Address != "bob@example.com" || draft.Participants[1].Protocol != EmailProtocol {
+		t.Errorf("expected sender and recipient with protocol, got %+v", draft.Participants)
+	}
+}
@@ -0,0 +1,284 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package REST
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/facilities/Notifications"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/mailbox"
+	log "github.com/Sirupsen/logrus"
+	"github.com/satori/go.uuid"
+	"io"
+	"time"
+)
+
+const (
+	// exportTimeout bounds the duration of an export, runExport gives up beyond
+	exportTimeout = 6 * time.Hour
+	// staleExportDelay : an export still pending that long after its creation has been interrupted
+	// by a restart of the API server that was running it
+	staleExportDelay = exportTimeout + 10*time.Minute
+)
+
+// CreateExport registers a new export of user's data and runs it in background.
+// User is notified once the archive is ready to be downloaded, or if export failed.
+func (rest *RESTfacility) CreateExport(userId, format string, notifier Notifications.Notifiers) (*UserExport, CaliopenError) {
+	if format != ExportMbox && format != ExportMaildir {
+		return nil, NewCaliopenErrf(UnprocessableCaliopenErr, "[RESTfacility] CreateExport : unknown format <%s>", format)
+	}
+	export := &UserExport{
+		DateInsert: time.Now(),
+		Format:     format,
+		Status:     ExportPending,
+	}
+	export.ExportId.UnmarshalBinary(uuid.NewV4().Bytes())
+	user_uuid, err := uuid.FromString(userId)
+	if err != nil {
+		return nil, WrapCaliopenErr(err, UnprocessableCaliopenErr, "[RESTfacility] CreateExport : invalid user id")
+	}
+	export.UserId.UnmarshalBinary(user_uuid.Bytes())
+	if err := rest.store.CreateUserExport(export); err != nil {
+		return nil, WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] CreateExport failed to create export in store")
+	}
+	go rest.runExport(*export, notifier)
+	return export, nil
+}
+
+// RetrieveExport returns user's export. A stale pending export is marked as failed, thus it can be deleted.
+func (rest *RESTfacility) RetrieveExport(userId, exportId string) (*UserExport, CaliopenError) {
+	export, err := rest.store.RetrieveUserExport(userId, exportId)
+	if err != nil {
+		if err.Error() == "not found" {
+			return nil, WrapCaliopenErr(err, NotFoundCaliopenErr, "[RESTfacility] RetrieveExport : export not found")
+		}
+		return nil, WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] RetrieveExport failed")
+	}
+	if export.Status == ExportPending && time.Since(export.DateInsert) > staleExportDelay {
+		export.Status = ExportFailed
+		export.Error = "export has been interrupted"
+		export.DateEnd = time.Now()
+		err = rest.store.UpdateUserExport(export, map[string]interface{}{
+			"Status":  export.Status,
+			"Error":   export.Error,
+			"DateEnd": export.DateEnd,
+		})
+		if err != nil {
+			log.WithError(err).Warnf("[RESTfacility] failed to mark stale export %s of user %s as failed", exportId, userId)
+		}
+	}
+	return export, nil
+}
+
+// OpenExport returns the archive of a successful export.
+func (rest *RESTfacility) OpenExport(userId, exportId string) (*UserExport, io.Reader, CaliopenError) {
+	export, e := rest.RetrieveExport(userId, exportId)
+	if e != nil {
+		return nil, nil, e
+	}
+	if export.Status != ExportDone {
+		return nil, nil, NewCaliopenErrf(FailDependencyCaliopenErr, "[RESTfacility] OpenExport : export is %s", export.Status)
+	}
+	archive, err := rest.store.GetAttachment(export.URI)
+	if err != nil {
+		return nil, nil, WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] OpenExport failed to get archive from objects store")
+	}
+	return export, archive, nil
+}
+
+// DeleteExport removes export and its archive, if any.
+func (rest *RESTfacility) DeleteExport(userId, exportId string) CaliopenError {
+	export, e := rest.RetrieveExport(userId, exportId)
+	if e != nil {
+		return e
+	}
+	if export.Status == ExportPending {
+		return NewCaliopenErr(FailDependencyCaliopenErr, "[RESTfacility] DeleteExport : export is still running")
+	}
+	if export.URI != "" {
+		if err := rest.store.DeleteAttachment(export.URI); err != nil {
+			return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] DeleteExport failed to remove archive from objects store")
+		}
+	}
+	if err := rest.store.DeleteUserExport(userId, exportId); err != nil {
+		return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] DeleteExport failed to delete export in store")
+	}
+	return nil
+}
+
+// runExport writes user's messages, contacts and tags into a zip archive streamed to the objects store,
+// then notifies user. Export fails if it lasts longer than exportTimeout.
+func (rest *RESTfacility) runExport(export UserExport, notifier Notifications.Notifiers) {
+	userId := export.UserId.String()
+	fields := map[string]interface{}{}
+	ctx, cancel := context.WithTimeout(context.Background(), exportTimeout)
+	err := rest.writeExport(ctx, &export)
+	cancel()
+	export.DateEnd = time.Now()
+	fields["DateEnd"] = export.DateEnd
+	if err != nil {
+		log.WithError(err).Warnf("[RESTfacility] export %s of user %s failed", export.ExportId.String(), userId)
+		export.Status = ExportFailed
+		export.Error = err.Error()
+		fields["Error"] = export.Error
+	} else {
+		export.Status = ExportDone
+		fields["URI"] = export.URI
+		fields["Size"] = export.Size
+		fields["MessagesCount"] = export.MessagesCount
+		fields["ContactsCount"] = export.ContactsCount
+	}
+	fields["Status"] = export.Status
+	if err = rest.store.UpdateUserExport(&export, fields); err != nil {
+		log.WithError(err).Errorf("[RESTfacility] failed to save export %s of user %s", export.ExportId.String(), userId)
+		return
+	}
+
+	body := map[string]string{
+		"export_id": export.ExportId.String(),
+		"status":    export.Status,
+	}
+	notif := Notification{
+		Emitter: "api",
+		Type:    EventNotif,
+		TTLcode: LongLived,
+		User: &User{
+			UserId: export.UserId,
+		},
+		NotifId: UUID(uuid.NewV1()),
+	}
+	if export.Status == ExportDone {
+		body["download_url"] = "/api/v2/exports/" + export.ExportId.String() + "/download"
+	} else {
+		notif.Type = ErrorNotif
+	}
+	jsonBody, _ := json.Marshal(map[string]interface{}{"exportDone": body})
+	notif.Body = string(jsonBody)
+	notifier.ByNotifQueue(&notif)
+}
+
+// writeExport streams export's archive to the objects store, while writeArchive builds it.
+func (rest *RESTfacility) writeExport(ctx context.Context, export *UserExport) error {
+	userId := export.UserId.String()
+	pr, pw := io.Pipe()
+	written := make(chan error, 1)
+	go func() {
+		err := rest.writeArchive(ctx, export, pw)
+		pw.CloseWithError(err)
+		written <- err
+	}()
+	// archive is stored like an attachment, thus it is encrypted at rest if encryption is enabled
+	uri, size, err := rest.store.StoreAttachment(userId, "export-"+export.ExportId.String(), pr)
+	// unblocks writeArchive if archive has not been read up to its end
+	pr.Close()
+	if e := <-written; e != nil {
+		if err == nil {
+			rest.store.DeleteAttachment(uri)
+		}
+		return e
+	}
+	if err != nil {
+		return err
+	}
+	if uri == "" {
+		return errors.New("objects store returned an empty uri")
+	}
+	export.URI = uri
+	export.Size = size
+	return nil
+}
+
+// writeArchive writes user's messages, contacts and tags into a zip archive.
+func (rest *RESTfacility) writeArchive(ctx context.Context, export *UserExport, w io.Writer) error {
+	userId := export.UserId.String()
+	archive, err := mailbox.NewArchive(w, export.Format)
+	if err != nil {
+		return err
+	}
+	messages, err := rest.store.RetrieveAllMessages(userId)
+	if err != nil {
+		return err
+	}
+	for msg := range messages {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil {
+			continue // drain channel
+		}
+		if bytes.Equal(msg.Raw_msg_id.Bytes(), EmptyUUID.Bytes()) {
+			// draft not sent yet, it has no raw email
+			continue
+		}
+		raw, e := rest.store.GetRawMessage(msg.Raw_msg_id.String())
+		if e != nil {
+			log.WithError(e).Warnf("[RESTfacility] export %s : failed to get raw email of message %s", export.ExportId.String(), msg.Message_id.String())
+			continue
+		}
+		date := msg.Date
+		if date.IsZero() {
+			date = msg.Date_insert
+		}
+		err = archive.AddMessage([]byte(raw.Raw_data), mailbox.MessageInfo{
+			Date:     date,
+			Keywords: msg.Tags,
+			Unread:   msg.Is_unread,
+			Draft:    msg.Is_draft,
+			Trashed:  !msg.Date_delete.IsZero(),
+		})
+	}
+	if err != nil {
+		return err
+	}
+	export.MessagesCount = archive.MessagesCount()
+
+	vcards, err := archive.Create(mailbox.ContactsPath)
+	if err != nil {
+		return err
+	}
+	contacts, err := rest.store.RetrieveAllContacts(userId)
+	if err != nil {
+		return err
+	}
+	for contact := range contacts {
+		if err == nil {
+			err = ctx.Err()
+		}
+		if err != nil || !contact.Deleted.IsZero() {
+			continue
+		}
+		if err = mailbox.WriteVCard(vcards, contact); err == nil {
+			export.ContactsCount++
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	tags, err := rest.store.RetrieveUserTags(userId)
+	if err != nil {
+		return err
+	}
+	tagsFile, err := archive.Create(mailbox.TagsPath)
+	if err != nil {
+		return err
+	}
+	jsonTags := []json.RawMessage{}
+	for i := range tags {
+		if tag, e := tags[i].MarshalFrontEnd(); e == nil {
+			jsonTags = append(jsonTags, tag)
+		}
+	}
+	if err = json.NewEncoder(tagsFile).Encode(jsonTags); err != nil {
+		return err
+	}
+
+	return archive.Close()
+}
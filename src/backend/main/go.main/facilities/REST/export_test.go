@@ -0,0 +1,96 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package REST
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/mailbox"
+	"github.com/satori/go.uuid"
+	"io/ioutil"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteExport(t *testing.T) {
+	store := memory.NewMemoryBackend()
+	rest := &RESTfacility{store: store}
+	userId := UUID(uuid.NewV4())
+	store.CreateTag(&Tag{User_id: userId, Name: "work"})
+	for i := 0; i < 3; i++ {
+		raw := &RawMessage{Raw_msg_id: UUID(uuid.NewV4()), Raw_data: "Subject: hello\r\n\r\nhello " + strings.Repeat("x", i*100000)}
+		store.StoreRawMessage(raw, []UUID{userId})
+		store.CreateMessage(&Message{
+			User_id:     userId,
+			Message_id:  UUID(uuid.NewV4()),
+			Raw_msg_id:  raw.Raw_msg_id,
+			Date_insert: time.Now(),
+		})
+	}
+	newExport := func() *UserExport {
+		return &UserExport{UserId: userId, ExportId: UUID(uuid.NewV4()), Format: ExportMbox, Status: ExportPending}
+	}
+
+	export := newExport()
+	if err := rest.writeExport(context.Background(), export); err != nil {
+		t.Fatal(err)
+	}
+	if export.MessagesCount != 3 || export.URI == "" {
+		t.Fatalf("unexpected export %+v", export)
+	}
+	file, err := store.GetAttachment(export.URI)
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, _ := ioutil.ReadAll(file)
+	if len(content) != export.Size {
+		t.Errorf("expected archive of %d bytes, got %d", export.Size, len(content))
+	}
+	archive, err := zip.NewReader(bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]bool{}
+	for _, f := range archive.File {
+		files[f.Name] = true
+	}
+	if !files[mailbox.MboxPath] || !files[mailbox.ContactsPath] || !files[mailbox.TagsPath] {
+		t.Errorf("unexpected archive's files %v", files)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := rest.writeExport(ctx, newExport()); err == nil {
+		t.Error("expected export to fail once its context is done")
+	}
+}
+
+func TestStaleExport(t *testing.T) {
+	store := memory.NewMemoryBackend()
+	rest := &RESTfacility{store: store}
+	userId := UUID(uuid.NewV4())
+	running := &UserExport{UserId: userId, ExportId: UUID(uuid.NewV4()), Status: ExportPending, DateInsert: time.Now()}
+	stale := &UserExport{UserId: userId, ExportId: UUID(uuid.NewV4()), Status: ExportPending, DateInsert: time.Now().Add(-staleExportDelay - time.Minute)}
+	store.CreateUserExport(running)
+	store.CreateUserExport(stale)
+
+	if err := rest.DeleteExport(userId.String(), running.ExportId.String()); err == nil || err.Code() != FailDependencyCaliopenErr {
+		t.Errorf("expected running export to be kept, got %v", err)
+	}
+	export, err := rest.RetrieveExport(userId.String(), stale.ExportId.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if export.Status != ExportFailed {
+		t.Errorf("expected stale export to be failed, got %s", export.Status)
+	}
+	if err := rest.DeleteExport(userId.String(), stale.ExportId.String()); err != nil {
+		t.Errorf("expected stale export to be deleted, got %s", err)
+	}
+}
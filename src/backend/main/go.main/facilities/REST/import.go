@@ -0,0 +1,73 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package REST
+
+import (
+	"encoding/json"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/satori/go.uuid"
+	"io"
+	"time"
+)
+
+// CreateImport stores uploaded archive into objects store, then orders IMAP workers to import its messages.
+// Archive could be a single mbox file or a zip holding mbox files and/or Maildir folders.
+func (rest *RESTfacility) CreateImport(userId string, archive io.Reader) (*UserImport, CaliopenError) {
+	userImport := &UserImport{
+		DateInsert: time.Now(),
+		Status:     ImportPending,
+	}
+	userImport.ImportId.UnmarshalBinary(uuid.NewV4().Bytes())
+	user_uuid, err := uuid.FromString(userId)
+	if err != nil {
+		return nil, WrapCaliopenErr(err, UnprocessableCaliopenErr, "[RESTfacility] CreateImport : invalid user id")
+	}
+	userImport.UserId.UnmarshalBinary(user_uuid.Bytes())
+
+	// archive is streamed to objects store like an attachment, thus it is encrypted at rest if encryption is enabled
+	uri, size, err := rest.store.StoreAttachment(userId, "import-"+userImport.ImportId.String(), archive)
+	if err != nil {
+		return nil, WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] CreateImport failed to store archive")
+	}
+	if size == 0 {
+		rest.store.DeleteAttachment(uri)
+		return nil, NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] CreateImport : archive is empty")
+	}
+	userImport.Source = uri
+	if err = rest.store.CreateUserImport(userImport); err != nil {
+		rest.store.DeleteAttachment(uri)
+		return nil, WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] CreateImport failed to create import in store")
+	}
+
+	order, _ := json.Marshal(IMAPfetchOrder{
+		Order:      "import",
+		UserId:     userId,
+		Identifier: userImport.ImportId.String(),
+	})
+	if err = rest.PublishOnNats(string(order), rest.natsTopics[Nats_IMAP_topicKey]); err != nil {
+		userImport.Status = ImportFailed
+		userImport.Error = "failed to order import to IMAP workers"
+		rest.store.UpdateUserImport(userImport, map[string]interface{}{
+			"Status": userImport.Status,
+			"Error":  userImport.Error,
+		})
+		rest.store.DeleteAttachment(uri)
+		return nil, WrapCaliopenErr(err, FailDependencyCaliopenErr, "[RESTfacility] CreateImport failed to publish order on NATS")
+	}
+	return userImport, nil
+}
+
+func (rest *RESTfacility) RetrieveImport(userId, importId string) (*UserImport, CaliopenError) {
+	userImport, err := rest.store.RetrieveUserImport(userId, importId)
+	if err != nil {
+		if err.Error() == "not found" {
+			return nil, WrapCaliopenErr(err, NotFoundCaliopenErr, "[RESTfacility] RetrieveImport : import not found")
+		}
+		return nil, WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] RetrieveImport failed")
+	}
+	return userImport, nil
+}
@@ -7,17 +7,25 @@ package REST
 import (
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
 	m "github.com/CaliOpen/Caliopen/src/backend/main/go.main/messages"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/outbox"
+	"github.com/satori/go.uuid"
+	"time"
 )
 
 func (rest *RESTfacility) SetMessageUnread(user_id, message_id string, status bool) (err error) {
+	userId, messageId := UUID(uuid.FromStringOrNil(user_id)), UUID(uuid.FromStringOrNil(message_id))
+	mutation, err := outbox.Record(rest.store, userId, MessageType, messageId, IndexUpdate, "Is_unread")
+	if err != nil {
+		return err
+	}
 
 	err = rest.store.SetMessageUnread(user_id, message_id, status)
 	if err != nil {
 		return err
 	}
 
-	err = rest.index.SetMessageUnread(user_id, message_id, status)
-	return err
+	outbox.ApplyOrDefer(rest.store, rest.index, mutation)
+	return nil
 }
 
 func (rest *RESTfacility) GetRawMessage(raw_message_id string) (raw_message []byte, err error) {
@@ -30,25 +38,94 @@ func (rest *RESTfacility) GetRawMessage(raw_message_id string) (raw_message []by
 
 //return a list of messages given filter parameters
 //messages are sanitized, ie : ready for display in front interface, and an excerpt of body is generated
-func (rest *RESTfacility) GetMessagesList(filter IndexSearch) (messages []*Message, totalFound int64, err error) {
+func (rest *RESTfacility) GetMessagesList(filter IndexSearch, loadRemote bool) (messages []*Message, totalFound int64, err error) {
 	messages, totalFound, err = rest.index.FilterMessages(filter)
 	if err != nil {
 		return []*Message{}, 0, err
 	}
 	for _, msg := range messages {
-		m.SanitizeMessageBodies(msg)
-		(*msg).Body_excerpt = m.ExcerptMessage(*msg, 200, true, true)
+		rest.prepareMessageForDisplay(msg, loadRemote)
 	}
 	return
 }
 
 //return a sanitized message, ready for display in front interface
-func (rest *RESTfacility) GetMessage(user_id, msg_id string) (msg *Message, err error) {
+func (rest *RESTfacility) GetMessage(user_id, msg_id string, loadRemote bool) (msg *Message, err error) {
 	msg, err = rest.store.RetrieveMessage(user_id, msg_id)
 	if err != nil {
 		return nil, err
 	}
+	rest.prepareMessageForDisplay(msg, loadRemote)
+	return msg, err
+}
+
+// prepareMessageForDisplay sanitizes message's bodies and generates its excerpt.
+// Remote content of html body is blocked unless loadRemote is true, trackers are always stripped.
+// Loaded remote content goes through proxy when it is enabled.
+func (rest *RESTfacility) prepareMessageForDisplay(msg *Message, loadRemote bool) {
 	m.SanitizeMessageBodies(msg)
+	rewrite := m.BlockRemoteContent
+	if loadRemote {
+		if rest.remoteSigner != nil {
+			rewrite = rest.proxyRemoteContent(msg)
+		} else {
+			rewrite = m.KeepRemoteContent
+		}
+	}
+	m.NeutralizeRemoteContent(msg, rewrite)
 	(*msg).Body_excerpt = m.ExcerptMessage(*msg, 200, true, true)
-	return msg, err
+}
+
+// DeleteMessage moves a message to trash by setting its date_delete.
+// Message will be purged later, once user's trash retention period has passed.
+// Deleting a message that is already in trash does nothing.
+func (rest *RESTfacility) DeleteMessage(user_id, message_id string) CaliopenError {
+	msg, err := rest.store.RetrieveMessage(user_id, message_id)
+	if err != nil {
+		if err.Error() == "not found" {
+			return WrapCaliopenErr(err, NotFoundCaliopenErr, "[RESTfacility] DeleteMessage : message not found")
+		}
+		return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] DeleteMessage failed to retrieve message")
+	}
+	if !msg.Date_delete.IsZero() {
+		return nil
+	}
+	msg.Date_delete = time.Now()
+	fields := map[string]interface{}{"Date_delete": msg.Date_delete}
+	mutation, err := outbox.Record(rest.store, msg.User_id, MessageType, msg.Message_id, IndexUpdate, "Date_delete")
+	if err != nil {
+		return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] DeleteMessage failed to record index mutation")
+	}
+	err = rest.store.UpdateMessage(msg, fields)
+	if err != nil {
+		return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] DeleteMessage failed to update message in store")
+	}
+	outbox.ApplyOrDefer(rest.store, rest.index, mutation)
+	return nil
+}
+
+// RestoreMessage takes back a message from trash by removing its date_delete.
+func (rest *RESTfacility) RestoreMessage(user_id, message_id string) CaliopenError {
+	msg, err := rest.store.RetrieveMessage(user_id, message_id)
+	if err != nil {
+		if err.Error() == "not found" {
+			return WrapCaliopenErr(err, NotFoundCaliopenErr, "[RESTfacility] RestoreMessage : message not found")
+		}
+		return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] RestoreMessage failed to retrieve message")
+	}
+	if msg.Date_delete.IsZero() {
+		return NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] RestoreMessage : message is not in trash")
+	}
+	msg.Date_delete = time.Time{}
+	fields := map[string]interface{}{"Date_delete": nil}
+	mutation, err := outbox.Record(rest.store, msg.User_id, MessageType, msg.Message_id, IndexUpdate, "Date_delete")
+	if err != nil {
+		return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] RestoreMessage failed to record index mutation")
+	}
+	err = rest.store.UpdateMessage(msg, fields)
+	if err != nil {
+		return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] RestoreMessage failed to update message in store")
+	}
+	outbox.ApplyOrDefer(rest.store, rest.index, mutation)
+	return nil
 }
@@ -0,0 +1,62 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package REST
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
+	"github.com/satori/go.uuid"
+	"testing"
+	"time"
+)
+
+func TestTrashAndRestoreMessage(t *testing.T) {
+	store, index := memory.NewMemoryBackend(), memory.NewMemoryIndex()
+	rest := &RESTfacility{store: store, index: index}
+	userId := "8a8fdb3d-cd41-4988-a0a5-80ea2df2633e"
+	msg := &Message{
+		User_id:     UUID(uuid.FromStringOrNil(userId)),
+		Message_id:  UUID(uuid.NewV4()),
+		Date_insert: time.Now(),
+	}
+	store.CreateMessage(msg)
+	index.CreateMessage(msg)
+	msgId := msg.Message_id.String()
+	inTrash := func() (inStore, inIndex bool) {
+		stored, err := store.RetrieveMessage(userId, msgId)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trashed, _ := index.TrashedMessagesBefore(userId, time.Now().Add(time.Minute), 10)
+		return !stored.Date_delete.IsZero(), len(trashed) == 1
+	}
+
+	if err := rest.DeleteMessage(userId, msgId); err != nil {
+		t.Fatal(err)
+	}
+	if inStore, inIndex := inTrash(); !inStore || !inIndex {
+		t.Errorf("expected message in trash, got in store %v, in index %v", inStore, inIndex)
+	}
+	trashed, _ := store.RetrieveMessage(userId, msgId)
+	if err := rest.DeleteMessage(userId, msgId); err != nil {
+		t.Errorf("deleting a message already in trash : %s", err)
+	}
+	if again, _ := store.RetrieveMessage(userId, msgId); !again.Date_delete.Equal(trashed.Date_delete) {
+		t.Error("deleting a message already in trash changed its date_delete")
+	}
+
+	if err := rest.RestoreMessage(userId, msgId); err != nil {
+		t.Fatal(err)
+	}
+	if inStore, inIndex := inTrash(); inStore || inIndex {
+		t.Errorf("expected message restored, got in store %v, in index %v", inStore, inIndex)
+	}
+	if err := rest.RestoreMessage(userId, msgId); err == nil || err.Code() != UnprocessableCaliopenErr {
+		t.Errorf("expected restoring a message not in trash to be unprocessable, got %v", err)
+	}
+	if err := rest.DeleteMessage(userId, uuid.NewV4().String()); err == nil || err.Code() != NotFoundCaliopenErr {
+		t.Errorf("expected unknown message to be not found, got %v", err)
+	}
+}
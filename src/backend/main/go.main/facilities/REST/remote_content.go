@@ -0,0 +1,60 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package REST
+
+import (
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	m "github.com/CaliOpen/Caliopen/src/backend/main/go.main/messages"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/remote"
+	log "github.com/Sirupsen/logrus"
+	"net/url"
+)
+
+// route of REST API that serves remote content of a message through proxy
+const remoteContentRoute = "/api/v2/messages/%s/remote-content?url=%s&sig=%s"
+
+// proxyRemoteContent returns a rewriter that points remote urls of msg to proxy,
+// with a signature that proves url was found in user's message.
+func (rest *RESTfacility) proxyRemoteContent(msg *Message) m.RemoteContentRewriter {
+	user_id, message_id := msg.User_id.String(), msg.Message_id.String()
+	return func(remoteURL string) string {
+		sig := rest.remoteSigner.Sign(user_id, message_id, remoteURL)
+		return fmt.Sprintf(remoteContentRoute, message_id, url.QueryEscape(remoteURL), sig)
+	}
+}
+
+// FetchRemoteContent returns the image at remoteURL if signature proves that url was found in user's message.
+// Images are fetched without any information about user, then cached in objects store for all users.
+func (rest *RESTfacility) FetchRemoteContent(user_id, message_id, remoteURL, signature string) (content []byte, contentType string, err CaliopenError) {
+	if rest.remoteFetcher == nil {
+		return nil, "", NewCaliopenErr(NotImplementedCaliopenErr, "[RESTfacility] remote content proxy is disabled")
+	}
+	if !rest.remoteSigner.Check(user_id, message_id, remoteURL, signature) {
+		return nil, "", NewCaliopenErr(ForbiddenCaliopenErr, "[RESTfacility] invalid signature of remote content url")
+	}
+
+	key := remote.CacheKey(remoteURL)
+	if cached, e := rest.store.GetRemoteContent(key); e == nil {
+		if contentType, ok := remote.ImageType(cached); ok {
+			return cached, contentType, nil
+		}
+	}
+
+	content, contentType, e := rest.remoteFetcher.Fetch(remoteURL)
+	switch e {
+	case nil:
+	case remote.ErrForbiddenAddress:
+		return nil, "", WrapCaliopenErr(e, ForbiddenCaliopenErr, "[RESTfacility] remote content is on a forbidden address")
+	case remote.ErrInvalidURL, remote.ErrTooLarge, remote.ErrUnsupportedType:
+		return nil, "", WrapCaliopenErr(e, UnprocessableCaliopenErr, e.Error())
+	default:
+		return nil, "", WrapCaliopenErr(e, FailDependencyCaliopenErr, "[RESTfacility] failed to fetch remote content")
+	}
+	if e = rest.store.PutRemoteContent(key, content); e != nil {
+		log.WithError(e).Warn("[RESTfacility] failed to cache remote content")
+	}
+	return content, contentType, nil
+}
@@ -0,0 +1,175 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package REST
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/helpers"
+	log "github.com/Sirupsen/logrus"
+	"github.com/bitly/go-simplejson"
+	"strings"
+	"time"
+)
+
+// RetrieveSavedSearches returns user's saved searches, each one with its current unread count
+func (rest *RESTfacility) RetrieveSavedSearches(userId string, ILrange [2]int8) (searches []SavedSearch, err CaliopenError) {
+	searches, e := rest.store.RetrieveSavedSearches(userId)
+	if e != nil {
+		return searches, WrapCaliopenErr(e, DbCaliopenErr, "[RESTfacility] RetrieveSavedSearches failed")
+	}
+	for i := range searches {
+		searches[i].UnreadCount = rest.savedSearchUnreadCount(&searches[i], ILrange)
+	}
+	return searches, nil
+}
+
+func (rest *RESTfacility) CreateSavedSearch(search *SavedSearch) CaliopenError {
+	search.Label = strings.TrimSpace(search.Label)
+	search.Query = strings.TrimSpace(search.Query)
+	if e := validateSavedSearch(search); e != nil {
+		return e
+	}
+	search.MarshallNew()
+
+	err := rest.store.CreateSavedSearch(search)
+	if err != nil {
+		return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] CreateSavedSearch failed to create saved search in store")
+	}
+	return nil
+}
+
+// RetrieveSavedSearch returns a saved search with its current unread count
+func (rest *RESTfacility) RetrieveSavedSearch(userId, searchId string, ILrange [2]int8) (search *SavedSearch, err CaliopenError) {
+	search, e := rest.store.RetrieveSavedSearch(userId, searchId)
+	if e != nil {
+		return nil, WrapCaliopenErr(e, DbCaliopenErr, "[RESTfacility] RetrieveSavedSearch failed")
+	}
+	search.UnreadCount = rest.savedSearchUnreadCount(search, ILrange)
+	return search, nil
+}
+
+// PatchSavedSearch is a shortcut for REST api to :
+// - retrieve the saved search from db
+// - UpdateWithPatch()
+// - then UpdateSavedSearch() to save updated search to store if everything went good.
+func (rest *RESTfacility) PatchSavedSearch(patch []byte, userId, searchId string) CaliopenError {
+	currentSearch, e := rest.store.RetrieveSavedSearch(userId, searchId)
+	if e != nil {
+		return WrapCaliopenErr(e, DbCaliopenErr, "[RESTfacility] PatchSavedSearch failed to retrieve saved search")
+	}
+
+	// read into the patch to make basic controls before processing it with generic helper
+	patchReader, err := simplejson.NewJson(patch)
+	if err != nil {
+		return WrapCaliopenErrf(err, FailDependencyCaliopenErr, "[RESTfacility] PatchSavedSearch failed with simplejson error : %s", err)
+	}
+	// check "current_state" property is present
+	if _, hasCurrentState := patchReader.CheckGet("current_state"); !hasCurrentState {
+		return NewCaliopenErr(ForbiddenCaliopenErr, "[RESTfacility] PatchSavedSearch : current_state property must be in patch")
+	}
+
+	// patch seams OK, apply it to the resource
+	newSearch, modifiedFields, err := helpers.UpdateWithPatch(patch, currentSearch, UserActor)
+	if err != nil {
+		return WrapCaliopenErrf(err, FailDependencyCaliopenErr, "[RESTfacility] PatchSavedSearch failed with UpdateWithPatch error : %s", err)
+	}
+	search := newSearch.(*SavedSearch)
+	if e := validateSavedSearch(search); e != nil {
+		return e
+	}
+	if len(modifiedFields) == 0 {
+		return nil
+	}
+	search.DateUpdate = time.Now()
+	modifiedFields["DateUpdate"] = search.DateUpdate
+
+	// save updated resource
+	err = rest.store.UpdateSavedSearch(search, modifiedFields)
+	if err != nil {
+		return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] PatchSavedSearch failed to update saved search")
+	}
+	return nil
+}
+
+func (rest *RESTfacility) DeleteSavedSearch(userId, searchId string) CaliopenError {
+	if _, e := rest.store.RetrieveSavedSearch(userId, searchId); e != nil {
+		return WrapCaliopenErr(e, DbCaliopenErr, "[RESTfacility] DeleteSavedSearch failed to retrieve saved search")
+	}
+	e := rest.store.DeleteSavedSearch(userId, searchId)
+	if e != nil {
+		return WrapCaliopenErr(e, DbCaliopenErr, "[RESTfacility] DeleteSavedSearch failed to delete saved search")
+	}
+	return nil
+}
+
+// RunSavedSearch evaluates a saved search through index and returns matching messages,
+// ready for display in front interface.
+func (rest *RESTfacility) RunSavedSearch(userId, searchId string, ILrange [2]int8, limit, offset int, loadRemote bool) (messages []*Message, totalFound int64, err CaliopenError) {
+	search, e := rest.store.RetrieveSavedSearch(userId, searchId)
+	if e != nil {
+		return nil, 0, WrapCaliopenErr(e, DbCaliopenErr, "[RESTfacility] RunSavedSearch failed to retrieve saved search")
+	}
+	messages, totalFound, e = rest.runIndexSearch(search.IndexSearch(ILrange, limit, offset))
+	if e != nil {
+		return nil, 0, WrapCaliopenErr(e, FailDependencyCaliopenErr, "[RESTfacility] RunSavedSearch failed to search index")
+	}
+	for _, msg := range messages {
+		rest.prepareMessageForDisplay(msg, loadRemote)
+	}
+	return messages, totalFound, nil
+}
+
+// runIndexSearch sends saved search to index :
+// a search without full text term is a list of messages filtered by exact values (as GET /messages does),
+// otherwise it's a full text search restricted to filters.
+func (rest *RESTfacility) runIndexSearch(search IndexSearch) (messages []*Message, totalFound int64, err error) {
+	if len(search.Terms) == 0 {
+		return rest.index.FilterMessages(search)
+	}
+	result, err := rest.index.Search(search)
+	if err != nil {
+		return nil, 0, err
+	}
+	messages = []*Message{}
+	for _, hit := range result.MessagesHits.Messages {
+		if msg, ok := hit.Document.(*Message); ok {
+			messages = append(messages, msg)
+		}
+	}
+	return messages, result.MessagesHits.Total, nil
+}
+
+// savedSearchUnreadCount returns how many unread messages match the saved search.
+// Errors are logged out and end up with a zero count, they should not prevent saved search to be returned.
+func (rest *RESTfacility) savedSearchUnreadCount(search *SavedSearch, ILrange [2]int8) int64 {
+	unread := search.IndexSearch(ILrange, 1, 0)
+	unread.Filters["is_unread"] = []string{"true"}
+	_, total, err := rest.runIndexSearch(unread)
+	if err != nil {
+		log.WithError(err).Warnf("[RESTfacility] failed to count unread messages for saved search %s", search.SearchId.String())
+		return 0
+	}
+	return total
+}
+
+func validateSavedSearch(search *SavedSearch) CaliopenError {
+	if search.Label == "" || len(search.Label) > SavedSearchMaxLabel {
+		return NewCaliopenErrf(UnprocessableCaliopenErr, "[RESTfacility] saved search label must be between 1 and %d chars", SavedSearchMaxLabel)
+	}
+	if search.Query == "" && len(search.Filters) == 0 {
+		return NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] saved search must have a query or filters")
+	}
+	if search.Query != "" && len(search.Query) < SavedSearchMinQuery {
+		return NewCaliopenErrf(UnprocessableCaliopenErr, "[RESTfacility] saved search query must length %d chars at least", SavedSearchMinQuery)
+	}
+	for name, values := range search.Filters {
+		if strings.TrimSpace(name) == "" || len(values) == 0 {
+			return NewCaliopenErrf(UnprocessableCaliopenErr, "[RESTfacility] invalid filter <%s> for saved search", name)
+		}
+	}
+	return nil
+}
@@ -3,7 +3,6 @@ package REST
 import (
 	"errors"
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
-	m "github.com/CaliOpen/Caliopen/src/backend/main/go.main/messages"
 )
 
 // API to execute broad-based searches within index
@@ -27,11 +26,10 @@ func (rest *RESTfacility) Search(search IndexSearch) (response *IndexResult, err
 		return nil, err
 	}
 
-	// prepare messages objects for frontend rendering
+	// prepare messages objects for frontend rendering, search results never load remote content
 	for _, doc := range result.MessagesHits.Messages {
 		msg := doc.Document.(*Message)
-		m.SanitizeMessageBodies(msg)
-		(*msg).Body_excerpt = m.ExcerptMessage(*msg, 200, true, true)
+		rest.prepareMessageForDisplay(msg, false)
 	}
 
 	return result, nil
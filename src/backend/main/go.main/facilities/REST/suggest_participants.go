@@ -7,17 +7,78 @@ package REST
 import (
 	"errors"
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	log "github.com/Sirupsen/logrus"
+	"sort"
+	"time"
 )
 
+// max number of suggestions returned to frontend
+const maxRecipientSuggestions = 30
+
 // Make use of index facility to return to user a list of suggested recipients
 // within the context of composing a new message
 // list is ordered by relevance : first suggestion should be the best
 func (rest *RESTfacility) SuggestRecipients(user_id, query_string string) (suggests []RecipientSuggestion, err error) {
 	if user_id != "" && query_string != "" && len(query_string) > 2 {
 		// TODO : more consistency checking against user_id & query_string
-		return rest.index.RecipientsSuggest(user_id, query_string)
+		suggests, err = rest.index.RecipientsSuggest(user_id, query_string)
+		if err != nil {
+			return
+		}
+		rest.rankSuggestions(user_id, suggests)
+		if len(suggests) > maxRecipientSuggestions {
+			suggests = suggests[:maxRecipientSuggestions]
+		}
+		rest.addContactsPI(user_id, suggests)
+		return
 	} else {
 		err = errors.New("[RESTfacility.SuggestRecipients] unprocessable parameters")
 		return
 	}
 }
+
+// rankSuggestions sorts suggestions according to how often and how recently user exchanged messages with their address.
+// Suggestions without interaction keep the order given by index.
+func (rest *RESTfacility) rankSuggestions(user_id string, suggests []RecipientSuggestion) {
+	addresses := []string{}
+	for _, suggest := range suggests {
+		if suggest.Address != "" {
+			addresses = append(addresses, suggest.Address)
+		}
+	}
+	interactions, err := rest.store.RetrieveInteractions(user_id, addresses)
+	if err != nil {
+		log.WithError(err).Warn("[RESTfacility] failed to retrieve interactions, suggestions are not ranked")
+		return
+	}
+	if len(interactions) == 0 {
+		return
+	}
+	now := time.Now()
+	scores := make(map[string]float64, len(interactions))
+	for address, interaction := range interactions {
+		scores[address] = interaction.Score(now)
+	}
+	sort.SliceStable(suggests, func(i, j int) bool {
+		return scores[NormalizeInteractionAddress(suggests[i].Address)] > scores[NormalizeInteractionAddress(suggests[j].Address)]
+	})
+}
+
+// addContactsPI fills privacy index of suggestions that belong to a contact but came without PI
+func (rest *RESTfacility) addContactsPI(user_id string, suggests []RecipientSuggestion) {
+	contactsPI := map[string]*PrivacyIndex{}
+	for i, suggest := range suggests {
+		if suggest.Contact_Id == "" || suggest.PI != nil {
+			continue
+		}
+		pi, ok := contactsPI[suggest.Contact_Id]
+		if !ok {
+			contact, err := rest.store.RetrieveContact(user_id, suggest.Contact_Id)
+			if err == nil && contact != nil {
+				pi = contact.PrivacyIndex
+			}
+			contactsPI[suggest.Contact_Id] = pi
+		}
+		suggests[i].PI = pi
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/helpers"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/outbox"
 	"github.com/bitly/go-simplejson"
 	"github.com/mozillazg/go-unidecode"
 	"strings"
@@ -33,6 +34,9 @@ func (rest *RESTfacility) CreateTag(tag *Tag) CaliopenError {
 	if !isUnique {
 		return NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] tag's name/label conflict with existing one")
 	}
+	if tag.Expiry_days < 0 {
+		return NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] tag's expiry_days can't be negative")
+	}
 
 	err = rest.store.CreateTag(tag)
 	if err != nil {
@@ -65,16 +69,21 @@ func (rest *RESTfacility) PatchTag(patch []byte, user_id, tag_name string) Calio
 	if err != nil {
 		return WrapCaliopenErrf(err, FailDependencyCaliopenErr, "[RESTfacility] PatchTag failed with simplejson error : %s", err)
 	}
-	label := patchReader.Get("label").MustString()
-	if label == "" || strings.Replace(label, " ", "", -1) == "" {
-		return NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] new tag's label is empty")
-	}
-	isUnique, name, err := rest.IsTagLabelNameUnique(label, user_id)
-	if err != nil {
-		return NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] tag's name/label conflict with existing one")
+	if _, hasLabel := patchReader.CheckGet("label"); hasLabel {
+		label := patchReader.Get("label").MustString()
+		if label == "" || strings.Replace(label, " ", "", -1) == "" {
+			return NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] new tag's label is empty")
+		}
+		isUnique, name, err := rest.IsTagLabelNameUnique(label, user_id)
+		if err != nil {
+			return NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] tag's name/label conflict with existing one")
+		}
+		if !isUnique && name != tag_name {
+			return NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] tag's name/label conflict with existing one")
+		}
 	}
-	if !isUnique && name != tag_name {
-		return NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] tag's name/label conflict with existing one")
+	if patchReader.Get("expiry_days").MustInt(0) < 0 {
+		return NewCaliopenErr(UnprocessableCaliopenErr, "[RESTfacility] tag's expiry_days can't be negative")
 	}
 
 	// patch seams OK, apply it to the resource
@@ -100,7 +109,7 @@ func (rest *RESTfacility) UpdateTag(tag *Tag) CaliopenError {
 		if err != nil {
 			return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] UpdateTag failed to RetrieveTag from store")
 		}
-		// RESTfacility allows user to only modify label and importance_level properties
+		// RESTfacility allows user to only modify label, importance_level and expiry_days properties
 		// thus squash other properties with those from db to ignore any modifications
 		tag.Date_insert = db_tag.Date_insert
 		tag.Name = db_tag.Name
@@ -212,28 +221,28 @@ func (rest *RESTfacility) UpdateResourceTags(userID, resourceID, resourceType st
 		update := map[string]interface{}{
 			"Tags": newObj.(*Message).Tags,
 		}
-		err := rest.store.UpdateMessage(newObj.(*Message), update)
+		mutation, err := outbox.Record(rest.store, newObj.(*Message).User_id, MessageType, newObj.(*Message).Message_id, IndexUpdate, "Tags")
 		if err != nil {
 			return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] UpdateResourceTags")
 		}
-
-		err = rest.index.UpdateMessage(newObj.(*Message), update)
+		err = rest.store.UpdateMessage(newObj.(*Message), update)
 		if err != nil {
-			return WrapCaliopenErr(err, IndexCaliopenErr, "[RESTfacility] UpdateResourceTags")
+			return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] UpdateResourceTags")
 		}
+		outbox.ApplyOrDefer(rest.store, rest.index, mutation)
 	case ContactType:
 		update := map[string]interface{}{
 			"Tags": newObj.(*Contact).Tags,
 		}
-		err := rest.store.UpdateContact(newObj.(*Contact), obj.(*Contact), update)
+		mutation, err := outbox.Record(rest.store, newObj.(*Contact).UserId, ContactType, newObj.(*Contact).ContactId, IndexUpdate, "Tags")
 		if err != nil {
 			return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] UpdateResourceTags")
 		}
-
-		err = rest.index.UpdateContact(newObj.(*Contact), update)
+		err = rest.store.UpdateContact(newObj.(*Contact), obj.(*Contact), update)
 		if err != nil {
-			return WrapCaliopenErr(err, IndexCaliopenErr, "[RESTfacility] UpdateResourceTags")
+			return WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] UpdateResourceTags")
 		}
+		outbox.ApplyOrDefer(rest.store, rest.index, mutation)
 	}
 
 	return nil
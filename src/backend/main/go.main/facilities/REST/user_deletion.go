@@ -0,0 +1,51 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package REST
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	log "github.com/Sirupsen/logrus"
+	"time"
+)
+
+// RequestUserDeletion disables user's account and revokes all its sessions.
+// User's data is actually deleted by the purger worker once the grace period has passed.
+// Requesting again an already requested deletion returns the existing one.
+func (rest *RESTfacility) RequestUserDeletion(userId string) (*UserDeletion, CaliopenError) {
+	deletion, err := rest.store.RetrieveUserDeletion(userId)
+	if err == nil {
+		return deletion, nil
+	}
+	if err.Error() != "not found" {
+		return nil, WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] RequestUserDeletion failed to lookup deletion")
+	}
+	user, err := rest.store.RetrieveUser(userId)
+	if err != nil {
+		return nil, WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] RequestUserDeletion failed to retrieve user")
+	}
+
+	deletion = &UserDeletion{
+		DateInsert:  time.Now(),
+		RequestedBy: DeletionByUser,
+		Status:      DeletionPending,
+		Steps:       []string{},
+		UserId:      user.UserId,
+	}
+	if err = rest.store.CreateUserDeletion(deletion); err != nil {
+		return nil, WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] RequestUserDeletion failed to create deletion")
+	}
+	user.DateDelete = deletion.DateInsert
+	if err = rest.store.UpdateUser(user, map[string]interface{}{"DateDelete": user.DateDelete}); err != nil {
+		rest.store.DeleteUserDeletion(userId)
+		return nil, WrapCaliopenErr(err, DbCaliopenErr, "[RESTfacility] RequestUserDeletion failed to disable user")
+	}
+	if err = rest.Cache.DeleteUserSessions(userId); err != nil {
+		// user can't log in anymore and purger will revoke remaining sessions anyway
+		log.WithError(err).Warnf("[RESTfacility] RequestUserDeletion failed to revoke sessions of user %s", userId)
+	}
+	return deletion, nil
+}
@@ -0,0 +1,65 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+// Package geoip resolves IP addresses to countries and autonomous systems,
+// with offline MaxMind DB files such as GeoLite2-Country (or City) and GeoLite2-ASN.
+package geoip
+
+import (
+	"github.com/oschwald/geoip2-golang"
+	"net"
+)
+
+// Location is what is known about an IP address
+type Location struct {
+	Country      string // ISO 3166-1 alpha-2 code, empty if unknown
+	ASN          uint32 // autonomous system number, 0 if unknown
+	Organization string // autonomous system organization
+}
+
+// Resolver looks up IP addresses into a countries database and an autonomous systems database.
+// Both are optional : a nil Resolver, or one without databases, resolves nothing.
+type Resolver struct {
+	countries *geoip2.Reader
+	asns      *geoip2.Reader
+}
+
+// NewResolver loads databases files, empty paths are ignored
+func NewResolver(countryDB, asnDB string) (r *Resolver, err error) {
+	r = new(Resolver)
+	if countryDB != "" {
+		if r.countries, err = geoip2.Open(countryDB); err != nil {
+			return nil, err
+		}
+	}
+	if asnDB != "" {
+		if r.asns, err = geoip2.Open(asnDB); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Lookup returns ip's location, missing properties are left empty.
+func (r *Resolver) Lookup(ip string) (loc Location) {
+	addr := net.ParseIP(ip)
+	if r == nil || addr == nil {
+		return
+	}
+	if r.countries != nil {
+		if record, err := r.countries.Country(addr); err == nil {
+			loc.Country = record.Country.IsoCode
+			if loc.Country == "" {
+				loc.Country = record.RegisteredCountry.IsoCode
+			}
+		}
+	}
+	if r.asns != nil {
+		if record, err := r.asns.ASN(addr); err == nil {
+			loc.ASN = uint32(record.AutonomousSystemNumber)
+			loc.Organization = record.AutonomousSystemOrganization
+		}
+	}
+	return
+}
@@ -0,0 +1,155 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package geoip
+
+import (
+	"encoding/binary"
+	"github.com/oschwald/geoip2-golang"
+	"net"
+	"sort"
+	"testing"
+)
+
+// MaxMind DB format's data types and metadata marker
+const (
+	typeString = 2
+	typeUint32 = 6
+	typeMap    = 7
+)
+
+var metadataMarker = []byte("\xab\xcd\xefMaxMind.com")
+
+// buildDB returns a MaxMind DB of databaseType with an IPv4 search tree of 32 bits records, mapping networks to records
+func buildDB(databaseType string, networks map[string]map[string]interface{}) []byte {
+	const empty = -1
+	nodes := [][2]int{{empty, empty}}
+	var data []byte
+	refs := map[int]int{} // data offset by negative child value
+	cidrs := []string{}
+	for cidr := range networks {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+	for i, cidr := range cidrs {
+		_, network, _ := net.ParseCIDR(cidr)
+		ones, _ := network.Mask.Size()
+		ip := network.IP.To4()
+		node := 0
+		for b := 0; b < ones; b++ {
+			bit := int(ip[b/8]>>(7-uint(b%8))) & 1
+			if b == ones-1 {
+				nodes[node][bit] = -2 - i
+				refs[-2-i] = len(data)
+				data = append(data, encode(networks[cidr])...)
+				break
+			}
+			if nodes[node][bit] == empty {
+				nodes = append(nodes, [2]int{empty, empty})
+				nodes[node][bit] = len(nodes) - 1
+			}
+			node = nodes[node][bit]
+		}
+	}
+	count := len(nodes)
+	buf := []byte{}
+	for _, node := range nodes {
+		for _, child := range node {
+			record := uint32(count) // empty
+			if child >= 0 {
+				record = uint32(child)
+			} else if child < empty {
+				record = uint32(count + 16 + refs[child])
+			}
+			buf = append(buf, 0, 0, 0, 0)
+			binary.BigEndian.PutUint32(buf[len(buf)-4:], record)
+		}
+	}
+	buf = append(buf, make([]byte, 16)...)
+	buf = append(buf, data...)
+	buf = append(buf, metadataMarker...)
+	return append(buf, encode(map[string]interface{}{
+		"binary_format_major_version": uint32(2),
+		"database_type":               databaseType,
+		"ip_version":                  uint32(4),
+		"node_count":                  uint32(count),
+		"record_size":                 uint32(32),
+	})...)
+}
+
+func encode(value interface{}) []byte {
+	ctrl := func(kind, size int) []byte {
+		extra := []byte{}
+		if size >= 29 {
+			extra, size = []byte{byte(size - 29)}, 29
+		}
+		if kind > 7 {
+			return append([]byte{byte(size), byte(kind - 7)}, extra...)
+		}
+		return append([]byte{byte(kind<<5 | size)}, extra...)
+	}
+	switch v := value.(type) {
+	case string:
+		return append(ctrl(typeString, len(v)), v...)
+	case uint32:
+		b := ctrl(typeUint32, 4)
+		return append(b, byte(v>>24), byte(v>>16), byte(v>>8), byte(v))
+	case map[string]interface{}:
+		keys := []string{}
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		b := ctrl(typeMap, len(v))
+		for _, k := range keys {
+			b = append(b, encode(k)...)
+			b = append(b, encode(v[k])...)
+		}
+		return b
+	}
+	return nil
+}
+
+func TestLookup(t *testing.T) {
+	countries, err := geoip2.FromBytes(buildDB("GeoLite2-Country", map[string]map[string]interface{}{
+		"192.0.2.0/24":    {"country": map[string]interface{}{"iso_code": "FR"}},
+		"198.51.100.0/25": {"registered_country": map[string]interface{}{"iso_code": "DE"}},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	asns, err := geoip2.FromBytes(buildDB("GeoLite2-ASN", map[string]map[string]interface{}{
+		"192.0.2.0/25": {"autonomous_system_number": uint32(64500), "autonomous_system_organization": "Example"},
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := &Resolver{countries: countries, asns: asns}
+
+	for ip, expected := range map[string]Location{
+		"192.0.2.1":      {Country: "FR", ASN: 64500, Organization: "Example"},
+		"192.0.2.200":    {Country: "FR"},
+		"198.51.100.7":   {Country: "DE"},
+		"198.51.100.200": {},
+		"203.0.113.1":    {},
+		"2001:db8::1":    {},
+		"not an ip":      {},
+	} {
+		if loc := r.Lookup(ip); loc != expected {
+			t.Errorf("Lookup(%s) = %+v, expected %+v", ip, loc, expected)
+		}
+	}
+	if loc := (*Resolver)(nil).Lookup("192.0.2.1"); loc != (Location{}) {
+		t.Errorf("nil resolver should resolve nothing, got %+v", loc)
+	}
+
+	// databases swapped in configuration
+	swapped := &Resolver{countries: asns, asns: countries}
+	if loc := swapped.Lookup("192.0.2.1"); loc != (Location{}) {
+		t.Errorf("databases of wrong types should resolve nothing, got %+v", loc)
+	}
+	if _, err = NewResolver("/nonexistent/GeoLite2-Country.mmdb", ""); err == nil {
+		t.Error("missing database should be reported")
+	}
+}
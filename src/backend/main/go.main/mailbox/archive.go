@@ -0,0 +1,165 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+// Package mailbox converts users' data from and to standard mailbox formats :
+// mbox (mboxrd variant) and Maildir for messages, vCard 4 for contacts.
+package mailbox
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const (
+	MboxFormat    = "mbox"
+	MaildirFormat = "maildir"
+
+	MboxPath     = "messages.mbox"
+	MaildirPath  = "Maildir"
+	ContactsPath = "contacts.vcf"
+	TagsPath     = "tags.json"
+)
+
+// MessageInfo holds message's properties that are kept along with its raw email.
+type MessageInfo struct {
+	Date     time.Time
+	Keywords []string // tags names, written into X-Keywords header
+	Unread   bool
+	Draft    bool
+	Trashed  bool
+}
+
+// Archive writes messages into a zip archive, either in a single mbox file or as a Maildir tree.
+// Messages must be added first, before any other file is created into the archive.
+type Archive struct {
+	format  string
+	zw      *zip.Writer
+	mbox    io.Writer
+	count   int
+	started time.Time
+}
+
+func NewArchive(w io.Writer, format string) (*Archive, error) {
+	if format != MboxFormat && format != MaildirFormat {
+		return nil, fmt.Errorf("[Archive] unknown format <%s>", format)
+	}
+	return &Archive{
+		format:  format,
+		zw:      zip.NewWriter(w),
+		started: time.Now(),
+	}, nil
+}
+
+// AddMessage writes raw email into the archive, with info added to its headers.
+func (a *Archive) AddMessage(raw []byte, info MessageInfo) (err error) {
+	if a.format == MboxFormat {
+		if a.mbox == nil {
+			if a.mbox, err = a.zw.Create(MboxPath); err != nil {
+				return err
+			}
+		}
+		err = WriteMboxMessage(a.mbox, raw, info)
+	} else {
+		var w io.Writer
+		w, err = a.zw.CreateHeader(&zip.FileHeader{
+			Name:     MaildirPath + "/cur/" + MaildirName(a.started, a.count, info),
+			Method:   zip.Deflate,
+			Modified: info.Date,
+		})
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(addHeaders(toLF(raw), info, false))
+	}
+	if err == nil {
+		a.count++
+	}
+	return err
+}
+
+// Create adds a new file into the archive. Its content must be written before any other call on Archive.
+func (a *Archive) Create(name string) (io.Writer, error) {
+	a.mbox = nil
+	return a.zw.Create(name)
+}
+
+// Close writes missing Maildir directories and the archive's central directory.
+// It does not close the underlying writer.
+func (a *Archive) Close() error {
+	if a.format == MaildirFormat {
+		for _, dir := range []string{"cur/", "new/", "tmp/"} {
+			if _, err := a.zw.Create(MaildirPath + "/" + dir); err != nil {
+				return err
+			}
+		}
+	}
+	return a.zw.Close()
+}
+
+// MessagesCount returns the number of messages added so far.
+func (a *Archive) MessagesCount() int {
+	return a.count
+}
+
+// addHeaders prepends X-Keywords and, for mbox, Status headers to raw email.
+// Existing headers with the same names are removed.
+func addHeaders(raw []byte, info MessageInfo, withStatus bool) []byte {
+	headers, body := splitMessage(raw)
+	var buf bytes.Buffer
+	if len(info.Keywords) > 0 {
+		buf.WriteString("X-Keywords: " + strings.Join(info.Keywords, ", ") + "\n")
+	}
+	if withStatus {
+		if info.Unread {
+			buf.WriteString("Status: O\n")
+		} else {
+			buf.WriteString("Status: RO\n")
+		}
+	}
+	skip := false
+	for _, line := range strings.SplitAfter(string(headers), "\n") {
+		if line == "" {
+			continue
+		}
+		if line[0] == ' ' || line[0] == '\t' {
+			// folded header continues
+			if !skip {
+				buf.WriteString(line)
+			}
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(strings.SplitN(line, ":", 2)[0]))
+		skip = name == "x-keywords" || (withStatus && (name == "status" || name == "x-status"))
+		if !skip {
+			buf.WriteString(line)
+		}
+	}
+	buf.Write(body)
+	return buf.Bytes()
+}
+
+// splitMessage returns email's headers block and the remaining data, starting with the blank line.
+func splitMessage(raw []byte) (headers, body []byte) {
+	if bytes.HasPrefix(raw, []byte("\n")) {
+		return nil, raw
+	}
+	if i := bytes.Index(raw, []byte("\n\n")); i >= 0 {
+		return raw[:i+1], raw[i+1:]
+	}
+	if len(raw) > 0 && raw[len(raw)-1] != '\n' {
+		return append(raw, '\n'), nil
+	}
+	return raw, nil
+}
+
+// toLF converts CRLF line endings to LF, as used by mbox and Maildir files.
+func toLF(raw []byte) []byte {
+	return bytes.Replace(raw, []byte("\r\n"), []byte("\n"), -1)
+}
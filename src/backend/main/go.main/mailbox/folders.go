@@ -0,0 +1,63 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package mailbox
+
+import (
+	"github.com/mozillazg/go-unidecode"
+	"strings"
+)
+
+// folders that have no tag counterpart : Caliopen has no inbox/sent/drafts/trash folder,
+// these states are given by message's properties.
+var untaggedFolders = map[string]bool{
+	"":                 true,
+	"all mail":         true,
+	"archive":          true,
+	"archives":         true,
+	"deleted items":    true,
+	"deleted messages": true,
+	"drafts":           true,
+	"inbox":            true,
+	"mbox":             true,
+	"messages":         true, // see MboxPath
+	"outbox":           true,
+	"sent":             true,
+	"sent items":       true,
+	"sent messages":    true,
+	"trash":            true,
+}
+
+// folders mapped to system tags
+var systemFolders = map[string]string{
+	"junk":        "spam",
+	"junk e-mail": "spam",
+	"spam":        "spam",
+}
+
+// FolderTag returns the tag that messages of folder should get, empty strings if none.
+// name is the tag's identifier and label the one displayed to user.
+func FolderTag(folder string) (name, label string) {
+	key := strings.ToLower(strings.TrimSpace(folder))
+	if i := strings.Index(key, "/"); i >= 0 && untaggedFolders[key[:i]] {
+		// sub-folders of inbox are regular folders
+		key = key[i+1:]
+		folder = strings.TrimSpace(folder)[i+1:]
+	}
+	if untaggedFolders[key] {
+		return "", ""
+	}
+	if system, ok := systemFolders[key]; ok {
+		return system, system
+	}
+	return TagName(folder), folder
+}
+
+// TagName returns tag's name for the given label, as REST api does for new tags.
+func TagName(label string) string {
+	name := strings.ToLower(unidecode.Unidecode(strings.TrimSpace(label)))
+	return strings.Replace(strings.Replace(name, " ", "_", -1), "/", "_", -1)
+}
@@ -0,0 +1,182 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package mailbox
+
+import (
+	"archive/zip"
+	"bytes"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteMboxMessage(t *testing.T) {
+	raw := "From: alice@example.com\r\nX-Keywords: old\r\nStatus: RO\r\nSubject: test\r\n\r\nhello\r\nFrom here\r\n>From there"
+	var buf bytes.Buffer
+	info := MessageInfo{
+		Date:     time.Date(2018, 3, 5, 10, 4, 5, 0, time.UTC),
+		Keywords: []string{"work", "todo"},
+		Unread:   true,
+	}
+	if err := WriteMboxMessage(&buf, []byte(raw), info); err != nil {
+		t.Fatal(err)
+	}
+	expected := "From MAILER-DAEMON Mon Mar  5 10:04:05 2018\n" +
+		"X-Keywords: work, todo\n" +
+		"Status: O\n" +
+		"From: alice@example.com\n" +
+		"Subject: test\n" +
+		"\n" +
+		"hello\n" +
+		">From here\n" +
+		">>From there\n" +
+		"\n"
+	if buf.String() != expected {
+		t.Errorf("unexpected mbox message :\n%q\nexpected :\n%q", buf.String(), expected)
+	}
+}
+
+func TestArchive_Maildir(t *testing.T) {
+	var buf bytes.Buffer
+	archive, err := NewArchive(&buf, MaildirFormat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = archive.AddMessage([]byte("Subject: draft\r\n\r\nbody\r\n"), MessageInfo{Draft: true, Keywords: []string{"inbox"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = archive.AddMessage([]byte("Subject: unread\r\n\r\nbody\r\n"), MessageInfo{Unread: true, Trashed: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = archive.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	if len(names) != 5 || !strings.HasSuffix(names[0], ":2,DS") || !strings.HasSuffix(names[1], ":2,T") {
+		t.Fatalf("unexpected Maildir files %v", names)
+	}
+	if names[0] == names[1] {
+		t.Error("Maildir files names should be unique")
+	}
+	f, _ := zr.File[0].Open()
+	var content bytes.Buffer
+	content.ReadFrom(f)
+	if content.String() != "X-Keywords: inbox\nSubject: draft\n\nbody\n" {
+		t.Errorf("unexpected Maildir message %q", content.String())
+	}
+}
+
+func TestWriteVCard(t *testing.T) {
+	contact := &Contact{
+		GivenName:  "Jean",
+		FamilyName: "Dupont; Durand",
+		Emails:     []EmailContact{{Address: "jean@example.com", Type: "work", IsPrimary: true}},
+		Phones:     []Phone{{Number: "+33 1 23 45 67 89", Type: "mobile"}},
+		Tags:       []string{"family", "a,b"},
+		Infos:      map[string]string{},
+	}
+	contact.Organizations = []Organization{{Name: strings.Repeat("é", 50)}}
+	var buf bytes.Buffer
+	if err := WriteVCard(&buf, contact); err != nil {
+		t.Fatal(err)
+	}
+	vcard := buf.String()
+	for _, line := range []string{
+		"BEGIN:VCARD\r\nVERSION:4.0\r\n",
+		"FN:Jean Dupont\\; Durand\r\n",
+		"N:Dupont\\; Durand;Jean;;;\r\n",
+		"EMAIL;TYPE=work;PREF=1:jean@example.com\r\n",
+		"TEL;TYPE=cell:+33 1 23 45 67 89\r\n",
+		"CATEGORIES:family,a\\,b\r\n",
+		"END:VCARD\r\n",
+	} {
+		if !strings.Contains(vcard, line) {
+			t.Errorf("vCard should contain %q :\n%s", line, vcard)
+		}
+	}
+	for _, line := range strings.Split(vcard, "\r\n") {
+		if len(line) > vcardLineLength {
+			t.Errorf("line longer than %d octets : %q", vcardLineLength, line)
+		}
+	}
+	unfolded := strings.Replace(vcard, "\r\n ", "", -1)
+	if !strings.Contains(unfolded, "ORG:"+strings.Repeat("é", 50)+";\r\n") {
+		t.Errorf("folded line should be unfolded to its original value :\n%s", unfolded)
+	}
+}
+
+func TestWalkZip(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	files := map[string]string{
+		"Mail/Inbox":                               "From MAILER-DAEMON Mon Mar  5 10:04:05 2018\nStatus: O\nMessage-ID: <1@example.com>\n\n>From here\n\nFrom bob Tue Mar  6 10:04:05 2018\nSubject: no date\n\nbye\n\n",
+		"Mail/Archives.sbd/Work.mbox":              "From MAILER-DAEMON Mon Mar  5 10:04:05 2018\nX-Keywords: todo, Read later\n\nbody\n",
+		"Maildir/.Junk/cur/1520244245.M1.host:2,S": "Date: Mon, 05 Mar 2018 11:04:05 +0100\r\n\r\nspam\r\n",
+		"Maildir/new/1520244245.M2.host":           "Subject: new\n\nhello\n",
+		"contacts.vcf":                             "BEGIN:VCARD\r\n",
+	}
+	for name, content := range files {
+		w, _ := zw.Create(name)
+		w.Write([]byte(content))
+	}
+	zw.Close()
+
+	var messages []*ArchivedMessage
+	err := WalkZip(bytes.NewReader(buf.Bytes()), int64(buf.Len()), func(msg *ArchivedMessage) error {
+		messages = append(messages, msg)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(messages) != 5 {
+		t.Fatalf("expected 5 messages, got %d", len(messages))
+	}
+	work, inbox1, inbox2, junk, root := messages[0], messages[1], messages[2], messages[3], messages[4]
+	if work.Folder != "Mail/Archives/Work" || strings.Join(work.Info.Keywords, "|") != "todo|Read later" || work.Info.Unread {
+		t.Errorf("unexpected mbox message %+v", work)
+	}
+	if inbox1.Folder != "Mail/Inbox" || inbox1.MessageId != "1@example.com" || !inbox1.Info.Unread ||
+		string(inbox1.Raw) != "Status: O\nMessage-ID: <1@example.com>\n\nFrom here\n" {
+		t.Errorf("unexpected mbox message %+v", inbox1)
+	}
+	if !inbox2.Info.Date.Equal(time.Date(2018, 3, 6, 10, 4, 5, 0, time.UTC)) || string(inbox2.Raw) != "Subject: no date\n\nbye\n" {
+		t.Errorf("unexpected mbox message %+v", inbox2)
+	}
+	if junk.Folder != "Junk" || junk.Info.Unread || !junk.Info.Date.Equal(time.Date(2018, 3, 5, 10, 4, 5, 0, time.UTC)) {
+		t.Errorf("unexpected Maildir message %+v", junk)
+	}
+	if root.Folder != "" || !root.Info.Unread || root.Info.Date.Unix() != 1520244245 {
+		t.Errorf("unexpected Maildir message %+v", root)
+	}
+}
+
+func TestFolderTag(t *testing.T) {
+	for folder, expected := range map[string][2]string{
+		"INBOX":                {"", ""},
+		"Sent":                 {"", ""},
+		"Junk":                 {"spam", "spam"},
+		"Inbox/Café Crème":     {"cafe_creme", "Café Crème"},
+		"Mail/Archives/Travel": {"mail_archives_travel", "Mail/Archives/Travel"},
+	} {
+		name, label := FolderTag(folder)
+		if name != expected[0] || label != expected[1] {
+			t.Errorf("FolderTag(%q) returned %q, %q ; expected %q, %q", folder, name, label, expected[0], expected[1])
+		}
+	}
+}
@@ -0,0 +1,32 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package mailbox
+
+import (
+	"fmt"
+	"time"
+)
+
+// MaildirName returns a unique file name for the seq-th message of a Maildir
+// created at time t, with message's flags in the info part (see https://cr.yp.to/proto/maildir.html).
+func MaildirName(t time.Time, seq int, info MessageInfo) string {
+	return fmt.Sprintf("%d.M%dQ%d.caliopen:2,%s", t.Unix(), t.Nanosecond()/1000, seq, MaildirFlags(info))
+}
+
+// MaildirFlags returns message's Maildir flags, in ASCII order as required by the spec.
+func MaildirFlags(info MessageInfo) (flags string) {
+	if info.Draft {
+		flags += "D"
+	}
+	if !info.Unread {
+		flags += "S"
+	}
+	if info.Trashed {
+		flags += "T"
+	}
+	return
+}
@@ -0,0 +1,50 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package mailbox
+
+import (
+	"bytes"
+	"io"
+	"time"
+)
+
+// mbox "From " lines use asctime date format, in UTC.
+const mboxDateLayout = "Mon Jan _2 15:04:05 2006"
+
+// WriteMboxMessage appends raw email to an mbox file, using the mboxrd variant :
+// lines of the message that begin with "From ", optionally preceded by '>', get one more '>'.
+func WriteMboxMessage(w io.Writer, raw []byte, info MessageInfo) error {
+	date := info.Date
+	if date.IsZero() {
+		date = time.Now()
+	}
+	var buf bytes.Buffer
+	buf.WriteString("From MAILER-DAEMON " + date.UTC().Format(mboxDateLayout) + "\n")
+	msg := addHeaders(toLF(raw), info, true)
+	for len(msg) > 0 {
+		line := msg
+		if i := bytes.IndexByte(msg, '\n'); i >= 0 {
+			line = msg[:i+1]
+		}
+		msg = msg[len(line):]
+		if isFromLine(line) {
+			buf.WriteByte('>')
+		}
+		buf.Write(line)
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+	// blank line separating messages
+	buf.WriteByte('\n')
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+func isFromLine(line []byte) bool {
+	return bytes.HasPrefix(bytes.TrimLeft(line, ">"), []byte("From "))
+}
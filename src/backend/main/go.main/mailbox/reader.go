@@ -0,0 +1,297 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package mailbox
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"io"
+	"net/mail"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ArchivedMessage is an email read from a mailbox archive.
+type ArchivedMessage struct {
+	Raw       []byte
+	Folder    string // folder the message was found in, "/" separated, empty for the root mailbox
+	MessageId string // Message-ID header without angle brackets, empty if missing
+	Format    string // MboxFormat or MaildirFormat
+	Info      MessageInfo
+}
+
+// WalkFunc is called for each message read from an archive. Walk stops at the first error returned.
+type WalkFunc func(msg *ArchivedMessage) error
+
+// entry is a file found into an archive, either on filesystem or within a zip.
+type entry struct {
+	name string // "/" separated path, relative to archive's root
+	open func() (io.ReadCloser, error)
+}
+
+var zipMagic = []byte("PK\x03\x04")
+
+// Walk reads all messages found at a local path, which could be a single mbox file,
+// a zip archive or a directory holding mbox files and/or Maildir folders.
+func Walk(root string, fn WalkFunc) error {
+	info, err := os.Stat(root)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		f, err := os.Open(root)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		return WalkFile(f, mboxFolder(filepath.Base(root)), fn)
+	}
+
+	entries := []entry{}
+	err = filepath.Walk(root, func(p string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		rel, _ := filepath.Rel(root, p)
+		entries = append(entries, entry{
+			name: filepath.ToSlash(rel),
+			open: func() (io.ReadCloser, error) { return os.Open(p) },
+		})
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return walkEntries(entries, fn)
+}
+
+// WalkFile reads all messages of a zip archive or of a single mbox file.
+// folder is the folder name given to messages of a mbox file.
+func WalkFile(f *os.File, folder string, fn WalkFunc) error {
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	magic := make([]byte, len(zipMagic))
+	if n, _ := io.ReadFull(f, magic); n == len(zipMagic) && bytes.Equal(magic, zipMagic) {
+		return WalkZip(f, info.Size(), fn)
+	}
+	if _, err = f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return ReadMbox(f, folder, fn)
+}
+
+// WalkZip reads all messages from a zip archive, like the ones written by Archive.
+func WalkZip(r io.ReaderAt, size int64, fn WalkFunc) error {
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return err
+	}
+	entries := []entry{}
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		entries = append(entries, entry{name: f.Name, open: f.Open})
+	}
+	return walkEntries(entries, fn)
+}
+
+// walkEntries reads files under a cur/ or new/ directory as Maildir messages.
+// Other files are read as mbox if they begin with a "From " line, they are ignored otherwise.
+func walkEntries(entries []entry, fn WalkFunc) error {
+	sort.Slice(entries, func(i, j int) bool { return entries[i].name < entries[j].name })
+	for _, e := range entries {
+		dir, file := path.Split(e.name)
+		if strings.HasPrefix(file, ".") {
+			continue
+		}
+		dir = strings.TrimSuffix(dir, "/")
+		if sub := path.Base(dir); sub == "cur" || sub == "new" {
+			if err := readMaildirMessage(e, maildirFolder(path.Dir(dir)), sub == "new", fn); err != nil {
+				return err
+			}
+			continue
+		}
+		r, err := e.open()
+		if err != nil {
+			return err
+		}
+		err = ReadMbox(r, mboxFolder(e.name), fn)
+		r.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func readMaildirMessage(e entry, folder string, isNew bool, fn WalkFunc) error {
+	r, err := e.open()
+	if err != nil {
+		return err
+	}
+	raw, err := readAll(r)
+	r.Close()
+	if err != nil {
+		return err
+	}
+	msg := newMessage(raw, folder)
+	msg.Format = MaildirFormat
+	name := path.Base(e.name)
+	flags := ""
+	if i := strings.Index(name, ":2,"); i >= 0 {
+		flags = name[i+3:]
+	}
+	msg.Info.Unread = isNew || !strings.Contains(flags, "S")
+	msg.Info.Draft = strings.Contains(flags, "D")
+	msg.Info.Trashed = strings.Contains(flags, "T")
+	if msg.Info.Date.IsZero() {
+		// Maildir files names begin with delivery's unix time
+		if ts, err := strconv.ParseInt(strings.SplitN(name, ".", 2)[0], 10, 64); err == nil {
+			msg.Info.Date = time.Unix(ts, 0)
+		}
+	}
+	return fn(msg)
+}
+
+// ReadMbox splits an mbox file into messages, unescaping mboxrd "From " lines.
+// Nothing is read if r does not begin with a "From " line.
+func ReadMbox(r io.Reader, folder string, fn WalkFunc) error {
+	br := bufio.NewReader(r)
+	var current []byte
+	var fromLine string
+	started := false
+	flush := func() error {
+		if !started {
+			return nil
+		}
+		// remove the blank line separating messages
+		current = bytes.TrimSuffix(current, []byte("\n"))
+		msg := newMessage(current, folder)
+		msg.Format = MboxFormat
+		setMboxFlags(msg)
+		if msg.Info.Date.IsZero() {
+			msg.Info.Date = mboxDate(fromLine)
+		}
+		current = nil
+		return fn(msg)
+	}
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			line = toLF(line)
+			if bytes.HasPrefix(line, []byte("From ")) && (!started || len(current) == 0 || bytes.HasSuffix(current, []byte("\n\n"))) {
+				if e := flush(); e != nil {
+					return e
+				}
+				started = true
+				fromLine = strings.TrimSpace(string(line))
+			} else if !started {
+				// not an mbox file
+				return nil
+			} else {
+				if isFromLine(line) {
+					line = line[1:]
+				}
+				current = append(current, line...)
+			}
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return flush()
+}
+
+// newMessage parses headers of raw email to fill message's properties.
+func newMessage(raw []byte, folder string) *ArchivedMessage {
+	msg := &ArchivedMessage{
+		Raw:    raw,
+		Folder: folder,
+	}
+	m, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return msg
+	}
+	msg.MessageId = strings.Trim(strings.TrimSpace(m.Header.Get("Message-Id")), "<>")
+	if date, err := mail.ParseDate(m.Header.Get("Date")); err == nil {
+		msg.Info.Date = date
+	}
+	for _, keywords := range m.Header["X-Keywords"] {
+		for _, k := range strings.Split(keywords, ",") {
+			if k = strings.TrimSpace(k); k != "" {
+				msg.Info.Keywords = append(msg.Info.Keywords, k)
+			}
+		}
+	}
+	return msg
+}
+
+// setMboxFlags reads Status and X-Status headers, a message without Status is considered read.
+func setMboxFlags(msg *ArchivedMessage) {
+	m, err := mail.ReadMessage(bytes.NewReader(msg.Raw))
+	if err != nil {
+		return
+	}
+	if status := m.Header.Get("Status"); status != "" {
+		msg.Info.Unread = !strings.Contains(status, "R")
+	}
+	xstatus := m.Header.Get("X-Status")
+	msg.Info.Draft = strings.Contains(xstatus, "T")
+	msg.Info.Trashed = strings.Contains(xstatus, "D")
+}
+
+// mboxDate returns date of a "From sender date" line, zero time if it can't be parsed.
+func mboxDate(fromLine string) time.Time {
+	if len(fromLine) < len(mboxDateLayout) {
+		return time.Time{}
+	}
+	date, err := time.Parse(mboxDateLayout, fromLine[len(fromLine)-len(mboxDateLayout):])
+	if err != nil {
+		return time.Time{}
+	}
+	return date
+}
+
+// mboxFolder returns folder name of an mbox file, Thunderbird's .sbd directories are folders too.
+func mboxFolder(name string) string {
+	if ext := path.Ext(name); ext == ".mbox" || ext == ".mbx" {
+		name = strings.TrimSuffix(name, ext)
+	}
+	return strings.Replace(name, ".sbd/", "/", -1)
+}
+
+// maildirFolder returns folder name of a Maildir directory.
+// Maildir++ sub-folders (.A.B) are A/B, the root Maildir has no folder name.
+func maildirFolder(dir string) string {
+	base := path.Base(dir)
+	switch {
+	case strings.HasPrefix(base, ".") && base != "." && base != "..":
+		return strings.Replace(base[1:], ".", "/", -1)
+	case dir == "." || dir == "" || strings.EqualFold(base, MaildirPath):
+		return ""
+	default:
+		return dir
+	}
+}
+
+func readAll(r io.Reader) ([]byte, error) {
+	var buf bytes.Buffer
+	_, err := buf.ReadFrom(r)
+	return toLF(buf.Bytes()), err
+}
@@ -0,0 +1,156 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package mailbox
+
+import (
+	"bytes"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+const vcardLineLength = 75 // octets, CRLF excluded (RFC 6350 §3.2)
+
+var vcardEscaper = strings.NewReplacer(`\`, `\\`, `,`, `\,`, `;`, `\;`, "\r\n", `\n`, "\n", `\n`)
+
+// WriteVCard writes contact as a vCard 4.0 (RFC 6350).
+func WriteVCard(w io.Writer, contact *Contact) error {
+	var buf bytes.Buffer
+	prop := func(name, value string) {
+		writeVCardLine(&buf, name+":"+value)
+	}
+
+	prop("BEGIN", "VCARD")
+	prop("VERSION", "4.0")
+	prop("UID", "urn:uuid:"+contact.ContactId.String())
+	prop("FN", vcardText(formattedName(contact)))
+	prop("N", strings.Join([]string{
+		vcardText(contact.FamilyName),
+		vcardText(contact.GivenName),
+		vcardText(contact.AdditionalName),
+		vcardText(contact.NamePrefix),
+		vcardText(contact.NameSuffix),
+	}, ";"))
+	for _, email := range contact.Emails {
+		prop("EMAIL"+vcardParams(email.Type, email.IsPrimary), vcardText(email.Address))
+	}
+	for _, phone := range contact.Phones {
+		if phone.Uri != "" {
+			prop("TEL;VALUE=uri"+vcardParams(phoneType(phone.Type), phone.IsPrimary), phone.Uri)
+		} else {
+			prop("TEL"+vcardParams(phoneType(phone.Type), phone.IsPrimary), vcardText(phone.Number))
+		}
+	}
+	for _, adr := range contact.Addresses {
+		name := "ADR" + vcardParams(adr.Type, adr.IsPrimary)
+		if adr.Label != "" {
+			name += `;LABEL="` + strings.Replace(adr.Label, `"`, "'", -1) + `"`
+		}
+		// post office box;extended address;street;locality;region;postal code;country
+		prop(name, strings.Join([]string{
+			"", "",
+			vcardText(adr.Street),
+			vcardText(adr.City),
+			vcardText(adr.Region),
+			vcardText(adr.PostalCode),
+			vcardText(adr.Country),
+		}, ";"))
+	}
+	for _, im := range contact.Ims {
+		uri := im.Address
+		if im.Protocol != "" {
+			uri = im.Protocol + ":" + im.Address
+		}
+		if strings.Contains(uri, ":") {
+			prop("IMPP"+vcardParams(im.Type, im.IsPrimary), uri)
+		}
+	}
+	for _, org := range contact.Organizations {
+		if org.Deleted {
+			continue
+		}
+		prop("ORG", vcardText(org.Name)+";"+vcardText(org.Department))
+		if org.Title != "" {
+			prop("TITLE", vcardText(org.Title))
+		}
+		if org.JobDescription != "" {
+			prop("ROLE", vcardText(org.JobDescription))
+		}
+	}
+	if contact.Avatar != "" && strings.Contains(contact.Avatar, ":") {
+		prop("PHOTO", contact.Avatar)
+	}
+	if len(contact.Tags) > 0 {
+		values := make([]string, len(contact.Tags))
+		for i, tag := range contact.Tags {
+			values[i] = vcardText(tag)
+		}
+		prop("CATEGORIES", strings.Join(values, ","))
+	}
+	if !contact.DateUpdate.IsZero() {
+		prop("REV", contact.DateUpdate.UTC().Format("20060102T150405Z"))
+	}
+	prop("END", "VCARD")
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// formattedName returns the mandatory FN property's value.
+func formattedName(contact *Contact) string {
+	if contact.Title != "" {
+		return contact.Title
+	}
+	name := strings.TrimSpace(strings.Join([]string{contact.NamePrefix, contact.GivenName,
+		contact.AdditionalName, contact.FamilyName, contact.NameSuffix}, " "))
+	if name != "" {
+		return strings.Join(strings.Fields(name), " ")
+	}
+	if len(contact.Emails) > 0 {
+		return contact.Emails[0].Address
+	}
+	return ""
+}
+
+// vcardParams returns TYPE and PREF parameters. TYPE is omitted for values not defined by RFC 6350.
+func vcardParams(kind string, primary bool) (params string) {
+	switch kind {
+	case "home", "work", "cell", "fax", "pager", "voice", "text", "video", "textphone":
+		params = ";TYPE=" + kind
+	}
+	if primary {
+		params += ";PREF=1"
+	}
+	return
+}
+
+func phoneType(kind string) string {
+	if kind == "mobile" {
+		return "cell"
+	}
+	return kind
+}
+
+func vcardText(value string) string {
+	return vcardEscaper.Replace(value)
+}
+
+// writeVCardLine writes a content line folded every 75 octets, without splitting UTF-8 characters.
+func writeVCardLine(buf *bytes.Buffer, line string) {
+	limit := vcardLineLength
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut] + "\r\n ")
+		line = line[cut:]
+		limit = vcardLineLength - 1 // leading space of continuation lines counts
+	}
+	buf.WriteString(line + "\r\n")
+}
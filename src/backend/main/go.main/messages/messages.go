@@ -45,6 +45,8 @@ func CaliopenPolicy() *bluemonday.Policy {
 	basePolicy.AllowAttrs("marginwidth").Matching(bluemonday.Integer).OnElements("body")
 	basePolicy.AllowAttrs("marginheight").Matching(bluemonday.Integer).OnElements("body")
 	basePolicy.AllowAttrs("offset").Matching(bluemonday.Integer).OnElements("body")
+	// remote images of srcset are neutralized with src ones, see NeutralizeRemoteContent
+	basePolicy.AllowAttrs("srcset").Matching(regexp.MustCompile(`^[^<>"'\x60]*$`)).OnElements("img")
 
 	return basePolicy
 }
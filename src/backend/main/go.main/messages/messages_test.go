@@ -2,6 +2,7 @@ package messages
 
 import (
 	"github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"strings"
 	"testing"
 )
 
@@ -17,6 +18,36 @@ func TestSanitizeMessageBodies(t *testing.T) {
 	t.Log(msg_html_inlined.Body_html)
 }
 
+func TestNeutralizeRemoteContent(t *testing.T) {
+	msg := objects.Message{
+		Body_html: `<div style="background: url('https://example.com/bg.png')">` +
+			`<img src="https://example.com/logo.png" srcset="https://example.com/logo2x.png 2x, cid:logo 3x" alt="logo">` +
+			`<img src="cid:inline@caliopen.local">` +
+			`<img src="https://tracker.example.com/open?id=1" width="1" height="1">` +
+			`<a href="https://example.com/article?id=2&amp;utm_source=newsletter&amp;utm_campaign=may">read</a>` +
+			`<a href="https://example.com/about">about</a></div>`,
+	}
+	NeutralizeRemoteContent(&msg, BlockRemoteContent)
+	if strings.Contains(msg.Body_html, "https://example.com/logo") || strings.Contains(msg.Body_html, "bg.png") ||
+		strings.Contains(msg.Body_html, "tracker") || strings.Contains(msg.Body_html, "utm_") {
+		t.Errorf("remote content or trackers left in %s", msg.Body_html)
+	}
+	if !strings.Contains(msg.Body_html, `src="cid:inline@caliopen.local"`) || !strings.Contains(msg.Body_html, "cid:logo 3x") ||
+		!strings.Contains(msg.Body_html, `href="https://example.com/article?id=2"`) || !strings.Contains(msg.Body_html, `href="https://example.com/about"`) {
+		t.Errorf("local content or links altered in %s", msg.Body_html)
+	}
+	features := *msg.Privacy_features
+	if features[RemoteContentFeature] != "3" || features[TrackersFeature] != "2" {
+		t.Errorf("unexpected privacy features %v", features)
+	}
+
+	msg.Body_html = `<img src="https://example.com/logo.png">`
+	NeutralizeRemoteContent(&msg, KeepRemoteContent)
+	if msg.Body_html != `<img src="https://example.com/logo.png"/>` && msg.Body_html != `<img src="https://example.com/logo.png">` {
+		t.Errorf("remote content should be kept, got %s", msg.Body_html)
+	}
+}
+
 var (
 	msg_html2 = objects.Message{
 		Body_html: "\n\n\n\n\n\n\n<p>\n<a href=\"http://app.trouver-presta.fr/v/?camp=9544767838567738696_8&amp;ms=bGF1cmVudEBicmFpbnN0b3JtLmZy\" title=\"Si cet e-mail ne s&#39;affiche pas correctement, suivez ce lien.\" rel=\"nofollow\">Si cet email ne s’affiche pas correctement, suivez ce lien.</a></p><table width=\"600\">     <tbody>         <tr>             <td width=\"600\" height=\"40\" align=\"center\" valign=\"middle\">Ma Nouvelle Caisse enregistreuse est un jeu d&#39;enfant.<br>             Si ce message ne s&#39;affiche pas correctement, cliquez ici.</td>         </tr>         <tr>             <td width=\"600\" height=\"77\" align=\"center\"><img src=\"http://app.trouver-presta.fr/r/?rc=aHR0cHM6Ly9ub2Rlcy5uZW9wZXJmLmNvbS91cGxvYWRzL3Zpc3VlbHMvMTMvMjcva2l0cy9Mb2dvX0Jwcm8uanBn\" alt=\"BPRO\" width=\"136\" height=\"77\"></td>         </tr>         <tr>             <td width=\"600\" height=\"43\" align=\"center\" valign=\"middle\">Caisse enregistreuse</td>         </tr>         <tr>             <td width=\"600\" height=\"2\"> </td>         </tr>         <tr>             <td>             <table width=\"600\">                 <tbody>                     <tr>                         <td width=\"72\" height=\"15\"> </td>                         <td width=\"1\" height=\"15\"> </td>                         <td width=\"66\" height=\"15\"> </td>                         <td width=\"419\" height=\"15\"> </td>                         <td height=\"15\"> </td>                     </tr>                 </tbody>             </table>             </td>         </tr>         <tr>             <td>             <table width=\"600\">                 <tbody>                     <tr>                         <td><a href=\"http://app.trouver-presta.fr/r/?m=bGF1cmVudEBicmFpbnN0b3JtLmZy&amp;c=9544767838567738696_8&amp;rc=aHR0cDovL25vZGVzLm5wdjE3cWVqYmQuY29tL3IvY2xpYy0yNy0zNjItMzAxOC11cmwtYUhSMGNEb3ZMMkp3Y204dVpuSXZNemN5TUY5Q1VETmZUbVZ2Y0Q5eFkzQTlNemN5TUY5Q1VETmZUbVZ2Y0NOMWRHMWZjMjkxY21ObFBXNG1kWFJ0WDIxbFpHbDFiVDFsTFcxaGFXd21kWFJ0WDJOaGJYQmhhV2R1UFc1bGQzTmpKblYwYlY5dWIyOTJaWEp5YVdSbFBURT9yZ3JvdXA9cF8xMTM1MSZhbXA7dHJhY2thZmY9JmFtcDtncm91cD0=\" rel=\"nofollow\"><img src=\"http://app.trouver-presta.fr/r/?rc=aHR0cHM6Ly9ub2Rlcy5uZW9wZXJmLmNvbS91cGxvYWRzL3Zpc3VlbHMvMTMvMjcva2l0cy9QaWN0b19FcXVpcG10LmpwZw==\" width=\"139\" height=\"98\" alt=\"\"></a></td>                         <td>       \n                   <table width=\"419\">                             <tbody>                                 <tr>                                     <td width=\"19\"> </td>                                     <td width=\"400\" height=\"4\"> </td>                                 </tr>                                 <tr>                                     <td width=\"19\" height=\"60\"> </td>                                     <td width=\"400\" align=\"left\" valign=\"middle\"><span>Avec ma nouvelle caisse enregistreuse<br>                                     </span>l&#39;encaissement est un jeu d&#39;enfant !                 </td>                                 </tr>                                 <tr>                                     <td width=\"19\"> </td>                                     <td width=\"400\" height=\"4\"> </td>                                 </tr>                             </tbody>                         </table>                         </td>                         <td width=\"42\" height=\"98\"> </td>                     </tr>                 </tbody>             </table>             </td>         </tr>         <tr>             <td>             <table width=\"600\">           \n       <tbody>                     <tr>                         <td width=\"72\" height=\"53\"> </td>                         <td width=\"1\"> </td>                         <td width=\"66\"> </td>                         <td width=\"419\"><a href=\"http://app.trouver-presta.fr/r/?m=bGF1cmVudEBicmFpbnN0b3JtLmZy&amp;c=9544767838567738696_8&amp;rc=aHR0cDovL25vZGVzLm5wdjE3cWVqYmQuY29tL3IvY2xpYy0yNy0zNjItMzAxOC11cmwtYUhSMGNEb3ZMMkp3Y204dVpuSXZNemN5TUY5Q1VETmZUbVZ2Y0Q5eFkzQTlNemN5TUY5Q1VETmZUbVZ2Y0NOMWRHMWZjMjkxY21ObFBXNG1kWFJ0WDIxbFpHbDFiVDFsTFcxaGFXd21kWFJ0WDJOaGJYQmhhV2R1UFc1bGQzTmpKblYwYlY5dWIyOTJaWEp5YVdSbFBURT9yZ3JvdXA9cF8xMTM1MSZhbXA7dHJhY2thZmY9JmFtcDtncm91cD0=\" rel=\"nofollow\">                         <ul>                             <li>Facile</li>                             <li>Pratique</li>                             <li>Ergonomique</li>   \n                       </ul>                         </a></td>                         <td width=\"42\"> </td>                     </tr>                 </tbody>             </table>             </td>         </tr>         <tr>             <td>             <table width=\"600\">                 <tbody>                     <tr>                         <td width=\"72\" height=\"16\"> </td>                         <td width=\"1\" height=\"16\"> </td>                                                  <td height=\"16\" width=\"485\"> </td>                                                  <td width=\"42\" height=\"16\"> </td>                     </tr>                 </tbody>             </table>             </td>         </tr>         <tr>             <td>             <table width=\"600\">                 <tbody>                     <tr>                         <td width=\"72\" height=\"178\"> </td>                         <td width=\"1\" height=\"178\"> </td>                         <td><a href=\"http://app.trouver-presta.fr/r/?m=bGF1cmVudEBicmFpbnN0b3JtLmZy&amp;c=9544767838567738696_8&amp;rc=aHR0cDovL25vZGVzLm5wdjE3cWVqYmQuY29tL3IvY2xpYy0yNy0zNjItMzAxOC11cmwtYUhSMGNEb3ZMMkp3Y204dVpuSXZNemN5TUY5Q1VETmZUbVZ2Y0Q5eFkzQTlNemN5TUY5Q1VETmZUbVZ2Y0NOMWRHMWZjMjkxY21ObFBXNG1kWFJ0WDIxbFpHbDFiVDFsTFcxaGFXd21kWFJ0WDJOaGJYQmhhV2R1UFc1bGQzTmpKblYwYlY5dWIyOTJaWEp5YVdSbFBURT9yZ3JvdXA9cF8xMTM1MSZhbXA7dHJhY2thZmY9JmFtcDtncm91cD0=\" rel=\"nofollow\"><img src=\"http://app.trouver-presta.fr/r/?rc=aHR0cHM6Ly9ub2Rlcy5uZW9wZXJmLmNvbS91cGxvYWRzL3Zpc3VlbHMvMTMvMjcva2l0cy9WaXN1ZWwuanBn\" width=\"485\" height=\"178\" alt=\"\"></a></td>                         <td width=\"42\" height=\"178\"> </td>                     </tr>                 </tbody>             </table>                      </tr>         <tr>             <td>             <table width=\"600\">                 <tbody>                     <tr>                         <td width=\"72\" height=\"33\"> </td>                         <td width=\"1\" height=\"33\"> </td>                         <td width=\"527\" height=\"33\"> </td>                     </tr>                 </tbody>             </table>             </td>         </tr>         <tr>             <td>             <table width=\"600\">                 <tbody>                     <tr>                         <td width=\"72\" height=\"65\"> </td>                         <td width=\"1\" height=\"65\"> </td>                         <td width=\"91\" height=\"65\"> </td>                         <td align=\"center\"><a href=\"http://app.trouver-presta.fr/r/?m=bGF1cmVudEBicmFpbnN0b3JtLmZy&amp;c=9544767838567738696_8&amp;rc=aHR0cDovL25vZGVzLm5wdjE3cWVqYmQuY29tL3IvY2xpYy0yNy0zNjItMzAxOC11cmwtYUhSMGNEb3ZMMkp3Y204dVpuSXZNemN5TUY5Q1VETmZUbVZ2Y0Q5eFkzQTlNemN5TUY5Q1VETmZUbVZ2Y0NOMWRHMWZjMjkxY21ObFBXNG1kWFJ0WDIxbFpHbDFiVDFsTFcxaGFXd21kWFJ0WDJOaGJYQmhhV2R1UFc1bGQzTmpKblYwYlY5dWIyOTJaWEp5YVdSbFBURT9yZ3JvdXA9cF8xMTM1MSZhbXA7dHJhY2thZmY9JmFtcDtncm91cD0=\" rel=\"nofollow\"><img src=\"http://app.trouver-presta.fr/r/?rc=aHR0cHM6Ly9ub2Rlcy5uZW9wZXJmLmNvbS91cGxvYWRzL3Zpc3VlbHMvMTMvMjcva2l0cy9CdG5fRXF1aXBtdC5qcGc=\" alt=\"en savoir plus\" width=\"272\" height=\"65\"></a></td>                         <td width=\"164\" height=\"65\"> </td>                     </tr>                 </tbody>             </table>             </td>         </tr>         <tr>             <td><img width=\"600\" height=\"33\" alt=\"\"></td>         </tr>         <tr>             <td width=\"600\" height=\"35\" align=\"center\"> </td>         </tr>     </tbody> </table><img alt=\"\" src=\"http://app.trouver-presta.fr/r/?rc=aHR0cDovL25vZGVzLm5wdjE3cWVqYmQuY29tL21haWxpbmctMjctMzYyLTMwMTg/cmdyb3VwPXBfMTEzNTEmYW1wO2dyb3VwPQ==\" height=\"1\" width=\"1\"><img src=\"http://app.trouver-presta.fr/t/?i=9544767838567738696_8&amp;m=bGF1cmVudEBicmFpbnN0b3JtLmZy&amp;url=http://app.trouver-presta.fr/images/blank.jpg\" alt=\"_pspacer5\"/><p>\n<a href=\"http://app.trouver-presta.fr/d/?camp=9544767838567738696_8&amp;ms=bGF1cmVudEBicmFpbnN0b3JtLmZy\" title=\"Ne plus recevoir d&#39;informations de notre part\" rel=\"nofollow\">Pour se désabonner : Suivez ce lien.</a><br/>Si ce message vous a causé un quelconque dérangement, nous vous prions de nous en excuser.\n</p>\n\n\n\n\n",
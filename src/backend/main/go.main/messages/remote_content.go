@@ -0,0 +1,222 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package messages
+
+import (
+	"bytes"
+	"github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"golang.org/x/net/html"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// privacy features of messages ready for display
+const (
+	RemoteContentFeature = "message_remote_content" // number of remote resources (images, css urls…) found in html body
+	TrackersFeature      = "message_trackers"       // number of tracking pixels and tracked links stripped from html body
+)
+
+// RemoteContentPlaceholder replaces remote resources that are not loaded : a transparent 1x1 gif
+const RemoteContentPlaceholder = "data:image/gif;base64,R0lGODlhAQABAIAAAAAAAP///yH5BAEAAAAALAAAAAABAAEAAAIBRAA7"
+
+// RemoteContentRewriter returns the url that replaces the url of a remote resource in html bodies
+type RemoteContentRewriter func(remote string) string
+
+// BlockRemoteContent replaces remote resources with RemoteContentPlaceholder
+func BlockRemoteContent(remote string) string {
+	return RemoteContentPlaceholder
+}
+
+// KeepRemoteContent lets remote resources be loaded by frontend
+func KeepRemoteContent(remote string) string {
+	return remote
+}
+
+// attributes that make frontend fetch a resource
+var resourceAttributes = map[string]bool{
+	"src":        true,
+	"background": true,
+	"poster":     true,
+}
+
+// query parameters added to links by mailing and analytics tools to track clicks
+var trackingParams = map[string]bool{
+	"utm_source":   true,
+	"utm_medium":   true,
+	"utm_campaign": true,
+	"utm_term":     true,
+	"utm_content":  true,
+	"utm_id":       true,
+	"mc_cid":       true,
+	"mc_eid":       true,
+	"_hsenc":       true,
+	"_hsmi":        true,
+	"mkt_tok":      true,
+	"fbclid":       true,
+	"gclid":        true,
+	"dclid":        true,
+	"msclkid":      true,
+	"yclid":        true,
+}
+
+var (
+	cssURL     = regexp.MustCompile(`(?i)url\(\s*(['"]?)([^'")]*)(['"]?)\s*\)`)
+	cssImport  = regexp.MustCompile(`(?i)@import\s+(['"])([^'"]*)(['"])`)
+	cssHidden  = regexp.MustCompile(`(?i)(display\s*:\s*none|visibility\s*:\s*hidden)`)
+	dimensions = regexp.MustCompile(`^\s*(\d+)\s*(px)?\s*$`)
+)
+
+// NeutralizeRemoteContent rewrites the urls of remote resources of message's html body with rewrite :
+// images' src and srcset, background and poster attributes, css urls of style attributes and elements.
+// Tracking pixels are removed and tracking parameters are stripped from links.
+// Counts are recorded in message's privacy features, that are not saved : they describe message as displayed.
+func NeutralizeRemoteContent(msg *objects.Message, rewrite RemoteContentRewriter) {
+	if msg.Body_html == "" {
+		return
+	}
+	var remote, trackers int
+	msg.Body_html, remote, trackers = neutralizeHTML(msg.Body_html, rewrite)
+	if msg.Privacy_features == nil {
+		msg.Privacy_features = &objects.PrivacyFeatures{}
+	}
+	(*msg.Privacy_features)[RemoteContentFeature] = strconv.Itoa(remote)
+	(*msg.Privacy_features)[TrackersFeature] = strconv.Itoa(trackers)
+}
+
+func neutralizeHTML(body string, rewrite RemoteContentRewriter) (out string, remote, trackers int) {
+	z := html.NewTokenizer(strings.NewReader(body))
+	buf := new(bytes.Buffer)
+	inStyle := false
+	rewriteURL := func(u string) string {
+		if !isRemote(u) {
+			return u
+		}
+		remote++
+		return rewrite(u)
+	}
+	for {
+		tt := z.Next()
+		switch tt {
+		case html.ErrorToken:
+			// io.EOF, tokenizer has no buffer limit
+			return buf.String(), remote, trackers
+		case html.StartTagToken, html.SelfClosingTagToken:
+			t := z.Token()
+			if t.Data == "img" && isTrackingPixel(t) {
+				trackers++
+				continue
+			}
+			if t.Data == "style" && tt == html.StartTagToken {
+				inStyle = true
+			}
+			for i, attr := range t.Attr {
+				switch {
+				case resourceAttributes[attr.Key]:
+					t.Attr[i].Val = rewriteURL(strings.TrimSpace(attr.Val))
+				case attr.Key == "srcset":
+					t.Attr[i].Val = rewriteSrcset(attr.Val, rewriteURL)
+				case attr.Key == "style":
+					t.Attr[i].Val = rewriteCSS(attr.Val, rewriteURL)
+				case attr.Key == "href" && t.Data == "a":
+					if stripped, ok := stripTrackingParams(attr.Val); ok {
+						t.Attr[i].Val = stripped
+						trackers++
+					}
+				}
+			}
+			buf.WriteString(t.String())
+		case html.EndTagToken:
+			if name, _ := z.TagName(); string(name) == "style" {
+				inStyle = false
+			}
+			buf.Write(z.Raw())
+		case html.TextToken:
+			if inStyle {
+				buf.WriteString(rewriteCSS(string(z.Raw()), rewriteURL))
+			} else {
+				buf.Write(z.Raw())
+			}
+		default:
+			buf.Write(z.Raw())
+		}
+	}
+}
+
+// isRemote tells if u makes frontend fetch a resource from a remote server
+func isRemote(u string) bool {
+	u = strings.ToLower(strings.TrimSpace(u))
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "//")
+}
+
+// isTrackingPixel tells if img is invisible : at most 1x1 pixel, or hidden by its style
+func isTrackingPixel(img html.Token) bool {
+	var width, height = -1, -1
+	for _, attr := range img.Attr {
+		switch attr.Key {
+		case "width", "height":
+			if m := dimensions.FindStringSubmatch(attr.Val); m != nil {
+				size, _ := strconv.Atoi(m[1])
+				if attr.Key == "width" {
+					width = size
+				} else {
+					height = size
+				}
+			}
+		case "style":
+			if cssHidden.MatchString(attr.Val) {
+				return true
+			}
+		}
+	}
+	return width == 0 || height == 0 || (width >= 0 && width <= 1 && height >= 0 && height <= 1)
+}
+
+// rewriteSrcset rewrites the url of each candidate of a srcset attribute, keeping its descriptor
+func rewriteSrcset(srcset string, rewriteURL func(string) string) string {
+	candidates := strings.Split(srcset, ",")
+	for i, candidate := range candidates {
+		fields := strings.Fields(candidate)
+		if len(fields) == 0 {
+			continue
+		}
+		fields[0] = rewriteURL(fields[0])
+		candidates[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(candidates, ", ")
+}
+
+// rewriteCSS rewrites urls of url() functions and @import rules of css
+func rewriteCSS(css string, rewriteURL func(string) string) string {
+	css = cssURL.ReplaceAllStringFunc(css, func(match string) string {
+		m := cssURL.FindStringSubmatch(match)
+		return "url(" + m[1] + rewriteURL(strings.TrimSpace(m[2])) + m[3] + ")"
+	})
+	return cssImport.ReplaceAllStringFunc(css, func(match string) string {
+		m := cssImport.FindStringSubmatch(match)
+		return "@import " + m[1] + rewriteURL(m[2]) + m[3]
+	})
+}
+
+// stripTrackingParams removes tracking parameters from link's query, ok is false if link has none
+func stripTrackingParams(link string) (stripped string, ok bool) {
+	u, err := url.Parse(strings.TrimSpace(link))
+	if err != nil || u.RawQuery == "" {
+		return link, false
+	}
+	query := u.Query()
+	for name := range query {
+		if trackingParams[strings.ToLower(name)] {
+			query.Del(name)
+			ok = true
+		}
+	}
+	if !ok {
+		return link, false
+	}
+	u.RawQuery = query.Encode()
+	return u.String(), true
+}
@@ -0,0 +1,160 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+// Package outbox replicates store writes to index through the store's index outbox.
+//
+// A mutation is recorded before each store write that must be replicated, then applied at once.
+// If index write fails, mutation is left in outbox and the indexer worker retries it later.
+// Mutations are applied with the resource as it is in store when they are applied,
+// thus they can be applied many times and in any order, index always ends up in line with store.
+package outbox
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/messages"
+	log "github.com/Sirupsen/logrus"
+	"github.com/satori/go.uuid"
+	"gopkg.in/oleiade/reflections.v1"
+	"gopkg.in/olivere/elastic.v5"
+	"net/http"
+	"time"
+)
+
+// Record saves a new mutation into store's outbox.
+// It must be called before the store write it replicates : if store write fails, applying the mutation
+// rewrites into index what is in store, which is harmless.
+// fields are the struct fields names modified by an IndexUpdate.
+func Record(store backends.IndexOutbox, userId UUID, resourceType string, resourceId UUID, operation string, fields ...string) (*IndexMutation, error) {
+	mutation := &IndexMutation{
+		DateInsert:   time.Now(),
+		Fields:       fields,
+		MutationId:   UUID(uuid.NewV1()),
+		Operation:    operation,
+		ResourceId:   resourceId,
+		ResourceType: resourceType,
+		UserId:       userId,
+	}
+	err := store.CreateIndexMutation(mutation)
+	if err != nil {
+		return nil, fmt.Errorf("[Outbox] failed to record %s of %s %s : %s", operation, resourceType, resourceId.String(), err)
+	}
+	return mutation, nil
+}
+
+// Apply writes mutation into index, then removes it from outbox.
+// On failure, mutation's attempts and last error are updated and mutation is left in outbox.
+func Apply(store backends.OutboxStore, index backends.OutboxIndex, mutation *IndexMutation) error {
+	err := apply(store, index, mutation)
+	if err != nil {
+		mutation.Attempts++
+		mutation.LastError = err.Error()
+		e := store.UpdateIndexMutation(mutation, map[string]interface{}{
+			"Attempts":  mutation.Attempts,
+			"LastError": mutation.LastError,
+		})
+		if e != nil {
+			log.WithError(e).Warnf("[Outbox] failed to update mutation %s", mutation.MutationId.String())
+		}
+		return err
+	}
+	return store.DeleteIndexMutation(mutation.UserId.String(), mutation.MutationId.String())
+}
+
+// ApplyOrDefer applies mutation, failures are only logged : the indexer worker will retry.
+// It is meant for facilities, once the store write succeeded.
+func ApplyOrDefer(store backends.OutboxStore, index backends.OutboxIndex, mutation *IndexMutation) {
+	if mutation == nil {
+		return
+	}
+	if err := Apply(store, index, mutation); err != nil {
+		log.WithError(err).Warnf("[Outbox] %s of %s %s deferred to indexer", mutation.Operation, mutation.ResourceType, mutation.ResourceId.String())
+	}
+}
+
+func apply(store backends.OutboxStore, index backends.OutboxIndex, mutation *IndexMutation) error {
+	userId, resourceId := mutation.UserId.String(), mutation.ResourceId.String()
+	switch mutation.ResourceType {
+	case MessageType:
+		msg, err := store.RetrieveMessage(userId, resourceId)
+		if err != nil && err.Error() != "not found" {
+			return err
+		}
+		if msg == nil || err != nil || mutation.Operation == IndexDelete {
+			// resource is not in store anymore
+			err = index.DeleteMessage(&Message{User_id: mutation.UserId, Message_id: mutation.ResourceId})
+			return ignoreNotFound(err)
+		}
+		SetIndexedOnly(msg)
+		if mutation.Operation == IndexUpdate {
+			err = index.UpdateMessage(msg, fieldsValues(msg, mutation.Fields))
+			if !isNotFound(err) {
+				return err
+			}
+			// not indexed yet : create below
+		}
+		return index.CreateMessage(msg)
+	case ContactType:
+		contact, err := store.RetrieveContact(userId, resourceId)
+		if err != nil && err.Error() != "not found" {
+			return err
+		}
+		if contact == nil || err != nil || mutation.Operation == IndexDelete {
+			err = index.DeleteContact(&Contact{UserId: mutation.UserId, ContactId: mutation.ResourceId})
+			return ignoreNotFound(err)
+		}
+		if mutation.Operation == IndexUpdate {
+			err = index.UpdateContact(contact, fieldsValues(contact, mutation.Fields))
+			if !isNotFound(err) {
+				return err
+			}
+		}
+		return index.CreateContact(contact)
+	}
+	return errors.New("[Outbox] unknown resource type " + mutation.ResourceType)
+}
+
+// SetIndexedOnly sets the properties of a message read from store that are indexed but not stored :
+// its excerpt is derived from its bodies, as when message is displayed.
+func SetIndexedOnly(msg *Message) {
+	msg.Body_excerpt = messages.ExcerptMessage(*msg, 200, true, true)
+}
+
+// fieldsValues returns the current values of fields, as expected by index's Update methods.
+// Zero dates are sent as null, to remove them from index.
+func fieldsValues(obj interface{}, fields []string) map[string]interface{} {
+	values := map[string]interface{}{}
+	for _, field := range fields {
+		value, err := reflections.GetField(obj, field)
+		if err != nil {
+			log.WithError(err).Warnf("[Outbox] unknown field %s", field)
+			continue
+		}
+		if t, ok := value.(time.Time); ok && t.IsZero() {
+			value = nil
+		}
+		values[field] = value
+	}
+	return values
+}
+
+// isNotFound returns true for indexes' errors for missing documents.
+// A missing Elasticsearch index is not one : documents must not be recreated into an index that has not been set up.
+func isNotFound(err error) bool {
+	if e, ok := err.(*elastic.Error); ok {
+		return e.Status == http.StatusNotFound && (e.Details == nil || e.Details.Type != "index_not_found_exception")
+	}
+	return err != nil && err.Error() == "not found"
+}
+
+func ignoreNotFound(err error) error {
+	if isNotFound(err) {
+		return nil
+	}
+	return err
+}
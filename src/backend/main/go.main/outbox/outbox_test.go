@@ -0,0 +1,118 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package outbox
+
+import (
+	"errors"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
+	"github.com/satori/go.uuid"
+	"gopkg.in/olivere/elastic.v5"
+	"strings"
+	"testing"
+)
+
+// failingIndex fails all writes, as an unavailable index would do
+type failingIndex struct {
+	*memory.MemoryIndex
+}
+
+func (failingIndex) UpdateMessage(msg *Message, fields map[string]interface{}) error {
+	return errors.New("index unavailable")
+}
+
+func pending(t *testing.T, store *memory.MemoryBackend) (mutations []*IndexMutation) {
+	ch, err := store.RetrieveIndexMutations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for m := range ch {
+		mutations = append(mutations, m)
+	}
+	return
+}
+
+func TestApply(t *testing.T) {
+	store, index := memory.NewMemoryBackend(), memory.NewMemoryIndex()
+	msg := &Message{
+		User_id:    UUID(uuid.FromStringOrNil("8a8fdb3d-cd41-4988-a0a5-80ea2df2633e")),
+		Message_id: UUID(uuid.FromStringOrNil("06e35fed-72d5-4138-b5d3-cc2e28a1bf6d")),
+		Subject:    "hello",
+	}
+	userId, msgId := msg.User_id.String(), msg.Message_id.String()
+
+	// update of a message not indexed yet ends up with message created into index
+	m, err := Record(store, msg.User_id, MessageType, msg.Message_id, IndexCreate)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.CreateMessage(msg)
+	msg.Tags = []string{"work"}
+	store.UpdateMessage(msg, map[string]interface{}{"Tags": msg.Tags})
+	if err := Apply(store, index, m); err != nil {
+		t.Fatal(err)
+	}
+	indexed, _ := index.IndexedMessages(userId, []string{msgId})
+	if indexed[msgId] == nil || len(indexed[msgId].Tags) != 1 {
+		t.Fatalf("expected message to be indexed with its tags, got %+v", indexed[msgId])
+	}
+	if p := pending(t, store); len(p) != 0 {
+		t.Errorf("expected outbox to be empty, got %d mutations", len(p))
+	}
+
+	// failed mutation stays in outbox
+	m, _ = Record(store, msg.User_id, MessageType, msg.Message_id, IndexUpdate, "Subject")
+	msg.Subject = "updated"
+	store.UpdateMessage(msg, map[string]interface{}{"Subject": msg.Subject})
+	if err := Apply(store, failingIndex{index}, m); err == nil {
+		t.Fatal("expected Apply to fail")
+	}
+	p := pending(t, store)
+	if len(p) != 1 || p[0].Attempts != 1 || p[0].LastError == "" {
+		t.Fatalf("expected failed mutation to be left in outbox with its attempt, got %+v", p)
+	}
+
+	// retry succeeds
+	if err := Apply(store, index, p[0]); err != nil {
+		t.Fatal(err)
+	}
+	indexed, _ = index.IndexedMessages(userId, []string{msgId})
+	if indexed[msgId].Subject != "updated" {
+		t.Errorf("expected subject to be updated in index, got %s", indexed[msgId].Subject)
+	}
+
+	// excerpt is not stored, it is derived from bodies
+	m, _ = Record(store, msg.User_id, MessageType, msg.Message_id, IndexUpdate, "Body_plain", "Body_excerpt")
+	msg.Body_plain = "decrypted body"
+	store.UpdateMessage(msg, map[string]interface{}{"Body_plain": msg.Body_plain})
+	if err := Apply(store, index, m); err != nil {
+		t.Fatal(err)
+	}
+	indexed, _ = index.IndexedMessages(userId, []string{msgId})
+	if !strings.HasPrefix(indexed[msgId].Body_excerpt, "decrypted") {
+		t.Errorf("expected excerpt of new body to be indexed, got %q", indexed[msgId].Body_excerpt)
+	}
+
+	// mutation of a resource removed from store removes it from index, whatever its operation
+	m, _ = Record(store, msg.User_id, MessageType, msg.Message_id, IndexUpdate, "Subject")
+	store.DeleteMessage(msg)
+	if err := Apply(store, index, m); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := index.IndexedIds(userId, MessageIndexType); len(ids) != 0 {
+		t.Errorf("expected message to be removed from index, got %v", ids)
+	}
+}
+
+func TestIsNotFound(t *testing.T) {
+	missingDoc := &elastic.Error{Status: 404, Details: &elastic.ErrorDetails{Type: "document_missing_exception"}}
+	missingIndex := &elastic.Error{Status: 404, Details: &elastic.ErrorDetails{Type: "index_not_found_exception"}}
+	if !isNotFound(missingDoc) || !isNotFound(&elastic.Error{Status: 404}) || !isNotFound(errors.New("not found")) {
+		t.Error("expected missing documents to be not found")
+	}
+	if isNotFound(missingIndex) || isNotFound(errors.New("index unavailable")) || isNotFound(nil) {
+		t.Error("expected missing index and failures not to be not found")
+	}
+}
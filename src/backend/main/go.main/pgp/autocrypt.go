@@ -0,0 +1,194 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package pgp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/mail"
+	"strings"
+)
+
+// Autocrypt (https://autocrypt.org/level1.html) prefer-encrypt values
+const (
+	PreferEncryptMutual       = "mutual"
+	PreferEncryptNoPreference = "nopreference"
+)
+
+// privacy features of a PublicKey learnt from emails
+const (
+	AutocryptPreferEncryptFeature = "autocrypt_prefer_encrypt"
+	AutocryptTimestampFeature     = "autocrypt_timestamp" // date of the most recent email the key has been seen in, RFC3339
+	KeySourceFeature              = "key_source"          // KeySourceAutocrypt or KeySourceAttachment
+)
+
+const (
+	KeySourceAutocrypt  = "autocrypt"
+	KeySourceAttachment = "attachment"
+)
+
+// AutocryptHeader is a parsed Autocrypt header
+type AutocryptHeader struct {
+	Addr          string
+	PreferEncrypt string // PreferEncryptMutual or PreferEncryptNoPreference
+	Key           *openpgp.Entity
+}
+
+// ParseAutocrypt parses the value of an Autocrypt header.
+// Headers with an unknown critical attribute, or without addr or keydata, are invalid.
+func ParseAutocrypt(value string) (*AutocryptHeader, error) {
+	h := &AutocryptHeader{PreferEncrypt: PreferEncryptNoPreference}
+	var keydata string
+	for _, attribute := range strings.Split(value, ";") {
+		attribute = strings.TrimSpace(attribute)
+		if attribute == "" {
+			continue
+		}
+		kv := strings.SplitN(attribute, "=", 2)
+		if len(kv) != 2 {
+			return nil, errors.New("[Autocrypt] malformed attribute " + attribute)
+		}
+		name := strings.ToLower(strings.TrimSpace(kv[0]))
+		switch {
+		case name == "addr":
+			h.Addr = strings.TrimSpace(kv[1])
+		case name == "prefer-encrypt":
+			if strings.TrimSpace(kv[1]) == PreferEncryptMutual {
+				h.PreferEncrypt = PreferEncryptMutual
+			}
+		case name == "keydata":
+			keydata = kv[1]
+		case strings.HasPrefix(name, "_"):
+			// non critical attribute
+		default:
+			return nil, errors.New("[Autocrypt] unknown critical attribute " + name)
+		}
+	}
+	if h.Addr == "" || keydata == "" {
+		return nil, errors.New("[Autocrypt] missing addr or keydata attribute")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(keydata), ""))
+	if err != nil {
+		return nil, err
+	}
+	keys, err := openpgp.ReadKeyRing(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) != 1 {
+		return nil, errors.New("[Autocrypt] keydata must hold exactly one key")
+	}
+	h.Key = keys[0]
+	return h, nil
+}
+
+// AutocryptHeaderValue returns the Autocrypt header value advertising entity's public key for address.
+// Key data is split by spaces, for the header to be folded.
+func AutocryptHeaderValue(address string, entity *openpgp.Entity, preferEncrypt string) (string, error) {
+	buf := new(bytes.Buffer)
+	if err := entity.Serialize(buf); err != nil {
+		return "", err
+	}
+	value := "addr=" + address + "; "
+	if preferEncrypt == PreferEncryptMutual {
+		value += "prefer-encrypt=mutual; "
+	}
+	keydata := base64.StdEncoding.EncodeToString(buf.Bytes())
+	chunks := []string{}
+	for len(keydata) > 64 {
+		chunks = append(chunks, keydata[:64])
+		keydata = keydata[64:]
+	}
+	chunks = append(chunks, keydata)
+	return value + "keydata=" + strings.Join(chunks, " "), nil
+}
+
+// EmailAutocrypt returns the key advertised by the single valid Autocrypt header of email, whose addr is sender's address.
+// It returns nil if email has none, or more than one.
+func EmailAutocrypt(header mail.Header, sender string) *AutocryptHeader {
+	var found *AutocryptHeader
+	for _, value := range header["Autocrypt"] {
+		h, err := ParseAutocrypt(value)
+		if err != nil || !strings.EqualFold(h.Addr, sender) {
+			continue
+		}
+		if found != nil {
+			return nil
+		}
+		found = h
+	}
+	return found
+}
+
+// AttachedKeys returns the OpenPGP public keys found in application/pgp-keys parts of raw email.
+func AttachedKeys(raw []byte) (keys openpgp.EntityList) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil
+	}
+	walkParts(msg.Header.Get("Content-Type"), msg.Header.Get("Content-Transfer-Encoding"), msg.Body, func(body []byte) {
+		list, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(body))
+		if err != nil {
+			if list, err = openpgp.ReadKeyRing(bytes.NewReader(body)); err != nil {
+				return
+			}
+		}
+		keys = append(keys, list...)
+	}, 0)
+	return
+}
+
+// walkParts calls found with the decoded body of each application/pgp-keys part of a MIME entity
+func walkParts(contentType, encoding string, body io.Reader, found func([]byte), depth int) {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || depth > 10 {
+		return
+	}
+	switch {
+	case strings.HasPrefix(mediaType, "multipart/"):
+		// encrypted parts are not read
+		if mediaType == "multipart/encrypted" || params["boundary"] == "" {
+			return
+		}
+		r := multipart.NewReader(body, params["boundary"])
+		for {
+			part, err := r.NextPart()
+			if err != nil {
+				return
+			}
+			walkParts(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part, found, depth+1)
+		}
+	case mediaType == "application/pgp-keys":
+		if strings.EqualFold(strings.TrimSpace(encoding), "base64") {
+			body = base64.NewDecoder(base64.StdEncoding, body)
+		}
+		if b, err := ioutil.ReadAll(body); err == nil {
+			found(b)
+		}
+	}
+}
+
+// ArmoredPublicKey returns entity's public key, ASCII armored
+func ArmoredPublicKey(entity *openpgp.Entity) ([]byte, error) {
+	buf := new(bytes.Buffer)
+	w, err := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	if err != nil {
+		return nil, err
+	}
+	if err = entity.Serialize(w); err != nil {
+		return nil, err
+	}
+	if err = w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,50 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package pgp
+
+import (
+	"bytes"
+	"net/mail"
+	"strings"
+	"testing"
+)
+
+func TestAutocrypt(t *testing.T) {
+	alice := newEntity(t, "Alice", "alice@caliopen.local")
+	value, err := AutocryptHeaderValue("alice@caliopen.local", alice, PreferEncryptMutual)
+	if err != nil {
+		t.Fatal(err)
+	}
+	email := "From: alice@caliopen.local\r\nAutocrypt: " + value + "\r\nAutocrypt: addr=alice@caliopen.local; _extra=1; keydata=invalid\r\n\r\nHello\r\n"
+	msg, err := mail.ReadMessage(strings.NewReader(email))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := EmailAutocrypt(msg.Header, "Alice@Caliopen.local")
+	if h == nil || h.PreferEncrypt != PreferEncryptMutual || h.Key.PrimaryKey.KeyId != alice.PrimaryKey.KeyId {
+		t.Fatalf("unexpected Autocrypt header %+v", h)
+	}
+	if EmailAutocrypt(msg.Header, "mallory@example.com") != nil {
+		t.Error("Autocrypt header of another address should be ignored")
+	}
+	if _, err = ParseAutocrypt("addr=alice@caliopen.local; unknown=1; keydata=" + strings.SplitN(value, "keydata=", 2)[1]); err == nil {
+		t.Error("unknown critical attribute should be rejected")
+	}
+
+	armored, err := ArmoredPublicKey(alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	email = "From: alice@caliopen.local\r\nContent-Type: multipart/mixed; boundary=b\r\n\r\n" +
+		"--b\r\nContent-Type: text/plain\r\n\r\nmy key\r\n" +
+		"--b\r\nContent-Type: application/pgp-keys\r\n\r\n" + string(armored) + "\r\n--b--\r\n"
+	keys := AttachedKeys([]byte(email))
+	if len(keys) != 1 || keys[0].PrimaryKey.KeyId != alice.PrimaryKey.KeyId || keys[0].PrivateKey != nil {
+		t.Errorf("unexpected attached keys %v", keys)
+	}
+	if !bytes.HasPrefix(armored, []byte("-----BEGIN PGP PUBLIC KEY BLOCK-----")) {
+		t.Errorf("unexpected armored key %q", armored)
+	}
+}
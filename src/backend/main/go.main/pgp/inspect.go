@@ -0,0 +1,278 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package pgp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"golang.org/x/crypto/openpgp/packet"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+)
+
+// signature statuses
+const (
+	SignatureValid      = "valid"
+	SignatureInvalid    = "invalid"
+	SignatureUnknownKey = "unknown_key" // signer's key is not one of sender's known keys
+)
+
+const (
+	beginMessage = "-----BEGIN PGP MESSAGE-----"
+	endMessage   = "-----END PGP MESSAGE-----"
+	beginSigned  = "-----BEGIN PGP SIGNED MESSAGE-----"
+	endSignature = "-----END PGP SIGNATURE-----"
+)
+
+// Protection is the OpenPGP protection found in an email
+type Protection struct {
+	Encrypted       bool
+	Inline          bool   // inline PGP, PGP/MIME otherwise
+	Signed          bool   // false for an encrypted email that could not be decrypted, its signature is unknown
+	SignatureStatus string // one of Signature* constants when Signed
+	Signer          string // hexadecimal id of the key that made the signature
+	Decrypted       []byte // email with decrypted content, nil if email is not encrypted or could not be decrypted
+}
+
+// Inspect looks for PGP/MIME (RFC 3156) and inline PGP protection of raw email.
+// Signatures are checked against senderKeys, encrypted content is decrypted with secretKeys if one of them is a recipient.
+// It returns nil if email is not protected.
+func Inspect(raw []byte, senderKeys, secretKeys openpgp.EntityList) (*Protection, error) {
+	raw = canonicalize(raw)
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	headers, _, err := splitMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+	body := partBody(raw)
+	mediaType, params, _ := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	p := &Protection{}
+	switch {
+	case mediaType == "multipart/encrypted" && strings.EqualFold(params["protocol"], "application/pgp-encrypted"):
+		p.Encrypted = true
+		parts := splitParts(body, params["boundary"])
+		if len(parts) < 2 {
+			return p, nil
+		}
+		plain, err := p.decrypt(partBody(parts[1]), senderKeys, secretKeys)
+		if err != nil || plain == nil {
+			return p, err
+		}
+		// content may have been signed then encrypted (RFC 3156 section 6.1)
+		decrypted := append(headers, canonicalize(plain)...)
+		if inner, err := Inspect(decrypted, senderKeys, nil); err == nil && inner != nil && inner.Signed && !p.Signed {
+			p.Signed, p.SignatureStatus, p.Signer = true, inner.SignatureStatus, inner.Signer
+		}
+		p.Decrypted = decrypted
+
+	case mediaType == "multipart/signed" && strings.EqualFold(params["protocol"], "application/pgp-signature"):
+		parts := splitParts(body, params["boundary"])
+		if len(parts) < 2 {
+			return p, nil
+		}
+		p.checkSignature(parts[0], partBody(parts[1]), senderKeys)
+
+	case mediaType == "" || strings.HasPrefix(mediaType, "text/"):
+		body, err = decodeBody(msg)
+		if err != nil {
+			return nil, err
+		}
+		begin, end := armoredBlock(body, beginMessage, endMessage)
+		if begin >= 0 {
+			p.Encrypted, p.Inline = true, true
+			plain, err := p.decrypt(body[begin:end], senderKeys, secretKeys)
+			if err != nil || plain == nil {
+				return p, err
+			}
+			// decrypted text may be a clear signed one
+			if signed, rest := clearsign.Decode(plain); signed != nil && !p.Signed {
+				p.checkSignature(signed.Bytes, readAll(signed.ArmoredSignature.Body), senderKeys)
+				plain = append(signed.Plaintext, rest...)
+			}
+			text := append(append(body[:begin:begin], plain...), body[end:]...)
+			p.Decrypted = inlineEmail(headers, params["charset"], text)
+			return p, nil
+		}
+		begin, end = armoredBlock(body, beginSigned, endSignature)
+		if begin < 0 {
+			return nil, nil
+		}
+		p.Inline = true
+		if signed, _ := clearsign.Decode(body[begin:end]); signed != nil {
+			p.checkSignature(signed.Bytes, readAll(signed.ArmoredSignature.Body), senderKeys)
+		} else {
+			p.Signed, p.SignatureStatus = true, SignatureInvalid
+		}
+
+	default:
+		return nil, nil
+	}
+	return p, nil
+}
+
+// decrypt returns the decrypted content of ASCII armored message, nil if none of secretKeys can decrypt it.
+// Signature of the message, if any, is checked against senderKeys.
+func (p *Protection) decrypt(armored []byte, senderKeys, secretKeys openpgp.EntityList) ([]byte, error) {
+	if len(secretKeys) == 0 {
+		return nil, nil
+	}
+	block, err := armor.Decode(bytes.NewReader(armored))
+	if err != nil {
+		return nil, nil
+	}
+	keyring := append(append(openpgp.EntityList{}, secretKeys...), senderKeys...)
+	md, err := openpgp.ReadMessage(block.Body, keyring, nil, config)
+	if err != nil {
+		// not encrypted to one of our keys
+		return nil, nil
+	}
+	plain, err := ioutil.ReadAll(md.UnverifiedBody)
+	if err != nil {
+		return nil, err
+	}
+	if md.IsSigned {
+		p.Signed = true
+		p.Signer = fmt.Sprintf("%016X", md.SignedByKeyId)
+		switch {
+		case md.SignedBy == nil || !isOneOf(md.SignedBy.Entity, senderKeys):
+			p.SignatureStatus = SignatureUnknownKey
+		case md.SignatureError != nil:
+			p.SignatureStatus = SignatureInvalid
+		default:
+			p.SignatureStatus = SignatureValid
+		}
+	}
+	return plain, nil
+}
+
+// checkSignature checks the detached signature of signed content
+func (p *Protection) checkSignature(signed, signature []byte, senderKeys openpgp.EntityList) {
+	p.Signed = true
+	sig := signature
+	if block, err := armor.Decode(bytes.NewReader(signature)); err == nil {
+		sig = readAll(block.Body)
+	}
+	pkt, err := packet.Read(bytes.NewReader(sig))
+	if err != nil {
+		p.SignatureStatus = SignatureInvalid
+		return
+	}
+	var issuer uint64
+	switch s := pkt.(type) {
+	case *packet.Signature:
+		if s.IssuerKeyId != nil {
+			issuer = *s.IssuerKeyId
+		}
+	case *packet.SignatureV3:
+		issuer = s.IssuerKeyId
+	default:
+		p.SignatureStatus = SignatureInvalid
+		return
+	}
+	p.Signer = fmt.Sprintf("%016X", issuer)
+	if len(senderKeys.KeysById(issuer)) == 0 {
+		p.SignatureStatus = SignatureUnknownKey
+		return
+	}
+	if _, err = openpgp.CheckDetachedSignature(senderKeys, bytes.NewReader(signed), bytes.NewReader(sig)); err != nil {
+		p.SignatureStatus = SignatureInvalid
+		return
+	}
+	p.SignatureStatus = SignatureValid
+}
+
+// splitParts returns the raw parts of a multipart body, as they are signed :
+// the CRLF preceding a boundary belongs to the boundary.
+func splitParts(body []byte, boundary string) (parts [][]byte) {
+	if boundary == "" {
+		return
+	}
+	delimiter := []byte("\r\n--" + boundary)
+	chunks := bytes.Split(append([]byte("\r\n"), body...), delimiter)
+	if len(chunks) < 3 {
+		return
+	}
+	for _, chunk := range chunks[1 : len(chunks)-1] {
+		// skip the end of boundary line (transport padding and CRLF)
+		if i := bytes.Index(chunk, []byte("\r\n")); i >= 0 {
+			parts = append(parts, chunk[i+2:])
+		}
+	}
+	return
+}
+
+// partBody returns the body of a MIME part, or of an email, following its headers
+func partBody(part []byte) []byte {
+	if bytes.HasPrefix(part, []byte("\r\n")) {
+		return part[2:]
+	}
+	if i := bytes.Index(part, []byte("\r\n\r\n")); i >= 0 {
+		return part[i+4:]
+	}
+	return nil
+}
+
+// decodeBody returns the body of a single part email, decoded from its transfer encoding
+func decodeBody(msg *mail.Message) ([]byte, error) {
+	var r io.Reader = msg.Body
+	switch strings.ToLower(strings.TrimSpace(msg.Header.Get("Content-Transfer-Encoding"))) {
+	case "quoted-printable":
+		r = quotedprintable.NewReader(r)
+	case "base64":
+		r = base64.NewDecoder(base64.StdEncoding, r)
+	}
+	return ioutil.ReadAll(r)
+}
+
+// armoredBlock returns the bounds of the first ASCII armored block of body starting with begin line, -1 if none
+func armoredBlock(body []byte, begin, end string) (int, int) {
+	start := bytes.Index(body, []byte(begin))
+	if start < 0 {
+		return -1, -1
+	}
+	stop := bytes.Index(body[start:], []byte(end))
+	if stop < 0 {
+		return start, len(body)
+	}
+	return start, start + stop + len(end)
+}
+
+// inlineEmail returns an email made of headers, without Content-* ones, and of text as its plain text body
+func inlineEmail(headers []byte, charset string, text []byte) []byte {
+	if charset == "" {
+		charset = "utf-8"
+	}
+	b := bytes.NewBuffer(append([]byte{}, headers...))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=" + charset + "\r\n")
+	b.WriteString("Content-Transfer-Encoding: 8bit\r\n\r\n")
+	b.Write(canonicalize(text))
+	return b.Bytes()
+}
+
+func isOneOf(entity *openpgp.Entity, list openpgp.EntityList) bool {
+	for _, e := range list {
+		if e == entity {
+			return true
+		}
+	}
+	return false
+}
+
+func readAll(r io.Reader) []byte {
+	b, _ := ioutil.ReadAll(r)
+	return b
+}
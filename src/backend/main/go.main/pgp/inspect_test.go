@@ -0,0 +1,108 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package pgp
+
+import (
+	"bytes"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/clearsign"
+	"strings"
+	"testing"
+)
+
+func TestInspectSigned(t *testing.T) {
+	alice := newEntity(t, "Alice", "alice@caliopen.local")
+	mallory := newEntity(t, "Mallory", "mallory@example.com")
+	signed, err := SignMIME([]byte(testEmail), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := Inspect(signed, openpgp.EntityList{alice}, nil)
+	if err != nil || p == nil {
+		t.Fatalf("expected a protection, got %v, %v", p, err)
+	}
+	if p.Encrypted || !p.Signed || p.SignatureStatus != SignatureValid || p.Inline {
+		t.Errorf("unexpected protection %+v", p)
+	}
+
+	p, _ = Inspect(signed, openpgp.EntityList{mallory}, nil)
+	if p.SignatureStatus != SignatureUnknownKey {
+		t.Errorf("expected unknown key, got %+v", p)
+	}
+
+	tampered := bytes.Replace(signed, []byte("Hello Bob"), []byte("Hello Eve"), 1)
+	p, _ = Inspect(tampered, openpgp.EntityList{alice}, nil)
+	if p.SignatureStatus != SignatureInvalid {
+		t.Errorf("expected invalid signature, got %+v", p)
+	}
+
+	p, err = Inspect([]byte(testEmail), openpgp.EntityList{alice}, nil)
+	if p != nil || err != nil {
+		t.Errorf("clear email should not be protected, got %+v, %v", p, err)
+	}
+}
+
+func TestInspectEncrypted(t *testing.T) {
+	alice := newEntity(t, "Alice", "alice@caliopen.local")
+	bob := newEntity(t, "Bob", "bob@example.com")
+	encrypted, err := EncryptMIME([]byte(testEmail), openpgp.EntityList{bob}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// without bob's secret key
+	p, err := Inspect(encrypted, openpgp.EntityList{alice}, nil)
+	if err != nil || p == nil || !p.Encrypted || p.Signed || p.Decrypted != nil {
+		t.Fatalf("unexpected protection %+v, %v", p, err)
+	}
+
+	p, err = Inspect(encrypted, openpgp.EntityList{alice}, openpgp.EntityList{bob})
+	if err != nil || p == nil {
+		t.Fatalf("expected a protection, got %v, %v", p, err)
+	}
+	if !p.Encrypted || !p.Signed || p.SignatureStatus != SignatureValid {
+		t.Errorf("unexpected protection %+v", p)
+	}
+	decrypted := string(p.Decrypted)
+	if !strings.HasPrefix(decrypted, "From: alice@caliopen.local\r\n") || !strings.Contains(decrypted, "Subject: hello\r\n") ||
+		!strings.HasSuffix(decrypted, testEntity) {
+		t.Errorf("unexpected decrypted email %q", decrypted)
+	}
+}
+
+func TestInspectInline(t *testing.T) {
+	alice := newEntity(t, "Alice", "alice@caliopen.local")
+	bob := newEntity(t, "Bob", "bob@example.com")
+
+	// clear signed text
+	clear := new(bytes.Buffer)
+	w, _ := clearsign.Encode(clear, alice.PrivateKey, config)
+	w.Write([]byte("Hello Bob\n"))
+	w.Close()
+	email := "From: alice@caliopen.local\r\nSubject: hello\r\n\r\nsome text\r\n" + clear.String()
+	p, err := Inspect([]byte(email), openpgp.EntityList{alice}, nil)
+	if err != nil || p == nil || !p.Inline || !p.Signed || p.SignatureStatus != SignatureValid {
+		t.Fatalf("unexpected protection %+v, %v", p, err)
+	}
+
+	// encrypted text
+	armored := new(bytes.Buffer)
+	aw, _ := armor.Encode(armored, "PGP MESSAGE", nil)
+	pw, _ := openpgp.Encrypt(aw, openpgp.EntityList{bob}, nil, nil, config)
+	pw.Write([]byte("Secret for Bob\n"))
+	pw.Close()
+	aw.Close()
+	email = "From: alice@caliopen.local\r\nSubject: hello\r\nContent-Type: text/plain; charset=iso-8859-1\r\n\r\nintro\r\n" + armored.String() + "\r\nbye\r\n"
+	p, err = Inspect([]byte(email), openpgp.EntityList{alice}, openpgp.EntityList{bob})
+	if err != nil || p == nil || !p.Inline || !p.Encrypted || p.Signed {
+		t.Fatalf("unexpected protection %+v, %v", p, err)
+	}
+	decrypted := string(p.Decrypted)
+	if !strings.Contains(decrypted, "charset=iso-8859-1") || !strings.HasSuffix(decrypted, "\r\n\r\nintro\r\nSecret for Bob\r\n\r\nbye\r\n") {
+		t.Errorf("unexpected decrypted email %q", decrypted)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package pgp
+
+import (
+	"fmt"
+	"golang.org/x/crypto/openpgp"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Keyring holds the secret keys of local identities, to sign outbound emails and decrypt inbound ones.
+type Keyring struct {
+	entities  openpgp.EntityList
+	byAddress map[string]*openpgp.Entity // lowercased email address of user ids
+}
+
+// LoadKeyring reads the ASCII armored secret keys of all *.asc files in dir.
+// Keys protected by a passphrase can't be used unattended, they are ignored.
+func LoadKeyring(dir string) (*Keyring, error) {
+	files, err := filepath.Glob(filepath.Join(dir, "*.asc"))
+	if err != nil {
+		return nil, err
+	}
+	k := &Keyring{byAddress: map[string]*openpgp.Entity{}}
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			return nil, err
+		}
+		list, err := openpgp.ReadArmoredKeyRing(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("[PGP] failed to read keys of %s : %s", file, err)
+		}
+		for _, entity := range list {
+			k.Add(entity)
+		}
+	}
+	return k, nil
+}
+
+// Add adds entity to keyring if its secret key is usable
+func (k *Keyring) Add(entity *openpgp.Entity) {
+	if entity.PrivateKey == nil || entity.PrivateKey.Encrypted {
+		return
+	}
+	if k.byAddress == nil {
+		k.byAddress = map[string]*openpgp.Entity{}
+	}
+	k.entities = append(k.entities, entity)
+	for _, id := range entity.Identities {
+		if id.UserId != nil && id.UserId.Email != "" {
+			k.byAddress[strings.ToLower(id.UserId.Email)] = entity
+		}
+	}
+}
+
+// Entity returns the secret key of address, nil if there is none.
+// A nil Keyring holds no key.
+func (k *Keyring) Entity(address string) *openpgp.Entity {
+	if k == nil {
+		return nil
+	}
+	return k.byAddress[strings.ToLower(address)]
+}
+
+// Entities returns all keys of keyring
+func (k *Keyring) Entities() openpgp.EntityList {
+	if k == nil {
+		return nil
+	}
+	return k.entities
+}
@@ -0,0 +1,92 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+// Package pgp handles OpenPGP keys of contacts and local identities,
+// and PGP/MIME (RFC 3156) protection of emails.
+package pgp
+
+import (
+	"bytes"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/packet"
+	"strings"
+	"time"
+)
+
+// key types of PublicKey that may hold an OpenPGP key
+var openPGPKeyTypes = map[string]bool{
+	"":    true,
+	"gpg": true,
+	"pgp": true,
+}
+
+// ReadPublicKeys returns the OpenPGP keys found in keys, ASCII armored or binary.
+// Expired keys and keys that are not OpenPGP ones are ignored.
+func ReadPublicKeys(keys []PublicKey, now time.Time) (entities openpgp.EntityList) {
+	for _, key := range keys {
+		if len(key.Key) == 0 || !openPGPKeyTypes[strings.ToLower(key.KeyType)] ||
+			(!key.ExpireDate.IsZero() && key.ExpireDate.Before(now)) {
+			continue
+		}
+		list, err := openpgp.ReadArmoredKeyRing(bytes.NewReader(key.Key))
+		if err != nil {
+			if list, err = openpgp.ReadKeyRing(bytes.NewReader(key.Key)); err != nil {
+				continue
+			}
+		}
+		entities = append(entities, list...)
+	}
+	return
+}
+
+// CanEncrypt tells if entity has a valid key to encrypt messages at time now
+func CanEncrypt(entity *openpgp.Entity, now time.Time) bool {
+	if len(entity.Revocations) > 0 {
+		return false
+	}
+	self := primarySelfSignature(entity)
+	if self == nil || self.KeyExpired(now) {
+		return false
+	}
+	for _, sub := range entity.Subkeys {
+		if sub.Sig != nil && sub.Sig.FlagsValid && sub.Sig.FlagEncryptCommunications &&
+			sub.PublicKey.PubKeyAlgo.CanEncrypt() && !sub.Sig.KeyExpired(now) {
+			return true
+		}
+	}
+	return (!self.FlagsValid || self.FlagEncryptCommunications) && entity.PrimaryKey.PubKeyAlgo.CanEncrypt()
+}
+
+// ExpireDate returns the expiration date of entity's primary key, zero if it does not expire
+func ExpireDate(entity *openpgp.Entity) time.Time {
+	self := primarySelfSignature(entity)
+	if self == nil || self.KeyLifetimeSecs == nil || *self.KeyLifetimeSecs == 0 {
+		return time.Time{}
+	}
+	return entity.PrimaryKey.CreationTime.Add(time.Duration(*self.KeyLifetimeSecs) * time.Second)
+}
+
+// HasAddress tells if one of entity's user ids is for email address
+func HasAddress(entity *openpgp.Entity, address string) bool {
+	for _, id := range entity.Identities {
+		if id.UserId != nil && strings.EqualFold(id.UserId.Email, address) {
+			return true
+		}
+	}
+	return false
+}
+
+// primarySelfSignature returns the self signature of entity's primary identity, or of any identity if none is primary
+func primarySelfSignature(entity *openpgp.Entity) (self *packet.Signature) {
+	for _, id := range entity.Identities {
+		if id.SelfSignature == nil {
+			continue
+		}
+		if self == nil || (id.SelfSignature.IsPrimaryId != nil && *id.SelfSignature.IsPrimaryId) {
+			self = id.SelfSignature
+		}
+	}
+	return
+}
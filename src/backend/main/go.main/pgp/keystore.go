@@ -0,0 +1,67 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package pgp
+
+import (
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/openpgp"
+	"os"
+	"path/filepath"
+)
+
+type (
+	KeystoreConfig struct {
+		KeystoreType string // only "fs" for now
+		Path         string // for "fs" keystore only
+	}
+
+	// Keystore holds users' OpenPGP secret keys, to decrypt their inbound emails server side.
+	Keystore interface {
+		// UserKeys returns user's secret keys, none if user has not stored any.
+		UserKeys(user_id string) (openpgp.EntityList, error)
+	}
+
+	// FSKeystore is a Keystore reading keys from a directory tree : <path>/<user_id>/*.asc,
+	// each file holding ASCII armored secret keys without passphrase.
+	FSKeystore struct {
+		path string
+	}
+)
+
+func InitializeKeystore(config KeystoreConfig) (Keystore, error) {
+	switch config.KeystoreType {
+	case "fs":
+		return NewFSKeystore(config.Path)
+	default:
+		return nil, fmt.Errorf("[PGP] unknown keystore type <%s>", config.KeystoreType)
+	}
+}
+
+func NewFSKeystore(path string) (*FSKeystore, error) {
+	if path == "" {
+		return nil, errors.New("[PGP] missing path of fs keystore")
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return nil, fmt.Errorf("[PGP] fs keystore %s is not a directory", path)
+	}
+	return &FSKeystore{path: path}, nil
+}
+
+func (ks *FSKeystore) UserKeys(user_id string) (openpgp.EntityList, error) {
+	// user_id comes from our own store, but never read outside of keystore
+	if user_id == "" || filepath.Base(user_id) != user_id {
+		return nil, fmt.Errorf("[PGP] invalid user id <%s>", user_id)
+	}
+	keyring, err := LoadKeyring(filepath.Join(ks.path, user_id))
+	if err != nil {
+		return nil, err
+	}
+	return keyring.Entities(), nil
+}
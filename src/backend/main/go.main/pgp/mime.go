@@ -0,0 +1,149 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package pgp
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"golang.org/x/crypto/openpgp/packet"
+	"strings"
+)
+
+// micalg parameter of multipart/signed, must match config's hash
+const micalg = "pgp-sha256"
+
+var config = &packet.Config{DefaultHash: crypto.SHA256}
+
+// SignMIME returns raw email as a multipart/signed email (RFC 3156 section 5) :
+// its content is signed by signer, its other headers are kept unchanged.
+func SignMIME(raw []byte, signer *openpgp.Entity) ([]byte, error) {
+	headers, entity, err := splitMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+	signature := new(bytes.Buffer)
+	if err = openpgp.ArmoredDetachSign(signature, signer, bytes.NewReader(entity), config); err != nil {
+		return nil, err
+	}
+
+	boundary := randomBoundary()
+	b := bytes.NewBuffer(headers)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString(`Content-Type: multipart/signed; micalg=` + micalg + `; protocol="application/pgp-signature"; boundary="` + boundary + "\"\r\n\r\n")
+	b.WriteString("This is an OpenPGP/MIME signed message (RFC 4880 and 3156)\r\n")
+	b.WriteString("--" + boundary + "\r\n")
+	b.Write(entity)
+	b.WriteString("\r\n--" + boundary + "\r\n")
+	b.WriteString("Content-Type: application/pgp-signature; name=\"signature.asc\"\r\n")
+	b.WriteString("Content-Description: OpenPGP digital signature\r\n")
+	b.WriteString("Content-Disposition: attachment; filename=\"signature.asc\"\r\n\r\n")
+	b.Write(canonicalize(signature.Bytes()))
+	b.WriteString("\r\n--" + boundary + "--\r\n")
+	return b.Bytes(), nil
+}
+
+// EncryptMIME returns raw email as a multipart/encrypted email (RFC 3156 section 4) :
+// its content is encrypted to recipients, and signed by signer if not nil (section 6.2),
+// its other headers are kept unchanged.
+func EncryptMIME(raw []byte, recipients openpgp.EntityList, signer *openpgp.Entity) ([]byte, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("[PGP] no recipient to encrypt to")
+	}
+	headers, entity, err := splitMessage(raw)
+	if err != nil {
+		return nil, err
+	}
+	encrypted := new(bytes.Buffer)
+	armored, err := armor.Encode(encrypted, "PGP MESSAGE", nil)
+	if err != nil {
+		return nil, err
+	}
+	plain, err := openpgp.Encrypt(armored, recipients, signer, &openpgp.FileHints{IsBinary: true}, config)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = plain.Write(entity); err != nil {
+		return nil, err
+	}
+	if err = plain.Close(); err != nil {
+		return nil, err
+	}
+	if err = armored.Close(); err != nil {
+		return nil, err
+	}
+
+	boundary := randomBoundary()
+	b := bytes.NewBuffer(headers)
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString(`Content-Type: multipart/encrypted; protocol="application/pgp-encrypted"; boundary="` + boundary + "\"\r\n\r\n")
+	b.WriteString("This is an OpenPGP/MIME encrypted message (RFC 4880 and 3156)\r\n")
+	b.WriteString("--" + boundary + "\r\n")
+	b.WriteString("Content-Type: application/pgp-encrypted\r\n")
+	b.WriteString("Content-Description: PGP/MIME version identification\r\n\r\n")
+	b.WriteString("Version: 1\r\n\r\n")
+	b.WriteString("--" + boundary + "\r\n")
+	b.WriteString("Content-Type: application/octet-stream; name=\"encrypted.asc\"\r\n")
+	b.WriteString("Content-Description: OpenPGP encrypted message\r\n")
+	b.WriteString("Content-Disposition: inline; filename=\"encrypted.asc\"\r\n\r\n")
+	b.Write(canonicalize(encrypted.Bytes()))
+	b.WriteString("\r\n--" + boundary + "--\r\n")
+	return b.Bytes(), nil
+}
+
+// splitMessage separates the headers describing raw email's content (Content-*) from the others.
+// It returns the others, without MIME-Version, and the content as a MIME entity made of its headers and email's body.
+// Line endings are canonicalized to CRLF.
+func splitMessage(raw []byte) (headers, entity []byte, err error) {
+	raw = canonicalize(raw)
+	end := bytes.Index(raw, []byte("\r\n\r\n"))
+	if end < 0 {
+		return nil, nil, errors.New("[PGP] email has no body")
+	}
+	outer, inner := new(bytes.Buffer), new(bytes.Buffer)
+	var current *bytes.Buffer
+	for _, line := range bytes.SplitAfter(raw[:end+2], []byte("\r\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		if line[0] != ' ' && line[0] != '\t' {
+			// new header, else continuation of current one
+			name := strings.ToLower(string(line))
+			switch {
+			case strings.HasPrefix(name, "content-"):
+				current = inner
+			case strings.HasPrefix(name, "mime-version:"):
+				current = nil
+			default:
+				current = outer
+			}
+		}
+		if current != nil {
+			current.Write(line)
+		}
+	}
+	if inner.Len() == 0 {
+		inner.WriteString("Content-Type: text/plain; charset=us-ascii\r\n")
+	}
+	inner.WriteString("\r\n")
+	inner.Write(raw[end+4:])
+	return outer.Bytes(), inner.Bytes(), nil
+}
+
+// canonicalize returns b with CRLF line endings
+func canonicalize(b []byte) []byte {
+	b = bytes.Replace(b, []byte("\r\n"), []byte("\n"), -1)
+	return bytes.Replace(b, []byte("\n"), []byte("\r\n"), -1)
+}
+
+func randomBoundary() string {
+	r := make([]byte, 15)
+	rand.Read(r)
+	return "caliopen-pgp-" + hex.EncodeToString(r)
+}
@@ -0,0 +1,142 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package pgp
+
+import (
+	"bytes"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+	"io/ioutil"
+	"mime"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testEmail = "From: alice@caliopen.local\r\n" +
+	"To: bob@example.com\r\n" +
+	"Subject: hello\r\n" +
+	"MIME-Version: 1.0\r\n" +
+	"Content-Type: text/plain; charset=UTF-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Hello Bob=0A\r\n"
+
+const testEntity = "Content-Type: text/plain; charset=UTF-8\r\n" +
+	"Content-Transfer-Encoding: quoted-printable\r\n" +
+	"\r\n" +
+	"Hello Bob=0A\r\n"
+
+func newEntity(t *testing.T, name, address string) *openpgp.Entity {
+	entity, err := openpgp.NewEntity(name, "", address, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// signs identities and subkeys
+	if err = entity.SerializePrivate(ioutil.Discard, nil); err != nil {
+		t.Fatal(err)
+	}
+	return entity
+}
+
+// parts returns email's headers and the parts of its multipart body
+func parts(t *testing.T, raw []byte) (mail.Header, [][]byte) {
+	msg, err := mail.ReadMessage(bytes.NewReader(raw))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(msg.Body)
+	// multipart.Reader decodes parts, split raw body on boundary instead
+	chunks := bytes.Split(body, []byte("\r\n--"+params["boundary"]))
+	result := [][]byte{}
+	for _, chunk := range chunks[1 : len(chunks)-1] {
+		result = append(result, bytes.TrimPrefix(chunk, []byte("\r\n")))
+	}
+	return msg.Header, result
+}
+
+func TestSignMIME(t *testing.T) {
+	alice := newEntity(t, "Alice", "alice@caliopen.local")
+	signed, err := SignMIME([]byte(testEmail), alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, p := parts(t, signed)
+	if header.Get("Subject") != "hello" || header.Get("To") != "bob@example.com" {
+		t.Errorf("headers not kept : %v", header)
+	}
+	if ct := header.Get("Content-Type"); !strings.HasPrefix(ct, "multipart/signed; micalg=pgp-sha256;") {
+		t.Errorf("unexpected content type %s", ct)
+	}
+	if len(p) != 2 || string(p[0]) != testEntity {
+		t.Fatalf("unexpected signed entity %q", p)
+	}
+	signature := p[1][bytes.Index(p[1], []byte("-----BEGIN")):]
+	if _, err = openpgp.CheckArmoredDetachedSignature(openpgp.EntityList{alice}, bytes.NewReader(p[0]), bytes.NewReader(signature)); err != nil {
+		t.Errorf("invalid signature : %s", err)
+	}
+}
+
+func TestEncryptMIME(t *testing.T) {
+	alice := newEntity(t, "Alice", "alice@caliopen.local")
+	bob := newEntity(t, "Bob", "bob@example.com")
+	if !CanEncrypt(bob, time.Now()) || !HasAddress(bob, "Bob@Example.com") {
+		t.Fatal("bob's key should be usable")
+	}
+	encrypted, err := EncryptMIME([]byte(testEmail), openpgp.EntityList{bob}, alice)
+	if err != nil {
+		t.Fatal(err)
+	}
+	header, p := parts(t, encrypted)
+	if ct := header.Get("Content-Type"); !strings.HasPrefix(ct, `multipart/encrypted; protocol="application/pgp-encrypted"`) {
+		t.Errorf("unexpected content type %s", ct)
+	}
+	if len(p) != 2 || !strings.Contains(string(p[0]), "Version: 1") {
+		t.Fatalf("unexpected parts %q", p)
+	}
+	block, err := armor.Decode(bytes.NewReader(p[1][bytes.Index(p[1], []byte("-----BEGIN")):]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := openpgp.ReadMessage(block.Body, openpgp.EntityList{bob, alice}, nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	plain, _ := ioutil.ReadAll(md.UnverifiedBody)
+	if string(plain) != testEntity {
+		t.Errorf("unexpected decrypted entity %q", plain)
+	}
+	if !md.IsSigned || md.SignatureError != nil || md.SignedBy == nil || md.SignedBy.Entity != alice {
+		t.Errorf("encrypted entity should be signed by alice : %v", md.SignatureError)
+	}
+}
+
+func TestReadPublicKeys(t *testing.T) {
+	bob := newEntity(t, "Bob", "bob@example.com")
+	buf := new(bytes.Buffer)
+	w, _ := armor.Encode(buf, openpgp.PublicKeyType, nil)
+	bob.Serialize(w)
+	w.Close()
+
+	keys := ReadPublicKeys(nil, time.Now())
+	if len(keys) != 0 {
+		t.Error("no key expected")
+	}
+	keys = ReadPublicKeys([]PublicKey{
+		{Key: buf.Bytes(), KeyType: "gpg"},
+		{Key: buf.Bytes(), KeyType: "gpg", ExpireDate: time.Now().Add(-time.Hour)},
+		{Key: buf.Bytes(), KeyType: "ec"},
+		{Key: []byte("garbage"), KeyType: "gpg"},
+	}, time.Now())
+	if len(keys) != 1 || !CanEncrypt(keys[0], time.Now()) {
+		t.Errorf("expected bob's key only, got %d keys", len(keys))
+	}
+}
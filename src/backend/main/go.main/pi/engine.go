@@ -0,0 +1,124 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+// Package pi computes privacy indexes.
+//
+// A message's privacy index is the sum of the points given by scoring rules to its features,
+// within each of the three dimensions : technic, context and comportment.
+// Rules are versioned as a whole : RulesVersion must be incremented whenever DefaultRules change,
+// messages scored by older rules are then scored again (see Rescore).
+package pi
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"time"
+)
+
+// PI dimensions
+const (
+	Technic     = "technic"
+	Context     = "context"
+	Comportment = "comportment"
+
+	MaxScore = 100 // each dimension is bounded to [0, MaxScore]
+)
+
+// RulesVersion is the version of DefaultRules
+const RulesVersion = 2
+
+// Rule gives points to one dimension of the privacy index, given message's features.
+type Rule struct {
+	Name      string
+	Dimension string // Technic, Context or Comportment
+	Score     func(f *MessageFeatures) int
+}
+
+// Engine scores messages with a set of rules
+type Engine struct {
+	Version    int
+	Rules      []Rule
+	AuthservID string // authserv-id of our MTA, the only Authentication-Results headers trusted
+}
+
+var tlsScores = map[string]int{
+	"TLSv1.3": 15,
+	"TLSv1.2": 10,
+	"TLSv1.1": 7,
+	"TLSv1":   7,
+	"TLS":     5,
+	"SSLv3":   2,
+}
+
+var authScores = map[string]int{
+	"pass": 10,
+	"fail": -10,
+}
+
+// DefaultRules are the rules used by the email broker.
+var DefaultRules = []Rule{
+	{"transport_tls", Technic, func(f *MessageFeatures) int { return tlsScores[f.TLSVersion] }},
+	{"transport_dkim", Technic, func(f *MessageFeatures) int { return authScores[f.DKIM] }},
+	{"transport_spf", Technic, func(f *MessageFeatures) int { return authScores[f.SPF] / 2 }},
+	{"message_encrypted", Technic, func(f *MessageFeatures) int { return bonus(f.PGPEncrypted, 30) }},
+	{"message_signed", Technic, func(f *MessageFeatures) int { return bonus(f.PGPSigned, 10) }},
+	{"sender_known", Context, func(f *MessageFeatures) int { return bonus(f.KnownSender, 20) }},
+	{"sender_contact_comportment", Context, func(f *MessageFeatures) int { return f.ContactComportment / 2 }},
+	{"sender_received", Comportment, func(f *MessageFeatures) int { return 2 * min(f.Received, 10) }},
+	{"sender_sent", Comportment, func(f *MessageFeatures) int { return 3 * min(f.Sent, 10) }},
+	{"signed_by_known_sender", Comportment, func(f *MessageFeatures) int { return bonus(f.PGPSigned && f.KnownSender, 20) }},
+}
+
+// DefaultEngine scores with DefaultRules
+var DefaultEngine = NewEngine(RulesVersion, DefaultRules)
+
+func NewEngine(version int, rules []Rule) *Engine {
+	return &Engine{
+		Version: version,
+		Rules:   rules,
+	}
+}
+
+// Compute returns the privacy index given by engine's rules to features.
+func (e *Engine) Compute(f *MessageFeatures) *PrivacyIndex {
+	scores := map[string]int{}
+	for _, rule := range e.Rules {
+		scores[rule.Dimension] += rule.Score(f)
+	}
+	return &PrivacyIndex{
+		Comportment: bounded(scores[Comportment]),
+		Context:     bounded(scores[Context]),
+		DateUpdate:  time.Now(),
+		Technic:     bounded(scores[Technic]),
+		Version:     e.Version,
+	}
+}
+
+// IsOutdated tells if pi has not been computed with engine's current rules
+func (e *Engine) IsOutdated(pi *PrivacyIndex) bool {
+	return pi == nil || pi.Version < e.Version || pi.DateUpdate.IsZero()
+}
+
+func bonus(condition bool, points int) int {
+	if condition {
+		return points
+	}
+	return 0
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func bounded(score int) int {
+	switch {
+	case score < 0:
+		return 0
+	case score > MaxScore:
+		return MaxScore
+	}
+	return score
+}
@@ -0,0 +1,113 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package pi
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/pgp"
+	"github.com/satori/go.uuid"
+	"strings"
+	"testing"
+)
+
+const signedEmail = "Received: from mx.example.net (mx.example.net [192.0.2.1])\r\n" +
+	"\t(using TLSv1.2 with cipher ECDHE-RSA-AES256-GCM-SHA384 (256/256 bits))\r\n" +
+	"\tby mx.caliopen.local (Postfix) with ESMTPS id 3F2A1\r\n" +
+	"Received: from laptop (unknown [198.51.100.7]) by mx.example.net with ESMTP\r\n" +
+	"Authentication-Results: mx.example.net; dkim=fail; spf=fail\r\n" +
+	"Authentication-Results: mx.caliopen.local; dkim=pass header.d=example.net; spf=softfail smtp.mailfrom=example.net\r\n" +
+	"From: Alice <alice@example.net>\r\n" +
+	"To: bob@caliopen.local\r\n" +
+	"Subject: signed\r\n" +
+	"Content-Type: multipart/signed; micalg=pgp-sha256; protocol=\"application/pgp-signature\"; boundary=\"b1\"\r\n" +
+	"\r\n" +
+	"--b1\r\n\r\nhello\r\n--b1--\r\n"
+
+func TestExtractEmailFeatures(t *testing.T) {
+	f, err := ExtractEmailFeatures(signedEmail, "mx.caliopen.local")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// signature is only known once broker verified it
+	if f.TLSVersion != "TLSv1.2" || f.DKIM != "pass" || f.SPF != "softfail" || f.PGPSigned || f.PGPEncrypted {
+		t.Errorf("unexpected features %+v", f)
+	}
+
+	// results of other servers may have been forged by sender
+	forged := strings.Replace(signedEmail, "Authentication-Results: mx.caliopen.local; dkim=pass header.d=example.net; spf=softfail smtp.mailfrom=example.net\r\n", "", 1)
+	for _, authservID := range []string{"mx.caliopen.local", ""} {
+		f, err = ExtractEmailFeatures(forged, authservID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.DKIM != "" || f.SPF != "" {
+			t.Errorf("authserv-id %q : expected forged results to be ignored, got %+v", authservID, f)
+		}
+	}
+
+	f, err = ExtractEmailFeatures("Received: from a by b with SMTP\r\nSubject: clear\r\n\r\n-----BEGIN PGP MESSAGE-----\r\n", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if f.TLSVersion != "" || !f.PGPEncrypted {
+		t.Errorf("unexpected features %+v", f)
+	}
+
+	for received, version := range map[string]string{
+		"by mx (Postfix) with ESMTPS id 1 (version=TLS1_3 cipher=TLS_AES_256_GCM_SHA384)": "TLSv1.3",
+		"by mx with ESMTPS id 1 (TLSv1:AES256-SHA:256)":                                   "TLSv1",
+		"by mx with ESMTPS id 1":                                                          "TLS",
+		"by mx with LMTP id 1":                                                            "",
+	} {
+		if v := receivedTLS(received); v != version {
+			t.Errorf("receivedTLS(%q) = %q, expected %q", received, v, version)
+		}
+	}
+}
+
+func TestCompute(t *testing.T) {
+	engine := NewEngine(2, []Rule{
+		{"a", Technic, func(f *MessageFeatures) int { return 80 }},
+		{"b", Technic, func(f *MessageFeatures) int { return 40 }},
+		{"c", Context, func(f *MessageFeatures) int { return -5 }},
+		{"d", Comportment, func(f *MessageFeatures) int { return f.Received }},
+	})
+	pi := engine.Compute(&MessageFeatures{Received: 3})
+	if pi.Technic != MaxScore || pi.Context != 0 || pi.Comportment != 3 || pi.Version != 2 {
+		t.Errorf("unexpected privacy index %+v", pi)
+	}
+	if engine.IsOutdated(pi) {
+		t.Error("privacy index computed by engine should be up to date")
+	}
+	if !engine.IsOutdated(nil) || !engine.IsOutdated(&PrivacyIndex{Version: 1}) {
+		t.Error("missing or older privacy indexes should be outdated")
+	}
+}
+
+func TestExtractMessageFeaturesSignature(t *testing.T) {
+	store := memory.NewMemoryBackend()
+	for status, signed := range map[string]bool{
+		pgp.SignatureValid:      true,
+		pgp.SignatureInvalid:    false,
+		pgp.SignatureUnknownKey: false,
+		"":                      false,
+	} {
+		msg := &Message{
+			User_id:          UUID(uuid.NewV4()),
+			Privacy_features: &PrivacyFeatures{"message_signed": "true", "message_signature_status": status},
+		}
+		f, err := ExtractMessageFeatures(store, msg, signedEmail, "mx.caliopen.local")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if f.PGPSigned != signed {
+			t.Errorf("signature status %q : expected signed to be %v", status, signed)
+		}
+		if _, ok := f.PrivacyFeatures()["message_signed"]; ok {
+			t.Error("message_signed feature set by broker should be left as is")
+		}
+	}
+}
@@ -0,0 +1,129 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package pi
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"io/ioutil"
+	"mime"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// MessageFeatures are the privacy related facts known about a received message, scoring rules' inputs.
+type MessageFeatures struct {
+	TLSVersion   string // protocol used on the receiving hop : TLSv1.3, TLSv1.2, TLSv1.1, TLSv1, SSLv3, TLS if version is unknown, empty if received in clear
+	DKIM         string // DKIM verification result of the receiving MTA : pass, fail, none… empty if unknown
+	SPF          string // SPF verification result of the receiving MTA
+	PGPEncrypted bool
+	PGPSigned    bool // signature has been verified by broker against sender's keys, see pgp.Inspect
+
+	KnownSender        bool // sender's address belongs to one of user's contacts
+	ContactComportment int  // lowest comportment PI of sender's contacts
+	Received           int  // messages previously received from sender
+	Sent               int  // messages previously sent to sender by user
+}
+
+var (
+	tlsVersion = regexp.MustCompile(`(?i)\b(TLS|SSL)v?([1-3])(?:[._]([0-3]))?\b`)
+	tlsWith    = regexp.MustCompile(`(?i)\bwith\s+(?:E?SMTPS|LMTPS|UTF8SMTPS)A?\b`)
+	authResult = regexp.MustCompile(`(?i)\b(dkim|spf)\s*=\s*([a-z]+)`)
+)
+
+// ExtractEmailFeatures reads message's features from raw email's headers and body :
+// transport security as reported by the receiving MTA and PGP encryption of the content.
+// Authentication-Results headers are only read if they have been added by authservID, our MTA :
+// others may have been forged by the sender.
+func ExtractEmailFeatures(raw, authservID string) (*MessageFeatures, error) {
+	parsed, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	f := &MessageFeatures{}
+
+	// topmost headers are the ones added by our MTA, the only ones we trust
+	if received := parsed.Header["Received"]; len(received) > 0 {
+		f.TLSVersion = receivedTLS(received[0])
+	}
+	if results := authenticationResults(parsed.Header["Authentication-Results"], authservID); results != "" {
+		for _, match := range authResult.FindAllStringSubmatch(results, -1) {
+			switch strings.ToLower(match[1]) {
+			case "dkim":
+				if f.DKIM == "" || f.DKIM == "none" {
+					f.DKIM = strings.ToLower(match[2])
+				}
+			case "spf":
+				f.SPF = strings.ToLower(match[2])
+			}
+		}
+	}
+	if f.SPF == "" {
+		if spf := strings.Fields(parsed.Header.Get("Received-SPF")); len(spf) > 0 {
+			f.SPF = strings.ToLower(spf[0])
+		}
+	}
+
+	mediaType, params, _ := mime.ParseMediaType(parsed.Header.Get("Content-Type"))
+	switch {
+	case mediaType == "multipart/encrypted" && strings.EqualFold(params["protocol"], "application/pgp-encrypted"):
+		f.PGPEncrypted = true
+	case mediaType == "" || strings.HasPrefix(mediaType, "text/"):
+		// inline PGP
+		body, _ := ioutil.ReadAll(parsed.Body)
+		f.PGPEncrypted = strings.Contains(string(body), "-----BEGIN PGP MESSAGE-----")
+	}
+	return f, nil
+}
+
+// authenticationResults returns the results of the first header added by authservID, if any.
+// Header's value is `authserv-id [version]; method=result…` (RFC 8601).
+func authenticationResults(headers []string, authservID string) string {
+	if authservID == "" {
+		return ""
+	}
+	for _, header := range headers {
+		i := strings.Index(header, ";")
+		if i < 0 {
+			continue
+		}
+		if id := strings.Fields(header[:i]); len(id) > 0 && strings.EqualFold(id[0], authservID) {
+			return header[i+1:]
+		}
+	}
+	return ""
+}
+
+// receivedTLS returns the TLS protocol reported in a Received header, if any
+func receivedTLS(received string) string {
+	if match := tlsVersion.FindStringSubmatch(received); match != nil {
+		if strings.EqualFold(match[1], "SSL") {
+			return "SSLv" + match[2]
+		}
+		if match[2] == "1" && match[3] != "" && match[3] != "0" {
+			return "TLSv1." + match[3]
+		}
+		return "TLSv" + match[2]
+	}
+	if tlsWith.MatchString(received) {
+		return "TLS"
+	}
+	return ""
+}
+
+// PrivacyFeatures returns features as saved along message
+func (f *MessageFeatures) PrivacyFeatures() PrivacyFeatures {
+	return PrivacyFeatures{
+		"ingress_socket_version": f.TLSVersion,
+		"transport_dkim":         f.DKIM,
+		"transport_spf":          f.SPF,
+		"transport_signed":       strconv.FormatBool(f.DKIM == "pass"),
+		"message_encrypted":      strconv.FormatBool(f.PGPEncrypted),
+		"sender_known":           strconv.FormatBool(f.KnownSender),
+		"sender_received":        strconv.Itoa(f.Received),
+		"sender_sent":            strconv.Itoa(f.Sent),
+	}
+}
@@ -0,0 +1,178 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package pi
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/outbox"
+	log "github.com/Sirupsen/logrus"
+	"net/mail"
+	"strings"
+)
+
+// importance levels are within [MinImportanceLevel, MaxImportanceLevel], as X-Caliopen-IL ranges
+const (
+	MinImportanceLevel = -10
+	MaxImportanceLevel = 10
+)
+
+// ImportanceFeatures are the signals used to compute the importance level of a received message
+type ImportanceFeatures struct {
+	KnownSender bool          // sender belongs to one of user's contacts
+	Addressed   string        // how user is addressed : ParticipantTo, ParticipantCC, or empty (blind copy, list…)
+	MailingList bool          // message has a List-Id header
+	Bulk        bool          // message is flagged as bulk or automatic : Precedence, Auto-Submitted, List-Unsubscribe headers
+	ThreadReply bool          // message belongs to a discussion in which user wrote
+	TagsLevel   int32         // most significant importance level of message's tags
+	PI          *PrivacyIndex // message's privacy index
+}
+
+// ImportanceLevel computes the importance level given by features
+func ImportanceLevel(f *ImportanceFeatures) int32 {
+	var level int32
+	if f.KnownSender {
+		level += 3
+	}
+	switch f.Addressed {
+	case ParticipantTo:
+		level += 2
+	case ParticipantCC:
+		level += 1
+	}
+	if f.MailingList {
+		level -= 2
+	}
+	if f.Bulk {
+		level -= 3
+	}
+	if f.ThreadReply {
+		level += 3
+	}
+	level += f.TagsLevel / 2
+	if f.PI != nil {
+		level += int32(f.PI.Comportment/25 + f.PI.Context/50)
+	}
+	switch {
+	case level < MinImportanceLevel:
+		return MinImportanceLevel
+	case level > MaxImportanceLevel:
+		return MaxImportanceLevel
+	}
+	return level
+}
+
+// ExtractImportanceFeatures reads message's importance signals from its raw email, user's identities, contacts and tags,
+// and from the discussion it belongs to. Message's privacy index must have been computed before.
+func ExtractImportanceFeatures(store backends.ImportanceStore, index backends.ImportanceIndex, msg *Message, raw string) (*ImportanceFeatures, error) {
+	parsed, err := mail.ReadMessage(strings.NewReader(raw))
+	if err != nil {
+		return nil, err
+	}
+	userId := msg.User_id.String()
+	f := &ImportanceFeatures{
+		MailingList: parsed.Header.Get("List-Id") != "",
+		PI:          msg.PrivacyIndex,
+	}
+	switch strings.ToLower(strings.TrimSpace(parsed.Header.Get("Precedence"))) {
+	case "bulk", "junk", "list":
+		f.Bulk = true
+	}
+	if auto := strings.ToLower(strings.TrimSpace(parsed.Header.Get("Auto-Submitted"))); auto != "" && auto != "no" {
+		f.Bulk = true
+	}
+	if parsed.Header.Get("List-Unsubscribe") != "" {
+		f.Bulk = true
+	}
+
+	if sender := messageSender(msg); sender != nil {
+		f.KnownSender = len(senderContacts(store, msg, sender)) > 0
+	}
+
+	identities, err := store.GetLocalsIdentities(userId)
+	if err != nil {
+		log.WithError(err).Warnf("[Importance] failed to retrieve local identities of user %s", userId)
+	}
+	for _, participant := range msg.Participants {
+		for _, identity := range identities {
+			if !strings.EqualFold(participant.Address, identity.Identifier) {
+				continue
+			}
+			switch {
+			case strings.EqualFold(participant.Type, ParticipantTo):
+				f.Addressed = ParticipantTo
+			case strings.EqualFold(participant.Type, ParticipantCC) && f.Addressed == "":
+				f.Addressed = ParticipantCC
+			}
+		}
+	}
+
+	if len(msg.Tags) > 0 {
+		tags, err := store.RetrieveUserTags(userId)
+		if err != nil {
+			log.WithError(err).Warnf("[Importance] failed to retrieve tags of user %s", userId)
+		}
+		for _, tag := range tags {
+			for _, name := range msg.Tags {
+				if tag.Name == name && abs(tag.Importance_level) > abs(f.TagsLevel) {
+					f.TagsLevel = tag.Importance_level
+				}
+			}
+		}
+	}
+
+	if msg.Discussion_id.String() != EmptyUUID.String() {
+		// messages written by user in the same discussion
+		result, err := index.Search(IndexSearch{
+			User_id: msg.User_id,
+			DocType: MessageIndexType,
+			ILrange: [2]int8{MinImportanceLevel, MaxImportanceLevel},
+			Limit:   1,
+			Filters: map[string][]string{
+				"discussion_id": {msg.Discussion_id.String()},
+				"is_received":   {"false"},
+				"is_draft":      {"false"},
+			},
+		})
+		if err != nil {
+			log.WithError(err).Warnf("[Importance] failed to search discussion %s of user %s", msg.Discussion_id.String(), userId)
+		} else {
+			f.ThreadReply = result.MessagesHits.Total > 0
+		}
+	}
+	return f, nil
+}
+
+// ScoreImportance computes importance level of a received message, then saves it on message and on its discussion.
+// Message's index is updated through index outbox.
+func ScoreImportance(store backends.ImportanceStore, index backends.ImportanceIndex, msg *Message, raw string) error {
+	f, err := ExtractImportanceFeatures(store, index, msg, raw)
+	if err != nil {
+		return err
+	}
+	msg.Importance_level = ImportanceLevel(f)
+
+	mutation, err := outbox.Record(store, msg.User_id, MessageType, msg.Message_id, IndexUpdate, "Importance_level")
+	if err != nil {
+		log.WithError(err).Warnf("[Importance] failed to record index update of message %s", msg.Message_id.String())
+	}
+	err = store.UpdateMessage(msg, map[string]interface{}{"Importance_level": msg.Importance_level})
+	if err != nil {
+		return err
+	}
+	outbox.ApplyOrDefer(store, index, mutation)
+
+	if msg.Discussion_id.String() != EmptyUUID.String() {
+		return store.RaiseDiscussionImportanceLevel(msg.User_id.String(), msg.Discussion_id.String(), msg.Importance_level)
+	}
+	return nil
+}
+
+func abs(level int32) int32 {
+	if level < 0 {
+		return -level
+	}
+	return level
+}
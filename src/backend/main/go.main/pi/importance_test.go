@@ -0,0 +1,76 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package pi
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
+	"github.com/satori/go.uuid"
+	"testing"
+)
+
+func TestScoreImportance(t *testing.T) {
+	store, index := memory.NewMemoryBackend(), memory.NewMemoryIndex()
+	userId := UUID(uuid.FromStringOrNil("8a8fdb3d-cd41-4988-a0a5-80ea2df2633e"))
+	discussionId := UUID(uuid.FromStringOrNil("5b0b4a9b-1e0e-4f5e-9a59-1d1f0b0b3c21"))
+	store.CreateUser(&User{UserId: userId, Name: "bob"})
+	store.CreateLocalIdentity(&LocalIdentity{User_id: userId, Identifier: "bob@caliopen.local", Type: "local"})
+	store.CreateTag(&Tag{User_id: userId, Name: "work", Importance_level: 4})
+
+	// user already wrote in discussion
+	sent := &Message{
+		User_id:       userId,
+		Message_id:    UUID(uuid.NewV4()),
+		Discussion_id: discussionId,
+	}
+	index.CreateMessage(sent)
+
+	received := &Message{
+		User_id:       userId,
+		Message_id:    UUID(uuid.NewV4()),
+		Discussion_id: discussionId,
+		Is_received:   true,
+		Participants: []Participant{
+			{Type: ParticipantFrom, Address: "alice@example.net"},
+			{Type: ParticipantCC, Address: "Bob@caliopen.local"},
+		},
+		PrivacyIndex: &PrivacyIndex{Comportment: 50, Context: 20},
+		Tags:         []string{"work"},
+	}
+	store.CreateMessage(received)
+	index.CreateMessage(received)
+	raw := "From: alice@example.net\r\nCc: Bob@caliopen.local\r\nSubject: hi\r\n\r\nhello\r\n"
+
+	if err := ScoreImportance(store, index, received, raw); err != nil {
+		t.Fatal(err)
+	}
+	// cc : 1, thread reply : 3, tag : 2, comportment : 2
+	if received.Importance_level != 8 {
+		t.Errorf("expected importance level 8, got %d", received.Importance_level)
+	}
+	stored, _ := store.RetrieveMessage(userId.String(), received.Message_id.String())
+	indexed, _ := index.IndexedMessages(userId.String(), []string{received.Message_id.String()})
+	if stored.Importance_level != 8 || indexed[received.Message_id.String()].Importance_level != 8 {
+		t.Error("importance level should be saved into store and index")
+	}
+	discussion, err := store.RetrieveDiscussion(userId.String(), discussionId.String())
+	if err != nil || discussion.Importance_level != 8 {
+		t.Errorf("expected discussion importance level 8, got %+v (%v)", discussion, err)
+	}
+
+	// a less important message leaves discussion's level as is
+	raw = "From: news@example.net\r\nList-Id: <news.example.net>\r\nPrecedence: bulk\r\n\r\nhello\r\n"
+	bulk := &Message{User_id: userId, Message_id: UUID(uuid.NewV4()), Discussion_id: discussionId, Is_received: true}
+	store.CreateMessage(bulk)
+	if err := ScoreImportance(store, index, bulk, raw); err != nil {
+		t.Fatal(err)
+	}
+	if bulk.Importance_level != -2 {
+		t.Errorf("expected importance level -2, got %d", bulk.Importance_level)
+	}
+	if discussion, _ = store.RetrieveDiscussion(userId.String(), discussionId.String()); discussion.Importance_level != 8 {
+		t.Errorf("discussion importance level should not decrease, got %d", discussion.Importance_level)
+	}
+}
@@ -0,0 +1,145 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package pi
+
+import (
+	"errors"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/outbox"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/pgp"
+	log "github.com/Sirupsen/logrus"
+	"strings"
+)
+
+// ExtractMessageFeatures extracts features of a received message from its raw email,
+// then adds what user knows about its sender : contacts and past interactions.
+// Signature only counts once broker verified it, message_signed feature is left as set by broker.
+func ExtractMessageFeatures(store backends.PIStore, msg *Message, raw, authservID string) (*MessageFeatures, error) {
+	f, err := ExtractEmailFeatures(raw, authservID)
+	if err != nil {
+		return nil, err
+	}
+	if msg.Privacy_features != nil {
+		f.PGPSigned = (*msg.Privacy_features)["message_signature_status"] == pgp.SignatureValid
+	}
+	sender := messageSender(msg)
+	if sender == nil {
+		return f, nil
+	}
+	userId := msg.User_id.String()
+
+	for _, id := range senderContacts(store, msg, sender) {
+		contact, err := store.RetrieveContact(userId, id)
+		if err != nil || contact == nil {
+			continue
+		}
+		comportment := 0
+		if contact.PrivacyIndex != nil {
+			comportment = contact.PrivacyIndex.Comportment
+		}
+		if !f.KnownSender || comportment < f.ContactComportment {
+			f.ContactComportment = comportment
+		}
+		f.KnownSender = true
+	}
+
+	interactions, err := store.RetrieveInteractions(userId, []string{sender.Address})
+	if err != nil {
+		log.WithError(err).Warnf("[PI] failed to retrieve interactions of user %s with %s", userId, sender.Address)
+	}
+	if interaction, ok := interactions[NormalizeInteractionAddress(sender.Address)]; ok {
+		f.Received, f.Sent = interaction.Received, interaction.Sent
+	}
+	return f, nil
+}
+
+// messageSender returns message's From participant, nil if message has no sender address
+func messageSender(msg *Message) *Participant {
+	for i, participant := range msg.Participants {
+		if strings.EqualFold(participant.Type, ParticipantFrom) && participant.Address != "" {
+			return &msg.Participants[i]
+		}
+	}
+	return nil
+}
+
+// senderContacts returns ids of user's contacts that sender belongs to
+func senderContacts(store backends.PIStore, msg *Message, sender *Participant) (contactIds []string) {
+	for _, id := range sender.Contact_ids {
+		contactIds = append(contactIds, id.String())
+	}
+	if len(contactIds) == 0 {
+		contactIds, _ = store.LookupContactsByIdentifier(msg.User_id.String(), sender.Address)
+	}
+	return
+}
+
+// ScoreMessage computes privacy index of a received message with engine's rules,
+// then saves it along with message's privacy features. Index is updated through index outbox.
+func (e *Engine) ScoreMessage(store backends.PIStore, index backends.OutboxIndex, msg *Message, raw string) error {
+	f, err := ExtractMessageFeatures(store, msg, raw, e.AuthservID)
+	if err != nil {
+		return err
+	}
+	features := PrivacyFeatures{}
+	if msg.Privacy_features != nil {
+		// keep features set by other components
+		for k, v := range *msg.Privacy_features {
+			features[k] = v
+		}
+	}
+	for k, v := range f.PrivacyFeatures() {
+		features[k] = v
+	}
+	msg.Privacy_features = &features
+	msg.PrivacyIndex = e.Compute(f)
+
+	mutation, err := outbox.Record(store, msg.User_id, MessageType, msg.Message_id, IndexUpdate, "PrivacyIndex", "Privacy_features")
+	if err != nil {
+		log.WithError(err).Warnf("[PI] failed to record index update of message %s", msg.Message_id.String())
+	}
+	err = store.UpdateMessage(msg, map[string]interface{}{
+		"PrivacyIndex":     msg.PrivacyIndex,
+		"Privacy_features": msg.Privacy_features,
+	})
+	if err != nil {
+		return err
+	}
+	outbox.ApplyOrDefer(store, index, mutation)
+	return nil
+}
+
+// Rescore scores again user's received messages whose privacy index has been computed with older rules,
+// or all of them if force is set. It returns the number of messages scored.
+// Sender's interactions are the current ones, they may include messages received after the scored one.
+func (e *Engine) Rescore(store backends.PIRescoreStore, index backends.OutboxIndex, userId string, force bool) (count int, err error) {
+	messages, err := store.RetrieveAllMessages(userId)
+	if err != nil {
+		return 0, err
+	}
+	// channel is always drained, to release store's iterator
+	for msg := range messages {
+		if !msg.Is_received || msg.Is_draft || (!force && !e.IsOutdated(msg.PrivacyIndex)) {
+			continue
+		}
+		if msg.Raw_msg_id.String() == EmptyUUID.String() {
+			continue
+		}
+		raw, e1 := store.GetRawMessage(msg.Raw_msg_id.String())
+		if e1 != nil {
+			log.WithError(e1).Warnf("[PI] failed to retrieve raw email of message %s", msg.Message_id.String())
+			err = errors.New("[PI] some messages could not be scored")
+			continue
+		}
+		if e1 = e.ScoreMessage(store, index, msg, raw.Raw_data); e1 != nil {
+			log.WithError(e1).Warnf("[PI] failed to score message %s", msg.Message_id.String())
+			err = errors.New("[PI] some messages could not be scored")
+			continue
+		}
+		count++
+	}
+	return
+}
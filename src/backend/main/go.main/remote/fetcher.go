@@ -0,0 +1,232 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+// Package remote fetches remote resources of messages (images…) on behalf of users,
+// thus remote servers never see users' addresses, cookies or referrers.
+package remote
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultMaxSize = 5 << 20 // bytes
+	DefaultTimeout = 10 * time.Second
+	maxRedirects   = 3
+	userAgent      = "Mozilla/5.0 (compatible; Caliopen remote content proxy)"
+)
+
+var (
+	ErrInvalidURL       = errors.New("[remote] only absolute http(s) urls can be fetched")
+	ErrForbiddenAddress = errors.New("[remote] remote host resolves to a private or reserved address")
+	ErrTooLarge         = errors.New("[remote] remote content exceeds max size")
+	ErrUnsupportedType  = errors.New("[remote] remote content is not a supported image")
+)
+
+// types of images that are proxied, as sniffed by http.DetectContentType.
+// svg is not, because it may embed scripts and links.
+var imageTypes = map[string]bool{
+	"image/png":                true,
+	"image/jpeg":               true,
+	"image/gif":                true,
+	"image/webp":               true,
+	"image/bmp":                true,
+	"image/x-icon":             true,
+	"image/vnd.microsoft.icon": true,
+}
+
+// ranges of addresses that remote content must never be fetched from :
+// loopback, private networks, link-local, shared and reserved ranges, multicast,
+// and IPv6 ranges that embed an IPv4 address (NAT64, 6to4, Teredo), which may be routed to a private one.
+var forbiddenNets = parseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"2001::/32",
+	"2002::/16",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+// Fetcher gets remote resources without any credential, within size and time limits.
+// Hosts are resolved and checked on each connection, including redirects,
+// thus remote content can't be used to reach internal services.
+type Fetcher struct {
+	MaxSize int64
+	client  *http.Client
+	allowed func(ip net.IP) bool // whether connections to ip are allowed
+}
+
+// NewFetcher returns a Fetcher, zero values of maxSize and timeout are replaced by defaults.
+func NewFetcher(maxSize int64, timeout time.Duration) *Fetcher {
+	if maxSize <= 0 {
+		maxSize = DefaultMaxSize
+	}
+	if timeout <= 0 {
+		timeout = DefaultTimeout
+	}
+	f := &Fetcher{
+		MaxSize: maxSize,
+		allowed: PublicAddress,
+	}
+	f.client = &http.Client{
+		Transport: &http.Transport{
+			Proxy:                 nil, // never use environment's proxy, it may reach internal hosts
+			DialContext:           f.dial,
+			TLSHandshakeTimeout:   timeout,
+			ResponseHeaderTimeout: timeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       90 * time.Second,
+		},
+		CheckRedirect: checkRedirect,
+		Timeout:       timeout,
+		// no cookie jar : cookies set by remote servers are dropped
+	}
+	return f
+}
+
+// Fetch gets the image at remote url. Protocol-relative urls are fetched with https.
+// No cookie, referrer or authentication is sent, response's headers other than status and length are ignored.
+// contentType is sniffed from content, never taken from remote server.
+func (f *Fetcher) Fetch(remote string) (content []byte, contentType string, err error) {
+	remote = strings.TrimSpace(remote)
+	if strings.HasPrefix(remote, "//") {
+		remote = "https:" + remote
+	}
+	u, err := url.Parse(remote)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.User != nil {
+		return nil, "", ErrInvalidURL
+	}
+	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, "", ErrInvalidURL
+	}
+	req.Header.Set("User-Agent", userAgent)
+	req.Header.Set("Accept", "image/*")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok {
+			err = urlErr.Err
+		}
+		return nil, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", errors.New("[remote] remote server responded " + resp.Status)
+	}
+	if resp.ContentLength > f.MaxSize {
+		return nil, "", ErrTooLarge
+	}
+	content, err = ioutil.ReadAll(io.LimitReader(resp.Body, f.MaxSize+1))
+	if err != nil {
+		return nil, "", err
+	}
+	if int64(len(content)) > f.MaxSize {
+		return nil, "", ErrTooLarge
+	}
+	contentType, ok := ImageType(content)
+	if !ok {
+		return nil, "", ErrUnsupportedType
+	}
+	return content, contentType, nil
+}
+
+// ImageType returns the sniffed content type of content, ok is false if it is not a supported image.
+func ImageType(content []byte) (contentType string, ok bool) {
+	contentType = http.DetectContentType(content)
+	return contentType, imageTypes[contentType]
+}
+
+// PublicAddress tells if ip is outside of private and reserved ranges.
+func PublicAddress(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, network := range forbiddenNets {
+		if network.Contains(ip) {
+			return false
+		}
+	}
+	return true
+}
+
+// dial resolves host, checks all its addresses, then connects to the first one that answers.
+// Checked addresses are dialed directly, thus host can't resolve to another address in between.
+func (f *Fetcher) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	host, port, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("[remote] no address found for " + host)
+	}
+	for _, addr := range addrs {
+		if !f.allowed(addr.IP) {
+			return nil, ErrForbiddenAddress
+		}
+	}
+	dialer := &net.Dialer{KeepAlive: 30 * time.Second}
+	for _, addr := range addrs {
+		var conn net.Conn
+		conn, err = dialer.DialContext(ctx, network, net.JoinHostPort(addr.IP.String(), port))
+		if err == nil {
+			return conn, nil
+		}
+	}
+	return nil, err
+}
+
+// checkRedirect limits redirects to http(s) urls and prevents referrer and cookies from being forwarded.
+func checkRedirect(req *http.Request, via []*http.Request) error {
+	if len(via) >= maxRedirects {
+		return errors.New("[remote] too many redirects")
+	}
+	if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+		return ErrInvalidURL
+	}
+	req.Header.Del("Referer")
+	req.Header.Del("Cookie")
+	req.Header.Del("Authorization")
+	return nil
+}
+
+func parseCIDRs(cidrs ...string) []*net.IPNet {
+	nets := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		nets[i] = network
+	}
+	return nets
+}
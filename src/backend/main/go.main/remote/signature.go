@@ -0,0 +1,69 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package remote
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+)
+
+const (
+	MinSigningKeyLength = 16
+	placeholderKey      = "CHANGE_ME_IN_PROD" // value of signing_key in configuration's samples
+)
+
+var (
+	ErrNoSigningKey   = errors.New("[remote] no signing key")
+	ErrWeakSigningKey = errors.New("[remote] signing key is configuration's placeholder or is too short")
+)
+
+// Signer signs the remote urls found in a message when it is displayed,
+// thus proxy only fetches urls that really appear in user's messages.
+type Signer struct {
+	key []byte
+}
+
+// CheckSigningKey tells why key can't be used to sign urls : it is empty, too short or left to its placeholder.
+func CheckSigningKey(key string) error {
+	if key == "" {
+		return ErrNoSigningKey
+	}
+	if key == placeholderKey || len(key) < MinSigningKeyLength {
+		return ErrWeakSigningKey
+	}
+	return nil
+}
+
+func NewSigner(key string) *Signer {
+	return &Signer{[]byte(key)}
+}
+
+// Sign returns the signature of remote url for user's message
+func (s *Signer) Sign(user_id, message_id, remote string) string {
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(user_id + "\n" + message_id + "\n" + remote))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Check tells if sig is the signature of remote url for user's message
+func (s *Signer) Check(user_id, message_id, remote, sig string) bool {
+	expected, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha256.New, s.key)
+	mac.Write([]byte(user_id + "\n" + message_id + "\n" + remote))
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+// CacheKey returns the name of remote url's content in cache.
+// Content is shared by all users, because key tells nothing about who fetched it.
+func CacheKey(remote string) string {
+	sum := sha256.Sum256([]byte(remote))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,69 @@
+# -*- coding: utf-8 -*-
+"""
+Envelope encryption helpers.
+
+Contents (raw messages, attachments) are sealed with their own content key,
+wrapped with the data key of their owner, itself wrapped by a master key.
+Data are written by go backends, see main/go.backends/store/kms package.
+Sealed data are a 12 bytes nonce followed by AES-256-GCM ciphertext.
+"""
+from __future__ import absolute_import, print_function, unicode_literals
+
+import base64
+import json
+import logging
+
+from cryptography.hazmat.primitives.ciphers.aead import AESGCM
+
+from caliopen_storage.config import Configuration
+
+log = logging.getLogger(__name__)
+
+NONCE_SIZE = 12
+
+
+def open_sealed(key, sealed):
+    """Decrypt and authenticate data sealed with key."""
+    return AESGCM(key).decrypt(sealed[:NONCE_SIZE], sealed[NONCE_SIZE:], None)
+
+
+class LocalKMS(object):
+    """Key management service with master keys from a local keyring file."""
+
+    def __init__(self, keyring_path):
+        with open(keyring_path, 'rb') as f:
+            keyring = json.load(f)
+        self.current = keyring['current']
+        self.keys = dict((key_id, base64.b64decode(key))
+                         for key_id, key in keyring['keys'].items())
+
+    def unwrap_key(self, wrapped, master_key_id):
+        """Decrypt a data key wrapped by master key master_key_id."""
+        if master_key_id not in self.keys:
+            raise KeyError('Unknown master key {}'.format(master_key_id))
+        return open_sealed(self.keys[master_key_id], wrapped)
+
+
+_kms = None
+
+
+def get_kms():
+    """Return the configured key management service."""
+    global _kms
+    if _kms is None:
+        conf = Configuration('global').get('encryption') or {}
+        if conf.get('kms') != 'local':
+            raise Exception('Encryption is not configured')
+        _kms = LocalKMS(conf['keyring_path'])
+    return _kms
+
+
+def open_content(key_owner, key_version, wrapped_key, sealed):
+    """Decrypt a content sealed with a key wrapped by owner's data key."""
+    from caliopen_main.user.core.user import UserDataKey
+
+    data_key = UserDataKey.get_by_user_id(key_owner, key_version)
+    user_key = get_kms().unwrap_key(data_key.wrapped_key,
+                                    data_key.master_key_id)
+    content_key = open_sealed(user_key, wrapped_key)
+    return open_sealed(content_key, sealed)
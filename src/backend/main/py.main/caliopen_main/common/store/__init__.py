@@ -4,5 +4,6 @@ from __future__ import absolute_import, print_function, unicode_literals
 
 
 from .pubkey import PublicKey
+from .outbox import IndexOutbox
 
-__all__ = ['PublicKey']
+__all__ = ['PublicKey', 'IndexOutbox']
@@ -0,0 +1,22 @@
+# -*- coding: utf-8 -*-
+"""Caliopen outbox of index mutations."""
+from __future__ import absolute_import, print_function, unicode_literals
+
+from cassandra.cqlengine import columns
+
+from caliopen_storage.store import BaseModel
+
+
+class IndexOutbox(BaseModel):
+    """Index mutation recorded along with a store write, until applied."""
+
+    user_id = columns.UUID(primary_key=True)
+    mutation_id = columns.TimeUUID(primary_key=True)    # clustering key
+
+    resource_type = columns.Text()      # message or contact
+    resource_id = columns.UUID()
+    operation = columns.Text()          # create, update or delete
+    fields = columns.List(columns.Text())
+    attempts = columns.Integer()
+    last_error = columns.Text()
+    date_insert = columns.DateTime()
@@ -25,6 +25,8 @@ class PublicKey(BaseModel):
 
     key = columns.Text()
     fingerprint = columns.Text()
+    # how key has been learnt, Autocrypt state
+    privacy_features = columns.Map(columns.Text(), columns.Text())
 
     # JWT parameters
     kty = columns.Text()    # rsa / ec
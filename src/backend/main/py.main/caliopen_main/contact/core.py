@@ -10,6 +10,7 @@ import phonenumbers
 
 from .store import (Contact as ModelContact,
                     ContactLookup as ModelContactLookup,
+                    ParticipantInteraction as ModelParticipantInteraction,
                     Organization, Email, IM, PostalAddress,
                     Phone, SocialIdentity)
 from .store.contact_index import IndexedContact
@@ -29,6 +30,14 @@ class ContactLookup(BaseUserCore):
     _pkey_name = 'value'
 
 
+class ParticipantInteraction(BaseUserCore):
+
+    """Participant interactions core class."""
+
+    _model_class = ModelParticipantInteraction
+    _pkey_name = 'address'
+
+
 class BaseContactSubCore(BaseCore):
     """
     Base core object for contact related objects
@@ -2,10 +2,12 @@
 from __future__ import absolute_import, print_function, unicode_literals
 
 from .contact import Contact, IndexedContact, ContactLookup
+from .contact import ParticipantInteraction, ParticipantInteractionCount
 from .contact import Organization, PostalAddress
 from .contact import Email, IM, Phone, SocialIdentity
 
 
 __all__ = ['Contact', 'ContactLookup', 'IndexedContact',
+           'ParticipantInteraction', 'ParticipantInteractionCount',
            'Organization', 'PostalAddress',
            'Email', 'IM', 'Phone', 'SocialIdentity']
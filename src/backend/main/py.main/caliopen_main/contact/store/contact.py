@@ -136,3 +136,24 @@ class ContactLookup(BaseModel):
         primary_key=True)  # address or 'identifier' in identity
     type = columns.Text(primary_key=True)  # email, IM, etc.
     contact_ids = columns.List(columns.UUID())  # many contacts is allowed
+
+
+class ParticipantInteraction(BaseModel):
+    """Last messages exchanged by user with an address."""
+
+    user_id = columns.UUID(primary_key=True)
+    address = columns.Text(primary_key=True)  # lower-cased address
+    protocol = columns.Text()
+    sent = columns.Integer(default=0)  # counted before counters, not updated
+    received = columns.Integer(default=0)  # counted before counters, not updated
+    last_sent = columns.DateTime()
+    last_received = columns.DateTime()
+
+
+class ParticipantInteractionCount(BaseModel):
+    """Count messages exchanged by user with an address."""
+
+    user_id = columns.UUID(primary_key=True)
+    address = columns.Text(primary_key=True)  # lower-cased address
+    sent = columns.Counter()
+    received = columns.Counter()
@@ -26,6 +26,7 @@ class DeviceLocation(BaseModel):
     address = columns.Text(primary_key=True)    # IP address with CIDR
     type = columns.Text()                       # home/work/etc
     country = columns.Text()
+    asn = columns.Integer()                     # autonomous system number
 
 
 class Device(BaseModel):
@@ -56,3 +57,17 @@ class DeviceConnectionLog(BaseModel):
     ip_address = columns.Text(required=True)
     type = columns.Text()       # Connection type (login/logout)
     country = columns.Text()    # Geoip detected country
+    asn = columns.Integer()     # Geoip detected autonomous system number
+
+
+class DeviceAudit(BaseModel):
+    """Audit of actions done on user's devices, most recent first."""
+
+    user_id = columns.UUID(primary_key=True)
+    audit_id = columns.TimeUUID(primary_key=True,
+                                clustering_order='DESC')
+    action = columns.Text()         # revoke
+    device_id = columns.UUID()      # device the action has been done on
+    by_device_id = columns.UUID()   # device that requested the action
+    ip_address = columns.Text()
+    date_insert = columns.DateTime()
@@ -1,5 +1,7 @@
-from .raw import RawMessage, UserRawLookup
+from .raw import RawMessage, UserRawLookup, RawMessageHash, RawMessageRefs
+from .attachment import AttachmentBlob, AttachmentRefs, MessageRelease
 
 __all__ = [
-    'RawMessage', 'UserRawLookup'
+    'RawMessage', 'UserRawLookup', 'RawMessageHash', 'RawMessageRefs',
+    'AttachmentBlob', 'AttachmentRefs', 'MessageRelease'
 ]
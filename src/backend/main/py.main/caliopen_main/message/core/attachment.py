@@ -0,0 +1,30 @@
+# -*- coding: utf-8 -*-
+"""Caliopen core attachment classes."""
+from __future__ import absolute_import, print_function, unicode_literals
+
+from caliopen_storage.core import BaseCore, BaseUserCore
+
+from ..store import (AttachmentBlob as ModelAttachmentBlob,
+                     AttachmentRefs as ModelAttachmentRefs,
+                     MessageRelease as ModelMessageRelease)
+
+
+class AttachmentBlob(BaseCore):
+    """Attachment's file shared by attachments with the same content."""
+
+    _model_class = ModelAttachmentBlob
+    _pkey_name = 'hash'
+
+
+class AttachmentRefs(BaseCore):
+    """Attachment's file references counter."""
+
+    _model_class = ModelAttachmentRefs
+    _pkey_name = 'hash'
+
+
+class MessageRelease(BaseUserCore):
+    """Attachment's reference released by a message being deleted."""
+
+    _model_class = ModelMessageRelease
+    _pkey_name = 'message_id'
@@ -2,6 +2,7 @@
 """Caliopen core raw message class."""
 from __future__ import absolute_import, print_function, unicode_literals
 
+import os
 import uuid
 import logging
 
@@ -17,8 +18,11 @@ from caliopen_storage.exception import NotFound
 from caliopen_storage.config import Configuration
 
 from ..store import (RawMessage as ModelRaw,
-                     UserRawLookup as ModelUserRawLookup)
+                     UserRawLookup as ModelUserRawLookup,
+                     RawMessageHash as ModelRawMessageHash,
+                     RawMessageRefs as ModelRawMessageRefs)
 from caliopen_main.message.parsers.mail import MailMessage
+from caliopen_main.common.helpers.encryption import open_content
 
 log = logging.getLogger(__name__)
 
@@ -101,28 +105,65 @@ class RawMessage(BaseCore):
         if raw_msg.raw_data == "" and raw_msg.uri != "":
             # means raw message data have been stored in object store
             # need to retrieve raw_data from it
-            minioConf = Configuration("global").get("object_store")
-            minioClient = Minio(minioConf["endpoint"],
-                                access_key=minioConf["access_key"],
-                                secret_key=minioConf["secret_key"],
-                                secure=False,
-                                region=minioConf["location"])
-            try:
-                resp = minioClient.get_object(
-                    minioConf["buckets"]["raw_messages"],
-                    raw_msg_id)
-            except ResponseError as exc:
-                log.warn(exc)
-                return NotFound
-            # resp is a urllib3.response.HTTPResponse class
+            url = urlparse.urlsplit(raw_msg.uri)
+            if url.scheme == 'fs':
+                try:
+                    raw_msg.raw_data = cls._read_fs_object(url)
+                except IOError as exc:
+                    log.warn(exc)
+                    return NotFound
+            else:
+                minioConf = Configuration("global").get("object_store")
+                minioClient = Minio(minioConf["endpoint"],
+                                    access_key=minioConf["access_key"],
+                                    secret_key=minioConf["secret_key"],
+                                    secure=False,
+                                    region=minioConf["location"])
+                try:
+                    resp = minioClient.get_object(
+                        minioConf["buckets"]["raw_messages"],
+                        raw_msg_id)
+                except ResponseError as exc:
+                    log.warn(exc)
+                    return NotFound
+                # resp is a urllib3.response.HTTPResponse class
+                try:
+                    raw_msg.raw_data = resp.data
+                except Exception as exc:
+                    log.warn(exc)
+                    return NotFound
+
+        if raw_msg.wrapped_key:
             try:
-                raw_msg.raw_data = resp.data
+                raw_msg.raw_data = open_content(raw_msg.key_owner,
+                                                raw_msg.key_version,
+                                                raw_msg.wrapped_key,
+                                                raw_msg.raw_data)
             except Exception as exc:
-                log.warn(exc)
+                log.error('Decryption of raw message {} failed : {}'.
+                          format(raw_msg_id, exc))
                 return NotFound
 
         return raw_msg
 
+    @classmethod
+    def _read_fs_object(cls, url):
+        """
+        Read an object written by the filesystem objects store.
+
+        Objects are hard links named after their name,
+        into a directory sharded by name's first two characters.
+        """
+        conf = Configuration("global").get("object_store")
+        name = url.path.strip("/")
+        if url.netloc != conf["buckets"]["raw_messages"] or \
+                name != os.path.basename(name):
+            raise IOError("invalid object uri {}".format(url.geturl()))
+        path = os.path.join(conf["root_path"], url.netloc, "objects",
+                            name[:2], name)
+        with open(path, "rb") as f:
+            return f.read()
+
     @classmethod
     def get_for_user(cls, user_id, raw_msg_id):
         """
@@ -149,3 +190,17 @@ class UserRawLookup(BaseUserCore):
 
     _model_class = ModelUserRawLookup
     _pkey_name = 'raw_msg_id'
+
+
+class RawMessageHash(BaseCore):
+    """Raw message content's hash lookup."""
+
+    _model_class = ModelRawMessageHash
+    _pkey_name = 'hash'
+
+
+class RawMessageRefs(BaseCore):
+    """Raw message references counter."""
+
+    _model_class = ModelRawMessageRefs
+    _pkey_name = 'raw_msg_id'
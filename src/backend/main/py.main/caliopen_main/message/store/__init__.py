@@ -1,7 +1,8 @@
 # -*- coding: utf-8 -*-
 from __future__ import absolute_import, print_function, unicode_literals
 
-from .attachment import MessageAttachment
+from .attachment import MessageAttachment, AttachmentBlob, AttachmentRefs, \
+    MessageRelease
 from .attachment_index import IndexedMessageAttachment
 from .external_references import ExternalReferences
 from .external_references_index import IndexedExternalReferences
@@ -9,10 +10,11 @@ from .message import Message
 from .message_index import IndexedMessage
 from .participant import Participant
 from .participant_index import IndexedParticipant
-from .raw import RawMessage, UserRawLookup
+from .raw import RawMessage, UserRawLookup, RawMessageHash, RawMessageRefs
 
 __all__ = ['MessageAttachment', 'IndexedMessageAttachment',
-           'RawMessage', 'UserRawLookup',
+           'AttachmentBlob', 'AttachmentRefs', 'MessageRelease',
+           'RawMessage', 'UserRawLookup', 'RawMessageHash', 'RawMessageRefs',
            'Message', 'IndexedMessage',
            'ExternalReferences', 'IndexedExternalReferences',
            'Participant', 'IndexedParticipant'
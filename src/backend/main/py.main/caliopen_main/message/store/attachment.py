@@ -4,6 +4,7 @@ from __future__ import absolute_import, print_function, unicode_literals
 from cassandra.cqlengine import columns
 
 from caliopen_storage.store import BaseUserType
+from caliopen_storage.store.model import BaseModel
 
 
 class MessageAttachment(BaseUserType):
@@ -17,3 +18,32 @@ class MessageAttachment(BaseUserType):
     temp_id = columns.UUID()
     url = columns.Text()  # objectsStore uri for temporary file (draft)
     mime_boundary = columns.Text()  # for attachments embedded in raw messages
+
+
+class AttachmentBlob(BaseModel):
+    """Attachment's file in objects store, keyed by its content's hash."""
+
+    hash = columns.Text(primary_key=True)
+    uri = columns.Text()    # object holding the file
+    size = columns.Integer()
+    refs = columns.Integer()    # number of attachments sharing the file
+    # encryption metadata, empty if file is stored in clear
+    key_owner = columns.UUID()
+    key_version = columns.Integer()
+    wrapped_key = columns.Bytes()
+    stream_sealed = columns.Boolean()
+
+
+class AttachmentRefs(BaseModel):
+    """Former number of attachments sharing the same file, see AttachmentBlob.refs."""
+
+    hash = columns.Text(primary_key=True)
+    refs = columns.Counter()
+
+
+class MessageRelease(BaseModel):
+    """Attachment's reference released by a message being deleted."""
+
+    user_id = columns.UUID(primary_key=True)
+    message_id = columns.UUID(primary_key=True)     # clustering key
+    ref = columns.Text(primary_key=True)            # position and uri of attachment
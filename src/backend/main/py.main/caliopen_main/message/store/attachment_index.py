@@ -3,7 +3,8 @@ from __future__ import absolute_import, print_function, unicode_literals
 
 import logging
 
-from elasticsearch_dsl import InnerObjectWrapper, Boolean, Integer, Keyword
+from elasticsearch_dsl import InnerObjectWrapper, Boolean, Integer, Keyword, \
+    Text
 
 log = logging.getLogger(__name__)
 
@@ -19,3 +20,4 @@ class IndexedMessageAttachment(InnerObjectWrapper):
     temp_id = Keyword()
     url = Keyword()  # objectsStore uri for temporary file (draft)
     mime_boundary = Keyword()  # for attachments embedded in raw messages
+    content = Text()  # text extracted from attachment by email broker
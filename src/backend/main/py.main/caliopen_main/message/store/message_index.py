@@ -63,7 +63,8 @@ class IndexedMessage(BaseIndexDocument):
                                           "size": Integer(),
                                           "temp_id": Keyword(),
                                           "url": Keyword(),
-                                          "mime_boundary": Keyword()
+                                          "mime_boundary": Keyword(),
+                                          "content": Text()
                                       })
                 )
         m.field('body_html', 'text', fields={
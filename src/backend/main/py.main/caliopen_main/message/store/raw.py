@@ -16,6 +16,25 @@ class RawMessage(BaseModel):
     raw_data = columns.Bytes()  # may be empty if data is too large to fit into cassandra
     raw_size = columns.Integer()  # number of bytes in 'data' column
     uri = columns.Text()  # where object is stored if it was too large to fit into raw_data column
+    hash = columns.Text()  # sha256 of raw_data, see RawMessageHash
+    # encryption metadata, empty if raw data is stored in clear
+    key_owner = columns.UUID()  # user whose data key wraps the content key
+    key_version = columns.Integer()
+    wrapped_key = columns.Bytes()
+
+
+class RawMessageHash(BaseModel):
+    """Lookup from a raw message content's hash to the raw message."""
+
+    hash = columns.Text(primary_key=True)
+    raw_msg_id = columns.UUID()
+
+
+class RawMessageRefs(BaseModel):
+    """Number of messages built from a raw message."""
+
+    raw_msg_id = columns.UUID(primary_key=True)
+    refs = columns.Counter()
 
 
 class UserRawLookup(BaseModel):
@@ -23,3 +42,4 @@ class UserRawLookup(BaseModel):
 
     user_id = columns.UUID(primary_key=True)
     raw_msg_id = columns.UUID(primary_key=True)
+    refs = columns.Integer()  # number of user's messages built from it
@@ -111,6 +111,7 @@ def setup_settings(user, settings):
         'message_display_format': settings.message_display_format,
         'contact_display_order': settings.contact_display_order,
         'contact_display_format': settings.contact_display_format,
+        'inbound_decryption': settings.inbound_decryption,
         'notification_enabled': settings.notification_enabled,
         'notification_message_preview':
             settings.notification_message_preview,
@@ -118,6 +119,9 @@ def setup_settings(user, settings):
             settings.notification_sound_enabled,
         'notification_delay_disappear':
             settings.notification_delay_disappear,
+        'outbound_encryption': settings.outbound_encryption,
+        'outbound_signature': settings.outbound_signature,
+        'trash_retention_days': settings.trash_retention_days,
     }
 
     obj = ObjectSettings(user.user_id)
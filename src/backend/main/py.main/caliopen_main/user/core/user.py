@@ -20,11 +20,15 @@ from ..store import (User as ModelUser,
                      UserRecoveryEmail as ModelUserRecoveryEmail,
                      IndexUser,
                      UserTag as ModelUserTag,
+                     UserSavedSearch as ModelUserSavedSearch,
                      Settings as ModelSettings,
                      FilterRule as ModelFilterRule,
                      ReservedName as ModelReservedName,
                      LocalIdentity as ModelLocalIdentity,
-                     RemoteIdentity as ModelRemoteIdentity)
+                     RemoteIdentity as ModelRemoteIdentity,
+                     UserDataKey as ModelUserDataKey,
+                     UserExport as ModelUserExport,
+                     UserImport as ModelUserImport)
 
 from caliopen_storage.core import BaseCore, BaseUserCore
 from caliopen_main.contact.core import Contact as CoreContact
@@ -51,6 +55,27 @@ class RemoteIdentity(BaseUserCore):
     _pkey_name = 'identifier'
 
 
+class UserDataKey(BaseUserCore):
+    """User data key core class, see caliopen_main.common.helpers.encryption."""
+
+    _model_class = ModelUserDataKey
+    _pkey_name = 'version'
+
+
+class UserExport(BaseUserCore):
+    """User's data export core class."""
+
+    _model_class = ModelUserExport
+    _pkey_name = 'export_id'
+
+
+class UserImport(BaseUserCore):
+    """User's mailbox import core class."""
+
+    _model_class = ModelUserImport
+    _pkey_name = 'import_id'
+
+
 class Tag(BaseUserCore):
     """Tag core object."""
 
@@ -58,6 +83,13 @@ class Tag(BaseUserCore):
     _pkey_name = 'tag_id'
 
 
+class SavedSearch(BaseUserCore):
+    """User saved search core class."""
+
+    _model_class = ModelUserSavedSearch
+    _pkey_name = 'search_id'
+
+
 class FilterRule(BaseUserCore):
     """Filter rule core class."""
 
@@ -357,6 +389,8 @@ class User(BaseCore):
             user = cls.by_name(user_name)
         except NotFound:
             raise CredentialException('Invalid user')
+        if user.date_delete:
+            raise CredentialException('Invalid user')
         # XXX : decode unicode not this way
         if bcrypt.hashpw(str(password.encode('utf-8')),
                          str(user.password)) == user.password:
@@ -23,10 +23,14 @@ class Settings(ObjectUser):
         'message_display_format': types.StringType,
         'contact_display_order': types.StringType,
         'contact_display_format': types.StringType,
+        'inbound_decryption': types.BooleanType,
         'notification_enabled': types.BooleanType,
         'notification_message_preview': types.StringType,
         'notification_sound_enabled': types.BooleanType,
         'notification_delay_disappear': types.IntType,
+        'outbound_encryption': types.StringType,
+        'outbound_signature': types.StringType,
+        'trash_retention_days': types.IntType,
     }
 
     _model_class = ModelSettings
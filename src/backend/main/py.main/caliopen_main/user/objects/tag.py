@@ -17,6 +17,7 @@ class UserTag(ObjectUser):
 
     _attrs = {
         'date_insert': datetime.datetime,
+        'expiry_days': types.IntType,
         'importance_level': types.IntType,
         'name': types.StringType,
         'label': types.StringType,
@@ -11,6 +11,7 @@ CONTACT_FORMAT_CHOICES = ['given_name, family_name',
 CONTACT_ORDER_CHOICES = ['family_name', 'given_name']
 PREVIEW_CHOICES = ['off', 'always']
 DELAY_CHOICES = [0, 5, 10, 30]
+PROTECTION_CHOICES = ['never', 'when_possible', 'always']
 
 
 class Settings(Model):
@@ -23,9 +24,15 @@ class Settings(Model):
                                         choices=CONTACT_FORMAT_CHOICES)
     contact_display_order = StringType(default='given_name',
                                        choices=CONTACT_ORDER_CHOICES)
+    inbound_decryption = BooleanType(default=False)
     notification_enabled = BooleanType(default=True)
     notification_message_preview = StringType(default='always',
                                               choices=PREVIEW_CHOICES)
     notification_sound_enabled = BooleanType(default=False)
     notification_delay_disappear = IntType(default=10,
                                            choices=DELAY_CHOICES)
+    outbound_encryption = StringType(default='when_possible',
+                                     choices=PROTECTION_CHOICES)
+    outbound_signature = StringType(default='when_possible',
+                                    choices=PROTECTION_CHOICES)
+    trash_retention_days = IntType(default=30, min_value=0)
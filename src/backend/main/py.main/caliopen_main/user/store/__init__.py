@@ -2,8 +2,12 @@
 from __future__ import absolute_import, print_function, unicode_literals
 
 from .user import User, UserName, ReservedName, FilterRule, UserRecoveryEmail
-from .user import RemoteIdentity, IndexUser, Settings
+from .user import RemoteIdentity, IndexUser, Settings, UserDataKey
 from .tag import UserTag
+from .saved_search import UserSavedSearch
+from .export import UserExport
+from .import_ import UserImport
+from .deletion import UserDeletion
 from .local_identity_index import IndexedLocalIdentity
 from .local_identity import LocalIdentity
 
@@ -12,5 +16,6 @@ __all__ = [
     'User', 'UserName', 'UserRecoveryEmail', 'UserTag', 'FilterRule',
     'ReservedName',
     'RemoteIdentity', 'IndexUser', 'UserTag', 'Settings',
-    'IndexedLocalIdentity', 'LocalIdentity',
+    'IndexedLocalIdentity', 'LocalIdentity', 'UserSavedSearch',
+    'UserDataKey', 'UserExport', 'UserImport', 'UserDeletion',
 ]
@@ -0,0 +1,18 @@
+# -*- coding: utf-8 -*-
+"""Caliopen user's account deletion objects."""
+from __future__ import absolute_import, print_function, unicode_literals
+
+from cassandra.cqlengine import columns
+
+from caliopen_storage.store import BaseModel
+
+
+class UserDeletion(BaseModel):
+    """Pending or completed deletion of an user's account and all its data."""
+    user_id = columns.UUID(primary_key=True)
+    status = columns.Text()         # pending, running or done
+    requested_by = columns.Text()   # user or admin
+    steps = columns.Set(columns.Text())  # cascade steps already completed
+    error = columns.Text()
+    date_insert = columns.DateTime()
+    date_end = columns.DateTime()
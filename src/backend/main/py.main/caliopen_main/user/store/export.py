@@ -0,0 +1,22 @@
+# -*- coding: utf-8 -*-
+"""Caliopen user's data export objects."""
+from __future__ import absolute_import, print_function, unicode_literals
+
+from cassandra.cqlengine import columns
+
+from caliopen_storage.store import BaseModel
+
+
+class UserExport(BaseModel):
+    """Export of user's messages, contacts and tags into an archive."""
+    user_id = columns.UUID(primary_key=True)
+    export_id = columns.UUID(primary_key=True)
+    format = columns.Text()         # mbox or maildir
+    status = columns.Text()         # pending, done or failed
+    uri = columns.Text()            # archive location in objects store
+    size = columns.Integer()
+    messages_count = columns.Integer()
+    contacts_count = columns.Integer()
+    error = columns.Text()
+    date_insert = columns.DateTime()
+    date_end = columns.DateTime()
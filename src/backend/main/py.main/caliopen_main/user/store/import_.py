@@ -0,0 +1,24 @@
+# -*- coding: utf-8 -*-
+"""Caliopen user's mailbox import objects."""
+from __future__ import absolute_import, print_function, unicode_literals
+
+from cassandra.cqlengine import columns
+
+from caliopen_storage.store import BaseModel
+
+
+class UserImport(BaseModel):
+    """Import of a mbox or Maildir archive into user's account."""
+    user_id = columns.UUID(primary_key=True)
+    import_id = columns.UUID(primary_key=True)
+    format = columns.Text()         # mbox, maildir or empty if unknown yet
+    source = columns.Text()         # archive location in objects store or local path
+    status = columns.Text()         # pending, running, done or failed
+    total = columns.Integer()
+    imported = columns.Integer()
+    duplicates = columns.Integer()
+    failed = columns.Integer()
+    error = columns.Text()
+    date_insert = columns.DateTime()
+    date_update = columns.DateTime()
+    date_end = columns.DateTime()
@@ -0,0 +1,20 @@
+# -*- coding: utf-8 -*-
+"""Caliopen saved search objects."""
+from __future__ import absolute_import, print_function, unicode_literals
+
+from cassandra.cqlengine import columns
+
+from caliopen_storage.store import BaseModel
+
+
+class UserSavedSearch(BaseModel):
+    """User saved searches model."""
+    user_id = columns.UUID(primary_key=True)
+    search_id = columns.UUID(primary_key=True)
+    date_insert = columns.DateTime()
+    date_update = columns.DateTime()
+    label = columns.Text()
+    query = columns.Text()
+    field = columns.Text()
+    filters = columns.Map(columns.Text(), columns.List(columns.Text()))
+    notify = columns.Boolean(default=False)
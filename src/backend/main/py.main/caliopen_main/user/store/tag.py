@@ -12,6 +12,7 @@ class UserTag(BaseModel):
     user_id = columns.UUID(primary_key=True)
     name = columns.Text(primary_key=True)
     date_insert = columns.DateTime()
+    expiry_days = columns.Integer()
     importance_level = columns.Integer()
     label = columns.Text()
     type = columns.Text()
@@ -50,6 +50,7 @@ class User(BaseModel):
     main_user_id = columns.UUID()
     recovery_email = columns.Text(required=True)
     local_identities = columns.List(columns.Text())
+    date_delete = columns.DateTime()    # set when account deletion is requested
 
     privacy_features = columns.Map(columns.Text(), columns.Text())
     pi = columns.UserDefinedType(PIModel)
@@ -75,10 +76,14 @@ class Settings(BaseModel):
     message_display_format = columns.Text()
     contact_display_format = columns.Text()
     contact_display_order = columns.Text()
+    inbound_decryption = columns.Boolean()
     notification_enabled = columns.Boolean()
     notification_message_preview = columns.Text()
     notification_sound_enabled = columns.Boolean()
     notification_delay_disappear = columns.Integer()
+    outbound_encryption = columns.Text()
+    outbound_signature = columns.Text()
+    trash_retention_days = columns.Integer()
 
 
 class RemoteIdentity(BaseModel):
@@ -93,6 +98,16 @@ class RemoteIdentity(BaseModel):
     infos = columns.Map(columns.Text, columns.Text)
 
 
+class UserDataKey(BaseModel):
+    """User's data keys, wrapped by a master key of the key management service."""
+
+    user_id = columns.UUID(primary_key=True)
+    version = columns.Integer(primary_key=True, clustering_order='DESC')
+    wrapped_key = columns.Bytes()
+    master_key_id = columns.Text()
+    date_insert = columns.DateTime()
+
+
 class IndexUser(object):
     """User index management class."""
 
@@ -24,6 +24,7 @@ requires = [
     'zope.interface',
     'vobject',
     'minio',
+    'cryptography',
 ]
 
 extras_require = {
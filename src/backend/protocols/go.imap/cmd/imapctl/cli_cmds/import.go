@@ -0,0 +1,68 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package cmd
+
+import (
+	"encoding/json"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/Sirupsen/logrus"
+	"github.com/nats-io/go-nats"
+	"github.com/spf13/cobra"
+	"path/filepath"
+)
+
+var (
+	importPath string
+	importCmd  = &cobra.Command{
+		Use:   "import",
+		Short: "imports mails from a mbox file, a Maildir directory or a zip archive into user's account.",
+		Long: `imports mails from a mbox file, a Maildir directory or a zip archive into user's account.
+Path must be readable by IMAP workers. Messages already in user's account are skipped,
+folders are mapped to tags. Progress is saved into user_import table.`,
+		Run: importArchive,
+	}
+)
+
+func init() {
+	importCmd.Flags().StringVarP(&id.UserId, "userid", "u", "", "user account uuid in which mails will be imported (required)")
+	importCmd.Flags().StringVarP(&importPath, "path", "p", "", "path to mbox file, Maildir or zip archive, as seen by IMAP workers (required)")
+	importCmd.MarkFlagRequired("userid")
+	importCmd.MarkFlagRequired("path")
+	RootCmd.AddCommand(importCmd)
+}
+
+// importArchive
+func importArchive(cmd *cobra.Command, args []string) {
+	path, err := filepath.Abs(importPath)
+	if err != nil {
+		logrus.WithError(err).Fatalf("invalid path %s", importPath)
+	}
+
+	nc, err := nats.Connect(cmdConfig.NatsUrl)
+	if err != nil {
+		logrus.WithError(err).Fatal("nats connect failed")
+	}
+	defer nc.Close()
+
+	msg, err := json.Marshal(IMAPfetchOrder{
+		Order:   "import",
+		UserId:  id.UserId,
+		Mailbox: path,
+	})
+	if err != nil {
+		logrus.WithError(err).Fatal("unable to marshal natsOrder")
+	}
+
+	nc.Publish(cmdConfig.NatsTopic, msg)
+	nc.Flush()
+
+	if err := nc.LastError(); err != nil {
+		logrus.WithError(err).Fatal("nats publish failed")
+	}
+
+	logrus.Infof("ordering to import mails from %s for user %s", path, id.UserId)
+}
@@ -0,0 +1,312 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package imap_worker
+
+import (
+	"encoding/json"
+	"errors"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/mailbox"
+	log "github.com/Sirupsen/logrus"
+	"github.com/satori/go.uuid"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+)
+
+// import's counters are saved into store every importProgressStep messages
+const importProgressStep = 50
+
+// Importer feeds messages of mbox or Maildir archives into user's account,
+// through the same broker path as emails fetched from remote IMAP servers.
+type Importer struct {
+	Lda *Lda
+}
+
+// ImportArchive imports archive designated by order. If order.Identifier is set, it is the id of an import
+// created by REST api whose archive has been uploaded into objects store. Otherwise order.Mailbox is a path
+// on worker's filesystem to a mbox file, a zip or a directory.
+// Messages are deduplicated by Message-ID, folders are mapped to tags. User is notified at the end.
+func (imp *Importer) ImportArchive(order IMAPfetchOrder) error {
+	store := imp.Lda.broker.Store
+	var userImport *UserImport
+	var err error
+	if order.Identifier != "" {
+		userImport, err = store.RetrieveUserImport(order.UserId, order.Identifier)
+		if err != nil {
+			log.WithError(err).Warnf("[ImportArchive] failed to retrieve import %s of user %s", order.Identifier, order.UserId)
+			return err
+		}
+	} else {
+		userImport = &UserImport{
+			DateInsert: time.Now(),
+			Source:     order.Mailbox,
+			Status:     ImportPending,
+		}
+		userImport.ImportId.UnmarshalBinary(uuid.NewV4().Bytes())
+		userImport.UserId.UnmarshalBinary(uuid.FromStringOrNil(order.UserId).Bytes())
+		if err = store.CreateUserImport(userImport); err != nil {
+			log.WithError(err).Warnf("[ImportArchive] failed to create import of %s for user %s", order.Mailbox, order.UserId)
+			return err
+		}
+	}
+	if userImport.Status != ImportPending {
+		return errors.New("[ImportArchive] import " + userImport.ImportId.String() + " is " + userImport.Status)
+	}
+	log.Infof("[ImportArchive] importing archive %s for user %s", userImport.ImportId.String(), order.UserId)
+
+	run := importRun{
+		importer:   imp,
+		userImport: userImport,
+		seen:       map[string]bool{},
+	}
+	err = run.walk(order.Identifier != "", run.importMessage)
+	userImport.DateEnd = time.Now()
+	fields := run.counters()
+	fields["DateEnd"] = userImport.DateEnd
+	if err != nil {
+		log.WithError(err).Warnf("[ImportArchive] import %s of user %s failed", userImport.ImportId.String(), order.UserId)
+		userImport.Status = ImportFailed
+		userImport.Error = err.Error()
+		fields["Error"] = userImport.Error
+	} else {
+		userImport.Status = ImportDone
+	}
+	fields["Status"] = userImport.Status
+	if e := store.UpdateUserImport(userImport, fields); e != nil {
+		log.WithError(e).Warnf("[ImportArchive] failed to save import %s of user %s", userImport.ImportId.String(), order.UserId)
+	}
+	if order.Identifier != "" {
+		// uploaded archive is not needed anymore
+		if e := store.DeleteAttachment(userImport.Source); e != nil {
+			log.WithError(e).Warnf("[ImportArchive] failed to remove archive of import %s", userImport.ImportId.String())
+		}
+	}
+	run.notify()
+	return err
+}
+
+type importRun struct {
+	importer   *Importer
+	userImport *UserImport
+	seen       map[string]bool   // Message-IDs already met during this run
+	tags       map[string]string // user's tags names by names and lowercased labels
+	count      int
+}
+
+// walk calls fn for each message of the archive, which is read twice :
+// a first time to count messages, then to import them.
+func (run *importRun) walk(fromObjectStore bool, fn mailbox.WalkFunc) error {
+	walk := func(fn mailbox.WalkFunc) error {
+		return mailbox.Walk(run.userImport.Source, fn)
+	}
+	if fromObjectStore {
+		file, err := run.importer.Lda.broker.Store.GetAttachment(run.userImport.Source)
+		if err != nil {
+			return err
+		}
+		tmp, err := ioutil.TempFile("", "caliopen-import-")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			tmp.Close()
+			os.Remove(tmp.Name())
+		}()
+		if _, err = io.Copy(tmp, file); err != nil {
+			return err
+		}
+		walk = func(fn mailbox.WalkFunc) error {
+			if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+				return err
+			}
+			return mailbox.WalkFile(tmp, "", fn)
+		}
+	}
+
+	err := walk(func(msg *mailbox.ArchivedMessage) error {
+		if importable(msg) {
+			run.userImport.Total++
+			if run.userImport.Format == "" {
+				run.userImport.Format = msg.Format
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	if run.userImport.Total == 0 {
+		return errors.New("no message found in archive")
+	}
+	run.userImport.Status = ImportRunning
+	run.save("Status", "Total", "Format")
+	return walk(fn)
+}
+
+// importMessage delivers msg to user unless it has already been imported.
+// Delivery failures are counted, they do not stop the import.
+func (run *importRun) importMessage(msg *mailbox.ArchivedMessage) error {
+	if !importable(msg) {
+		return nil
+	}
+	defer func() {
+		run.count++
+		if run.count%importProgressStep == 0 {
+			run.save()
+		}
+	}()
+	userId := run.userImport.UserId.String()
+	if msg.MessageId != "" {
+		// messages delivered during this run may not be searchable yet in index,
+		// index is only looked up for messages that were in user's account before the import.
+		if run.seen[msg.MessageId] {
+			run.userImport.Duplicates++
+			return nil
+		}
+		run.seen[msg.MessageId] = true
+		exists, err := run.importer.Lda.broker.Index.MessageExistsByExternalId(userId, msg.MessageId)
+		if err != nil {
+			log.WithError(err).Warnf("[ImportArchive] failed to lookup message <%s> in index", msg.MessageId)
+		}
+		if exists {
+			run.userImport.Duplicates++
+			return nil
+		}
+	}
+
+	imported := &ImportedMessage{
+		IsUnread: msg.Info.Unread,
+		Tags:     run.messageTags(msg),
+	}
+	if !msg.Info.Date.IsZero() {
+		imported.Date = &msg.Info.Date
+	}
+	mail := new(Email)
+	mail.Raw.Write(msg.Raw)
+	if err := run.importer.Lda.deliverImported(mail, userId, imported); err != nil {
+		log.WithError(err).Warnf("[ImportArchive] failed to deliver message <%s> of import %s", msg.MessageId, run.userImport.ImportId.String())
+		run.userImport.Failed++
+		return nil
+	}
+	run.userImport.Imported++
+	return nil
+}
+
+// importable returns false for drafts and deleted messages, they can't be delivered as received emails.
+func importable(msg *mailbox.ArchivedMessage) bool {
+	return !msg.Info.Draft && !msg.Info.Trashed
+}
+
+// messageTags returns names of tags for message's folder and keywords, creating missing user's tags.
+func (run *importRun) messageTags(msg *mailbox.ArchivedMessage) (tags []string) {
+	labels := map[string]string{} // name -> label
+	if name, label := mailbox.FolderTag(msg.Folder); name != "" {
+		labels[name] = label
+	}
+	for _, keyword := range msg.Info.Keywords {
+		labels[mailbox.TagName(keyword)] = keyword
+	}
+	for name, label := range labels {
+		if tag := run.tag(name, label); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return
+}
+
+// tag returns name of user's tag matching name or label, tag is created if it does not exist yet.
+func (run *importRun) tag(name, label string) string {
+	store := run.importer.Lda.broker.Store
+	userId := run.userImport.UserId.String()
+	if run.tags == nil {
+		run.tags = map[string]string{}
+		userTags, _ := store.RetrieveUserTags(userId) // "tags not found" error if user has no tag yet
+		for _, t := range userTags {
+			run.tags[t.Name] = t.Name
+			run.tags[strings.ToLower(t.Label)] = t.Name
+		}
+	}
+	if existing, ok := run.tags[name]; ok {
+		return existing
+	}
+	if existing, ok := run.tags[strings.ToLower(label)]; ok {
+		return existing
+	}
+	tag := &Tag{
+		User_id: run.userImport.UserId,
+		Label:   label,
+		Name:    name,
+	}
+	if err := store.CreateTag(tag); err != nil {
+		log.WithError(err).Warnf("[ImportArchive] failed to create tag %s for user %s", name, userId)
+		return ""
+	}
+	run.tags[name] = name
+	run.tags[strings.ToLower(label)] = name
+	return name
+}
+
+func (run *importRun) counters() map[string]interface{} {
+	run.userImport.DateUpdate = time.Now()
+	return map[string]interface{}{
+		"DateUpdate": run.userImport.DateUpdate,
+		"Duplicates": run.userImport.Duplicates,
+		"Failed":     run.userImport.Failed,
+		"Imported":   run.userImport.Imported,
+	}
+}
+
+// save updates import's counters and the given fields into store
+func (run *importRun) save(fields ...string) {
+	modified := run.counters()
+	for _, field := range fields {
+		switch field {
+		case "Format":
+			modified[field] = run.userImport.Format
+		case "Status":
+			modified[field] = run.userImport.Status
+		case "Total":
+			modified[field] = run.userImport.Total
+		}
+	}
+	if err := run.importer.Lda.broker.Store.UpdateUserImport(run.userImport, modified); err != nil {
+		log.WithError(err).Warnf("[ImportArchive] failed to save progress of import %s", run.userImport.ImportId.String())
+	}
+}
+
+func (run *importRun) notify() {
+	if run.importer.Lda.broker.Notifier == nil {
+		return
+	}
+	ui := run.userImport
+	body, _ := json.Marshal(map[string]interface{}{
+		"importDone": map[string]interface{}{
+			"import_id":  ui.ImportId.String(),
+			"status":     ui.Status,
+			"imported":   ui.Imported,
+			"duplicates": ui.Duplicates,
+			"failed":     ui.Failed,
+		},
+	})
+	notif := Notification{
+		Emitter: "imap",
+		Type:    EventNotif,
+		TTLcode: LongLived,
+		User: &User{
+			UserId: ui.UserId,
+		},
+		NotifId: UUID(uuid.NewV1()),
+		Body:    string(body),
+	}
+	if ui.Status != ImportDone {
+		notif.Type = ErrorNotif
+	}
+	run.importer.Lda.broker.Notifier.ByNotifQueue(&notif)
+}
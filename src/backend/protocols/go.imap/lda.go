@@ -36,6 +36,15 @@ func (lda *Lda) shutdown() error {
 }
 
 func (lda *Lda) deliverMail(mail *Email, userId string) (err error) {
+	return lda.deliver(mail, userId, nil)
+}
+
+// deliverImported delivers an email read from a mailbox archive, keeping its original state.
+func (lda *Lda) deliverImported(mail *Email, userId string, imported *ImportedMessage) (err error) {
+	return lda.deliver(mail, userId, imported)
+}
+
+func (lda *Lda) deliver(mail *Email, userId string, imported *ImportedMessage) (err error) {
 	emailMsg := &EmailMessage{
 		Email: mail,
 		Message: &Message{
@@ -44,6 +53,7 @@ func (lda *Lda) deliverMail(mail *Email, userId string) (err error) {
 	}
 	incoming := &broker.SmtpEmail{
 		EmailMessage: emailMsg,
+		Import:       imported,
 		Response:     make(chan *DeliveryAck),
 	}
 	defer close(incoming.Response)
@@ -10,6 +10,7 @@ import (
 	"encoding/json"
 	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/cassandra"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gocql/gocql"
@@ -61,8 +62,10 @@ func NewWorker(config WorkerConfig, id uint8) (worker *Worker, err error) {
 			Consistency: gocql.Consistency(config.StoreConfig.Consistency),
 			SizeLimit:   config.StoreConfig.SizeLimit,
 		}
-		if config.StoreConfig.ObjectStore == "s3" {
+		if config.StoreConfig.ObjectStore == "s3" || config.StoreConfig.ObjectStore == "fs" {
 			c.WithObjStore = true
+			c.StoreType = config.StoreConfig.ObjectStore
+			c.RootPath = config.StoreConfig.OSSConfig.RootPath
 			c.Endpoint = config.StoreConfig.OSSConfig.Endpoint
 			c.AccessKey = config.StoreConfig.OSSConfig.AccessKey
 			c.SecretKey = config.StoreConfig.OSSConfig.SecretKey
@@ -75,6 +78,8 @@ func NewWorker(config WorkerConfig, id uint8) (worker *Worker, err error) {
 			log.WithError(err).Warnf("[NewWorker] initalization of %s backend failed", config.StoreName)
 			return nil, err
 		}
+	case "memory":
+		w.Store, _ = memory.InitializeMemoryBackend()
 	}
 
 	return &w, nil
@@ -124,6 +129,11 @@ func (worker *Worker) natsMsgHandler(msg *nats.Msg) {
 			Lda:   worker.Lda,
 		}
 		go fetcher.FetchRemoteToLocal(message)
+	case "import": // order sent by REST api or imapctl to import a mbox/Maildir archive
+		importer := Importer{
+			Lda: worker.Lda,
+		}
+		go importer.ImportArchive(message)
 	case "test":
 		log.Info("Order « test » received")
 	}
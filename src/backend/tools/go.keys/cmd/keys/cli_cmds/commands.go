@@ -0,0 +1,46 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package cmd
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	reencrypt bool
+	rewrapCmd = &cobra.Command{
+		Use:   "rewrap",
+		Short: "Wraps all users' data keys with current master key",
+		Long: `Once a new master key has been added to the keyring and made current,
+rewrap must be run before former master key is removed from the keyring.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			km := newKeysManager()
+			defer km.Close()
+			if err := km.Rewrap(); err != nil {
+				log.WithError(err).Fatal("re-wrapping failed for some data keys")
+			}
+		},
+	}
+	rotateCmd = &cobra.Command{
+		Use:   "rotate [user_id…]",
+		Short: "Rotates data keys of the given users, or of all users",
+		Run: func(cmd *cobra.Command, args []string) {
+			km := newKeysManager()
+			defer km.Close()
+			if err := km.Rotate(args, reencrypt); err != nil {
+				log.WithError(err).Fatal("rotation failed for some users")
+			}
+		},
+	}
+)
+
+func init() {
+	rotateCmd.Flags().BoolVarP(&reencrypt, "reencrypt", "r", false,
+		"also re-encrypt users' contents with new content keys")
+	RootCmd.AddCommand(rewrapCmd, rotateCmd)
+}
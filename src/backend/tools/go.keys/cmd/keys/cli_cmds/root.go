@@ -0,0 +1,78 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package cmd
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/tools/go.keys"
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	configFile string
+	configPath string
+	verbose    bool
+	RootCmd    = &cobra.Command{
+		Use:   "keys",
+		Short: "Encryption keys management",
+		Long:  `keys re-wraps users' data keys after a master key rotation, and rotates users' data keys`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+)
+
+func init() {
+	RootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false,
+		"print out more debug information")
+	RootCmd.PersistentFlags().StringVarP(&configFile, "config", "c",
+		"caliopen-keys_dev", "Name of the configuration file, without extension. (YAML, TOML, JSON… allowed)")
+	RootCmd.PersistentFlags().StringVarP(&configPath, "configpath", "",
+		"../../../../configs/", "Main config file path.")
+	RootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if verbose {
+			log.SetLevel(log.DebugLevel)
+		} else {
+			log.SetLevel(log.InfoLevel)
+		}
+	}
+}
+
+func newKeysManager() *KeysManager {
+	config := KeysConfig{}
+	if err := readConfig(&config); err != nil {
+		log.WithError(err).Fatal("Error while reading config")
+	}
+	km, err := NewKeysManager(config)
+	if err != nil {
+		log.WithError(err).Fatal("can't initialize keys manager")
+	}
+	return km
+}
+
+func readConfig(config *KeysConfig) error {
+	// load in the main config. Reading from YAML, TOML, JSON, HCL and Java properties config files
+	v := viper.New()
+	v.SetConfigName(configFile)                           // name of config file (without extension)
+	v.AddConfigPath(configPath)                           // path to look for the config file in
+	v.AddConfigPath("$CALIOPENROOT/src/backend/configs/") // call multiple times to add many search paths
+	v.AddConfigPath(".")                                  // optionally look for config in the working directory
+
+	err := v.ReadInConfig() // Find and read the config file
+	if err != nil {
+		log.WithError(err).Infof("Could not read main config file <%s>.", configFile)
+		return err
+	}
+	err = v.Unmarshal(config)
+	if err != nil {
+		log.WithError(err).Infof("Could not parse config file: <%s>", configFile)
+		return err
+	}
+
+	return nil
+}
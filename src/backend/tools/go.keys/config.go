@@ -0,0 +1,16 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package go_keys
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+)
+
+type KeysConfig struct {
+	StoreName   string      `mapstructure:"store_name"`
+	StoreConfig StoreConfig `mapstructure:"store_settings"`
+}
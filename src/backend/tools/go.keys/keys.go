@@ -0,0 +1,95 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+// Package go_keys handles administrative tasks on the keys used to encrypt raw messages and attachments :
+// - re-wrapping of users' data keys after a master key rotation,
+// - rotation of users' data keys, with optional re-encryption of their contents.
+package go_keys
+
+import (
+	"errors"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/cassandra"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gocql/gocql"
+)
+
+type KeysManager struct {
+	Config KeysConfig
+	Store  backends.KeysStore
+}
+
+func NewKeysManager(config KeysConfig) (manager *KeysManager, err error) {
+	if config.StoreConfig.Encryption != "local" {
+		return nil, errors.New("[KeysManager] encryption is not enabled in store settings")
+	}
+	km := KeysManager{Config: config}
+
+	switch config.StoreName {
+	case "cassandra":
+		c := store.CassandraConfig{
+			Hosts:          config.StoreConfig.Hosts,
+			Keyspace:       config.StoreConfig.Keyspace,
+			Consistency:    gocql.Consistency(config.StoreConfig.Consistency),
+			SizeLimit:      config.StoreConfig.SizeLimit,
+			WithEncryption: true,
+		}
+		c.KMSType = config.StoreConfig.Encryption
+		c.KeyringPath = config.StoreConfig.KMSConfig.KeyringPath
+		if config.StoreConfig.ObjectStore == "s3" || config.StoreConfig.ObjectStore == "fs" {
+			c.WithObjStore = true
+			c.StoreType = config.StoreConfig.ObjectStore
+			c.RootPath = config.StoreConfig.OSSConfig.RootPath
+			c.Endpoint = config.StoreConfig.OSSConfig.Endpoint
+			c.AccessKey = config.StoreConfig.OSSConfig.AccessKey
+			c.SecretKey = config.StoreConfig.OSSConfig.SecretKey
+			c.RawMsgBucket = config.StoreConfig.OSSConfig.Buckets["raw_messages"]
+			c.AttachmentBucket = config.StoreConfig.OSSConfig.Buckets["temporary_attachments"]
+			c.Location = config.StoreConfig.OSSConfig.Location
+		}
+		km.Store, err = store.InitializeCassandraBackend(c)
+		if err != nil {
+			log.WithError(err).Warnf("[NewKeysManager] initalization of %s backend failed", config.StoreName)
+			return nil, err
+		}
+	default:
+		return nil, errors.New("[NewKeysManager] unknown store backend " + config.StoreName)
+	}
+	return &km, nil
+}
+
+// Rewrap wraps users' data keys with current master key.
+func (km *KeysManager) Rewrap() error {
+	count, err := km.Store.RewrapUserDataKeys()
+	log.Infof("[KeysManager] %d data keys re-wrapped", count)
+	return err
+}
+
+// Rotate rotates data keys of the given users, or of all users if user_ids is empty.
+func (km *KeysManager) Rotate(user_ids []string, reencrypt bool) (err error) {
+	if len(user_ids) == 0 {
+		users, e := km.Store.RetrieveAllUsersIds()
+		if e != nil {
+			return e
+		}
+		for user_id := range users {
+			user_ids = append(user_ids, user_id)
+		}
+	}
+	for _, user_id := range user_ids {
+		if e := km.Store.RotateUserDataKey(user_id, reencrypt); e != nil {
+			log.WithError(e).Warnf("[KeysManager] failed to rotate data key of user %s", user_id)
+			err = e
+			continue
+		}
+		log.Infof("[KeysManager] data key of user %s rotated", user_id)
+	}
+	return
+}
+
+func (km *KeysManager) Close() {
+	km.Store.Close()
+}
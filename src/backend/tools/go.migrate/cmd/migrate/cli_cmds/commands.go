@@ -0,0 +1,67 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/cassandra"
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+)
+
+var (
+	dryRun    bool
+	toVersion int
+	upCmd     = &cobra.Command{
+		Use:   "up",
+		Short: "Applies pending migrations",
+		Long: `up creates keyspace if needed, then applies pending migrations in version order.
+With --dry-run, statements of pending migrations are printed instead of being executed.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			m := newMigrator()
+			defer m.Close()
+			if err := m.Up(toVersion, dryRun, os.Stdout); err != nil {
+				log.WithError(err).Fatal("migration failed")
+			}
+		},
+	}
+	statusCmd = &cobra.Command{
+		Use:   "status",
+		Short: "Lists applied and pending migrations",
+		Run: func(cmd *cobra.Command, args []string) {
+			m := newMigrator()
+			defer m.Close()
+			status, err := m.Status()
+			if err != nil {
+				log.WithError(err).Fatal("can't read schema version")
+			}
+			for _, s := range status {
+				state := "pending"
+				if s.Applied {
+					state = "applied " + s.DateApplied.Format("2006-01-02 15:04:05")
+				}
+				if s.Modified {
+					state += " (file modified since)"
+				}
+				if s.Missing {
+					state += " (file not found)"
+				}
+				fmt.Printf("%04d %-32s %s\n", s.Version, s.Name, state)
+			}
+			fmt.Printf("backends require version %d\n", store.SchemaVersion)
+		},
+	}
+)
+
+func init() {
+	upCmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false,
+		"print statements without executing them")
+	upCmd.Flags().IntVarP(&toVersion, "to", "t", 0,
+		"apply migrations up to this version only")
+	RootCmd.AddCommand(upCmd, statusCmd)
+}
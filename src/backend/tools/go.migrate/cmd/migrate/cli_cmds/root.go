@@ -0,0 +1,78 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package cmd
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/tools/go.migrate"
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	configFile string
+	configPath string
+	verbose    bool
+	RootCmd    = &cobra.Command{
+		Use:   "migrate",
+		Short: "Cassandra schema migrations",
+		Long:  `migrate creates Caliopen's keyspace and applies CQL migrations in order, recording them into schema_version table`,
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.Help()
+		},
+	}
+)
+
+func init() {
+	RootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false,
+		"print out more debug information")
+	RootCmd.PersistentFlags().StringVarP(&configFile, "config", "c",
+		"caliopen-migrate_dev", "Name of the configuration file, without extension. (YAML, TOML, JSON… allowed)")
+	RootCmd.PersistentFlags().StringVarP(&configPath, "configpath", "",
+		"../../../../configs/", "Main config file path.")
+	RootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if verbose {
+			log.SetLevel(log.DebugLevel)
+		} else {
+			log.SetLevel(log.InfoLevel)
+		}
+	}
+}
+
+func newMigrator() *Migrator {
+	config := MigrateConfig{}
+	if err := readConfig(&config); err != nil {
+		log.WithError(err).Fatal("Error while reading config")
+	}
+	m, err := NewMigrator(config)
+	if err != nil {
+		log.WithError(err).Fatal("can't initialize migrator")
+	}
+	return m
+}
+
+func readConfig(config *MigrateConfig) error {
+	// load in the main config. Reading from YAML, TOML, JSON, HCL and Java properties config files
+	v := viper.New()
+	v.SetConfigName(configFile)                           // name of config file (without extension)
+	v.AddConfigPath(configPath)                           // path to look for the config file in
+	v.AddConfigPath("$CALIOPENROOT/src/backend/configs/") // call multiple times to add many search paths
+	v.AddConfigPath(".")                                  // optionally look for config in the working directory
+
+	err := v.ReadInConfig() // Find and read the config file
+	if err != nil {
+		log.WithError(err).Infof("Could not read main config file <%s>.", configFile)
+		return err
+	}
+	err = v.Unmarshal(config)
+	if err != nil {
+		log.WithError(err).Infof("Could not parse config file: <%s>", configFile)
+		return err
+	}
+
+	return nil
+}
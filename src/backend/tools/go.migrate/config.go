@@ -0,0 +1,20 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package go_migrate
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+)
+
+type MigrateConfig struct {
+	MigrationsPath    string      `mapstructure:"migrations_path"`    // directory of NNNN_name.cql files
+	ReplicationFactor int         `mapstructure:"replication_factor"` // for keyspace creation, SimpleStrategy
+	StoreName         string      `mapstructure:"store_name"`
+	StoreConfig       StoreConfig `mapstructure:"store_settings"`
+}
+
+const DefaultReplicationFactor = 1
@@ -0,0 +1,210 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+// Package go_migrate creates and upgrades Caliopen's Cassandra schema.
+// Migrations are CQL files applied in version order ; each applied migration is recorded into
+// the schema_version table, which Go backends check at startup.
+package go_migrate
+
+import (
+	"errors"
+	"fmt"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/cassandra"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gocql/gocql"
+	"io"
+	"sort"
+	"strings"
+	"time"
+)
+
+type Migrator struct {
+	Config     MigrateConfig
+	Migrations []Migration
+	cluster    *gocql.ClusterConfig
+	session    *gocql.Session // not bound to keyspace, which may not exist yet
+}
+
+// MigrationStatus is a migration found in migrations path and/or in schema_version table
+type MigrationStatus struct {
+	Version     int
+	Name        string
+	Applied     bool
+	DateApplied time.Time
+	Modified    bool // file has changed since it has been applied
+	Missing     bool // applied, but file is not found anymore
+}
+
+func NewMigrator(config MigrateConfig) (m *Migrator, err error) {
+	if config.StoreName != "cassandra" {
+		return nil, errors.New("[Migrator] unknown store backend " + config.StoreName)
+	}
+	if config.ReplicationFactor <= 0 {
+		config.ReplicationFactor = DefaultReplicationFactor
+	}
+	m = &Migrator{Config: config}
+	m.Migrations, err = LoadMigrations(config.MigrationsPath)
+	if err != nil {
+		return nil, fmt.Errorf("[Migrator] failed to load migrations from %s : %s", config.MigrationsPath, err)
+	}
+	if len(m.Migrations) > 0 && m.Migrations[len(m.Migrations)-1].Version != store.SchemaVersion {
+		log.Warnf("[Migrator] last migration is version %d, Go backends are built for version %d",
+			m.Migrations[len(m.Migrations)-1].Version, store.SchemaVersion)
+	}
+
+	m.cluster = gocql.NewCluster(config.StoreConfig.Hosts...)
+	m.cluster.Consistency = gocql.Consistency(config.StoreConfig.Consistency)
+	m.cluster.Timeout = 10 * time.Second // schema changes are slow
+	m.session, err = m.cluster.CreateSession()
+	if err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (m *Migrator) Close() {
+	m.session.Close()
+}
+
+// Status returns all migrations, applied or not, ordered by version.
+func (m *Migrator) Status() (status []MigrationStatus, err error) {
+	applied, err := m.applied()
+	if err != nil {
+		return nil, err
+	}
+	for _, migration := range m.Migrations {
+		s := MigrationStatus{
+			Version: migration.Version,
+			Name:    migration.Name,
+		}
+		if a, ok := applied[migration.Version]; ok {
+			s.Applied = true
+			s.DateApplied = a.DateApplied
+			s.Modified = a.checksum != migration.Checksum
+			delete(applied, migration.Version)
+		}
+		status = append(status, s)
+	}
+	// applied migrations without file
+	for _, a := range applied {
+		a.Missing = true
+		status = append(status, a.MigrationStatus)
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Version < status[j].Version })
+	return
+}
+
+// Up applies pending migrations up to version `to`, or all of them if `to` is 0.
+// With dryRun, statements are written to out instead of being executed.
+func (m *Migrator) Up(to int, dryRun bool, out io.Writer) error {
+	applied, err := m.applied()
+	if err != nil {
+		return err
+	}
+	pending := []Migration{}
+	for _, migration := range m.Migrations {
+		if _, ok := applied[migration.Version]; ok || (to > 0 && migration.Version > to) {
+			continue
+		}
+		pending = append(pending, migration)
+	}
+	if len(pending) == 0 {
+		log.Info("[Migrator] schema is up to date")
+		return nil
+	}
+
+	if dryRun {
+		for _, migration := range pending {
+			fmt.Fprintf(out, "-- %04d_%s\n", migration.Version, migration.Name)
+			for _, stmt := range migration.Statements {
+				fmt.Fprintf(out, "%s;\n", stmt)
+			}
+		}
+		return nil
+	}
+
+	session, err := m.keyspaceSession()
+	if err != nil {
+		return err
+	}
+	defer session.Close()
+	for _, migration := range pending {
+		log.Infof("[Migrator] applying migration %04d_%s", migration.Version, migration.Name)
+		for i, stmt := range migration.Statements {
+			if err := session.Query(stmt).Exec(); err != nil && !alreadyApplied(err) {
+				return fmt.Errorf("[Migrator] migration %04d_%s failed at statement %d : %s",
+					migration.Version, migration.Name, i+1, err)
+			}
+		}
+		err := session.Query(`INSERT INTO `+store.SchemaVersionTable+` (version, name, checksum, date_applied) VALUES (?, ?, ?, ?)`,
+			migration.Version, migration.Name, migration.Checksum, time.Now()).Exec()
+		if err != nil {
+			return fmt.Errorf("[Migrator] failed to record migration %04d_%s : %s", migration.Version, migration.Name, err)
+		}
+	}
+	log.Infof("[Migrator] schema is at version %d", pending[len(pending)-1].Version)
+	return nil
+}
+
+type appliedMigration struct {
+	MigrationStatus
+	checksum string
+}
+
+// applied returns the migrations recorded in schema_version, by version.
+// Nothing has been applied if keyspace or table do not exist yet.
+func (m *Migrator) applied() (applied map[int]*appliedMigration, err error) {
+	applied = map[int]*appliedMigration{}
+	var name string
+	err = m.session.Query(`SELECT table_name FROM system_schema.tables WHERE keyspace_name = ? AND table_name = ?`,
+		m.Config.StoreConfig.Keyspace, store.SchemaVersionTable).Scan(&name)
+	if err == gocql.ErrNotFound {
+		return applied, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	iter := m.session.Query(`SELECT version, name, checksum, date_applied FROM ` +
+		m.Config.StoreConfig.Keyspace + `.` + store.SchemaVersionTable).Iter()
+	a := appliedMigration{}
+	for iter.Scan(&a.Version, &a.Name, &a.checksum, &a.DateApplied) {
+		a.Applied = true
+		record := a
+		applied[a.Version] = &record
+	}
+	return applied, iter.Close()
+}
+
+// keyspaceSession creates keyspace and schema_version table if needed, then returns a session bound to keyspace.
+func (m *Migrator) keyspaceSession() (*gocql.Session, error) {
+	keyspace := m.Config.StoreConfig.Keyspace
+	err := m.session.Query(fmt.Sprintf(`CREATE KEYSPACE IF NOT EXISTS %s WITH replication = {'class': 'SimpleStrategy', 'replication_factor': %d}`,
+		keyspace, m.Config.ReplicationFactor)).Exec()
+	if err != nil {
+		return nil, fmt.Errorf("[Migrator] failed to create keyspace %s : %s", keyspace, err)
+	}
+	err = m.session.Query(`CREATE TABLE IF NOT EXISTS ` + keyspace + `.` + store.SchemaVersionTable + ` (
+    version int PRIMARY KEY,
+    name text,
+    checksum text,
+    date_applied timestamp
+)`).Exec()
+	if err != nil {
+		return nil, fmt.Errorf("[Migrator] failed to create %s table : %s", store.SchemaVersionTable, err)
+	}
+	cluster := *m.cluster
+	cluster.Keyspace = keyspace
+	return cluster.CreateSession()
+}
+
+// alreadyApplied returns true if statement failed because its change is already in schema,
+// so that a migration interrupted in the middle can be applied again.
+func alreadyApplied(err error) bool {
+	if _, ok := err.(*gocql.RequestErrAlreadyExists); ok {
+		return true
+	}
+	return strings.Contains(err.Error(), "conflicts with an existing column")
+}
@@ -0,0 +1,93 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package go_migrate
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Migration is a CQL file named NNNN_description.cql, NNNN being its version.
+type Migration struct {
+	Version    int
+	Name       string
+	Checksum   string // sha256 of file's content
+	Statements []string
+}
+
+var migrationFile = regexp.MustCompile(`^(\d+)_([\w-]+)\.cql$`)
+
+// LoadMigrations reads all migration files of dir, ordered by version.
+// Files that are not named like a migration are ignored.
+func LoadMigrations(dir string) (migrations []Migration, err error) {
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	versions := map[int]string{}
+	for _, f := range files {
+		match := migrationFile.FindStringSubmatch(f.Name())
+		if f.IsDir() || match == nil {
+			continue
+		}
+		version, _ := strconv.Atoi(match[1])
+		if version <= 0 {
+			return nil, fmt.Errorf("migration %s : version must be greater than 0", f.Name())
+		}
+		if previous, ok := versions[version]; ok {
+			return nil, fmt.Errorf("migrations %s and %s have the same version", previous, f.Name())
+		}
+		versions[version] = f.Name()
+		content, err := ioutil.ReadFile(filepath.Join(dir, f.Name()))
+		if err != nil {
+			return nil, err
+		}
+		sum := sha256.Sum256(content)
+		migrations = append(migrations, Migration{
+			Version:    version,
+			Name:       match[2],
+			Checksum:   hex.EncodeToString(sum[:]),
+			Statements: SplitStatements(content),
+		})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return
+}
+
+// SplitStatements splits a CQL script into statements. Statements end with a semicolon at end of line,
+// lines beginning with -- or // are comments.
+func SplitStatements(script []byte) (statements []string) {
+	var current []string
+	scanner := bufio.NewScanner(bytes.NewReader(script))
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \t\r")
+		trimmed := strings.TrimSpace(line)
+		if trimmed == "" || strings.HasPrefix(trimmed, "--") || strings.HasPrefix(trimmed, "//") {
+			continue
+		}
+		if strings.HasSuffix(line, ";") {
+			current = append(current, strings.TrimSuffix(line, ";"))
+			statements = append(statements, strings.Join(current, "\n"))
+			current = nil
+			continue
+		}
+		current = append(current, line)
+	}
+	if len(current) > 0 {
+		statements = append(statements, strings.Join(current, "\n"))
+	}
+	return
+}
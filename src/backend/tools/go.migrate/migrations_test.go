@@ -0,0 +1,78 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package go_migrate
+
+import (
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/cassandra"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSplitStatements(t *testing.T) {
+	script := `-- header comment
+CREATE TABLE a (
+    id int PRIMARY KEY
+);
+
+// another comment
+ALTER TABLE a ADD b text;
+INSERT INTO a (id) VALUES (1)
+`
+	stmts := SplitStatements([]byte(script))
+	if len(stmts) != 3 {
+		t.Fatalf("expected 3 statements, got %d : %v", len(stmts), stmts)
+	}
+	if stmts[1] != "ALTER TABLE a ADD b text" {
+		t.Errorf("unexpected statement %q", stmts[1])
+	}
+}
+
+func TestLoadMigrations(t *testing.T) {
+	dir, err := ioutil.TempDir("", "migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	files := map[string]string{
+		"0002_second.cql":  "CREATE TABLE b (id int PRIMARY KEY);",
+		"0001_initial.cql": "CREATE TABLE a (id int PRIMARY KEY);",
+		"README.md":        "not a migration",
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	migrations, err := LoadMigrations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Version != 1 || migrations[1].Name != "second" {
+		t.Errorf("unexpected migrations %+v", migrations)
+	}
+
+	ioutil.WriteFile(filepath.Join(dir, "0002_duplicate.cql"), []byte(""), 0644)
+	if _, err := LoadMigrations(dir); err == nil {
+		t.Error("expected an error for duplicate version")
+	}
+}
+
+// TestSchemaVersion checks that Go backends are built for the last migration of defs/cql
+func TestSchemaVersion(t *testing.T) {
+	migrations, err := LoadMigrations("../../defs/cql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) == 0 {
+		t.Fatal("no migration found in defs/cql")
+	}
+	if last := migrations[len(migrations)-1].Version; last != store.SchemaVersion {
+		t.Errorf("last migration is version %d, store.SchemaVersion is %d : bump SchemaVersion along with migrations", last, store.SchemaVersion)
+	}
+}
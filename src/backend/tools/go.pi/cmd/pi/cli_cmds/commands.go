@@ -0,0 +1,35 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package cmd
+
+import (
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	force      bool
+	rescoreCmd = &cobra.Command{
+		Use:   "rescore [user_id…]",
+		Short: "Scores again received messages of the given users, or of all users",
+		Long: `Messages whose privacy index has been computed by older rules are scored with current rules.
+rescore must be run once a new version of privacy index rules has been deployed.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			pm := newPIManager()
+			defer pm.Close()
+			if err := pm.Rescore(args, force); err != nil {
+				log.WithError(err).Fatal("scoring failed for some messages")
+			}
+		},
+	}
+)
+
+func init() {
+	rescoreCmd.Flags().BoolVarP(&force, "force", "f", false,
+		"also score messages already scored by current rules")
+	RootCmd.AddCommand(rescoreCmd)
+}
@@ -0,0 +1,23 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package go_pi
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+)
+
+type PIConfig struct {
+	AuthservID  string      `mapstructure:"authserv_id"` // authserv-id of the Authentication-Results headers added by our MTA, others are ignored
+	StoreName   string      `mapstructure:"store_name"`
+	StoreConfig StoreConfig `mapstructure:"store_settings"`
+	IndexName   string      `mapstructure:"index_name"`
+	IndexConfig IndexConfig `mapstructure:"index_settings"`
+}
+
+type IndexConfig struct {
+	Urls []string `mapstructure:"urls"`
+}
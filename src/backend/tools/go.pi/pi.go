@@ -0,0 +1,116 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+// Package go_pi handles administrative tasks on privacy indexes :
+// received messages are scored again once privacy index rules have changed.
+package go_pi
+
+import (
+	"errors"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/index/elasticsearch"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/cassandra"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/pi"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gocql/gocql"
+)
+
+type PIManager struct {
+	Config PIConfig
+	Engine *pi.Engine
+	Index  backends.PIRescoreIndex
+	Store  backends.PIRescoreStore
+}
+
+func NewPIManager(config PIConfig) (manager *PIManager, err error) {
+	engine := *pi.DefaultEngine
+	engine.AuthservID = config.AuthservID
+	pm := PIManager{
+		Config: config,
+		Engine: &engine,
+	}
+
+	switch config.StoreName {
+	case "cassandra":
+		c := store.CassandraConfig{
+			Hosts:       config.StoreConfig.Hosts,
+			Keyspace:    config.StoreConfig.Keyspace,
+			Consistency: gocql.Consistency(config.StoreConfig.Consistency),
+			SizeLimit:   config.StoreConfig.SizeLimit,
+		}
+		if config.StoreConfig.ObjectStore == "s3" || config.StoreConfig.ObjectStore == "fs" {
+			c.WithObjStore = true
+			c.StoreType = config.StoreConfig.ObjectStore
+			c.RootPath = config.StoreConfig.OSSConfig.RootPath
+			c.Endpoint = config.StoreConfig.OSSConfig.Endpoint
+			c.AccessKey = config.StoreConfig.OSSConfig.AccessKey
+			c.SecretKey = config.StoreConfig.OSSConfig.SecretKey
+			c.RawMsgBucket = config.StoreConfig.OSSConfig.Buckets["raw_messages"]
+			c.AttachmentBucket = config.StoreConfig.OSSConfig.Buckets["temporary_attachments"]
+			c.Location = config.StoreConfig.OSSConfig.Location
+		}
+		if config.StoreConfig.Encryption == "local" {
+			c.WithEncryption = true
+			c.KMSType = config.StoreConfig.Encryption
+			c.KeyringPath = config.StoreConfig.KMSConfig.KeyringPath
+		}
+		pm.Store, err = store.InitializeCassandraBackend(c)
+		if err != nil {
+			log.WithError(err).Warnf("[NewPIManager] initalization of %s backend failed", config.StoreName)
+			return nil, err
+		}
+	case "memory":
+		pm.Store, _ = memory.InitializeMemoryBackend()
+	default:
+		return nil, errors.New("[NewPIManager] unknown store backend " + config.StoreName)
+	}
+
+	switch config.IndexName {
+	case "elasticsearch":
+		c := index.ElasticSearchConfig{
+			Urls: config.IndexConfig.Urls,
+		}
+		pm.Index, err = index.InitializeElasticSearchIndex(c)
+		if err != nil {
+			log.WithError(err).Warnf("[NewPIManager] initalization of %s backend failed", config.IndexName)
+			return nil, err
+		}
+	case "memory":
+		pm.Index, _ = memory.InitializeMemoryIndex()
+	default:
+		return nil, errors.New("[NewPIManager] unknown index backend " + config.IndexName)
+	}
+	return &pm, nil
+}
+
+// Rescore scores again received messages of the given users, or of all users if user_ids is empty.
+// Only messages scored by older rules are scored, unless force is set.
+func (pm *PIManager) Rescore(user_ids []string, force bool) (err error) {
+	if len(user_ids) == 0 {
+		users, e := pm.Store.RetrieveAllUsersIds()
+		if e != nil {
+			return e
+		}
+		for user_id := range users {
+			user_ids = append(user_ids, user_id)
+		}
+	}
+	for _, user_id := range user_ids {
+		count, e := pm.Engine.Rescore(pm.Store, pm.Index, user_id, force)
+		if e != nil {
+			log.WithError(e).Warnf("[PIManager] failed to score some messages of user %s", user_id)
+			err = e
+		}
+		log.Infof("[PIManager] %d messages of user %s scored with rules version %d", count, user_id, pm.Engine.Version)
+	}
+	return
+}
+
+func (pm *PIManager) Close() {
+	pm.Store.Close()
+	pm.Index.Close()
+}
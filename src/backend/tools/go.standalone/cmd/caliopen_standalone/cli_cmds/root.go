@@ -0,0 +1,86 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package cmd
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/tools/go.standalone"
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	configFile string
+	configPath string
+	verbose    bool
+	version    bool
+	RootCmd    = &cobra.Command{
+		Use:   "caliopen_standalone",
+		Short: "Caliopen backend within a single process",
+		Long: `caliopen_standalone runs REST API, lmtp server, purger and indexer within a single process,
+on memory backends and bus : neither Cassandra, Elasticsearch, Redis nor NATS is needed. Nothing is persisted.`,
+		Run: nil,
+	}
+)
+
+const __version__ = "0.1.0"
+
+func init() {
+	RootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false,
+		"print out more debug information")
+	RootCmd.PersistentFlags().BoolVarP(&version, "version", "V", false,
+		"print out the version of this program")
+	RootCmd.Run = func(cmd *cobra.Command, args []string) {
+		if version {
+			log.Infof("caliopen_standalone version %s", __version__)
+		}
+		if len(args) == 0 {
+			cmd.Help()
+		}
+	}
+	RootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if verbose {
+			log.SetLevel(log.DebugLevel)
+		} else {
+			log.SetLevel(log.InfoLevel)
+		}
+	}
+	RootCmd.AddCommand(versionCmd)
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version number of caliopen_standalone",
+	Long:  `All software has versions. This is caliopen_standalone's`,
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Infof("caliopen_standalone version %s", __version__)
+	},
+}
+
+// ReadConfig which should be called at startup
+func readConfig(config *StandaloneConfig) error {
+	// load in the main config. Reading from YAML, TOML, JSON, HCL and Java properties config files
+	v := viper.New()
+	v.SetConfigName(configFile)                           // name of config file (without extension)
+	v.AddConfigPath(configPath)                           // path to look for the config file in
+	v.AddConfigPath("$CALIOPENROOT/src/backend/configs/") // call multiple times to add many search paths
+	v.AddConfigPath(".")                                  // optionally look for config in the working directory
+
+	err := v.ReadInConfig() // Find and read the config file
+	if err != nil {
+		log.WithError(err).Infof("Could not read main config file <%s>.", configFile)
+		return err
+	}
+	err = v.Unmarshal(config)
+	if err != nil {
+		log.WithError(err).Infof("Could not parse config file: <%s>", configFile)
+		return err
+	}
+	config.AppConfig.AppVersion = __version__
+	config.LDAConfig.AppVersion = __version__
+	return nil
+}
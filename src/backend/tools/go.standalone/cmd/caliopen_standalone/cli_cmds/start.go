@@ -0,0 +1,83 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package cmd
+
+import (
+	"fmt"
+	standalone "github.com/CaliOpen/Caliopen/src/backend/tools/go.standalone"
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+var (
+	pidFile       string
+	signalChannel chan os.Signal
+	cmdConfig     standalone.StandaloneConfig
+	startCmd      = &cobra.Command{
+		Use:   "start",
+		Short: "Starts REST API, lmtp server, purger and indexer",
+		Run:   start,
+	}
+)
+
+func init() {
+	startCmd.PersistentFlags().StringVarP(&configFile, "config", "c",
+		"caliopen-standalone_dev", "Name of the configuration file, without extension. (YAML, TOML, JSON… allowed)")
+	startCmd.PersistentFlags().StringVarP(&configPath, "configpath", "",
+		"../../../../configs/", "Main config file path.")
+	startCmd.PersistentFlags().StringVarP(&pidFile, "pid-file", "p",
+		"/var/run/caliopen_standalone.pid", "Path to the pid file")
+
+	RootCmd.AddCommand(startCmd)
+	signalChannel = make(chan os.Signal, 1)
+}
+
+func sigHandler(s *standalone.Standalone) {
+	// configuration is not reloaded on SIGHUP : components' state would be lost with memory backends
+	signal.Notify(signalChannel, syscall.SIGTERM, syscall.SIGQUIT, syscall.SIGINT)
+
+	for range signalChannel {
+		log.Info("Shutdown signal caught")
+		s.Stop()
+		log.Info("Shutdown completed, exiting")
+		os.Exit(0)
+	}
+}
+
+func start(cmd *cobra.Command, args []string) {
+
+	err := readConfig(&cmdConfig)
+	if err != nil {
+		log.WithError(err).Fatal("Error while reading config")
+	}
+	// Write out our PID
+	if len(pidFile) > 0 {
+		if f, err := os.Create(pidFile); err == nil {
+			defer f.Close()
+			if _, err := f.WriteString(fmt.Sprintf("%d", os.Getpid())); err == nil {
+				f.Sync()
+			} else {
+				log.WithError(err).Fatalf("Error while writing pidFile (%s)", pidFile)
+			}
+		} else {
+			log.WithError(err).Fatalf("Error while creating pidFile (%s)", pidFile)
+		}
+	}
+
+	s, err := standalone.NewStandalone(cmdConfig)
+	if err != nil {
+		log.WithError(err).Fatal("invalid configuration")
+	}
+	if err = s.Start(); err != nil {
+		log.WithError(err).Fatal("can't start caliopen standalone")
+	}
+	log.Info("caliopen standalone started")
+	sigHandler(s)
+}
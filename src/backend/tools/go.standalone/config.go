@@ -0,0 +1,33 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package go_standalone
+
+import (
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server"
+	csmtp "github.com/CaliOpen/Caliopen/src/backend/protocols/go.smtp"
+	indexer "github.com/CaliOpen/Caliopen/src/backend/workers/go.indexer"
+	purger "github.com/CaliOpen/Caliopen/src/backend/workers/go.purge"
+)
+
+// StandaloneConfig gathers the configurations of all components run by the process,
+// with the same sections as their own configuration files.
+// Backends and NATS settings are ignored : all components share the process' memory backends and bus.
+type StandaloneConfig struct {
+	APIConfig        rest_api.APIConfig       `mapstructure:"ApiConfig"`
+	csmtp.SMTPConfig `mapstructure:",squash"` // AppConfig and LDAConfig sections
+	PurgerConfig     purger.PurgerConfig      `mapstructure:"PurgerConfig"`
+	IndexerConfig    indexer.IndexerConfig    `mapstructure:"IndexerConfig"`
+	Users            []UserSeed               `mapstructure:"users"`
+}
+
+// UserSeed is an user created at startup, since memory backends start empty and there is no Python API to sign up.
+type UserSeed struct {
+	UserId        string `mapstructure:"user_id"`
+	Name          string `mapstructure:"name"`
+	LocalIdentity string `mapstructure:"local_identity"` // email address delivered by lmtp server to this user
+	AccessToken   string `mapstructure:"access_token"`   // to authenticate API requests as this user, without device
+}
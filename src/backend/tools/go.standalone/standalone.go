@@ -0,0 +1,152 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+// Package go_standalone runs the REST API, the lmtp server with its email broker, the purger and the indexer
+// within a single process, on shared memory backends and bus : neither Cassandra, Elasticsearch, Redis nor NATS is needed.
+// Nothing is persisted, it is meant for demos and development.
+package go_standalone
+
+import (
+	"errors"
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/interfaces/REST/go.server"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
+	csmtp "github.com/CaliOpen/Caliopen/src/backend/protocols/go.smtp"
+	indexer "github.com/CaliOpen/Caliopen/src/backend/workers/go.indexer"
+	purger "github.com/CaliOpen/Caliopen/src/backend/workers/go.purge"
+	log "github.com/Sirupsen/logrus"
+	"github.com/satori/go.uuid"
+	"time"
+)
+
+const (
+	memoryName = "memory"
+	tokenTTL   = 365 * 24 * time.Hour // seeded tokens outlive the process
+)
+
+type Standalone struct {
+	Config  StandaloneConfig
+	purger  *purger.Purger
+	indexer *indexer.Indexer
+}
+
+// NewStandalone checks config, then sets all components onto memory backends and bus
+func NewStandalone(config StandaloneConfig) (*Standalone, error) {
+	if len(config.AppConfig.AllowedHosts) == 0 {
+		return nil, errors.New("[Standalone] empty `allowed_hosts` is not allowed")
+	}
+	config.LDAConfig.PrimaryMailHost = config.AppConfig.PrimaryMailHost
+
+	api := &config.APIConfig
+	api.BackendConfig.BackendName = memoryName
+	api.IndexConfig.IndexName = memoryName
+	api.CacheSettings.CacheName = memoryName
+	api.NatsConfig.Url = memoryName
+	// drafts are sent and notifications are emailed by the broker of this process
+	api.NatsConfig.OutSMTP_topic = config.LDAConfig.OutTopic
+	api.NatsConfig.Contacts_topic = config.LDAConfig.ContactsTopic
+	lda := &config.LDAConfig
+	lda.StoreName, lda.IndexName, lda.NatsURL = memoryName, memoryName, memoryName
+	config.PurgerConfig.StoreName = memoryName
+	config.PurgerConfig.IndexName = memoryName
+	config.PurgerConfig.CacheConfig.CacheName = memoryName
+	config.IndexerConfig.StoreName = memoryName
+	config.IndexerConfig.IndexName = memoryName
+
+	return &Standalone{Config: config}, nil
+}
+
+// Start seeds users, then initializes and starts all components.
+// It returns once they are started, servers listen in background.
+func (s *Standalone) Start() (err error) {
+	if err = s.seedUsers(); err != nil {
+		return err
+	}
+	// notifiers of API and broker look up the admin user when they are initialized
+	if err = rest_api.InitializeServer(s.Config.APIConfig); err != nil {
+		return err
+	}
+	if err = csmtp.InitializeServer(s.Config.SMTPConfig); err != nil {
+		return err
+	}
+	if s.purger, err = purger.NewPurger(s.Config.PurgerConfig); err != nil {
+		return err
+	}
+	if s.indexer, err = indexer.NewIndexer(s.Config.IndexerConfig); err != nil {
+		return err
+	}
+
+	go csmtp.StartServer()
+	go func() {
+		if err := rest_api.StartServer(); err != nil {
+			log.WithError(err).Fatal("[Standalone] REST API stopped")
+		}
+	}()
+	go func() {
+		if err := s.purger.Start(); err != nil {
+			log.WithError(err).Fatal("[Standalone] can't schedule purge")
+		}
+	}()
+	go func() {
+		if err := s.indexer.Start(); err != nil {
+			log.WithError(err).Fatal("[Standalone] can't schedule indexer")
+		}
+	}()
+	return nil
+}
+
+func (s *Standalone) Stop() {
+	csmtp.ShutdownServer()
+	if s.purger != nil {
+		s.purger.Stop()
+	}
+	if s.indexer != nil {
+		s.indexer.Stop()
+	}
+}
+
+// seedUsers creates configured users into memory backends, with their local identity and access token
+func (s *Standalone) seedUsers() error {
+	store, _ := memory.InitializeMemoryBackend()
+	cache, _ := memory.InitializeMemoryCache()
+	for _, seed := range s.Config.Users {
+		id, err := uuid.FromString(seed.UserId)
+		if err != nil {
+			return fmt.Errorf("[Standalone] invalid user_id for user %s : %s", seed.Name, err)
+		}
+		user := &User{
+			DateInsert:      time.Now(),
+			LocalIdentities: []string{},
+			Name:            seed.Name,
+			UserId:          UUID(id),
+		}
+		if err = store.CreateUser(user); err != nil {
+			return err
+		}
+		if seed.LocalIdentity != "" {
+			err = store.CreateLocalIdentity(&LocalIdentity{
+				Display_name: seed.Name,
+				Identifier:   seed.LocalIdentity,
+				Status:       "active",
+				Type:         "local",
+				User_id:      user.UserId,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		if seed.AccessToken != "" {
+			cache.SetAuthToken("tokens::"+seed.UserId, &Auth_cache{
+				Access_token: seed.AccessToken,
+				Expires_in:   int(tokenTTL.Seconds()),
+				Expires_at:   time.Now().Add(tokenTTL),
+			})
+		}
+		log.Infof("[Standalone] user %s (%s) created", seed.Name, seed.UserId)
+	}
+	return nil
+}
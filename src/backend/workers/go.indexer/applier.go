@@ -0,0 +1,42 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package go_indexer
+
+import (
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/outbox"
+	log "github.com/Sirupsen/logrus"
+)
+
+// ApplyOutbox applies the pending mutations of all users.
+// Mutations that already failed MaxAttempts times are removed from outbox without being applied :
+// the resulting divergence will be repaired by next consistency check.
+func (ix *Indexer) ApplyOutbox() (applied, failed, dropped int, err error) {
+	mutations, err := ix.Store.RetrieveIndexMutations()
+	if err != nil {
+		return
+	}
+	for mutation := range mutations {
+		if mutation.Attempts >= ix.Config.MaxAttempts {
+			log.Errorf("[Indexer] dropping %s of %s %s for user %s after %d attempts, last error : %s",
+				mutation.Operation, mutation.ResourceType, mutation.ResourceId.String(), mutation.UserId.String(),
+				mutation.Attempts, mutation.LastError)
+			if e := ix.Store.DeleteIndexMutation(mutation.UserId.String(), mutation.MutationId.String()); e != nil {
+				log.WithError(e).Warnf("[Indexer] failed to drop mutation %s", mutation.MutationId.String())
+				continue
+			}
+			dropped++
+			continue
+		}
+		if e := outbox.Apply(ix.Store, ix.Index, mutation); e != nil {
+			log.WithError(e).Debugf("[Indexer] failed to apply mutation %s", mutation.MutationId.String())
+			failed++
+			continue
+		}
+		applied++
+	}
+	return
+}
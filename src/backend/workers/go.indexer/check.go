@@ -0,0 +1,298 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package go_indexer
+
+import (
+	"encoding/json"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/outbox"
+	log "github.com/Sirupsen/logrus"
+	"github.com/satori/go.uuid"
+	"reflect"
+	"sort"
+	"time"
+)
+
+// Divergence is a resource that is not the same in store and in index
+type Divergence struct {
+	ResourceType string // MessageType or ContactType
+	ResourceId   string
+	Operation    string   // operation of the index mutation that repairs the divergence
+	Fields       []string // for an IndexUpdate, struct fields names whose value is not the same in index
+}
+
+// CheckUser compares user's messages and contacts in store with their indexed documents and returns divergences :
+// - resources missing from index are to be created,
+// - indexed documents that differ from store are to be updated, or created again if the differing properties can't be mapped to struct fields,
+// - indexed documents without resource in store are to be deleted.
+// Unless dryRun is set, divergences are repaired through index outbox, as if store had just been written.
+func (ix *Indexer) CheckUser(userId string, dryRun bool) (divergences []Divergence, err error) {
+	report := func(d Divergence) {
+		divergences = append(divergences, d)
+	}
+
+	indexedMessages, err := ix.indexedSet(userId, MessageIndexType)
+	if err != nil {
+		return
+	}
+	if err = ix.checkMessages(userId, indexedMessages, report); err != nil {
+		return
+	}
+	for id := range indexedMessages {
+		report(Divergence{ResourceType: MessageType, ResourceId: id, Operation: IndexDelete})
+	}
+
+	indexedContacts, err := ix.indexedSet(userId, ContactIndexType)
+	if err != nil {
+		return
+	}
+	if err = ix.checkContacts(userId, indexedContacts, report); err != nil {
+		return
+	}
+	for id := range indexedContacts {
+		report(Divergence{ResourceType: ContactType, ResourceId: id, Operation: IndexDelete})
+	}
+
+	if !dryRun {
+		ix.repair(userId, divergences)
+	}
+	return
+}
+
+// repair records and applies a mutation for each divergence.
+// Mutations are applied with what is in store at that time, thus resources written since the check are not harmed.
+func (ix *Indexer) repair(userId string, divergences []Divergence) {
+	userUUID := UUID(uuid.FromStringOrNil(userId))
+	for _, d := range divergences {
+		mutation, err := outbox.Record(ix.Store, userUUID, d.ResourceType, UUID(uuid.FromStringOrNil(d.ResourceId)), d.Operation, d.Fields...)
+		if err != nil {
+			log.WithError(err).Warnf("[Indexer] failed to repair %s %s of user %s", d.ResourceType, d.ResourceId, userId)
+			continue
+		}
+		if err = outbox.Apply(ix.Store, ix.Index, mutation); err != nil {
+			log.WithError(err).Warnf("[Indexer] repair of %s %s of user %s left to outbox", d.ResourceType, d.ResourceId, userId)
+		}
+	}
+}
+
+// indexedSet returns the ids of user's indexed documents of docType.
+// checks remove ids found in store, the remaining ones are orphans.
+func (ix *Indexer) indexedSet(userId, docType string) (map[string]bool, error) {
+	ids, err := ix.Index.IndexedIds(userId, docType)
+	if err != nil {
+		return nil, err
+	}
+	set := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set, nil
+}
+
+func (ix *Indexer) checkMessages(userId string, indexed map[string]bool, report func(Divergence)) error {
+	messages, err := ix.Store.RetrieveAllMessages(userId)
+	if err != nil {
+		return err
+	}
+	batch := make([]*Message, 0, ix.Config.BatchSize)
+	compare := func() error {
+		ids := make([]string, len(batch))
+		for i, msg := range batch {
+			ids[i] = msg.Message_id.String()
+		}
+		docs, err := ix.Index.IndexedMessages(userId, ids)
+		if err != nil {
+			return err
+		}
+		for _, msg := range batch {
+			id := msg.Message_id.String()
+			doc, found := docs[id]
+			if !found {
+				report(Divergence{ResourceType: MessageType, ResourceId: id, Operation: IndexCreate})
+				continue
+			}
+			// excerpt is not stored, it can't diverge
+			msg.Body_excerpt = doc.Body_excerpt
+			stored, _ := msg.MarshalES()
+			current, _ := doc.MarshalES()
+			if op, fields := diff(stored, current, msg.JsonTags()); op != "" {
+				report(Divergence{ResourceType: MessageType, ResourceId: id, Operation: op, Fields: fields})
+			}
+		}
+		return nil
+	}
+
+	// channel is always drained, to release store's iterator
+	for msg := range messages {
+		if err != nil {
+			continue
+		}
+		id := msg.Message_id.String()
+		if !indexed[id] {
+			report(Divergence{ResourceType: MessageType, ResourceId: id, Operation: IndexCreate})
+			continue
+		}
+		delete(indexed, id)
+		batch = append(batch, msg)
+		if len(batch) == ix.Config.BatchSize {
+			err = compare()
+			batch = batch[:0]
+		}
+	}
+	if err == nil && len(batch) > 0 {
+		err = compare()
+	}
+	return err
+}
+
+func (ix *Indexer) checkContacts(userId string, indexed map[string]bool, report func(Divergence)) error {
+	contacts, err := ix.Store.RetrieveAllContacts(userId)
+	if err != nil {
+		return err
+	}
+	batch := make([]*Contact, 0, ix.Config.BatchSize)
+	compare := func() error {
+		ids := make([]string, len(batch))
+		for i, contact := range batch {
+			ids[i] = contact.ContactId.String()
+		}
+		docs, err := ix.Index.IndexedContacts(userId, ids)
+		if err != nil {
+			return err
+		}
+		for _, contact := range batch {
+			id := contact.ContactId.String()
+			doc, found := docs[id]
+			if !found {
+				report(Divergence{ResourceType: ContactType, ResourceId: id, Operation: IndexCreate})
+				continue
+			}
+			stored, _ := contact.MarshelES()
+			current, _ := doc.MarshelES()
+			if op, fields := diff(stored, current, contact.JsonTags()); op != "" {
+				report(Divergence{ResourceType: ContactType, ResourceId: id, Operation: op, Fields: fields})
+			}
+		}
+		return nil
+	}
+
+	for contact := range contacts {
+		if err != nil {
+			continue
+		}
+		id := contact.ContactId.String()
+		if !indexed[id] {
+			report(Divergence{ResourceType: ContactType, ResourceId: id, Operation: IndexCreate})
+			continue
+		}
+		delete(indexed, id)
+		batch = append(batch, contact)
+		if len(batch) == ix.Config.BatchSize {
+			err = compare()
+			batch = batch[:0]
+		}
+	}
+	if err == nil && len(batch) > 0 {
+		err = compare()
+	}
+	return err
+}
+
+// diff compares the index representations of a resource, as in store and as in index.
+// It returns IndexUpdate with the struct fields that differ, IndexCreate if some differing property has no struct field,
+// or an empty operation if both are the same.
+func diff(stored, indexed []byte, tags map[string]string) (operation string, fields []string) {
+	var s, i map[string]interface{}
+	if json.Unmarshal(stored, &s) != nil || json.Unmarshal(indexed, &i) != nil {
+		return IndexCreate, nil
+	}
+	keys := map[string]bool{}
+	for key := range s {
+		keys[key] = true
+	}
+	for key := range i {
+		keys[key] = true
+	}
+	for key := range keys {
+		if equivalent(s[key], i[key]) {
+			continue
+		}
+		field, ok := tags[key]
+		if !ok {
+			return IndexCreate, nil
+		}
+		fields = append(fields, field)
+	}
+	if len(fields) == 0 {
+		return "", nil
+	}
+	sort.Strings(fields)
+	return IndexUpdate, fields
+}
+
+// equivalent tells if two decoded JSON values hold the same data :
+// null and empty values are the same, dates are compared at store's precision (milliseconds).
+func equivalent(a, b interface{}) bool {
+	if isEmpty(a) && isEmpty(b) {
+		return true
+	}
+	switch av := a.(type) {
+	case string:
+		bv, ok := b.(string)
+		if !ok {
+			return false
+		}
+		if av == bv {
+			return true
+		}
+		at, errA := time.Parse(time.RFC3339Nano, av)
+		bt, errB := time.Parse(time.RFC3339Nano, bv)
+		return errA == nil && errB == nil && at.Truncate(time.Millisecond).Equal(bt.Truncate(time.Millisecond))
+	case []interface{}:
+		bv, ok := b.([]interface{})
+		if !ok || len(av) != len(bv) {
+			return false
+		}
+		for k := range av {
+			if !equivalent(av[k], bv[k]) {
+				return false
+			}
+		}
+		return true
+	case map[string]interface{}:
+		bv, ok := b.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		for k := range av {
+			if !equivalent(av[k], bv[k]) {
+				return false
+			}
+		}
+		for k := range bv {
+			if _, ok := av[k]; !ok && !isEmpty(bv[k]) {
+				return false
+			}
+		}
+		return true
+	}
+	return reflect.DeepEqual(a, b)
+}
+
+func isEmpty(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case string:
+		return value == ""
+	case []interface{}:
+		return len(value) == 0
+	case map[string]interface{}:
+		return len(value) == 0
+	}
+	return false
+}
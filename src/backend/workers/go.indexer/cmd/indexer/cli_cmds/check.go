@@ -0,0 +1,66 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package cmd
+
+import (
+	indexer "github.com/CaliOpen/Caliopen/src/backend/workers/go.indexer"
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"strings"
+)
+
+var (
+	checkUserId string
+	checkDryRun bool
+	checkCmd    = &cobra.Command{
+		Use:   "check",
+		Short: "Checks that an user's index is consistent with store",
+		Long: `check compares user's messages and contacts in store with their indexed documents, and repairs divergences.
+With --dry-run, divergences are only reported.`,
+		Run: check,
+	}
+)
+
+func init() {
+	checkCmd.Flags().StringVarP(&checkUserId, "user", "u", "", "id of the user to check (required)")
+	checkCmd.Flags().BoolVarP(&checkDryRun, "dry-run", "", false, "report divergences without repairing them")
+	checkCmd.PersistentFlags().StringVarP(&configFile, "config", "c",
+		"caliopen-indexer_dev", "Name of the configuration file, without extension. (YAML, TOML, JSON… allowed)")
+	checkCmd.PersistentFlags().StringVarP(&configPath, "configpath", "",
+		"../../../../configs/", "Main config file path.")
+
+	RootCmd.AddCommand(checkCmd)
+}
+
+func check(cmd *cobra.Command, args []string) {
+	if checkUserId == "" {
+		cmd.Help()
+		log.Fatal("user id is required")
+	}
+	err := readConfig(&cmdConfig)
+	if err != nil {
+		log.WithError(err).Fatal("Error while reading config")
+	}
+	ix, err := indexer.NewIndexer(cmdConfig)
+	if err != nil {
+		log.WithError(err).Fatal("can't initialize indexer")
+	}
+	defer ix.Stop()
+
+	divergences, err := ix.CheckUser(checkUserId, checkDryRun)
+	if err != nil {
+		log.WithError(err).Fatalf("failed to check index of user %s", checkUserId)
+	}
+	for _, d := range divergences {
+		log.Infof("%s %s : %s %s", d.ResourceType, d.ResourceId, d.Operation, strings.Join(d.Fields, ", "))
+	}
+	if checkDryRun {
+		log.Infof("%d divergences found for user %s", len(divergences), checkUserId)
+	} else {
+		log.Infof("%d divergences repaired for user %s", len(divergences), checkUserId)
+	}
+}
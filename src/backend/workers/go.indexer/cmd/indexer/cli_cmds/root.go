@@ -0,0 +1,86 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package cmd
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/workers/go.indexer"
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var (
+	config     IndexerConfig
+	configFile string
+	configPath string
+	verbose    bool
+	version    bool
+	RootCmd    = &cobra.Command{
+		Use:   "indexer",
+		Short: "Index outbox and consistency daemon",
+		Long:  `indexer is a daemon to replicate store writes to index and to repair store/index divergences`,
+		Run:   nil,
+	}
+)
+
+const __version__ = "0.1.0"
+
+func init() {
+	RootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false,
+		"print out more debug information")
+	RootCmd.PersistentFlags().BoolVarP(&version, "version", "V", false,
+		"print out the version of this program")
+	RootCmd.Run = func(cmd *cobra.Command, args []string) {
+		if version {
+			log.Infof("indexer version %s", __version__)
+		}
+		if len(args) == 0 {
+			cmd.Help()
+		}
+		readConfig(&config)
+	}
+	RootCmd.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		if verbose {
+			log.SetLevel(log.DebugLevel)
+		} else {
+			log.SetLevel(log.InfoLevel)
+		}
+	}
+	RootCmd.AddCommand(versionCmd)
+}
+
+var versionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Print the version number of indexer",
+	Long:  `All software has versions. This is indexer's'`,
+	Run: func(cmd *cobra.Command, args []string) {
+		log.Infof("indexer version %s", __version__)
+	},
+}
+
+// ReadConfig which should be called at startup, or when a SIG_HUP is caught
+func readConfig(config *IndexerConfig) error {
+	// load in the main config. Reading from YAML, TOML, JSON, HCL and Java properties config files
+	v := viper.New()
+	v.SetConfigName(configFile)                           // name of config file (without extension)
+	v.AddConfigPath(configPath)                           // path to look for the config file in
+	v.AddConfigPath("$CALIOPENROOT/src/backend/configs/") // call multiple times to add many search paths
+	v.AddConfigPath(".")                                  // optionally look for config in the working directory
+
+	err := v.ReadInConfig() // Find and read the config file
+	if err != nil {
+		log.WithError(err).Infof("Could not read main config file <%s>.", configFile)
+		return err
+	}
+	err = v.Unmarshal(config)
+	if err != nil {
+		log.WithError(err).Infof("Could not parse config file: <%s>", configFile)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,32 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package go_indexer
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+)
+
+type IndexerConfig struct {
+	ScanInterval  uint16      `mapstructure:"scan_interval"`  // in seconds
+	CheckInterval uint16      `mapstructure:"check_interval"` // in minutes, 0 disables periodic consistency check
+	BatchSize     int         `mapstructure:"batch_size"`     // max documents fetched at once from index when checking consistency
+	MaxAttempts   int         `mapstructure:"max_attempts"`   // a mutation failing that many times is dropped
+	StoreName     string      `mapstructure:"store_name"`
+	StoreConfig   StoreConfig `mapstructure:"store_settings"`
+	IndexName     string      `mapstructure:"index_name"`
+	IndexConfig   IndexConfig `mapstructure:"index_settings"`
+}
+
+type IndexConfig struct {
+	Urls []string `mapstructure:"urls"`
+}
+
+const (
+	DefaultScanInterval = 10
+	DefaultBatchSize    = 500
+	DefaultMaxAttempts  = 20
+)
@@ -0,0 +1,153 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+// Package go_indexer keeps index in line with store :
+// - it applies the index mutations left in store's outbox by facilities, retrying the ones that failed,
+// - it periodically checks that index holds the same messages and contacts than store, and repairs divergences.
+package go_indexer
+
+import (
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/index/elasticsearch"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/cassandra"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gocql/gocql"
+	"gopkg.in/robfig/cron.v2"
+	"strconv"
+	"sync/atomic"
+)
+
+type Indexer struct {
+	Config   IndexerConfig
+	Index    backends.IndexerIndex
+	MainCron *cron.Cron
+	Store    backends.IndexerStore
+	applying int32 // set to 1 while outbox is being applied
+	checking int32 // set to 1 while consistency is being checked
+}
+
+func NewIndexer(config IndexerConfig) (indexer *Indexer, err error) {
+	ix := Indexer{
+		Config:   config,
+		MainCron: cron.New(),
+	}
+	if ix.Config.ScanInterval == 0 {
+		ix.Config.ScanInterval = DefaultScanInterval
+	}
+	if ix.Config.BatchSize <= 0 {
+		ix.Config.BatchSize = DefaultBatchSize
+	}
+	if ix.Config.MaxAttempts <= 0 {
+		ix.Config.MaxAttempts = DefaultMaxAttempts
+	}
+
+	// Store
+	switch config.StoreName {
+	case "cassandra":
+		c := store.CassandraConfig{
+			Hosts:       config.StoreConfig.Hosts,
+			Keyspace:    config.StoreConfig.Keyspace,
+			Consistency: gocql.Consistency(config.StoreConfig.Consistency),
+			SizeLimit:   config.StoreConfig.SizeLimit,
+		}
+		ix.Store, err = store.InitializeCassandraBackend(c)
+		if err != nil {
+			log.WithError(err).Warnf("[NewIndexer] initalization of %s backend failed", config.StoreName)
+			return nil, err
+		}
+	case "memory":
+		ix.Store, _ = memory.InitializeMemoryBackend()
+	}
+
+	// Index
+	switch config.IndexName {
+	case "elasticsearch":
+		c := index.ElasticSearchConfig{
+			Urls: config.IndexConfig.Urls,
+		}
+		ix.Index, err = index.InitializeElasticSearchIndex(c)
+		if err != nil {
+			log.WithError(err).Warnf("[NewIndexer] initalization of %s backend failed", config.IndexName)
+			return nil, err
+		}
+	case "memory":
+		ix.Index, _ = memory.InitializeMemoryIndex()
+	}
+
+	return &ix, nil
+}
+
+func (ix *Indexer) Start() error {
+	cronStr := "@every " + strconv.Itoa(int(ix.Config.ScanInterval)) + "s"
+	_, err := ix.MainCron.AddFunc(cronStr, ix.applyOutbox)
+	if err != nil {
+		return err
+	}
+	if ix.Config.CheckInterval > 0 {
+		cronStr = "@every " + strconv.Itoa(int(ix.Config.CheckInterval)) + "m"
+		_, err = ix.MainCron.AddFunc(cronStr, ix.checkAll)
+		if err != nil {
+			return err
+		}
+	}
+	// apply pending mutations once before starting MainCron
+	ix.applyOutbox()
+	ix.MainCron.Start()
+	return nil
+}
+
+func (ix *Indexer) Stop() {
+	ix.MainCron.Stop()
+	ix.Store.Close()
+	ix.Index.Close()
+}
+
+// applyOutbox applies all pending mutations.
+// A run is skipped if previous one has not completed yet.
+func (ix *Indexer) applyOutbox() {
+	if !atomic.CompareAndSwapInt32(&ix.applying, 0, 1) {
+		log.Debug("[Indexer] previous outbox run is still running, skipping this one")
+		return
+	}
+	defer atomic.StoreInt32(&ix.applying, 0)
+
+	applied, failed, dropped, err := ix.ApplyOutbox()
+	if err != nil {
+		log.WithError(err).Warn("[Indexer] failed to retrieve index outbox")
+		return
+	}
+	if applied+failed+dropped > 0 {
+		log.Infof("[Indexer] %d mutations applied, %d failed, %d dropped.", applied, failed, dropped)
+	}
+}
+
+// checkAll checks consistency of all users' index and repairs divergences.
+// A run is skipped if previous one has not completed yet.
+func (ix *Indexer) checkAll() {
+	if !atomic.CompareAndSwapInt32(&ix.checking, 0, 1) {
+		log.Warn("[Indexer] previous consistency check is still running, skipping this one")
+		return
+	}
+	defer atomic.StoreInt32(&ix.checking, 0)
+
+	users, err := ix.Store.RetrieveAllUsersIds()
+	if err != nil {
+		log.WithError(err).Warn("[Indexer] failed to retrieve users")
+		return
+	}
+	var count, repaired int
+	for userId := range users {
+		divergences, err := ix.CheckUser(userId, false)
+		if err != nil {
+			log.WithError(err).Warnf("[Indexer] failed to check index of user %s", userId)
+			continue
+		}
+		count++
+		repaired += len(divergences)
+	}
+	log.Infof("[Indexer] index of %d users checked, %d divergences repaired.", count, repaired)
+}
@@ -0,0 +1,187 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package go_purge
+
+import (
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	log "github.com/Sirupsen/logrus"
+	"strings"
+	"time"
+)
+
+// deleteAccounts deletes the accounts whose grace period has passed and resumes the interrupted deletions.
+// It returns how many accounts have been deleted.
+func (p *Purger) deleteAccounts() (count int) {
+	deletions, err := p.Store.RetrieveUserDeletions()
+	if err != nil {
+		log.WithError(err).Warn("[Purger] failed to retrieve accounts' deletions")
+		return
+	}
+	due := time.Now().AddDate(0, 0, -p.Config.DeletionGrace)
+	todo := []*UserDeletion{}
+	for deletion := range deletions {
+		if deletion.Status == DeletionRunning || (deletion.Status == DeletionPending && deletion.DateInsert.Before(due)) {
+			todo = append(todo, deletion)
+		}
+	}
+	for _, deletion := range todo {
+		if err := p.runDeletion(deletion); err != nil {
+			log.WithError(err).Warnf("[Purger] failed to delete account of user %s", deletion.UserId.String())
+			continue
+		}
+		count++
+	}
+	return
+}
+
+// DeleteAccount deletes user's account and all its data at once, whether its deletion has been requested or not.
+// This is the administrator's override of the grace period. Nothing is done if account has already been deleted.
+func (p *Purger) DeleteAccount(userId string) error {
+	deletion, err := p.Store.RetrieveUserDeletion(userId)
+	if err != nil {
+		if err.Error() != "not found" {
+			return err
+		}
+		user, err := p.Store.RetrieveUser(userId)
+		if err != nil {
+			return err
+		}
+		deletion = &UserDeletion{
+			DateInsert:  time.Now(),
+			RequestedBy: DeletionByAdmin,
+			Status:      DeletionPending,
+			Steps:       []string{},
+			UserId:      user.UserId,
+		}
+		if err = p.Store.CreateUserDeletion(deletion); err != nil {
+			return err
+		}
+		user.DateDelete = deletion.DateInsert
+		if err = p.Store.UpdateUser(user, map[string]interface{}{"DateDelete": user.DateDelete}); err != nil {
+			return err
+		}
+	}
+	if deletion.Status == DeletionDone {
+		return nil
+	}
+	return p.runDeletion(deletion)
+}
+
+// CancelAccountDeletion restores an account whose deletion is still pending : user is able to log in again.
+func (p *Purger) CancelAccountDeletion(userId string) error {
+	deletion, err := p.Store.RetrieveUserDeletion(userId)
+	if err != nil {
+		return err
+	}
+	if deletion.Status != DeletionPending {
+		return fmt.Errorf("deletion of user %s is %s, it can't be cancelled", userId, deletion.Status)
+	}
+	user, err := p.Store.RetrieveUser(userId)
+	if err != nil {
+		return err
+	}
+	user.DateDelete = time.Time{}
+	if err = p.Store.UpdateUser(user, map[string]interface{}{"DateDelete": nil}); err != nil {
+		return err
+	}
+	return p.Store.DeleteUserDeletion(userId)
+}
+
+// runDeletion runs the deletion's steps that have not been completed yet.
+// Each completed step is recorded, thus a failed deletion resumes at the failed step on next run.
+func (p *Purger) runDeletion(deletion *UserDeletion) error {
+	userId := deletion.UserId.String()
+	if deletion.Status != DeletionRunning {
+		deletion.Status = DeletionRunning
+		if err := p.Store.UpdateUserDeletion(deletion, map[string]interface{}{"Status": deletion.Status}); err != nil {
+			return err
+		}
+	}
+	user, err := p.Store.RetrieveUser(userId)
+	if err != nil {
+		if !strings.HasSuffix(err.Error(), "not found") {
+			return err
+		}
+		// user has been removed by an interrupted run, before account step has been recorded
+		user = nil
+	}
+
+	for _, step := range DeletionSteps {
+		if deletion.HasStep(step) {
+			continue
+		}
+		if err := p.runDeletionStep(step, userId, user); err != nil {
+			deletion.Error = step + " : " + err.Error()
+			p.Store.UpdateUserDeletion(deletion, map[string]interface{}{"Error": deletion.Error})
+			return err
+		}
+		deletion.Steps = append(deletion.Steps, step)
+		if err := p.Store.UpdateUserDeletion(deletion, map[string]interface{}{"Steps": deletion.Steps}); err != nil {
+			return err
+		}
+	}
+
+	deletion.Status = DeletionDone
+	deletion.DateEnd = time.Now()
+	deletion.Error = ""
+	log.Infof("[Purger] account of user %s deleted", userId)
+	return p.Store.UpdateUserDeletion(deletion, map[string]interface{}{
+		"DateEnd": deletion.DateEnd,
+		"Error":   deletion.Error,
+		"Status":  deletion.Status,
+	})
+}
+
+func (p *Purger) runDeletionStep(step, userId string, user *User) error {
+	switch step {
+	case DeletionStepSessions:
+		if p.Cache == nil {
+			log.Warnf("[Purger] no cache configured, sessions of user %s will only end when they expire", userId)
+			return nil
+		}
+		return p.Cache.DeleteUserSessions(userId)
+	case DeletionStepIndex:
+		return p.Index.DeleteUserIndex(userId)
+	case DeletionStepMessages:
+		return p.deleteMessages(userId)
+	case DeletionStepArchives:
+		return p.Store.DeleteUserArchives(userId)
+	case DeletionStepData:
+		return p.Store.DeleteUserPartitions(userId)
+	case DeletionStepAccount:
+		if user == nil {
+			return nil
+		}
+		return p.Store.DeleteUserAccount(user)
+	}
+	return fmt.Errorf("unknown deletion step %s", step)
+}
+
+// deleteMessages releases the attachments and raw message of each user's message before deleting the message,
+// then releases the raw messages of user that are not referenced by any message.
+// References are released idempotently, thus this step can be run again after a failure.
+func (p *Purger) deleteMessages(userId string) error {
+	messages, err := p.Store.RetrieveAllMessages(userId)
+	if err != nil {
+		return err
+	}
+	var failed error
+	for msg := range messages {
+		if failed != nil {
+			// channel must be drained
+			continue
+		}
+		if failed = p.Store.ReleaseMessageRefs(msg); failed == nil {
+			failed = p.Store.DeleteMessage(msg)
+		}
+	}
+	if failed != nil {
+		return failed
+	}
+	return p.Store.DeleteUserRawMessages(userId)
+}
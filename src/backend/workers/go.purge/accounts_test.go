@@ -0,0 +1,89 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package go_purge
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/satori/go.uuid"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDeleteAccountResumes(t *testing.T) {
+	p, store, _ := newTestPurger()
+	alice, bob := UUID(uuid.FromStringOrNil(aliceId)), UUID(uuid.FromStringOrNil(bobId))
+	store.CreateUser(&User{UserId: alice, Name: "alice"})
+	store.CreateUser(&User{UserId: bob, Name: "bob"})
+	store.CreateSettings(&Settings{UserId: bob, TrashRetentionDays: 30})
+
+	// alice and bob received the same message, they share its raw message and attachment
+	raw := &RawMessage{Raw_msg_id: UUID(uuid.NewV4()), Raw_data: "Subject: hello\r\n\r\nhello"}
+	if err := store.StoreRawMessage(raw, []UUID{alice, bob}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	messages := map[string]*Message{}
+	for _, userId := range []string{aliceId, bobId} {
+		uri, _, err := store.StoreAttachment(userId, "", strings.NewReader("attached file"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := newMessage(userId, now.AddDate(0, 0, -60), time.Time{})
+		msg.Raw_msg_id = raw.Raw_msg_id
+		msg.Attachments = []Attachment{{FileName: "file.txt", URL: uri}}
+		createMessage(t, p, msg)
+		messages[userId] = msg
+	}
+
+	// first run is interrupted after message's references have been released
+	store.failures = 1
+	if err := p.DeleteAccount(aliceId); err == nil {
+		t.Fatal("expected interrupted deletion to fail")
+	}
+	deletion, err := store.RetrieveUserDeletion(aliceId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if deletion.Status != DeletionRunning || !strings.HasPrefix(deletion.Error, DeletionStepMessages) {
+		t.Errorf("expected deletion to be running with an error at messages step, got %s : %s", deletion.Status, deletion.Error)
+	}
+
+	if err = p.DeleteAccount(aliceId); err != nil {
+		t.Fatal(err)
+	}
+	deletion, _ = store.RetrieveUserDeletion(aliceId)
+	if deletion.Status != DeletionDone || len(deletion.Steps) != len(DeletionSteps) {
+		t.Errorf("expected deletion done with all steps, got %s with steps %v", deletion.Status, deletion.Steps)
+	}
+	if _, err = store.RetrieveUser(aliceId); err == nil {
+		t.Error("user has not been deleted")
+	}
+	if _, err = store.RetrieveMessage(aliceId, messages[aliceId].Message_id.String()); err == nil {
+		t.Error("message of deleted user has not been deleted")
+	}
+	uri := messages[bobId].Attachments[0].URL
+	if !store.AttachmentExists(uri) {
+		t.Error("attachment still referenced by bob's message has been removed")
+	}
+	if _, err = store.GetRawMessage(raw.Raw_msg_id.String()); err != nil {
+		t.Errorf("raw message still referenced by bob's message has been removed : %s", err)
+	}
+
+	// bob's message holds the last references
+	bobMsg := messages[bobId]
+	bobMsg.Date_delete = now.AddDate(0, 0, -40)
+	store.UpdateMessage(bobMsg, map[string]interface{}{"Date_delete": bobMsg.Date_delete})
+	p.Index.UpdateMessage(bobMsg, map[string]interface{}{"Date_delete": bobMsg.Date_delete})
+	if count := p.purgeTrash(bobId); count != 1 {
+		t.Fatalf("expected 1 message purged, got %d", count)
+	}
+	if store.AttachmentExists(uri) {
+		t.Error("attachment not referenced anymore has not been removed")
+	}
+	if _, err = store.GetRawMessage(raw.Raw_msg_id.String()); err == nil {
+		t.Error("raw message not referenced anymore has not been removed")
+	}
+}
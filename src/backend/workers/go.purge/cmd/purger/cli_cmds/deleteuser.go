@@ -0,0 +1,64 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package cmd
+
+import (
+	purger "github.com/CaliOpen/Caliopen/src/backend/workers/go.purge"
+	log "github.com/Sirupsen/logrus"
+	"github.com/spf13/cobra"
+)
+
+var (
+	deleteUserId  string
+	deleteCancel  bool
+	deleteUserCmd = &cobra.Command{
+		Use:   "deleteuser",
+		Short: "Deletes an user's account now, or cancels its pending deletion",
+		Long: `deleteuser deletes at once an user's account with all its data, without waiting for the grace period.
+An interrupted deletion is resumed where it stopped. With --cancel, a pending deletion is cancelled and user is able to log in again.`,
+		Run: deleteUser,
+	}
+)
+
+func init() {
+	deleteUserCmd.Flags().StringVarP(&deleteUserId, "user", "u", "", "id of the user to delete (required)")
+	deleteUserCmd.Flags().BoolVarP(&deleteCancel, "cancel", "", false, "cancel a pending deletion instead of deleting")
+	deleteUserCmd.PersistentFlags().StringVarP(&configFile, "config", "c",
+		"caliopen-purger_dev", "Name of the configuration file, without extension. (YAML, TOML, JSON… allowed)")
+	deleteUserCmd.PersistentFlags().StringVarP(&configPath, "configpath", "",
+		"../../../../configs/", "Main config file path.")
+
+	RootCmd.AddCommand(deleteUserCmd)
+}
+
+func deleteUser(cmd *cobra.Command, args []string) {
+	if deleteUserId == "" {
+		cmd.Help()
+		log.Fatal("user id is required")
+	}
+	err := readConfig(&cmdConfig)
+	if err != nil {
+		log.WithError(err).Fatal("Error while reading config")
+	}
+	p, err := purger.NewPurger(cmdConfig)
+	if err != nil {
+		log.WithError(err).Fatal("can't initialize purger")
+	}
+	defer p.Stop()
+
+	if deleteCancel {
+		if err = p.CancelAccountDeletion(deleteUserId); err != nil {
+			log.WithError(err).Fatalf("failed to cancel deletion of user %s", deleteUserId)
+		}
+		log.Infof("deletion of user %s cancelled", deleteUserId)
+		return
+	}
+	if err = p.DeleteAccount(deleteUserId); err != nil {
+		log.WithError(err).Fatalf("failed to delete user %s", deleteUserId)
+	}
+	log.Infof("user %s deleted", deleteUserId)
+}
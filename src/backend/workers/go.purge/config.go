@@ -0,0 +1,32 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package go_purge
+
+import (
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+)
+
+type PurgerConfig struct {
+	ScanInterval  uint16      `mapstructure:"scan_interval"` // in minutes
+	BatchSize     int         `mapstructure:"batch_size"`    // max messages to process for each user and each policy at each run
+	StoreName     string      `mapstructure:"store_name"`
+	StoreConfig   StoreConfig `mapstructure:"store_settings"`
+	IndexName     string      `mapstructure:"index_name"`
+	IndexConfig   IndexConfig `mapstructure:"index_settings"`
+	CacheConfig   CacheConfig `mapstructure:"cache_settings"`
+	DeletionGrace int         `mapstructure:"user_deletion_grace"` // in days, delay before data of a deleted account is purged
+}
+
+type IndexConfig struct {
+	Urls []string `mapstructure:"urls"`
+}
+
+const (
+	DefaultBatchSize      = 500
+	DefaultDeletionGrace  = 30
+	DefaultTrashRetention = 30 // in days, when user's trash_retention_days is unset or 0
+)
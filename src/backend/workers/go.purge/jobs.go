@@ -0,0 +1,122 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+package go_purge
+
+import (
+	"fmt"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.main/outbox"
+	log "github.com/Sirupsen/logrus"
+	"time"
+)
+
+// expireTaggedMessages moves to trash the messages that carry a tag with an expiry delay,
+// once this delay has passed since messages' reception.
+// It returns how many messages have been moved.
+func (p *Purger) expireTaggedMessages(userId string) (count int) {
+	tags, err := p.Store.RetrieveUserTags(userId)
+	if err != nil {
+		// user has no tag
+		return
+	}
+	now := time.Now()
+	for _, tag := range tags {
+		if tag.Expiry_days <= 0 {
+			continue
+		}
+		before := now.AddDate(0, 0, -int(tag.Expiry_days))
+		messages, err := p.Index.TaggedMessagesBefore(userId, tag.Name, before, p.Config.BatchSize)
+		if err != nil {
+			log.WithError(err).Warnf("[Purger] failed to retrieve expired messages for tag %s of user %s", tag.Name, userId)
+			continue
+		}
+		for _, msg := range messages {
+			mutation, err := outbox.Record(p.Store, msg.User_id, MessageType, msg.Message_id, IndexUpdate, "Date_delete")
+			if err != nil {
+				log.WithError(err).Warnf("[Purger] failed to record index mutation for message %s", msg.Message_id.String())
+				continue
+			}
+			msg.Date_delete = now
+			if err := p.Store.UpdateMessage(msg, map[string]interface{}{"Date_delete": msg.Date_delete}); err != nil {
+				log.WithError(err).Warnf("[Purger] failed to move message %s to trash in store", msg.Message_id.String())
+				continue
+			}
+			outbox.ApplyOrDefer(p.Store, p.Index, mutation)
+			count++
+		}
+	}
+	return
+}
+
+// purgeTrash definitively deletes the messages that are in trash for longer than user's retention period.
+// Retention period defaults to DefaultTrashRetention if user's setting is unset or 0.
+// It returns how many messages have been deleted.
+func (p *Purger) purgeTrash(userId string) (count int) {
+	settings, err := p.Store.GetSettings(userId)
+	if err != nil {
+		log.WithError(err).Warnf("[Purger] failed to retrieve settings of user %s", userId)
+		return
+	}
+	retention := settings.TrashRetentionDays
+	if retention <= 0 {
+		retention = DefaultTrashRetention
+	}
+	before := time.Now().AddDate(0, 0, -retention)
+	messages, err := p.Index.TrashedMessagesBefore(userId, before, p.Config.BatchSize)
+	if err != nil {
+		log.WithError(err).Warnf("[Purger] failed to retrieve trashed messages of user %s", userId)
+		return
+	}
+	for _, msg := range messages {
+		if err := p.purgeMessage(msg); err != nil {
+			log.WithError(err).Warnf("[Purger] failed to purge message %s of user %s", msg.Message_id.String(), userId)
+			continue
+		}
+		count++
+	}
+	return
+}
+
+// purgeMessage releases message's attachments and raw message, then deletes message itself and its index entry.
+// References are released idempotently and index entry is removed last, through index outbox,
+// thus a message that failed to be purged is found again and purged at next run, without releasing anything twice.
+func (p *Purger) purgeMessage(indexed *Message) error {
+	userId, msgId := indexed.User_id.String(), indexed.Message_id.String()
+	msg, err := p.Store.RetrieveMessage(userId, msgId)
+	if err != nil {
+		if err.Error() == "not found" {
+			// message has been removed from store during a previous run, its index entry is left
+			return p.deleteIndexedMessage(indexed)
+		}
+		return err
+	}
+	if msg.Date_delete.IsZero() {
+		return fmt.Errorf("message %s has been restored from trash", msgId)
+	}
+
+	if err := p.Store.ReleaseMessageRefs(msg); err != nil {
+		return err
+	}
+	mutation, err := outbox.Record(p.Store, msg.User_id, MessageType, msg.Message_id, IndexDelete)
+	if err != nil {
+		return err
+	}
+	if err := p.Store.DeleteMessage(msg); err != nil {
+		return err
+	}
+	outbox.ApplyOrDefer(p.Store, p.Index, mutation)
+	return nil
+}
+
+// deleteIndexedMessage removes the index entry of a message that is not in store anymore
+func (p *Purger) deleteIndexedMessage(msg *Message) error {
+	mutation, err := outbox.Record(p.Store, msg.User_id, MessageType, msg.Message_id, IndexDelete)
+	if err != nil {
+		return err
+	}
+	return outbox.Apply(p.Store, p.Index, mutation)
+}
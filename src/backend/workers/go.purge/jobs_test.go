@@ -0,0 +1,216 @@
+// Copyleft (ɔ) 2018 The Caliopen contributors.
+// Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+// license (AGPL) that can be found in the LICENSE file.
+
+package go_purge
+
+import (
+	"errors"
+	. "github.com/CaliOpen/Caliopen/src/backend/defs/go-objects"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
+	"github.com/satori/go.uuid"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	aliceId = "8a8fdb3d-cd41-4988-a0a5-80ea2df2633e"
+	bobId   = "5032ba23-f172-45d7-a600-7cb4089bd458"
+)
+
+// failingStore fails to delete messages a given number of times, as an interrupted purge would
+type failingStore struct {
+	*memory.MemoryBackend
+	failures int
+}
+
+func (s *failingStore) DeleteMessage(msg *Message) error {
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("connection lost")
+	}
+	return s.MemoryBackend.DeleteMessage(msg)
+}
+
+func newTestPurger() (*Purger, *failingStore, *memory.MemoryIndex) {
+	store := &failingStore{MemoryBackend: memory.NewMemoryBackend()}
+	index := memory.NewMemoryIndex()
+	return &Purger{
+		Config: PurgerConfig{BatchSize: 10},
+		Index:  index,
+		Store:  store,
+	}, store, index
+}
+
+// createMessage puts a message of user into store and index
+func createMessage(t *testing.T, p *Purger, msg *Message) {
+	if err := p.Store.(*failingStore).CreateMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := p.Index.(*memory.MemoryIndex).CreateMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func newMessage(userId string, inserted, deleted time.Time) *Message {
+	return &Message{
+		User_id:     UUID(uuid.FromStringOrNil(userId)),
+		Message_id:  UUID(uuid.NewV4()),
+		Date_insert: inserted,
+		Date_delete: deleted,
+		Attachments: []Attachment{},
+		Tags:        []string{},
+	}
+}
+
+func TestExpireTaggedMessages(t *testing.T) {
+	p, store, index := newTestPurger()
+	store.CreateTag(&Tag{User_id: UUID(uuid.FromStringOrNil(aliceId)), Name: "newsletter", Expiry_days: 7})
+	store.CreateTag(&Tag{User_id: UUID(uuid.FromStringOrNil(aliceId)), Name: "work"})
+
+	now := time.Now()
+	expired := newMessage(aliceId, now.AddDate(0, 0, -10), time.Time{})
+	expired.Tags = []string{"newsletter"}
+	recent := newMessage(aliceId, now.AddDate(0, 0, -2), time.Time{})
+	recent.Tags = []string{"newsletter"}
+	neverExpires := newMessage(aliceId, now.AddDate(0, 0, -100), time.Time{})
+	neverExpires.Tags = []string{"work"}
+	for _, msg := range []*Message{expired, recent, neverExpires} {
+		createMessage(t, p, msg)
+	}
+
+	if count := p.expireTaggedMessages(aliceId); count != 1 {
+		t.Errorf("expected 1 message moved to trash, got %d", count)
+	}
+	for _, msg := range []*Message{expired, recent, neverExpires} {
+		stored, err := store.RetrieveMessage(aliceId, msg.Message_id.String())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if trashed := !stored.Date_delete.IsZero(); trashed != (msg == expired) {
+			t.Errorf("message tagged %v received at %s : expected in trash to be %v", msg.Tags, msg.Date_insert, msg == expired)
+		}
+	}
+	trashed, _ := index.TrashedMessagesBefore(aliceId, now.Add(time.Minute), 10)
+	if len(trashed) != 1 || trashed[0].Message_id.String() != expired.Message_id.String() {
+		t.Errorf("expected expired message to be in trash in index, got %v", trashed)
+	}
+	if count := p.expireTaggedMessages(aliceId); count != 0 {
+		t.Errorf("expected messages already in trash to be left as is, got %d moved", count)
+	}
+}
+
+func TestPurgeTrash(t *testing.T) {
+	p, store, index := newTestPurger()
+	alice, bob := UUID(uuid.FromStringOrNil(aliceId)), UUID(uuid.FromStringOrNil(bobId))
+	store.CreateSettings(&Settings{UserId: alice, TrashRetentionDays: 30})
+	store.CreateSettings(&Settings{UserId: bob, TrashRetentionDays: 30})
+
+	// alice and bob received the same message, they share its raw message and attachment
+	raw := &RawMessage{Raw_msg_id: UUID(uuid.NewV4()), Raw_data: "Subject: hello\r\n\r\nhello"}
+	if err := store.StoreRawMessage(raw, []UUID{alice, bob}); err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	messages := map[string]*Message{}
+	for _, userId := range []string{aliceId, bobId} {
+		uri, _, err := store.StoreAttachment(userId, "", strings.NewReader("attached file"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		msg := newMessage(userId, now.AddDate(0, 0, -60), now.AddDate(0, 0, -40))
+		msg.Raw_msg_id = raw.Raw_msg_id
+		msg.Attachments = []Attachment{{FileName: "file.txt", URL: uri}}
+		createMessage(t, p, msg)
+		messages[userId] = msg
+	}
+	recent := newMessage(aliceId, now.AddDate(0, 0, -60), now.AddDate(0, 0, -2))
+	createMessage(t, p, recent)
+	// restored from trash, but not yet updated in index
+	restored := newMessage(aliceId, now.AddDate(0, 0, -60), time.Time{})
+	createMessage(t, p, restored)
+	restored.Date_delete = now.AddDate(0, 0, -40)
+	index.UpdateMessage(restored, map[string]interface{}{"Date_delete": restored.Date_delete})
+
+	// first run is interrupted after references have been released
+	store.failures = 1
+	if count := p.purgeTrash(aliceId); count != 0 {
+		t.Errorf("expected interrupted purge to delete nothing, got %d", count)
+	}
+	if count := p.purgeTrash(aliceId); count != 1 {
+		t.Errorf("expected 1 message purged, got %d", count)
+	}
+	uri := messages[aliceId].Attachments[0].URL
+	if !store.AttachmentExists(uri) {
+		t.Error("attachment still referenced by bob's message has been removed")
+	}
+	if _, err := store.GetRawMessage(raw.Raw_msg_id.String()); err != nil {
+		t.Errorf("raw message still referenced by bob's message has been removed : %s", err)
+	}
+	for msg, expected := range map[*Message]bool{messages[aliceId]: false, recent: true, restored: true} {
+		if _, err := store.RetrieveMessage(aliceId, msg.Message_id.String()); (err == nil) != expected {
+			t.Errorf("message deleted %s ago : expected in store to be %v, got error %v", now.Sub(msg.Date_delete), expected, err)
+		}
+	}
+	if trashed, _ := index.TrashedMessagesBefore(aliceId, now.AddDate(0, 0, -30), 10); len(trashed) != 1 {
+		t.Errorf("expected only restored message to be found again in index, got %d messages", len(trashed))
+	}
+
+	if count := p.purgeTrash(bobId); count != 1 {
+		t.Errorf("expected 1 message purged, got %d", count)
+	}
+	if store.AttachmentExists(uri) {
+		t.Error("attachment not referenced anymore has not been removed")
+	}
+	if _, err := store.GetRawMessage(raw.Raw_msg_id.String()); err == nil {
+		t.Error("raw message not referenced anymore has not been removed")
+	}
+
+	// unset retention falls back to default one
+	store.CreateSettings(&Settings{UserId: alice, TrashRetentionDays: 0})
+	if count := p.purgeTrash(aliceId); count != 0 {
+		t.Errorf("expected nothing purged within default retention, got %d", count)
+	}
+	old := newMessage(aliceId, now.AddDate(0, 0, -60), now.AddDate(0, 0, -DefaultTrashRetention-1))
+	createMessage(t, p, old)
+	if count := p.purgeTrash(aliceId); count != 1 {
+		t.Errorf("expected 1 message purged after default retention, got %d", count)
+	}
+}
+
+func TestPurgeTrashSameRawMessage(t *testing.T) {
+	p, store, _ := newTestPurger()
+	alice := UUID(uuid.FromStringOrNil(aliceId))
+	store.CreateSettings(&Settings{UserId: alice, TrashRetentionDays: 30})
+
+	// alice received the same content twice, both messages share its raw message
+	raw := &RawMessage{Raw_msg_id: UUID(uuid.NewV4()), Raw_data: "Subject: hello\r\n\r\nhello"}
+	now := time.Now()
+	var messages []*Message
+	for _, deleted := range []time.Time{now.AddDate(0, 0, -40), now.AddDate(0, 0, -2)} {
+		if err := store.StoreRawMessage(raw, []UUID{alice}); err != nil {
+			t.Fatal(err)
+		}
+		msg := newMessage(aliceId, now.AddDate(0, 0, -60), deleted)
+		msg.Raw_msg_id = raw.Raw_msg_id
+		createMessage(t, p, msg)
+		messages = append(messages, msg)
+	}
+
+	store.failures = 1
+	p.purgeTrash(aliceId)
+	if count := p.purgeTrash(aliceId); count != 1 {
+		t.Errorf("expected 1 message purged, got %d", count)
+	}
+	if _, err := store.GetRawMessage(raw.Raw_msg_id.String()); err != nil {
+		t.Errorf("raw message still referenced by alice's other message has been removed : %s", err)
+	}
+
+	if err := store.ReleaseMessageRefs(messages[1]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.GetRawMessage(raw.Raw_msg_id.String()); err == nil {
+		t.Error("raw message not referenced anymore has not been removed")
+	}
+}
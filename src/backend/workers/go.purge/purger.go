@@ -0,0 +1,146 @@
+/*
+ * // Copyleft (ɔ) 2018 The Caliopen contributors.
+ * // Use of this source code is governed by a GNU AFFERO GENERAL PUBLIC
+ * // license (AGPL) that can be found in the LICENSE file.
+ */
+
+// Package go_purge enforces messages retention policies :
+// - messages carrying a tag with an expiry delay are moved to trash once this delay has passed,
+// - messages that are in trash for longer than user's retention period are definitively deleted,
+// with their raw message, attachments and index entry.
+// It also deletes the accounts whose deletion has been requested, once their grace period has passed.
+package go_purge
+
+import (
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/cache/redis"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/index/elasticsearch"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/cassandra"
+	log "github.com/Sirupsen/logrus"
+	"github.com/gocql/gocql"
+	"gopkg.in/robfig/cron.v2"
+	"strconv"
+	"sync/atomic"
+)
+
+type Purger struct {
+	Cache    backends.UserSessionsCache
+	Config   PurgerConfig
+	Index    backends.PurgerIndex
+	MainCron *cron.Cron
+	Store    backends.PurgerStore
+	running  int32 // set to 1 while a purge is in progress
+}
+
+func NewPurger(config PurgerConfig) (purger *Purger, err error) {
+	p := Purger{
+		Config:   config,
+		MainCron: cron.New(),
+	}
+	if p.Config.BatchSize <= 0 {
+		p.Config.BatchSize = DefaultBatchSize
+	}
+	if p.Config.DeletionGrace <= 0 {
+		p.Config.DeletionGrace = DefaultDeletionGrace
+	}
+
+	// Store
+	switch config.StoreName {
+	case "cassandra":
+		c := store.CassandraConfig{
+			Hosts:       config.StoreConfig.Hosts,
+			Keyspace:    config.StoreConfig.Keyspace,
+			Consistency: gocql.Consistency(config.StoreConfig.Consistency),
+			SizeLimit:   config.StoreConfig.SizeLimit,
+		}
+		if config.StoreConfig.ObjectStore == "s3" || config.StoreConfig.ObjectStore == "fs" {
+			c.WithObjStore = true
+			c.StoreType = config.StoreConfig.ObjectStore
+			c.RootPath = config.StoreConfig.OSSConfig.RootPath
+			c.Endpoint = config.StoreConfig.OSSConfig.Endpoint
+			c.AccessKey = config.StoreConfig.OSSConfig.AccessKey
+			c.SecretKey = config.StoreConfig.OSSConfig.SecretKey
+			c.RawMsgBucket = config.StoreConfig.OSSConfig.Buckets["raw_messages"]
+			c.AttachmentBucket = config.StoreConfig.OSSConfig.Buckets["temporary_attachments"]
+			c.Location = config.StoreConfig.OSSConfig.Location
+		}
+		p.Store, err = store.InitializeCassandraBackend(c)
+		if err != nil {
+			log.WithError(err).Warnf("[NewPurger] initalization of %s backend failed", config.StoreName)
+			return nil, err
+		}
+	case "memory":
+		p.Store, _ = memory.InitializeMemoryBackend()
+	}
+
+	// Index
+	switch config.IndexName {
+	case "elasticsearch":
+		c := index.ElasticSearchConfig{
+			Urls: config.IndexConfig.Urls,
+		}
+		p.Index, err = index.InitializeElasticSearchIndex(c)
+		if err != nil {
+			log.WithError(err).Warnf("[NewPurger] initalization of %s backend failed", config.IndexName)
+			return nil, err
+		}
+	case "memory":
+		p.Index, _ = memory.InitializeMemoryIndex()
+	}
+
+	// Cache
+	if config.CacheConfig.CacheName == "memory" {
+		p.Cache, _ = memory.InitializeMemoryCache()
+	} else if config.CacheConfig.Host != "" {
+		p.Cache, err = cache.InitializeRedisBackend(config.CacheConfig)
+		if err != nil {
+			log.WithError(err).Warn("[NewPurger] initalization of cache backend failed")
+			return nil, err
+		}
+	}
+
+	return &p, nil
+}
+
+func (p *Purger) Start() error {
+	cronStr := "@every " + strconv.Itoa(int(p.Config.ScanInterval)) + "m"
+	_, err := p.MainCron.AddFunc(cronStr, p.purge)
+	if err != nil {
+		return err
+	}
+	// run purge() once before starting MainCron
+	p.purge()
+	p.MainCron.Start()
+	return nil
+}
+
+func (p *Purger) Stop() {
+	p.MainCron.Stop()
+	p.Store.Close()
+	p.Index.Close()
+}
+
+// purge iterates over all users to apply retention policies.
+// A run is skipped if previous one has not completed yet.
+func (p *Purger) purge() {
+	if !atomic.CompareAndSwapInt32(&p.running, 0, 1) {
+		log.Warn("[Purger] previous purge is still running, skipping this one")
+		return
+	}
+	defer atomic.StoreInt32(&p.running, 0)
+
+	deleted := p.deleteAccounts()
+
+	users, err := p.Store.RetrieveAllUsersIds()
+	if err != nil {
+		log.WithError(err).Warn("[Purger] failed to retrieve users")
+		return
+	}
+	var expired, purged int
+	for userId := range users {
+		expired += p.expireTaggedMessages(userId)
+		purged += p.purgeTrash(userId)
+	}
+	log.Infof("[Purger] %d accounts deleted, %d messages moved to trash, %d messages purged.", deleted, expired, purged)
+}
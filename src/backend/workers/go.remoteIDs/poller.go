@@ -8,6 +8,7 @@ package go_remoteIDs
 
 import (
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends"
+	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/memory"
 	"github.com/CaliOpen/Caliopen/src/backend/main/go.backends/store/cassandra"
 	log "github.com/Sirupsen/logrus"
 	"github.com/gocql/gocql"
@@ -47,8 +48,10 @@ func NewPoller(config PollerConfig) (poller *Poller, err error) {
 			Consistency: gocql.Consistency(config.StoreConfig.Consistency),
 			SizeLimit:   config.StoreConfig.SizeLimit,
 		}
-		if config.StoreConfig.ObjectStore == "s3" {
+		if config.StoreConfig.ObjectStore == "s3" || config.StoreConfig.ObjectStore == "fs" {
 			c.WithObjStore = true
+			c.StoreType = config.StoreConfig.ObjectStore
+			c.RootPath = config.StoreConfig.OSSConfig.RootPath
 			c.Endpoint = config.StoreConfig.OSSConfig.Endpoint
 			c.AccessKey = config.StoreConfig.OSSConfig.AccessKey
 			c.SecretKey = config.StoreConfig.OSSConfig.SecretKey
@@ -61,6 +64,8 @@ func NewPoller(config PollerConfig) (poller *Poller, err error) {
 			log.WithError(err).Warnf("[NewWorker] initalization of %s backend failed", config.StoreName)
 			return nil, err
 		}
+	case "memory":
+		p.Store, _ = memory.InitializeMemoryBackend()
 	}
 
 	return &p, nil